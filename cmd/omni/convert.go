@@ -0,0 +1,40 @@
+package main
+
+import (
+	"github.com/wayneeseguin/omni/pkg/reader"
+)
+
+// runConvert rewrites the entries of one file or standard input in another format
+func runConvert(e *env, args []string) error {
+	fs := newFlagSet(e, "convert", "-to json|logfmt|text|msgpack|cbor [-from auto|json|logfmt|text|msgpack|cbor] [FILE]")
+	to := fs.String("to", "", "Output `format`: json, logfmt, text, msgpack or cbor")
+	from := fs.String("from", "auto", "Input `format`: auto, json, logfmt, text, msgpack or cbor")
+
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *to == "" {
+		return usageError(e, fs, "-to is required")
+	}
+	if fs.NArg() > 1 {
+		return usageError(e, fs, "expected at most one file")
+	}
+
+	format, err := parseFormat(*from)
+	if err != nil {
+		return err
+	}
+	render, err := newRenderer(*to, false)
+	if err != nil {
+		return err
+	}
+	write := func(record reader.Record) error {
+		return render(e.stdout, record)
+	}
+
+	r := reader.New(fs.Arg(0), reader.WithFormat(format), reader.WithRotated(false))
+	if fs.NArg() == 0 || fs.Arg(0) == "-" {
+		return r.Scan(e.stdin, "-", write)
+	}
+	return r.Each(write)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/features"
+	"github.com/wayneeseguin/omni/pkg/reader"
+)
+
+// stringList is a repeatable string flag
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// filterFlags are the selection flags shared by tail and query
+type filterFlags struct {
+	since   string
+	until   string
+	level   string
+	grep    string
+	exclude string
+	fields  stringList
+	format  string
+}
+
+// register adds the filter flags to fs
+func (f *filterFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.since, "since", "", "Only entries at or after `time` (RFC 3339, date, or duration ago such as 15m)")
+	fs.StringVar(&f.until, "until", "", "Only entries before `time` (RFC 3339, date, or duration ago such as 15m)")
+	fs.StringVar(&f.level, "level", "", "Only entries at or above `level` (trace, debug, info, warn, error)")
+	fs.StringVar(&f.grep, "grep", "", "Only entries whose message matches `regexp`")
+	fs.StringVar(&f.exclude, "exclude", "", "Skip entries whose message matches `regexp`")
+	fs.Var(&f.fields, "field", "Only entries with field `key=value`, or with the field present for `key`; repeatable")
+	fs.StringVar(&f.format, "format", "auto", "Input `format`: auto, json, logfmt, text, msgpack or cbor")
+}
+
+// options converts the flags into reader options
+func (f *filterFlags) options(now time.Time) ([]reader.Option, error) {
+	var opts []reader.Option
+
+	format, err := parseFormat(f.format)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, reader.WithFormat(format))
+
+	since, err := parseTimeArg(f.since, now)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -since: %w", err)
+	}
+	until, err := parseTimeArg(f.until, now)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -until: %w", err)
+	}
+	if !since.IsZero() || !until.IsZero() {
+		opts = append(opts, reader.WithTimeRange(since, until))
+	}
+
+	if f.level != "" {
+		level := reader.ParseLevel(f.level)
+		if level == reader.LevelUnknown {
+			return nil, fmt.Errorf("invalid -level: %q", f.level)
+		}
+		opts = append(opts, reader.WithLevel(level))
+	}
+
+	if f.grep != "" {
+		pattern, err := regexp.Compile(f.grep)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -grep: %w", err)
+		}
+		opts = append(opts, reader.WithMessageRegex(pattern))
+	}
+	if f.exclude != "" {
+		pattern, err := regexp.Compile(f.exclude)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -exclude: %w", err)
+		}
+		opts = append(opts, reader.WithExcludeRegex(pattern))
+	}
+
+	for _, field := range f.fields {
+		key, value, hasValue := strings.Cut(field, "=")
+		if key == "" {
+			return nil, fmt.Errorf("invalid -field: %q", field)
+		}
+		if !hasValue {
+			opts = append(opts, reader.WithFilter(features.CreateFieldExistsFilter(key)))
+			continue
+		}
+		opts = append(opts, reader.WithFilter(fieldEquals(key, value)))
+	}
+
+	return opts, nil
+}
+
+// fieldEquals matches fields by their rendered value, so "-field status=200"
+// matches the number 200 read back from JSON as well as the text "200"
+func fieldEquals(key, value string) features.FilterFunc {
+	return func(level int, message string, fields map[string]interface{}) bool {
+		v, ok := fields[key]
+		return ok && formatValue(v) == value
+	}
+}
+
+// parseFormat parses an input format name
+func parseFormat(name string) (reader.Format, error) {
+	switch strings.ToLower(name) {
+	case "", "auto":
+		return reader.FormatAuto, nil
+	case "json":
+		return reader.FormatJSON, nil
+	case "text":
+		return reader.FormatText, nil
+	case "logfmt":
+		return reader.FormatLogfmt, nil
+	case "msgpack":
+		return reader.FormatMsgpack, nil
+	case "cbor":
+		return reader.FormatCBOR, nil
+	default:
+		return reader.FormatAuto, fmt.Errorf("unknown input format %q", name)
+	}
+}
+
+// timeArgLayouts are accepted for absolute -since and -until values
+var timeArgLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// parseTimeArg parses an absolute time, or a duration meaning that long before now
+func parseTimeArg(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	for _, layout := range timeArgLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %q as a time or duration", value)
+}
+
+// outputFlags select how records are written
+type outputFlags struct {
+	output string
+	color  string
+}
+
+// register adds the output flags to fs
+func (o *outputFlags) register(fs *flag.FlagSet, defaultOutput string) {
+	fs.StringVar(&o.output, "o", defaultOutput, "Output `format`: "+outputFormats)
+	fs.StringVar(&o.color, "color", "auto", "Colour pretty output: auto, always or never (auto honours NO_COLOR)")
+}
+
+// renderer returns the renderer selected by the flags
+func (o *outputFlags) renderer(e *env) (renderer, error) {
+	color, err := useColor(e, o.color)
+	if err != nil {
+		return nil, err
+	}
+	return newRenderer(o.output, color)
+}
@@ -0,0 +1,138 @@
+// Command omni tails, queries, converts and summarises log files written by
+// the omni logging library.
+//
+// Usage:
+//
+//	omni tail [-f] [-n lines] [filter flags] FILE
+//	omni query [filter flags] FILE
+//	omni convert -to json|logfmt|text|msgpack|cbor [FILE]
+//	omni stats [-bucket 1h] [-top 10] FILE
+//	omni pretty [FILE...]
+//
+// FILE is the path of the active log. Its rotated files (FILE.<timestamp>,
+// optionally compressed as .gz or .zst) are read first, in chronological
+// order. convert and pretty read standard input when FILE is omitted or "-".
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// env holds the process environment so commands can be run from tests
+type env struct {
+	ctx    context.Context
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	getenv func(string) string
+}
+
+// command is an omni subcommand
+type command struct {
+	name    string
+	summary string
+	run     func(e *env, args []string) error
+}
+
+var commands = []command{
+	{"tail", "Print the last entries of a log, optionally following it across rotations", runTail},
+	{"query", "Print entries matching a time range, level, fields or pattern", runQuery},
+	{"convert", "Convert entries between text, JSON, logfmt, MessagePack and CBOR", runConvert},
+	{"stats", "Summarise entries by level, message and error rate over time", runStats},
+	{"pretty", "Render JSON lines in colour for reading", runPretty},
+}
+
+// errUsage reports invalid arguments; the usage has already been printed
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(&env{
+		ctx:    ctx,
+		stdin:  os.Stdin,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+		getenv: os.Getenv,
+	}, os.Args[1:])
+	stop()
+	os.Exit(code)
+}
+
+// run executes the subcommand named by args[0] and returns the exit code
+func run(e *env, args []string) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "--help" || args[0] == "help" {
+		printUsage(e.stderr)
+		if len(args) == 0 {
+			return 2
+		}
+		return 0
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+		err := cmd.run(e, args[1:])
+		switch {
+		case err == nil, errors.Is(err, flag.ErrHelp):
+			return 0
+		case errors.Is(err, errUsage):
+			return 2
+		default:
+			fmt.Fprintf(e.stderr, "omni %s: %v\n", cmd.name, err)
+			return 1
+		}
+	}
+
+	fmt.Fprintf(e.stderr, "omni: unknown command %q\n\n", args[0])
+	printUsage(e.stderr)
+	return 2
+}
+
+// printUsage lists the subcommands
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: omni <command> [flags] [FILE]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "omni <command> -h" for the flags of a command.`)
+}
+
+// newFlagSet creates a flag set for a subcommand that reports errors instead of exiting
+func newFlagSet(e *env, name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet("omni "+name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(e.stderr, "Usage: omni %s %s\n\nFlags:\n", name, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses args, mapping parse failures to errUsage
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+	return nil
+}
+
+// usageError prints msg and the command usage and returns errUsage
+func usageError(e *env, fs *flag.FlagSet, msg string) error {
+	fmt.Fprintf(e.stderr, "%s: %s\n", fs.Name(), msg)
+	fs.Usage()
+	return errUsage
+}
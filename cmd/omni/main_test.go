@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testLog mixes text and JSON entries; structured text entries end with a space
+const testLog = "[2024-01-01T10:00:00Z] [INFO] started port=8080 \n" +
+	`{"fields":{"user":"bob","status":200},"level":"INFO","message":"request done","timestamp":"2024-01-01T10:05:00Z"}` + "\n" +
+	`{"fields":{"user":"eve","status":500},"level":"ERROR","message":"request failed","timestamp":"2024-01-01T11:01:00Z"}` + "\n" +
+	"[2024-01-01T11:30:00Z] [DEBUG] cache miss\n"
+
+// syncBuffer is a bytes.Buffer safe for concurrent use by a following command
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// runCommand runs the CLI and returns the exit code, stdout and stderr
+func runCommand(t *testing.T, stdin string, vars map[string]string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr syncBuffer
+	code := run(&env{
+		ctx:    context.Background(),
+		stdin:  strings.NewReader(stdin),
+		stdout: &stdout,
+		stderr: &stderr,
+		getenv: func(key string) string { return vars[key] },
+	}, args)
+	return code, stdout.String(), stderr.String()
+}
+
+// writeTestLogs writes an active log and a gzip-compressed rotated file
+func writeTestLogs(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "app.log")
+
+	if err := os.WriteFile(logPath, []byte(testLog), 0600); err != nil {
+		t.Fatalf("Failed to write log: %v", err)
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, _ = gz.Write([]byte("[2024-01-01T09:00:00Z] [WARN] rotated warning\n"))
+	_ = gz.Close()
+	if err := os.WriteFile(logPath+".20240101-095959.000.gz", buf.Bytes(), 0600); err != nil {
+		t.Fatalf("Failed to write rotated log: %v", err)
+	}
+	return logPath
+}
+
+func TestUsage(t *testing.T) {
+	if code, _, stderr := runCommand(t, "", nil); code != 2 || !strings.Contains(stderr, "Commands:") {
+		t.Errorf("Expected usage with exit code 2, got %d: %s", code, stderr)
+	}
+	if code, _, _ := runCommand(t, "", nil, "help"); code != 0 {
+		t.Errorf("Expected exit code 0 for help, got %d", code)
+	}
+	if code, _, stderr := runCommand(t, "", nil, "bogus"); code != 2 || !strings.Contains(stderr, "unknown command") {
+		t.Errorf("Expected unknown command error, got %d: %s", code, stderr)
+	}
+	if code, _, _ := runCommand(t, "", nil, "query"); code != 2 {
+		t.Errorf("Expected exit code 2 without a file, got %d", code)
+	}
+	if code, _, stderr := runCommand(t, "", nil, "query", "-level", "loud", "app.log"); code != 1 || !strings.Contains(stderr, "invalid -level") {
+		t.Errorf("Expected invalid level error, got %d: %s", code, stderr)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	logPath := writeTestLogs(t)
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{"all", nil, []string{"rotated warning", "started", "request done", "request failed", "cache miss"}},
+		{"level", []string{"-level", "warn"}, []string{"rotated warning", "request failed"}},
+		{"grep", []string{"-grep", "^request"}, []string{"request done", "request failed"}},
+		{"field", []string{"-field", "status=500"}, []string{"request failed"}},
+		{"field exists", []string{"-field", "port"}, []string{"started"}},
+		{"time range", []string{"-since", "2024-01-01T10:00:00Z", "-until", "2024-01-01T11:00:00Z"}, []string{"started", "request done"}},
+		{"active only", []string{"-active", "-limit", "1"}, []string{"started"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append(append([]string{"query", "-o", "json"}, tt.args...), logPath)
+			code, stdout, stderr := runCommand(t, "", nil, args...)
+			if code != 0 {
+				t.Fatalf("Exit code %d: %s", code, stderr)
+			}
+
+			lines := strings.Split(strings.TrimSpace(stdout), "\n")
+			if len(lines) != len(tt.expected) {
+				t.Fatalf("Expected %d entries, got %d:\n%s", len(tt.expected), len(lines), stdout)
+			}
+			for i, message := range tt.expected {
+				if !strings.Contains(lines[i], `"message":"`+message+`"`) {
+					t.Errorf("Line %d: expected %q, got %s", i, message, lines[i])
+				}
+			}
+		})
+	}
+}
+
+func TestConvert(t *testing.T) {
+	code, stdout, stderr := runCommand(t, "[2024-01-01T10:00:00Z] [INFO] started port=8080 \n", nil, "convert", "-to", "json")
+	if code != 0 {
+		t.Fatalf("Exit code %d: %s", code, stderr)
+	}
+	expected := `{"fields":{"port":8080},"level":"INFO","message":"started","timestamp":"2024-01-01T10:00:00Z"}` + "\n"
+	if stdout != expected {
+		t.Errorf("Expected %s, got %s", expected, stdout)
+	}
+
+	code, stdout, _ = runCommand(t, `{"fields":{"user":"bob smith"},"level":"INFO","message":"login ok","timestamp":"2024-01-01T10:05:00Z"}`+"\n", nil, "convert", "-to", "logfmt", "-")
+	expected = `ts=2024-01-01T10:05:00Z level=info msg="login ok" user="bob smith"` + "\n"
+	if code != 0 || stdout != expected {
+		t.Errorf("Expected %s, got %s", expected, stdout)
+	}
+
+	code, stdout, _ = runCommand(t, `ts=2024-01-01T10:05:00Z level=info msg="login ok" user="bob smith" http.status=200`+"\n", nil, "convert", "-from", "logfmt", "-to", "json")
+	expected = `{"fields":{"http.status":200,"user":"bob smith"},"level":"info","message":"login ok","timestamp":"2024-01-01T10:05:00Z"}` + "\n"
+	if code != 0 || stdout != expected {
+		t.Errorf("Expected %s, got %s", expected, stdout)
+	}
+
+	// Binary entries convert back, detected from their first byte
+	for _, format := range []string{"msgpack", "cbor"} {
+		code, binary, stderr := runCommand(t, "[2024-01-01T10:00:00Z] [WARN] disk low free=12 \n", nil, "convert", "-to", format)
+		if code != 0 {
+			t.Fatalf("Exit code %d converting to %s: %s", code, format, stderr)
+		}
+		code, stdout, stderr = runCommand(t, binary, nil, "convert", "-to", "json")
+		expected = `{"fields":{"free":12},"level":"WARN","message":"disk low","timestamp":"2024-01-01T10:00:00Z"}` + "\n"
+		if code != 0 || stdout != expected {
+			t.Errorf("Expected %s from %s, got %s (%s)", expected, format, stdout, stderr)
+		}
+	}
+
+	// Rotated compressed files are converted directly
+	logPath := writeTestLogs(t)
+	code, stdout, _ = runCommand(t, "", nil, "convert", "-to", "text", logPath+".20240101-095959.000.gz")
+	if code != 0 || stdout != "[2024-01-01T09:00:00Z] [WARN] rotated warning\n" {
+		t.Errorf("Unexpected conversion of compressed file: %q", stdout)
+	}
+
+	if code, _, _ := runCommand(t, "", nil, "convert"); code != 2 {
+		t.Errorf("Expected exit code 2 without -to, got %d", code)
+	}
+}
+
+func TestStats(t *testing.T) {
+	logPath := writeTestLogs(t)
+
+	code, stdout, stderr := runCommand(t, "", nil, "stats", "-top", "2", logPath)
+	if code != 0 {
+		t.Fatalf("Exit code %d: %s", code, stderr)
+	}
+
+	for _, expected := range []string{
+		"Entries:  5",
+		"ERROR  1      20.0%",
+		"2024-01-01T09:00:00Z  1        0       0.0%",
+		"2024-01-01T11:00:00Z  2        1       50.0%",
+	} {
+		if !strings.Contains(stdout, expected) {
+			t.Errorf("Expected %q in stats:\n%s", expected, stdout)
+		}
+	}
+	if strings.Count(stdout, "\n1      ") != 2 {
+		t.Errorf("Expected two top messages:\n%s", stdout)
+	}
+}
+
+func TestPretty(t *testing.T) {
+	input := `{"fields":{"user":"bob"},"level":"error","message":"failed","timestamp":"2024-01-01T10:00:00Z","stack_trace":"main.main()"}` + "\n"
+
+	code, stdout, _ := runCommand(t, input, nil, "pretty")
+	expected := "2024-01-01T10:00:00Z ERROR failed user=bob\n    main.main()\n"
+	if code != 0 || stdout != expected {
+		t.Errorf("Expected %q, got %q", expected, stdout)
+	}
+
+	_, stdout, _ = runCommand(t, input, nil, "pretty", "-color", "always")
+	if !strings.Contains(stdout, ansiRed+"ERROR"+ansiReset) {
+		t.Errorf("Expected coloured level, got %q", stdout)
+	}
+
+	// NO_COLOR is honoured in auto mode
+	_, stdout, _ = runCommand(t, input, map[string]string{"NO_COLOR": "1"}, "pretty")
+	if strings.Contains(stdout, "\033[") {
+		t.Errorf("Expected no colour with NO_COLOR, got %q", stdout)
+	}
+}
+
+func TestTail(t *testing.T) {
+	logPath := writeTestLogs(t)
+
+	code, stdout, stderr := runCommand(t, "", nil, "tail", "-n", "2", logPath)
+	if code != 0 {
+		t.Fatalf("Exit code %d: %s", code, stderr)
+	}
+	expected := "[2024-01-01T11:01:00Z] [ERROR] request failed status=500 user=eve \n[2024-01-01T11:30:00Z] [DEBUG] cache miss\n"
+	if stdout != expected {
+		t.Errorf("Expected %q, got %q", expected, stdout)
+	}
+}
+
+func TestTailFollow(t *testing.T) {
+	logPath := writeTestLogs(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var stdout, stderr syncBuffer
+	done := make(chan int, 1)
+	go func() {
+		done <- run(&env{
+			ctx:    ctx,
+			stdin:  strings.NewReader(""),
+			stdout: &stdout,
+			stderr: &stderr,
+			getenv: func(string) string { return "" },
+		}, []string{"tail", "-f", "-n", "1", "-poll", "10ms", "-o", "logfmt", logPath})
+	}()
+
+	waitFor := func(expected string) {
+		t.Helper()
+		deadline := time.Now().Add(3 * time.Second)
+		for time.Now().Before(deadline) {
+			if strings.Contains(stdout.String(), expected) {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("Expected %q in output, got %q", expected, stdout.String())
+	}
+
+	waitFor("msg=\"cache miss\"")
+
+	// Rotate and write to the new file
+	if err := os.Rename(logPath, logPath+".20240101-120000.000"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if err := os.WriteFile(logPath, []byte("[2024-01-01T12:00:01Z] [INFO] after rotation\n"), 0600); err != nil {
+		t.Fatalf("Failed to write new log: %v", err)
+	}
+	waitFor("msg=\"after rotation\"")
+
+	cancel()
+	if code := <-done; code != 0 {
+		t.Errorf("Expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if strings.Contains(stdout.String(), "request failed") {
+		t.Errorf("Expected only the last entry before following, got %q", stdout.String())
+	}
+}
+
+func TestParseTimeArg(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		input    string
+		expected time.Time
+	}{
+		{"", time.Time{}},
+		{"90m", now.Add(-90 * time.Minute)},
+		{"2024-01-01T10:00:00Z", time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)},
+		{"2024-01-01T10:00:00+02:00", time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := parseTimeArg(tt.input, now)
+		if err != nil || !got.Equal(tt.expected) {
+			t.Errorf("parseTimeArg(%q) = %v, %v; want %v", tt.input, got, err, tt.expected)
+		}
+	}
+
+	if _, err := parseTimeArg("yesterday", now); err == nil {
+		t.Error("Expected error for invalid time")
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/wayneeseguin/omni/pkg/formatters"
+	"github.com/wayneeseguin/omni/pkg/reader"
+	"github.com/wayneeseguin/omni/pkg/types"
+)
+
+// renderer writes one record
+type renderer func(w io.Writer, record reader.Record) error
+
+// outputFormats lists the names accepted by newRenderer
+const outputFormats = "text, json, logfmt, pretty, msgpack or cbor"
+
+// newRenderer returns the renderer for an output format name
+func newRenderer(name string, color bool) (renderer, error) {
+	switch strings.ToLower(name) {
+	case "text":
+		return renderText, nil
+	case "json":
+		return renderJSON, nil
+	case "logfmt":
+		return renderLogfmt, nil
+	case "msgpack":
+		return renderBinary(formatters.NewMsgpackFormatter()), nil
+	case "cbor":
+		return renderBinary(formatters.NewCBORFormatter()), nil
+	case "pretty":
+		return func(w io.Writer, record reader.Record) error {
+			return renderPretty(w, record, color)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q (want %s)", name, outputFormats)
+	}
+}
+
+// renderText writes the record the way omni's text formatter does
+func renderText(w io.Writer, record reader.Record) error {
+	entry := record.Entry
+
+	var b strings.Builder
+	if entry.Timestamp != "" {
+		fmt.Fprintf(&b, "[%s] ", entry.Timestamp)
+	}
+	if entry.Level != "" {
+		fmt.Fprintf(&b, "[%s] ", strings.ToUpper(entry.Level))
+	}
+	b.WriteString(entry.Message)
+	if len(entry.Fields) > 0 {
+		b.WriteString(" ")
+		for _, key := range sortedKeys(entry.Fields) {
+			fmt.Fprintf(&b, "%s=%s ", key, formatValue(entry.Fields[key]))
+		}
+	}
+	if entry.StackTrace != "" {
+		fmt.Fprintf(&b, " stack_trace=%s", entry.StackTrace)
+	}
+	b.WriteString("\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// renderJSON writes the record as a JSON line in omni's JSON layout
+func renderJSON(w io.Writer, record reader.Record) error {
+	data, err := json.Marshal(record.Entry)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// logfmtFormatter renders logfmt output exactly as omni's logfmt formatter writes it
+var logfmtFormatter = formatters.NewLogfmtFormatter()
+
+// renderLogfmt writes the record as a logfmt line
+func renderLogfmt(w io.Writer, record reader.Record) error {
+	data, err := logfmtFormatter.Format(types.LogMessage{Entry: record.Entry})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// renderBinary returns a renderer writing records as MessagePack or CBOR
+// entries, exactly as omni's binary formatters write them
+func renderBinary(formatter types.Formatter) renderer {
+	return func(w io.Writer, record reader.Record) error {
+		data, err := formatter.Format(types.LogMessage{Timestamp: record.Time, Entry: record.Entry})
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+}
+
+// ANSI colour codes used by renderPretty
+const (
+	ansiReset  = "\033[0m"
+	ansiBold   = "\033[1m"
+	ansiDim    = "\033[2m"
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+	ansiCyan   = "\033[36m"
+	ansiGray   = "\033[90m"
+)
+
+// levelColors maps levels to their colour in pretty output
+var levelColors = map[int]string{
+	formatters.LevelTrace: ansiGray,
+	formatters.LevelDebug: ansiCyan,
+	formatters.LevelInfo:  ansiGreen,
+	formatters.LevelWarn:  ansiYellow,
+	formatters.LevelError: ansiBold + ansiRed,
+}
+
+// renderPretty writes the record for people: aligned level, dimmed
+// timestamp and field keys, and the stack trace on its own lines
+func renderPretty(w io.Writer, record reader.Record, color bool) error {
+	entry := record.Entry
+	paint := func(code, s string) string {
+		if !color || code == "" {
+			return s
+		}
+		return code + s + ansiReset
+	}
+
+	var b strings.Builder
+	if entry.Timestamp != "" {
+		b.WriteString(paint(ansiDim, entry.Timestamp))
+		b.WriteString(" ")
+	}
+	level := strings.ToUpper(entry.Level)
+	if level == "" {
+		level = "-"
+	}
+	b.WriteString(paint(levelColors[record.Level], fmt.Sprintf("%-5s", level)))
+	b.WriteString(" ")
+	b.WriteString(entry.Message)
+	for _, key := range sortedKeys(entry.Fields) {
+		fmt.Fprintf(&b, " %s%s", paint(ansiDim, key+"="), formatValue(entry.Fields[key]))
+	}
+	b.WriteString("\n")
+	if entry.StackTrace != "" {
+		for _, line := range strings.Split(strings.TrimRight(entry.StackTrace, "\n"), "\n") {
+			b.WriteString("    ")
+			b.WriteString(paint(ansiGray, line))
+			b.WriteString("\n")
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// useColor decides whether pretty output is coloured. "auto" colours
+// terminals unless NO_COLOR is set.
+func useColor(e *env, mode string) (bool, error) {
+	switch strings.ToLower(mode) {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "", "auto":
+		if e.getenv("NO_COLOR") != "" {
+			return false, nil
+		}
+		file, ok := e.stdout.(*os.File)
+		if !ok {
+			return false, nil
+		}
+		info, err := file.Stat()
+		return err == nil && info.Mode()&os.ModeCharDevice != 0, nil
+	default:
+		return false, fmt.Errorf("invalid -color %q (want auto, always or never)", mode)
+	}
+}
+
+// formatValue renders a field value for text output
+func formatValue(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Sprint(value)
+		}
+		return string(data)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// sortedKeys returns the keys of fields in order
+func sortedKeys(fields map[string]interface{}) []string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"github.com/wayneeseguin/omni/pkg/reader"
+)
+
+// runPretty renders JSON lines from files or standard input for reading
+func runPretty(e *env, args []string) error {
+	fs := newFlagSet(e, "pretty", "[-color auto|always|never] [FILE...]")
+	color := fs.String("color", "auto", "Colour output: auto, always or never (auto honours NO_COLOR)")
+
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	colored, err := useColor(e, *color)
+	if err != nil {
+		return err
+	}
+	write := func(record reader.Record) error {
+		return renderPretty(e.stdout, record, colored)
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	for _, file := range files {
+		r := reader.New(file, reader.WithRotated(false))
+		if file == "-" {
+			err = r.Scan(e.stdin, "-", write)
+		} else {
+			err = r.Each(write)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/reader"
+)
+
+// runQuery prints the entries of a log and its rotated files that match the filters
+func runQuery(e *env, args []string) error {
+	fs := newFlagSet(e, "query", "[flags] FILE")
+	limit := fs.Int("limit", 0, "Stop after `n` matching entries (0 for no limit)")
+	activeOnly := fs.Bool("active", false, "Only read the active file, not rotated files")
+	var filters filterFlags
+	filters.register(fs)
+	var output outputFlags
+	output.register(fs, "text")
+
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError(e, fs, "expected one log file")
+	}
+
+	opts, err := filters.options(time.Now())
+	if err != nil {
+		return err
+	}
+	opts = append(opts, reader.WithRotated(!*activeOnly))
+	render, err := output.renderer(e)
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	return reader.New(fs.Arg(0), opts...).Each(func(record reader.Record) error {
+		if err := render(e.stdout, record); err != nil {
+			return err
+		}
+		count++
+		if *limit > 0 && count >= *limit {
+			return reader.ErrStop
+		}
+		return nil
+	})
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/formatters"
+	"github.com/wayneeseguin/omni/pkg/reader"
+)
+
+// levelNames orders levels in stats output
+var levelNames = []struct {
+	level int
+	name  string
+}{
+	{formatters.LevelTrace, "TRACE"},
+	{formatters.LevelDebug, "DEBUG"},
+	{formatters.LevelInfo, "INFO"},
+	{formatters.LevelWarn, "WARN"},
+	{formatters.LevelError, "ERROR"},
+	{reader.LevelUnknown, "UNKNOWN"},
+}
+
+// maxMessageWidth truncates messages in the top messages table
+const maxMessageWidth = 80
+
+// logStats accumulates the statistics of a log
+type logStats struct {
+	total    int
+	byLevel  map[int]int
+	messages map[string]int
+	buckets  map[time.Time]*bucketStats
+	bucket   time.Duration
+	first    time.Time
+	last     time.Time
+}
+
+// bucketStats counts entries in one time bucket
+type bucketStats struct {
+	total  int
+	errors int
+}
+
+// newLogStats creates empty statistics grouping entries into buckets of the given size
+func newLogStats(bucket time.Duration) *logStats {
+	return &logStats{
+		byLevel:  make(map[int]int),
+		messages: make(map[string]int),
+		buckets:  make(map[time.Time]*bucketStats),
+		bucket:   bucket,
+	}
+}
+
+// add counts a record
+func (s *logStats) add(record reader.Record) {
+	s.total++
+	s.byLevel[record.Level]++
+	s.messages[record.Entry.Message]++
+
+	if record.Time.IsZero() {
+		return
+	}
+	t := record.Time.UTC()
+	if s.first.IsZero() || t.Before(s.first) {
+		s.first = t
+	}
+	if t.After(s.last) {
+		s.last = t
+	}
+
+	start := t.Truncate(s.bucket)
+	b := s.buckets[start]
+	if b == nil {
+		b = &bucketStats{}
+		s.buckets[start] = b
+	}
+	b.total++
+	if record.Level >= formatters.LevelError {
+		b.errors++
+	}
+}
+
+// runStats summarises a log by level, top messages and error rate over time
+func runStats(e *env, args []string) error {
+	fs := newFlagSet(e, "stats", "[-bucket 1h] [-top 10] [filter flags] FILE")
+	bucket := fs.Duration("bucket", time.Hour, "Bucket `size` for the error rate over time")
+	top := fs.Int("top", 10, "Number of most frequent messages to list")
+	var filters filterFlags
+	filters.register(fs)
+
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError(e, fs, "expected one log file")
+	}
+	if *bucket <= 0 {
+		return usageError(e, fs, "-bucket must be positive")
+	}
+
+	opts, err := filters.options(time.Now())
+	if err != nil {
+		return err
+	}
+
+	stats := newLogStats(*bucket)
+	err = reader.New(fs.Arg(0), opts...).Each(func(record reader.Record) error {
+		stats.add(record)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return stats.write(e, *top)
+}
+
+// write prints the statistics as tables
+func (s *logStats) write(e *env, top int) error {
+	w := tabwriter.NewWriter(e.stdout, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(w, "Entries:\t%d\n", s.total)
+	if !s.first.IsZero() {
+		fmt.Fprintf(w, "First:\t%s\n", s.first.Format(time.RFC3339))
+		fmt.Fprintf(w, "Last:\t%s\n", s.last.Format(time.RFC3339))
+	}
+
+	fmt.Fprintln(w, "\nLEVEL\tCOUNT\tPERCENT")
+	for _, level := range levelNames {
+		count := s.byLevel[level.level]
+		if count == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%d\t%.1f%%\n", level.name, count, percent(count, s.total))
+	}
+
+	if top > 0 && len(s.messages) > 0 {
+		fmt.Fprintln(w, "\nCOUNT\tMESSAGE")
+		for _, message := range s.topMessages(top) {
+			fmt.Fprintf(w, "%d\t%s\n", s.messages[message], truncateMessage(message))
+		}
+	}
+
+	if len(s.buckets) > 0 {
+		starts := make([]time.Time, 0, len(s.buckets))
+		for start := range s.buckets {
+			starts = append(starts, start)
+		}
+		sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+
+		fmt.Fprintln(w, "\nPERIOD\tENTRIES\tERRORS\tERROR RATE")
+		for _, start := range starts {
+			b := s.buckets[start]
+			fmt.Fprintf(w, "%s\t%d\t%d\t%.1f%%\n", start.Format(time.RFC3339), b.total, b.errors, percent(b.errors, b.total))
+		}
+	}
+
+	return w.Flush()
+}
+
+// topMessages returns the n most frequent messages, most frequent first
+func (s *logStats) topMessages(n int) []string {
+	messages := make([]string, 0, len(s.messages))
+	for message := range s.messages {
+		messages = append(messages, message)
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		if s.messages[messages[i]] != s.messages[messages[j]] {
+			return s.messages[messages[i]] > s.messages[messages[j]]
+		}
+		return messages[i] < messages[j]
+	})
+	if len(messages) > n {
+		messages = messages[:n]
+	}
+	return messages
+}
+
+// truncateMessage shortens a message to one line of at most maxMessageWidth runes
+func truncateMessage(message string) string {
+	message, _, _ = strings.Cut(message, "\n")
+	runes := []rune(message)
+	if len(runes) > maxMessageWidth {
+		return string(runes[:maxMessageWidth-3]) + "..."
+	}
+	return message
+}
+
+// percent returns part as a percentage of total
+func percent(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) * 100 / float64(total)
+}
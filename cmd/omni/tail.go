@@ -0,0 +1,69 @@
+package main
+
+import (
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/reader"
+)
+
+// runTail prints the last entries of a log and optionally follows it
+func runTail(e *env, args []string) error {
+	fs := newFlagSet(e, "tail", "[-f] [-n lines] [flags] FILE")
+	follow := fs.Bool("f", false, "Follow the log as it grows, across rotations")
+	lines := fs.Int("n", 10, "Number of entries to print first (-1 for all)")
+	poll := fs.Duration("poll", reader.DefaultPollInterval, "How often to check for new entries when following")
+	var filters filterFlags
+	filters.register(fs)
+	var output outputFlags
+	output.register(fs, "text")
+
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError(e, fs, "expected one log file")
+	}
+
+	opts, err := filters.options(time.Now())
+	if err != nil {
+		return err
+	}
+	render, err := output.renderer(e)
+	if err != nil {
+		return err
+	}
+	write := func(record reader.Record) error {
+		return render(e.stdout, record)
+	}
+
+	if *follow {
+		opts = append(opts, reader.WithTail(*lines), reader.WithPollInterval(*poll))
+		return reader.New(fs.Arg(0), opts...).Follow(e.ctx, write)
+	}
+
+	if *lines < 0 {
+		return reader.New(fs.Arg(0), opts...).Each(write)
+	}
+
+	// Keep only the last n matching entries
+	var last []reader.Record
+	err = reader.New(fs.Arg(0), opts...).Each(func(record reader.Record) error {
+		if *lines == 0 {
+			return reader.ErrStop
+		}
+		if len(last) == *lines {
+			last = last[1:]
+		}
+		last = append(last, record)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, record := range last {
+		if err := write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ go 1.24.4
 
 require (
 	github.com/hashicorp/vault/api v1.20.0
+	github.com/klauspost/compress v1.18.0
 	github.com/nats-io/nats.go v1.43.0
 )
 
@@ -19,7 +20,6 @@ require (
 	github.com/hashicorp/go-secure-stdlib/strutil v0.1.2 // indirect
 	github.com/hashicorp/go-sockaddr v1.0.7 // indirect
 	github.com/hashicorp/hcl v1.0.1-vault-7 // indirect
-	github.com/klauspost/compress v1.18.0 // indirect
 	github.com/mitchellh/go-homedir v1.1.0 // indirect
 	github.com/mitchellh/mapstructure v1.5.0 // indirect
 	github.com/nats-io/nkeys v0.4.11 // indirect
@@ -33,5 +33,5 @@ require (
 
 require (
 	github.com/gofrs/flock v0.12.1
-	golang.org/x/sys v0.33.0 // indirect
+	golang.org/x/sys v0.33.0
 )
@@ -0,0 +1,218 @@
+// Package cbor encodes and decodes the CBOR (RFC 8949) values used by log
+// records: null, booleans, integers, floats, text and byte strings, arrays,
+// maps with text keys and tagged values.
+package cbor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+)
+
+// Major types
+const (
+	majorUint   = 0
+	majorNegInt = 1
+	majorBytes  = 2
+	majorText   = 3
+	majorArray  = 4
+	majorMap    = 5
+	majorTag    = 6
+	majorSimple = 7
+)
+
+// Tag numbers for date/time values
+const (
+	// TagDateTime marks an RFC 3339 date/time text string
+	TagDateTime = 0
+	// TagEpoch marks seconds since the Unix epoch as an integer or float
+	TagEpoch = 1
+)
+
+// Tag is a tagged CBOR value other than a date/time
+type Tag struct {
+	Number  uint64
+	Content interface{}
+}
+
+// appendHead appends the initial byte and argument of a data item
+func appendHead(b []byte, major byte, n uint64) []byte {
+	major <<= 5
+	switch {
+	case n < 24:
+		return append(b, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(b, major|24, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, major|25), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, major|26), uint32(n))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, major|27), n)
+	}
+}
+
+// AppendNil appends null
+func AppendNil(b []byte) []byte {
+	return append(b, 0xf6)
+}
+
+// AppendBool appends a boolean
+func AppendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 0xf5)
+	}
+	return append(b, 0xf4)
+}
+
+// AppendInt appends a signed integer in its smallest encoding
+func AppendInt(b []byte, v int64) []byte {
+	if v >= 0 {
+		return appendHead(b, majorUint, uint64(v))
+	}
+	return appendHead(b, majorNegInt, uint64(-1-v)) // #nosec G115 - -1-v is not negative
+}
+
+// AppendUint appends an unsigned integer in its smallest encoding
+func AppendUint(b []byte, v uint64) []byte {
+	return appendHead(b, majorUint, v)
+}
+
+// AppendFloat appends a 64-bit float
+func AppendFloat(b []byte, v float64) []byte {
+	return binary.BigEndian.AppendUint64(append(b, 0xfb), math.Float64bits(v))
+}
+
+// AppendString appends a text string
+func AppendString(b []byte, s string) []byte {
+	return append(appendHead(b, majorText, uint64(len(s))), s...)
+}
+
+// AppendBytes appends a byte string
+func AppendBytes(b []byte, data []byte) []byte {
+	return append(appendHead(b, majorBytes, uint64(len(data))), data...)
+}
+
+// AppendArrayHeader appends the header of an array of n elements
+func AppendArrayHeader(b []byte, n int) []byte {
+	return appendHead(b, majorArray, uint64(n)) // #nosec G115 - lengths are not negative
+}
+
+// AppendMapHeader appends the header of a map of n key/value pairs
+func AppendMapHeader(b []byte, n int) []byte {
+	return appendHead(b, majorMap, uint64(n)) // #nosec G115 - lengths are not negative
+}
+
+// AppendTag appends the head of a tag; the tagged value follows it
+func AppendTag(b []byte, number uint64) []byte {
+	return appendHead(b, majorTag, number)
+}
+
+// AppendTime appends t as an RFC 3339 date/time string (tag 0), which keeps
+// nanoseconds and the UTC offset
+func AppendTime(b []byte, t time.Time) []byte {
+	return AppendString(AppendTag(b, TagDateTime), t.Format(time.RFC3339Nano))
+}
+
+// AppendValue appends a Go value. Maps are encoded with text keys, times
+// as tagged date/time strings, errors and Stringers as their text, and
+// other types through their JSON encoding.
+func AppendValue(b []byte, value interface{}) []byte {
+	switch v := value.(type) {
+	case nil:
+		return AppendNil(b)
+	case bool:
+		return AppendBool(b, v)
+	case int:
+		return AppendInt(b, int64(v))
+	case int8:
+		return AppendInt(b, int64(v))
+	case int16:
+		return AppendInt(b, int64(v))
+	case int32:
+		return AppendInt(b, int64(v))
+	case int64:
+		return AppendInt(b, v)
+	case uint:
+		return AppendUint(b, uint64(v))
+	case uint8:
+		return AppendUint(b, uint64(v))
+	case uint16:
+		return AppendUint(b, uint64(v))
+	case uint32:
+		return AppendUint(b, uint64(v))
+	case uint64:
+		return AppendUint(b, v)
+	case float32:
+		return AppendFloat(b, float64(v))
+	case float64:
+		return AppendFloat(b, v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return AppendInt(b, i)
+		}
+		if f, err := v.Float64(); err == nil {
+			return AppendFloat(b, f)
+		}
+		return AppendString(b, v.String())
+	case string:
+		return AppendString(b, v)
+	case []byte:
+		return AppendBytes(b, v)
+	case Tag:
+		return AppendValue(AppendTag(b, v.Number), v.Content)
+	case time.Time:
+		return AppendTime(b, v)
+	case time.Duration:
+		return AppendString(b, v.String())
+	case error:
+		return AppendString(b, v.Error())
+	case fmt.Stringer:
+		return AppendString(b, v.String())
+	case map[string]interface{}:
+		b = AppendMapHeader(b, len(v))
+		for key, item := range v {
+			b = AppendValue(AppendString(b, key), item)
+		}
+		return b
+	case map[string]string:
+		b = AppendMapHeader(b, len(v))
+		for key, item := range v {
+			b = AppendString(AppendString(b, key), item)
+		}
+		return b
+	case []interface{}:
+		b = AppendArrayHeader(b, len(v))
+		for _, item := range v {
+			b = AppendValue(b, item)
+		}
+		return b
+	case []string:
+		b = AppendArrayHeader(b, len(v))
+		for _, item := range v {
+			b = AppendString(b, item)
+		}
+		return b
+	}
+
+	if rv := reflect.ValueOf(value); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return AppendNil(b)
+	}
+
+	// Other types are encoded through JSON, as the JSON formatter would write them
+	data, err := json.Marshal(value)
+	if err != nil {
+		return AppendString(b, fmt.Sprint(value))
+	}
+	var decoded interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		return AppendString(b, string(data))
+	}
+	return AppendValue(b, decoded)
+}
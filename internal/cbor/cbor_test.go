@@ -0,0 +1,147 @@
+package cbor
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRoundTrip(t *testing.T) {
+	timestamp := time.Date(2024, 5, 1, 12, 0, 0, 250, time.UTC)
+	tests := []struct {
+		name     string
+		value    interface{}
+		expected interface{}
+	}{
+		{"nil", nil, nil},
+		{"true", true, true},
+		{"false", false, false},
+		{"small", 7, int64(7)},
+		{"negative", -5, int64(-5)},
+		{"uint8", 200, int64(200)},
+		{"uint16", 60000, int64(60000)},
+		{"uint32", uint32(4000000000), int64(4000000000)},
+		{"uint64", uint64(math.MaxUint64), uint64(math.MaxUint64)},
+		{"int8", -100, int64(-100)},
+		{"int64", int64(math.MinInt64), int64(math.MinInt64)},
+		{"float", 12.5, 12.5},
+		{"float32", float32(0.5), 0.5},
+		{"text", "hello", "hello"},
+		{"long text", strings.Repeat("c", 70000), strings.Repeat("c", 70000)},
+		{"bytes", []byte{1, 2, 3}, []byte{1, 2, 3}},
+		{"array", []interface{}{1, "two", nil}, []interface{}{int64(1), "two", nil}},
+		{"strings", []string{"a", "b"}, []interface{}{"a", "b"}},
+		{
+			"map",
+			map[string]interface{}{"user": "ada", "nested": map[string]string{"tier": "gold"}},
+			map[string]interface{}{"user": "ada", "nested": map[string]interface{}{"tier": "gold"}},
+		},
+		{"tag", Tag{Number: 32, Content: "https://example.com"}, Tag{Number: 32, Content: "https://example.com"}},
+		{"time", timestamp, timestamp},
+		{"error", errors.New("boom"), "boom"},
+		{"struct", struct {
+			Name string `json:"name"`
+			Age  int    `json:"age"`
+		}{"ada", 36}, map[string]interface{}{"name": "ada", "age": int64(36)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := AppendValue(nil, tt.value)
+			value, rest, err := Decode(data)
+			if err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+			if len(rest) != 0 {
+				t.Errorf("Expected no remaining bytes, got %d", len(rest))
+			}
+			if !reflect.DeepEqual(value, tt.expected) {
+				t.Errorf("Expected %#v, got %#v", tt.expected, value)
+			}
+		})
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	// Examples from RFC 8949 appendix A
+	tests := []struct {
+		value    interface{}
+		expected []byte
+	}{
+		{0, []byte{0x00}},
+		{23, []byte{0x17}},
+		{24, []byte{0x18, 0x18}},
+		{1000, []byte{0x19, 0x03, 0xe8}},
+		{-1, []byte{0x20}},
+		{-1000, []byte{0x39, 0x03, 0xe7}},
+		{"IETF", []byte{0x64, 'I', 'E', 'T', 'F'}},
+		{[]interface{}{1, 2}, []byte{0x82, 0x01, 0x02}},
+		{Tag{Number: 1, Content: 1363896240}, []byte{0xc1, 0x1a, 0x51, 0x4b, 0x67, 0xb0}},
+	}
+	for _, tt := range tests {
+		if got := AppendValue(nil, tt.value); !bytes.Equal(got, tt.expected) {
+			t.Errorf("AppendValue(%v) = % x, want % x", tt.value, got, tt.expected)
+		}
+	}
+}
+
+func TestDecodeFloatsAndEpoch(t *testing.T) {
+	tests := []struct {
+		data     []byte
+		expected interface{}
+	}{
+		{[]byte{0xf9, 0x3c, 0x00}, 1.0},
+		{[]byte{0xf9, 0xc4, 0x00}, -4.0},
+		{[]byte{0xf9, 0x00, 0x01}, 5.960464477539063e-8},
+		{[]byte{0xfa, 0x47, 0xc3, 0x50, 0x00}, 100000.0},
+		{[]byte{0xf7}, nil},
+		{[]byte{0xc1, 0x1a, 0x51, 0x4b, 0x67, 0xb0}, time.Unix(1363896240, 0).UTC()},
+		{[]byte{0xc1, 0xfb, 0x41, 0xd4, 0x52, 0xd9, 0xec, 0x20, 0x00, 0x00}, time.Unix(1363896240, 500000000).UTC()},
+	}
+	for _, tt := range tests {
+		value, _, err := Decode(tt.data)
+		if err != nil {
+			t.Fatalf("Decode(% x) failed: %v", tt.data, err)
+		}
+		if !reflect.DeepEqual(value, tt.expected) {
+			t.Errorf("Decode(% x) = %#v, want %#v", tt.data, value, tt.expected)
+		}
+	}
+}
+
+func TestDecodeSequence(t *testing.T) {
+	data := AppendString(AppendInt(nil, 1), "next")
+	first, rest, err := Decode(data)
+	if err != nil || first != int64(1) {
+		t.Fatalf("Unexpected first value %v, %v", first, err)
+	}
+	second, rest, err := Decode(rest)
+	if err != nil || second != "next" || len(rest) != 0 {
+		t.Errorf("Unexpected second value %v, %v", second, err)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for _, data := range [][]byte{
+		nil,
+		{0x1c},
+		{0x65, 'a'},
+		{0x9f, 0xff},
+		{0x19, 0x01},
+		{0xc0, 0x01},
+		AppendMapHeader(nil, 2),
+		bytes.Repeat([]byte{0x81}, 100),
+	} {
+		if _, _, err := Decode(data); err == nil {
+			t.Errorf("Expected error for % x", data)
+		}
+	}
+
+	if _, _, err := Decode([]byte{0x65, 'a'}); !errors.Is(err, ErrShortBuffer) {
+		t.Errorf("Expected ErrShortBuffer, got %v", err)
+	}
+}
@@ -0,0 +1,231 @@
+package cbor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// ErrShortBuffer reports that data ends within a value
+var ErrShortBuffer = fmt.Errorf("cbor: %w", io.ErrUnexpectedEOF)
+
+// maxDepth limits the nesting of decoded arrays, maps and tags
+const maxDepth = 64
+
+// Decode decodes the first value in data and returns it with the remaining
+// bytes. Integers decode as int64 (uint64 above the int64 range), floats as
+// float64, byte strings as []byte, maps as map[string]interface{} with other
+// key types formatted as strings, date/time tags as time.Time and other tags
+// as Tag. Indefinite-length items are not supported.
+func Decode(data []byte) (interface{}, []byte, error) {
+	d := decoder{data: data}
+	value, err := d.value(0)
+	if err != nil {
+		return nil, data, err
+	}
+	return value, d.data, nil
+}
+
+// decoder consumes values from the front of data
+type decoder struct {
+	data []byte
+}
+
+// take consumes n bytes
+func (d *decoder) take(n uint64) ([]byte, error) {
+	if uint64(len(d.data)) < n {
+		return nil, ErrShortBuffer
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b, nil
+}
+
+// head consumes the initial byte and argument of a data item
+func (d *decoder) head() (major byte, info byte, n uint64, err error) {
+	b, err := d.take(1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	major, info = b[0]>>5, b[0]&0x1f
+
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info <= 27:
+		arg, err := d.take(1 << (info - 24))
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		var v uint64
+		for _, c := range arg {
+			v = v<<8 | uint64(c)
+		}
+		return major, info, v, nil
+	case info == 31:
+		return 0, 0, 0, errors.New("cbor: indefinite-length items are not supported")
+	default:
+		return 0, 0, 0, fmt.Errorf("cbor: invalid additional information %d", info)
+	}
+}
+
+// value decodes one value
+func (d *decoder) value(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, errors.New("cbor: nesting too deep")
+	}
+
+	major, info, n, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case majorUint:
+		if n > math.MaxInt64 {
+			return n, nil
+		}
+		return int64(n), nil // #nosec G115 - range checked
+	case majorNegInt:
+		if n > math.MaxInt64 {
+			return nil, errors.New("cbor: negative integer out of range")
+		}
+		return -1 - int64(n), nil // #nosec G115 - range checked
+	case majorBytes:
+		b, err := d.take(n)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), b...), nil
+	case majorText:
+		b, err := d.take(n)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case majorArray:
+		return d.array(n, depth)
+	case majorMap:
+		return d.mapValue(n, depth)
+	case majorTag:
+		return d.tag(n, depth)
+	default:
+		return d.simple(info, n)
+	}
+}
+
+// array decodes n elements
+func (d *decoder) array(n uint64, depth int) (interface{}, error) {
+	if n > uint64(len(d.data)) {
+		return nil, ErrShortBuffer
+	}
+	values := make([]interface{}, n)
+	for i := range values {
+		value, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// mapValue decodes n key/value pairs
+func (d *decoder) mapValue(n uint64, depth int) (interface{}, error) {
+	if 2*n > uint64(len(d.data)) {
+		return nil, ErrShortBuffer
+	}
+	values := make(map[string]interface{}, n)
+	for i := uint64(0); i < n; i++ {
+		key, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		value, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := key.(string); ok {
+			values[s] = value
+		} else {
+			values[fmt.Sprint(key)] = value
+		}
+	}
+	return values, nil
+}
+
+// tag decodes the value of a tag, converting date/time tags to time.Time
+func (d *decoder) tag(number uint64, depth int) (interface{}, error) {
+	content, err := d.value(depth + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	switch number {
+	case TagDateTime:
+		if s, ok := content.(string); ok {
+			t, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return nil, fmt.Errorf("cbor: invalid date/time: %w", err)
+			}
+			return t, nil
+		}
+		return nil, errors.New("cbor: date/time tag without a text string")
+	case TagEpoch:
+		switch v := content.(type) {
+		case int64:
+			return time.Unix(v, 0).UTC(), nil
+		case float64:
+			sec, frac := math.Modf(v)
+			return time.Unix(int64(sec), int64(frac*1e9)).UTC(), nil
+		}
+		return nil, errors.New("cbor: epoch tag without a number")
+	}
+	return Tag{Number: number, Content: content}, nil
+}
+
+// simple decodes the simple values and floats of major type 7
+func (d *decoder) simple(info byte, n uint64) (interface{}, error) {
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		// null and undefined
+		return nil, nil
+	case 25:
+		return float16(uint16(n)), nil // #nosec G115 - two-byte argument
+	case 26:
+		return float64(math.Float32frombits(uint32(n))), nil // #nosec G115 - four-byte argument
+	case 27:
+		return math.Float64frombits(n), nil
+	default:
+		return nil, fmt.Errorf("cbor: unsupported simple value %d", n)
+	}
+}
+
+// float16 converts an IEEE 754 half-precision float
+func float16(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var v float64
+	switch exp {
+	case 0:
+		v = math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant == 0 {
+			v = math.Inf(1)
+		} else {
+			v = math.NaN()
+		}
+	default:
+		v = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,250 @@
+package msgpack
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// ErrShortBuffer reports that data ends within a value
+var ErrShortBuffer = fmt.Errorf("msgpack: %w", io.ErrUnexpectedEOF)
+
+// maxDepth limits the nesting of decoded arrays and maps
+const maxDepth = 64
+
+// Decode decodes the first value in data and returns it with the remaining
+// bytes. Integers decode as int64 (uint64 above the int64 range), floats as
+// float64, binary data as []byte, maps as map[string]interface{} with other
+// key types formatted as strings, the timestamp extension as time.Time and
+// other extensions as Ext.
+func Decode(data []byte) (interface{}, []byte, error) {
+	d := decoder{data: data}
+	value, err := d.value(0)
+	if err != nil {
+		return nil, data, err
+	}
+	return value, d.data, nil
+}
+
+// decoder consumes values from the front of data
+type decoder struct {
+	data []byte
+}
+
+// take consumes n bytes
+func (d *decoder) take(n int) ([]byte, error) {
+	if n < 0 || len(d.data) < n {
+		return nil, ErrShortBuffer
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b, nil
+}
+
+// length consumes a big-endian length of size bytes
+func (d *decoder) length(size int) (int, error) {
+	b, err := d.take(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return int(b[0]), nil
+	case 2:
+		return int(binary.BigEndian.Uint16(b)), nil
+	default:
+		n := binary.BigEndian.Uint32(b)
+		if uint64(n) > uint64(math.MaxInt32) {
+			return 0, fmt.Errorf("msgpack: length %d too large", n)
+		}
+		return int(n), nil
+	}
+}
+
+// value decodes one value
+func (d *decoder) value(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, errors.New("msgpack: nesting too deep")
+	}
+
+	head, err := d.take(1)
+	if err != nil {
+		return nil, err
+	}
+	c := head[0]
+
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil // #nosec G115 - negative fixint
+	case c&0xf0 == 0x80:
+		return d.mapValue(int(c&0x0f), depth)
+	case c&0xf0 == 0x90:
+		return d.array(int(c&0x0f), depth)
+	case c&0xe0 == 0xa0:
+		return d.str(int(c & 0x1f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.length(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.take(n)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), b...), nil
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.length(1 << (c - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return d.ext(n)
+	case 0xca:
+		b, err := d.take(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+	case 0xcb:
+		b, err := d.take(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		b, err := d.take(1 << (c - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		v := bigEndian(b)
+		if v > math.MaxInt64 {
+			return v, nil
+		}
+		return int64(v), nil // #nosec G115 - range checked
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		b, err := d.take(1 << (c - 0xd0))
+		if err != nil {
+			return nil, err
+		}
+		// Sign-extend from the encoded width
+		shift := 64 - 8*len(b)
+		return int64(bigEndian(b)<<shift) >> shift, nil // #nosec G115 - two's complement
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.ext(1 << (c - 0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.length(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.str(n)
+	case 0xdc, 0xdd:
+		n, err := d.length(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.array(n, depth)
+	case 0xde, 0xdf:
+		n, err := d.length(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.mapValue(n, depth)
+	default:
+		return nil, fmt.Errorf("msgpack: invalid type byte 0x%02x", c)
+	}
+}
+
+// str decodes a string of n bytes
+func (d *decoder) str(n int) (interface{}, error) {
+	b, err := d.take(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// array decodes n elements
+func (d *decoder) array(n int, depth int) (interface{}, error) {
+	if n > len(d.data) {
+		return nil, ErrShortBuffer
+	}
+	values := make([]interface{}, n)
+	for i := range values {
+		value, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// mapValue decodes n key/value pairs
+func (d *decoder) mapValue(n int, depth int) (interface{}, error) {
+	if 2*n > len(d.data) {
+		return nil, ErrShortBuffer
+	}
+	values := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		key, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		value, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := key.(string); ok {
+			values[s] = value
+		} else {
+			values[fmt.Sprint(key)] = value
+		}
+	}
+	return values, nil
+}
+
+// ext decodes an extension value with n data bytes
+func (d *decoder) ext(n int) (interface{}, error) {
+	b, err := d.take(n + 1)
+	if err != nil {
+		return nil, err
+	}
+	typ, data := int8(b[0]), b[1:] // #nosec G115 - extension types are signed bytes
+
+	if typ == -1 {
+		switch len(data) {
+		case 4:
+			return time.Unix(int64(binary.BigEndian.Uint32(data)), 0).UTC(), nil
+		case 8:
+			v := binary.BigEndian.Uint64(data)
+			return time.Unix(int64(v&(1<<34-1)), int64(v>>34)).UTC(), nil // #nosec G115 - 34 and 30 bit fields
+		case 12:
+			nsec := binary.BigEndian.Uint32(data[:4])
+			sec := int64(binary.BigEndian.Uint64(data[4:])) // #nosec G115 - two's complement
+			return time.Unix(sec, int64(nsec)).UTC(), nil
+		}
+	}
+	return Ext{Type: typ, Data: append([]byte(nil), data...)}, nil
+}
+
+// bigEndian reads an unsigned big-endian integer of 1 to 8 bytes
+func bigEndian(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
@@ -0,0 +1,262 @@
+// Package msgpack encodes and decodes the MessagePack values used by log
+// records: nil, booleans, integers, floats, strings, binary data, arrays,
+// maps with string keys and extension types.
+package msgpack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+)
+
+// Ext is a MessagePack extension value
+type Ext struct {
+	Type int8
+	Data []byte
+}
+
+// AppendNil appends nil
+func AppendNil(b []byte) []byte {
+	return append(b, 0xc0)
+}
+
+// AppendBool appends a boolean
+func AppendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 0xc3)
+	}
+	return append(b, 0xc2)
+}
+
+// AppendInt appends a signed integer in its smallest encoding
+func AppendInt(b []byte, v int64) []byte {
+	switch {
+	case v >= 0:
+		return AppendUint(b, uint64(v))
+	case v >= -32:
+		return append(b, byte(v)) // #nosec G115 - negative fixint
+	case v >= math.MinInt8:
+		return append(b, 0xd0, byte(v)) // #nosec G115 - range checked
+	case v >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(b, 0xd1), uint16(v)) // #nosec G115 - two's complement
+	case v >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(v)) // #nosec G115 - two's complement
+	default:
+		return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(v)) // #nosec G115 - two's complement
+	}
+}
+
+// AppendUint appends an unsigned integer in its smallest encoding
+func AppendUint(b []byte, v uint64) []byte {
+	switch {
+	case v <= 0x7f:
+		return append(b, byte(v))
+	case v <= math.MaxUint8:
+		return append(b, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xcd), uint16(v))
+	case v <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0xce), uint32(v))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, 0xcf), v)
+	}
+}
+
+// AppendFloat appends a 64-bit float
+func AppendFloat(b []byte, v float64) []byte {
+	return binary.BigEndian.AppendUint64(append(b, 0xcb), math.Float64bits(v))
+}
+
+// AppendString appends a string
+func AppendString(b []byte, s string) []byte {
+	n := len(s)
+	switch {
+	case n <= 31:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, 0xda), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xdb), uint32(n)) // #nosec G115 - strings are far below 4 GiB
+	}
+	return append(b, s...)
+}
+
+// AppendBinary appends binary data
+func AppendBinary(b []byte, data []byte) []byte {
+	n := len(data)
+	switch {
+	case n <= math.MaxUint8:
+		b = append(b, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, 0xc5), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xc6), uint32(n)) // #nosec G115 - payloads are far below 4 GiB
+	}
+	return append(b, data...)
+}
+
+// AppendArrayHeader appends the header of an array of n elements
+func AppendArrayHeader(b []byte, n int) []byte {
+	switch {
+	case n <= 15:
+		return append(b, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xdc), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(b, 0xdd), uint32(n)) // #nosec G115 - arrays are far below 4G elements
+	}
+}
+
+// AppendMapHeader appends the header of a map of n key/value pairs
+func AppendMapHeader(b []byte, n int) []byte {
+	switch {
+	case n <= 15:
+		return append(b, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xde), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(b, 0xdf), uint32(n)) // #nosec G115 - maps are far below 4G entries
+	}
+}
+
+// AppendExt appends an extension value
+func AppendExt(b []byte, typ int8, data []byte) []byte {
+	n := len(data)
+	switch n {
+	case 1:
+		b = append(b, 0xd4)
+	case 2:
+		b = append(b, 0xd5)
+	case 4:
+		b = append(b, 0xd6)
+	case 8:
+		b = append(b, 0xd7)
+	case 16:
+		b = append(b, 0xd8)
+	default:
+		switch {
+		case n <= math.MaxUint8:
+			b = append(b, 0xc7, byte(n))
+		case n <= math.MaxUint16:
+			b = binary.BigEndian.AppendUint16(append(b, 0xc8), uint16(n))
+		default:
+			b = binary.BigEndian.AppendUint32(append(b, 0xc9), uint32(n)) // #nosec G115 - payloads are far below 4 GiB
+		}
+	}
+	return append(append(b, byte(typ)), data...) // #nosec G115 - extension types are signed bytes
+}
+
+// AppendTimestamp appends t as the timestamp extension type (-1)
+func AppendTimestamp(b []byte, t time.Time) []byte {
+	sec, nsec := t.Unix(), t.Nanosecond()
+	if sec >= 0 && sec <= math.MaxUint32 && nsec == 0 {
+		return AppendExt(b, -1, binary.BigEndian.AppendUint32(nil, uint32(sec)))
+	}
+	data := binary.BigEndian.AppendUint32(nil, uint32(nsec)) // #nosec G115 - nanoseconds fit in 30 bits
+	return AppendExt(b, -1, binary.BigEndian.AppendUint64(data, uint64(sec)))
+}
+
+// AppendValue appends a Go value. Maps are encoded with string keys, times
+// as RFC 3339 strings, errors and Stringers as their text, and other types
+// through their JSON encoding.
+func AppendValue(b []byte, value interface{}) []byte {
+	switch v := value.(type) {
+	case nil:
+		return AppendNil(b)
+	case bool:
+		return AppendBool(b, v)
+	case int:
+		return AppendInt(b, int64(v))
+	case int8:
+		return AppendInt(b, int64(v))
+	case int16:
+		return AppendInt(b, int64(v))
+	case int32:
+		return AppendInt(b, int64(v))
+	case int64:
+		return AppendInt(b, v)
+	case uint:
+		return AppendUint(b, uint64(v))
+	case uint8:
+		return AppendUint(b, uint64(v))
+	case uint16:
+		return AppendUint(b, uint64(v))
+	case uint32:
+		return AppendUint(b, uint64(v))
+	case uint64:
+		return AppendUint(b, v)
+	case float32:
+		return AppendFloat(b, float64(v))
+	case float64:
+		return AppendFloat(b, v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return AppendInt(b, i)
+		}
+		if f, err := v.Float64(); err == nil {
+			return AppendFloat(b, f)
+		}
+		return AppendString(b, v.String())
+	case string:
+		return AppendString(b, v)
+	case []byte:
+		return AppendBinary(b, v)
+	case Ext:
+		return AppendExt(b, v.Type, v.Data)
+	case time.Time:
+		return AppendString(b, v.Format(time.RFC3339Nano))
+	case time.Duration:
+		return AppendString(b, v.String())
+	case error:
+		return AppendString(b, v.Error())
+	case fmt.Stringer:
+		return AppendString(b, v.String())
+	case map[string]interface{}:
+		b = AppendMapHeader(b, len(v))
+		for key, item := range v {
+			b = AppendValue(AppendString(b, key), item)
+		}
+		return b
+	case map[string]string:
+		b = AppendMapHeader(b, len(v))
+		for key, item := range v {
+			b = AppendString(AppendString(b, key), item)
+		}
+		return b
+	case []interface{}:
+		b = AppendArrayHeader(b, len(v))
+		for _, item := range v {
+			b = AppendValue(b, item)
+		}
+		return b
+	case []string:
+		b = AppendArrayHeader(b, len(v))
+		for _, item := range v {
+			b = AppendString(b, item)
+		}
+		return b
+	}
+
+	if rv := reflect.ValueOf(value); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return AppendNil(b)
+	}
+
+	// Other types are encoded through JSON, as the JSON formatter would write them
+	data, err := json.Marshal(value)
+	if err != nil {
+		return AppendString(b, fmt.Sprint(value))
+	}
+	var decoded interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		return AppendString(b, string(data))
+	}
+	return AppendValue(b, decoded)
+}
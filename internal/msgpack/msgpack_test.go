@@ -0,0 +1,123 @@
+package msgpack
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRoundTrip(t *testing.T) {
+	timestamp := time.Date(2024, 5, 1, 12, 0, 0, 250, time.UTC)
+	tests := []struct {
+		name     string
+		value    interface{}
+		expected interface{}
+	}{
+		{"nil", nil, nil},
+		{"true", true, true},
+		{"false", false, false},
+		{"positive fixint", 7, int64(7)},
+		{"negative fixint", -5, int64(-5)},
+		{"uint8", 200, int64(200)},
+		{"uint16", 60000, int64(60000)},
+		{"uint32", uint32(4000000000), int64(4000000000)},
+		{"uint64", uint64(math.MaxUint64), uint64(math.MaxUint64)},
+		{"int8", -100, int64(-100)},
+		{"int16", -30000, int64(-30000)},
+		{"int32", -2000000000, int64(-2000000000)},
+		{"int64", int64(math.MinInt64), int64(math.MinInt64)},
+		{"float", 12.5, 12.5},
+		{"float32", float32(0.5), 0.5},
+		{"fixstr", "hello", "hello"},
+		{"str8", strings.Repeat("a", 100), strings.Repeat("a", 100)},
+		{"str16", strings.Repeat("b", 1000), strings.Repeat("b", 1000)},
+		{"str32", strings.Repeat("c", 70000), strings.Repeat("c", 70000)},
+		{"binary", []byte{1, 2, 3}, []byte{1, 2, 3}},
+		{"array", []interface{}{1, "two", nil}, []interface{}{int64(1), "two", nil}},
+		{"array16", make([]interface{}, 20), make([]interface{}, 20)},
+		{"strings", []string{"a", "b"}, []interface{}{"a", "b"}},
+		{
+			"map",
+			map[string]interface{}{"user": "ada", "nested": map[string]string{"tier": "gold"}},
+			map[string]interface{}{"user": "ada", "nested": map[string]interface{}{"tier": "gold"}},
+		},
+		{"ext", Ext{Type: 5, Data: []byte{1, 2, 3}}, Ext{Type: 5, Data: []byte{1, 2, 3}}},
+		{"fixext", Ext{Type: 0, Data: make([]byte, 8)}, Ext{Type: 0, Data: make([]byte, 8)}},
+		{"time", timestamp, timestamp.Format(time.RFC3339Nano)},
+		{"error", errors.New("boom"), "boom"},
+		{"struct", struct {
+			Name string `json:"name"`
+			Age  int    `json:"age"`
+		}{"ada", 36}, map[string]interface{}{"name": "ada", "age": int64(36)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := AppendValue(nil, tt.value)
+			value, rest, err := Decode(data)
+			if err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+			if len(rest) != 0 {
+				t.Errorf("Expected no remaining bytes, got %d", len(rest))
+			}
+			if !reflect.DeepEqual(value, tt.expected) {
+				t.Errorf("Expected %#v, got %#v", tt.expected, value)
+			}
+		})
+	}
+}
+
+func TestTimestamp(t *testing.T) {
+	for _, timestamp := range []time.Time{
+		time.Unix(1714564800, 0).UTC(),
+		time.Unix(1714564800, 123456789).UTC(),
+		time.Unix(-1, 5).UTC(),
+	} {
+		value, _, err := Decode(AppendTimestamp(nil, timestamp))
+		if err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		if got, ok := value.(time.Time); !ok || !got.Equal(timestamp) {
+			t.Errorf("Expected %v, got %v", timestamp, value)
+		}
+	}
+
+	// The 64-bit form is only decoded
+	data := []byte{0xd7, 0xff, 0, 0, 0, 4, 0, 0, 0, 1}
+	if value, _, err := Decode(data); err != nil || !value.(time.Time).Equal(time.Unix(1, 1)) {
+		t.Errorf("Unexpected 64-bit timestamp %v, %v", value, err)
+	}
+}
+
+func TestDecodeSequence(t *testing.T) {
+	data := AppendString(AppendInt(nil, 1), "next")
+	first, rest, err := Decode(data)
+	if err != nil || first != int64(1) {
+		t.Fatalf("Unexpected first value %v, %v", first, err)
+	}
+	second, rest, err := Decode(rest)
+	if err != nil || second != "next" || len(rest) != 0 {
+		t.Errorf("Unexpected second value %v, %v", second, err)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for _, data := range [][]byte{
+		nil,
+		{0xc1},
+		{0xa5, 'a'},
+		{0xdc, 0xff, 0xff},
+		{0xcd, 0x01},
+		AppendMapHeader(nil, 2),
+		bytes.Repeat([]byte{0x91}, 100),
+	} {
+		if _, _, err := Decode(data); err == nil {
+			t.Errorf("Expected error for % x", data)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package testing
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestCertificate is a self-signed certificate for localhost that tests can
+// use as CA, server and client certificate at once.
+type TestCertificate struct {
+	Certificate tls.Certificate
+	Pool        *x509.CertPool // Contains the certificate as a trusted root
+	CertFile    string         // PEM-encoded certificate in a test temporary directory
+	KeyFile     string         // PEM-encoded private key in a test temporary directory
+}
+
+// NewTestCertificate creates a self-signed certificate valid for localhost,
+// 127.0.0.1 and ::1, for both server and client authentication.
+func NewTestCertificate(t *testing.T) *TestCertificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("Failed to load key pair: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(certPEM)
+
+	dir := t.TempDir()
+	tc := &TestCertificate{
+		Certificate: cert,
+		Pool:        pool,
+		CertFile:    filepath.Join(dir, "cert.pem"),
+		KeyFile:     filepath.Join(dir, "key.pem"),
+	}
+	if err := os.WriteFile(tc.CertFile, certPEM, 0600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(tc.KeyFile, keyPEM, 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+
+	return tc
+}
+
+// ServerConfig returns a TLS server configuration that requires clients to
+// present the test certificate
+func (tc *TestCertificate) ServerConfig() *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{tc.Certificate},
+		ClientCAs:    tc.Pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}
+}
+
+// ClientConfig returns a TLS client configuration that trusts and presents the test certificate
+func (tc *TestCertificate) ClientConfig() *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{tc.Certificate},
+		RootCAs:      tc.Pool,
+		MinVersion:   tls.VersionTLS12,
+	}
+}
@@ -0,0 +1,41 @@
+package testing
+
+import (
+	"crypto/tls"
+	"io"
+	"testing"
+)
+
+func TestNewTestCertificate(t *testing.T) {
+	tc := NewTestCertificate(t)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", tc.ServerConfig())
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = conn.Write([]byte("ok"))
+	}()
+
+	conn, err := tls.Dial("tcp", listener.Addr().String(), tc.ClientConfig())
+	if err != nil {
+		t.Fatalf("Mutual TLS handshake failed: %v", err)
+	}
+	defer conn.Close()
+
+	data, err := io.ReadAll(conn)
+	if err != nil || string(data) != "ok" {
+		t.Errorf("Expected ok, got %q, %v", data, err)
+	}
+
+	if _, err := tls.LoadX509KeyPair(tc.CertFile, tc.KeyFile); err != nil {
+		t.Errorf("Failed to load written key pair: %v", err)
+	}
+}
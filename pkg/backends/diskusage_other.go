@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd
+
+package backends
+
+// GetDiskUsage returns capacity and free space for the filesystem containing path.
+// Platforms without statfs report ErrDiskUsageUnsupported, which disables
+// free-space checks while leaving the byte budgets in effect.
+func GetDiskUsage(path string) (DiskUsage, error) {
+	return DiskUsage{}, ErrDiskUsageUnsupported
+}
@@ -0,0 +1,23 @@
+//go:build linux || darwin || freebsd
+
+package backends
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// GetDiskUsage returns capacity and free space for the filesystem containing path
+func GetDiskUsage(path string) (DiskUsage, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return DiskUsage{}, fmt.Errorf("statfs %s: %w", path, err)
+	}
+
+	blockSize := uint64(stat.Bsize) // #nosec G115 - block size is always positive
+	return DiskUsage{
+		Total:     uint64(stat.Blocks) * blockSize,
+		Free:      uint64(stat.Bfree) * blockSize,
+		Available: uint64(stat.Bavail) * blockSize, // #nosec G115 - available blocks are never negative
+	}, nil
+}
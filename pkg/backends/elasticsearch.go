@@ -0,0 +1,321 @@
+package backends
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultElasticsearchIndex is the index template used when neither an index
+// nor a data stream is configured
+const DefaultElasticsearchIndex = "logs-omni-%Y.%m.%d"
+
+// ElasticsearchConfig configures an Elasticsearch or OpenSearch backend
+type ElasticsearchConfig struct {
+	URL           string            // Cluster base URL
+	Index         string            // Index template; %Y, %m, %d and %H expand from the entry time (UTC)
+	DataStream    string            // Data stream name; takes precedence over Index
+	FieldMapping  map[string]string // Renames document fields, e.g. "message" to "log.message"
+	APIKey        string            // Encoded API key, sent as "Authorization: ApiKey <key>"
+	Username      string            // Basic auth, used when APIKey is empty
+	Password      string
+	Compress      bool          // Gzip request bodies
+	BatchSize     int           // Documents per bulk request; 0 = DefaultHTTPBatchSize
+	BatchBytes    int           // Bytes per bulk request; 0 = DefaultHTTPBatchBytes
+	FlushInterval time.Duration // 0 = DefaultHTTPFlushInterval, negative disables
+	Timeout       time.Duration // 0 = DefaultHTTPTimeout
+	MaxRetries    int           // Retries for failed requests and documents; 0 = DefaultHTTPMaxRetries, negative disables
+	MinBackoff    time.Duration // 0 = DefaultHTTPMinBackoff
+	MaxBackoff    time.Duration // 0 = DefaultHTTPMaxBackoff
+	MaxInFlight   int           // Concurrent bulk requests; 0 = DefaultHTTPMaxInFlight
+	TLSConfig     *tls.Config
+	Client        *http.Client
+}
+
+// esDocument is a queued document with its bulk action
+type esDocument struct {
+	action []byte
+	source []byte
+}
+
+// ElasticsearchBackend indexes entries with the bulk API. Each entry becomes
+// a document with @timestamp, level, message and the entry fields. Bulk
+// responses are checked per document: documents failing with 429 or 5xx are
+// retried, other failures are rejected.
+type ElasticsearchBackend struct {
+	batcher      *httpBatcher
+	path         string
+	index        string
+	dataStream   string
+	fieldMapping map[string]string
+
+	mu       sync.Mutex
+	rejected uint64
+}
+
+// NewElasticsearchBackend creates an Elasticsearch backend and starts its
+// flush interval timer
+func NewElasticsearchBackend(config ElasticsearchConfig) (*ElasticsearchBackend, error) {
+	endpoint, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Elasticsearch URL: %w", err)
+	}
+	if (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid Elasticsearch URL %q", config.URL)
+	}
+	endpoint.Path = strings.TrimSuffix(endpoint.Path, "/") + "/_bulk"
+
+	if config.Index == "" && config.DataStream == "" {
+		config.Index = DefaultElasticsearchIndex
+	}
+
+	header := http.Header{}
+	header.Set("User-Agent", "omni")
+	header.Set("Content-Type", "application/x-ndjson")
+	switch {
+	case config.APIKey != "":
+		header.Set("Authorization", "ApiKey "+config.APIKey)
+	case config.Username != "":
+		req := http.Request{Header: http.Header{}}
+		req.SetBasicAuth(config.Username, config.Password)
+		header.Set("Authorization", req.Header.Get("Authorization"))
+	}
+
+	eb := &ElasticsearchBackend{
+		index:        config.Index,
+		dataStream:   config.DataStream,
+		fieldMapping: config.FieldMapping,
+	}
+
+	bulkURL := endpoint.String()
+	endpoint.User = nil
+	eb.path = endpoint.String()
+
+	eb.batcher = newHTTPBatcher(httpBatchConfig{
+		client:        httpClient(config.Client, config.Timeout, config.TLSConfig),
+		url:           bulkURL,
+		header:        header,
+		compress:      config.Compress,
+		batchSize:     config.BatchSize,
+		batchBytes:    config.BatchBytes,
+		flushInterval: config.FlushInterval,
+		maxRetries:    config.MaxRetries,
+		minBackoff:    config.MinBackoff,
+		maxBackoff:    config.MaxBackoff,
+		maxInFlight:   config.MaxInFlight,
+	}, encodeBulk)
+	eb.batcher.check = eb.checkBulkResponse
+
+	return eb, nil
+}
+
+// WriteRecord queues an entry as a document
+func (eb *ElasticsearchBackend) WriteRecord(rec Record) (int, error) {
+	timestamp := rec.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	source := make(map[string]interface{}, len(rec.Fields)+3)
+	for key, value := range rec.Fields {
+		source[key] = value
+	}
+	source["@timestamp"] = timestamp.UTC().Format(time.RFC3339Nano)
+	if rec.Level != "" {
+		source["level"] = rec.Level
+	}
+	source["message"] = rec.Message
+
+	if err := eb.queue(timestamp, source); err != nil {
+		return 0, err
+	}
+	return len(rec.Line), nil
+}
+
+// Write queues an entry as a document. JSON objects are indexed as they are,
+// other entries become the message of a document timestamped now.
+func (eb *ElasticsearchBackend) Write(entry []byte) (int, error) {
+	line := bytes.TrimSpace(entry)
+	var source map[string]interface{}
+	if err := json.Unmarshal(line, &source); err != nil || source == nil {
+		return eb.WriteRecord(Record{Timestamp: time.Now(), Message: string(line), Line: entry})
+	}
+
+	timestamp := time.Now()
+	if _, ok := source["@timestamp"]; !ok {
+		source["@timestamp"] = timestamp.UTC().Format(time.RFC3339Nano)
+	}
+	if err := eb.queue(timestamp, source); err != nil {
+		return 0, err
+	}
+	return len(entry), nil
+}
+
+// queue applies the field mapping and queues the document with its bulk
+// action line
+func (eb *ElasticsearchBackend) queue(timestamp time.Time, source map[string]interface{}) error {
+	for from, to := range eb.fieldMapping {
+		if value, ok := source[from]; ok {
+			delete(source, from)
+			source[to] = value
+		}
+	}
+
+	data, err := json.Marshal(source)
+	if err != nil {
+		return fmt.Errorf("encode document: %w", err)
+	}
+
+	// Data streams only accept create actions
+	op, index := "index", expandIndex(eb.index, timestamp)
+	if eb.dataStream != "" {
+		op, index = "create", eb.dataStream
+	}
+	action, err := json.Marshal(map[string]map[string]string{op: {"_index": index}})
+	if err != nil {
+		return fmt.Errorf("encode bulk action: %w", err)
+	}
+
+	return eb.batcher.add(&esDocument{action: action, source: data}, len(action)+len(data))
+}
+
+// Rejected returns the number of documents Elasticsearch refused for good,
+// such as mapping conflicts
+func (eb *ElasticsearchBackend) Rejected() uint64 {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	return eb.rejected
+}
+
+// Flush sends the queued documents and waits for all requests to finish,
+// returning the delivery failures since the last Flush
+func (eb *ElasticsearchBackend) Flush() error {
+	return eb.batcher.flush()
+}
+
+// Close sends the queued documents and stops the flush interval timer
+func (eb *ElasticsearchBackend) Close() error {
+	return eb.batcher.close()
+}
+
+// Batching returns true as documents are sent in batches on their own schedule
+func (eb *ElasticsearchBackend) Batching() bool {
+	return true
+}
+
+// SupportsAtomic returns false as batches are delivered asynchronously
+func (eb *ElasticsearchBackend) SupportsAtomic() bool {
+	return false
+}
+
+// Sync sends the queued documents
+func (eb *ElasticsearchBackend) Sync() error {
+	return eb.Flush()
+}
+
+// GetStats returns backend statistics. WriteCount counts indexed documents
+// and Dropped counts rejected documents and those that ran out of retries.
+func (eb *ElasticsearchBackend) GetStats() BackendStats {
+	return eb.batcher.stats(eb.path)
+}
+
+// encodeBulk writes the action and source line of each document
+func encodeBulk(batch []interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, item := range batch {
+		doc := item.(*esDocument)
+		buf.Write(doc.action)
+		buf.WriteByte('\n')
+		buf.Write(doc.source)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// bulkResponse is the part of a bulk API response describing item results
+type bulkResponse struct {
+	Errors bool                        `json:"errors"`
+	Items  []map[string]bulkItemResult `json:"items"`
+}
+
+type bulkItemResult struct {
+	Status int `json:"status"`
+	Error  *struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
+
+// checkBulkResponse returns the documents to retry and counts those rejected.
+// Responses other than 2xx reject the whole batch.
+func (eb *ElasticsearchBackend) checkBulkResponse(resp *http.Response, body []byte, batch []interface{}) ([]interface{}, int, error) {
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		eb.countRejected(len(batch))
+		return nil, len(batch), httpStatusError(resp, body)
+	}
+
+	var result bulkResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		// The documents may have been indexed; retrying could duplicate them
+		return nil, 0, fmt.Errorf("decode bulk response: %w", err)
+	}
+	if !result.Errors {
+		return nil, 0, nil
+	}
+	if len(result.Items) != len(batch) {
+		return nil, 0, fmt.Errorf("bulk response has %d items for %d documents", len(result.Items), len(batch))
+	}
+
+	var retry []interface{}
+	var rejected int
+	var firstErr string
+	for i, item := range result.Items {
+		for _, res := range item {
+			switch {
+			case res.Status >= 200 && res.Status < 300:
+			case res.Status == http.StatusTooManyRequests || res.Status >= 500:
+				retry = append(retry, batch[i])
+			default:
+				rejected++
+				if firstErr == "" && res.Error != nil {
+					firstErr = res.Error.Type + ": " + res.Error.Reason
+				}
+			}
+		}
+	}
+	eb.countRejected(rejected)
+
+	if rejected > 0 {
+		return retry, rejected, fmt.Errorf("%d of %d documents rejected: %s", rejected, len(batch), firstErr)
+	}
+	return retry, 0, nil
+}
+
+// countRejected records documents refused for good
+func (eb *ElasticsearchBackend) countRejected(count int) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	eb.rejected += uint64(count) // #nosec G115 - count is never negative
+}
+
+// expandIndex replaces %Y, %m, %d and %H in an index template with the UTC
+// date and hour of t, and %% with a percent sign
+func expandIndex(template string, t time.Time) string {
+	if !strings.Contains(template, "%") {
+		return template
+	}
+	t = t.UTC()
+	return strings.NewReplacer(
+		"%Y", t.Format("2006"),
+		"%m", t.Format("01"),
+		"%d", t.Format("02"),
+		"%H", t.Format("15"),
+		"%%", "%",
+	).Replace(template)
+}
@@ -0,0 +1,251 @@
+package backends_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/backends"
+)
+
+// fakeElasticsearch accepts bulk requests, failing documents whose message
+// is listed in fail with the given status until they have failed that many times
+type fakeElasticsearch struct {
+	mu       sync.Mutex
+	actions  []map[string]map[string]string
+	docs     []map[string]interface{}
+	headers  []http.Header
+	failures map[string][]int // message -> statuses to answer in turn
+}
+
+func (f *fakeElasticsearch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/_bulk" {
+		http.NotFound(w, r)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.headers = append(f.headers, r.Header)
+
+	var items []string
+	failed := false
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		var action map[string]map[string]string
+		_ = json.Unmarshal(scanner.Bytes(), &action)
+		scanner.Scan()
+		var doc map[string]interface{}
+		_ = json.Unmarshal(scanner.Bytes(), &doc)
+
+		status := http.StatusCreated
+		message, _ := doc["message"].(string)
+		if statuses := f.failures[message]; len(statuses) > 0 {
+			status, f.failures[message] = statuses[0], statuses[1:]
+		}
+		if status == http.StatusCreated {
+			f.actions = append(f.actions, action)
+			f.docs = append(f.docs, doc)
+			items = append(items, `{"index":{"status":201}}`)
+			continue
+		}
+		failed = true
+		items = append(items, fmt.Sprintf(`{"index":{"status":%d,"error":{"type":"mapper_parsing_exception","reason":"failed to parse %s"}}}`, status, message))
+	}
+
+	fmt.Fprintf(w, `{"took":1,"errors":%t,"items":[%s]}`, failed, strings.Join(items, ","))
+}
+
+func newElasticsearchBackend(t *testing.T, config backends.ElasticsearchConfig) *backends.ElasticsearchBackend {
+	t.Helper()
+	backend, err := backends.NewElasticsearchBackend(config)
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	t.Cleanup(func() { backend.Close() })
+	return backend
+}
+
+func TestElasticsearchBackend_Index(t *testing.T) {
+	es := &fakeElasticsearch{}
+	server := httptest.NewServer(es)
+	defer server.Close()
+
+	backend := newElasticsearchBackend(t, backends.ElasticsearchConfig{
+		URL:          server.URL,
+		Index:        "logs-app-%Y.%m.%d",
+		FieldMapping: map[string]string{"message": "log.message", "service": "service.name"},
+		APIKey:       "a2V5OnNlY3JldA==",
+	})
+
+	timestamp := time.Date(2024, 3, 9, 23, 30, 0, 0, time.FixedZone("EST", -5*3600))
+	_, err := backend.WriteRecord(backends.Record{
+		Timestamp: timestamp,
+		Level:     "warn",
+		Message:   "slow request",
+		Fields:    map[string]interface{}{"service": "api", "duration_ms": 1200},
+	})
+	if err != nil {
+		t.Fatalf("WriteRecord failed: %v", err)
+	}
+	// JSON entries written directly are indexed as they are
+	_, _ = backend.Write([]byte(`{"message":"raw","custom":true}` + "\n"))
+	if err := backend.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	if len(es.docs) != 2 {
+		t.Fatalf("Expected 2 documents, got %d", len(es.docs))
+	}
+	if got := es.headers[0].Get("Authorization"); got != "ApiKey a2V5OnNlY3JldA==" {
+		t.Errorf("Unexpected Authorization %q", got)
+	}
+	// The index date is taken from the entry time in UTC
+	if index := es.actions[0]["index"]["_index"]; index != "logs-app-2024.03.10" {
+		t.Errorf("Unexpected index %q", index)
+	}
+
+	doc := es.docs[0]
+	expected := map[string]interface{}{
+		"@timestamp":   "2024-03-10T04:30:00Z",
+		"level":        "warn",
+		"log.message":  "slow request",
+		"service.name": "api",
+		"duration_ms":  float64(1200),
+	}
+	if len(doc) != len(expected) {
+		t.Errorf("Expected document %v, got %v", expected, doc)
+	}
+	for key, value := range expected {
+		if doc[key] != value {
+			t.Errorf("Expected %s=%v, got %v", key, value, doc[key])
+		}
+	}
+	if es.docs[1]["log.message"] != "raw" || es.docs[1]["custom"] != true || es.docs[1]["@timestamp"] == nil {
+		t.Errorf("Unexpected raw document %v", es.docs[1])
+	}
+}
+
+func TestElasticsearchBackend_DataStream(t *testing.T) {
+	es := &fakeElasticsearch{}
+	server := httptest.NewServer(es)
+	defer server.Close()
+
+	backend := newElasticsearchBackend(t, backends.ElasticsearchConfig{
+		URL:        server.URL + "/",
+		DataStream: "logs-app-default",
+		Username:   "elastic",
+		Password:   "secret",
+		Compress:   false,
+	})
+
+	_, _ = backend.WriteRecord(backends.Record{Message: "to stream"})
+	if err := backend.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	if len(es.actions) != 1 || es.actions[0]["create"]["_index"] != "logs-app-default" {
+		t.Errorf("Expected a create action for the data stream, got %v", es.actions)
+	}
+	if !strings.HasPrefix(es.headers[0].Get("Authorization"), "Basic ") {
+		t.Errorf("Expected basic auth, got %q", es.headers[0].Get("Authorization"))
+	}
+}
+
+func TestElasticsearchBackend_ItemErrors(t *testing.T) {
+	es := &fakeElasticsearch{failures: map[string][]int{
+		"throttled": {http.StatusTooManyRequests},
+		"broken":    {http.StatusBadRequest},
+	}}
+	server := httptest.NewServer(es)
+	defer server.Close()
+
+	backend := newElasticsearchBackend(t, backends.ElasticsearchConfig{URL: server.URL, MinBackoff: time.Millisecond})
+
+	for _, message := range []string{"ok", "throttled", "broken", "fine"} {
+		_, _ = backend.WriteRecord(backends.Record{Message: message})
+	}
+	err := backend.Flush()
+	if err == nil || !strings.Contains(err.Error(), "mapper_parsing_exception") {
+		t.Errorf("Expected the rejection to be reported, got %v", err)
+	}
+
+	// Only the throttled document is retried
+	var messages []string
+	for _, doc := range es.docs {
+		messages = append(messages, doc["message"].(string))
+	}
+	if strings.Join(messages, ",") != "ok,fine,throttled" {
+		t.Errorf("Unexpected indexed documents %v", messages)
+	}
+
+	stats := backend.GetStats()
+	if stats.WriteCount != 3 || stats.Dropped != 1 || stats.Requests != 2 || stats.Retries != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if backend.Rejected() != 1 {
+		t.Errorf("Expected 1 rejected document, got %d", backend.Rejected())
+	}
+}
+
+func TestElasticsearchBackend_RequestErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"security_exception"}`, http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	backend := newElasticsearchBackend(t, backends.ElasticsearchConfig{URL: server.URL})
+	_, _ = backend.Write([]byte("plain text"))
+	if err := backend.Flush(); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Expected 401 error, got %v", err)
+	}
+	if stats := backend.GetStats(); stats.Dropped != 1 || stats.Requests != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	if _, err := backends.NewElasticsearchBackend(backends.ElasticsearchConfig{URL: "es:9200"}); err == nil {
+		t.Error("Expected error for a URL without scheme")
+	}
+}
+
+func TestElasticsearchBackend_Batching(t *testing.T) {
+	var mu sync.Mutex
+	var requests []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body bytes.Buffer
+		_, _ = body.ReadFrom(r.Body)
+		mu.Lock()
+		requests = append(requests, bytes.Count(body.Bytes(), []byte("\n"))/2)
+		mu.Unlock()
+		fmt.Fprint(w, `{"errors":false,"items":[]}`)
+	}))
+	defer server.Close()
+
+	backend := newElasticsearchBackend(t, backends.ElasticsearchConfig{URL: server.URL, BatchSize: 2, FlushInterval: -1})
+	for i := 0; i < 5; i++ {
+		_, _ = backend.WriteRecord(backends.Record{Message: "entry"})
+	}
+	if err := backend.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	total := 0
+	for _, count := range requests {
+		if count > 2 {
+			t.Errorf("Expected at most 2 documents per request, got %d", count)
+		}
+		total += count
+	}
+	if total != 5 || len(requests) != 3 {
+		t.Errorf("Expected 5 documents in 3 requests, got %v", requests)
+	}
+}
@@ -33,7 +33,8 @@ type FileBackendImpl struct {
 	lock   *flock.Flock
 	path   string
 	size   int64
-	mu     sync.Mutex // Protects writer access
+	stream *fileStream // Framing of the file, nil when entries are written bare
+	mu     sync.Mutex  // Protects writer access
 }
 
 // NewFileBackend creates a new file backend
@@ -87,7 +88,12 @@ func (fb *FileBackendImpl) Write(entry []byte) (int, error) {
 		_ = fb.lock.Unlock() // Best effort unlock
 	}()
 
-	// Write entry
+	// Write entry, separated from the previous one in a stream
+	if fb.stream != nil {
+		n, total, err := fb.stream.write(fb.writer, entry)
+		fb.size += int64(total)
+		return n, err
+	}
 	n, err := fb.writer.Write(entry)
 	if err != nil {
 		return n, err
@@ -97,6 +103,40 @@ func (fb *FileBackendImpl) Write(entry []byte) (int, error) {
 	return n, nil
 }
 
+// SetStream frames the file with stream
+func (fb *FileBackendImpl) SetStream(stream FileStream) error {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+
+	if err := fb.writer.Flush(); err != nil {
+		return fmt.Errorf("flush: %w", err)
+	}
+	s := &fileStream{FileStream: stream}
+	n, err := s.open(fb.path, fb.file, fb.writer, fb.size)
+	if err != nil {
+		return err
+	}
+	fb.size += n
+	fb.stream = s
+	return nil
+}
+
+// FinishStream writes the end of the file's stream and flushes it
+func (fb *FileBackendImpl) FinishStream() error {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+
+	if fb.stream == nil {
+		return nil
+	}
+	n, err := fb.stream.finish(fb.writer)
+	fb.size += int64(n)
+	if err != nil {
+		return err
+	}
+	return fb.writer.Flush()
+}
+
 // Flush flushes buffered data to disk
 func (fb *FileBackendImpl) Flush() error {
 	fb.mu.Lock()
@@ -116,6 +156,13 @@ func (fb *FileBackendImpl) Close() error {
 
 	var errs []error
 
+	// Finish the stream framing the file
+	if fb.stream != nil && fb.writer != nil {
+		if _, err := fb.stream.finish(fb.writer); err != nil {
+			errs = append(errs, fmt.Errorf("finish stream: %w", err))
+		}
+	}
+
 	// Flush writer (without calling Flush() to avoid deadlock)
 	if fb.writer != nil {
 		if err := fb.writer.Flush(); err != nil {
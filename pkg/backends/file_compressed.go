@@ -0,0 +1,497 @@
+package backends
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gofrs/flock"
+	"github.com/wayneeseguin/omni/pkg/features"
+)
+
+// Defaults for stream-compressed file backends
+const (
+	DefaultFrameFlushInterval = time.Second
+	DefaultMaxFrameSize       = 1024 * 1024 // 1 MB of uncompressed data per frame
+)
+
+// frameEncoder is a compressor that can be reused for successive frames.
+// Both *gzip.Writer and *zstd.Encoder satisfy it.
+type frameEncoder interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+// CompressedFileBackend writes gzip or zstd frames directly to the active log file.
+// Entries are compressed into an in-memory frame which is appended to the file
+// as a single write when the frame is completed on Sync, on the flush interval,
+// on rotation, or when it reaches the maximum frame size. A crash loses at
+// most the frame being built; a frame torn while it was appended is removed
+// when the file is next opened, leaving a valid concatenation of frames.
+type CompressedFileBackend struct {
+	file             *os.File
+	lock             *flock.Flock
+	path             string
+	size             int64 // Compressed bytes on disk
+	uncompressedSize int64
+	compression      features.CompressionType
+	encoder          frameEncoder
+	frame            bytes.Buffer
+	frameBytes       int64 // Uncompressed bytes in the open frame
+	maxFrameSize     int64
+	stream           *fileStream // Framing of each file, nil when entries are written bare
+	writeCount       uint64
+	errorCount       uint64
+	lastError        time.Time
+	mu               sync.Mutex
+	flushDone        chan struct{}
+	flushWg          sync.WaitGroup
+	errorHandler     func(source, dest, msg string, err error)
+}
+
+// NewCompressedFileBackend creates a file backend that compresses the active file
+// with the given compression type and level. A positive flushInterval completes
+// the open frame periodically so data reaches disk without an explicit Sync.
+func NewCompressedFileBackend(path string, compression features.CompressionType, level int, flushInterval time.Duration) (*CompressedFileBackend, error) {
+	if compression == features.CompressionNone {
+		return nil, fmt.Errorf("stream compression requires gzip or zstd")
+	}
+
+	// Create directory if needed
+	dir := filepath.Dir(path)
+	// #nosec G301 - log directories need to be accessible by other processes
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create directory: %w", err)
+	}
+
+	// Clean the path to prevent directory traversal
+	cleanPath := filepath.Clean(path)
+
+	cb := &CompressedFileBackend{
+		lock:         flock.New(cleanPath),
+		path:         cleanPath,
+		compression:  compression,
+		maxFrameSize: DefaultMaxFrameSize,
+	}
+
+	encoder, err := features.NewCompressingWriter(&cb.frame, compression, level)
+	if err != nil {
+		return nil, fmt.Errorf("create encoder: %w", err)
+	}
+	resettable, ok := encoder.(frameEncoder)
+	if !ok {
+		return nil, fmt.Errorf("compression type %s does not support streaming", features.CompressionTypeString(compression))
+	}
+	cb.encoder = resettable
+
+	if err := cb.openFile(); err != nil {
+		return nil, err
+	}
+
+	if flushInterval > 0 {
+		cb.startFlusher(flushInterval)
+	}
+
+	return cb, nil
+}
+
+// SetErrorHandler sets the error handler for background flush failures
+func (cb *CompressedFileBackend) SetErrorHandler(handler func(source, dest, msg string, err error)) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.errorHandler = handler
+}
+
+// SetMaxFrameSize sets the uncompressed size at which a frame is completed
+func (cb *CompressedFileBackend) SetMaxFrameSize(size int64) {
+	if size <= 0 {
+		size = DefaultMaxFrameSize
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.maxFrameSize = size
+}
+
+// Write compresses an entry into the open frame. It returns the uncompressed length.
+func (cb *CompressedFileBackend) Write(entry []byte) (int, error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.file == nil {
+		return 0, fmt.Errorf("backend closed")
+	}
+
+	n, total, err := cb.writeEntryLocked(entry)
+	cb.frameBytes += int64(total)
+	cb.uncompressedSize += int64(total)
+	if err != nil {
+		cb.trackError()
+		return n, err
+	}
+	cb.writeCount++
+
+	if cb.frameBytes >= cb.maxFrameSize {
+		if err := cb.finishFrameLocked(); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// writeEntryLocked compresses an entry, separated from the previous one in a
+// stream, returning the bytes of entry written and the total. Caller must
+// hold cb.mu.
+func (cb *CompressedFileBackend) writeEntryLocked(entry []byte) (int, int, error) {
+	if cb.stream != nil {
+		return cb.stream.write(cb.encoder, entry)
+	}
+	n, err := cb.encoder.Write(entry)
+	return n, n, err
+}
+
+// SetStream frames the file, and every file opened after rotation, with
+// stream. Compressed frames cannot be reopened on restart, so streams with
+// an end are refused; a start, such as a CSV header, is written to new files.
+func (cb *CompressedFileBackend) SetStream(stream FileStream) error {
+	if len(stream.End) > 0 {
+		return fmt.Errorf("compressed files cannot reopen a stream with an end")
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.file == nil {
+		return fmt.Errorf("backend closed")
+	}
+	s := &fileStream{FileStream: stream}
+	if err := cb.startStreamLocked(s); err != nil {
+		return err
+	}
+	cb.stream = s
+	return nil
+}
+
+// FinishStream does nothing, as streams of compressed files have no end
+func (cb *CompressedFileBackend) FinishStream() error {
+	return nil
+}
+
+// startStreamLocked starts the stream of the active file, writing its start
+// when the file is new. Caller must hold cb.mu.
+func (cb *CompressedFileBackend) startStreamLocked(s *fileStream) error {
+	n, err := s.open(cb.path, cb.file, cb.encoder, cb.size+cb.frameBytes)
+	cb.frameBytes += n
+	cb.uncompressedSize += n
+	if err != nil {
+		cb.trackError()
+		return fmt.Errorf("start stream: %w", err)
+	}
+	return nil
+}
+
+// Flush is a no-op for the open frame. Completing a frame on every flush
+// would defeat compression, so frames are completed by Sync, the flush
+// interval, rotation and the frame size limit.
+func (cb *CompressedFileBackend) Flush() error {
+	return nil
+}
+
+// Sync completes the open frame and syncs the file to disk
+func (cb *CompressedFileBackend) Sync() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.file == nil {
+		return nil
+	}
+	if err := cb.finishFrameLocked(); err != nil {
+		return err
+	}
+	return cb.file.Sync()
+}
+
+// Close completes the open frame and closes the file
+func (cb *CompressedFileBackend) Close() error {
+	cb.stopFlusher()
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.file == nil {
+		return nil
+	}
+
+	var errs []error
+	if err := cb.finishFrameLocked(); err != nil {
+		errs = append(errs, fmt.Errorf("finish frame: %w", err))
+	}
+	if err := cb.file.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("close file: %w", err))
+	}
+	cb.file = nil
+
+	if len(errs) > 0 {
+		return fmt.Errorf("close errors: %v", errs)
+	}
+	return nil
+}
+
+// SupportsAtomic returns true as complete frames are appended under the file lock
+func (cb *CompressedFileBackend) SupportsAtomic() bool {
+	return true
+}
+
+// Rotate rotates the active file
+func (cb *CompressedFileBackend) Rotate() error {
+	_, err := cb.RotateFile()
+	return err
+}
+
+// RotateFile completes the open frame, renames the active file to
+// path.<timestamp><suffix> and opens a new active file. The rotated file
+// carries its compression suffix so CompressionManager leaves it alone.
+func (cb *CompressedFileBackend) RotateFile() (string, error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.file == nil {
+		return "", fmt.Errorf("backend closed")
+	}
+
+	if err := cb.finishFrameLocked(); err != nil {
+		return "", err
+	}
+	if err := cb.file.Close(); err != nil {
+		return "", fmt.Errorf("close before rotation: %w", err)
+	}
+	cb.file = nil
+
+	timestamp := time.Now().UTC().Format(features.RotationTimeFormat)
+	rotatedPath := fmt.Sprintf("%s.%s%s", cb.path, timestamp, features.CompressionSuffix(cb.compression))
+	if err := os.Rename(cb.path, rotatedPath); err != nil {
+		// Keep logging to the existing file if the rename fails
+		if openErr := cb.openFile(); openErr != nil {
+			return "", fmt.Errorf("rotating log: %w (reopen: %v)", err, openErr)
+		}
+		return "", fmt.Errorf("rotating log: %w", err)
+	}
+
+	if err := cb.openFile(); err != nil {
+		return rotatedPath, fmt.Errorf("reopen after rotation: %w", err)
+	}
+	cb.uncompressedSize = 0
+	if cb.stream != nil {
+		if err := cb.startStreamLocked(cb.stream); err != nil {
+			return rotatedPath, err
+		}
+	}
+	return rotatedPath, nil
+}
+
+// Size returns the compressed size of the active file on disk
+func (cb *CompressedFileBackend) Size() int64 {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.size
+}
+
+// Path returns the file path
+func (cb *CompressedFileBackend) Path() string {
+	return cb.path
+}
+
+// GetWriter returns nil as entries are written through the compressor
+func (cb *CompressedFileBackend) GetWriter() *bufio.Writer {
+	return nil
+}
+
+// GetFile returns the underlying file
+func (cb *CompressedFileBackend) GetFile() *os.File {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.file
+}
+
+// GetLock returns the file lock
+func (cb *CompressedFileBackend) GetLock() *flock.Flock {
+	return cb.lock
+}
+
+// GetSize returns the compressed size of the active file on disk
+func (cb *CompressedFileBackend) GetSize() int64 {
+	return cb.Size()
+}
+
+// UncompressedSize returns the bytes written to the active file before compression
+func (cb *CompressedFileBackend) UncompressedSize() int64 {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.uncompressedSize
+}
+
+// Compression returns the compression type of the active file
+func (cb *CompressedFileBackend) Compression() features.CompressionType {
+	return cb.compression
+}
+
+// GetStats returns backend statistics
+func (cb *CompressedFileBackend) GetStats() BackendStats {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	bytesWritten := uint64(0)
+	if cb.size > 0 {
+		bytesWritten = uint64(cb.size)
+	}
+
+	return BackendStats{
+		Path:         cb.path,
+		Size:         cb.size,
+		WriteCount:   cb.writeCount,
+		BytesWritten: bytesWritten,
+		ErrorCount:   cb.errorCount,
+		LastError:    cb.lastError,
+	}
+}
+
+// openFile opens the active file for appending. Caller must hold cb.mu or
+// be the constructor.
+func (cb *CompressedFileBackend) openFile() error {
+	file, err := os.OpenFile(cb.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644) // #nosec G302 - log files need to be readable
+	if err != nil {
+		return fmt.Errorf("open file: %w", err)
+	}
+
+	size, err := cb.dropTornFrame(file)
+	if err != nil {
+		_ = file.Close() // Best effort close on error path
+		return err
+	}
+
+	cb.file = file
+	cb.size = size
+	return nil
+}
+
+// dropTornFrame truncates the file opened as file to its complete frames,
+// removing a frame torn by a crash so that appended frames stay readable. It
+// returns the size of the file.
+func (cb *CompressedFileBackend) dropTornFrame(file *os.File) (int64, error) {
+	// Hold the file lock so a frame being appended by another process is not
+	// mistaken for a torn one
+	if err := cb.lock.Lock(); err != nil {
+		return 0, fmt.Errorf("acquire lock: %w", err)
+	}
+	defer func() {
+		_ = cb.lock.Unlock() // Best effort unlock
+	}()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("stat file: %w", err)
+	}
+	size := info.Size()
+	if size == 0 {
+		return 0, nil
+	}
+
+	src, err := os.Open(cb.path)
+	if err != nil {
+		return 0, fmt.Errorf("scan frames: %w", err)
+	}
+	complete, err := features.CompleteFramesLength(src, cb.compression)
+	_ = src.Close() // Read only
+	if err != nil {
+		return 0, fmt.Errorf("scan frames: %w", err)
+	}
+	if complete < size {
+		if err := file.Truncate(complete); err != nil {
+			return 0, fmt.Errorf("drop torn frame: %w", err)
+		}
+	}
+	return complete, nil
+}
+
+// finishFrameLocked completes the open frame and appends it to the file in a
+// single write under the file lock. Caller must hold cb.mu.
+func (cb *CompressedFileBackend) finishFrameLocked() error {
+	if cb.frameBytes == 0 {
+		return nil
+	}
+
+	if err := cb.encoder.Close(); err != nil {
+		cb.trackError()
+		return fmt.Errorf("finish frame: %w", err)
+	}
+
+	if err := cb.lock.Lock(); err != nil {
+		cb.trackError()
+		return fmt.Errorf("acquire lock: %w", err)
+	}
+	n, err := cb.file.Write(cb.frame.Bytes())
+	_ = cb.lock.Unlock() // Best effort unlock
+
+	cb.size += int64(n)
+	cb.frame.Reset()
+	cb.frameBytes = 0
+	cb.encoder.Reset(&cb.frame)
+
+	if err != nil {
+		cb.trackError()
+		return fmt.Errorf("write frame: %w", err)
+	}
+	return nil
+}
+
+// trackError records a failure in the backend statistics. Caller must hold cb.mu.
+func (cb *CompressedFileBackend) trackError() {
+	cb.errorCount++
+	cb.lastError = time.Now()
+}
+
+// startFlusher starts the goroutine that completes frames on an interval
+func (cb *CompressedFileBackend) startFlusher(interval time.Duration) {
+	cb.flushDone = make(chan struct{})
+	done := cb.flushDone
+
+	cb.flushWg.Add(1)
+	go func() {
+		defer cb.flushWg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				cb.mu.Lock()
+				if cb.file != nil {
+					if err := cb.finishFrameLocked(); err != nil && cb.errorHandler != nil {
+						cb.errorHandler("flush", cb.path, "Failed to flush compressed frame", err)
+					}
+				}
+				cb.mu.Unlock()
+			case <-done:
+				return
+			}
+		}
+	}()
+}
+
+// stopFlusher stops the interval flush goroutine
+func (cb *CompressedFileBackend) stopFlusher() {
+	cb.mu.Lock()
+	done := cb.flushDone
+	cb.flushDone = nil
+	cb.mu.Unlock()
+
+	if done != nil {
+		close(done)
+		cb.flushWg.Wait()
+	}
+}
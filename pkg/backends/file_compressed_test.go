@@ -0,0 +1,305 @@
+package backends_test
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/backends"
+	"github.com/wayneeseguin/omni/pkg/features"
+)
+
+// readLogFile reads a log file through the decompressing reader
+func readLogFile(t *testing.T, path string) string {
+	t.Helper()
+
+	reader, err := features.OpenLogFile(path)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", path, err)
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return string(content)
+}
+
+func TestCompressedFileBackend_RoundTrip(t *testing.T) {
+	for _, compression := range []features.CompressionType{features.CompressionGzip, features.CompressionZstd} {
+		t.Run(features.CompressionTypeString(compression), func(t *testing.T) {
+			logPath := filepath.Join(t.TempDir(), "app.log")
+
+			backend, err := backends.NewCompressedFileBackend(logPath, compression, features.CompressionLevelDefault, 0)
+			if err != nil {
+				t.Fatalf("Failed to create backend: %v", err)
+			}
+			defer backend.Close()
+
+			var expected strings.Builder
+			for frame := 0; frame < 3; frame++ {
+				for i := 0; i < 10; i++ {
+					line := fmt.Sprintf("frame %d entry %d\n", frame, i)
+					n, err := backend.Write([]byte(line))
+					if err != nil {
+						t.Fatalf("Write failed: %v", err)
+					}
+					if n != len(line) {
+						t.Errorf("Expected %d bytes written, got %d", len(line), n)
+					}
+					expected.WriteString(line)
+				}
+
+				// Each sync completes a frame, leaving a valid stream on disk
+				if err := backend.Sync(); err != nil {
+					t.Fatalf("Sync failed: %v", err)
+				}
+				if got := readLogFile(t, logPath); got != expected.String() {
+					t.Fatalf("After frame %d expected %q, got %q", frame, expected.String(), got)
+				}
+			}
+
+			info, err := os.Stat(logPath)
+			if err != nil {
+				t.Fatalf("Failed to stat log: %v", err)
+			}
+			if backend.Size() != info.Size() {
+				t.Errorf("Expected size %d, got %d", info.Size(), backend.Size())
+			}
+			if backend.UncompressedSize() != int64(expected.Len()) {
+				t.Errorf("Expected uncompressed size %d, got %d", expected.Len(), backend.UncompressedSize())
+			}
+		})
+	}
+}
+
+func TestCompressedFileBackend_OpenFrameNotOnDisk(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "app.log")
+
+	backend, err := backends.NewCompressedFileBackend(logPath, features.CompressionGzip, features.CompressionLevelDefault, 0)
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	defer backend.Close()
+
+	if _, err := backend.Write([]byte("synced\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := backend.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	// Flush must not complete the frame; a crash now loses only this entry
+	if _, err := backend.Write([]byte("pending\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := backend.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	if got := readLogFile(t, logPath); got != "synced\n" {
+		t.Errorf("Expected only the synced frame on disk, got %q", got)
+	}
+
+	if err := backend.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if got := readLogFile(t, logPath); got != "synced\npending\n" {
+		t.Errorf("Expected close to complete the frame, got %q", got)
+	}
+}
+
+func TestCompressedFileBackend_FrameTriggers(t *testing.T) {
+	t.Run("max frame size", func(t *testing.T) {
+		logPath := filepath.Join(t.TempDir(), "app.log")
+
+		backend, err := backends.NewCompressedFileBackend(logPath, features.CompressionZstd, features.CompressionLevelDefault, 0)
+		if err != nil {
+			t.Fatalf("Failed to create backend: %v", err)
+		}
+		defer backend.Close()
+		backend.SetMaxFrameSize(64)
+
+		line := strings.Repeat("x", 70) + "\n"
+		if _, err := backend.Write([]byte(line)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		if got := readLogFile(t, logPath); got != line {
+			t.Errorf("Expected full frame to be written, got %q", got)
+		}
+	})
+
+	t.Run("flush interval", func(t *testing.T) {
+		logPath := filepath.Join(t.TempDir(), "app.log")
+
+		backend, err := backends.NewCompressedFileBackend(logPath, features.CompressionGzip, features.CompressionLevelDefault, 10*time.Millisecond)
+		if err != nil {
+			t.Fatalf("Failed to create backend: %v", err)
+		}
+		defer backend.Close()
+
+		if _, err := backend.Write([]byte("interval\n")); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) && backend.Size() == 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if got := readLogFile(t, logPath); got != "interval\n" {
+			t.Errorf("Expected interval flush to complete the frame, got %q", got)
+		}
+	})
+}
+
+func TestCompressedFileBackend_Rotate(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "app.log")
+
+	backend, err := backends.NewCompressedFileBackend(logPath, features.CompressionZstd, 3, 0)
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	defer backend.Close()
+
+	if _, err := backend.Write([]byte("before rotation\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	rotatedPath, err := backend.RotateFile()
+	if err != nil {
+		t.Fatalf("RotateFile failed: %v", err)
+	}
+	if !strings.HasSuffix(rotatedPath, features.ZstdSuffix) {
+		t.Errorf("Expected rotated file to keep the %s suffix, got %s", features.ZstdSuffix, rotatedPath)
+	}
+	if got := readLogFile(t, rotatedPath); got != "before rotation\n" {
+		t.Errorf("Unexpected rotated content: %q", got)
+	}
+
+	// Rotated files are recognised as compressed by the rotation manager
+	rotated, err := features.NewRotationManager().GetRotatedFiles(logPath)
+	if err != nil {
+		t.Fatalf("GetRotatedFiles failed: %v", err)
+	}
+	if len(rotated) != 1 || !rotated[0].IsCompressed {
+		t.Errorf("Expected one compressed rotated file, got %+v", rotated)
+	}
+
+	if backend.Size() != 0 {
+		t.Errorf("Expected new active file to be empty, size %d", backend.Size())
+	}
+	if _, err := backend.Write([]byte("after rotation\n")); err != nil {
+		t.Fatalf("Write after rotation failed: %v", err)
+	}
+	if err := backend.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if got := readLogFile(t, logPath); got != "after rotation\n" {
+		t.Errorf("Unexpected active content: %q", got)
+	}
+}
+
+func TestNewCompressedFileBackend_Errors(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "app.log")
+
+	if _, err := backends.NewCompressedFileBackend(logPath, features.CompressionNone, 0, 0); err == nil {
+		t.Error("Expected error without a compression type")
+	}
+	if _, err := backends.NewCompressedFileBackend(logPath, features.CompressionGzip, 15, 0); err == nil {
+		t.Error("Expected error for invalid gzip level")
+	}
+}
+
+func TestCompressedFileBackend_StreamStart(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "app.csv")
+
+	backend, err := backends.NewCompressedFileBackend(logPath, features.CompressionGzip, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	defer backend.Close()
+
+	if err := backend.SetStream(backends.FileStream{End: []byte("]\n")}); err == nil {
+		t.Error("Expected error for a stream with an end")
+	}
+	if err := backend.SetStream(backends.FileStream{Start: []byte("level,message\n")}); err != nil {
+		t.Fatalf("SetStream failed: %v", err)
+	}
+	if _, err := backend.Write([]byte("info,before\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	rotatedPath, err := backend.RotateFile()
+	if err != nil {
+		t.Fatalf("RotateFile failed: %v", err)
+	}
+	if _, err := backend.Write([]byte("info,after\n")); err != nil {
+		t.Fatalf("Write after rotation failed: %v", err)
+	}
+	if err := backend.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	if got := readLogFile(t, rotatedPath); got != "level,message\ninfo,before\n" {
+		t.Errorf("Unexpected rotated content: %q", got)
+	}
+	if got := readLogFile(t, logPath); got != "level,message\ninfo,after\n" {
+		t.Errorf("Expected the header again after rotation, got %q", got)
+	}
+}
+
+func TestCompressedFileBackend_TornFrame(t *testing.T) {
+	for _, compression := range []features.CompressionType{features.CompressionGzip, features.CompressionZstd} {
+		t.Run(features.CompressionTypeString(compression), func(t *testing.T) {
+			logPath := filepath.Join(t.TempDir(), "app.log")
+
+			backend, err := backends.NewCompressedFileBackend(logPath, compression, 0, 0)
+			if err != nil {
+				t.Fatalf("Failed to create backend: %v", err)
+			}
+			for _, entry := range []string{"first\n", "second\n"} {
+				if _, err := backend.Write([]byte(entry)); err != nil {
+					t.Fatalf("Write failed: %v", err)
+				}
+				if err := backend.Sync(); err != nil {
+					t.Fatalf("Sync failed: %v", err)
+				}
+			}
+			if err := backend.Close(); err != nil {
+				t.Fatalf("Close failed: %v", err)
+			}
+
+			// Simulate a crash while the second frame was appended
+			info, err := os.Stat(logPath)
+			if err != nil {
+				t.Fatalf("Stat failed: %v", err)
+			}
+			if err := os.Truncate(logPath, info.Size()-5); err != nil {
+				t.Fatalf("Truncate failed: %v", err)
+			}
+			if got := readLogFile(t, logPath); !strings.HasPrefix(got, "first\n") {
+				t.Errorf("Expected the complete frame to stay readable, got %q", got)
+			}
+
+			backend, err = backends.NewCompressedFileBackend(logPath, compression, 0, 0)
+			if err != nil {
+				t.Fatalf("Failed to reopen backend: %v", err)
+			}
+			defer backend.Close()
+			if _, err := backend.Write([]byte("after restart\n")); err != nil {
+				t.Fatalf("Write after restart failed: %v", err)
+			}
+			if err := backend.Sync(); err != nil {
+				t.Fatalf("Sync failed: %v", err)
+			}
+			if got := readLogFile(t, logPath); got != "first\nafter restart\n" {
+				t.Errorf("Expected the torn frame to be dropped on restart, got %q", got)
+			}
+		})
+	}
+}
@@ -2,11 +2,12 @@ package backends
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 	"sync"
+	"syscall"
 
 	"github.com/gofrs/flock"
 	"github.com/wayneeseguin/omni/pkg/features"
@@ -19,10 +20,19 @@ type FileBackendWithRotation struct {
 	lock            *flock.Flock
 	path            string
 	size            int64
+	stream          *fileStream // Framing of each file, nil when entries are written bare
 	mu              sync.Mutex
 	rotationManager *features.RotationManager
 	maxRetries      int
 	errorHandler    func(source, dest, msg string, err error)
+
+	// Disk budget and free-space monitoring
+	diskConfig      DiskSpaceConfig
+	diskStatus      DiskSpaceStatus
+	lowSpace        bool
+	fallback        *os.File
+	diskMonitorDone chan struct{}
+	diskMonitorWg   sync.WaitGroup
 }
 
 // NewFileBackendWithRotation creates a new file backend with rotation support
@@ -87,6 +97,16 @@ func (fb *FileBackendWithRotation) Write(entry []byte) (int, error) {
 	fb.mu.Lock()
 	defer fb.mu.Unlock()
 
+	// Apply the low-space policy before touching the primary file
+	if fb.lowSpace {
+		switch fb.diskConfig.Policy {
+		case LowSpaceStop:
+			return 0, ErrLowDiskSpace
+		case LowSpaceFallback:
+			return fb.writeFallbackLocked(entry)
+		}
+	}
+
 	retries := 0
 	for retries <= fb.maxRetries {
 		// Try to acquire lock
@@ -94,15 +114,22 @@ func (fb *FileBackendWithRotation) Write(entry []byte) (int, error) {
 			return 0, fmt.Errorf("acquire lock: %w", err)
 		}
 
-		// Write entry
-		n, err := fb.writer.Write(entry)
+		// Write entry, separated from the previous one in a stream
+		var n, total int
+		var err error
+		if fb.stream != nil {
+			n, total, err = fb.stream.write(fb.writer, entry)
+		} else {
+			n, err = fb.writer.Write(entry)
+			total = n
+		}
 		if unlockErr := fb.lock.Unlock(); unlockErr != nil {
 			// Log unlock error but continue with write error handling
-			fb.errorHandler("unlock", fb.path, "Failed to unlock file", unlockErr)
+			fb.reportError("unlock", fb.path, "Failed to unlock file", unlockErr)
 		}
 
 		if err == nil {
-			fb.size += int64(n)
+			fb.size += int64(total)
 			return n, nil
 		}
 
@@ -124,22 +151,20 @@ func (fb *FileBackendWithRotation) Write(entry []byte) (int, error) {
 			continue
 		}
 
+		// Out of retries; keep the entry if a fallback destination is configured
+		if fb.diskConfig.Policy == LowSpaceFallback && fb.diskConfig.FallbackPath != "" {
+			return fb.writeFallbackLocked(entry)
+		}
+
 		return n, err
 	}
 
 	return 0, fmt.Errorf("write failed after %d retries", fb.maxRetries)
 }
 
-// isDiskFullError checks if an error indicates disk is full
+// isDiskFullError checks if an error indicates the disk or the user's quota is full
 func isDiskFullError(err error) bool {
-	if err == nil {
-		return false
-	}
-	errStr := strings.ToLower(err.Error())
-	return strings.Contains(errStr, "no space left") ||
-		strings.Contains(errStr, "enospc") ||
-		strings.Contains(errStr, "disk full") ||
-		strings.Contains(errStr, "out of disk space")
+	return errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EDQUOT)
 }
 
 // handleDiskFull handles disk full condition by rotating logs
@@ -263,16 +288,33 @@ func (fb *FileBackendWithRotation) Flush() error {
 
 // Close closes the file backend
 func (fb *FileBackendWithRotation) Close() error {
+	fb.stopDiskMonitor()
+
 	fb.mu.Lock()
 	defer fb.mu.Unlock()
 
 	var errs []error
 
+	// Release the fallback file
+	if fb.fallback != nil {
+		if err := fb.fallback.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close fallback: %w", err))
+		}
+		fb.fallback = nil
+	}
+
 	// Remove from rotation manager
 	if fb.rotationManager != nil {
 		fb.rotationManager.RemoveLogPath(fb.path)
 	}
 
+	// Finish the stream framing the file
+	if fb.stream != nil && fb.writer != nil {
+		if _, err := fb.stream.finish(fb.writer); err != nil {
+			errs = append(errs, fmt.Errorf("finish stream: %w", err))
+		}
+	}
+
 	// Flush writer
 	if fb.writer != nil {
 		if err := fb.writer.Flush(); err != nil {
@@ -300,6 +342,40 @@ func (fb *FileBackendWithRotation) Close() error {
 	return nil
 }
 
+// SetStream frames the file, and every file opened after rotation, with stream
+func (fb *FileBackendWithRotation) SetStream(stream FileStream) error {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+
+	if err := fb.writer.Flush(); err != nil {
+		return fmt.Errorf("flush: %w", err)
+	}
+	s := &fileStream{FileStream: stream}
+	n, err := s.open(fb.path, fb.file, fb.writer, fb.size)
+	if err != nil {
+		return err
+	}
+	fb.size += n
+	fb.stream = s
+	return nil
+}
+
+// FinishStream writes the end of the file's stream and flushes it
+func (fb *FileBackendWithRotation) FinishStream() error {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+
+	if fb.stream == nil {
+		return nil
+	}
+	n, err := fb.stream.finish(fb.writer)
+	fb.size += int64(n)
+	if err != nil {
+		return err
+	}
+	return fb.writer.Flush()
+}
+
 // SupportsAtomic returns true as file backend supports atomic writes via locking
 func (fb *FileBackendWithRotation) SupportsAtomic() bool {
 	return true
@@ -310,41 +386,63 @@ func (fb *FileBackendWithRotation) Rotate() error {
 	fb.mu.Lock()
 	defer fb.mu.Unlock()
 
+	_, err := fb.rotateLocked()
+	return err
+}
+
+// rotateLocked rotates the log file and returns the rotated path. Caller must hold fb.mu.
+func (fb *FileBackendWithRotation) rotateLocked() (string, error) {
 	if fb.rotationManager == nil {
-		return fmt.Errorf("no rotation manager configured")
+		return "", fmt.Errorf("no rotation manager configured")
 	}
 
-	// Flush before rotation
+	// Finish the stream and flush before rotation
+	if fb.stream != nil {
+		if _, err := fb.stream.finish(fb.writer); err != nil {
+			return "", fmt.Errorf("finish stream before rotation: %w", err)
+		}
+	}
 	if err := fb.writer.Flush(); err != nil {
-		return fmt.Errorf("flush before rotation: %w", err)
+		return "", fmt.Errorf("flush before rotation: %w", err)
 	}
 
 	// Close current file
 	if err := fb.file.Close(); err != nil {
-		return fmt.Errorf("close before rotation: %w", err)
+		return "", fmt.Errorf("close before rotation: %w", err)
 	}
 
 	// Rotate
-	_, err := fb.rotationManager.RotateFile(fb.path, nil)
+	rotatedPath, err := fb.rotationManager.RotateFile(fb.path, nil)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	// Reopen
 	file, err := os.OpenFile(fb.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
 	if err != nil {
-		return fmt.Errorf("reopen after rotation: %w", err)
+		return "", fmt.Errorf("reopen after rotation: %w", err)
 	}
 
 	fb.file = file
 	fb.writer = bufio.NewWriterSize(file, DefaultBufferSize)
 	fb.size = 0
 
-	return nil
+	// Start the stream of the new file
+	if fb.stream != nil {
+		n, err := fb.stream.open(fb.path, fb.file, fb.writer, 0)
+		if err != nil {
+			return rotatedPath, fmt.Errorf("start stream after rotation: %w", err)
+		}
+		fb.size = n
+	}
+
+	return rotatedPath, nil
 }
 
 // Size returns the current file size
 func (fb *FileBackendWithRotation) Size() int64 {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
 	return fb.size
 }
 
@@ -370,7 +468,7 @@ func (fb *FileBackendWithRotation) GetLock() *flock.Flock {
 
 // GetSize returns the current file size
 func (fb *FileBackendWithRotation) GetSize() int64 {
-	return fb.size
+	return fb.Size()
 }
 
 // Sync syncs the file to disk
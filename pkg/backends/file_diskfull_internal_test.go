@@ -0,0 +1,33 @@
+package backends
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestIsDiskFullError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"nil error", nil, false},
+		{"ENOSPC", &os.PathError{Op: "write", Path: "/tmp/test.log", Err: syscall.ENOSPC}, true},
+		{"EDQUOT", &os.PathError{Op: "write", Path: "/tmp/test.log", Err: syscall.EDQUOT}, true},
+		{"wrapped ENOSPC", fmt.Errorf("flush: %w", syscall.ENOSPC), true},
+		{"permission denied", &os.PathError{Op: "write", Path: "/tmp/test.log", Err: syscall.EACCES}, false},
+		// Messages alone are not trusted; only the errno decides
+		{"message only", errors.New("no space left on device"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isDiskFullError(tt.err); got != tt.expected {
+				t.Errorf("isDiskFullError(%v) = %v, expected %v", tt.err, got, tt.expected)
+			}
+		})
+	}
+}
@@ -1,7 +1,6 @@
 package backends_test
 
 import (
-	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -223,66 +222,6 @@ func TestFileBackendWithRotation_Write(t *testing.T) {
 	}
 }
 
-// TestFileBackendWithRotation_IsDiskFullError tests disk full error detection
-func TestFileBackendWithRotation_IsDiskFullError(t *testing.T) {
-	// Since isDiskFullError is not exported, we need to test it indirectly
-	// by creating scenarios where Write would encounter disk full errors
-
-	// This test focuses on the error detection logic patterns
-	tests := []struct {
-		name     string
-		errMsg   string
-		expected bool
-	}{
-		{
-			name:     "no space left error",
-			errMsg:   "write /tmp/test.log: no space left on device",
-			expected: true,
-		},
-		{
-			name:     "enospc error",
-			errMsg:   "write error: ENOSPC",
-			expected: true,
-		},
-		{
-			name:     "disk full error",
-			errMsg:   "disk full",
-			expected: true,
-		},
-		{
-			name:     "out of disk space",
-			errMsg:   "out of disk space",
-			expected: true,
-		},
-		{
-			name:     "different error",
-			errMsg:   "permission denied",
-			expected: false,
-		},
-		{
-			name:     "empty error",
-			errMsg:   "",
-			expected: false,
-		},
-	}
-
-	// We test this by examining error patterns that would be caught
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			// Test the error pattern matching logic that would be used
-			errStr := strings.ToLower(tt.errMsg)
-			isDiskFull := strings.Contains(errStr, "no space left") ||
-				strings.Contains(errStr, "enospc") ||
-				strings.Contains(errStr, "disk full") ||
-				strings.Contains(errStr, "out of disk space")
-
-			if isDiskFull != tt.expected {
-				t.Errorf("Expected disk full detection %v for %q, got %v", tt.expected, tt.errMsg, isDiskFull)
-			}
-		})
-	}
-}
-
 // TestFileBackendWithRotation_FlushAndSync tests flushing and syncing
 func TestFileBackendWithRotation_FlushAndSync(t *testing.T) {
 	tempDir := t.TempDir()
@@ -740,85 +679,6 @@ func TestFileBackendWithRotation_WriteRetryLogic(t *testing.T) {
 
 // Helper functions for testing disk full scenarios
 
-// TestFileBackendWithRotation_DiskFullErrorDetection tests isDiskFullError function
-func TestFileBackendWithRotation_DiskFullErrorDetection(t *testing.T) {
-	tests := []struct {
-		name     string
-		err      error
-		expected bool
-	}{
-		{
-			name:     "nil error",
-			err:      nil,
-			expected: false,
-		},
-		{
-			name:     "no space left error",
-			err:      errors.New("no space left on device"),
-			expected: true,
-		},
-		{
-			name:     "ENOSPC error",
-			err:      errors.New("write: ENOSPC"),
-			expected: true,
-		},
-		{
-			name:     "disk full error",
-			err:      errors.New("disk full condition detected"),
-			expected: true,
-		},
-		{
-			name:     "out of disk space error",
-			err:      errors.New("operation failed: out of disk space"),
-			expected: true,
-		},
-		{
-			name:     "case insensitive - uppercase",
-			err:      errors.New("NO SPACE LEFT ON DEVICE"),
-			expected: true,
-		},
-		{
-			name:     "case insensitive - mixed case",
-			err:      errors.New("Write failed: Disk Full"),
-			expected: true,
-		},
-		{
-			name:     "permission denied error",
-			err:      errors.New("permission denied"),
-			expected: false,
-		},
-		{
-			name:     "network timeout error",
-			err:      errors.New("network timeout"),
-			expected: false,
-		},
-		{
-			name:     "file not found error",
-			err:      errors.New("file not found"),
-			expected: false,
-		},
-	}
-
-	// We test the error detection logic patterns used in the implementation
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			var isDiskFull bool
-			if tt.err != nil {
-				errStr := strings.ToLower(tt.err.Error())
-				isDiskFull = strings.Contains(errStr, "no space left") ||
-					strings.Contains(errStr, "enospc") ||
-					strings.Contains(errStr, "disk full") ||
-					strings.Contains(errStr, "out of disk space")
-			}
-
-			if isDiskFull != tt.expected {
-				t.Errorf("Expected disk full detection %v for error %q, got %v",
-					tt.expected, tt.err, isDiskFull)
-			}
-		})
-	}
-}
-
 // TestFileBackendWithRotation_HandleDiskFullScenarios tests various disk full handling scenarios
 func TestFileBackendWithRotation_HandleDiskFullScenarios(t *testing.T) {
 	tests := []struct {
@@ -1117,3 +977,42 @@ func TestFileBackendWithRotation_StressTestDiskFull(t *testing.T) {
 		t.Error("Expected at least some successful writes during stress test")
 	}
 }
+
+func TestFileBackendWithRotation_Stream(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stream.csv")
+
+	backend, err := backends.NewFileBackendWithRotation(path, features.NewRotationManager())
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	if err := backend.SetStream(backends.FileStream{Start: []byte("a,b\n")}); err != nil {
+		t.Fatalf("SetStream failed: %v", err)
+	}
+	if _, err := backend.Write([]byte("1,2\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := backend.Rotate(); err != nil {
+		t.Fatalf("Rotation failed: %v", err)
+	}
+	if _, err := backend.Write([]byte("3,4\n")); err != nil {
+		t.Fatalf("Write after rotation failed: %v", err)
+	}
+	if err := backend.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	files, _ := filepath.Glob(path + "*")
+	if len(files) != 2 {
+		t.Fatalf("Expected the active and rotated files, got %v", files)
+	}
+	for _, file := range files {
+		data, _ := os.ReadFile(file)
+		want := "a,b\n1,2\n"
+		if file == path {
+			want = "a,b\n3,4\n"
+		}
+		if string(data) != want {
+			t.Errorf("%s holds %q, want %q", file, data, want)
+		}
+	}
+}
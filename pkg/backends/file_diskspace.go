@@ -0,0 +1,432 @@
+package backends
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/features"
+)
+
+// LowSpacePolicy controls how a file backend behaves while free space stays
+// below the configured threshold after rotation and cleanup have run.
+type LowSpacePolicy int
+
+const (
+	// LowSpaceCleanup only rotates and removes old files; writes continue
+	LowSpaceCleanup LowSpacePolicy = iota
+	// LowSpaceShedDebug drops TRACE and DEBUG entries while space is low
+	LowSpaceShedDebug
+	// LowSpaceFallback redirects writes to the fallback path while space is low
+	LowSpaceFallback
+	// LowSpaceStop rejects all writes while space is low
+	LowSpaceStop
+)
+
+// shedLevelThreshold is the lowest level still written under LowSpaceShedDebug (INFO)
+const shedLevelThreshold = 2
+
+var (
+	// ErrLowDiskSpace is returned by Write when the low-space policy rejects an entry
+	ErrLowDiskSpace = errors.New("low disk space")
+	// ErrDiskUsageUnsupported is returned by GetDiskUsage on platforms without statfs
+	ErrDiskUsageUnsupported = errors.New("disk usage not supported on this platform")
+)
+
+// DiskSpaceConfig configures disk budgets and free-space monitoring for a file backend
+type DiskSpaceConfig struct {
+	MaxTotalBytes  int64          // Budget for the active file plus its rotated files (0 = unlimited)
+	MaxDirBytes    int64          // Budget for all files in the log directory (0 = unlimited)
+	MinFreeBytes   uint64         // Rotate and clean up when available space drops below this
+	MinFreePercent float64        // Rotate and clean up when available space drops below this percentage
+	CheckInterval  time.Duration  // How often to check in the background (0 = only on demand)
+	Policy         LowSpacePolicy // What to do when cleanup cannot free enough space
+	FallbackPath   string         // Destination used by LowSpaceFallback
+}
+
+// DiskUsage describes the filesystem holding a log file
+type DiskUsage struct {
+	Total     uint64 // Total capacity in bytes
+	Free      uint64 // Free bytes including those reserved for root
+	Available uint64 // Bytes available to unprivileged users
+}
+
+// DiskSpaceStatus reports the result of the most recent disk space check
+type DiskSpaceStatus struct {
+	LowSpace         bool
+	Usage            DiskUsage
+	DestinationBytes int64 // Active plus rotated files
+	LastCheck        time.Time
+}
+
+// isLow reports whether the usage falls below the configured free-space thresholds
+func (c DiskSpaceConfig) isLow(usage DiskUsage) bool {
+	if c.MinFreeBytes > 0 && usage.Available < c.MinFreeBytes {
+		return true
+	}
+	if c.MinFreePercent > 0 && usage.Total > 0 {
+		return float64(usage.Available)/float64(usage.Total)*100 < c.MinFreePercent
+	}
+	return false
+}
+
+// SetDiskSpaceConfig configures disk budgets and free-space monitoring.
+// A positive CheckInterval starts a background goroutine that runs CheckDiskSpace.
+func (fb *FileBackendWithRotation) SetDiskSpaceConfig(config DiskSpaceConfig) {
+	fb.stopDiskMonitor()
+
+	fb.mu.Lock()
+	fb.diskConfig = config
+	if !fb.lowSpace || config.Policy != LowSpaceFallback {
+		fb.closeFallbackLocked()
+	}
+	fb.mu.Unlock()
+
+	if config.CheckInterval > 0 {
+		fb.startDiskMonitor(config.CheckInterval)
+	}
+}
+
+// GetDiskSpaceConfig returns the current disk space configuration
+func (fb *FileBackendWithRotation) GetDiskSpaceConfig() DiskSpaceConfig {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+	return fb.diskConfig
+}
+
+// DiskStatus returns the result of the most recent disk space check
+func (fb *FileBackendWithRotation) DiskStatus() DiskSpaceStatus {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+	return fb.diskStatus
+}
+
+// IsLowSpace returns whether the backend is currently applying its low-space policy
+func (fb *FileBackendWithRotation) IsLowSpace() bool {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+	return fb.lowSpace
+}
+
+// AcceptsLevel reports whether entries at the given level should be written.
+// Under LowSpaceShedDebug, TRACE and DEBUG entries are refused while space is low.
+func (fb *FileBackendWithRotation) AcceptsLevel(level int) bool {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+	return !(fb.lowSpace && fb.diskConfig.Policy == LowSpaceShedDebug && level < shedLevelThreshold)
+}
+
+// CheckDiskSpace enforces the configured budgets and free-space thresholds once
+func (fb *FileBackendWithRotation) CheckDiskSpace() error {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+	return fb.checkDiskSpaceLocked()
+}
+
+// checkDiskSpaceLocked performs a disk space check. Caller must hold fb.mu.
+func (fb *FileBackendWithRotation) checkDiskSpaceLocked() error {
+	config := fb.diskConfig
+	var errs []error
+
+	if config.MaxTotalBytes > 0 {
+		if err := fb.enforceDestinationBudget(config.MaxTotalBytes); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if config.MaxDirBytes > 0 {
+		if err := fb.enforceDirectoryBudget(config.MaxDirBytes); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	low := false
+	if config.MinFreeBytes > 0 || config.MinFreePercent > 0 {
+		usage, err := GetDiskUsage(filepath.Dir(fb.path))
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			if config.isLow(usage) {
+				usage = fb.reclaimSpace(config, usage)
+			}
+			fb.diskStatus.Usage = usage
+			low = config.isLow(usage)
+		}
+	}
+
+	fb.setLowSpaceLocked(low)
+	fb.diskStatus.DestinationBytes = fb.destinationBytes()
+	fb.diskStatus.LastCheck = time.Now()
+
+	if len(errs) > 0 {
+		return fmt.Errorf("disk space check: %v", errs)
+	}
+	return nil
+}
+
+// enforceDestinationBudget removes the oldest rotated files until the active
+// and rotated files together fit within maxBytes.
+func (fb *FileBackendWithRotation) enforceDestinationBudget(maxBytes int64) error {
+	total := fb.destinationBytes()
+	if total <= maxBytes {
+		return nil
+	}
+
+	// An oversized active file can only be reclaimed once it has been rotated
+	if fb.size > maxBytes && fb.rotationManager != nil {
+		if _, err := fb.rotateLocked(); err != nil {
+			return fmt.Errorf("rotate over-budget file: %w", err)
+		}
+	}
+
+	rotated := fb.rotatedFiles()
+	total = fb.destinationBytes()
+	for i := len(rotated) - 1; i >= 0 && total > maxBytes; i-- {
+		if fb.removeRotatedFile(rotated[i].Path, "Removed rotated log to stay within destination budget") {
+			total -= rotated[i].Size
+		}
+	}
+
+	if total > maxBytes {
+		return fmt.Errorf("destination uses %d bytes, budget is %d", total, maxBytes)
+	}
+	return nil
+}
+
+// enforceDirectoryBudget removes this destination's oldest rotated files until
+// every file in the log directory together fits within maxBytes.
+func (fb *FileBackendWithRotation) enforceDirectoryBudget(maxBytes int64) error {
+	total, err := directoryBytes(filepath.Dir(fb.path))
+	if err != nil {
+		return err
+	}
+	if total <= maxBytes {
+		return nil
+	}
+
+	rotated := fb.rotatedFiles()
+	for i := len(rotated) - 1; i >= 0 && total > maxBytes; i-- {
+		if fb.removeRotatedFile(rotated[i].Path, "Removed rotated log to stay within directory budget") {
+			total -= rotated[i].Size
+		}
+	}
+
+	if total > maxBytes {
+		return fmt.Errorf("directory uses %d bytes, budget is %d", total, maxBytes)
+	}
+	return nil
+}
+
+// reclaimSpace rotates the active file and removes the oldest rotated files
+// until free space is back above the configured threshold.
+func (fb *FileBackendWithRotation) reclaimSpace(config DiskSpaceConfig, usage DiskUsage) DiskUsage {
+	if fb.size > 0 && fb.rotationManager != nil {
+		if _, err := fb.rotateLocked(); err != nil {
+			fb.reportError("diskspace", fb.path, "Failed to rotate on low disk space", err)
+		}
+		if err := fb.rotationManager.RunCleanup(fb.path); err != nil {
+			fb.reportError("cleanup", fb.path, "Cleanup failed on low disk space", err)
+		}
+	}
+
+	dir := filepath.Dir(fb.path)
+	rotated := fb.rotatedFiles()
+	for i := len(rotated) - 1; i >= 0 && config.isLow(usage); i-- {
+		if !fb.removeRotatedFile(rotated[i].Path, "Removed rotated log to free disk space") {
+			continue
+		}
+		if refreshed, err := GetDiskUsage(dir); err == nil {
+			usage = refreshed
+		}
+	}
+
+	return usage
+}
+
+// setLowSpaceLocked records a low-space state change and reports transitions
+func (fb *FileBackendWithRotation) setLowSpaceLocked(low bool) {
+	if low == fb.lowSpace {
+		return
+	}
+	fb.lowSpace = low
+	fb.diskStatus.LowSpace = low
+
+	if low {
+		fb.reportError("diskspace", fb.path, fmt.Sprintf("Low disk space, applying policy %s", fb.diskConfig.Policy), nil)
+		return
+	}
+
+	fb.closeFallbackLocked()
+	fb.reportError("diskspace", fb.path, "Disk space recovered, resuming normal writes", nil)
+}
+
+// writeFallbackLocked writes an entry to the fallback path. Caller must hold fb.mu.
+func (fb *FileBackendWithRotation) writeFallbackLocked(entry []byte) (int, error) {
+	if fb.diskConfig.FallbackPath == "" {
+		return 0, fmt.Errorf("%w: no fallback path configured", ErrLowDiskSpace)
+	}
+
+	if fb.fallback == nil {
+		fallbackPath := filepath.Clean(fb.diskConfig.FallbackPath)
+		if err := os.MkdirAll(filepath.Dir(fallbackPath), 0750); err != nil {
+			return 0, fmt.Errorf("create fallback directory: %w", err)
+		}
+		file, err := os.OpenFile(fallbackPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return 0, fmt.Errorf("open fallback file: %w", err)
+		}
+		fb.fallback = file
+	}
+
+	return fb.fallback.Write(entry)
+}
+
+// closeFallbackLocked closes the fallback file if open. Caller must hold fb.mu.
+func (fb *FileBackendWithRotation) closeFallbackLocked() {
+	if fb.fallback == nil {
+		return
+	}
+	if err := fb.fallback.Close(); err != nil {
+		fb.reportError("diskspace", fb.diskConfig.FallbackPath, "Failed to close fallback file", err)
+	}
+	fb.fallback = nil
+}
+
+// startDiskMonitor starts the periodic disk space check goroutine
+func (fb *FileBackendWithRotation) startDiskMonitor(interval time.Duration) {
+	done := make(chan struct{})
+
+	fb.mu.Lock()
+	fb.diskMonitorDone = done
+	fb.mu.Unlock()
+
+	fb.diskMonitorWg.Add(1)
+	go func() {
+		defer fb.diskMonitorWg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := fb.CheckDiskSpace(); err != nil {
+					fb.mu.Lock()
+					fb.reportError("diskspace", fb.path, "Disk space check failed", err)
+					fb.mu.Unlock()
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+}
+
+// stopDiskMonitor stops the periodic disk space check goroutine
+func (fb *FileBackendWithRotation) stopDiskMonitor() {
+	fb.mu.Lock()
+	done := fb.diskMonitorDone
+	fb.diskMonitorDone = nil
+	fb.mu.Unlock()
+
+	if done != nil {
+		close(done)
+		fb.diskMonitorWg.Wait()
+	}
+}
+
+// destinationBytes returns the size of the active file plus its rotated files
+func (fb *FileBackendWithRotation) destinationBytes() int64 {
+	total := fb.size
+	for _, file := range fb.rotatedFiles() {
+		total += file.Size
+	}
+	return total
+}
+
+// rotatedFiles returns this destination's rotated files, newest first
+func (fb *FileBackendWithRotation) rotatedFiles() []features.RotatedFileInfo {
+	lister := fb.rotationManager
+	if lister == nil {
+		lister = features.NewRotationManager()
+	}
+
+	files, err := lister.GetRotatedFiles(fb.path)
+	if err != nil {
+		fb.reportError("diskspace", fb.path, "Failed to list rotated files", err)
+		return nil
+	}
+	return files
+}
+
+// removeRotatedFile removes a rotated file and reports the outcome
+func (fb *FileBackendWithRotation) removeRotatedFile(path, reason string) bool {
+	if err := os.Remove(path); err != nil {
+		fb.reportError("cleanup", path, "Failed to remove old log", err)
+		return false
+	}
+	fb.reportError("cleanup", path, reason, nil)
+	return true
+}
+
+// reportError calls the error handler if one is configured
+func (fb *FileBackendWithRotation) reportError(source, dest, msg string, err error) {
+	if fb.errorHandler != nil {
+		fb.errorHandler(source, dest, msg, err)
+	}
+}
+
+// directoryBytes returns the total size of the regular files in dir
+func directoryBytes(dir string) (int64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, fmt.Errorf("reading log directory: %w", err)
+	}
+
+	var total int64
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		total += info.Size()
+	}
+	return total, nil
+}
+
+// String returns the policy name
+func (p LowSpacePolicy) String() string {
+	switch p {
+	case LowSpaceCleanup:
+		return "cleanup"
+	case LowSpaceShedDebug:
+		return "shed-debug"
+	case LowSpaceFallback:
+		return "fallback"
+	case LowSpaceStop:
+		return "stop"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseLowSpacePolicy parses a policy name into a LowSpacePolicy
+func ParseLowSpacePolicy(s string) (LowSpacePolicy, error) {
+	switch s {
+	case "cleanup", "":
+		return LowSpaceCleanup, nil
+	case "shed-debug":
+		return LowSpaceShedDebug, nil
+	case "fallback":
+		return LowSpaceFallback, nil
+	case "stop":
+		return LowSpaceStop, nil
+	default:
+		return LowSpaceCleanup, fmt.Errorf("unsupported low space policy: %s", s)
+	}
+}
@@ -0,0 +1,301 @@
+package backends_test
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/backends"
+	"github.com/wayneeseguin/omni/pkg/features"
+)
+
+// writeRotatedFiles creates fake rotated files for path, oldest first
+func writeRotatedFiles(t *testing.T, path string, count int, size int) []string {
+	t.Helper()
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	paths := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		rotated := path + "." + base.Add(time.Duration(i)*time.Minute).Format(features.RotationTimeFormat)
+		if err := os.WriteFile(rotated, []byte(strings.Repeat("x", size)), 0600); err != nil {
+			t.Fatalf("Failed to create rotated file: %v", err)
+		}
+		paths = append(paths, rotated)
+	}
+	return paths
+}
+
+func TestFileBackendWithRotation_DestinationBudget(t *testing.T) {
+	tempDir := t.TempDir()
+	logPath := filepath.Join(tempDir, "app.log")
+
+	backend, err := backends.NewFileBackendWithRotation(logPath, features.NewRotationManager())
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	defer backend.Close()
+
+	rotated := writeRotatedFiles(t, logPath, 4, 100)
+
+	backend.SetDiskSpaceConfig(backends.DiskSpaceConfig{MaxTotalBytes: 250})
+	if err := backend.CheckDiskSpace(); err != nil {
+		t.Fatalf("CheckDiskSpace failed: %v", err)
+	}
+
+	// The two oldest files must be removed, the two newest kept
+	for i, path := range rotated {
+		_, statErr := os.Stat(path)
+		if i < 2 && !os.IsNotExist(statErr) {
+			t.Errorf("Expected %s to be removed", filepath.Base(path))
+		}
+		if i >= 2 && statErr != nil {
+			t.Errorf("Expected %s to be kept: %v", filepath.Base(path), statErr)
+		}
+	}
+
+	if status := backend.DiskStatus(); status.DestinationBytes > 250 {
+		t.Errorf("Expected destination bytes <= 250, got %d", status.DestinationBytes)
+	}
+}
+
+func TestFileBackendWithRotation_DestinationBudgetRotatesActiveFile(t *testing.T) {
+	tempDir := t.TempDir()
+	logPath := filepath.Join(tempDir, "app.log")
+
+	backend, err := backends.NewFileBackendWithRotation(logPath, features.NewRotationManager())
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	defer backend.Close()
+
+	if _, err := backend.Write([]byte(strings.Repeat("a", 200) + "\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	backend.SetDiskSpaceConfig(backends.DiskSpaceConfig{MaxTotalBytes: 100})
+	if err := backend.CheckDiskSpace(); err != nil {
+		t.Fatalf("CheckDiskSpace failed: %v", err)
+	}
+
+	if backend.GetSize() != 0 {
+		t.Errorf("Expected active file to be rotated, size is %d", backend.GetSize())
+	}
+	if status := backend.DiskStatus(); status.DestinationBytes != 0 {
+		t.Errorf("Expected destination bytes 0, got %d", status.DestinationBytes)
+	}
+}
+
+func TestFileBackendWithRotation_DirectoryBudget(t *testing.T) {
+	tempDir := t.TempDir()
+	logPath := filepath.Join(tempDir, "app.log")
+
+	backend, err := backends.NewFileBackendWithRotation(logPath, features.NewRotationManager())
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	defer backend.Close()
+
+	// Files belonging to other applications count towards the budget but are never removed
+	otherPath := filepath.Join(tempDir, "other.log")
+	if err := os.WriteFile(otherPath, []byte(strings.Repeat("o", 200)), 0600); err != nil {
+		t.Fatalf("Failed to create other file: %v", err)
+	}
+	rotated := writeRotatedFiles(t, logPath, 3, 100)
+
+	backend.SetDiskSpaceConfig(backends.DiskSpaceConfig{MaxDirBytes: 350})
+	if err := backend.CheckDiskSpace(); err != nil {
+		t.Fatalf("CheckDiskSpace failed: %v", err)
+	}
+
+	if _, err := os.Stat(otherPath); err != nil {
+		t.Errorf("Expected unrelated file to be kept: %v", err)
+	}
+	for i, path := range rotated {
+		_, statErr := os.Stat(path)
+		if i < 2 && !os.IsNotExist(statErr) {
+			t.Errorf("Expected %s to be removed", filepath.Base(path))
+		}
+		if i == 2 && statErr != nil {
+			t.Errorf("Expected %s to be kept: %v", filepath.Base(path), statErr)
+		}
+	}
+
+	// A budget that cannot be met is reported
+	backend.SetDiskSpaceConfig(backends.DiskSpaceConfig{MaxDirBytes: 50})
+	if err := backend.CheckDiskSpace(); err == nil {
+		t.Error("Expected error when directory budget cannot be met")
+	}
+}
+
+func TestFileBackendWithRotation_LowSpacePolicies(t *testing.T) {
+	if _, err := backends.GetDiskUsage(os.TempDir()); err != nil {
+		t.Skipf("Disk usage not available: %v", err)
+	}
+
+	// An impossible threshold forces the low-space state
+	lowSpace := backends.DiskSpaceConfig{MinFreeBytes: math.MaxUint64}
+
+	t.Run("stop", func(t *testing.T) {
+		backend, err := backends.NewFileBackendWithRotation(filepath.Join(t.TempDir(), "app.log"), nil)
+		if err != nil {
+			t.Fatalf("Failed to create backend: %v", err)
+		}
+		defer backend.Close()
+
+		config := lowSpace
+		config.Policy = backends.LowSpaceStop
+		backend.SetDiskSpaceConfig(config)
+		_ = backend.CheckDiskSpace()
+
+		if !backend.IsLowSpace() {
+			t.Fatal("Expected backend to report low space")
+		}
+		if _, err := backend.Write([]byte("dropped\n")); !errors.Is(err, backends.ErrLowDiskSpace) {
+			t.Errorf("Expected ErrLowDiskSpace, got %v", err)
+		}
+	})
+
+	t.Run("fallback", func(t *testing.T) {
+		tempDir := t.TempDir()
+		logPath := filepath.Join(tempDir, "app.log")
+		fallbackPath := filepath.Join(tempDir, "fallback", "app.log")
+
+		backend, err := backends.NewFileBackendWithRotation(logPath, nil)
+		if err != nil {
+			t.Fatalf("Failed to create backend: %v", err)
+		}
+
+		config := lowSpace
+		config.Policy = backends.LowSpaceFallback
+		config.FallbackPath = fallbackPath
+		backend.SetDiskSpaceConfig(config)
+		_ = backend.CheckDiskSpace()
+
+		if _, err := backend.Write([]byte("to fallback\n")); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		if err := backend.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+
+		content, err := os.ReadFile(fallbackPath)
+		if err != nil {
+			t.Fatalf("Failed to read fallback file: %v", err)
+		}
+		if string(content) != "to fallback\n" {
+			t.Errorf("Unexpected fallback content: %q", content)
+		}
+
+		primary, err := os.ReadFile(logPath)
+		if err != nil {
+			t.Fatalf("Failed to read primary file: %v", err)
+		}
+		if len(primary) != 0 {
+			t.Errorf("Expected primary file to be empty, got %q", primary)
+		}
+	})
+
+	t.Run("shed-debug", func(t *testing.T) {
+		backend, err := backends.NewFileBackendWithRotation(filepath.Join(t.TempDir(), "app.log"), nil)
+		if err != nil {
+			t.Fatalf("Failed to create backend: %v", err)
+		}
+		defer backend.Close()
+
+		if !backend.AcceptsLevel(0) {
+			t.Error("Expected all levels to be accepted before low space")
+		}
+
+		config := lowSpace
+		config.Policy = backends.LowSpaceShedDebug
+		backend.SetDiskSpaceConfig(config)
+		_ = backend.CheckDiskSpace()
+
+		if backend.AcceptsLevel(1) {
+			t.Error("Expected debug level to be shed")
+		}
+		if !backend.AcceptsLevel(2) {
+			t.Error("Expected info level to be accepted")
+		}
+
+		// Recovering space resumes normal writes
+		backend.SetDiskSpaceConfig(backends.DiskSpaceConfig{Policy: backends.LowSpaceShedDebug})
+		_ = backend.CheckDiskSpace()
+		if backend.IsLowSpace() || !backend.AcceptsLevel(1) {
+			t.Error("Expected debug level to be accepted after recovery")
+		}
+	})
+}
+
+func TestFileBackendWithRotation_DiskMonitor(t *testing.T) {
+	tempDir := t.TempDir()
+	logPath := filepath.Join(tempDir, "app.log")
+
+	backend, err := backends.NewFileBackendWithRotation(logPath, features.NewRotationManager())
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+
+	rotated := writeRotatedFiles(t, logPath, 2, 100)
+	backend.SetDiskSpaceConfig(backends.DiskSpaceConfig{
+		MaxTotalBytes: 150,
+		CheckInterval: 10 * time.Millisecond,
+	})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(rotated[0]); os.IsNotExist(err) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := os.Stat(rotated[0]); !os.IsNotExist(err) {
+		t.Error("Expected monitor to remove the oldest rotated file")
+	}
+
+	// Close must stop the monitor without blocking
+	closed := make(chan error, 1)
+	go func() { closed <- backend.Close() }()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Errorf("Close failed: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close blocked on disk monitor")
+	}
+}
+
+func TestParseLowSpacePolicy(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected backends.LowSpacePolicy
+		wantErr  bool
+	}{
+		{"", backends.LowSpaceCleanup, false},
+		{"cleanup", backends.LowSpaceCleanup, false},
+		{"shed-debug", backends.LowSpaceShedDebug, false},
+		{"fallback", backends.LowSpaceFallback, false},
+		{"stop", backends.LowSpaceStop, false},
+		{"panic", backends.LowSpaceCleanup, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			policy, err := backends.ParseLowSpacePolicy(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLowSpacePolicy(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if err == nil && policy != tt.expected {
+				t.Errorf("ParseLowSpacePolicy(%q) = %v, want %v", tt.input, policy, tt.expected)
+			}
+			if err == nil && tt.input != "" && policy.String() != tt.input {
+				t.Errorf("String() = %q, want %q", policy.String(), tt.input)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package backends
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// FileStream frames the entries of every file a file backend writes, such as
+// the brackets of a JSON array or the header row of a CSV file
+type FileStream struct {
+	Start     []byte // Written at the top of each new file, including after rotation
+	Separator []byte // Written between two entries of a file
+	End       []byte // Written when the file is rotated or closed
+}
+
+// IsZero reports whether the stream writes nothing
+func (s FileStream) IsZero() bool {
+	return len(s.Start) == 0 && len(s.Separator) == 0 && len(s.End) == 0
+}
+
+// StreamingBackend is implemented by file backends that frame their files
+// with a FileStream
+type StreamingBackend interface {
+	// SetStream frames the open file and the files opened after rotation.
+	// A file that already holds a finished stream is reopened by removing
+	// its end, so entries appended on restart stay inside the stream.
+	SetStream(stream FileStream) error
+
+	// FinishStream writes the end of the open file's stream before the file
+	// is rotated by someone else. Close writes no second end.
+	FinishStream() error
+}
+
+// fileStream tracks the framing of a backend's open file
+type fileStream struct {
+	FileStream
+	separate bool // The open file holds an entry, so the next is separated from it
+	finished bool // The end of the open file has been written
+}
+
+// open starts the stream of the file at path, opened as file with size
+// bytes, returning how much the file grew or shrank. Empty files get the
+// stream start and files ending with the stream end lose it.
+func (s *fileStream) open(path string, file *os.File, w io.Writer, size int64) (int64, error) {
+	s.finished = false
+	if size == 0 {
+		s.separate = false
+		n, err := w.Write(s.Start)
+		return int64(n), err
+	}
+
+	s.separate = true
+	if len(s.End) == 0 || size < int64(len(s.End)) {
+		return 0, nil
+	}
+
+	tail, err := readTail(path, size, len(s.Start)+len(s.End))
+	if err != nil {
+		return 0, err
+	}
+	if !bytes.HasSuffix(tail, s.End) {
+		// The stream was never finished, such as after a crash
+		return 0, nil
+	}
+
+	end := size - int64(len(s.End))
+	if err := file.Truncate(end); err != nil {
+		return 0, fmt.Errorf("reopen stream: %w", err)
+	}
+	// A stream holding only its start has no entry to separate from
+	s.separate = end != int64(len(s.Start)) || !bytes.HasPrefix(tail, s.Start)
+	return end - size, nil
+}
+
+// write writes an entry, preceded by the separator unless it is the first
+// entry of the file. It returns the bytes of entry written and the total.
+func (s *fileStream) write(w io.Writer, entry []byte) (int, int, error) {
+	sep := 0
+	if s.separate && len(s.Separator) > 0 {
+		n, err := w.Write(s.Separator)
+		if err != nil {
+			return 0, n, err
+		}
+		sep = n
+	}
+	n, err := w.Write(entry)
+	if err == nil {
+		s.separate = true
+	}
+	return n, sep + n, err
+}
+
+// finish writes the end of the stream once, returning the bytes written
+func (s *fileStream) finish(w io.Writer) (int, error) {
+	if s.finished {
+		return 0, nil
+	}
+	s.finished = true
+	return w.Write(s.End)
+}
+
+// readTail reads up to the last n bytes of the file at path, of size bytes
+func readTail(path string, size int64, n int) ([]byte, error) {
+	if int64(n) > size {
+		n = int(size)
+	}
+	file, err := os.Open(path) // #nosec G304 - path is the backend's own log file
+	if err != nil {
+		return nil, fmt.Errorf("read stream end: %w", err)
+	}
+	defer func() {
+		_ = file.Close() // Read only
+	}()
+
+	tail := make([]byte, n)
+	if _, err := file.ReadAt(tail, size-int64(n)); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("read stream end: %w", err)
+	}
+	return tail, nil
+}
@@ -792,3 +792,70 @@ func TestFileBackendImpl_BufferSizes(t *testing.T) {
 	t.Logf("Content before flush: %q", string(contentBeforeFlush))
 	t.Logf("Content after flush: %q", string(contentAfterFlush))
 }
+
+// TestFileBackendImpl_Stream tests framing files with a stream
+func TestFileBackendImpl_Stream(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stream.json")
+	stream := backends.FileStream{Start: []byte("[\n"), Separator: []byte(","), End: []byte("]\n")}
+
+	open := func() *backends.FileBackendImpl {
+		t.Helper()
+		backend, err := backends.NewFileBackend(path)
+		if err != nil {
+			t.Fatalf("Failed to create backend: %v", err)
+		}
+		if err := backend.SetStream(stream); err != nil {
+			t.Fatalf("SetStream failed: %v", err)
+		}
+		return backend
+	}
+	contents := func() string {
+		t.Helper()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read file: %v", err)
+		}
+		return string(data)
+	}
+
+	// An empty stream is still complete
+	backend := open()
+	if err := backend.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if got := contents(); got != "[\n]\n" {
+		t.Fatalf("Unexpected empty stream %q", got)
+	}
+
+	// Reopening removes the end; the first entry needs no separator
+	backend = open()
+	for _, entry := range []string{"1\n", "2\n"} {
+		if _, err := backend.Write([]byte(entry)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := backend.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if got := contents(); got != "[\n1\n,2\n]\n" {
+		t.Fatalf("Unexpected stream %q", got)
+	}
+
+	// Entries appended later continue the stream
+	backend = open()
+	if _, err := backend.Write([]byte("3\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := backend.FinishStream(); err != nil {
+		t.Fatalf("FinishStream failed: %v", err)
+	}
+	if err := backend.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if got := contents(); got != "[\n1\n,2\n,3\n]\n" {
+		t.Fatalf("Unexpected stream %q", got)
+	}
+	if backend.Size() != int64(len(contents())) {
+		t.Errorf("Size %d does not match the file, %d bytes", backend.Size(), len(contents()))
+	}
+}
@@ -0,0 +1,507 @@
+package backends
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wayneeseguin/omni/internal/msgpack"
+)
+
+// FluentMode selects the Forward protocol event mode
+type FluentMode int
+
+const (
+	// FluentModePackedForward sends each batch as one binary blob of
+	// concatenated entries, which the server can store without re-encoding
+	FluentModePackedForward FluentMode = iota
+	// FluentModeForward sends each batch as an array of entries
+	FluentModeForward
+)
+
+// ParseFluentMode parses a mode name: packed (or packed_forward) and forward
+func ParseFluentMode(name string) (FluentMode, error) {
+	switch strings.ToLower(name) {
+	case "", "packed", "packed_forward", "packedforward":
+		return FluentModePackedForward, nil
+	case "forward":
+		return FluentModeForward, nil
+	default:
+		return FluentModePackedForward, fmt.Errorf("unknown Fluent forward mode %q", name)
+	}
+}
+
+// Defaults for FluentConfig
+const (
+	DefaultFluentTag           = "omni"
+	DefaultFluentBatchSize     = 500
+	DefaultFluentFlushInterval = time.Second
+	DefaultFluentAckTimeout    = 30 * time.Second
+)
+
+// fluentEventTimeType is the extension type of Forward protocol EventTime
+const fluentEventTimeType = 0
+
+// fluentProbeTimeout is how long a heartbeat waits to detect a closed connection
+const fluentProbeTimeout = time.Millisecond
+
+// FluentConfig configures a Fluentd / Fluent Bit forward backend
+type FluentConfig struct {
+	Network       string        // tcp, tcp+tls or unix
+	Address       string        // host:port or socket path of the forward input
+	Tag           string        // Tag of entries without a TagField value; empty = DefaultFluentTag
+	TagField      string        // Entry field whose string value is used as the tag
+	Mode          FluentMode    // Event mode; FluentModePackedForward by default
+	Ack           bool          // Request an ack for every batch and resend unacknowledged batches
+	AckTimeout    time.Duration // 0 = DefaultFluentAckTimeout
+	TimeAsInteger bool          // Send whole-second timestamps for servers without EventTime support
+	BatchSize     int           // Entries per message; 0 = DefaultFluentBatchSize
+	FlushInterval time.Duration // 0 = DefaultFluentFlushInterval, negative disables
+	Heartbeat     time.Duration // Connection check interval; 0 disables
+	TLSConfig     *tls.Config   // Used by tcp+tls; nil verifies the server against the system roots
+	DialTimeout   time.Duration // 0 = DefaultNetworkDialTimeout
+	WriteTimeout  time.Duration // 0 = DefaultNetworkWriteTimeout
+	BufferSize    int           // Messages kept while disconnected; 0 = DefaultNetworkBufferSize
+	MinBackoff    time.Duration // First reconnect delay; 0 = DefaultNetworkMinBackoff
+	MaxBackoff    time.Duration // Reconnect delay limit; 0 = DefaultNetworkMaxBackoff
+}
+
+// fluentEntry is an encoded [time, record] entry awaiting its batch
+type fluentEntry struct {
+	tag  string
+	data []byte
+}
+
+// FluentBackend sends entries to a Fluentd or Fluent Bit forward input with
+// the Forward protocol. Entries are grouped by tag into batches sent every
+// BatchSize entries, FlushInterval or on Flush. Writes only queue entries;
+// batches are sent and acknowledged by a background goroutine, or by Flush.
+// With Ack, each batch carries a chunk ID and is resent until the server
+// acknowledges it, giving at-least-once delivery.
+type FluentBackend struct {
+	sender        netSender // Guarded by sendMu
+	tag           string
+	tagField      string
+	mode          FluentMode
+	ack           bool
+	ackTimeout    time.Duration
+	timeAsInteger bool
+	batchSize     int
+
+	pending []fluentEntry // Entries not yet packed into messages
+	closed  bool
+	mu      sync.Mutex    // Guards pending and closed
+	sendMu  sync.Mutex    // Serializes delivery; acquired before mu
+	full    chan struct{} // Wakes the loop to send a full batch
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// NewFluentBackend connects to the forward input. The first connection must
+// succeed; later connection failures are retried.
+func NewFluentBackend(config FluentConfig) (*FluentBackend, error) {
+	switch config.Network {
+	case "tcp", "tcp4", "tcp6", NetworkTLS, "unix":
+	default:
+		return nil, fmt.Errorf("unsupported Fluent network %q", config.Network)
+	}
+	if config.Address == "" {
+		return nil, fmt.Errorf("fluent destination needs an address")
+	}
+
+	fb := &FluentBackend{
+		sender: newNetSender(config.Network, config.Address, config.TLSConfig,
+			config.DialTimeout, config.WriteTimeout, config.BufferSize, config.MinBackoff, config.MaxBackoff),
+		tag:           config.Tag,
+		tagField:      config.TagField,
+		mode:          config.Mode,
+		ack:           config.Ack,
+		ackTimeout:    config.AckTimeout,
+		timeAsInteger: config.TimeAsInteger,
+		batchSize:     config.BatchSize,
+		full:          make(chan struct{}, 1),
+		done:          make(chan struct{}),
+		stopped:       make(chan struct{}),
+	}
+	if fb.tag == "" {
+		fb.tag = DefaultFluentTag
+	}
+	if fb.ackTimeout <= 0 {
+		fb.ackTimeout = DefaultFluentAckTimeout
+	}
+	if fb.batchSize <= 0 {
+		fb.batchSize = DefaultFluentBatchSize
+	}
+
+	if err := fb.sender.dial(); err != nil {
+		return nil, fmt.Errorf("dial %s: %w", config.Network, err)
+	}
+
+	interval := config.FlushInterval
+	if interval == 0 {
+		interval = DefaultFluentFlushInterval
+	}
+	go fb.loop(interval, config.Heartbeat)
+
+	return fb, nil
+}
+
+// loop sends full batches as they fill, partial batches every flush interval
+// and checks the connection every heartbeat interval until the backend is
+// closed
+func (fb *FluentBackend) loop(interval, heartbeat time.Duration) {
+	defer close(fb.stopped)
+
+	var flushC, heartbeatC <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		flushC = ticker.C
+	}
+	if heartbeat > 0 {
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		heartbeatC = ticker.C
+	}
+
+	for {
+		select {
+		case <-fb.full:
+			// Unsent batches stay queued and the failure is reported by Flush
+			_ = fb.Flush()
+		case <-flushC:
+			_ = fb.Flush()
+		case <-heartbeatC:
+			fb.heartbeat()
+		case <-fb.done:
+			return
+		}
+	}
+}
+
+// WriteRecord queues a log record as an entry with the message, level and
+// fields
+func (fb *FluentBackend) WriteRecord(rec Record) (int, error) {
+	record := make(map[string]interface{}, len(rec.Fields)+2)
+	for key, value := range rec.Fields {
+		record[key] = value
+	}
+	record["message"] = rec.Message
+	record["level"] = rec.Level
+
+	return fb.add(rec.Timestamp, record)
+}
+
+// Write queues a formatted entry. JSON objects are sent as records, so the
+// server does not parse them again; other entries are sent as the message.
+func (fb *FluentBackend) Write(entry []byte) (int, error) {
+	data := bytes.TrimSuffix(entry, []byte("\n"))
+
+	var record map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if decoder.Decode(&record) != nil || record == nil {
+		record = map[string]interface{}{"message": string(data)}
+	}
+
+	if _, err := fb.add(time.Now(), record); err != nil {
+		return 0, err
+	}
+	return len(entry), nil
+}
+
+// add encodes an entry and queues it, waking the loop once the batch is full
+func (fb *FluentBackend) add(timestamp time.Time, record map[string]interface{}) (int, error) {
+	tag := fb.tag
+	if fb.tagField != "" {
+		if value, ok := record[fb.tagField].(string); ok && value != "" {
+			tag = value
+		}
+	}
+
+	data := msgpack.AppendArrayHeader(nil, 2)
+	if fb.timeAsInteger {
+		data = msgpack.AppendInt(data, timestamp.Unix())
+	} else {
+		eventTime := binary.BigEndian.AppendUint32(nil, uint32(timestamp.Unix()))            // #nosec G115 - EventTime holds 32-bit seconds
+		eventTime = binary.BigEndian.AppendUint32(eventTime, uint32(timestamp.Nanosecond())) // #nosec G115 - nanoseconds fit in 30 bits
+		data = msgpack.AppendExt(data, fluentEventTimeType, eventTime)
+	}
+	data = msgpack.AppendValue(data, record)
+
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+
+	if fb.closed {
+		return 0, os.ErrClosed
+	}
+
+	fb.pending = append(fb.pending, fluentEntry{tag: tag, data: data})
+	if len(fb.pending) >= fb.batchSize {
+		select {
+		case fb.full <- struct{}{}:
+		default:
+		}
+	}
+
+	return len(data), nil
+}
+
+// pack moves the pending entries into one message per tag; the caller holds
+// sendMu
+func (fb *FluentBackend) pack() error {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+	if len(fb.pending) == 0 {
+		return nil
+	}
+
+	var tags []string
+	batches := make(map[string][][]byte)
+	for _, entry := range fb.pending {
+		if _, seen := batches[entry.tag]; !seen {
+			tags = append(tags, entry.tag)
+		}
+		batches[entry.tag] = append(batches[entry.tag], entry.data)
+	}
+
+	for _, tag := range tags {
+		message, err := fb.message(tag, batches[tag])
+		if err != nil {
+			return err
+		}
+		fb.sender.enqueue(message)
+	}
+	fb.pending = nil
+	return nil
+}
+
+// message encodes a batch of entries in the configured mode, with the option
+// map carrying the entry count and, with acks, the chunk ID
+func (fb *FluentBackend) message(tag string, entries [][]byte) ([]byte, error) {
+	message := msgpack.AppendArrayHeader(nil, 3)
+	message = msgpack.AppendString(message, tag)
+
+	if fb.mode == FluentModeForward {
+		message = msgpack.AppendArrayHeader(message, len(entries))
+		for _, entry := range entries {
+			message = append(message, entry...)
+		}
+	} else {
+		message = msgpack.AppendBinary(message, bytes.Join(entries, nil))
+	}
+
+	if !fb.ack {
+		message = msgpack.AppendMapHeader(message, 1)
+		return msgpack.AppendInt(msgpack.AppendString(message, "size"), int64(len(entries))), nil
+	}
+
+	chunk := make([]byte, 16)
+	if _, err := rand.Read(chunk); err != nil {
+		return nil, fmt.Errorf("generate chunk ID: %w", err)
+	}
+	message = msgpack.AppendMapHeader(message, 2)
+	message = msgpack.AppendInt(msgpack.AppendString(message, "size"), int64(len(entries)))
+	return msgpack.AppendString(msgpack.AppendString(message, "chunk"), base64.StdEncoding.EncodeToString(chunk)), nil
+}
+
+// send packs the pending entries and delivers the queued messages; the
+// caller holds sendMu. Without acks the queue is written at once; with acks each
+// message is written and acknowledged in turn, and unacknowledged messages
+// stay queued to be resent with their chunk ID.
+func (fb *FluentBackend) send() error {
+	if err := fb.pack(); err != nil {
+		return err
+	}
+	if !fb.ack {
+		return fb.sender.send()
+	}
+
+	s := &fb.sender
+	for len(s.pending) > 0 {
+		if s.conn == nil {
+			if err := s.reconnect(); err != nil {
+				return fmt.Errorf("disconnected from %s, %d messages buffered: %w", s.address, len(s.pending), err)
+			}
+		}
+
+		message := s.pending[0]
+		start := time.Now()
+		_ = s.conn.SetWriteDeadline(start.Add(s.writeTimeout))
+		_, err := s.conn.Write(message)
+		if err == nil {
+			err = fb.readAck(message)
+		}
+
+		duration := time.Since(start)
+		s.totalWriteTime += duration
+		if duration > s.maxWriteTime {
+			s.maxWriteTime = duration
+		}
+
+		if err != nil {
+			s.disconnect()
+			return err
+		}
+		s.sent(1, len(message))
+	}
+	return nil
+}
+
+// readAck waits for the server to acknowledge the chunk of message
+func (fb *FluentBackend) readAck(message []byte) error {
+	chunk := fluentChunk(message)
+
+	_ = fb.sender.conn.SetReadDeadline(time.Now().Add(fb.ackTimeout))
+	defer func() { _ = fb.sender.conn.SetReadDeadline(time.Time{}) }()
+
+	var response []byte
+	buf := make([]byte, 256)
+	for {
+		n, err := fb.sender.conn.Read(buf)
+		response = append(response, buf[:n]...)
+
+		value, _, decodeErr := msgpack.Decode(response)
+		if decodeErr == nil {
+			fields, _ := value.(map[string]interface{})
+			if ack, _ := fields["ack"].(string); ack != chunk {
+				return fmt.Errorf("unexpected ack response %v for chunk %s", value, chunk)
+			}
+			return nil
+		}
+		if !errors.Is(decodeErr, msgpack.ErrShortBuffer) {
+			return fmt.Errorf("invalid ack response: %w", decodeErr)
+		}
+		if err != nil {
+			return fmt.Errorf("wait for ack of chunk %s: %w", chunk, err)
+		}
+	}
+}
+
+// fluentChunk returns the chunk ID in the option map of an encoded message
+func fluentChunk(message []byte) string {
+	value, _, err := msgpack.Decode(message)
+	if err != nil {
+		return ""
+	}
+	parts, _ := value.([]interface{})
+	if len(parts) != 3 {
+		return ""
+	}
+	option, _ := parts[2].(map[string]interface{})
+	chunk, _ := option["chunk"].(string)
+	return chunk
+}
+
+// heartbeat detects a connection closed by the server and reconnects,
+// sending the queued messages once the server is reachable again
+func (fb *FluentBackend) heartbeat() {
+	fb.sendMu.Lock()
+	defer fb.sendMu.Unlock()
+
+	s := &fb.sender
+	if s.closed {
+		return
+	}
+
+	if s.conn != nil {
+		// The server only sends acks, so a read returns at once only when the connection is gone
+		_ = s.conn.SetReadDeadline(time.Now().Add(fluentProbeTimeout))
+		_, err := s.conn.Read(make([]byte, 1))
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			_ = s.conn.SetReadDeadline(time.Time{})
+			return
+		}
+		s.disconnect()
+	}
+
+	if err := s.reconnect(); err == nil && len(s.pending) > 0 {
+		_ = fb.send()
+	}
+}
+
+// Flush sends the pending entries. While the server is unreachable or does
+// not acknowledge a batch, the batches stay queued and an error is returned.
+func (fb *FluentBackend) Flush() error {
+	fb.sendMu.Lock()
+	defer fb.sendMu.Unlock()
+
+	return fb.send()
+}
+
+// Close stops the flush timer, sends the pending entries, making one
+// reconnect attempt if needed, and closes the connection
+func (fb *FluentBackend) Close() error {
+	fb.mu.Lock()
+	if fb.closed {
+		fb.mu.Unlock()
+		return nil
+	}
+	fb.closed = true
+	fb.mu.Unlock()
+
+	close(fb.done)
+	<-fb.stopped
+
+	fb.sendMu.Lock()
+	defer fb.sendMu.Unlock()
+
+	var errs []error
+	fb.sender.nextDial = time.Time{}
+	if err := fb.send(); err != nil {
+		errs = append(errs, fmt.Errorf("flush: %w", err))
+		// Batches that could not be delivered are lost once closed
+		fb.sender.dropped += uint64(len(fb.sender.pending))
+		fb.sender.pending = nil
+		fb.sender.pendingBytes = 0
+	}
+	if err := fb.sender.close(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// Batching reports that entries are sent in batches, so Flush is deferred to Sync and Close
+func (fb *FluentBackend) Batching() bool {
+	return true
+}
+
+// SupportsAtomic returns false as network writes are not atomic
+func (fb *FluentBackend) SupportsAtomic() bool {
+	return false
+}
+
+// Sync sends the pending entries
+func (fb *FluentBackend) Sync() error {
+	return fb.Flush()
+}
+
+// GetStats returns backend statistics. WriteCount and BytesWritten count
+// Forward messages delivered to the server; Buffered counts queued messages
+// and entries not yet packed into one. While a batch awaits its ack, GetStats
+// waits for the delivery to finish.
+func (fb *FluentBackend) GetStats() BackendStats {
+	fb.sendMu.Lock()
+	defer fb.sendMu.Unlock()
+
+	network := fb.sender.network
+	if network == NetworkTLS {
+		network = "tls"
+	}
+	stats := fb.sender.stats("fluent+" + network + "://" + fb.sender.address)
+	fb.mu.Lock()
+	stats.Buffered += len(fb.pending)
+	fb.mu.Unlock()
+	return stats
+}
@@ -0,0 +1,363 @@
+package backends_test
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wayneeseguin/omni/internal/msgpack"
+	"github.com/wayneeseguin/omni/pkg/backends"
+)
+
+// fluentMessage is a Forward protocol message received by fakeFluent
+type fluentMessage struct {
+	tag     string
+	packed  bool
+	entries [][]interface{} // [time, record] pairs
+	option  map[string]interface{}
+}
+
+// fakeFluent is a forward input that decodes messages and acknowledges
+// chunks after ackDelay, ignoring the first dropAcks of them
+type fakeFluent struct {
+	listener net.Listener
+	messages chan fluentMessage
+	mu       sync.Mutex
+	dropAcks int
+	ackDelay time.Duration
+	conns    []net.Conn
+}
+
+func startFakeFluent(t *testing.T) *fakeFluent {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	f := &fakeFluent{listener: listener, messages: make(chan fluentMessage, 100)}
+	go f.accept(t)
+	t.Cleanup(f.stop)
+	return f
+}
+
+func (f *fakeFluent) accept(t *testing.T) {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.conns = append(f.conns, conn)
+		f.mu.Unlock()
+		go f.serve(t, conn)
+	}
+}
+
+func (f *fakeFluent) serve(t *testing.T, conn net.Conn) {
+	var data []byte
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		data = append(data, buf[:n]...)
+		for len(data) > 0 {
+			value, rest, decodeErr := msgpack.Decode(data)
+			if decodeErr != nil {
+				break
+			}
+			data = rest
+			message := decodeFluentMessage(t, value)
+			f.messages <- message
+
+			chunk, ok := message.option["chunk"].(string)
+			if !ok {
+				continue
+			}
+			f.mu.Lock()
+			drop := f.dropAcks > 0
+			if drop {
+				f.dropAcks--
+			}
+			delay := f.ackDelay
+			f.mu.Unlock()
+			if drop {
+				// Without an ack, the client must resend the chunk on a new connection
+				conn.Close()
+				return
+			}
+			time.Sleep(delay)
+			ack := msgpack.AppendMapHeader(nil, 1)
+			ack = msgpack.AppendString(msgpack.AppendString(ack, "ack"), chunk)
+			_, _ = conn.Write(ack)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func decodeFluentMessage(t *testing.T, value interface{}) fluentMessage {
+	parts, ok := value.([]interface{})
+	if !ok || len(parts) != 3 {
+		t.Errorf("Unexpected message %v", value)
+		return fluentMessage{}
+	}
+
+	message := fluentMessage{}
+	message.tag, _ = parts[0].(string)
+	message.option, _ = parts[2].(map[string]interface{})
+
+	switch entries := parts[1].(type) {
+	case []interface{}:
+		for _, entry := range entries {
+			pair, _ := entry.([]interface{})
+			message.entries = append(message.entries, pair)
+		}
+	case []byte:
+		message.packed = true
+		for len(entries) > 0 {
+			entry, rest, err := msgpack.Decode(entries)
+			if err != nil {
+				t.Errorf("Invalid packed entries: %v", err)
+				break
+			}
+			pair, _ := entry.([]interface{})
+			message.entries = append(message.entries, pair)
+			entries = rest
+		}
+	}
+	return message
+}
+
+func (f *fakeFluent) stop() {
+	f.listener.Close()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, conn := range f.conns {
+		conn.Close()
+	}
+}
+
+func (f *fakeFluent) next(t *testing.T) fluentMessage {
+	t.Helper()
+	select {
+	case message := <-f.messages:
+		return message
+	case <-time.After(3 * time.Second):
+		t.Fatal("Timed out waiting for a message")
+		return fluentMessage{}
+	}
+}
+
+func TestFluentBackend_Modes(t *testing.T) {
+	timestamp := time.Date(2024, 5, 1, 12, 0, 0, 500, time.UTC)
+
+	for _, mode := range []backends.FluentMode{backends.FluentModePackedForward, backends.FluentModeForward} {
+		fluent := startFakeFluent(t)
+		backend, err := backends.NewFluentBackend(backends.FluentConfig{
+			Network:       "tcp",
+			Address:       fluent.listener.Addr().String(),
+			Tag:           "app",
+			TagField:      "service",
+			Mode:          mode,
+			FlushInterval: -1,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create backend: %v", err)
+		}
+		defer backend.Close()
+
+		for _, rec := range []backends.Record{
+			{Timestamp: timestamp, Level: "info", Message: "one"},
+			{Timestamp: timestamp, Level: "warn", Message: "two", Fields: map[string]interface{}{"service": "billing", "attempt": 2}},
+			{Timestamp: timestamp, Level: "info", Message: "three"},
+		} {
+			if _, err := backend.WriteRecord(rec); err != nil {
+				t.Fatalf("WriteRecord failed: %v", err)
+			}
+		}
+		if err := backend.Flush(); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+
+		// One message per tag, in order of first appearance
+		app := fluent.next(t)
+		if app.tag != "app" || len(app.entries) != 2 || app.packed != (mode == backends.FluentModePackedForward) || app.option["size"] != int64(2) {
+			t.Fatalf("Unexpected message %+v", app)
+		}
+		eventTime, ok := app.entries[0][0].(msgpack.Ext)
+		if !ok || eventTime.Type != 0 || binary.BigEndian.Uint32(eventTime.Data) != uint32(timestamp.Unix()) || binary.BigEndian.Uint32(eventTime.Data[4:]) != 500 {
+			t.Errorf("Unexpected event time %v", app.entries[0][0])
+		}
+		if record := app.entries[1][1].(map[string]interface{}); record["message"] != "three" || record["level"] != "info" {
+			t.Errorf("Unexpected record %v", record)
+		}
+
+		billing := fluent.next(t)
+		record := billing.entries[0][1].(map[string]interface{})
+		if billing.tag != "billing" || record["message"] != "two" || record["attempt"] != int64(2) {
+			t.Errorf("Unexpected message %+v", billing)
+		}
+	}
+}
+
+func TestFluentBackend_Write(t *testing.T) {
+	fluent := startFakeFluent(t)
+	backend, err := backends.NewFluentBackend(backends.FluentConfig{
+		Network:       "tcp",
+		Address:       fluent.listener.Addr().String(),
+		TimeAsInteger: true,
+		BatchSize:     2,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	defer backend.Close()
+
+	// JSON entries are sent as records; a full batch is sent without Flush
+	if _, err := backend.Write([]byte(`{"message":"json","count":3}` + "\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if _, err := backend.Write([]byte("plain\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	message := fluent.next(t)
+	if message.tag != backends.DefaultFluentTag || len(message.entries) != 2 {
+		t.Fatalf("Unexpected message %+v", message)
+	}
+	if _, ok := message.entries[0][0].(int64); !ok {
+		t.Errorf("Expected an integer time, got %T", message.entries[0][0])
+	}
+	if record := message.entries[0][1].(map[string]interface{}); record["message"] != "json" || record["count"] != int64(3) {
+		t.Errorf("Unexpected record %v", record)
+	}
+	if record := message.entries[1][1].(map[string]interface{}); record["message"] != "plain" {
+		t.Errorf("Unexpected record %v", record)
+	}
+}
+
+func TestFluentBackend_Ack(t *testing.T) {
+	fluent := startFakeFluent(t)
+	fluent.dropAcks = 1
+
+	backend, err := backends.NewFluentBackend(backends.FluentConfig{
+		Network:       "tcp",
+		Address:       fluent.listener.Addr().String(),
+		Ack:           true,
+		AckTimeout:    time.Second,
+		FlushInterval: -1,
+		MinBackoff:    time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	defer backend.Close()
+
+	if _, err := backend.WriteRecord(backends.Record{Level: "info", Message: "once"}); err != nil {
+		t.Fatalf("WriteRecord failed: %v", err)
+	}
+
+	// The first attempt is not acknowledged and stays queued
+	if err := backend.Flush(); err == nil {
+		t.Fatal("Expected error for a missing ack")
+	}
+	first := fluent.next(t)
+	if stats := backend.GetStats(); stats.Buffered != 1 || stats.WriteCount != 0 {
+		t.Errorf("Unexpected stats after a missing ack: %+v", stats)
+	}
+
+	// The retry reuses the chunk ID and is acknowledged
+	if err := backend.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	second := fluent.next(t)
+	if first.option["chunk"] == nil || first.option["chunk"] != second.option["chunk"] {
+		t.Errorf("Expected the same chunk, got %v and %v", first.option["chunk"], second.option["chunk"])
+	}
+	if stats := backend.GetStats(); stats.Buffered != 0 || stats.WriteCount != 1 || stats.Reconnects != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestFluentBackend_AckDoesNotBlockWrites(t *testing.T) {
+	fluent := startFakeFluent(t)
+	fluent.ackDelay = 300 * time.Millisecond
+
+	backend, err := backends.NewFluentBackend(backends.FluentConfig{
+		Network:       "tcp",
+		Address:       fluent.listener.Addr().String(),
+		Ack:           true,
+		BatchSize:     1,
+		FlushInterval: -1,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	defer backend.Close()
+
+	// Full batches are sent in the background, so writes do not wait for acks
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := backend.WriteRecord(backends.Record{Level: "info", Message: "queued"}); err != nil {
+			t.Fatalf("WriteRecord failed: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("Writes waited %s for acks", elapsed)
+	}
+	fluent.next(t)
+
+	if err := backend.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if stats := backend.GetStats(); stats.WriteCount == 0 || stats.Buffered != 0 {
+		t.Errorf("Unexpected stats after Flush: %+v", stats)
+	}
+}
+
+func TestFluentBackend_Heartbeat(t *testing.T) {
+	fluent := startFakeFluent(t)
+	backend, err := backends.NewFluentBackend(backends.FluentConfig{
+		Network:       "tcp",
+		Address:       fluent.listener.Addr().String(),
+		FlushInterval: -1,
+		Heartbeat:     20 * time.Millisecond,
+		MinBackoff:    time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	defer backend.Close()
+
+	// Closing the server side is noticed by the heartbeat, which reconnects
+	fluent.mu.Lock()
+	for _, conn := range fluent.conns {
+		conn.Close()
+	}
+	fluent.mu.Unlock()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for backend.GetStats().Reconnects == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the heartbeat to reconnect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestFluentBackend_Errors(t *testing.T) {
+	for _, config := range []backends.FluentConfig{
+		{Network: "udp", Address: "127.0.0.1:24224"},
+		{Network: "tcp"},
+	} {
+		if _, err := backends.NewFluentBackend(config); err == nil {
+			t.Errorf("Expected error for %+v", config)
+		}
+	}
+
+	if _, err := backends.ParseFluentMode("compressed"); err == nil {
+		t.Error("Expected error for unknown mode")
+	}
+}
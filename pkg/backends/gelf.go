@@ -0,0 +1,294 @@
+package backends
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/formatters"
+)
+
+// GELFCompression selects how GELF messages are compressed over UDP
+type GELFCompression int
+
+const (
+	// GELFCompressionNone sends messages uncompressed
+	GELFCompressionNone GELFCompression = iota
+	// GELFCompressionGzip compresses each message with gzip
+	GELFCompressionGzip
+	// GELFCompressionZlib compresses each message with zlib
+	GELFCompressionZlib
+)
+
+// ParseGELFCompression parses a compression name: none, gzip or zlib
+func ParseGELFCompression(name string) (GELFCompression, error) {
+	switch strings.ToLower(name) {
+	case "", "none":
+		return GELFCompressionNone, nil
+	case "gzip":
+		return GELFCompressionGzip, nil
+	case "zlib":
+		return GELFCompressionZlib, nil
+	default:
+		return GELFCompressionNone, fmt.Errorf("unknown GELF compression %q", name)
+	}
+}
+
+// DefaultGELFChunkSize is the default largest UDP datagram, which fits a
+// typical Ethernet MTU
+const DefaultGELFChunkSize = 1420
+
+// GELF chunk layout: 2 magic bytes, an 8-byte message ID, the sequence number
+// and the sequence count
+const (
+	gelfChunkHeaderSize = 12
+	gelfMaxChunks       = 128
+)
+
+// gelfChunkMagic starts every chunk of a chunked GELF message
+var gelfChunkMagic = []byte{0x1e, 0x0f}
+
+// GELFConfig configures a GELF backend
+type GELFConfig struct {
+	Network      string          // udp, tcp or tcp+tls
+	Address      string          // host:port of the Graylog GELF input
+	Host         string          // Source host sent with each message; defaults to the hostname
+	Compression  GELFCompression // UDP only; Graylog's TCP inputs do not accept compressed messages
+	ChunkSize    int             // Largest UDP datagram; larger messages are chunked. 0 = DefaultGELFChunkSize
+	TLSConfig    *tls.Config     // Used by tcp+tls; nil verifies the server against the system roots
+	DialTimeout  time.Duration   // 0 = DefaultNetworkDialTimeout
+	WriteTimeout time.Duration   // 0 = DefaultNetworkWriteTimeout
+	BufferSize   int             // Messages (UDP chunks) kept while disconnected; 0 = DefaultNetworkBufferSize
+	MinBackoff   time.Duration   // First reconnect delay; 0 = DefaultNetworkMinBackoff
+	MaxBackoff   time.Duration   // Reconnect delay limit; 0 = DefaultNetworkMaxBackoff
+}
+
+// GELFBackend sends GELF 1.1 messages to a Graylog UDP or TCP input. Over
+// UDP, messages larger than the chunk size are split into GELF chunks and
+// may be compressed; over TCP, messages are NUL-terminated. Messages are
+// queued and sent like the network backend's entries.
+type GELFBackend struct {
+	sender      netSender
+	host        string
+	compression GELFCompression
+	chunkSize   int
+	mu          sync.Mutex
+}
+
+// NewGELFBackend connects to the GELF input. The first connection must
+// succeed; later connection failures are retried.
+func NewGELFBackend(config GELFConfig) (*GELFBackend, error) {
+	switch config.Network {
+	case "udp", "udp4", "udp6":
+	case "tcp", "tcp4", "tcp6", NetworkTLS:
+		if config.Compression != GELFCompressionNone {
+			return nil, fmt.Errorf("GELF compression is only supported over UDP")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported GELF network %q", config.Network)
+	}
+	if config.Address == "" {
+		return nil, fmt.Errorf("GELF destination needs an address")
+	}
+
+	chunkSize := config.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultGELFChunkSize
+	}
+	if chunkSize <= gelfChunkHeaderSize || chunkSize > maxDatagramSize {
+		return nil, fmt.Errorf("invalid GELF chunk size %d", config.ChunkSize)
+	}
+
+	host := config.Host
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+
+	gb := &GELFBackend{
+		sender: newNetSender(config.Network, config.Address, config.TLSConfig,
+			config.DialTimeout, config.WriteTimeout, config.BufferSize, config.MinBackoff, config.MaxBackoff),
+		host:        host,
+		compression: config.Compression,
+		chunkSize:   chunkSize,
+	}
+
+	if err := gb.sender.dial(); err != nil {
+		return nil, fmt.Errorf("dial %s: %w", config.Network, err)
+	}
+
+	return gb, nil
+}
+
+// WriteRecord queues a log record as a GELF message
+func (gb *GELFBackend) WriteRecord(rec Record) (int, error) {
+	message := formatters.NewGELFMessage(gb.host, rec.Timestamp, rec.Level, rec.Message, rec.Fields)
+	message.File = rec.SourceFile
+	message.Line = rec.SourceLine
+	data, err := json.Marshal(message)
+	if err != nil {
+		return 0, fmt.Errorf("encode GELF message: %w", err)
+	}
+	return gb.send(data)
+}
+
+// Write queues a formatted entry. Entries produced by the GELF formatter are
+// sent as they are; other entries are sent as the short message.
+func (gb *GELFBackend) Write(entry []byte) (int, error) {
+	data := bytes.TrimSuffix(entry, []byte("\n"))
+
+	var probe struct {
+		Version      string `json:"version"`
+		ShortMessage string `json:"short_message"`
+	}
+	if json.Unmarshal(data, &probe) != nil || probe.Version == "" || probe.ShortMessage == "" {
+		message := formatters.NewGELFMessage(gb.host, time.Now(), "", string(data), nil)
+		encoded, err := json.Marshal(message)
+		if err != nil {
+			return 0, fmt.Errorf("encode GELF message: %w", err)
+		}
+		data = encoded
+	} else {
+		// The entry buffer may be reused once Write returns
+		data = append([]byte(nil), data...)
+	}
+
+	if _, err := gb.send(data); err != nil {
+		return 0, err
+	}
+	return len(entry), nil
+}
+
+// send queues an encoded message, compressing and chunking it over UDP
+func (gb *GELFBackend) send(data []byte) (int, error) {
+	gb.mu.Lock()
+	defer gb.mu.Unlock()
+
+	if gb.sender.closed {
+		return 0, os.ErrClosed
+	}
+
+	if gb.sender.datagram {
+		packets, err := gb.packets(data)
+		if err != nil {
+			return 0, err
+		}
+		for _, packet := range packets {
+			gb.sender.enqueue(packet)
+		}
+	} else {
+		gb.sender.enqueue(append(append(make([]byte, 0, len(data)+1), data...), 0))
+	}
+
+	if gb.sender.pendingBytes >= networkFlushBytes {
+		// Unsent messages stay queued and the failure is reported by Flush
+		_ = gb.sender.send()
+	}
+
+	return len(data), nil
+}
+
+// packets compresses a message and splits it into chunks when it does not
+// fit in one datagram
+func (gb *GELFBackend) packets(data []byte) ([][]byte, error) {
+	data, err := gb.compress(data)
+	if err != nil {
+		return nil, fmt.Errorf("compress GELF message: %w", err)
+	}
+	if len(data) <= gb.chunkSize {
+		return [][]byte{data}, nil
+	}
+
+	payloadSize := gb.chunkSize - gelfChunkHeaderSize
+	count := (len(data) + payloadSize - 1) / payloadSize
+	if count > gelfMaxChunks {
+		return nil, fmt.Errorf("GELF message of %d bytes needs %d chunks, more than %d", len(data), count, gelfMaxChunks)
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("generate GELF message ID: %w", err)
+	}
+
+	packets := make([][]byte, 0, count)
+	for seq := 0; seq < count; seq++ {
+		payload := data[seq*payloadSize : min((seq+1)*payloadSize, len(data))]
+		packet := make([]byte, 0, gelfChunkHeaderSize+len(payload))
+		packet = append(packet, gelfChunkMagic...)
+		packet = append(packet, id...)
+		packet = append(packet, byte(seq), byte(count)) // #nosec G115 - at most gelfMaxChunks
+		packets = append(packets, append(packet, payload...))
+	}
+	return packets, nil
+}
+
+// compress applies the configured compression
+func (gb *GELFBackend) compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+
+	switch gb.compression {
+	case GELFCompressionGzip:
+		zw := gzip.NewWriter(&buf)
+		if _, err = zw.Write(data); err == nil {
+			err = zw.Close()
+		}
+	case GELFCompressionZlib:
+		zw := zlib.NewWriter(&buf)
+		if _, err = zw.Write(data); err == nil {
+			err = zw.Close()
+		}
+	default:
+		return data, nil
+	}
+
+	return buf.Bytes(), err
+}
+
+// Flush sends the queued messages. While the server is unreachable the
+// messages stay queued and an error is returned.
+func (gb *GELFBackend) Flush() error {
+	gb.mu.Lock()
+	defer gb.mu.Unlock()
+
+	return gb.sender.send()
+}
+
+// Close sends the queued messages, making one reconnect attempt if needed,
+// and closes the connection
+func (gb *GELFBackend) Close() error {
+	gb.mu.Lock()
+	defer gb.mu.Unlock()
+
+	return gb.sender.close()
+}
+
+// SupportsAtomic returns false as network writes are not atomic
+func (gb *GELFBackend) SupportsAtomic() bool {
+	return false
+}
+
+// Sync sends the queued messages
+func (gb *GELFBackend) Sync() error {
+	return gb.Flush()
+}
+
+// GetStats returns backend statistics. WriteCount and BytesWritten count
+// datagrams or framed messages delivered to the server.
+func (gb *GELFBackend) GetStats() BackendStats {
+	gb.mu.Lock()
+	defer gb.mu.Unlock()
+
+	network := gb.sender.network
+	if network == NetworkTLS {
+		network = "tls"
+	}
+	return gb.sender.stats("gelf+" + network + "://" + gb.sender.address)
+}
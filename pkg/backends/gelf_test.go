@@ -0,0 +1,240 @@
+package backends_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/backends"
+)
+
+// readGELFDatagram reads one GELF message from conn, reassembling chunks and
+// decompressing gzip or zlib payloads
+func readGELFDatagram(t *testing.T, conn net.PacketConn) (map[string]interface{}, int) {
+	t.Helper()
+
+	var chunks [][]byte
+	packets := 0
+	buf := make([]byte, 65536)
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("ReadFrom failed: %v", err)
+		}
+		packets++
+		packet := append([]byte(nil), buf[:n]...)
+
+		if len(packet) < 2 || packet[0] != 0x1e || packet[1] != 0x0f {
+			return decodeGELF(t, packet), packets
+		}
+		seq, count := int(packet[10]), int(packet[11])
+		if chunks == nil {
+			chunks = make([][]byte, count)
+		}
+		if len(chunks) != count || seq >= count {
+			t.Fatalf("Inconsistent chunk %d of %d", seq, count)
+		}
+		chunks[seq] = packet[12:]
+		if packets == count {
+			return decodeGELF(t, bytes.Join(chunks, nil)), packets
+		}
+	}
+}
+
+func decodeGELF(t *testing.T, payload []byte) map[string]interface{} {
+	t.Helper()
+
+	var r io.Reader = bytes.NewReader(payload)
+	switch {
+	case bytes.HasPrefix(payload, []byte{0x1f, 0x8b}):
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			t.Fatalf("Invalid gzip payload: %v", err)
+		}
+		r = zr
+	case payload[0] == 0x78:
+		zr, err := zlib.NewReader(r)
+		if err != nil {
+			t.Fatalf("Invalid zlib payload: %v", err)
+		}
+		r = zr
+	}
+
+	var message map[string]interface{}
+	if err := json.NewDecoder(r).Decode(&message); err != nil {
+		t.Fatalf("Invalid GELF message: %v", err)
+	}
+	return message
+}
+
+func TestGELFBackend_UDP(t *testing.T) {
+	for _, compression := range []backends.GELFCompression{
+		backends.GELFCompressionNone,
+		backends.GELFCompressionGzip,
+		backends.GELFCompressionZlib,
+	} {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		defer conn.Close()
+
+		backend, err := backends.NewGELFBackend(backends.GELFConfig{
+			Network:     "udp",
+			Address:     conn.LocalAddr().String(),
+			Host:        "web-1",
+			Compression: compression,
+			ChunkSize:   256,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create backend: %v", err)
+		}
+		defer backend.Close()
+
+		rec := backends.Record{
+			Timestamp: time.Date(2024, 5, 1, 12, 0, 0, 250e6, time.UTC),
+			Level:     "error",
+			Message:   "payment failed",
+			Fields:    map[string]interface{}{"order": "A-17", "amount": 12.5, "id": 7},
+		}
+		if _, err := backend.WriteRecord(rec); err != nil {
+			t.Fatalf("WriteRecord failed: %v", err)
+		}
+		if err := backend.Flush(); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+
+		message, packets := readGELFDatagram(t, conn)
+		if packets != 1 {
+			t.Errorf("Expected an unchunked message, got %d packets", packets)
+		}
+		expected := map[string]interface{}{
+			"version":       "1.1",
+			"host":          "web-1",
+			"short_message": "payment failed",
+			"timestamp":     1714564800.25,
+			"level":         float64(3),
+			"_order":        "A-17",
+			"_amount":       12.5,
+			"_id_":          float64(7),
+		}
+		for key, value := range expected {
+			if message[key] != value {
+				t.Errorf("Compression %d: expected %s %v, got %v", compression, key, value, message[key])
+			}
+		}
+
+		// Messages that do not fit in one datagram once compressed are chunked
+		large := strings.Repeat("0123456789abcdefghijklmnopqrstuvwxyz", 100)
+		rec.Message = "dump\n" + large
+		if _, err := backend.WriteRecord(rec); err != nil {
+			t.Fatalf("WriteRecord failed: %v", err)
+		}
+		if err := backend.Flush(); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+		message, packets = readGELFDatagram(t, conn)
+		if compression == backends.GELFCompressionNone && packets < 2 {
+			t.Errorf("Expected a chunked message, got %d packets", packets)
+		}
+		if message["short_message"] != "dump" || message["full_message"] != rec.Message {
+			t.Errorf("Unexpected reassembled message %v", message["short_message"])
+		}
+	}
+}
+
+func TestGELFBackend_TooManyChunks(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer conn.Close()
+
+	backend, err := backends.NewGELFBackend(backends.GELFConfig{
+		Network:   "udp",
+		Address:   conn.LocalAddr().String(),
+		ChunkSize: 100,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	defer backend.Close()
+
+	// 128 chunks of 88 bytes hold at most 11264 bytes
+	if _, err := backend.WriteRecord(backends.Record{Message: strings.Repeat("x", 12000)}); err == nil {
+		t.Error("Expected error for a message needing more than 128 chunks")
+	}
+}
+
+func TestGELFBackend_TCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	collector := startFakeCollector(t, listener, backends.NetworkFramingNull)
+
+	backend, err := backends.NewGELFBackend(backends.GELFConfig{
+		Network: "tcp",
+		Address: listener.Addr().String(),
+		Host:    "web-1",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	defer backend.Close()
+
+	// Formatted GELF entries pass through; other entries become the short message
+	formatted := `{"version":"1.1","host":"api","short_message":"formatted","level":6}`
+	if _, err := backend.Write([]byte(formatted + "\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if _, err := backend.Write([]byte("plain text\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := backend.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	for _, expected := range []string{"formatted", "plain text"} {
+		select {
+		case entry := <-collector.entries:
+			var message map[string]interface{}
+			if err := json.Unmarshal([]byte(entry), &message); err != nil {
+				t.Fatalf("Invalid GELF message %q: %v", entry, err)
+			}
+			if message["short_message"] != expected {
+				t.Errorf("Expected short_message %q, got %v", expected, message["short_message"])
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatal("Timed out waiting for the message")
+		}
+	}
+
+	if stats := backend.GetStats(); stats.Path != "gelf+tcp://"+listener.Addr().String() || stats.WriteCount != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestGELFBackend_Errors(t *testing.T) {
+	for _, config := range []backends.GELFConfig{
+		{Network: "unix", Address: "/tmp/gelf.sock"},
+		{Network: "udp"},
+		{Network: "tcp", Address: "127.0.0.1:12201", Compression: backends.GELFCompressionGzip},
+		{Network: "udp", Address: "127.0.0.1:12201", ChunkSize: 12},
+	} {
+		if _, err := backends.NewGELFBackend(config); err == nil {
+			t.Errorf("Expected error for %+v", config)
+		}
+	}
+
+	if _, err := backends.ParseGELFCompression("lz4"); err == nil {
+		t.Error("Expected error for unknown compression")
+	}
+}
@@ -0,0 +1,277 @@
+package backends
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/formatters"
+	"github.com/wayneeseguin/omni/pkg/types"
+)
+
+// HTTPFormat selects how a batch of entries is encoded in the request body
+type HTTPFormat int
+
+const (
+	// HTTPFormatNDJSON sends one entry per line (application/x-ndjson)
+	HTTPFormatNDJSON HTTPFormat = iota
+	// HTTPFormatJSONArray sends the batch as a JSON array; entries that are
+	// not JSON are sent as strings. Batches of messages written with
+	// WriteBatchMessage are encoded by their formatter's FormatBatch.
+	HTTPFormatJSONArray
+)
+
+// ParseHTTPFormat parses a batch format name: ndjson (or jsonl) and json (or array)
+func ParseHTTPFormat(name string) (HTTPFormat, error) {
+	switch strings.ToLower(name) {
+	case "", "ndjson", "jsonl", "jsonlines":
+		return HTTPFormatNDJSON, nil
+	case "json", "array", "json-array":
+		return HTTPFormatJSONArray, nil
+	default:
+		return HTTPFormatNDJSON, fmt.Errorf("unknown HTTP batch format %q", name)
+	}
+}
+
+// Defaults for HTTPConfig
+const (
+	DefaultHTTPBatchSize     = 100
+	DefaultHTTPBatchBytes    = 1 << 20
+	DefaultHTTPFlushInterval = time.Second
+	DefaultHTTPTimeout       = 10 * time.Second
+	DefaultHTTPMaxRetries    = 3
+	DefaultHTTPMinBackoff    = 500 * time.Millisecond
+	DefaultHTTPMaxBackoff    = 30 * time.Second
+	DefaultHTTPMaxInFlight   = 2
+)
+
+// HTTPConfig configures an HTTP backend
+type HTTPConfig struct {
+	URL           string            // Endpoint receiving the POSTed batches
+	Format        HTTPFormat        // Batch encoding
+	Compress      bool              // Gzip request bodies
+	Headers       map[string]string // Extra request headers
+	BearerToken   string            // Sent as "Authorization: Bearer <token>"
+	Username      string            // Basic auth, used when BearerToken is empty
+	Password      string
+	BatchSize     int           // Entries per request; 0 = DefaultHTTPBatchSize
+	BatchBytes    int           // Bytes per request before compression; 0 = DefaultHTTPBatchBytes
+	FlushInterval time.Duration // Send partial batches this often; 0 = DefaultHTTPFlushInterval, negative disables
+	Timeout       time.Duration // Request timeout; 0 = DefaultHTTPTimeout
+	MaxRetries    int           // Retries per batch; 0 = DefaultHTTPMaxRetries, negative disables
+	MinBackoff    time.Duration // First retry delay; 0 = DefaultHTTPMinBackoff
+	MaxBackoff    time.Duration // Retry delay limit, also bounding Retry-After; 0 = DefaultHTTPMaxBackoff
+	MaxInFlight   int           // Concurrent requests; 0 = DefaultHTTPMaxInFlight
+	TLSConfig     *tls.Config   // Used for https URLs; nil verifies against the system roots
+	Client        *http.Client  // Overrides Timeout and TLSConfig when set
+}
+
+// HTTPBackend POSTs batches of entries to an HTTP endpoint. Delivery happens
+// in the background; failures are reported by Flush and counted in GetStats.
+type HTTPBackend struct {
+	batcher *httpBatcher
+	path    string
+}
+
+// NewHTTPBackend creates an HTTP backend and starts its flush interval timer
+func NewHTTPBackend(config HTTPConfig) (*HTTPBackend, error) {
+	endpoint, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid HTTP URL: %w", err)
+	}
+	if (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid HTTP URL %q", config.URL)
+	}
+
+	header := http.Header{}
+	header.Set("User-Agent", "omni")
+	switch config.Format {
+	case HTTPFormatJSONArray:
+		header.Set("Content-Type", "application/json")
+	default:
+		header.Set("Content-Type", "application/x-ndjson")
+	}
+	for key, value := range config.Headers {
+		header.Set(key, value)
+	}
+	switch {
+	case config.BearerToken != "":
+		header.Set("Authorization", "Bearer "+config.BearerToken)
+	case config.Username != "":
+		req := http.Request{Header: http.Header{}}
+		req.SetBasicAuth(config.Username, config.Password)
+		header.Set("Authorization", req.Header.Get("Authorization"))
+	}
+
+	encode := encodeNDJSON
+	if config.Format == HTTPFormatJSONArray {
+		encode = encodeJSONArray
+	}
+
+	// Keep credentials out of the stats path
+	endpoint.User = nil
+
+	return &HTTPBackend{
+		batcher: newHTTPBatcher(httpBatchConfig{
+			client:        httpClient(config.Client, config.Timeout, config.TLSConfig),
+			url:           config.URL,
+			header:        header,
+			compress:      config.Compress,
+			batchSize:     config.BatchSize,
+			batchBytes:    config.BatchBytes,
+			flushInterval: config.FlushInterval,
+			maxRetries:    config.MaxRetries,
+			minBackoff:    config.MinBackoff,
+			maxBackoff:    config.MaxBackoff,
+			maxInFlight:   config.MaxInFlight,
+		}, encode),
+		path: endpoint.String(),
+	}, nil
+}
+
+// httpClient returns client, or one with the given timeout and TLS configuration
+func httpClient(client *http.Client, timeout time.Duration, tlsConfig *tls.Config) *http.Client {
+	if client != nil {
+		return client
+	}
+	if timeout <= 0 {
+		timeout = DefaultHTTPTimeout
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// batchMessage is a queued entry kept with the message it was formatted from
+type batchMessage struct {
+	formatter formatters.BatchFormatter
+	msg       types.LogMessage
+	entry     []byte
+}
+
+// batchEntry returns the formatted entry of a queued item
+func batchEntry(item interface{}) []byte {
+	if message, ok := item.(batchMessage); ok {
+		return message.entry
+	}
+	return item.([]byte)
+}
+
+// encodeNDJSON writes one entry per line
+func encodeNDJSON(batch []interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, entry := range batch {
+		buf.Write(batchEntry(entry))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeJSONArray writes the entries as a JSON array, quoting entries that
+// are not JSON. Batches of messages sharing a formatter are encoded by it.
+func encodeJSONArray(batch []interface{}) ([]byte, error) {
+	if formatter, messages := batchMessages(batch); formatter != nil {
+		data, err := formatter.FormatBatch(messages)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.TrimSuffix(data, []byte("\n")), nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, item := range batch {
+		entry := batchEntry(item)
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if json.Valid(entry) {
+			buf.Write(entry)
+			continue
+		}
+		quoted, err := json.Marshal(string(entry))
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(quoted)
+	}
+	buf.WriteByte(']')
+	return buf.Bytes(), nil
+}
+
+// batchMessages returns the formatter and messages of a batch when every
+// entry was queued by WriteBatchMessage with the same formatter
+func batchMessages(batch []interface{}) (formatters.BatchFormatter, []types.LogMessage) {
+	var formatter formatters.BatchFormatter
+	messages := make([]types.LogMessage, 0, len(batch))
+	for _, item := range batch {
+		message, ok := item.(batchMessage)
+		if !ok || (formatter != nil && message.formatter != formatter) {
+			return nil, nil
+		}
+		formatter = message.formatter
+		messages = append(messages, message.msg)
+	}
+	return formatter, messages
+}
+
+// Write queues an entry for the next batch. It blocks while the maximum
+// number of requests is in flight.
+func (hb *HTTPBackend) Write(entry []byte) (int, error) {
+	data := bytes.TrimSuffix(entry, []byte("\n"))
+	if err := hb.batcher.add(append([]byte(nil), data...), len(data)); err != nil {
+		return 0, err
+	}
+	return len(entry), nil
+}
+
+// WriteBatchMessage queues a message for the next batch, keeping it for the
+// formatter's FormatBatch in the JSON array format
+func (hb *HTTPBackend) WriteBatchMessage(formatter formatters.BatchFormatter, msg types.LogMessage, entry []byte) (int, error) {
+	data := bytes.TrimSuffix(entry, []byte("\n"))
+	message := batchMessage{formatter: formatter, msg: msg, entry: append([]byte(nil), data...)}
+	if err := hb.batcher.add(message, len(data)); err != nil {
+		return 0, err
+	}
+	return len(entry), nil
+}
+
+// Flush sends the queued entries and waits for all requests to finish,
+// returning the delivery failures since the last Flush
+func (hb *HTTPBackend) Flush() error {
+	return hb.batcher.flush()
+}
+
+// Close sends the queued entries and stops the flush interval timer
+func (hb *HTTPBackend) Close() error {
+	return hb.batcher.close()
+}
+
+// Batching returns true as entries are sent in batches on their own schedule
+func (hb *HTTPBackend) Batching() bool {
+	return true
+}
+
+// SupportsAtomic returns false as batches are delivered asynchronously
+func (hb *HTTPBackend) SupportsAtomic() bool {
+	return false
+}
+
+// Sync sends the queued entries
+func (hb *HTTPBackend) Sync() error {
+	return hb.Flush()
+}
+
+// GetStats returns backend statistics. WriteCount counts entries accepted by
+// the server, TotalWriteTime and MaxWriteTime measure request latency, and
+// ErrorCount counts failed requests; Dropped counts entries given up on.
+func (hb *HTTPBackend) GetStats() BackendStats {
+	return hb.batcher.stats(hb.path)
+}
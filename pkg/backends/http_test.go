@@ -0,0 +1,367 @@
+package backends_test
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/backends"
+	"github.com/wayneeseguin/omni/pkg/formatters"
+	"github.com/wayneeseguin/omni/pkg/types"
+)
+
+// fakeIngest records the requests it receives and answers with the queued
+// status codes, then 200
+type fakeIngest struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   []string
+	statuses []int
+	header   http.Header // Added to non-200 responses
+	active   int32
+	maxSeen  int32
+	delay    time.Duration
+}
+
+func (f *fakeIngest) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	active := atomic.AddInt32(&f.active, 1)
+	defer atomic.AddInt32(&f.active, -1)
+	for {
+		seen := atomic.LoadInt32(&f.maxSeen)
+		if active <= seen || atomic.CompareAndSwapInt32(&f.maxSeen, seen, active) {
+			break
+		}
+	}
+	time.Sleep(f.delay)
+
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body = zr
+	}
+	data, _ := io.ReadAll(body)
+
+	f.mu.Lock()
+	f.requests = append(f.requests, r)
+	f.bodies = append(f.bodies, string(data))
+	status := http.StatusOK
+	if len(f.statuses) > 0 {
+		status, f.statuses = f.statuses[0], f.statuses[1:]
+	}
+	f.mu.Unlock()
+
+	if status != http.StatusOK {
+		for key, values := range f.header {
+			w.Header()[key] = values
+		}
+	}
+	w.WriteHeader(status)
+}
+
+func (f *fakeIngest) received() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.bodies...)
+}
+
+func newHTTPBackend(t *testing.T, config backends.HTTPConfig) *backends.HTTPBackend {
+	t.Helper()
+	backend, err := backends.NewHTTPBackend(config)
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	t.Cleanup(func() { backend.Close() })
+	return backend
+}
+
+func TestHTTPBackend_NDJSON(t *testing.T) {
+	ingest := &fakeIngest{}
+	server := httptest.NewServer(ingest)
+	defer server.Close()
+
+	backend := newHTTPBackend(t, backends.HTTPConfig{
+		URL:         server.URL + "/ingest",
+		BearerToken: "secret",
+		Headers:     map[string]string{"X-Source": "omni-test"},
+	})
+
+	for _, entry := range []string{`{"msg":"one"}` + "\n", `{"msg":"two"}` + "\n"} {
+		if _, err := backend.Write([]byte(entry)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := backend.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	bodies := ingest.received()
+	if len(bodies) != 1 || bodies[0] != "{\"msg\":\"one\"}\n{\"msg\":\"two\"}\n" {
+		t.Fatalf("Unexpected bodies %q", bodies)
+	}
+	req := ingest.requests[0]
+	if req.Method != http.MethodPost || req.URL.Path != "/ingest" {
+		t.Errorf("Unexpected request %s %s", req.Method, req.URL.Path)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("Unexpected Authorization %q", got)
+	}
+	if got := req.Header.Get("Content-Type"); got != "application/x-ndjson" {
+		t.Errorf("Unexpected Content-Type %q", got)
+	}
+	if got := req.Header.Get("X-Source"); got != "omni-test" {
+		t.Errorf("Unexpected X-Source %q", got)
+	}
+
+	stats := backend.GetStats()
+	if stats.WriteCount != 2 || stats.Requests != 1 || stats.ErrorCount != 0 || stats.TotalWriteTime <= 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestHTTPBackend_JSONArrayGzipBasicAuth(t *testing.T) {
+	ingest := &fakeIngest{}
+	server := httptest.NewServer(ingest)
+	defer server.Close()
+
+	backend := newHTTPBackend(t, backends.HTTPConfig{
+		URL:      server.URL,
+		Format:   backends.HTTPFormatJSONArray,
+		Compress: true,
+		Username: "omni",
+		Password: "pa:ss",
+	})
+
+	_, _ = backend.Write([]byte(`{"msg":"json"}`))
+	_, _ = backend.Write([]byte("plain text\n"))
+	if err := backend.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	bodies := ingest.received()
+	if len(bodies) != 1 {
+		t.Fatalf("Expected 1 request, got %d", len(bodies))
+	}
+	var batch []interface{}
+	if err := json.Unmarshal([]byte(bodies[0]), &batch); err != nil {
+		t.Fatalf("Body is not a JSON array: %v", err)
+	}
+	if len(batch) != 2 || batch[1] != "plain text" {
+		t.Errorf("Unexpected batch %q", bodies[0])
+	}
+
+	req := ingest.requests[0]
+	if user, pass, ok := req.BasicAuth(); !ok || user != "omni" || pass != "pa:ss" {
+		t.Errorf("Unexpected basic auth %q %q", user, pass)
+	}
+	if req.Header.Get("Content-Encoding") != "gzip" || req.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Unexpected headers %v", req.Header)
+	}
+}
+
+func TestHTTPBackend_Batching(t *testing.T) {
+	ingest := &fakeIngest{}
+	server := httptest.NewServer(ingest)
+	defer server.Close()
+
+	backend := newHTTPBackend(t, backends.HTTPConfig{
+		URL:           server.URL,
+		BatchSize:     3,
+		BatchBytes:    25,
+		FlushInterval: 20 * time.Millisecond,
+	})
+
+	// A full batch is sent without waiting for Flush
+	for _, entry := range []string{"a", "b", "c"} {
+		_, _ = backend.Write([]byte(entry))
+	}
+	// So is a batch reaching the byte limit
+	_, _ = backend.Write([]byte(strings.Repeat("x", 30)))
+	// A partial batch is sent by the flush interval
+	_, _ = backend.Write([]byte("tail"))
+
+	deadline := time.Now().Add(3 * time.Second)
+	for len(ingest.received()) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected 3 requests, got %q", ingest.received())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	bodies := strings.Join(ingest.received(), "|")
+	for _, expected := range []string{"a\nb\nc\n", strings.Repeat("x", 30) + "\n", "tail\n"} {
+		if !strings.Contains(bodies, expected) {
+			t.Errorf("Missing batch %q in %q", expected, bodies)
+		}
+	}
+}
+
+func TestHTTPBackend_RetryAfter(t *testing.T) {
+	ingest := &fakeIngest{
+		statuses: []int{http.StatusTooManyRequests, http.StatusServiceUnavailable},
+		header:   http.Header{"Retry-After": []string{"1"}},
+	}
+	server := httptest.NewServer(ingest)
+	defer server.Close()
+
+	backend := newHTTPBackend(t, backends.HTTPConfig{
+		URL:        server.URL,
+		MinBackoff: 10 * time.Millisecond,
+		MaxBackoff: 200 * time.Millisecond,
+	})
+
+	_, _ = backend.Write([]byte("retried"))
+	start := time.Now()
+	if err := backend.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	// Retry-After is honoured up to MaxBackoff
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond || elapsed > 900*time.Millisecond {
+		t.Errorf("Expected two retries delayed by MaxBackoff, took %v", elapsed)
+	}
+
+	stats := backend.GetStats()
+	if stats.WriteCount != 1 || stats.Requests != 3 || stats.Retries != 2 || stats.ErrorCount != 2 || stats.Dropped != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestHTTPBackend_Failures(t *testing.T) {
+	ingest := &fakeIngest{statuses: []int{http.StatusBadRequest, 500, 500, 500}}
+	server := httptest.NewServer(ingest)
+	defer server.Close()
+
+	backend := newHTTPBackend(t, backends.HTTPConfig{
+		URL:        server.URL,
+		MaxRetries: 2,
+		MinBackoff: time.Millisecond,
+	})
+
+	// Client errors are not retried
+	_, _ = backend.Write([]byte("bad"))
+	if err := backend.Flush(); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("Expected 400 error, got %v", err)
+	}
+	if stats := backend.GetStats(); stats.Requests != 1 || stats.Dropped != 1 {
+		t.Errorf("Unexpected stats after 400: %+v", stats)
+	}
+
+	// Server errors are retried MaxRetries times
+	_, _ = backend.Write([]byte("unlucky"))
+	if err := backend.Flush(); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("Expected 500 error, got %v", err)
+	}
+	stats := backend.GetStats()
+	if stats.Requests != 4 || stats.Retries != 2 || stats.Dropped != 2 || stats.ErrorCount != 4 || stats.Buffered != 0 {
+		t.Errorf("Unexpected stats after 500s: %+v", stats)
+	}
+
+	// Errors are reported once
+	if err := backend.Flush(); err != nil {
+		t.Errorf("Expected no error from a second Flush, got %v", err)
+	}
+}
+
+func TestHTTPBackend_MaxInFlight(t *testing.T) {
+	ingest := &fakeIngest{delay: 20 * time.Millisecond}
+	server := httptest.NewServer(ingest)
+	defer server.Close()
+
+	backend := newHTTPBackend(t, backends.HTTPConfig{
+		URL:         server.URL,
+		BatchSize:   1,
+		MaxInFlight: 2,
+	})
+
+	for i := 0; i < 8; i++ {
+		_, _ = backend.Write([]byte("entry"))
+	}
+	if err := backend.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	if got := len(ingest.received()); got != 8 {
+		t.Errorf("Expected 8 requests, got %d", got)
+	}
+	if max := atomic.LoadInt32(&ingest.maxSeen); max > 2 {
+		t.Errorf("Expected at most 2 concurrent requests, saw %d", max)
+	}
+}
+
+func TestHTTPBackend_Close(t *testing.T) {
+	ingest := &fakeIngest{}
+	server := httptest.NewServer(ingest)
+	defer server.Close()
+
+	if _, err := backends.NewHTTPBackend(backends.HTTPConfig{URL: "ftp://example.com"}); err == nil {
+		t.Error("Expected error for a non-HTTP URL")
+	}
+
+	backend, err := backends.NewHTTPBackend(backends.HTTPConfig{URL: server.URL, FlushInterval: -1})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	_, _ = backend.Write([]byte("last words"))
+	if err := backend.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if bodies := ingest.received(); len(bodies) != 1 || bodies[0] != "last words\n" {
+		t.Errorf("Expected Close to deliver the queue, got %q", bodies)
+	}
+	if _, err := backend.Write([]byte("too late")); err == nil {
+		t.Error("Expected error writing to a closed backend")
+	}
+}
+
+func TestHTTPBackend_WriteBatchMessage(t *testing.T) {
+	ingest := &fakeIngest{}
+	server := httptest.NewServer(ingest)
+	defer server.Close()
+
+	backend := newHTTPBackend(t, backends.HTTPConfig{URL: server.URL, Format: backends.HTTPFormatJSONArray})
+
+	formatter := formatters.NewJSONFormatter()
+	formatter.Options.IncludeTime = false
+	formatter.SetIndentation(" ")
+	for _, text := range []string{"one", "two"} {
+		msg := types.LogMessage{Level: formatters.LevelInfo, Format: text}
+		entry, _ := formatter.Format(msg)
+		if _, err := backend.WriteBatchMessage(formatter, msg, entry); err != nil {
+			t.Fatalf("WriteBatchMessage failed: %v", err)
+		}
+	}
+	if err := backend.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	// The batch is the formatter's own array
+	expected := "[\n {\n  \"level\": \"info\",\n  \"message\": \"one\"\n },\n {\n  \"level\": \"info\",\n  \"message\": \"two\"\n }\n]"
+	if bodies := ingest.received(); len(bodies) != 1 || bodies[0] != expected {
+		t.Fatalf("Unexpected bodies %q", bodies)
+	}
+
+	// Batches mixing plain entries fall back to joining them
+	msg := types.LogMessage{Level: formatters.LevelWarn, Format: "three"}
+	entry, _ := formatter.Format(msg)
+	_, _ = backend.WriteBatchMessage(formatter, msg, entry)
+	_, _ = backend.Write([]byte("plain\n"))
+	if err := backend.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	var batch []interface{}
+	bodies := ingest.received()
+	if err := json.Unmarshal([]byte(bodies[1]), &batch); err != nil || len(batch) != 2 || batch[1] != "plain" {
+		t.Errorf("Unexpected batch %q: %v", bodies[1], err)
+	}
+}
@@ -0,0 +1,475 @@
+package backends
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// httpBatcher queues entries and POSTs them in batches. Entries are opaque to
+// the batcher; the owning backend encodes a batch into the request body. A
+// batch is sent once it reaches batchSize entries or batchBytes bytes, when the flush interval
+// elapses, or on Flush. Up to maxInFlight requests run concurrently; Write
+// blocks while all are busy. Requests failing with a network error, 429 or
+// 5xx are retried with exponential backoff, honouring Retry-After up to
+// maxBackoff; other failures drop the batch.
+type httpBatcher struct {
+	client     *http.Client
+	url        string
+	header     http.Header
+	compress   bool
+	batchSize  int
+	batchBytes int
+	interval   time.Duration
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	inFlight   chan struct{} // Holds a token per running request
+	encode     func(batch []interface{}) ([]byte, error)
+	// check inspects responses other than 429 and 5xx for APIs that report
+	// per-entry failures, returning the entries to retry and the number
+	// rejected for good. Nil accepts 2xx responses and drops the batch on
+	// any other status.
+	check func(resp *http.Response, body []byte, batch []interface{}) (retry []interface{}, rejected int, err error)
+	// confirmed leaves counting accepted entries as written to the owner,
+	// for APIs that confirm delivery after the response
+	confirmed bool
+
+	mu           sync.Mutex
+	pending      []interface{}
+	pendingBytes int
+	sending      int // Entries in running requests
+	errs         []error
+	closed       bool
+	done         chan struct{}
+	stopped      chan struct{}
+
+	writeCount     uint64
+	bytesWritten   uint64
+	errorCount     uint64
+	lastError      time.Time
+	dropped        uint64
+	requests       uint64
+	retries        uint64
+	totalWriteTime time.Duration
+	maxWriteTime   time.Duration
+}
+
+// httpMaxReportedErrors bounds the delivery failures kept for the next flush
+const httpMaxReportedErrors = 10
+
+// httpBatchConfig holds the batching settings shared by the HTTP backends
+type httpBatchConfig struct {
+	client        *http.Client
+	url           string
+	header        http.Header
+	compress      bool
+	batchSize     int
+	batchBytes    int
+	flushInterval time.Duration
+	maxRetries    int
+	minBackoff    time.Duration
+	maxBackoff    time.Duration
+	maxInFlight   int
+}
+
+// newHTTPBatcher creates a batcher, applying defaults, and starts its flush
+// interval timer
+func newHTTPBatcher(config httpBatchConfig, encode func([]interface{}) ([]byte, error)) *httpBatcher {
+	if config.client == nil {
+		config.client = &http.Client{Timeout: DefaultHTTPTimeout}
+	}
+	if config.header == nil {
+		config.header = http.Header{}
+	}
+	if config.batchSize <= 0 {
+		config.batchSize = DefaultHTTPBatchSize
+	}
+	if config.batchBytes <= 0 {
+		config.batchBytes = DefaultHTTPBatchBytes
+	}
+	if config.flushInterval == 0 {
+		config.flushInterval = DefaultHTTPFlushInterval
+	}
+	if config.maxRetries == 0 {
+		config.maxRetries = DefaultHTTPMaxRetries
+	}
+	if config.maxRetries < 0 {
+		config.maxRetries = 0
+	}
+	if config.minBackoff <= 0 {
+		config.minBackoff = DefaultHTTPMinBackoff
+	}
+	if config.maxBackoff <= 0 {
+		config.maxBackoff = DefaultHTTPMaxBackoff
+	}
+	if config.maxBackoff < config.minBackoff {
+		config.maxBackoff = config.minBackoff
+	}
+	if config.maxInFlight <= 0 {
+		config.maxInFlight = DefaultHTTPMaxInFlight
+	}
+
+	b := &httpBatcher{
+		client:     config.client,
+		url:        config.url,
+		header:     config.header,
+		compress:   config.compress,
+		batchSize:  config.batchSize,
+		batchBytes: config.batchBytes,
+		interval:   config.flushInterval,
+		maxRetries: config.maxRetries,
+		minBackoff: config.minBackoff,
+		maxBackoff: config.maxBackoff,
+		inFlight:   make(chan struct{}, config.maxInFlight),
+		encode:     encode,
+		done:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+
+	if b.interval > 0 {
+		go b.flushLoop()
+	} else {
+		close(b.stopped)
+	}
+
+	return b
+}
+
+// flushLoop sends partial batches every flush interval until the batcher is closed
+func (b *httpBatcher) flushLoop() {
+	defer close(b.stopped)
+
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			b.mu.Lock()
+			batch := b.take()
+			b.mu.Unlock()
+			if batch != nil {
+				b.dispatch(batch)
+			}
+		case <-b.done:
+			return
+		}
+	}
+}
+
+// add queues an entry of about size bytes, sending the batch once it is full
+func (b *httpBatcher) add(entry interface{}, size int) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return os.ErrClosed
+	}
+
+	b.pending = append(b.pending, entry)
+	b.pendingBytes += size
+
+	var batch []interface{}
+	if len(b.pending) >= b.batchSize || b.pendingBytes >= b.batchBytes {
+		batch = b.take()
+	}
+	b.mu.Unlock()
+
+	if batch != nil {
+		b.dispatch(batch)
+	}
+	return nil
+}
+
+// take removes and returns the queued entries; the caller holds mu
+func (b *httpBatcher) take() []interface{} {
+	if len(b.pending) == 0 {
+		return nil
+	}
+	batch := b.pending
+	b.pending = nil
+	b.pendingBytes = 0
+	b.sending += len(batch)
+	return batch
+}
+
+// dispatch sends a batch in the background once a request slot is free
+func (b *httpBatcher) dispatch(batch []interface{}) {
+	b.inFlight <- struct{}{}
+	go b.send(batch)
+}
+
+// resend sends a batch again once a request slot is free, counting a retry,
+// and waits for its request
+func (b *httpBatcher) resend(batch []interface{}) {
+	b.mu.Lock()
+	b.sending += len(batch)
+	b.retries++
+	b.mu.Unlock()
+
+	b.inFlight <- struct{}{}
+	b.send(batch)
+}
+
+// send delivers a batch taken by take or resend and frees its request slot
+func (b *httpBatcher) send(batch []interface{}) {
+	defer func() { <-b.inFlight }()
+	err := b.deliver(batch)
+
+	b.mu.Lock()
+	b.sending -= len(batch)
+	b.mu.Unlock()
+	b.report(err)
+}
+
+// report keeps a delivery failure for the next flush
+func (b *httpBatcher) report(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err != nil && len(b.errs) < httpMaxReportedErrors {
+		b.errs = append(b.errs, err)
+	}
+}
+
+// flush sends the queued entries, waits for all running requests and returns
+// the delivery failures since the last flush
+func (b *httpBatcher) flush() error {
+	b.mu.Lock()
+	batch := b.take()
+	b.mu.Unlock()
+	if batch != nil {
+		b.dispatch(batch)
+	}
+
+	// Holding every request slot means no request is running
+	for i := 0; i < cap(b.inFlight); i++ {
+		b.inFlight <- struct{}{}
+	}
+	for i := 0; i < cap(b.inFlight); i++ {
+		<-b.inFlight
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	err := errors.Join(b.errs...)
+	b.errs = nil
+	return err
+}
+
+// close stops the flush interval timer and sends the queued entries
+func (b *httpBatcher) close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	b.mu.Unlock()
+
+	close(b.done)
+	<-b.stopped
+	return b.flush()
+}
+
+// deliver POSTs a batch, retrying failed requests and the entries a
+// partially successful response reports as failed
+func (b *httpBatcher) deliver(batch []interface{}) error {
+	backoff := b.minBackoff
+
+	for attempt := 0; ; attempt++ {
+		body, err := b.encode(batch)
+		if err != nil {
+			b.fail(len(batch))
+			return fmt.Errorf("encode batch: %w", err)
+		}
+
+		retry, delay, err := b.post(body, batch)
+		if len(retry) == 0 {
+			return err
+		}
+		if attempt >= b.maxRetries {
+			b.fail(len(retry))
+			return err
+		}
+
+		batch = retry
+		if delay < backoff {
+			delay = backoff
+		}
+		if delay > b.maxBackoff {
+			delay = b.maxBackoff
+		}
+		backoff *= 2
+
+		b.mu.Lock()
+		b.retries++
+		b.mu.Unlock()
+
+		time.Sleep(delay)
+	}
+}
+
+// post sends one request. On failure it returns the entries worth retrying
+// and the delay the server asked for; entries failing for good are dropped.
+func (b *httpBatcher) post(body []byte, batch []interface{}) ([]interface{}, time.Duration, error) {
+	req, err := b.newRequest(body)
+	if err != nil {
+		b.fail(len(batch))
+		return nil, 0, err
+	}
+
+	start := time.Now()
+	resp, err := b.client.Do(req)
+	latency := time.Since(start)
+
+	b.mu.Lock()
+	b.requests++
+	b.totalWriteTime += latency
+	if latency > b.maxWriteTime {
+		b.maxWriteTime = latency
+	}
+	b.mu.Unlock()
+
+	if err != nil {
+		b.failed()
+		return batch, 0, fmt.Errorf("post %s: %w", b.url, err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		b.failed()
+		return batch, retryAfter(resp.Header.Get("Retry-After")), httpStatusError(resp, respBody)
+	case b.check == nil && (resp.StatusCode < 200 || resp.StatusCode >= 300):
+		b.failed()
+		b.fail(len(batch))
+		return nil, 0, httpStatusError(resp, respBody)
+	}
+
+	var retry []interface{}
+	var rejected int
+	if b.check != nil {
+		retry, rejected, err = b.check(resp, respBody, batch)
+	}
+	if accepted := len(batch) - len(retry) - rejected; accepted > 0 && !b.confirmed {
+		b.delivered(accepted, len(body))
+	}
+	if rejected > 0 {
+		b.fail(rejected)
+	}
+	if err == nil && (len(retry) > 0 || rejected > 0) {
+		err = fmt.Errorf("%d of %d entries failed", len(retry)+rejected, len(batch))
+	}
+	if err != nil {
+		b.failed()
+	}
+	if rejected > 0 && len(retry) > 0 {
+		// Report the rejections now; deliver reports the outcome of the retry
+		b.report(err)
+	}
+	return retry, 0, err
+}
+
+// newRequest builds the POST request, compressing the body if configured
+func (b *httpBatcher) newRequest(body []byte) (*http.Request, error) {
+	if b.compress {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(body); err != nil {
+			return nil, fmt.Errorf("compress batch: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("compress batch: %w", err)
+		}
+		body = buf.Bytes()
+	}
+
+	req, err := http.NewRequest(http.MethodPost, b.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	for key, values := range b.header {
+		req.Header[key] = values
+	}
+	if b.compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	return req, nil
+}
+
+// delivered records entries accepted by the server
+func (b *httpBatcher) delivered(count, size int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.writeCount += uint64(count)  // #nosec G115 - count is never negative
+	b.bytesWritten += uint64(size) // #nosec G115 - size is never negative
+}
+
+// failed records a failed request
+func (b *httpBatcher) failed() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.errorCount++
+	b.lastError = time.Now()
+}
+
+// fail records entries given up on
+func (b *httpBatcher) fail(count int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.dropped += uint64(count) // #nosec G115 - count is never negative
+}
+
+// stats returns the delivery statistics
+func (b *httpBatcher) stats(path string) BackendStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return BackendStats{
+		Path:           path,
+		WriteCount:     b.writeCount,
+		BytesWritten:   b.bytesWritten,
+		ErrorCount:     b.errorCount,
+		LastError:      b.lastError,
+		TotalWriteTime: b.totalWriteTime,
+		MaxWriteTime:   b.maxWriteTime,
+		Buffered:       len(b.pending) + b.sending,
+		Dropped:        b.dropped,
+		Requests:       b.requests,
+		Retries:        b.retries,
+	}
+}
+
+// httpStatusError describes a failed response, including the start of its body
+func httpStatusError(resp *http.Response, body []byte) error {
+	body = bytes.TrimSpace(body)
+	if len(body) > 200 {
+		body = body[:200]
+	}
+	if len(body) == 0 {
+		return fmt.Errorf("server returned %s", resp.Status)
+	}
+	return fmt.Errorf("server returned %s: %s", resp.Status, body)
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date
+func retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
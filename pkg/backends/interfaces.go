@@ -3,6 +3,9 @@ package backends
 import (
 	"bufio"
 	"time"
+
+	"github.com/wayneeseguin/omni/pkg/formatters"
+	"github.com/wayneeseguin/omni/pkg/types"
 )
 
 // Backend interface for pluggable log backends
@@ -26,6 +29,26 @@ type Backend interface {
 	GetStats() BackendStats
 }
 
+// BatchingBackend is implemented by backends that deliver entries in batches
+// on their own schedule. Flushing after every Write would send each entry in
+// a request of its own, so callers flush only on Sync and Close.
+type BatchingBackend interface {
+	Backend
+
+	// Batching reports whether Flush should be deferred to Sync and Close
+	Batching() bool
+}
+
+// BatchMessageWriter is implemented by batching backends that can encode a
+// batch with the formatter's FormatBatch rather than by joining entries
+// formatted one at a time. Loggers whose formatter is a BatchFormatter call
+// WriteBatchMessage instead of Write for such backends.
+type BatchMessageWriter interface {
+	// WriteBatchMessage queues msg, formatted alone as entry, for a batch
+	// encoded by formatter
+	WriteBatchMessage(formatter formatters.BatchFormatter, msg types.LogMessage, entry []byte) (int, error)
+}
+
 // DestinationInfo provides information about a destination
 type DestinationInfo struct {
 	Name         string
@@ -47,6 +70,11 @@ type BackendStats struct {
 	LastError      time.Time
 	TotalWriteTime time.Duration
 	MaxWriteTime   time.Duration
+	Buffered       int    // Entries queued for a network destination
+	Dropped        uint64 // Entries discarded because the queue was full
+	Reconnects     uint64 // Connections re-established after a failure
+	Requests       uint64 // Requests sent by an HTTP destination
+	Retries        uint64 // Requests retried after a failure
 }
 
 // DestinationInterface represents a log output destination
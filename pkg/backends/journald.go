@@ -0,0 +1,275 @@
+package backends
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultJournaldSocket is the socket of the journal native protocol
+const DefaultJournaldSocket = "/run/systemd/journal/socket"
+
+// journaldMaxFieldName is the longest field name journald accepts
+const journaldMaxFieldName = 64
+
+// journaldFieldPrefix is added to entry fields whose names start with a
+// digit or collide with the fields set by the backend
+const journaldFieldPrefix = "FIELD_"
+
+// JournaldConfig configures a journald backend
+type JournaldConfig struct {
+	Socket     string // Journal socket; empty = DefaultJournaldSocket
+	Identifier string // SYSLOG_IDENTIFIER of every entry; empty = the program name
+}
+
+// JournaldBackend writes entries to the systemd journal with the native
+// protocol, so entry fields can be searched with journalctl. Field names are
+// upper-cased, the level is sent as PRIORITY and the source location, when
+// known, as CODE_FILE and CODE_LINE. Entries too large for a datagram are
+// passed to journald in a sealed memory file on Linux.
+type JournaldBackend struct {
+	socket     string
+	identifier string
+	conn       *net.UnixConn
+	mu         sync.Mutex
+
+	writeCount     uint64
+	bytesWritten   uint64
+	errorCount     uint64
+	lastError      time.Time
+	totalWriteTime time.Duration
+	maxWriteTime   time.Duration
+}
+
+// NewJournaldBackend connects to the journal socket
+func NewJournaldBackend(config JournaldConfig) (*JournaldBackend, error) {
+	jb := &JournaldBackend{
+		socket:     config.Socket,
+		identifier: config.Identifier,
+	}
+	if jb.socket == "" {
+		jb.socket = DefaultJournaldSocket
+	}
+	if jb.identifier == "" {
+		jb.identifier = filepath.Base(os.Args[0])
+	}
+
+	if err := jb.dial(); err != nil {
+		return nil, fmt.Errorf("connect to journald: %w", err)
+	}
+
+	return jb, nil
+}
+
+// dial connects to the journal socket
+func (jb *JournaldBackend) dial() error {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: jb.socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	jb.conn = conn
+	return nil
+}
+
+// WriteRecord sends a log record as a journal entry
+func (jb *JournaldBackend) WriteRecord(rec Record) (int, error) {
+	fields := map[string]string{
+		"MESSAGE":           rec.Message,
+		"PRIORITY":          strconv.Itoa(journaldPriority(rec.Level)),
+		"SYSLOG_IDENTIFIER": jb.identifier,
+	}
+	if rec.SourceFile != "" {
+		fields["CODE_FILE"] = rec.SourceFile
+		fields["CODE_LINE"] = strconv.Itoa(rec.SourceLine)
+	}
+
+	var buf bytes.Buffer
+	for _, key := range sortedStringKeys(fields) {
+		writeJournaldField(&buf, key, fields[key])
+	}
+	for _, key := range sortedFieldKeys(rec.Fields) {
+		name := JournaldFieldName(key)
+		if name == "" {
+			continue
+		}
+		if _, reserved := fields[name]; reserved {
+			name = journaldFieldPrefix + name
+		}
+		writeJournaldField(&buf, name, syslogFieldValue(rec.Fields[key]))
+	}
+
+	return jb.send(buf.Bytes())
+}
+
+// Write sends a formatted entry as the message of an informational journal entry
+func (jb *JournaldBackend) Write(entry []byte) (int, error) {
+	var buf bytes.Buffer
+	writeJournaldField(&buf, "MESSAGE", strings.TrimSuffix(string(entry), "\n"))
+	writeJournaldField(&buf, "PRIORITY", strconv.Itoa(SyslogSeverityInfo))
+	writeJournaldField(&buf, "SYSLOG_IDENTIFIER", jb.identifier)
+
+	if _, err := jb.send(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(entry), nil
+}
+
+// send writes one journal entry, passing entries too large for a datagram
+// as a file descriptor and reconnecting once if journald was restarted
+func (jb *JournaldBackend) send(data []byte) (int, error) {
+	jb.mu.Lock()
+	defer jb.mu.Unlock()
+
+	if jb.conn == nil {
+		return 0, os.ErrClosed
+	}
+
+	start := time.Now()
+	_, err := jb.conn.Write(data)
+	if errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS) {
+		err = sendJournaldFD(jb.conn, data)
+	} else if err != nil {
+		_ = jb.conn.Close()
+		if err = jb.dial(); err == nil {
+			_, err = jb.conn.Write(data)
+		}
+	}
+
+	duration := time.Since(start)
+	jb.totalWriteTime += duration
+	if duration > jb.maxWriteTime {
+		jb.maxWriteTime = duration
+	}
+
+	if err != nil {
+		jb.errorCount++
+		jb.lastError = time.Now()
+		return 0, fmt.Errorf("write journal entry: %w", err)
+	}
+
+	jb.writeCount++
+	jb.bytesWritten += uint64(len(data))
+	return len(data), nil
+}
+
+// writeJournaldField appends a field in the native protocol: KEY=value for
+// single-line values, otherwise the key, the value length as a 64-bit
+// little-endian integer and the value
+func writeJournaldField(buf *bytes.Buffer, key, value string) {
+	buf.WriteString(key)
+	if !strings.Contains(value, "\n") {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+
+	buf.WriteByte('\n')
+	_ = binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// JournaldFieldName converts a field name to a journal field name: letters
+// are upper-cased, other characters except digits become underscores,
+// leading underscores (reserved for trusted fields) are dropped and names
+// starting with a digit are prefixed with FIELD_. Empty names stay empty.
+func JournaldFieldName(key string) string {
+	name := []byte(strings.TrimLeft(key, "_"))
+	for i, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z':
+			name[i] = c - 'a' + 'A'
+		case c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_':
+		default:
+			name[i] = '_'
+		}
+	}
+
+	result := strings.TrimLeft(string(name), "_")
+	if result != "" && result[0] >= '0' && result[0] <= '9' {
+		result = journaldFieldPrefix + result
+	}
+	if len(result) > journaldMaxFieldName {
+		result = result[:journaldMaxFieldName]
+	}
+	return result
+}
+
+// journaldPriority maps a level name to its syslog severity
+func journaldPriority(level string) int {
+	switch level {
+	case "error":
+		return SyslogSeverityError
+	case "warn":
+		return SyslogSeverityWarning
+	case "info":
+		return SyslogSeverityInfo
+	default:
+		return SyslogSeverityDebug
+	}
+}
+
+// sortedStringKeys returns the keys of m in order
+func sortedStringKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Flush does nothing as entries are sent as they are written
+func (jb *JournaldBackend) Flush() error {
+	return nil
+}
+
+// Close closes the connection to journald
+func (jb *JournaldBackend) Close() error {
+	jb.mu.Lock()
+	defer jb.mu.Unlock()
+
+	if jb.conn == nil {
+		return nil
+	}
+	err := jb.conn.Close()
+	jb.conn = nil
+	return err
+}
+
+// SupportsAtomic returns true as each entry is sent in a single datagram
+func (jb *JournaldBackend) SupportsAtomic() bool {
+	return true
+}
+
+// Sync does nothing as entries are sent as they are written
+func (jb *JournaldBackend) Sync() error {
+	return nil
+}
+
+// GetStats returns backend statistics
+func (jb *JournaldBackend) GetStats() BackendStats {
+	jb.mu.Lock()
+	defer jb.mu.Unlock()
+
+	return BackendStats{
+		Path:           "journald://" + jb.socket,
+		WriteCount:     jb.writeCount,
+		BytesWritten:   jb.bytesWritten,
+		ErrorCount:     jb.errorCount,
+		LastError:      jb.lastError,
+		TotalWriteTime: jb.totalWriteTime,
+		MaxWriteTime:   jb.maxWriteTime,
+	}
+}
@@ -0,0 +1,75 @@
+package backends
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// sendJournaldFD passes an entry too large for a datagram to journald as a
+// sealed memfd, or an unlinked file in /dev/shm where memfd is unavailable
+func sendJournaldFD(conn *net.UnixConn, data []byte) error {
+	file, err := journaldMemfd(data)
+	if err != nil {
+		if file, err = journaldTempFile(data); err != nil {
+			return err
+		}
+	}
+	defer file.Close()
+
+	// WriteMsgUnix refuses connected datagram sockets, so send on the descriptor
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	rights := unix.UnixRights(int(file.Fd())) // #nosec G115 - file descriptors fit in an int
+	var sendErr error
+	if err := raw.Write(func(fd uintptr) bool {
+		sendErr = unix.Sendmsg(int(fd), nil, rights, nil, 0) // #nosec G115 - file descriptors fit in an int
+		return sendErr != unix.EAGAIN
+	}); err != nil {
+		return err
+	}
+	if sendErr != nil {
+		return fmt.Errorf("pass journal entry descriptor: %w", sendErr)
+	}
+	return nil
+}
+
+// journaldMemfd writes data to a memfd sealed against further changes, as
+// journald requires for memfds
+func journaldMemfd(data []byte) (*os.File, error) {
+	fd, err := unix.MemfdCreate("journal-entry", unix.MFD_CLOEXEC|unix.MFD_ALLOW_SEALING)
+	if err != nil {
+		return nil, err
+	}
+	file := os.NewFile(uintptr(fd), "journal-entry") // #nosec G115 - memfd_create returns a valid descriptor
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return nil, err
+	}
+	seals := unix.F_SEAL_SHRINK | unix.F_SEAL_GROW | unix.F_SEAL_WRITE | unix.F_SEAL_SEAL
+	if _, err := unix.FcntlInt(file.Fd(), unix.F_ADD_SEALS, seals); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// journaldTempFile writes data to an unlinked temporary file in /dev/shm
+func journaldTempFile(data []byte) (*os.File, error) {
+	file, err := os.CreateTemp("/dev/shm", "journal-entry-")
+	if err != nil {
+		return nil, fmt.Errorf("create journal entry file: %w", err)
+	}
+	_ = os.Remove(file.Name())
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("write journal entry file: %w", err)
+	}
+	return file, nil
+}
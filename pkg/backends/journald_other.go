@@ -0,0 +1,14 @@
+//go:build !linux
+
+package backends
+
+import (
+	"errors"
+	"net"
+)
+
+// sendJournaldFD reports that entries too large for a datagram cannot be
+// passed to journald, which only runs on Linux
+func sendJournaldFD(conn *net.UnixConn, data []byte) error {
+	return errors.New("journal entry too large for a datagram")
+}
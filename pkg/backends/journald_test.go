@@ -0,0 +1,195 @@
+package backends_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/backends"
+)
+
+// fakeJournal stands in for journald on a unixgram socket
+type fakeJournal struct {
+	conn *net.UnixConn
+	path string
+}
+
+func startFakeJournal(t *testing.T) *fakeJournal {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "journal.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unixgram sockets unavailable: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &fakeJournal{conn: conn, path: path}
+}
+
+// read receives one entry, reading it from a passed file descriptor when the
+// datagram carries one
+func (j *fakeJournal) read(t *testing.T) map[string]string {
+	t.Helper()
+
+	buf := make([]byte, 1<<16)
+	oob := make([]byte, 1024)
+	_ = j.conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	n, oobn, _, _, err := j.conn.ReadMsgUnix(buf, oob)
+	if err != nil {
+		t.Fatalf("ReadMsgUnix failed: %v", err)
+	}
+	data := buf[:n]
+
+	if oobn > 0 {
+		messages, err := syscall.ParseSocketControlMessage(oob[:oobn])
+		if err != nil || len(messages) != 1 {
+			t.Fatalf("Invalid control message: %v", err)
+		}
+		fds, err := syscall.ParseUnixRights(&messages[0])
+		if err != nil || len(fds) != 1 {
+			t.Fatalf("Invalid descriptors: %v", err)
+		}
+		file := os.NewFile(uintptr(fds[0]), "entry")
+		defer file.Close()
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			t.Fatalf("Seek failed: %v", err)
+		}
+		if data, err = io.ReadAll(file); err != nil {
+			t.Fatalf("Failed to read the passed entry: %v", err)
+		}
+	}
+
+	return parseJournalEntry(t, data)
+}
+
+// parseJournalEntry decodes the journal native protocol
+func parseJournalEntry(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+
+	fields := make(map[string]string)
+	for len(data) > 0 {
+		end := bytes.IndexByte(data, '\n')
+		if end < 0 {
+			t.Fatalf("Unterminated field %q", data)
+		}
+		line := data[:end]
+		if key, value, ok := bytes.Cut(line, []byte("=")); ok {
+			fields[string(key)] = string(value)
+			data = data[end+1:]
+			continue
+		}
+
+		size := binary.LittleEndian.Uint64(data[end+1 : end+9])
+		fields[string(line)] = string(data[end+9 : end+9+int(size)])
+		data = data[end+9+int(size)+1:]
+	}
+	return fields
+}
+
+func TestJournaldBackend_WriteRecord(t *testing.T) {
+	journal := startFakeJournal(t)
+
+	backend, err := backends.NewJournaldBackend(backends.JournaldConfig{Socket: journal.path, Identifier: "checkout"})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	defer backend.Close()
+
+	rec := backends.Record{
+		Level:      "warn",
+		Message:    "payment retried",
+		SourceFile: "charge.go",
+		SourceLine: 42,
+		Fields: map[string]interface{}{
+			"order.id": "A-17",
+			"attempt":  2,
+			"_secret":  "x",
+			"message":  "shadowed",
+			"trace":    "line one\nline two",
+		},
+	}
+	if _, err := backend.WriteRecord(rec); err != nil {
+		t.Fatalf("WriteRecord failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"MESSAGE":           "payment retried",
+		"PRIORITY":          "4",
+		"SYSLOG_IDENTIFIER": "checkout",
+		"CODE_FILE":         "charge.go",
+		"CODE_LINE":         "42",
+		"ORDER_ID":          "A-17",
+		"ATTEMPT":           "2",
+		"SECRET":            "x",
+		"FIELD_MESSAGE":     "shadowed",
+		"TRACE":             "line one\nline two",
+	}
+	if fields := journal.read(t); !reflect.DeepEqual(fields, expected) {
+		t.Errorf("Expected %v, got %v", expected, fields)
+	}
+
+	// Plain entries become informational messages
+	if _, err := backend.Write([]byte("plain entry\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if fields := journal.read(t); fields["MESSAGE"] != "plain entry" || fields["PRIORITY"] != "6" {
+		t.Errorf("Unexpected entry %v", fields)
+	}
+
+	if stats := backend.GetStats(); stats.Path != "journald://"+journal.path || stats.WriteCount != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestJournaldBackend_LargeEntry(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("descriptor passing is only used on Linux")
+	}
+	journal := startFakeJournal(t)
+
+	backend, err := backends.NewJournaldBackend(backends.JournaldConfig{Socket: journal.path})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	defer backend.Close()
+
+	// Larger than the socket send buffer, so sent as a memfd
+	message := strings.Repeat("x", 4<<20)
+	if _, err := backend.WriteRecord(backends.Record{Level: "info", Message: message}); err != nil {
+		t.Fatalf("WriteRecord failed: %v", err)
+	}
+	if fields := journal.read(t); fields["MESSAGE"] != message {
+		t.Errorf("Expected a %d byte message, got %d bytes", len(message), len(fields["MESSAGE"]))
+	}
+}
+
+func TestJournaldFieldName(t *testing.T) {
+	tests := map[string]string{
+		"user_id":               "USER_ID",
+		"http.status":           "HTTP_STATUS",
+		"_hostname":             "HOSTNAME",
+		"2fa":                   "FIELD_2FA",
+		"__":                    "",
+		strings.Repeat("a", 70): strings.Repeat("A", 64),
+		"Content-Type":          "CONTENT_TYPE",
+	}
+	for key, expected := range tests {
+		if got := backends.JournaldFieldName(key); got != expected {
+			t.Errorf("JournaldFieldName(%q) = %q, expected %q", key, got, expected)
+		}
+	}
+}
+
+func TestJournaldBackend_Errors(t *testing.T) {
+	if _, err := backends.NewJournaldBackend(backends.JournaldConfig{Socket: filepath.Join(t.TempDir(), "missing.sock")}); err == nil {
+		t.Error("Expected error for a missing socket")
+	}
+}
@@ -0,0 +1,361 @@
+package backends
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults for LokiConfig
+const (
+	DefaultLokiMaxStreams     = 1000
+	DefaultLokiMaxLabelLength = 128
+)
+
+// lokiPushPath is the path of Loki's push API
+const lokiPushPath = "/loki/api/v1/push"
+
+// LokiConfig configures a Loki backend
+type LokiConfig struct {
+	URL            string            // Loki base URL, or the full push API URL
+	Labels         map[string]string // Static labels added to every stream
+	LabelFields    []string          // Fields promoted to stream labels when present
+	LevelLabel     bool              // Add the entry level as the "level" label
+	MaxStreams     int               // Distinct label sets before new ones lose their field labels; 0 = DefaultLokiMaxStreams
+	MaxLabelLength int               // Longer label values are truncated; 0 = DefaultLokiMaxLabelLength
+	TenantID       string            // Sent as X-Scope-OrgID for multi-tenant Loki
+	BearerToken    string
+	Username       string // Basic auth, used when BearerToken is empty
+	Password       string
+	Compress       bool          // Gzip request bodies
+	BatchSize      int           // Entries per push; 0 = DefaultHTTPBatchSize
+	BatchBytes     int           // Bytes per push; 0 = DefaultHTTPBatchBytes
+	FlushInterval  time.Duration // 0 = DefaultHTTPFlushInterval, negative disables
+	Timeout        time.Duration // 0 = DefaultHTTPTimeout
+	MaxRetries     int           // Retries after 429 or 5xx; 0 = DefaultHTTPMaxRetries, negative disables
+	MinBackoff     time.Duration // 0 = DefaultHTTPMinBackoff
+	MaxBackoff     time.Duration // 0 = DefaultHTTPMaxBackoff
+	TLSConfig      *tls.Config
+	Client         *http.Client
+}
+
+// lokiEntry is a queued entry with its stream labels
+type lokiEntry struct {
+	stream    string // Canonical label set, identifying the stream
+	labels    map[string]string
+	timestamp time.Time
+	line      string
+}
+
+// LokiBackend pushes entries to Grafana Loki. Each entry's stream labels are
+// the static labels, the level and the configured label fields. Once
+// MaxStreams distinct label sets have been seen, entries that would start a
+// new stream keep only the static and level labels, protecting Loki from
+// unbounded label cardinality. Entries are sorted by timestamp within each
+// stream and pushed one request at a time to preserve their order.
+type LokiBackend struct {
+	batcher        *httpBatcher
+	path           string
+	labels         map[string]string
+	labelFields    []string
+	levelLabel     bool
+	maxStreams     int
+	maxLabelLength int
+
+	mu         sync.Mutex
+	streams    map[string]struct{}
+	overflowed uint64
+}
+
+// NewLokiBackend creates a Loki backend and starts its flush interval timer
+func NewLokiBackend(config LokiConfig) (*LokiBackend, error) {
+	endpoint, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Loki URL: %w", err)
+	}
+	if (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid Loki URL %q", config.URL)
+	}
+	if !strings.HasSuffix(endpoint.Path, lokiPushPath) {
+		endpoint.Path = strings.TrimSuffix(endpoint.Path, "/") + lokiPushPath
+	}
+
+	if config.MaxStreams <= 0 {
+		config.MaxStreams = DefaultLokiMaxStreams
+	}
+	if config.MaxLabelLength <= 0 {
+		config.MaxLabelLength = DefaultLokiMaxLabelLength
+	}
+
+	labels := make(map[string]string, len(config.Labels))
+	for name, value := range config.Labels {
+		labels[lokiLabelName(name)] = value
+	}
+	if len(labels) == 0 && len(config.LabelFields) == 0 && !config.LevelLabel {
+		// Loki requires at least one label per stream
+		labels["job"] = "omni"
+	}
+
+	header := http.Header{}
+	header.Set("User-Agent", "omni")
+	header.Set("Content-Type", "application/json")
+	if config.TenantID != "" {
+		header.Set("X-Scope-OrgID", config.TenantID)
+	}
+	switch {
+	case config.BearerToken != "":
+		header.Set("Authorization", "Bearer "+config.BearerToken)
+	case config.Username != "":
+		req := http.Request{Header: http.Header{}}
+		req.SetBasicAuth(config.Username, config.Password)
+		header.Set("Authorization", req.Header.Get("Authorization"))
+	}
+
+	lb := &LokiBackend{
+		labels:         labels,
+		labelFields:    config.LabelFields,
+		levelLabel:     config.LevelLabel,
+		maxStreams:     config.MaxStreams,
+		maxLabelLength: config.MaxLabelLength,
+		streams:        make(map[string]struct{}),
+	}
+
+	pushURL := endpoint.String()
+	endpoint.User = nil
+	lb.path = endpoint.String()
+
+	lb.batcher = newHTTPBatcher(httpBatchConfig{
+		client:        httpClient(config.Client, config.Timeout, config.TLSConfig),
+		url:           pushURL,
+		header:        header,
+		compress:      config.Compress,
+		batchSize:     config.BatchSize,
+		batchBytes:    config.BatchBytes,
+		flushInterval: config.FlushInterval,
+		maxRetries:    config.MaxRetries,
+		minBackoff:    config.MinBackoff,
+		maxBackoff:    config.MaxBackoff,
+		// Concurrent pushes could reach Loki out of order
+		maxInFlight: 1,
+	}, encodeLokiPush)
+	lb.batcher.check = checkLokiResponse
+
+	return lb, nil
+}
+
+// WriteRecord queues a structured entry, deriving its stream labels from
+// the level and fields
+func (lb *LokiBackend) WriteRecord(rec Record) (int, error) {
+	timestamp := rec.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	line := strings.TrimSuffix(string(rec.Line), "\n")
+	if line == "" {
+		line = rec.Message
+	}
+
+	labels, stream := lb.streamLabels(rec.Level, rec.Fields)
+	entry := &lokiEntry{stream: stream, labels: labels, timestamp: timestamp, line: line}
+	if err := lb.batcher.add(entry, len(line)); err != nil {
+		return 0, err
+	}
+	return len(rec.Line), nil
+}
+
+// Write queues an entry with the static labels, timestamped now
+func (lb *LokiBackend) Write(entry []byte) (int, error) {
+	return lb.WriteRecord(Record{Timestamp: time.Now(), Line: entry})
+}
+
+// streamLabels returns the labels for an entry and their canonical form,
+// falling back to the static and level labels once MaxStreams is reached
+func (lb *LokiBackend) streamLabels(level string, fields map[string]interface{}) (map[string]string, string) {
+	labels := make(map[string]string, len(lb.labels)+len(lb.labelFields)+1)
+	for name, value := range lb.labels {
+		labels[name] = value
+	}
+	if lb.levelLabel && level != "" {
+		labels["level"] = level
+	}
+	base := len(labels)
+	for _, field := range lb.labelFields {
+		if value, ok := fields[field]; ok {
+			labels[lokiLabelName(field)] = lb.labelValue(value)
+		}
+	}
+	stream := lokiStreamKey(labels)
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	if _, known := lb.streams[stream]; known {
+		return labels, stream
+	}
+	if len(lb.streams) >= lb.maxStreams && len(labels) > base {
+		lb.overflowed++
+		for _, field := range lb.labelFields {
+			delete(labels, lokiLabelName(field))
+		}
+		for name, value := range lb.labels {
+			labels[name] = value
+		}
+		stream = lokiStreamKey(labels)
+	}
+	lb.streams[stream] = struct{}{}
+	return labels, stream
+}
+
+// labelValue formats a field value as a label value, truncating long values
+func (lb *LokiBackend) labelValue(value interface{}) string {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case fmt.Stringer:
+		s = v.String()
+	default:
+		s = fmt.Sprint(v)
+	}
+	if len(s) > lb.maxLabelLength {
+		s = s[:lb.maxLabelLength]
+	}
+	return s
+}
+
+// Overflowed returns the number of entries whose field labels were dropped
+// because MaxStreams distinct streams had been reached
+func (lb *LokiBackend) Overflowed() uint64 {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return lb.overflowed
+}
+
+// Flush pushes the queued entries and waits for the request to finish,
+// returning the delivery failures since the last Flush
+func (lb *LokiBackend) Flush() error {
+	return lb.batcher.flush()
+}
+
+// Close pushes the queued entries and stops the flush interval timer
+func (lb *LokiBackend) Close() error {
+	return lb.batcher.close()
+}
+
+// Batching returns true as entries are pushed in batches on their own schedule
+func (lb *LokiBackend) Batching() bool {
+	return true
+}
+
+// SupportsAtomic returns false as batches are delivered asynchronously
+func (lb *LokiBackend) SupportsAtomic() bool {
+	return false
+}
+
+// Sync pushes the queued entries
+func (lb *LokiBackend) Sync() error {
+	return lb.Flush()
+}
+
+// GetStats returns backend statistics, as for HTTPBackend
+func (lb *LokiBackend) GetStats() BackendStats {
+	return lb.batcher.stats(lb.path)
+}
+
+// lokiPush is the JSON body of a push request
+type lokiPush struct {
+	Streams []lokiStream `json:"streams"`
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// encodeLokiPush groups a batch into streams, each sorted by timestamp
+func encodeLokiPush(batch []interface{}) ([]byte, error) {
+	byStream := make(map[string][]*lokiEntry)
+	var order []string
+	for _, item := range batch {
+		entry := item.(*lokiEntry)
+		if _, ok := byStream[entry.stream]; !ok {
+			order = append(order, entry.stream)
+		}
+		byStream[entry.stream] = append(byStream[entry.stream], entry)
+	}
+
+	push := lokiPush{Streams: make([]lokiStream, 0, len(order))}
+	for _, key := range order {
+		entries := byStream[key]
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].timestamp.Before(entries[j].timestamp)
+		})
+
+		stream := lokiStream{Stream: entries[0].labels, Values: make([][2]string, len(entries))}
+		for i, entry := range entries {
+			stream.Values[i] = [2]string{strconv.FormatInt(entry.timestamp.UnixNano(), 10), entry.line}
+		}
+		push.Streams = append(push.Streams, stream)
+	}
+
+	return json.Marshal(push)
+}
+
+// checkLokiResponse accepts 2xx responses. Loki rejects entries older than
+// its stream's newest entry with a 400 "entry out of order", which retrying
+// cannot fix, so those batches are dropped like other client errors.
+func checkLokiResponse(resp *http.Response, body []byte, batch []interface{}) ([]interface{}, int, error) {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil, 0, nil
+	}
+	if resp.StatusCode == http.StatusBadRequest && bytes.Contains(body, []byte("out of order")) {
+		return nil, len(batch), fmt.Errorf("loki rejected %d entries out of order: %w", len(batch), httpStatusError(resp, body))
+	}
+	return nil, len(batch), httpStatusError(resp, body)
+}
+
+// lokiStreamKey returns the canonical Prometheus form of a label set
+func lokiStreamKey(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(labels[name]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// lokiLabelName converts a field name into a valid label name, replacing
+// characters outside [a-zA-Z0-9_] with underscores
+func lokiLabelName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		valid := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9')
+		if !valid {
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 {
+		return "_"
+	}
+	return string(b)
+}
@@ -0,0 +1,214 @@
+package backends_test
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/backends"
+)
+
+// fakeLoki decodes push requests and answers with the queued responses, then 204
+type fakeLoki struct {
+	mu        sync.Mutex
+	pushes    []lokiPushBody
+	headers   []http.Header
+	responses []fakeLokiResponse
+}
+
+type fakeLokiResponse struct {
+	status int
+	body   string
+}
+
+type lokiPushBody struct {
+	Streams []struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	} `json:"streams"`
+}
+
+func (f *fakeLoki) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/loki/api/v1/push" {
+		http.NotFound(w, r)
+		return
+	}
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body = zr
+	}
+	var push lokiPushBody
+	if err := json.NewDecoder(body).Decode(&push); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.headers = append(f.headers, r.Header)
+	if len(f.responses) > 0 {
+		resp := f.responses[0]
+		f.responses = f.responses[1:]
+		http.Error(w, resp.body, resp.status)
+		return
+	}
+	f.pushes = append(f.pushes, push)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func newLokiBackend(t *testing.T, config backends.LokiConfig) *backends.LokiBackend {
+	t.Helper()
+	backend, err := backends.NewLokiBackend(config)
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	t.Cleanup(func() { backend.Close() })
+	return backend
+}
+
+func TestLokiBackend_StreamsAndOrdering(t *testing.T) {
+	loki := &fakeLoki{}
+	server := httptest.NewServer(loki)
+	defer server.Close()
+
+	backend := newLokiBackend(t, backends.LokiConfig{
+		URL:         server.URL,
+		Labels:      map[string]string{"job": "api"},
+		LabelFields: []string{"service", "k8s.pod"},
+		LevelLabel:  true,
+		TenantID:    "team-a",
+		Compress:    true,
+	})
+
+	base := time.Unix(1700000000, 0)
+	records := []backends.Record{
+		{Timestamp: base.Add(2 * time.Second), Level: "info", Fields: map[string]interface{}{"service": "auth"}, Line: []byte("third\n")},
+		{Timestamp: base, Level: "info", Fields: map[string]interface{}{"service": "auth"}, Line: []byte("first\n")},
+		{Timestamp: base.Add(time.Second), Level: "error", Fields: map[string]interface{}{"service": "auth", "k8s.pod": "auth-1", "user": "x"}, Line: []byte("second\n")},
+		{Timestamp: base.Add(time.Second), Level: "info", Fields: map[string]interface{}{"service": "auth"}, Line: []byte("also second\n")},
+	}
+	for _, rec := range records {
+		if _, err := backend.WriteRecord(rec); err != nil {
+			t.Fatalf("WriteRecord failed: %v", err)
+		}
+	}
+	if err := backend.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	if len(loki.pushes) != 1 || len(loki.pushes[0].Streams) != 2 {
+		t.Fatalf("Expected 1 push with 2 streams, got %+v", loki.pushes)
+	}
+	if got := loki.headers[0].Get("X-Scope-OrgID"); got != "team-a" {
+		t.Errorf("Unexpected tenant %q", got)
+	}
+
+	info := loki.pushes[0].Streams[0]
+	if info.Stream["job"] != "api" || info.Stream["level"] != "info" || info.Stream["service"] != "auth" || len(info.Stream) != 3 {
+		t.Errorf("Unexpected labels %v", info.Stream)
+	}
+	var lines []string
+	for _, value := range info.Values {
+		lines = append(lines, value[1])
+	}
+	if strings.Join(lines, ",") != "first,also second,third" {
+		t.Errorf("Expected entries ordered by timestamp, got %v", lines)
+	}
+	if info.Values[0][0] != "1700000000000000000" {
+		t.Errorf("Unexpected timestamp %q", info.Values[0][0])
+	}
+
+	errorStream := loki.pushes[0].Streams[1]
+	if errorStream.Stream["k8s_pod"] != "auth-1" || errorStream.Stream["level"] != "error" || errorStream.Stream["user"] != "" {
+		t.Errorf("Unexpected labels %v", errorStream.Stream)
+	}
+}
+
+func TestLokiBackend_Cardinality(t *testing.T) {
+	loki := &fakeLoki{}
+	server := httptest.NewServer(loki)
+	defer server.Close()
+
+	backend := newLokiBackend(t, backends.LokiConfig{
+		URL:            server.URL + "/",
+		Labels:         map[string]string{"job": "api"},
+		LabelFields:    []string{"request_id"},
+		MaxStreams:     2,
+		MaxLabelLength: 4,
+	})
+
+	for _, id := range []string{"aaaaaa", "bbbbbb", "cccccc", "dddddd", "aaaa"} {
+		_, _ = backend.WriteRecord(backends.Record{Fields: map[string]interface{}{"request_id": id}, Line: []byte(id)})
+	}
+	if err := backend.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	streams := make(map[string]int)
+	for _, stream := range loki.pushes[0].Streams {
+		streams[stream.Stream["request_id"]] = len(stream.Values)
+	}
+	// Long values are truncated, and once 2 streams exist new label sets
+	// fall back to the static labels
+	expected := map[string]int{"aaaa": 2, "bbbb": 1, "": 2}
+	if len(streams) != len(expected) {
+		t.Fatalf("Expected streams %v, got %v", expected, streams)
+	}
+	for id, count := range expected {
+		if streams[id] != count {
+			t.Errorf("Expected %d entries for request_id %q, got %d", count, id, streams[id])
+		}
+	}
+	if backend.Overflowed() != 2 {
+		t.Errorf("Expected 2 overflowed entries, got %d", backend.Overflowed())
+	}
+}
+
+func TestLokiBackend_Responses(t *testing.T) {
+	loki := &fakeLoki{responses: []fakeLokiResponse{
+		{http.StatusBadRequest, "entry with timestamp 2023-11-14 ignored, reason: 'entry out of order' for stream: {job=\"omni\"}"},
+		{http.StatusTooManyRequests, "Ingestion rate limit exceeded"},
+	}}
+	server := httptest.NewServer(loki)
+	defer server.Close()
+
+	backend := newLokiBackend(t, backends.LokiConfig{URL: server.URL, MinBackoff: time.Millisecond})
+
+	// Out-of-order rejections are not retried
+	_, _ = backend.Write([]byte("late"))
+	err := backend.Flush()
+	if err == nil || !strings.Contains(err.Error(), "out of order") {
+		t.Errorf("Expected out of order error, got %v", err)
+	}
+	if stats := backend.GetStats(); stats.Requests != 1 || stats.Dropped != 1 {
+		t.Errorf("Unexpected stats after 400: %+v", stats)
+	}
+
+	// Rate limiting is retried
+	_, _ = backend.Write([]byte("limited"))
+	if err := backend.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if len(loki.pushes) != 1 || loki.pushes[0].Streams[0].Values[0][1] != "limited" {
+		t.Fatalf("Expected the rate limited entry to be retried, got %+v", loki.pushes)
+	}
+	if job := loki.pushes[0].Streams[0].Stream["job"]; job != "omni" {
+		t.Errorf("Expected the default job label, got %v", loki.pushes[0].Streams[0].Stream)
+	}
+
+	stats := backend.GetStats()
+	if stats.Requests != 3 || stats.Retries != 1 || stats.WriteCount != 1 || stats.Path != server.URL+"/loki/api/v1/push" {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
@@ -0,0 +1,308 @@
+package backends
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// netSender delivers framed messages over a network connection. Messages are
+// queued until sent; while disconnected the queue keeps at most bufferSize
+// messages, dropping the oldest, and reconnects are attempted with
+// exponential backoff. A datagram failing for a reason other than the
+// connection is dropped, so it cannot hold up the messages behind it. Owners
+// serialise access with their own mutex.
+type netSender struct {
+	network      string // tcp, tcp+tls, udp, unix, unixgram, or "" to detect the local socket type
+	address      string
+	tlsConfig    *tls.Config
+	dialTimeout  time.Duration
+	writeTimeout time.Duration
+	bufferSize   int
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+
+	conn         net.Conn
+	datagram     bool     // One message per packet
+	pending      [][]byte // Framed messages not yet written
+	pendingBytes int
+	closed       bool
+	backoff      time.Duration // Delay after the next failed reconnect
+	nextDial     time.Time     // Reconnects are not attempted before this time
+
+	writeCount     uint64
+	bytesWritten   uint64
+	errorCount     uint64
+	lastError      time.Time
+	dropped        uint64
+	reconnects     uint64
+	totalWriteTime time.Duration
+	maxWriteTime   time.Duration
+}
+
+// errReconnectBackoff reports that a reconnect attempt is not yet due
+var errReconnectBackoff = errors.New("waiting to reconnect")
+
+// dial connects to the server
+func (s *netSender) dial() error {
+	dialer := &net.Dialer{Timeout: s.dialTimeout}
+
+	var conn net.Conn
+	var err error
+	switch s.network {
+	case NetworkTLS:
+		conn, err = tls.DialWithDialer(dialer, "tcp", s.address, s.tlsConfig)
+	case "":
+		// Most local syslog sockets are datagram sockets; fall back to a stream socket
+		network := "unixgram"
+		conn, err = dialer.Dial(network, s.address)
+		if err != nil {
+			network = "unix"
+			conn, err = dialer.Dial(network, s.address)
+		}
+		if err == nil {
+			s.datagram = network == "unixgram"
+		}
+	default:
+		conn, err = dialer.Dial(s.network, s.address)
+		if err == nil {
+			s.datagram = strings.HasPrefix(s.network, "udp") || s.network == "unixgram"
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	s.conn = conn
+	return nil
+}
+
+// enqueue adds a framed message to the queue, dropping the oldest messages
+// once bufferSize is exceeded
+func (s *netSender) enqueue(data []byte) {
+	s.pending = append(s.pending, data)
+	s.pendingBytes += len(data)
+
+	limit := s.bufferSize
+	if limit <= 0 {
+		limit = DefaultNetworkBufferSize
+	}
+	for len(s.pending) > limit {
+		s.drop()
+	}
+}
+
+// drop removes the oldest queued message without sending it
+func (s *netSender) drop() {
+	s.pendingBytes -= len(s.pending[0])
+	s.pending[0] = nil
+	s.pending = s.pending[1:]
+	s.dropped++
+}
+
+// send writes the queued messages, reconnecting first when disconnected.
+// Messages that could not be sent stay queued.
+func (s *netSender) send() error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+
+	if s.conn == nil {
+		if err := s.reconnect(); err != nil {
+			return fmt.Errorf("disconnected from %s, %d messages buffered: %w", s.address, len(s.pending), err)
+		}
+	}
+
+	start := time.Now()
+	if s.writeTimeout > 0 {
+		_ = s.conn.SetWriteDeadline(start.Add(s.writeTimeout))
+	}
+
+	var err, dropErr error
+	if s.datagram {
+		dropped := 0
+		for len(s.pending) > 0 {
+			if _, err = s.conn.Write(s.pending[0]); err == nil {
+				s.sent(1, len(s.pending[0]))
+				continue
+			}
+			if transientError(err) {
+				break
+			}
+
+			// Sending the datagram again cannot succeed
+			s.errorCount++
+			s.lastError = time.Now()
+			s.drop()
+			dropped++
+			if dropErr == nil {
+				dropErr = err
+			}
+			err = nil
+		}
+		if dropErr != nil {
+			dropErr = fmt.Errorf("dropped %d undeliverable datagrams: %w", dropped, dropErr)
+		}
+	} else {
+		// Write the queue at once; on failure resend whole messages on the next connection
+		var n int
+		n, err = s.conn.Write(joinMessages(s.pending, s.pendingBytes))
+		count, size := 0, 0
+		for _, data := range s.pending {
+			if size+len(data) > n {
+				break
+			}
+			size += len(data)
+			count++
+		}
+		s.sent(count, size)
+	}
+
+	duration := time.Since(start)
+	s.totalWriteTime += duration
+	if duration > s.maxWriteTime {
+		s.maxWriteTime = duration
+	}
+
+	if err != nil {
+		s.disconnect()
+		return errors.Join(dropErr, fmt.Errorf("write: %w", err))
+	}
+
+	// Release the queue's backing array once it has grown
+	if cap(s.pending) > s.bufferSize {
+		s.pending = nil
+	}
+	return dropErr
+}
+
+// transientError reports whether a write failed because of the connection or
+// the server rather than the message, so the message is worth sending again
+// after reconnecting
+func transientError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	for _, target := range []error{
+		net.ErrClosed,
+		syscall.ECONNREFUSED,
+		syscall.ECONNRESET,
+		syscall.ECONNABORTED,
+		syscall.EPIPE,
+		syscall.ENOTCONN,
+		syscall.ENETDOWN,
+		syscall.ENETUNREACH,
+		syscall.EHOSTUNREACH,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// sent removes count delivered messages totalling size bytes from the queue
+func (s *netSender) sent(count, size int) {
+	for i := 0; i < count; i++ {
+		s.pending[i] = nil
+	}
+	s.pending = s.pending[count:]
+	s.pendingBytes -= size
+	s.writeCount += uint64(count)
+	s.bytesWritten += uint64(size)
+}
+
+// disconnect closes a failed connection so the next send reconnects immediately
+func (s *netSender) disconnect() {
+	s.errorCount++
+	s.lastError = time.Now()
+	if s.conn != nil {
+		_ = s.conn.Close()
+		s.conn = nil
+	}
+	s.nextDial = time.Time{}
+	s.backoff = s.minBackoff
+}
+
+// reconnect dials again unless the backoff delay since the last failure has not yet passed
+func (s *netSender) reconnect() error {
+	if s.closed {
+		return os.ErrClosed
+	}
+	if time.Now().Before(s.nextDial) {
+		return errReconnectBackoff
+	}
+
+	if err := s.dial(); err != nil {
+		s.errorCount++
+		s.lastError = time.Now()
+		s.nextDial = time.Now().Add(s.backoff)
+		s.backoff *= 2
+		if s.backoff > s.maxBackoff {
+			s.backoff = s.maxBackoff
+		}
+		return err
+	}
+
+	s.reconnects++
+	s.backoff = s.minBackoff
+	return nil
+}
+
+// close sends the queued messages, making one reconnect attempt if needed,
+// and closes the connection
+func (s *netSender) close() error {
+	var errs []error
+
+	s.nextDial = time.Time{}
+	if err := s.send(); err != nil {
+		errs = append(errs, fmt.Errorf("flush: %w", err))
+	}
+	s.closed = true
+
+	if s.conn != nil {
+		if err := s.conn.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close conn: %w", err))
+		}
+		s.conn = nil
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("close errors: %v", errs)
+	}
+	return nil
+}
+
+// stats returns the delivery statistics
+func (s *netSender) stats(path string) BackendStats {
+	return BackendStats{
+		Path:           path,
+		WriteCount:     s.writeCount,
+		BytesWritten:   s.bytesWritten,
+		ErrorCount:     s.errorCount,
+		LastError:      s.lastError,
+		TotalWriteTime: s.totalWriteTime,
+		MaxWriteTime:   s.maxWriteTime,
+		Buffered:       len(s.pending),
+		Dropped:        s.dropped,
+		Reconnects:     s.reconnects,
+	}
+}
+
+// joinMessages concatenates the queued messages into one write
+func joinMessages(messages [][]byte, size int) []byte {
+	if len(messages) == 1 {
+		return messages[0]
+	}
+	data := make([]byte, 0, size)
+	for _, message := range messages {
+		data = append(data, message...)
+	}
+	return data
+}
@@ -0,0 +1,70 @@
+package backends
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// failingConn is a datagram connection whose writes of selected payloads fail
+type failingConn struct {
+	net.Conn
+	fail    map[string]error
+	written []string
+}
+
+func (c *failingConn) Write(b []byte) (int, error) {
+	if err := c.fail[string(b)]; err != nil {
+		return 0, &net.OpError{Op: "write", Net: "unixgram", Err: os.NewSyscallError("write", err)}
+	}
+	c.written = append(c.written, string(b))
+	return len(b), nil
+}
+
+func (c *failingConn) SetWriteDeadline(time.Time) error { return nil }
+
+func (c *failingConn) Close() error { return nil }
+
+func TestNetSender_DropsUndeliverableDatagrams(t *testing.T) {
+	for _, errno := range []syscall.Errno{syscall.EMSGSIZE, syscall.ENOBUFS} {
+		t.Run(errno.Error(), func(t *testing.T) {
+			conn := &failingConn{fail: map[string]error{"head": errno}}
+			s := &netSender{conn: conn, datagram: true, bufferSize: DefaultNetworkBufferSize}
+			for _, message := range []string{"head", "second", "third"} {
+				s.enqueue([]byte(message))
+			}
+
+			// The failing head is dropped and the datagrams behind it sent
+			err := s.send()
+			if !errors.Is(err, errno) || !strings.Contains(err.Error(), "dropped 1") {
+				t.Errorf("Expected the dropped datagram to be reported, got %v", err)
+			}
+			if strings.Join(conn.written, ",") != "second,third" {
+				t.Errorf("Expected the later datagrams to be sent, got %v", conn.written)
+			}
+			stats := s.stats("test")
+			if stats.Dropped != 1 || stats.Buffered != 0 || stats.WriteCount != 2 || stats.ErrorCount != 1 || s.conn == nil {
+				t.Errorf("Unexpected stats: %+v", stats)
+			}
+		})
+	}
+}
+
+func TestNetSender_KeepsDatagramsOnTransientErrors(t *testing.T) {
+	// The receiver is not listening: the datagram is kept for the next connection
+	conn := &failingConn{fail: map[string]error{"head": syscall.ECONNREFUSED}}
+	s := &netSender{conn: conn, datagram: true, bufferSize: DefaultNetworkBufferSize}
+	s.enqueue([]byte("head"))
+	s.enqueue([]byte("second"))
+
+	if err := s.send(); !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Errorf("Expected the connection error, got %v", err)
+	}
+	if stats := s.stats("test"); stats.Dropped != 0 || stats.Buffered != 2 || s.conn != nil {
+		t.Errorf("Expected the datagrams to stay queued after disconnecting, got %+v", stats)
+	}
+}
@@ -0,0 +1,236 @@
+package backends
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NetworkFraming selects how entries are delimited on stream connections
+type NetworkFraming int
+
+const (
+	// NetworkFramingNewline terminates each entry with LF, as expected by
+	// Logstash's json_lines codec and Vector's newline_delimited framing
+	NetworkFramingNewline NetworkFraming = iota
+	// NetworkFramingLength prefixes each entry with its length as a 4-byte
+	// big-endian integer (Vector's length_delimited framing)
+	NetworkFramingLength
+	// NetworkFramingNull terminates each entry with a NUL byte, as GELF over TCP does
+	NetworkFramingNull
+)
+
+// ParseNetworkFraming parses a framing name: newline, length (or
+// length-prefix) and null
+func ParseNetworkFraming(name string) (NetworkFraming, error) {
+	switch strings.ToLower(name) {
+	case "", "newline", "lf":
+		return NetworkFramingNewline, nil
+	case "length", "length-prefix", "length_delimited":
+		return NetworkFramingLength, nil
+	case "null", "nul", "zero":
+		return NetworkFramingNull, nil
+	default:
+		return NetworkFramingNewline, fmt.Errorf("unknown network framing %q", name)
+	}
+}
+
+// NetworkTLS is the network name for TCP with TLS
+const NetworkTLS = "tcp+tls"
+
+// Defaults for NetworkConfig
+const (
+	DefaultNetworkDialTimeout  = 5 * time.Second
+	DefaultNetworkWriteTimeout = 5 * time.Second
+	DefaultNetworkBufferSize   = 1000
+	DefaultNetworkMinBackoff   = 100 * time.Millisecond
+	DefaultNetworkMaxBackoff   = 30 * time.Second
+)
+
+// networkFlushBytes triggers a send from Write once this many bytes are pending
+const networkFlushBytes = 4096
+
+// maxDatagramSize is the largest UDP payload over IPv4
+const maxDatagramSize = 65507
+
+// NetworkConfig configures a network backend
+type NetworkConfig struct {
+	Network      string         // tcp, tcp+tls, udp or unix (also tcp4, tcp6, udp4, udp6 and unixgram)
+	Address      string         // host:port or socket path
+	Framing      NetworkFraming // Entry delimiting on stream connections; datagrams carry one entry each
+	TLSConfig    *tls.Config    // Used by tcp+tls; nil verifies the server against the system roots
+	DialTimeout  time.Duration  // 0 = DefaultNetworkDialTimeout
+	WriteTimeout time.Duration  // 0 = DefaultNetworkWriteTimeout
+	BufferSize   int            // Entries kept while disconnected; 0 = DefaultNetworkBufferSize
+	MinBackoff   time.Duration  // First reconnect delay; 0 = DefaultNetworkMinBackoff
+	MaxBackoff   time.Duration  // Reconnect delay limit; 0 = DefaultNetworkMaxBackoff
+	Binary       bool           // Entries are binary, such as MessagePack, and sent unchanged; streams need NetworkFramingLength
+}
+
+// newNetSender creates a sender for the transport settings, applying defaults
+func newNetSender(network, address string, tlsConfig *tls.Config, dialTimeout, writeTimeout time.Duration, bufferSize int, minBackoff, maxBackoff time.Duration) netSender {
+	if dialTimeout <= 0 {
+		dialTimeout = DefaultNetworkDialTimeout
+	}
+	if writeTimeout <= 0 {
+		writeTimeout = DefaultNetworkWriteTimeout
+	}
+	if bufferSize <= 0 {
+		bufferSize = DefaultNetworkBufferSize
+	}
+	if minBackoff <= 0 {
+		minBackoff = DefaultNetworkMinBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultNetworkMaxBackoff
+	}
+	if maxBackoff < minBackoff {
+		maxBackoff = minBackoff
+	}
+
+	return netSender{
+		network:      network,
+		address:      address,
+		tlsConfig:    tlsConfig,
+		dialTimeout:  dialTimeout,
+		writeTimeout: writeTimeout,
+		bufferSize:   bufferSize,
+		minBackoff:   minBackoff,
+		maxBackoff:   maxBackoff,
+		backoff:      minBackoff,
+	}
+}
+
+// NetworkBackend streams entries to a TCP, UDP or Unix socket server such as
+// Logstash or Vector. Entries are queued and sent on Flush or once enough
+// data is pending. Lost connections are re-established with exponential
+// backoff while up to BufferSize entries are kept.
+type NetworkBackend struct {
+	sender  netSender
+	framing NetworkFraming
+	binary  bool
+	mu      sync.Mutex
+}
+
+// NewNetworkBackend connects to the server. The first connection must
+// succeed; later connection failures are retried.
+func NewNetworkBackend(config NetworkConfig) (*NetworkBackend, error) {
+	switch config.Network {
+	case "tcp", "tcp4", "tcp6", NetworkTLS, "udp", "udp4", "udp6", "unix", "unixgram":
+	default:
+		return nil, fmt.Errorf("unsupported network %q", config.Network)
+	}
+	if config.Address == "" {
+		return nil, fmt.Errorf("network destination needs an address")
+	}
+	if config.Binary && config.Framing != NetworkFramingLength && !strings.HasPrefix(config.Network, "udp") && config.Network != "unixgram" {
+		return nil, fmt.Errorf("binary entries need length framing on %s connections", config.Network)
+	}
+
+	nb := &NetworkBackend{
+		sender: newNetSender(config.Network, config.Address, config.TLSConfig,
+			config.DialTimeout, config.WriteTimeout, config.BufferSize, config.MinBackoff, config.MaxBackoff),
+		framing: config.Framing,
+		binary:  config.Binary,
+	}
+
+	if err := nb.sender.dial(); err != nil {
+		return nil, fmt.Errorf("dial %s: %w", config.Network, err)
+	}
+
+	return nb, nil
+}
+
+// Write queues an entry; it is sent by Flush or once enough data is pending.
+// Connection failures are reported by Flush, not Write.
+func (nb *NetworkBackend) Write(entry []byte) (int, error) {
+	nb.mu.Lock()
+	defer nb.mu.Unlock()
+
+	if nb.sender.closed {
+		return 0, os.ErrClosed
+	}
+
+	data := nb.frame(entry)
+	if nb.sender.datagram && len(data) > maxDatagramSize {
+		return 0, fmt.Errorf("entry of %d bytes exceeds the maximum datagram size", len(data))
+	}
+	nb.sender.enqueue(data)
+
+	if nb.sender.pendingBytes >= networkFlushBytes {
+		// Unsent entries stay queued and the failure is reported by Flush
+		_ = nb.sender.send()
+	}
+
+	return len(entry), nil
+}
+
+// frame copies entry with the configured delimiting. The trailing newline
+// of text entries is replaced by the framing; binary entries are kept whole.
+func (nb *NetworkBackend) frame(entry []byte) []byte {
+	if !nb.binary {
+		entry = bytes.TrimSuffix(entry, []byte("\n"))
+	}
+
+	switch {
+	case nb.sender.datagram:
+		return append([]byte(nil), entry...)
+	case nb.framing == NetworkFramingLength:
+		data := make([]byte, 4, 4+len(entry))
+		binary.BigEndian.PutUint32(data, uint32(len(entry))) // #nosec G115 - entries are far below 4 GiB
+		return append(data, entry...)
+	case nb.framing == NetworkFramingNull:
+		return append(append(make([]byte, 0, len(entry)+1), entry...), 0)
+	default:
+		return append(append(make([]byte, 0, len(entry)+1), entry...), '\n')
+	}
+}
+
+// Flush sends the queued entries. While the server is unreachable the
+// entries stay queued and an error is returned.
+func (nb *NetworkBackend) Flush() error {
+	nb.mu.Lock()
+	defer nb.mu.Unlock()
+
+	return nb.sender.send()
+}
+
+// Close sends the queued entries, making one reconnect attempt if needed,
+// and closes the connection
+func (nb *NetworkBackend) Close() error {
+	nb.mu.Lock()
+	defer nb.mu.Unlock()
+
+	return nb.sender.close()
+}
+
+// SupportsAtomic returns false as network writes are not atomic
+func (nb *NetworkBackend) SupportsAtomic() bool {
+	return false
+}
+
+// Sync sends the queued entries
+func (nb *NetworkBackend) Sync() error {
+	return nb.Flush()
+}
+
+// GetStats returns backend statistics. WriteCount and BytesWritten count
+// entries delivered to the server, including framing.
+func (nb *NetworkBackend) GetStats() BackendStats {
+	nb.mu.Lock()
+	defer nb.mu.Unlock()
+
+	return nb.sender.stats(nb.sender.network + "://" + nb.sender.address)
+}
+
+// IsConnected reports whether the backend currently holds a connection
+func (nb *NetworkBackend) IsConnected() bool {
+	nb.mu.Lock()
+	defer nb.mu.Unlock()
+	return nb.sender.conn != nil
+}
@@ -0,0 +1,340 @@
+package backends_test
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	testhelpers "github.com/wayneeseguin/omni/internal/testing"
+	"github.com/wayneeseguin/omni/pkg/backends"
+)
+
+// fakeCollector receives entries over a stream listener, split by the given framing
+type fakeCollector struct {
+	listener net.Listener
+	framing  backends.NetworkFraming
+	entries  chan string
+	mu       sync.Mutex
+	conns    []net.Conn
+}
+
+func startFakeCollector(t *testing.T, listener net.Listener, framing backends.NetworkFraming) *fakeCollector {
+	t.Helper()
+	c := &fakeCollector{listener: listener, framing: framing, entries: make(chan string, 100)}
+	go c.accept()
+	t.Cleanup(c.stop)
+	return c
+}
+
+func (c *fakeCollector) accept() {
+	for {
+		conn, err := c.listener.Accept()
+		if err != nil {
+			return
+		}
+		c.mu.Lock()
+		c.conns = append(c.conns, conn)
+		c.mu.Unlock()
+		go c.read(conn)
+	}
+}
+
+func (c *fakeCollector) read(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	for {
+		switch c.framing {
+		case backends.NetworkFramingLength:
+			var size uint32
+			if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+				return
+			}
+			entry := make([]byte, size)
+			if _, err := io.ReadFull(r, entry); err != nil {
+				return
+			}
+			c.entries <- string(entry)
+		default:
+			delim := byte('\n')
+			if c.framing == backends.NetworkFramingNull {
+				delim = 0
+			}
+			entry, err := r.ReadString(delim)
+			if err != nil {
+				return
+			}
+			c.entries <- strings.TrimSuffix(entry, string(delim))
+		}
+	}
+}
+
+// stop closes the listener and all accepted connections
+func (c *fakeCollector) stop() {
+	c.listener.Close()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, conn := range c.conns {
+		conn.Close()
+	}
+}
+
+func writeEntries(t *testing.T, backend *backends.NetworkBackend, entries ...string) {
+	t.Helper()
+	for _, entry := range entries {
+		if _, err := backend.Write([]byte(entry)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := backend.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+}
+
+func TestNetworkBackend_Framing(t *testing.T) {
+	tests := []struct {
+		name    string
+		framing backends.NetworkFraming
+	}{
+		{"newline", backends.NetworkFramingNewline},
+		{"length", backends.NetworkFramingLength},
+		{"null", backends.NetworkFramingNull},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("Failed to listen: %v", err)
+			}
+			collector := startFakeCollector(t, listener, tt.framing)
+
+			backend, err := backends.NewNetworkBackend(backends.NetworkConfig{
+				Network: "tcp",
+				Address: listener.Addr().String(),
+				Framing: tt.framing,
+			})
+			if err != nil {
+				t.Fatalf("Failed to create backend: %v", err)
+			}
+			defer backend.Close()
+
+			// The formatter's trailing newline is replaced by the framing
+			writeEntries(t, backend, `{"msg":"one"}`+"\n", `{"msg":"two"}`)
+			expectMessages(t, collector.entries, `{"msg":"one"}`, `{"msg":"two"}`)
+		})
+	}
+}
+
+func TestNetworkBackend_Binary(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	collector := startFakeCollector(t, listener, backends.NetworkFramingLength)
+
+	backend, err := backends.NewNetworkBackend(backends.NetworkConfig{
+		Network: "tcp",
+		Address: listener.Addr().String(),
+		Framing: backends.NetworkFramingLength,
+		Binary:  true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	defer backend.Close()
+
+	// A binary entry may end in a newline byte, which must be kept
+	writeEntries(t, backend, "\x81\xa1n\x0a")
+	expectMessages(t, collector.entries, "\x81\xa1n\x0a")
+
+	if _, err := backends.NewNetworkBackend(backends.NetworkConfig{Network: "tcp", Address: listener.Addr().String(), Binary: true}); err == nil {
+		t.Error("Expected error for binary entries with newline framing")
+	}
+}
+
+func TestNetworkBackend_Unix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "collector.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	collector := startFakeCollector(t, listener, backends.NetworkFramingNewline)
+
+	backend, err := backends.NewNetworkBackend(backends.NetworkConfig{Network: "unix", Address: path})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	defer backend.Close()
+
+	writeEntries(t, backend, "over unix\n")
+	expectMessages(t, collector.entries, "over unix")
+
+	if stats := backend.GetStats(); stats.Path != "unix://"+path || stats.WriteCount != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestNetworkBackend_UDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer conn.Close()
+
+	backend, err := backends.NewNetworkBackend(backends.NetworkConfig{
+		Network: "udp",
+		Address: conn.LocalAddr().String(),
+		Framing: backends.NetworkFramingLength,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	defer backend.Close()
+
+	writeEntries(t, backend, "first\n", "second\n")
+
+	// Each entry is one datagram without framing
+	buf := make([]byte, 1024)
+	for _, expected := range []string{"first", "second"} {
+		_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("ReadFrom failed: %v", err)
+		}
+		if got := string(buf[:n]); got != expected {
+			t.Errorf("Expected datagram %q, got %q", expected, got)
+		}
+	}
+
+	if _, err := backend.Write(make([]byte, 70000)); err == nil {
+		t.Error("Expected error for an entry larger than a datagram")
+	}
+}
+
+func TestNetworkBackend_TLS(t *testing.T) {
+	cert := testhelpers.NewTestCertificate(t)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", cert.ServerConfig())
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	collector := startFakeCollector(t, listener, backends.NetworkFramingNewline)
+
+	clientConfig := cert.ClientConfig()
+	clientConfig.ServerName = "localhost"
+	backend, err := backends.NewNetworkBackend(backends.NetworkConfig{
+		Network:   backends.NetworkTLS,
+		Address:   listener.Addr().String(),
+		TLSConfig: clientConfig,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	defer backend.Close()
+
+	writeEntries(t, backend, "secure\n")
+	expectMessages(t, collector.entries, "secure")
+}
+
+func TestNetworkBackend_ReconnectAndBuffer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	collector := startFakeCollector(t, listener, backends.NetworkFramingNewline)
+
+	backend, err := backends.NewNetworkBackend(backends.NetworkConfig{
+		Network:    "tcp",
+		Address:    addr,
+		BufferSize: 2,
+		MinBackoff: 10 * time.Millisecond,
+		MaxBackoff: 20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	defer backend.Close()
+
+	writeEntries(t, backend, "before")
+	expectMessages(t, collector.entries, "before")
+
+	collector.stop()
+	deadline := time.Now().Add(3 * time.Second)
+	for backend.IsConnected() {
+		if time.Now().After(deadline) {
+			t.Fatal("Backend did not notice the lost connection")
+		}
+		_, _ = backend.Write([]byte("lost"))
+		_ = backend.Flush()
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for _, entry := range []string{"queued 1", "queued 2"} {
+		if _, err := backend.Write([]byte(entry)); err != nil {
+			t.Fatalf("Write while disconnected failed: %v", err)
+		}
+	}
+	if stats := backend.GetStats(); stats.Buffered != 2 || stats.Dropped == 0 {
+		t.Errorf("Expected 2 buffered and some dropped entries, got %+v", stats)
+	}
+
+	listener, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("Cannot listen on %s again: %v", addr, err)
+	}
+	collector = startFakeCollector(t, listener, backends.NetworkFramingNewline)
+
+	deadline = time.Now().Add(3 * time.Second)
+	for backend.Flush() != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("Backend did not reconnect")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	expectMessages(t, collector.entries, "queued 1", "queued 2")
+
+	if stats := backend.GetStats(); stats.Reconnects != 1 || stats.Buffered != 0 {
+		t.Errorf("Unexpected stats after reconnect: %+v", stats)
+	}
+}
+
+func TestNetworkBackend_Errors(t *testing.T) {
+	if _, err := backends.NewNetworkBackend(backends.NetworkConfig{Network: "sctp", Address: "127.0.0.1:1"}); err == nil {
+		t.Error("Expected error for an unsupported network")
+	}
+	if _, err := backends.NewNetworkBackend(backends.NetworkConfig{Network: "tcp"}); err == nil {
+		t.Error("Expected error for a missing address")
+	}
+	if _, err := backends.ParseNetworkFraming("crlf"); err == nil {
+		t.Error("Expected error for an unknown framing")
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	collector := startFakeCollector(t, listener, backends.NetworkFramingNewline)
+
+	backend, err := backends.NewNetworkBackend(backends.NetworkConfig{Network: "tcp", Address: listener.Addr().String()})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	if _, err := backend.Write([]byte("last words\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := backend.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	expectMessages(t, collector.entries, "last words")
+
+	if _, err := backend.Write([]byte("too late")); err == nil {
+		t.Error("Expected error writing to a closed backend")
+	}
+}
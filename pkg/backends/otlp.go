@@ -0,0 +1,540 @@
+package backends
+
+import (
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// OTLPEncoding selects the OTLP/HTTP request encoding
+type OTLPEncoding int
+
+const (
+	// OTLPEncodingProtobuf sends binary protobuf (application/x-protobuf)
+	OTLPEncodingProtobuf OTLPEncoding = iota
+	// OTLPEncodingJSON sends the OTLP JSON mapping (application/json)
+	OTLPEncodingJSON
+)
+
+// ParseOTLPEncoding parses an OTLP encoding name: protobuf (or proto) and json
+func ParseOTLPEncoding(name string) (OTLPEncoding, error) {
+	switch strings.ToLower(name) {
+	case "", "protobuf", "proto":
+		return OTLPEncodingProtobuf, nil
+	case "json":
+		return OTLPEncodingJSON, nil
+	default:
+		return OTLPEncodingProtobuf, fmt.Errorf("unknown OTLP encoding %q", name)
+	}
+}
+
+// otlpLogsPath is the path of the OTLP/HTTP logs endpoint
+const otlpLogsPath = "/v1/logs"
+
+// otlpScopeName is the instrumentation scope reported for every record
+const otlpScopeName = "github.com/wayneeseguin/omni"
+
+// Fields holding W3C trace context, moved into the dedicated LogRecord
+// fields when they contain valid hex IDs
+var (
+	otlpTraceIDFields = []string{"trace_id", "traceId", "trace.id"}
+	otlpSpanIDFields  = []string{"span_id", "spanId", "span.id"}
+)
+
+// OTLPConfig configures an OpenTelemetry OTLP/HTTP logs backend
+type OTLPConfig struct {
+	URL                string            // Collector base URL, or the full /v1/logs URL
+	Encoding           OTLPEncoding      // Request encoding
+	ServiceName        string            // service.name resource attribute; defaults to unknown_service:<executable>
+	ResourceAttributes map[string]string // Static resource attributes
+	Headers            map[string]string // Extra request headers, e.g. for authentication
+	Compress           bool              // Gzip request bodies
+	BatchSize          int               // Records per request; 0 = DefaultHTTPBatchSize
+	BatchBytes         int               // Approximate bytes per request; 0 = DefaultHTTPBatchBytes
+	FlushInterval      time.Duration     // 0 = DefaultHTTPFlushInterval, negative disables
+	Timeout            time.Duration     // 0 = DefaultHTTPTimeout
+	MaxRetries         int               // Retries after 429 or 5xx; 0 = DefaultHTTPMaxRetries, negative disables
+	MinBackoff         time.Duration     // 0 = DefaultHTTPMinBackoff
+	MaxBackoff         time.Duration     // 0 = DefaultHTTPMaxBackoff
+	MaxInFlight        int               // Concurrent requests; 0 = DefaultHTTPMaxInFlight
+	TLSConfig          *tls.Config
+	Client             *http.Client
+}
+
+// OTLP data model, shared by the JSON and protobuf encodings. JSON field
+// names follow the OTLP JSON mapping.
+type otlpLogsRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope       `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano         uint64         `json:"timeUnixNano,string"`
+	ObservedTimeUnixNano uint64         `json:"observedTimeUnixNano,string"`
+	SeverityNumber       int32          `json:"severityNumber,omitempty"`
+	SeverityText         string         `json:"severityText,omitempty"`
+	Body                 otlpAnyValue   `json:"body"`
+	Attributes           []otlpKeyValue `json:"attributes,omitempty"`
+	TraceID              otlpID         `json:"traceId,omitempty"`
+	SpanID               otlpID         `json:"spanId,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string           `json:"stringValue,omitempty"`
+	BoolValue   *bool             `json:"boolValue,omitempty"`
+	IntValue    *int64            `json:"intValue,omitempty,string"`
+	DoubleValue *float64          `json:"doubleValue,omitempty"`
+	ArrayValue  *otlpArrayValue   `json:"arrayValue,omitempty"`
+	KvlistValue *otlpKeyValueList `json:"kvlistValue,omitempty"`
+}
+
+type otlpArrayValue struct {
+	Values []otlpAnyValue `json:"values"`
+}
+
+type otlpKeyValueList struct {
+	Values []otlpKeyValue `json:"values"`
+}
+
+// otlpID is a trace or span ID, hex encoded in JSON
+type otlpID []byte
+
+// MarshalJSON encodes the ID as lower-case hex, as the OTLP JSON mapping requires
+func (id otlpID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hex.EncodeToString(id))
+}
+
+// otlpEntry is a queued log record with the resource it belongs to
+type otlpEntry struct {
+	resource   string // Canonical form of the resource attributes
+	attributes []otlpKeyValue
+	record     otlpLogRecord
+}
+
+// OTLPBackend exports entries as OpenTelemetry log records over OTLP/HTTP.
+// Levels map to severity numbers, entry fields to record attributes and the
+// logger's global fields, with the service name and static resource
+// attributes, to resource attributes. Trace and span IDs found in the
+// trace_id and span_id fields fill the record's trace context. Partial
+// success responses count the rejected records as dropped.
+type OTLPBackend struct {
+	batcher  *httpBatcher
+	path     string
+	resource map[string]interface{}
+}
+
+// NewOTLPBackend creates an OTLP backend and starts its flush interval timer
+func NewOTLPBackend(config OTLPConfig) (*OTLPBackend, error) {
+	endpoint, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OTLP URL: %w", err)
+	}
+	if (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid OTLP URL %q", config.URL)
+	}
+	if !strings.HasSuffix(endpoint.Path, otlpLogsPath) {
+		endpoint.Path = strings.TrimSuffix(endpoint.Path, "/") + otlpLogsPath
+	}
+
+	resource := make(map[string]interface{}, len(config.ResourceAttributes)+1)
+	for key, value := range config.ResourceAttributes {
+		resource[key] = value
+	}
+	switch {
+	case config.ServiceName != "":
+		resource["service.name"] = config.ServiceName
+	case resource["service.name"] == nil:
+		resource["service.name"] = "unknown_service:" + filepath.Base(os.Args[0])
+	}
+
+	header := http.Header{}
+	header.Set("User-Agent", "omni")
+	for key, value := range config.Headers {
+		header.Set(key, value)
+	}
+
+	ob := &OTLPBackend{
+		resource: resource,
+	}
+
+	encode := encodeOTLPProtobuf
+	header.Set("Content-Type", "application/x-protobuf")
+	if config.Encoding == OTLPEncodingJSON {
+		encode = encodeOTLPJSON
+		header.Set("Content-Type", "application/json")
+	}
+
+	logsURL := endpoint.String()
+	endpoint.User = nil
+	ob.path = endpoint.String()
+
+	ob.batcher = newHTTPBatcher(httpBatchConfig{
+		client:        httpClient(config.Client, config.Timeout, config.TLSConfig),
+		url:           logsURL,
+		header:        header,
+		compress:      config.Compress,
+		batchSize:     config.BatchSize,
+		batchBytes:    config.BatchBytes,
+		flushInterval: config.FlushInterval,
+		maxRetries:    config.MaxRetries,
+		minBackoff:    config.MinBackoff,
+		maxBackoff:    config.MaxBackoff,
+		maxInFlight:   config.MaxInFlight,
+	}, encode)
+	ob.batcher.check = checkOTLPResponse
+
+	return ob, nil
+}
+
+// WriteRecord queues an entry as a log record
+func (ob *OTLPBackend) WriteRecord(rec Record) (int, error) {
+	now := time.Now()
+	timestamp := rec.Timestamp
+	if timestamp.IsZero() {
+		timestamp = now
+	}
+	severity, severityText := otlpSeverity(rec.Level)
+
+	record := otlpLogRecord{
+		TimeUnixNano:         otlpTime(timestamp),
+		ObservedTimeUnixNano: otlpTime(now),
+		SeverityNumber:       severity,
+		SeverityText:         severityText,
+		Body:                 otlpValue(rec.Message),
+	}
+
+	// Global fields become resource attributes unless the entry overrides them
+	attributes := make(map[string]interface{}, len(rec.Fields))
+	for key, value := range rec.Fields {
+		if global, ok := rec.Global[key]; ok && reflect.DeepEqual(global, value) {
+			continue
+		}
+		attributes[key] = value
+	}
+	record.TraceID = otlpTakeID(attributes, otlpTraceIDFields, 16)
+	record.SpanID = otlpTakeID(attributes, otlpSpanIDFields, 8)
+	record.Attributes = otlpAttributes(attributes)
+
+	resource := ob.resource
+	if len(rec.Global) > 0 {
+		resource = make(map[string]interface{}, len(ob.resource)+len(rec.Global))
+		for key, value := range ob.resource {
+			resource[key] = value
+		}
+		for key, value := range rec.Global {
+			resource[key] = value
+		}
+	}
+	resourceKey, err := json.Marshal(resource)
+	if err != nil {
+		resourceKey = []byte(fmt.Sprint(resource))
+	}
+
+	entry := &otlpEntry{resource: string(resourceKey), attributes: otlpAttributes(resource), record: record}
+	if err := ob.batcher.add(entry, len(rec.Message)+64*(len(record.Attributes)+1)); err != nil {
+		return 0, err
+	}
+	return len(rec.Line), nil
+}
+
+// Write queues an entry as a log record whose body is the entry text,
+// timestamped now
+func (ob *OTLPBackend) Write(entry []byte) (int, error) {
+	if _, err := ob.WriteRecord(Record{Timestamp: time.Now(), Message: strings.TrimSpace(string(entry))}); err != nil {
+		return 0, err
+	}
+	return len(entry), nil
+}
+
+// Flush exports the queued records and waits for all requests to finish,
+// returning the delivery failures since the last Flush
+func (ob *OTLPBackend) Flush() error {
+	return ob.batcher.flush()
+}
+
+// Close exports the queued records and stops the flush interval timer
+func (ob *OTLPBackend) Close() error {
+	return ob.batcher.close()
+}
+
+// Batching returns true as records are exported in batches on their own schedule
+func (ob *OTLPBackend) Batching() bool {
+	return true
+}
+
+// SupportsAtomic returns false as batches are delivered asynchronously
+func (ob *OTLPBackend) SupportsAtomic() bool {
+	return false
+}
+
+// Sync exports the queued records
+func (ob *OTLPBackend) Sync() error {
+	return ob.Flush()
+}
+
+// GetStats returns backend statistics, as for HTTPBackend. Records the
+// collector rejected in a partial success response count as dropped.
+func (ob *OTLPBackend) GetStats() BackendStats {
+	return ob.batcher.stats(ob.path)
+}
+
+// otlpRequest groups a batch by resource, keeping the order of first appearance
+func otlpRequest(batch []interface{}) otlpLogsRequest {
+	var request otlpLogsRequest
+	index := make(map[string]int)
+	for _, item := range batch {
+		entry := item.(*otlpEntry)
+		i, ok := index[entry.resource]
+		if !ok {
+			i = len(request.ResourceLogs)
+			index[entry.resource] = i
+			request.ResourceLogs = append(request.ResourceLogs, otlpResourceLogs{
+				Resource:  otlpResource{Attributes: entry.attributes},
+				ScopeLogs: []otlpScopeLogs{{Scope: otlpScope{Name: otlpScopeName}}},
+			})
+		}
+		scope := &request.ResourceLogs[i].ScopeLogs[0]
+		scope.LogRecords = append(scope.LogRecords, entry.record)
+	}
+	return request
+}
+
+// encodeOTLPJSON encodes a batch as an ExportLogsServiceRequest in JSON
+func encodeOTLPJSON(batch []interface{}) ([]byte, error) {
+	return json.Marshal(otlpRequest(batch))
+}
+
+// encodeOTLPProtobuf encodes a batch as an ExportLogsServiceRequest in protobuf
+func encodeOTLPProtobuf(batch []interface{}) ([]byte, error) {
+	return marshalOTLPRequest(otlpRequest(batch)), nil
+}
+
+// checkOTLPResponse accepts 2xx responses, counting the records a partial
+// success response reports as rejected. Rejected records are not retried,
+// as the collector reports them as invalid.
+func checkOTLPResponse(resp *http.Response, body []byte, batch []interface{}) ([]interface{}, int, error) {
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, len(batch), httpStatusError(resp, body)
+	}
+	if len(body) == 0 {
+		return nil, 0, nil
+	}
+
+	var rejected int64
+	var message string
+	var err error
+	if strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		var result struct {
+			PartialSuccess struct {
+				RejectedLogRecords json.Number `json:"rejectedLogRecords"`
+				ErrorMessage       string      `json:"errorMessage"`
+			} `json:"partialSuccess"`
+		}
+		if err = json.Unmarshal(body, &result); err == nil && result.PartialSuccess.RejectedLogRecords != "" {
+			rejected, err = result.PartialSuccess.RejectedLogRecords.Int64()
+		}
+		message = result.PartialSuccess.ErrorMessage
+	} else {
+		rejected, message, err = unmarshalOTLPPartialSuccess(body)
+	}
+	if err != nil {
+		// The records were accepted; only the response detail is lost
+		return nil, 0, fmt.Errorf("decode OTLP response: %w", err)
+	}
+
+	if rejected <= 0 {
+		return nil, 0, nil
+	}
+	if rejected > int64(len(batch)) {
+		rejected = int64(len(batch))
+	}
+	return nil, int(rejected), fmt.Errorf("collector rejected %d of %d log records: %s", rejected, len(batch), message)
+}
+
+// otlpSeverity maps a level name to its OTLP severity number and text
+func otlpSeverity(level string) (int32, string) {
+	switch strings.ToLower(level) {
+	case "trace":
+		return 1, "TRACE"
+	case "debug":
+		return 5, "DEBUG"
+	case "info":
+		return 9, "INFO"
+	case "warn", "warning":
+		return 13, "WARN"
+	case "error":
+		return 17, "ERROR"
+	case "fatal", "panic", "critical":
+		return 21, "FATAL"
+	default:
+		return 0, strings.ToUpper(level)
+	}
+}
+
+// otlpTime converts t to nanoseconds since the Unix epoch
+func otlpTime(t time.Time) uint64 {
+	nanos := t.UnixNano()
+	if nanos < 0 {
+		return 0
+	}
+	return uint64(nanos)
+}
+
+// otlpTakeID removes the first field holding a valid hex ID of size bytes
+// and returns the decoded ID
+func otlpTakeID(fields map[string]interface{}, names []string, size int) otlpID {
+	for _, name := range names {
+		value, ok := fields[name].(string)
+		if !ok || len(value) != 2*size {
+			continue
+		}
+		id, err := hex.DecodeString(value)
+		if err != nil || isZeroID(id) {
+			continue
+		}
+		delete(fields, name)
+		return id
+	}
+	return nil
+}
+
+// isZeroID reports whether an ID is all zeros, which OTLP treats as invalid
+func isZeroID(id []byte) bool {
+	for _, b := range id {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// otlpAttributes converts fields to attributes sorted by key
+func otlpAttributes(fields map[string]interface{}) []otlpKeyValue {
+	if len(fields) == 0 {
+		return nil
+	}
+	attributes := make([]otlpKeyValue, 0, len(fields))
+	for key, value := range fields {
+		attributes = append(attributes, otlpKeyValue{Key: key, Value: otlpValue(value)})
+	}
+	sort.Slice(attributes, func(i, j int) bool { return attributes[i].Key < attributes[j].Key })
+	return attributes
+}
+
+// otlpValue converts a field value to an OTLP AnyValue
+func otlpValue(value interface{}) otlpAnyValue {
+	switch v := value.(type) {
+	case nil:
+		return otlpAnyValue{}
+	case string:
+		return otlpAnyValue{StringValue: &v}
+	case bool:
+		return otlpAnyValue{BoolValue: &v}
+	case int:
+		return otlpInt(int64(v))
+	case int8:
+		return otlpInt(int64(v))
+	case int16:
+		return otlpInt(int64(v))
+	case int32:
+		return otlpInt(int64(v))
+	case int64:
+		return otlpInt(v)
+	case uint:
+		return otlpUint(uint64(v))
+	case uint8:
+		return otlpInt(int64(v))
+	case uint16:
+		return otlpInt(int64(v))
+	case uint32:
+		return otlpInt(int64(v))
+	case uint64:
+		return otlpUint(v)
+	case float32:
+		f := float64(v)
+		return otlpAnyValue{DoubleValue: &f}
+	case float64:
+		return otlpAnyValue{DoubleValue: &v}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return otlpInt(i)
+		}
+		if f, err := v.Float64(); err == nil {
+			return otlpAnyValue{DoubleValue: &f}
+		}
+		return otlpString(v.String())
+	case time.Time:
+		return otlpString(v.Format(time.RFC3339Nano))
+	case time.Duration:
+		return otlpString(v.String())
+	case error:
+		return otlpString(v.Error())
+	case fmt.Stringer:
+		return otlpString(v.String())
+	case []interface{}:
+		array := &otlpArrayValue{Values: make([]otlpAnyValue, len(v))}
+		for i, item := range v {
+			array.Values[i] = otlpValue(item)
+		}
+		return otlpAnyValue{ArrayValue: array}
+	case []string:
+		array := &otlpArrayValue{Values: make([]otlpAnyValue, len(v))}
+		for i, item := range v {
+			array.Values[i] = otlpString(item)
+		}
+		return otlpAnyValue{ArrayValue: array}
+	case map[string]interface{}:
+		return otlpAnyValue{KvlistValue: &otlpKeyValueList{Values: otlpAttributes(v)}}
+	default:
+		return otlpString(fmt.Sprint(v))
+	}
+}
+
+func otlpString(s string) otlpAnyValue {
+	return otlpAnyValue{StringValue: &s}
+}
+
+func otlpInt(i int64) otlpAnyValue {
+	return otlpAnyValue{IntValue: &i}
+}
+
+// otlpUint converts an unsigned value, falling back to a string when it
+// does not fit in an int64
+func otlpUint(u uint64) otlpAnyValue {
+	if u > math.MaxInt64 {
+		return otlpString(fmt.Sprint(u))
+	}
+	return otlpInt(int64(u))
+}
@@ -0,0 +1,337 @@
+package backends_test
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/backends"
+)
+
+const (
+	testTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testSpanID  = "00f067aa0ba902b7"
+)
+
+// fakeOTLPCollector receives OTLP/HTTP log exports, answering each with the
+// next scripted response
+type fakeOTLPCollector struct {
+	mu        sync.Mutex
+	bodies    [][]byte
+	headers   []http.Header
+	responses []func(w http.ResponseWriter)
+}
+
+func (f *fakeOTLPCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/logs" {
+		http.NotFound(w, r)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.bodies = append(f.bodies, body)
+	f.headers = append(f.headers, r.Header)
+	if len(f.responses) > 0 {
+		respond := f.responses[0]
+		f.responses = f.responses[1:]
+		respond(w)
+	}
+}
+
+func newOTLPBackend(t *testing.T, config backends.OTLPConfig) *backends.OTLPBackend {
+	t.Helper()
+	backend, err := backends.NewOTLPBackend(config)
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	t.Cleanup(func() { backend.Close() })
+	return backend
+}
+
+// otlpJSON is the part of an OTLP JSON export request the tests inspect
+type otlpJSON struct {
+	ResourceLogs []struct {
+		Resource struct {
+			Attributes []otlpJSONKeyValue `json:"attributes"`
+		} `json:"resource"`
+		ScopeLogs []struct {
+			Scope      struct{ Name string } `json:"scope"`
+			LogRecords []struct {
+				TimeUnixNano   string                 `json:"timeUnixNano"`
+				SeverityNumber int                    `json:"severityNumber"`
+				SeverityText   string                 `json:"severityText"`
+				Body           map[string]interface{} `json:"body"`
+				Attributes     []otlpJSONKeyValue     `json:"attributes"`
+				TraceID        string                 `json:"traceId"`
+				SpanID         string                 `json:"spanId"`
+			} `json:"logRecords"`
+		} `json:"scopeLogs"`
+	} `json:"resourceLogs"`
+}
+
+type otlpJSONKeyValue struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+func otlpJSONAttributes(attributes []otlpJSONKeyValue) map[string]map[string]interface{} {
+	result := make(map[string]map[string]interface{}, len(attributes))
+	for _, kv := range attributes {
+		result[kv.Key] = kv.Value
+	}
+	return result
+}
+
+func TestOTLPBackend_JSON(t *testing.T) {
+	collector := &fakeOTLPCollector{}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	backend := newOTLPBackend(t, backends.OTLPConfig{
+		URL:                server.URL,
+		Encoding:           backends.OTLPEncodingJSON,
+		ServiceName:        "checkout",
+		ResourceAttributes: map[string]string{"deployment.environment": "prod"},
+		Headers:            map[string]string{"X-Api-Key": "secret"},
+	})
+
+	timestamp := time.Date(2024, 3, 10, 4, 30, 0, 0, time.UTC)
+	global := map[string]interface{}{"host.name": "web-1"}
+	_, _ = backend.WriteRecord(backends.Record{
+		Timestamp: timestamp,
+		Level:     "warn",
+		Message:   "slow payment",
+		Fields: map[string]interface{}{
+			"host.name":   "web-1",
+			"duration_ms": 1200,
+			"ratio":       0.5,
+			"retry":       true,
+			"tags":        []interface{}{"a", "b"},
+			"trace_id":    testTraceID,
+			"span_id":     testSpanID,
+		},
+		Global: global,
+	})
+	_, _ = backend.WriteRecord(backends.Record{
+		Timestamp: timestamp,
+		Level:     "info",
+		Message:   "other host",
+		Fields:    map[string]interface{}{"host.name": "web-2", "trace_id": "not-hex"},
+		Global:    map[string]interface{}{"host.name": "web-2"},
+	})
+	if err := backend.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	if len(collector.bodies) != 1 {
+		t.Fatalf("Expected 1 request, got %d", len(collector.bodies))
+	}
+	if ct := collector.headers[0].Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Unexpected Content-Type %q", ct)
+	}
+	if collector.headers[0].Get("X-Api-Key") != "secret" {
+		t.Error("Expected the configured header")
+	}
+
+	var request otlpJSON
+	if err := json.Unmarshal(collector.bodies[0], &request); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	// Records with different global fields belong to different resources
+	if len(request.ResourceLogs) != 2 {
+		t.Fatalf("Expected 2 resources, got %d", len(request.ResourceLogs))
+	}
+
+	resource := otlpJSONAttributes(request.ResourceLogs[0].Resource.Attributes)
+	for key, value := range map[string]string{"service.name": "checkout", "deployment.environment": "prod", "host.name": "web-1"} {
+		if resource[key]["stringValue"] != value {
+			t.Errorf("Expected resource %s=%q, got %v", key, value, resource[key])
+		}
+	}
+
+	scope := request.ResourceLogs[0].ScopeLogs[0]
+	if scope.Scope.Name != "github.com/wayneeseguin/omni" || len(scope.LogRecords) != 1 {
+		t.Fatalf("Unexpected scope logs %+v", scope)
+	}
+	record := scope.LogRecords[0]
+	if record.TimeUnixNano != "1710045000000000000" || record.SeverityNumber != 13 || record.SeverityText != "WARN" {
+		t.Errorf("Unexpected record header %+v", record)
+	}
+	if record.Body["stringValue"] != "slow payment" {
+		t.Errorf("Unexpected body %v", record.Body)
+	}
+	if record.TraceID != testTraceID || record.SpanID != testSpanID {
+		t.Errorf("Unexpected trace context %q/%q", record.TraceID, record.SpanID)
+	}
+
+	attributes := otlpJSONAttributes(record.Attributes)
+	if len(attributes) != 4 {
+		t.Errorf("Expected 4 attributes without global or trace fields, got %v", attributes)
+	}
+	if attributes["duration_ms"]["intValue"] != "1200" || attributes["ratio"]["doubleValue"] != 0.5 || attributes["retry"]["boolValue"] != true {
+		t.Errorf("Unexpected scalar attributes %v", attributes)
+	}
+	if values := attributes["tags"]["arrayValue"].(map[string]interface{})["values"].([]interface{}); len(values) != 2 {
+		t.Errorf("Unexpected array attribute %v", attributes["tags"])
+	}
+
+	// Invalid IDs stay attributes
+	other := request.ResourceLogs[1].ScopeLogs[0].LogRecords[0]
+	if other.TraceID != "" || otlpJSONAttributes(other.Attributes)["trace_id"]["stringValue"] != "not-hex" {
+		t.Errorf("Unexpected record %+v", other)
+	}
+}
+
+// protoMessage decodes a protobuf message into its fields, keeping varint
+// and fixed values as uint64 and length-delimited values as []byte
+func protoMessage(t *testing.T, data []byte) map[int][]interface{} {
+	t.Helper()
+	fields := make(map[int][]interface{})
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		data = data[n:]
+		field := int(key >> 3)
+		switch key & 7 {
+		case 0:
+			value, n := binary.Uvarint(data)
+			data = data[n:]
+			fields[field] = append(fields[field], value)
+		case 1:
+			fields[field] = append(fields[field], binary.LittleEndian.Uint64(data))
+			data = data[8:]
+		case 2:
+			size, n := binary.Uvarint(data)
+			fields[field] = append(fields[field], data[n:n+int(size)])
+			data = data[n+int(size):]
+		default:
+			t.Fatalf("Unexpected wire type %d", key&7)
+		}
+	}
+	return fields
+}
+
+func TestOTLPBackend_Protobuf(t *testing.T) {
+	collector := &fakeOTLPCollector{}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	backend := newOTLPBackend(t, backends.OTLPConfig{URL: server.URL + "/v1/logs", ServiceName: "checkout"})
+
+	timestamp := time.Unix(1710045000, 5)
+	_, _ = backend.WriteRecord(backends.Record{
+		Timestamp: timestamp,
+		Level:     "error",
+		Message:   "failed",
+		Fields:    map[string]interface{}{"attempt": -2, "ratio": 0.25, "trace_id": testTraceID},
+	})
+	if err := backend.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	if ct := collector.headers[0].Get("Content-Type"); ct != "application/x-protobuf" {
+		t.Errorf("Unexpected Content-Type %q", ct)
+	}
+
+	request := protoMessage(t, collector.bodies[0])
+	resourceLogs := protoMessage(t, request[1][0].([]byte))
+	resource := protoMessage(t, resourceLogs[1][0].([]byte))
+	serviceName := protoMessage(t, resource[1][0].([]byte))
+	if string(serviceName[1][0].([]byte)) != "service.name" || string(protoMessage(t, serviceName[2][0].([]byte))[1][0].([]byte)) != "checkout" {
+		t.Errorf("Unexpected resource attribute %v", serviceName)
+	}
+
+	scopeLogs := protoMessage(t, resourceLogs[2][0].([]byte))
+	record := protoMessage(t, scopeLogs[2][0].([]byte))
+	if record[1][0] != uint64(timestamp.UnixNano()) || record[2][0] != uint64(17) || string(record[3][0].([]byte)) != "ERROR" {
+		t.Errorf("Unexpected record header %v", record)
+	}
+	if body := protoMessage(t, record[5][0].([]byte)); string(body[1][0].([]byte)) != "failed" {
+		t.Errorf("Unexpected body %v", body)
+	}
+	if len(record[9]) != 1 || len(record[9][0].([]byte)) != 16 || len(record[10]) != 0 {
+		t.Errorf("Expected a 16 byte trace ID and no span ID, got %v", record)
+	}
+
+	attributes := make(map[string]map[int][]interface{})
+	for _, raw := range record[6] {
+		kv := protoMessage(t, raw.([]byte))
+		attributes[string(kv[1][0].([]byte))] = protoMessage(t, kv[2][0].([]byte))
+	}
+	if len(attributes) != 2 {
+		t.Fatalf("Expected 2 attributes, got %v", attributes)
+	}
+	if got := int64(attributes["attempt"][3][0].(uint64)); got != -2 {
+		t.Errorf("Expected attempt=-2, got %d", got)
+	}
+	if got := math.Float64frombits(attributes["ratio"][4][0].(uint64)); got != 0.25 {
+		t.Errorf("Expected ratio=0.25, got %v", got)
+	}
+}
+
+func TestOTLPBackend_PartialSuccess(t *testing.T) {
+	collector := &fakeOTLPCollector{responses: []func(w http.ResponseWriter){
+		func(w http.ResponseWriter) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"partialSuccess":{"rejectedLogRecords":"1","errorMessage":"record too large"}}`))
+		},
+		func(w http.ResponseWriter) {
+			// partial_success { rejected_log_records: 2, error_message: "bad" }
+			w.Header().Set("Content-Type", "application/x-protobuf")
+			_, _ = w.Write([]byte{0x0a, 0x07, 0x08, 0x02, 0x12, 0x03, 'b', 'a', 'd'})
+		},
+	}}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	backend := newOTLPBackend(t, backends.OTLPConfig{URL: server.URL, BatchSize: 3, FlushInterval: -1})
+	for i := 0; i < 3; i++ {
+		_, _ = backend.Write([]byte("entry"))
+	}
+	if err := backend.Flush(); err == nil || !strings.Contains(err.Error(), "record too large") {
+		t.Errorf("Expected partial success error, got %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		_, _ = backend.Write([]byte("entry"))
+	}
+	if err := backend.Flush(); err == nil || !strings.Contains(err.Error(), "rejected 2 of 3") {
+		t.Errorf("Expected partial success error, got %v", err)
+	}
+
+	// Rejected records are not retried
+	if stats := backend.GetStats(); stats.WriteCount != 3 || stats.Dropped != 3 || stats.Requests != 2 || stats.Retries != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestOTLPBackend_Retry(t *testing.T) {
+	collector := &fakeOTLPCollector{responses: []func(w http.ResponseWriter){
+		func(w http.ResponseWriter) { w.WriteHeader(http.StatusServiceUnavailable) },
+	}}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	backend := newOTLPBackend(t, backends.OTLPConfig{URL: server.URL, MinBackoff: time.Millisecond})
+	_, _ = backend.Write([]byte("entry"))
+	if err := backend.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if stats := backend.GetStats(); stats.WriteCount != 1 || stats.Retries != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	if _, err := backends.NewOTLPBackend(backends.OTLPConfig{URL: "collector:4318"}); err == nil {
+		t.Error("Expected error for a URL without scheme")
+	}
+	if _, err := backends.ParseOTLPEncoding("xml"); err == nil {
+		t.Error("Expected error for an unknown encoding")
+	}
+}
@@ -0,0 +1,216 @@
+package backends
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Protobuf encoding of the OTLP logs messages, written by hand to avoid
+// depending on generated code. Field numbers follow
+// opentelemetry/proto/collector/logs/v1/logs_service.proto and the
+// logs/v1, resource/v1 and common/v1 protos it imports.
+
+// Protobuf wire types
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+// protoWriter appends protobuf fields to a buffer
+type protoWriter struct {
+	buf []byte
+}
+
+func (w *protoWriter) tag(field, wireType int) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(field<<3|wireType)) // #nosec G115 - field numbers are small constants
+}
+
+func (w *protoWriter) varint(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	w.tag(field, protoVarint)
+	w.buf = binary.AppendUvarint(w.buf, v)
+}
+
+func (w *protoWriter) fixed64(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	w.tag(field, protoFixed64)
+	w.buf = binary.LittleEndian.AppendUint64(w.buf, v)
+}
+
+func (w *protoWriter) bytes(field int, b []byte) {
+	w.tag(field, protoBytes)
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+func (w *protoWriter) string(field int, s string) {
+	if s == "" {
+		return
+	}
+	w.bytes(field, []byte(s))
+}
+
+// message encodes a nested message with encode and writes it as field
+func (w *protoWriter) message(field int, encode func(*protoWriter)) {
+	var nested protoWriter
+	encode(&nested)
+	w.bytes(field, nested.buf)
+}
+
+// marshalOTLPRequest encodes an ExportLogsServiceRequest
+func marshalOTLPRequest(request otlpLogsRequest) []byte {
+	var w protoWriter
+	for _, resourceLogs := range request.ResourceLogs {
+		w.message(1, func(w *protoWriter) { writeOTLPResourceLogs(w, resourceLogs) })
+	}
+	return w.buf
+}
+
+func writeOTLPResourceLogs(w *protoWriter, resourceLogs otlpResourceLogs) {
+	w.message(1, func(w *protoWriter) {
+		for _, kv := range resourceLogs.Resource.Attributes {
+			w.message(1, func(w *protoWriter) { writeOTLPKeyValue(w, kv) })
+		}
+	})
+	for _, scopeLogs := range resourceLogs.ScopeLogs {
+		w.message(2, func(w *protoWriter) {
+			w.message(1, func(w *protoWriter) { w.string(1, scopeLogs.Scope.Name) })
+			for _, record := range scopeLogs.LogRecords {
+				w.message(2, func(w *protoWriter) { writeOTLPLogRecord(w, record) })
+			}
+		})
+	}
+}
+
+func writeOTLPLogRecord(w *protoWriter, record otlpLogRecord) {
+	w.fixed64(1, record.TimeUnixNano)
+	w.varint(2, uint64(record.SeverityNumber)) // #nosec G115 - severity numbers are 0 to 24
+	w.string(3, record.SeverityText)
+	w.message(5, func(w *protoWriter) { writeOTLPAnyValue(w, record.Body) })
+	for _, kv := range record.Attributes {
+		w.message(6, func(w *protoWriter) { writeOTLPKeyValue(w, kv) })
+	}
+	if len(record.TraceID) > 0 {
+		w.bytes(9, record.TraceID)
+	}
+	if len(record.SpanID) > 0 {
+		w.bytes(10, record.SpanID)
+	}
+	w.fixed64(11, record.ObservedTimeUnixNano)
+}
+
+func writeOTLPKeyValue(w *protoWriter, kv otlpKeyValue) {
+	w.string(1, kv.Key)
+	w.message(2, func(w *protoWriter) { writeOTLPAnyValue(w, kv.Value) })
+}
+
+// writeOTLPAnyValue writes the set member of the AnyValue oneof. Oneof
+// members are written even when they hold the zero value.
+func writeOTLPAnyValue(w *protoWriter, value otlpAnyValue) {
+	switch {
+	case value.StringValue != nil:
+		w.bytes(1, []byte(*value.StringValue))
+	case value.BoolValue != nil:
+		w.tag(2, protoVarint)
+		if *value.BoolValue {
+			w.buf = append(w.buf, 1)
+		} else {
+			w.buf = append(w.buf, 0)
+		}
+	case value.IntValue != nil:
+		w.tag(3, protoVarint)
+		w.buf = binary.AppendUvarint(w.buf, uint64(*value.IntValue)) // #nosec G115 - int64 is encoded as two's complement
+	case value.DoubleValue != nil:
+		w.tag(4, protoFixed64)
+		w.buf = binary.LittleEndian.AppendUint64(w.buf, math.Float64bits(*value.DoubleValue))
+	case value.ArrayValue != nil:
+		w.message(5, func(w *protoWriter) {
+			for _, item := range value.ArrayValue.Values {
+				w.message(1, func(w *protoWriter) { writeOTLPAnyValue(w, item) })
+			}
+		})
+	case value.KvlistValue != nil:
+		w.message(6, func(w *protoWriter) {
+			for _, kv := range value.KvlistValue.Values {
+				w.message(1, func(w *protoWriter) { writeOTLPKeyValue(w, kv) })
+			}
+		})
+	}
+}
+
+// errProtoTruncated reports a protobuf message ending inside a field
+var errProtoTruncated = errors.New("truncated protobuf message")
+
+// protoFields calls fn for each field of a protobuf message with the
+// field's varint value or, for length-delimited fields, its bytes
+func protoFields(data []byte, fn func(field, wireType int, value uint64, b []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errProtoTruncated
+		}
+		data = data[n:]
+		field, wireType := int(key>>3), int(key&7) // #nosec G115 - bounded by the shift and mask
+
+		var value uint64
+		var b []byte
+		switch wireType {
+		case protoVarint:
+			if value, n = binary.Uvarint(data); n <= 0 {
+				return errProtoTruncated
+			}
+			data = data[n:]
+		case protoFixed64:
+			if len(data) < 8 {
+				return errProtoTruncated
+			}
+			value, data = binary.LittleEndian.Uint64(data), data[8:]
+		case protoBytes:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || size > uint64(len(data)-n) {
+				return errProtoTruncated
+			}
+			b, data = data[n:n+int(size)], data[n+int(size):] // #nosec G115 - size is bounded by len(data)
+		case protoFixed32:
+			if len(data) < 4 {
+				return errProtoTruncated
+			}
+			value, data = uint64(binary.LittleEndian.Uint32(data)), data[4:]
+		default:
+			return fmt.Errorf("unsupported protobuf wire type %d", wireType)
+		}
+
+		if err := fn(field, wireType, value, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unmarshalOTLPPartialSuccess decodes the partial_success field of an
+// ExportLogsServiceResponse
+func unmarshalOTLPPartialSuccess(data []byte) (rejected int64, message string, err error) {
+	err = protoFields(data, func(field, wireType int, _ uint64, b []byte) error {
+		if field != 1 || wireType != protoBytes {
+			return nil
+		}
+		return protoFields(b, func(field, wireType int, value uint64, b []byte) error {
+			switch {
+			case field == 1 && wireType == protoVarint:
+				rejected = int64(value) // #nosec G115 - int64 is encoded as two's complement
+			case field == 2 && wireType == protoBytes:
+				message = string(b)
+			}
+			return nil
+		})
+	})
+	return rejected, message, err
+}
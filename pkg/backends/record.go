@@ -0,0 +1,22 @@
+package backends
+
+import "time"
+
+// Record is a structured log entry for backends whose wire format carries
+// the timestamp, level or fields separately from the entry text
+type Record struct {
+	Timestamp  time.Time
+	Level      string                 // Lower-case level name: trace, debug, info, warn or error
+	Message    string                 // Message without timestamp, level or fields
+	Fields     map[string]interface{} // Entry fields, including the logger's global fields
+	Global     map[string]interface{} // The logger's global fields, also merged into Fields
+	Line       []byte                 // The entry as formatted by the logger
+	SourceFile string                 // Source file of the log call, when known
+	SourceLine int                    // Line in SourceFile
+}
+
+// RecordWriter is implemented by backends that take structured records.
+// Loggers call WriteRecord instead of Write for such backends.
+type RecordWriter interface {
+	WriteRecord(rec Record) (int, error)
+}
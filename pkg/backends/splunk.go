@@ -0,0 +1,481 @@
+package backends
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults for SplunkHECConfig
+const (
+	DefaultSplunkAckTimeout      = 60 * time.Second
+	DefaultSplunkAckPollInterval = time.Second
+)
+
+// Paths of the HTTP Event Collector APIs
+const (
+	splunkEventPath = "/services/collector/event"
+	splunkAckPath   = "/services/collector/ack"
+)
+
+// SplunkHECConfig configures a Splunk HTTP Event Collector backend
+type SplunkHECConfig struct {
+	URL             string // HEC base URL, e.g. "https://splunk:8088"
+	Token           string // HEC token, sent as "Authorization: Splunk <token>"
+	Host            string // Event metadata; empty values use the token's defaults
+	Source          string
+	SourceType      string
+	Index           string
+	Ack             bool          // Count batches as delivered once the indexers acknowledge them
+	Channel         string        // Channel GUID for acknowledgements; generated when empty
+	AckTimeout      time.Duration // Unacknowledged batches are resent after this; 0 = DefaultSplunkAckTimeout
+	AckPollInterval time.Duration // 0 = DefaultSplunkAckPollInterval
+	Compress        bool          // Gzip request bodies
+	BatchSize       int           // Events per request; 0 = DefaultHTTPBatchSize
+	BatchBytes      int           // Bytes per request; 0 = DefaultHTTPBatchBytes
+	FlushInterval   time.Duration // 0 = DefaultHTTPFlushInterval, negative disables
+	Timeout         time.Duration // 0 = DefaultHTTPTimeout
+	MaxRetries      int           // Retries after failures and missing acknowledgements; 0 = DefaultHTTPMaxRetries, negative disables
+	MinBackoff      time.Duration // 0 = DefaultHTTPMinBackoff
+	MaxBackoff      time.Duration // 0 = DefaultHTTPMaxBackoff
+	MaxInFlight     int           // Concurrent requests; 0 = DefaultHTTPMaxInFlight
+	TLSConfig       *tls.Config
+	Client          *http.Client
+}
+
+// splunkEvent is the HEC event envelope
+type splunkEvent struct {
+	Time       json.Number `json:"time"`
+	Host       string      `json:"host,omitempty"`
+	Source     string      `json:"source,omitempty"`
+	SourceType string      `json:"sourcetype,omitempty"`
+	Index      string      `json:"index,omitempty"`
+	Event      interface{} `json:"event"`
+}
+
+// SplunkHECBackend sends entries to a Splunk HTTP Event Collector. Each entry
+// is wrapped in the HEC event envelope with its timestamp as the event time.
+// With Ack enabled, every batch is sent on a channel and only counts as
+// delivered once the indexers acknowledge it. One goroutine polls the
+// acknowledgements of all outstanding batches; batches not acknowledged
+// within AckTimeout are sent again, giving at-least-once delivery.
+type SplunkHECBackend struct {
+	batcher         *httpBatcher
+	client          *http.Client
+	path            string
+	ackURL          string
+	header          http.Header
+	host            string
+	source          string
+	sourceType      string
+	index           string
+	ackTimeout      time.Duration
+	ackPollInterval time.Duration
+
+	ackMu      sync.Mutex
+	acks       map[uint64]*splunkAck // Batches awaiting acknowledgement by ack ID
+	resending  int                   // Batches being sent again
+	closing    bool
+	ackDone    chan struct{}
+	ackStopped chan struct{}
+}
+
+// splunkEntry is a queued event envelope
+type splunkEntry struct {
+	data    []byte
+	resends int // Times sent again after a missing acknowledgement
+}
+
+// splunkAck is a batch accepted by HEC and awaiting acknowledgement
+type splunkAck struct {
+	batch    []interface{}
+	size     int
+	deadline time.Time
+}
+
+// NewSplunkHECBackend creates a Splunk HEC backend and starts its flush
+// interval timer
+func NewSplunkHECBackend(config SplunkHECConfig) (*SplunkHECBackend, error) {
+	endpoint, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Splunk HEC URL: %w", err)
+	}
+	if (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid Splunk HEC URL %q", config.URL)
+	}
+	if config.Token == "" {
+		return nil, fmt.Errorf("splunk HEC token is required")
+	}
+	base := strings.TrimSuffix(endpoint.Path, "/")
+	base = strings.TrimSuffix(base, splunkEventPath)
+
+	if config.AckTimeout <= 0 {
+		config.AckTimeout = DefaultSplunkAckTimeout
+	}
+	if config.AckPollInterval <= 0 {
+		config.AckPollInterval = DefaultSplunkAckPollInterval
+	}
+
+	header := http.Header{}
+	header.Set("User-Agent", "omni")
+	header.Set("Content-Type", "application/json")
+	header.Set("Authorization", "Splunk "+config.Token)
+	if config.Ack {
+		if config.Channel == "" {
+			if config.Channel, err = newChannelID(); err != nil {
+				return nil, fmt.Errorf("generate HEC channel: %w", err)
+			}
+		}
+		header.Set("X-Splunk-Request-Channel", config.Channel)
+	}
+
+	sb := &SplunkHECBackend{
+		client:          httpClient(config.Client, config.Timeout, config.TLSConfig),
+		header:          header,
+		host:            config.Host,
+		source:          config.Source,
+		sourceType:      config.SourceType,
+		index:           config.Index,
+		ackTimeout:      config.AckTimeout,
+		ackPollInterval: config.AckPollInterval,
+	}
+
+	endpoint.Path = base + splunkAckPath
+	endpoint.RawQuery = url.Values{"channel": {config.Channel}}.Encode()
+	sb.ackURL = endpoint.String()
+	endpoint.Path = base + splunkEventPath
+	endpoint.RawQuery = ""
+	eventURL := endpoint.String()
+	endpoint.User = nil
+	sb.path = endpoint.String()
+
+	sb.batcher = newHTTPBatcher(httpBatchConfig{
+		client:        sb.client,
+		url:           eventURL,
+		header:        header,
+		compress:      config.Compress,
+		batchSize:     config.BatchSize,
+		batchBytes:    config.BatchBytes,
+		flushInterval: config.FlushInterval,
+		maxRetries:    config.MaxRetries,
+		minBackoff:    config.MinBackoff,
+		maxBackoff:    config.MaxBackoff,
+		maxInFlight:   config.MaxInFlight,
+	}, encodeSplunkEvents)
+	if config.Ack {
+		sb.batcher.check = sb.checkAck
+		sb.batcher.confirmed = true
+		sb.acks = make(map[uint64]*splunkAck)
+		sb.ackDone = make(chan struct{})
+		sb.ackStopped = make(chan struct{})
+		go sb.ackLoop()
+	}
+
+	return sb, nil
+}
+
+// WriteRecord queues an entry as an event with the message, level and fields
+func (sb *SplunkHECBackend) WriteRecord(rec Record) (int, error) {
+	event := make(map[string]interface{}, len(rec.Fields)+2)
+	for key, value := range rec.Fields {
+		event[key] = value
+	}
+	if rec.Level != "" {
+		event["level"] = rec.Level
+	}
+	event["message"] = rec.Message
+
+	if err := sb.queue(rec.Timestamp, event); err != nil {
+		return 0, err
+	}
+	return len(rec.Line), nil
+}
+
+// Write queues an entry timestamped now. JSON objects are sent as the event
+// object, other entries as the event text.
+func (sb *SplunkHECBackend) Write(entry []byte) (int, error) {
+	line := bytes.TrimSpace(entry)
+	var event interface{} = string(line)
+	var object map[string]interface{}
+	if json.Unmarshal(line, &object) == nil && object != nil {
+		event = object
+	}
+
+	if err := sb.queue(time.Time{}, event); err != nil {
+		return 0, err
+	}
+	return len(entry), nil
+}
+
+// queue wraps an event in the HEC envelope and queues it
+func (sb *SplunkHECBackend) queue(timestamp time.Time, event interface{}) error {
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	data, err := json.Marshal(splunkEvent{
+		Time:       splunkTime(timestamp),
+		Host:       sb.host,
+		Source:     sb.source,
+		SourceType: sb.sourceType,
+		Index:      sb.index,
+		Event:      event,
+	})
+	if err != nil {
+		return fmt.Errorf("encode event: %w", err)
+	}
+	return sb.batcher.add(&splunkEntry{data: data}, len(data))
+}
+
+// Flush sends the queued events and waits for all requests, returning the
+// delivery failures since the last Flush. It does not wait for
+// acknowledgements; batches given up on after missing theirs are reported by
+// a later Flush or Close.
+func (sb *SplunkHECBackend) Flush() error {
+	return sb.batcher.flush()
+}
+
+// Close sends the queued events and stops the flush interval timer. With Ack
+// enabled it then waits for the outstanding acknowledgements, at most
+// AckTimeout, without resending; batches still unacknowledged are dropped.
+func (sb *SplunkHECBackend) Close() error {
+	if sb.acks == nil {
+		return sb.batcher.close()
+	}
+
+	sb.ackMu.Lock()
+	if sb.closing {
+		sb.ackMu.Unlock()
+		return nil
+	}
+	sb.closing = true
+	sb.ackMu.Unlock()
+
+	err := sb.batcher.close()
+	close(sb.ackDone)
+	<-sb.ackStopped
+	return errors.Join(err, sb.batcher.flush())
+}
+
+// Batching returns true as events are sent in batches on their own schedule
+func (sb *SplunkHECBackend) Batching() bool {
+	return true
+}
+
+// SupportsAtomic returns false as batches are delivered asynchronously
+func (sb *SplunkHECBackend) SupportsAtomic() bool {
+	return false
+}
+
+// Sync sends the queued events
+func (sb *SplunkHECBackend) Sync() error {
+	return sb.Flush()
+}
+
+// GetStats returns backend statistics, as for HTTPBackend. With Ack enabled,
+// WriteCount only counts acknowledged events and Buffered includes the
+// events awaiting acknowledgement.
+func (sb *SplunkHECBackend) GetStats() BackendStats {
+	stats := sb.batcher.stats(sb.path)
+	sb.ackMu.Lock()
+	for _, ack := range sb.acks {
+		stats.Buffered += len(ack.batch)
+	}
+	sb.ackMu.Unlock()
+	return stats
+}
+
+// encodeSplunkEvents concatenates the event envelopes of a batch
+func encodeSplunkEvents(batch []interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, item := range batch {
+		buf.Write(item.(*splunkEntry).data)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// splunkResponse is the body of a HEC event or acknowledgement response
+type splunkResponse struct {
+	Text  string          `json:"text"`
+	Code  int             `json:"code"`
+	AckID *uint64         `json:"ackId"`
+	Acks  map[string]bool `json:"acks"`
+}
+
+// checkAck records an accepted batch as awaiting acknowledgement; ackLoop
+// counts it as delivered once the indexers acknowledge it
+func (sb *SplunkHECBackend) checkAck(resp *http.Response, body []byte, batch []interface{}) ([]interface{}, int, error) {
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, len(batch), httpStatusError(resp, body)
+	}
+
+	size := 0
+	for _, item := range batch {
+		size += len(item.(*splunkEntry).data) + 1
+	}
+
+	var result splunkResponse
+	if err := json.Unmarshal(body, &result); err != nil || result.AckID == nil {
+		// Acknowledgement is disabled for the token; the events were accepted
+		sb.batcher.delivered(len(batch), size)
+		return nil, 0, nil
+	}
+
+	sb.ackMu.Lock()
+	sb.acks[*result.AckID] = &splunkAck{batch: batch, size: size, deadline: time.Now().Add(sb.ackTimeout)}
+	sb.ackMu.Unlock()
+	return nil, 0, nil
+}
+
+// ackLoop polls the outstanding acknowledgements every poll interval. Once
+// Close has sent the queued events it returns when none are left.
+func (sb *SplunkHECBackend) ackLoop() {
+	defer close(sb.ackStopped)
+
+	ticker := time.NewTicker(sb.ackPollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		sb.pollAcks()
+
+		select {
+		case <-sb.ackDone:
+			sb.ackMu.Lock()
+			idle := len(sb.acks) == 0 && sb.resending == 0
+			sb.ackMu.Unlock()
+			if idle {
+				return
+			}
+		default:
+		}
+	}
+}
+
+// pollAcks asks for the acknowledgements of all outstanding batches in one
+// request. Batches past their deadline are sent again, unless they are out of
+// retries or the backend is closing.
+func (sb *SplunkHECBackend) pollAcks() {
+	sb.ackMu.Lock()
+	ids := make([]uint64, 0, len(sb.acks))
+	for id := range sb.acks {
+		ids = append(ids, id)
+	}
+	sb.ackMu.Unlock()
+	if len(ids) == 0 {
+		return
+	}
+
+	acked, pollErr := sb.queryAcks(ids)
+	now := time.Now()
+
+	var resend [][]interface{}
+	var errs []error
+	sb.ackMu.Lock()
+	for _, id := range ids {
+		ack := sb.acks[id]
+		if acked[id] {
+			delete(sb.acks, id)
+			sb.batcher.delivered(len(ack.batch), ack.size)
+			continue
+		}
+		if !now.After(ack.deadline) {
+			continue
+		}
+
+		delete(sb.acks, id)
+		sb.batcher.failed()
+		if !sb.closing && ack.batch[0].(*splunkEntry).resends < sb.batcher.maxRetries {
+			for _, item := range ack.batch {
+				item.(*splunkEntry).resends++
+			}
+			sb.resending++
+			resend = append(resend, ack.batch)
+			continue
+		}
+
+		err := fmt.Errorf("ack %d not received within %s", id, sb.ackTimeout)
+		if pollErr != nil {
+			err = fmt.Errorf("%w: %w", err, pollErr)
+		}
+		sb.batcher.fail(len(ack.batch))
+		errs = append(errs, err)
+	}
+	sb.ackMu.Unlock()
+
+	for _, err := range errs {
+		sb.batcher.report(err)
+	}
+	for _, batch := range resend {
+		go func(batch []interface{}) {
+			sb.batcher.resend(batch)
+			sb.ackMu.Lock()
+			sb.resending--
+			sb.ackMu.Unlock()
+		}(batch)
+	}
+}
+
+// queryAcks asks which of the given ack IDs have been indexed
+func (sb *SplunkHECBackend) queryAcks(ids []uint64) (map[uint64]bool, error) {
+	payload, err := json.Marshal(map[string][]uint64{"acks": ids})
+	if err != nil {
+		return nil, fmt.Errorf("encode ack request: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, sb.ackURL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("create ack request: %w", err)
+	}
+	for key, values := range sb.header {
+		req.Header[key] = values
+	}
+
+	resp, err := sb.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("poll acks: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("poll acks: %w", httpStatusError(resp, body))
+	}
+
+	var result splunkResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("decode ack response: %w", err)
+	}
+	acked := make(map[uint64]bool, len(result.Acks))
+	for key, ok := range result.Acks {
+		if id, err := strconv.ParseUint(key, 10, 64); err == nil && ok {
+			acked[id] = true
+		}
+	}
+	return acked, nil
+}
+
+// splunkTime formats t as epoch seconds with millisecond precision
+func splunkTime(t time.Time) json.Number {
+	ms := t.UnixMilli()
+	return json.Number(fmt.Sprintf("%d.%03d", ms/1000, ms%1000))
+}
+
+// newChannelID returns a random UUID for a HEC acknowledgement channel
+func newChannelID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40 // Version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
@@ -0,0 +1,300 @@
+package backends_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/backends"
+)
+
+// fakeHEC is a Splunk HTTP Event Collector. With ack set it hands out ack
+// IDs, acknowledging a request once it has been polled ackAfter times;
+// requests listed in lost are never acknowledged.
+type fakeHEC struct {
+	mu       sync.Mutex
+	ack      bool
+	ackAfter int
+	lost     map[uint64]bool
+	events   []map[string]interface{}
+	headers  []http.Header
+	channels []string
+	nextAck  uint64
+	polls    map[uint64]int
+}
+
+func (f *fakeHEC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Splunk test-token" {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"text":"Invalid token","code":4}`)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/services/collector/event":
+		f.headers = append(f.headers, r.Header)
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var event map[string]interface{}
+			_ = json.Unmarshal(scanner.Bytes(), &event)
+			f.events = append(f.events, event)
+		}
+		if !f.ack {
+			fmt.Fprint(w, `{"text":"Success","code":0}`)
+			return
+		}
+		fmt.Fprintf(w, `{"text":"Success","code":0,"ackId":%d}`, f.nextAck)
+		f.nextAck++
+	case "/services/collector/ack":
+		f.channels = append(f.channels, r.URL.Query().Get("channel"))
+		var req struct {
+			Acks []uint64 `json:"acks"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		acks := make(map[string]bool)
+		for _, id := range req.Acks {
+			f.polls[id]++
+			acks[fmt.Sprint(id)] = !f.lost[id] && f.polls[id] > f.ackAfter
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"acks": acks})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newSplunkHECBackend(t *testing.T, config backends.SplunkHECConfig) *backends.SplunkHECBackend {
+	t.Helper()
+	backend, err := backends.NewSplunkHECBackend(config)
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	t.Cleanup(func() { backend.Close() })
+	return backend
+}
+
+func TestSplunkHECBackend_Envelope(t *testing.T) {
+	hec := &fakeHEC{}
+	server := httptest.NewServer(hec)
+	defer server.Close()
+
+	backend := newSplunkHECBackend(t, backends.SplunkHECConfig{
+		URL:        server.URL,
+		Token:      "test-token",
+		Host:       "web-1",
+		Source:     "omni",
+		SourceType: "_json",
+		Index:      "app",
+	})
+
+	timestamp := time.Date(2024, 3, 10, 4, 30, 0, 250*int(time.Millisecond), time.UTC)
+	_, _ = backend.WriteRecord(backends.Record{
+		Timestamp: timestamp,
+		Level:     "error",
+		Message:   "payment failed",
+		Fields:    map[string]interface{}{"order": "A-17"},
+	})
+	_, _ = backend.Write([]byte("plain text\n"))
+	if err := backend.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	if len(hec.events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(hec.events))
+	}
+	event := hec.events[0]
+	if event["time"] != 1710045000.25 {
+		t.Errorf("Unexpected time %v", event["time"])
+	}
+	for key, value := range map[string]string{"host": "web-1", "source": "omni", "sourcetype": "_json", "index": "app"} {
+		if event[key] != value {
+			t.Errorf("Expected %s=%q, got %v", key, value, event[key])
+		}
+	}
+	body, _ := event["event"].(map[string]interface{})
+	if body["message"] != "payment failed" || body["level"] != "error" || body["order"] != "A-17" {
+		t.Errorf("Unexpected event %v", event["event"])
+	}
+	if hec.events[1]["event"] != "plain text" {
+		t.Errorf("Expected text event, got %v", hec.events[1]["event"])
+	}
+	if hec.headers[0].Get("X-Splunk-Request-Channel") != "" {
+		t.Error("Expected no channel without acknowledgement")
+	}
+}
+
+func TestSplunkHECBackend_Ack(t *testing.T) {
+	hec := &fakeHEC{ack: true, ackAfter: 2, polls: make(map[uint64]int)}
+	server := httptest.NewServer(hec)
+	defer server.Close()
+
+	backend := newSplunkHECBackend(t, backends.SplunkHECConfig{
+		URL:             server.URL + "/services/collector/event",
+		Token:           "test-token",
+		Ack:             true,
+		AckPollInterval: time.Millisecond,
+	})
+
+	_, _ = backend.WriteRecord(backends.Record{Message: "acknowledged"})
+	if err := backend.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	channel := hec.headers[0].Get("X-Splunk-Request-Channel")
+	if len(channel) != 36 {
+		t.Errorf("Expected a generated channel GUID, got %q", channel)
+	}
+	if len(hec.channels) != 3 || hec.channels[0] != channel {
+		t.Errorf("Expected 3 polls on channel %q, got %v", channel, hec.channels)
+	}
+	if stats := backend.GetStats(); stats.WriteCount != 1 || stats.Retries != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestSplunkHECBackend_AckTimeout(t *testing.T) {
+	// The first request is never acknowledged, so its events are sent again
+	hec := &fakeHEC{ack: true, lost: map[uint64]bool{0: true}, polls: make(map[uint64]int)}
+	server := httptest.NewServer(hec)
+	defer server.Close()
+
+	backend := newSplunkHECBackend(t, backends.SplunkHECConfig{
+		URL:             server.URL,
+		Token:           "test-token",
+		Ack:             true,
+		Channel:         "8b0d6f3e-2a8c-4c55-9a62-3c1f4e0d7a11",
+		AckTimeout:      20 * time.Millisecond,
+		AckPollInterval: 5 * time.Millisecond,
+		MinBackoff:      time.Millisecond,
+	})
+
+	_, _ = backend.WriteRecord(backends.Record{Message: "at least once"})
+	if err := backend.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	waitForSplunkStats(t, backend, func(stats backends.BackendStats) bool { return stats.WriteCount == 1 })
+	if err := backend.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	hec.mu.Lock()
+	defer hec.mu.Unlock()
+	if len(hec.events) != 2 || hec.events[1]["event"].(map[string]interface{})["message"] != "at least once" {
+		t.Errorf("Expected the event to be sent twice, got %v", hec.events)
+	}
+	if hec.headers[0].Get("X-Splunk-Request-Channel") != "8b0d6f3e-2a8c-4c55-9a62-3c1f4e0d7a11" {
+		t.Errorf("Unexpected channel %q", hec.headers[0].Get("X-Splunk-Request-Channel"))
+	}
+	if stats := backend.GetStats(); stats.WriteCount != 1 || stats.Retries != 1 || stats.Dropped != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestSplunkHECBackend_FlushDoesNotWaitForAcks(t *testing.T) {
+	hec := &fakeHEC{ack: true, ackAfter: 20, polls: make(map[uint64]int)}
+	server := httptest.NewServer(hec)
+	defer server.Close()
+
+	backend := newSplunkHECBackend(t, backends.SplunkHECConfig{
+		URL:             server.URL,
+		Token:           "test-token",
+		Ack:             true,
+		BatchSize:       1,
+		AckTimeout:      time.Minute,
+		AckPollInterval: 5 * time.Millisecond,
+	})
+
+	for i := 0; i < 3; i++ {
+		_, _ = backend.WriteRecord(backends.Record{Message: fmt.Sprintf("event %d", i)})
+	}
+	start := time.Now()
+	if err := backend.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Flush waited %s for acknowledgements", elapsed)
+	}
+	if stats := backend.GetStats(); stats.WriteCount != 0 || stats.Buffered != 3 {
+		t.Errorf("Expected 3 events awaiting acknowledgement, got %+v", stats)
+	}
+
+	// Close waits for the outstanding acknowledgements, polled together
+	if err := backend.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if stats := backend.GetStats(); stats.WriteCount != 3 || stats.Buffered != 0 {
+		t.Errorf("Expected 3 acknowledged events, got %+v", stats)
+	}
+	hec.mu.Lock()
+	defer hec.mu.Unlock()
+	if len(hec.channels) > 25 {
+		t.Errorf("Expected the acks to be polled in shared requests, got %d polls", len(hec.channels))
+	}
+}
+
+func TestSplunkHECBackend_CloseDropsUnacknowledged(t *testing.T) {
+	hec := &fakeHEC{ack: true, lost: map[uint64]bool{0: true}, polls: make(map[uint64]int)}
+	server := httptest.NewServer(hec)
+	defer server.Close()
+
+	backend := newSplunkHECBackend(t, backends.SplunkHECConfig{
+		URL:             server.URL,
+		Token:           "test-token",
+		Ack:             true,
+		AckTimeout:      50 * time.Millisecond,
+		AckPollInterval: 5 * time.Millisecond,
+	})
+
+	_, _ = backend.WriteRecord(backends.Record{Message: "lost"})
+	start := time.Now()
+	if err := backend.Close(); err == nil || !strings.Contains(err.Error(), "ack 0 not received") {
+		t.Errorf("Expected an ack timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Close waited %s, expected at most about the ack timeout", elapsed)
+	}
+	if stats := backend.GetStats(); stats.Dropped != 1 || stats.Retries != 0 || stats.Buffered != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+// waitForSplunkStats waits up to a few seconds for the stats to satisfy done
+func waitForSplunkStats(t *testing.T, backend *backends.SplunkHECBackend, done func(backends.BackendStats) bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !done(backend.GetStats()) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for stats, got %+v", backend.GetStats())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSplunkHECBackend_Errors(t *testing.T) {
+	server := httptest.NewServer(&fakeHEC{})
+	defer server.Close()
+
+	backend := newSplunkHECBackend(t, backends.SplunkHECConfig{URL: server.URL, Token: "wrong"})
+	_, _ = backend.Write([]byte("rejected"))
+	if err := backend.Flush(); err == nil || !strings.Contains(err.Error(), "Invalid token") {
+		t.Errorf("Expected invalid token error, got %v", err)
+	}
+	if stats := backend.GetStats(); stats.Dropped != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	if _, err := backends.NewSplunkHECBackend(backends.SplunkHECConfig{URL: server.URL}); err == nil {
+		t.Error("Expected error without a token")
+	}
+	if _, err := backends.NewSplunkHECBackend(backends.SplunkHECConfig{URL: "splunk:8088", Token: "t"}); err == nil {
+		t.Error("Expected error for a URL without scheme")
+	}
+}
@@ -1,118 +1,336 @@
 package backends
 
 import (
-	"bufio"
+	"encoding/json"
 	"fmt"
-	"net"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
+// SyslogFormat selects how syslog messages are framed
+type SyslogFormat int
+
+const (
+	// SyslogFormatBasic writes "<PRI>TAG: MSG" and leaves the timestamp and
+	// hostname to the receiving syslog daemon
+	SyslogFormatBasic SyslogFormat = iota
+	// SyslogFormatRFC3164 writes BSD syslog messages:
+	// "<PRI>Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG"
+	SyslogFormatRFC3164
+	// SyslogFormatRFC5424 writes IETF syslog messages:
+	// "<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG"
+	SyslogFormatRFC5424
+)
+
+// String returns the name of the format as accepted by ParseSyslogFormat
+func (f SyslogFormat) String() string {
+	switch f {
+	case SyslogFormatRFC3164:
+		return "rfc3164"
+	case SyslogFormatRFC5424:
+		return "rfc5424"
+	default:
+		return "basic"
+	}
+}
+
+// ParseSyslogFormat parses a syslog format name: basic, rfc3164 (or bsd)
+// and rfc5424 (or ietf)
+func ParseSyslogFormat(name string) (SyslogFormat, error) {
+	switch strings.ToLower(name) {
+	case "basic":
+		return SyslogFormatBasic, nil
+	case "rfc3164", "3164", "bsd":
+		return SyslogFormatRFC3164, nil
+	case "rfc5424", "5424", "ietf":
+		return SyslogFormatRFC5424, nil
+	default:
+		return SyslogFormatBasic, fmt.Errorf("unknown syslog format %q", name)
+	}
+}
+
+// Syslog severities (RFC 5424 section 6.2.1)
+const (
+	SyslogSeverityEmergency = iota
+	SyslogSeverityAlert
+	SyslogSeverityCritical
+	SyslogSeverityError
+	SyslogSeverityWarning
+	SyslogSeverityNotice
+	SyslogSeverityInfo
+	SyslogSeverityDebug
+)
+
+// syslogFacilities maps facility names to their codes (RFC 5424 section 6.2.1)
+var syslogFacilities = map[string]int{
+	"kern":     0,
+	"user":     1,
+	"mail":     2,
+	"daemon":   3,
+	"auth":     4,
+	"syslog":   5,
+	"lpr":      6,
+	"news":     7,
+	"uucp":     8,
+	"cron":     9,
+	"authpriv": 10,
+	"ftp":      11,
+	"local0":   16,
+	"local1":   17,
+	"local2":   18,
+	"local3":   19,
+	"local4":   20,
+	"local5":   21,
+	"local6":   22,
+	"local7":   23,
+}
+
+// ParseSyslogFacility parses a facility name such as "local3" or a facility code from 0 to 23
+func ParseSyslogFacility(name string) (int, error) {
+	if facility, ok := syslogFacilities[strings.ToLower(name)]; ok {
+		return facility, nil
+	}
+	if facility, err := strconv.Atoi(name); err == nil && facility >= 0 && facility <= 23 {
+		return facility, nil
+	}
+	return 0, fmt.Errorf("unknown syslog facility %q", name)
+}
+
+// DefaultSyslogSDID is the SD-ID of the structured data element that carries
+// entry fields in RFC 5424 messages. 32473 is the enterprise number reserved
+// for documentation (RFC 5612).
+const DefaultSyslogSDID = "fields@32473"
+
+// rfc5424TimeFormat is the RFC 3339 timestamp with at most six fractional digits
+const rfc5424TimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// SyslogMessage is a log entry to be framed as a syslog message
+type SyslogMessage struct {
+	Severity  int
+	Timestamp time.Time
+	Message   string
+	Fields    map[string]interface{}
+}
+
+// SyslogMessageWriter is implemented by syslog backends, which carry the
+// timestamp and severity of each entry in the syslog header. Loggers call
+// WriteMessage instead of Write for such backends.
+type SyslogMessageWriter interface {
+	WriteMessage(msg SyslogMessage) (int, error)
+
+	// StructuredData reports whether entry fields are sent as RFC 5424
+	// structured data with the entry's message as MSG, rather than the
+	// formatted entry as MSG
+	StructuredData() bool
+}
+
 // SyslogBackendImpl implements the Backend interface for syslog
 type SyslogBackendImpl struct {
-	network  string
-	address  string
-	conn     net.Conn
-	writer   *bufio.Writer
+	sender   netSender
+	framing  SyslogFraming
 	priority int
 	tag      string
-	mu       sync.Mutex // Protects concurrent access to writer
+	format   SyslogFormat
+	hostname string
+	procID   string
+	msgID    string
+	sdID     string
+	sd       bool       // Send entry fields as structured data
+	mu       sync.Mutex // Protects concurrent access to the connection and queue
 }
 
-// NewSyslogBackend creates a new syslog backend
+// NewSyslogBackend creates a new syslog backend with the default transport settings
 func NewSyslogBackend(network, address string, priority int, tag string) (*SyslogBackendImpl, error) {
-	// Default to local syslog if no address specified
-	if address == "" {
-		// Try common Unix socket paths
-		for _, path := range []string{"/dev/log", "/var/run/syslog", "/var/run/log"} {
-			if _, err := os.Stat(path); err == nil {
-				network = "unix"
-				address = path
-				break
-			}
+	return NewSyslogBackendWithConfig(SyslogConfig{
+		Network:  network,
+		Address:  address,
+		Priority: priority,
+		Tag:      tag,
+	})
+}
+
+// Write writes a log entry to syslog at the configured priority
+func (sb *SyslogBackendImpl) Write(entry []byte) (int, error) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	return sb.writeMessage(sb.priority, SyslogMessage{Message: strings.TrimSpace(string(entry))})
+}
+
+// WriteMessage writes a log entry to syslog. The severity of the message
+// replaces the severity of the configured priority; the facility is kept.
+func (sb *SyslogBackendImpl) WriteMessage(msg SyslogMessage) (int, error) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	priority := sb.priority&^7 | msg.Severity&7
+	return sb.writeMessage(priority, msg)
+}
+
+// writeMessage frames and queues msg; it is sent by Flush or once enough
+// data is pending. Callers must hold sb.mu.
+func (sb *SyslogBackendImpl) writeMessage(priority int, msg SyslogMessage) (int, error) {
+	if sb.sender.closed {
+		return 0, os.ErrClosed
+	}
+
+	data := sb.frame(sb.formatMessage(priority, msg))
+	if sb.sender.datagram && len(data) > maxDatagramSize {
+		return 0, fmt.Errorf("message of %d bytes exceeds the maximum datagram size", len(data))
+	}
+	sb.sender.enqueue(data)
+
+	if sb.sender.pendingBytes >= networkFlushBytes {
+		// Unsent messages stay queued and the failure is reported by Flush
+		_ = sb.sender.send()
+	}
+
+	return len(data), nil
+}
+
+// formatMessage frames msg in the configured syslog format
+func (sb *SyslogBackendImpl) formatMessage(priority int, msg SyslogMessage) string {
+	timestamp := msg.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	var b strings.Builder
+	switch sb.format {
+	case SyslogFormatRFC5424:
+		fmt.Fprintf(&b, "<%d>1 %s %s %s %s %s ", priority,
+			timestamp.Format(rfc5424TimeFormat),
+			syslogHeaderField(sb.hostname, 255),
+			syslogHeaderField(sb.tag, 48),
+			syslogHeaderField(sb.procID, 128),
+			syslogHeaderField(sb.msgID, 32))
+		writeStructuredData(&b, sb.sdID, msg.Fields)
+		if msg.Message != "" {
+			b.WriteString(" ")
+			b.WriteString(msg.Message)
+		}
+	case SyslogFormatRFC3164:
+		hostname := sb.hostname
+		if hostname == "" {
+			hostname = "localhost"
 		}
-		if address == "" {
-			return nil, fmt.Errorf("no local syslog socket found")
+		fmt.Fprintf(&b, "<%d>%s %s %s", priority, timestamp.Format(time.Stamp),
+			syslogHeaderField(hostname, 255), syslogHeaderField(sb.tag, 32))
+		if sb.procID != "" {
+			fmt.Fprintf(&b, "[%s]", sb.procID)
 		}
+		fmt.Fprintf(&b, ": %s", msg.Message)
+		writeFieldPairs(&b, msg.Fields)
+	default:
+		fmt.Fprintf(&b, "<%d>%s: %s", priority, sb.tag, msg.Message)
+		writeFieldPairs(&b, msg.Fields)
 	}
+	return b.String()
+}
 
-	// Connect to syslog
-	conn, err := net.Dial(network, address)
-	if err != nil {
-		return nil, fmt.Errorf("dial syslog: %w", err)
+// syslogHeaderField returns value as an RFC 5424 header field: "-" when
+// empty, otherwise printable US-ASCII without spaces, at most max bytes
+func syslogHeaderField(value string, max int) string {
+	if value == "" {
+		return "-"
 	}
+	field := []byte(value)
+	for i, c := range field {
+		if c < 33 || c > 126 {
+			field[i] = '_'
+		}
+	}
+	if len(field) > max {
+		field = field[:max]
+	}
+	return string(field)
+}
 
-	return &SyslogBackendImpl{
-		network:  network,
-		address:  address,
-		conn:     conn,
-		writer:   bufio.NewWriter(conn),
-		priority: priority,
-		tag:      tag,
-	}, nil
+// syslogSDName returns name as an RFC 5424 SD-NAME: printable US-ASCII
+// except '=', ' ', ']' and '"', at most 32 bytes
+func syslogSDName(name string) string {
+	field := []byte(syslogHeaderField(name, 32))
+	for i, c := range field {
+		if c == '=' || c == ']' || c == '"' {
+			field[i] = '_'
+		}
+	}
+	return string(field)
 }
 
-// Write writes a log entry to syslog
-func (sb *SyslogBackendImpl) Write(entry []byte) (int, error) {
-	sb.mu.Lock()
-	defer sb.mu.Unlock()
+// sdParamEscaper escapes the characters RFC 5424 section 6.3.3 requires in PARAM-VALUE
+var sdParamEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
 
-	// Format syslog message: <priority>tag: message
-	message := fmt.Sprintf("<%d>%s: %s", sb.priority, sb.tag, strings.TrimSpace(string(entry)))
+// writeStructuredData writes fields as one SD-ELEMENT, or the NILVALUE when there are none
+func writeStructuredData(b *strings.Builder, sdID string, fields map[string]interface{}) {
+	if len(fields) == 0 {
+		b.WriteString("-")
+		return
+	}
+
+	b.WriteString("[")
+	b.WriteString(syslogSDName(sdID))
+	for _, key := range sortedFieldKeys(fields) {
+		fmt.Fprintf(b, ` %s="%s"`, syslogSDName(key), sdParamEscaper.Replace(syslogFieldValue(fields[key])))
+	}
+	b.WriteString("]")
+}
 
-	n, err := sb.writer.WriteString(message)
-	if err != nil {
-		return n, err
+// writeFieldPairs appends fields as key=value pairs to formats without structured data
+func writeFieldPairs(b *strings.Builder, fields map[string]interface{}) {
+	for _, key := range sortedFieldKeys(fields) {
+		fmt.Fprintf(b, " %s=%s", key, syslogFieldValue(fields[key]))
 	}
+}
 
-	// Add newline if not present
-	if !strings.HasSuffix(message, "\n") {
-		if _, err := sb.writer.WriteString("\n"); err != nil {
-			return n, err
+// syslogFieldValue renders a field value, encoding maps and slices as JSON
+func syslogFieldValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case nil:
+		return "null"
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
 		}
-		n++
+		return string(data)
+	default:
+		return fmt.Sprint(v)
 	}
+}
 
-	return n, nil
+// sortedFieldKeys returns the keys of fields in order
+func sortedFieldKeys(fields map[string]interface{}) []string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
-// Flush flushes buffered data
+// Flush sends the queued messages. While the server is unreachable the
+// messages stay queued and an error is returned.
 func (sb *SyslogBackendImpl) Flush() error {
 	sb.mu.Lock()
 	defer sb.mu.Unlock()
 
-	if sb.writer != nil {
-		return sb.writer.Flush()
-	}
-	return nil
+	return sb.sender.send()
 }
 
-// Close closes the syslog connection
+// Close sends the queued messages, making one reconnect attempt if needed,
+// and closes the syslog connection
 func (sb *SyslogBackendImpl) Close() error {
 	sb.mu.Lock()
 	defer sb.mu.Unlock()
 
-	var errs []error
-
-	// Flush writer
-	if sb.writer != nil {
-		if err := sb.writer.Flush(); err != nil {
-			errs = append(errs, fmt.Errorf("flush: %w", err))
-		}
-	}
-
-	// Close connection
-	if sb.conn != nil {
-		if err := sb.conn.Close(); err != nil {
-			errs = append(errs, fmt.Errorf("close conn: %w", err))
-		}
-	}
-
-	if len(errs) > 0 {
-		return fmt.Errorf("close errors: %v", errs)
-	}
-	return nil
+	return sb.sender.close()
 }
 
 // SupportsAtomic returns false as syslog doesn't support atomic writes
@@ -134,14 +352,73 @@ func (sb *SyslogBackendImpl) SetTag(tag string) {
 	sb.tag = tag
 }
 
+// SetFacility sets the facility of the syslog priority, keeping its severity
+func (sb *SyslogBackendImpl) SetFacility(facility int) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	sb.priority = facility<<3 | sb.priority&7
+}
+
+// SetFormat sets how messages are framed
+func (sb *SyslogBackendImpl) SetFormat(format SyslogFormat) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	sb.format = format
+}
+
+// SetHostname sets the HOSTNAME header field, which defaults to the local hostname
+func (sb *SyslogBackendImpl) SetHostname(hostname string) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	sb.hostname = hostname
+}
+
+// SetMsgID sets the RFC 5424 MSGID header field
+func (sb *SyslogBackendImpl) SetMsgID(msgID string) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	sb.msgID = msgID
+}
+
+// SetStructuredDataID sets the SD-ID of the RFC 5424 structured data element carrying entry fields
+func (sb *SyslogBackendImpl) SetStructuredDataID(sdID string) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	sb.sdID = sdID
+}
+
+// SetStructuredData sets whether entry fields are sent as RFC 5424
+// structured data rather than formatted into the message
+func (sb *SyslogBackendImpl) SetStructuredData(enabled bool) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	sb.sd = enabled
+}
+
+// StructuredData reports whether entry fields are sent as RFC 5424 structured data
+func (sb *SyslogBackendImpl) StructuredData() bool {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.sd && sb.format == SyslogFormatRFC5424
+}
+
 // Sync syncs the backend (flushes for syslog)
 func (sb *SyslogBackendImpl) Sync() error {
 	return sb.Flush()
 }
 
-// GetStats returns backend statistics
+// GetStats returns backend statistics. WriteCount and BytesWritten count
+// messages delivered to the server.
 func (sb *SyslogBackendImpl) GetStats() BackendStats {
-	return BackendStats{
-		Path: fmt.Sprintf("syslog://%s/%s", sb.network, sb.address),
-	}
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	return sb.sender.stats(fmt.Sprintf("syslog://%s/%s", sb.sender.network, sb.sender.address))
+}
+
+// IsConnected reports whether the backend currently holds a connection
+func (sb *SyslogBackendImpl) IsConnected() bool {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.sender.conn != nil
 }
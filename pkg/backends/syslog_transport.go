@@ -0,0 +1,135 @@
+package backends
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SyslogFraming selects how messages are delimited on stream transports
+type SyslogFraming int
+
+const (
+	// SyslogFramingAuto uses octet counting over TLS and newlines over other streams
+	SyslogFramingAuto SyslogFraming = iota
+	// SyslogFramingNewline terminates each message with LF (RFC 6587 section 3.4.2)
+	SyslogFramingNewline
+	// SyslogFramingOctetCounting prefixes each message with its length and a
+	// space (RFC 6587 section 3.4.1, required by RFC 5425 for TLS)
+	SyslogFramingOctetCounting
+)
+
+// ParseSyslogFraming parses a framing name: auto, newline or octet-counting (or octet)
+func ParseSyslogFraming(name string) (SyslogFraming, error) {
+	switch strings.ToLower(name) {
+	case "", "auto":
+		return SyslogFramingAuto, nil
+	case "newline", "lf", "non-transparent":
+		return SyslogFramingNewline, nil
+	case "octet-counting", "octet", "octet_counting":
+		return SyslogFramingOctetCounting, nil
+	default:
+		return SyslogFramingAuto, fmt.Errorf("unknown syslog framing %q", name)
+	}
+}
+
+// Syslog networks accepted by SyslogConfig in addition to those of net.Dial
+const (
+	// SyslogNetworkTLS is TCP with TLS (RFC 5425)
+	SyslogNetworkTLS = NetworkTLS
+	// SyslogNetworkLocal detects the local syslog socket type, trying
+	// unixgram before unix
+	SyslogNetworkLocal = ""
+)
+
+// Defaults for SyslogConfig
+const (
+	DefaultSyslogDialTimeout  = DefaultNetworkDialTimeout
+	DefaultSyslogWriteTimeout = DefaultNetworkWriteTimeout
+	DefaultSyslogBufferSize   = DefaultNetworkBufferSize
+	DefaultSyslogMinBackoff   = DefaultNetworkMinBackoff
+	DefaultSyslogMaxBackoff   = DefaultNetworkMaxBackoff
+)
+
+// localSyslogPaths are searched when no address is given
+var localSyslogPaths = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// SyslogConfig configures the transport of a syslog backend
+type SyslogConfig struct {
+	Network      string        // tcp, tcp+tls, udp, unix, unixgram, or "" to detect the local socket type
+	Address      string        // host:port or socket path; "" searches the usual local socket paths
+	Priority     int           // Facility and default severity
+	Tag          string        // TAG (RFC 3164) or APP-NAME (RFC 5424)
+	Framing      SyslogFraming // Message delimiting on stream transports
+	TLSConfig    *tls.Config   // Used by tcp+tls; nil verifies the server against the system roots
+	DialTimeout  time.Duration // 0 = DefaultSyslogDialTimeout
+	WriteTimeout time.Duration // 0 = DefaultSyslogWriteTimeout
+	BufferSize   int           // Messages kept while disconnected; 0 = DefaultSyslogBufferSize
+	MinBackoff   time.Duration // First reconnect delay; 0 = DefaultSyslogMinBackoff
+	MaxBackoff   time.Duration // Reconnect delay limit; 0 = DefaultSyslogMaxBackoff
+}
+
+// NewSyslogBackendWithConfig creates a syslog backend. The first connection
+// must succeed; later connection failures are retried with exponential
+// backoff while up to BufferSize messages are kept.
+func NewSyslogBackendWithConfig(config SyslogConfig) (*SyslogBackendImpl, error) {
+	// Default to local syslog if no address specified
+	if config.Address == "" {
+		for _, path := range localSyslogPaths {
+			if _, err := os.Stat(path); err == nil {
+				if config.Network != "unix" && config.Network != "unixgram" {
+					config.Network = SyslogNetworkLocal
+				}
+				config.Address = path
+				break
+			}
+		}
+		if config.Address == "" {
+			return nil, fmt.Errorf("no local syslog socket found")
+		}
+	}
+
+	framing := config.Framing
+	if framing == SyslogFramingAuto {
+		framing = SyslogFramingNewline
+		if config.Network == SyslogNetworkTLS {
+			framing = SyslogFramingOctetCounting
+		}
+	}
+
+	hostname, _ := os.Hostname()
+	sb := &SyslogBackendImpl{
+		sender: newNetSender(config.Network, config.Address, config.TLSConfig,
+			config.DialTimeout, config.WriteTimeout, config.BufferSize, config.MinBackoff, config.MaxBackoff),
+		framing:  framing,
+		priority: config.Priority,
+		tag:      config.Tag,
+		hostname: hostname,
+		procID:   strconv.Itoa(os.Getpid()),
+		sdID:     DefaultSyslogSDID,
+	}
+
+	// Connect to syslog
+	if err := sb.sender.dial(); err != nil {
+		return nil, fmt.Errorf("dial syslog: %w", err)
+	}
+
+	return sb, nil
+}
+
+// frame delimits a formatted message for the transport
+func (sb *SyslogBackendImpl) frame(message string) []byte {
+	message = strings.TrimSuffix(message, "\n")
+	switch {
+	case sb.sender.datagram:
+		// One message per datagram
+		return []byte(message)
+	case sb.framing == SyslogFramingOctetCounting:
+		return []byte(strconv.Itoa(len(message)) + " " + message)
+	default:
+		return []byte(message + "\n")
+	}
+}
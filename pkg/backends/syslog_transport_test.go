@@ -0,0 +1,381 @@
+package backends_test
+
+import (
+	"bufio"
+	"crypto/tls"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	testhelpers "github.com/wayneeseguin/omni/internal/testing"
+	"github.com/wayneeseguin/omni/pkg/backends"
+)
+
+// fakeSyslogServer receives syslog messages over a stream listener, split by
+// newline or octet-counting framing
+type fakeSyslogServer struct {
+	listener net.Listener
+	octets   bool
+	messages chan string
+	mu       sync.Mutex
+	conns    []net.Conn
+}
+
+func startFakeSyslogServer(t *testing.T, listener net.Listener, octets bool) *fakeSyslogServer {
+	t.Helper()
+	s := &fakeSyslogServer{listener: listener, octets: octets, messages: make(chan string, 100)}
+	go s.accept()
+	t.Cleanup(s.stop)
+	return s
+}
+
+func (s *fakeSyslogServer) accept() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+		go s.read(conn)
+	}
+}
+
+func (s *fakeSyslogServer) read(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	for {
+		if !s.octets {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			s.messages <- strings.TrimSuffix(line, "\n")
+			continue
+		}
+
+		length, err := r.ReadString(' ')
+		if err != nil {
+			return
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(length))
+		if err != nil {
+			s.messages <- "bad length: " + length
+			return
+		}
+		message := make([]byte, n)
+		if _, err := io.ReadFull(r, message); err != nil {
+			return
+		}
+		s.messages <- string(message)
+	}
+}
+
+// stop closes the listener and all accepted connections
+func (s *fakeSyslogServer) stop() {
+	s.listener.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+}
+
+// expectMessages waits for messages ending with the expected suffixes, in order
+func expectMessages(t *testing.T, messages <-chan string, suffixes ...string) {
+	t.Helper()
+	for _, suffix := range suffixes {
+		select {
+		case message := <-messages:
+			if !strings.HasSuffix(message, suffix) {
+				t.Fatalf("Expected message ending with %q, got %q", suffix, message)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("Timed out waiting for %q", suffix)
+		}
+	}
+}
+
+func writeAndFlush(t *testing.T, backend *backends.SyslogBackendImpl, messages ...string) {
+	t.Helper()
+	for _, message := range messages {
+		if _, err := backend.Write([]byte(message)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := backend.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+}
+
+func TestSyslogTransport_UDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer conn.Close()
+
+	backend, err := backends.NewSyslogBackendWithConfig(backends.SyslogConfig{
+		Network:  "udp",
+		Address:  conn.LocalAddr().String(),
+		Priority: 14,
+		Tag:      "udp-test",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	defer backend.Close()
+
+	writeAndFlush(t, backend, "first", "second")
+
+	// Each message is one datagram without a trailing newline
+	buf := make([]byte, 1024)
+	for _, expected := range []string{"<14>udp-test: first", "<14>udp-test: second"} {
+		_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("ReadFrom failed: %v", err)
+		}
+		if got := string(buf[:n]); got != expected {
+			t.Errorf("Expected datagram %q, got %q", expected, got)
+		}
+	}
+
+	if stats := backend.GetStats(); stats.WriteCount != 2 {
+		t.Errorf("Expected 2 messages written, got %d", stats.WriteCount)
+	}
+
+	// A message too large for a datagram is rejected rather than queued
+	// ahead of the messages written after it
+	if _, err := backend.Write(make([]byte, 70000)); err == nil {
+		t.Error("Expected error for a message larger than a datagram")
+	}
+	writeAndFlush(t, backend, "third")
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("ReadFrom failed: %v", err)
+	}
+	if got := string(buf[:n]); got != "<14>udp-test: third" {
+		t.Errorf("Expected the next message, got %q", got)
+	}
+	if stats := backend.GetStats(); stats.WriteCount != 3 || stats.Buffered != 0 || stats.Reconnects != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestSyslogTransport_UnixgramDetection(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unixgram sockets unavailable: %v", err)
+	}
+	defer conn.Close()
+
+	// An empty network detects the datagram socket, as most /dev/log sockets are
+	backend, err := backends.NewSyslogBackendWithConfig(backends.SyslogConfig{
+		Network:  backends.SyslogNetworkLocal,
+		Address:  path,
+		Priority: 14,
+		Tag:      "local",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	defer backend.Close()
+
+	writeAndFlush(t, backend, "hello")
+
+	buf := make([]byte, 1024)
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if got := string(buf[:n]); got != "<14>local: hello" {
+		t.Errorf("Unexpected datagram %q", got)
+	}
+
+	// A stream socket is still used when that is what the path is
+	streamPath := filepath.Join(t.TempDir(), "stream.sock")
+	listener, err := net.Listen("unix", streamPath)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := startFakeSyslogServer(t, listener, false)
+
+	stream, err := backends.NewSyslogBackendWithConfig(backends.SyslogConfig{Address: streamPath, Priority: 14, Tag: "local"})
+	if err != nil {
+		t.Fatalf("Failed to create stream backend: %v", err)
+	}
+	defer stream.Close()
+
+	writeAndFlush(t, stream, "over stream")
+	expectMessages(t, server.messages, "<14>local: over stream")
+}
+
+func TestSyslogTransport_OctetCounting(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := startFakeSyslogServer(t, listener, true)
+
+	backend, err := backends.NewSyslogBackendWithConfig(backends.SyslogConfig{
+		Network:  "tcp",
+		Address:  listener.Addr().String(),
+		Priority: 14,
+		Tag:      "octets",
+		Framing:  backends.SyslogFramingOctetCounting,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	defer backend.Close()
+
+	// Embedded newlines survive octet counting
+	writeAndFlush(t, backend, "line one\nline two", "next")
+	expectMessages(t, server.messages, "octets: line one\nline two", "octets: next")
+}
+
+func TestSyslogTransport_TLS(t *testing.T) {
+	cert := testhelpers.NewTestCertificate(t)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", cert.ServerConfig())
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := startFakeSyslogServer(t, listener, true)
+
+	clientConfig := cert.ClientConfig()
+	clientConfig.ServerName = "localhost"
+	backend, err := backends.NewSyslogBackendWithConfig(backends.SyslogConfig{
+		Network:   backends.SyslogNetworkTLS,
+		Address:   listener.Addr().String(),
+		Priority:  14,
+		Tag:       "tls",
+		TLSConfig: clientConfig,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	defer backend.Close()
+
+	// TLS defaults to octet counting (RFC 5425)
+	writeAndFlush(t, backend, "secure")
+	expectMessages(t, server.messages, "<14>tls: secure")
+
+	// An untrusted server is rejected
+	_, err = backends.NewSyslogBackendWithConfig(backends.SyslogConfig{
+		Network:   backends.SyslogNetworkTLS,
+		Address:   listener.Addr().String(),
+		TLSConfig: &tls.Config{MinVersion: tls.VersionTLS12},
+	})
+	if err == nil {
+		t.Error("Expected certificate verification error")
+	}
+}
+
+func TestSyslogTransport_ReconnectAndBuffer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	server := startFakeSyslogServer(t, listener, false)
+
+	backend, err := backends.NewSyslogBackendWithConfig(backends.SyslogConfig{
+		Network:    "tcp",
+		Address:    addr,
+		Priority:   14,
+		Tag:        "retry",
+		BufferSize: 3,
+		MinBackoff: 10 * time.Millisecond,
+		MaxBackoff: 20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	defer backend.Close()
+
+	writeAndFlush(t, backend, "before")
+	expectMessages(t, server.messages, "retry: before")
+
+	// Writes start failing once the peer is gone
+	server.stop()
+	deadline := time.Now().Add(3 * time.Second)
+	for backend.IsConnected() {
+		if time.Now().After(deadline) {
+			t.Fatal("Backend did not notice the lost connection")
+		}
+		_, _ = backend.Write([]byte("lost"))
+		_ = backend.Flush()
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Messages are kept while disconnected, dropping the oldest beyond BufferSize
+	for _, message := range []string{"queued 1", "queued 2", "queued 3"} {
+		if _, err := backend.Write([]byte(message)); err != nil {
+			t.Fatalf("Write while disconnected failed: %v", err)
+		}
+	}
+	if err := backend.Flush(); err == nil {
+		t.Error("Expected Flush to report the disconnection")
+	}
+	stats := backend.GetStats()
+	if stats.Buffered != 3 || stats.Dropped == 0 {
+		t.Errorf("Expected 3 buffered and some dropped messages, got %+v", stats)
+	}
+
+	// The queue is delivered in order once the server is back
+	listener, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("Cannot listen on %s again: %v", addr, err)
+	}
+	server = startFakeSyslogServer(t, listener, false)
+
+	deadline = time.Now().Add(3 * time.Second)
+	for backend.Flush() != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("Backend did not reconnect")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	expectMessages(t, server.messages, "retry: queued 1", "retry: queued 2", "retry: queued 3")
+
+	stats = backend.GetStats()
+	if stats.Reconnects != 1 || stats.Buffered != 0 || stats.ErrorCount == 0 {
+		t.Errorf("Unexpected stats after reconnect: %+v", stats)
+	}
+}
+
+func TestSyslogTransport_WriteAfterClose(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := startFakeSyslogServer(t, listener, false)
+
+	backend, err := backends.NewSyslogBackend("tcp", listener.Addr().String(), 14, "close")
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+
+	// Close delivers queued messages
+	if _, err := backend.Write([]byte("last words")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := backend.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	expectMessages(t, server.messages, "close: last words")
+
+	if _, err := backend.Write([]byte("too late")); err == nil {
+		t.Error("Expected error writing to a closed backend")
+	}
+}
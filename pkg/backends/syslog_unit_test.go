@@ -1,10 +1,13 @@
 package backends_test
 
 import (
+	"bufio"
 	"fmt"
 	"net"
+	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/wayneeseguin/omni/pkg/backends"
 )
@@ -438,3 +441,131 @@ func TestSyslogBackendImpl_AdvancedScenarios(t *testing.T) {
 		}
 	})
 }
+
+// TestSyslogBackendImpl_Framing tests the basic, RFC 3164 and RFC 5424 message framing
+func TestSyslogBackendImpl_Framing(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start mock server: %v", err)
+	}
+	defer listener.Close()
+
+	lines := make(chan string, 10)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	backend, err := backends.NewSyslogBackend("tcp", listener.Addr().String(), 19<<3|backends.SyslogSeverityInfo, "api")
+	if err != nil {
+		t.Fatalf("Failed to create syslog backend: %v", err)
+	}
+	defer backend.Close()
+	backend.SetHostname("web 1")
+
+	timestamp := time.Date(2024, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	pid := os.Getpid()
+
+	tests := []struct {
+		name     string
+		format   backends.SyslogFormat
+		msg      backends.SyslogMessage
+		expected string
+	}{
+		{
+			name:     "basic",
+			format:   backends.SyslogFormatBasic,
+			msg:      backends.SyslogMessage{Severity: backends.SyslogSeverityWarning, Timestamp: timestamp, Message: "disk low", Fields: map[string]interface{}{"free": 5}},
+			expected: "<156>api: disk low free=5",
+		},
+		{
+			name:     "rfc3164",
+			format:   backends.SyslogFormatRFC3164,
+			msg:      backends.SyslogMessage{Severity: backends.SyslogSeverityError, Timestamp: timestamp, Message: "failed", Fields: map[string]interface{}{"user": "bob"}},
+			expected: fmt.Sprintf("<155>Jan  2 03:04:05 web_1 api[%d]: failed user=bob", pid),
+		},
+		{
+			name:   "rfc5424 structured data",
+			format: backends.SyslogFormatRFC5424,
+			msg: backends.SyslogMessage{Severity: backends.SyslogSeverityDebug, Timestamp: timestamp, Message: "query", Fields: map[string]interface{}{
+				"sql":   `select "a\b" [x]`,
+				"rows":  3,
+				"a=b c": true,
+				"tags":  []interface{}{"x", "y"},
+			}},
+			expected: fmt.Sprintf(`<159>1 2024-01-02T03:04:05.123456Z web_1 api %d - [fields@32473 a_b_c="true" rows="3" sql="select \"a\\b\" [x\]" tags="[\"x\",\"y\"\]"] query`, pid),
+		},
+		{
+			name:     "rfc5424 without fields",
+			format:   backends.SyslogFormatRFC5424,
+			msg:      backends.SyslogMessage{Severity: backends.SyslogSeverityInfo, Timestamp: timestamp, Message: "started"},
+			expected: fmt.Sprintf("<158>1 2024-01-02T03:04:05.123456Z web_1 api %d - - started", pid),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend.SetFormat(tt.format)
+			if _, err := backend.WriteMessage(tt.msg); err != nil {
+				t.Fatalf("WriteMessage failed: %v", err)
+			}
+			if err := backend.Flush(); err != nil {
+				t.Fatalf("Flush failed: %v", err)
+			}
+
+			select {
+			case line := <-lines:
+				if line != tt.expected {
+					t.Errorf("Expected %q, got %q", tt.expected, line)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("Timed out waiting for message")
+			}
+		})
+	}
+
+	// Write keeps the configured priority and the MSGID and SD-ID are configurable
+	backend.SetMsgID("req")
+	backend.SetStructuredDataID("meta@12345")
+	if _, err := backend.Write([]byte("plain ")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	_ = backend.Flush()
+	select {
+	case line := <-lines:
+		if !strings.HasPrefix(line, "<158>1 ") || !strings.HasSuffix(line, fmt.Sprintf(" api %d req - plain", pid)) {
+			t.Errorf("Unexpected message %q", line)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for message")
+	}
+}
+
+func TestParseSyslogFacilityAndFormat(t *testing.T) {
+	for name, expected := range map[string]int{"kern": 0, "user": 1, "LOCAL3": 19, "local7": 23, "12": 12} {
+		if facility, err := backends.ParseSyslogFacility(name); err != nil || facility != expected {
+			t.Errorf("ParseSyslogFacility(%q) = %d, %v; want %d", name, facility, err, expected)
+		}
+	}
+	for _, name := range []string{"", "local8", "24", "-1"} {
+		if _, err := backends.ParseSyslogFacility(name); err == nil {
+			t.Errorf("Expected error for facility %q", name)
+		}
+	}
+
+	for name, expected := range map[string]backends.SyslogFormat{"rfc3164": backends.SyslogFormatRFC3164, "BSD": backends.SyslogFormatRFC3164, "rfc5424": backends.SyslogFormatRFC5424, "basic": backends.SyslogFormatBasic} {
+		if format, err := backends.ParseSyslogFormat(name); err != nil || format != expected {
+			t.Errorf("ParseSyslogFormat(%q) = %v, %v; want %v", name, format, err, expected)
+		}
+	}
+	if _, err := backends.ParseSyslogFormat("json"); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
@@ -1,12 +1,19 @@
 package features
 
 import (
+	"bufio"
+	"bytes"
 	"compress/gzip"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+
+	"github.com/klauspost/compress/zstd"
 )
 
 // CompressionType defines the compression algorithm used for rotated log files.
@@ -17,18 +24,42 @@ const (
 	CompressionNone CompressionType = iota
 	// CompressionGzip enables gzip compression
 	CompressionGzip
+	// CompressionZstd enables zstd compression
+	CompressionZstd
+)
+
+// File suffixes appended to rotated log files by each compression type
+const (
+	GzipSuffix = ".gz"
+	ZstdSuffix = ".zst"
+)
+
+// CompressionLevelDefault selects the default level of the compression algorithm
+const CompressionLevelDefault = 0
+
+// Valid level ranges. gzip uses the compress/flate levels and zstd uses the
+// zstd command line levels, which are mapped to the nearest encoder level.
+const (
+	GzipLevelMin = gzip.BestSpeed
+	GzipLevelMax = gzip.BestCompression
+	ZstdLevelMin = 1
+	ZstdLevelMax = 22
 )
 
 // CompressionManager handles log file compression
 type CompressionManager struct {
 	mu              sync.RWMutex
 	compressionType CompressionType
+	compressLevel   int
 	compressMinAge  int
 	compressWorkers int
-	compressCh      chan string
+	compressCh      chan compressJob
 	compressWg      sync.WaitGroup
 	errorHandler    func(source, dest, msg string, err error)
 	metricsHandler  func(string) // Function to track compression metrics
+
+	// Called with the original and compressed paths after a file is compressed
+	completionHandler func(original, compressed string)
 }
 
 // NewCompressionManager creates a new compression manager
@@ -54,16 +85,28 @@ func (c *CompressionManager) SetMetricsHandler(handler func(string)) {
 	c.metricsHandler = handler
 }
 
+// SetCompletionHandler sets the function called after a file has been compressed
+func (c *CompressionManager) SetCompletionHandler(handler func(original, compressed string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.completionHandler = handler
+}
+
 // SetCompression enables or disables compression for rotated log files
 func (c *CompressionManager) SetCompression(compressionType CompressionType) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	// Validate compression type
-	if compressionType != CompressionNone && compressionType != CompressionGzip {
+	if compressionType != CompressionNone && compressionType != CompressionGzip && compressionType != CompressionZstd {
 		return fmt.Errorf("invalid compression type: %d", compressionType)
 	}
 
+	// A level chosen for another algorithm may be out of range for this one
+	if ValidateCompressionLevel(compressionType, c.compressLevel) != nil {
+		c.compressLevel = CompressionLevelDefault
+	}
+
 	previousType := c.compressionType
 	c.compressionType = compressionType
 
@@ -77,6 +120,26 @@ func (c *CompressionManager) SetCompression(compressionType CompressionType) err
 	return nil
 }
 
+// SetLevel sets the compression level for the current compression type.
+// CompressionLevelDefault selects the algorithm's default level.
+func (c *CompressionManager) SetLevel(level int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := ValidateCompressionLevel(c.compressionType, level); err != nil {
+		return err
+	}
+	c.compressLevel = level
+	return nil
+}
+
+// GetLevel returns the compression level
+func (c *CompressionManager) GetLevel() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.compressLevel
+}
+
 // SetMinAge sets the minimum number of rotations before a log file is compressed
 func (c *CompressionManager) SetMinAge(age int) {
 	c.mu.Lock()
@@ -119,24 +182,48 @@ func (c *CompressionManager) GetMinAge() int {
 	return c.compressMinAge
 }
 
+// compressJob is a file queued for compression with the settings and
+// handlers in effect when it was queued. Workers never take c.mu, which
+// Stop, SetCompression and SetWorkers hold while waiting for them.
+type compressJob struct {
+	path              string
+	compressionType   CompressionType
+	level             int
+	errorHandler      func(source, dest, msg string, err error)
+	metricsHandler    func(string)
+	completionHandler func(original, compressed string)
+}
+
+// newJob captures the settings path is compressed with. Caller must hold c.mu.
+func (c *CompressionManager) newJob(path string) compressJob {
+	return compressJob{
+		path:              path,
+		compressionType:   c.compressionType,
+		level:             c.compressLevel,
+		errorHandler:      c.errorHandler,
+		metricsHandler:    c.metricsHandler,
+		completionHandler: c.completionHandler,
+	}
+}
+
 // startWorkers starts background goroutines for compression
 func (c *CompressionManager) startWorkers() {
 	// Create channel for compression jobs
-	c.compressCh = make(chan string, 100)
+	c.compressCh = make(chan compressJob, 100)
 
 	// Start worker goroutines
 	for i := 0; i < c.compressWorkers; i++ {
 		c.compressWg.Add(1)
-		go func() {
+		go func(jobs <-chan compressJob) {
 			defer c.compressWg.Done()
-			for path := range c.compressCh {
-				if err := c.compressFile(path); err != nil {
-					if c.errorHandler != nil {
-						c.errorHandler("compress", "", fmt.Sprintf("Failed to compress file %s", path), err)
+			for job := range jobs {
+				if err := job.run(); err != nil {
+					if job.errorHandler != nil {
+						job.errorHandler("compress", "", fmt.Sprintf("Failed to compress file %s", job.path), err)
 					}
 				}
 			}
-		}()
+		}(c.compressCh)
 	}
 }
 
@@ -158,7 +245,7 @@ func (c *CompressionManager) Start() {
 	}
 }
 
-// Stop stops the compression manager
+// Stop stops the compression manager once the queued files are compressed
 func (c *CompressionManager) Stop() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -172,7 +259,7 @@ func (c *CompressionManager) QueueFile(path string) {
 
 	if c.compressionType != CompressionNone && c.compressCh != nil {
 		select {
-		case c.compressCh <- path:
+		case c.compressCh <- c.newJob(path):
 			// Successfully queued
 		default:
 			// Queue full, log error
@@ -186,25 +273,58 @@ func (c *CompressionManager) QueueFile(path string) {
 // compressFile compresses the given file using the configured compression type
 func (c *CompressionManager) compressFile(path string) error {
 	c.mu.RLock()
-	compressionType := c.compressionType
+	job := c.newJob(path)
 	c.mu.RUnlock()
 
-	if compressionType == CompressionNone {
+	return job.run()
+}
+
+// compressFileGzip compresses a file using gzip compression
+func (c *CompressionManager) compressFileGzip(path string) error {
+	c.mu.RLock()
+	job := c.newJob(path)
+	c.mu.RUnlock()
+
+	job.compressionType = CompressionGzip
+	return job.compress(GzipSuffix)
+}
+
+// compressFileZstd compresses a file using zstd compression
+func (c *CompressionManager) compressFileZstd(path string) error {
+	c.mu.RLock()
+	job := c.newJob(path)
+	c.mu.RUnlock()
+
+	job.compressionType = CompressionZstd
+	return job.compress(ZstdSuffix)
+}
+
+// run compresses the job's file with its compression type
+func (j compressJob) run() error {
+	if j.compressionType == CompressionNone {
+		return nil
+	}
+
+	// Files compressed by an earlier run or another type are left alone
+	if IsCompressedFile(j.path) {
 		return nil
 	}
 
-	switch compressionType {
+	switch j.compressionType {
 	case CompressionGzip:
-		return c.compressFileGzip(path)
+		return j.compress(GzipSuffix)
+	case CompressionZstd:
+		return j.compress(ZstdSuffix)
 	default:
-		return fmt.Errorf("unsupported compression type: %v", compressionType)
+		return fmt.Errorf("unsupported compression type: %v", j.compressionType)
 	}
 }
 
-// compressFileGzip compresses a file using gzip compression
-func (c *CompressionManager) compressFileGzip(path string) error {
+// compress compresses the job's file into path+suffix and removes the
+// original once the compressed copy is complete.
+func (j compressJob) compress(suffix string) (err error) {
 	// Clean the path to prevent directory traversal
-	cleanPath := filepath.Clean(path)
+	cleanPath := filepath.Clean(j.path)
 
 	// Check if file exists
 	if _, err := os.Stat(cleanPath); os.IsNotExist(err) {
@@ -212,7 +332,7 @@ func (c *CompressionManager) compressFileGzip(path string) error {
 	}
 
 	// Compressed file path
-	compressedPath := filepath.Clean(cleanPath + ".gz")
+	compressedPath := filepath.Clean(cleanPath + suffix)
 
 	// Open source file
 	src, err := os.Open(cleanPath)
@@ -245,18 +365,22 @@ func (c *CompressionManager) compressFileGzip(path string) error {
 		}
 	}()
 
-	// Create gzip writer
-	gw := gzip.NewWriter(dst)
+	// Create compressing writer
+	cw, err := NewCompressingWriter(dst, j.compressionType, j.level)
+	if err != nil {
+		return fmt.Errorf("creating compressor: %w", err)
+	}
 
 	// Copy data from source to compressed destination
-	_, err = io.Copy(gw, src)
+	_, err = io.Copy(cw, src)
 	if err != nil {
+		_ = cw.Close()
 		return fmt.Errorf("compressing file: %w", err)
 	}
 
-	// Close gzip writer and check error
-	if err = gw.Close(); err != nil {
-		return fmt.Errorf("closing gzip writer: %w", err)
+	// Close compressing writer and check error
+	if err = cw.Close(); err != nil {
+		return fmt.Errorf("closing compressor: %w", err)
 	}
 
 	// Close destination file and check error
@@ -273,8 +397,12 @@ func (c *CompressionManager) compressFileGzip(path string) error {
 	}
 
 	// Track compression metric
-	if c.metricsHandler != nil {
-		c.metricsHandler("compression_completed")
+	if j.metricsHandler != nil {
+		j.metricsHandler("compression_completed")
+	}
+
+	if j.completionHandler != nil {
+		j.completionHandler(cleanPath, compressedPath)
 	}
 
 	return nil
@@ -287,6 +415,7 @@ func (c *CompressionManager) GetStatus() CompressionStatus {
 
 	status := CompressionStatus{
 		Type:      c.compressionType,
+		Level:     c.compressLevel,
 		MinAge:    c.compressMinAge,
 		Workers:   c.compressWorkers,
 		IsRunning: c.compressCh != nil,
@@ -303,6 +432,7 @@ func (c *CompressionManager) GetStatus() CompressionStatus {
 // CompressionStatus represents the status of the compression manager
 type CompressionStatus struct {
 	Type          CompressionType `json:"type"`
+	Level         int             `json:"level"`
 	MinAge        int             `json:"min_age"`
 	Workers       int             `json:"workers"`
 	IsRunning     bool            `json:"is_running"`
@@ -320,6 +450,7 @@ func GetSupportedCompressionTypes() []CompressionType {
 	return []CompressionType{
 		CompressionNone,
 		CompressionGzip,
+		CompressionZstd,
 	}
 }
 
@@ -330,6 +461,8 @@ func CompressionTypeString(ct CompressionType) string {
 		return "none"
 	case CompressionGzip:
 		return "gzip"
+	case CompressionZstd:
+		return "zstd"
 	default:
 		return "unknown"
 	}
@@ -342,7 +475,399 @@ func ParseCompressionType(s string) (CompressionType, error) {
 		return CompressionNone, nil
 	case "gzip":
 		return CompressionGzip, nil
+	case "zstd":
+		return CompressionZstd, nil
 	default:
 		return CompressionNone, fmt.Errorf("unsupported compression type: %s", s)
 	}
 }
+
+// ValidateCompressionLevel checks that level is valid for the compression type
+func ValidateCompressionLevel(ct CompressionType, level int) error {
+	if level == CompressionLevelDefault {
+		return nil
+	}
+
+	switch ct {
+	case CompressionGzip:
+		if level < GzipLevelMin || level > GzipLevelMax {
+			return fmt.Errorf("invalid gzip compression level %d: must be between %d and %d", level, GzipLevelMin, GzipLevelMax)
+		}
+	case CompressionZstd:
+		if level < ZstdLevelMin || level > ZstdLevelMax {
+			return fmt.Errorf("invalid zstd compression level %d: must be between %d and %d", level, ZstdLevelMin, ZstdLevelMax)
+		}
+	case CompressionNone:
+		// Level is ignored without compression
+	default:
+		return fmt.Errorf("invalid compression type: %d", ct)
+	}
+	return nil
+}
+
+// CompressionSuffix returns the file suffix for a compression type
+func CompressionSuffix(ct CompressionType) string {
+	switch ct {
+	case CompressionGzip:
+		return GzipSuffix
+	case CompressionZstd:
+		return ZstdSuffix
+	default:
+		return ""
+	}
+}
+
+// CompressionTypeForPath returns the compression type implied by a file's suffix
+func CompressionTypeForPath(path string) CompressionType {
+	switch {
+	case strings.HasSuffix(path, GzipSuffix):
+		return CompressionGzip
+	case strings.HasSuffix(path, ZstdSuffix):
+		return CompressionZstd
+	default:
+		return CompressionNone
+	}
+}
+
+// IsCompressedFile reports whether path has a recognised compression suffix
+func IsCompressedFile(path string) bool {
+	return CompressionTypeForPath(path) != CompressionNone
+}
+
+// TrimCompressionSuffix removes a recognised compression suffix from path
+func TrimCompressionSuffix(path string) string {
+	return strings.TrimSuffix(path, CompressionSuffix(CompressionTypeForPath(path)))
+}
+
+// OpenLogFile opens a log file for reading, transparently decompressing
+// rotated files based on their suffix. Files without a suffix are inspected
+// for a compressed stream, as written by stream-compressed destinations.
+func OpenLogFile(path string) (io.ReadCloser, error) {
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+
+	var src io.ReadCloser = file
+	compressionType := CompressionTypeForPath(path)
+	if compressionType == CompressionNone {
+		buffered := bufio.NewReader(file)
+		compressionType = DetectCompression(buffered)
+		src = &decompressingReader{Reader: buffered, closers: []io.Closer{file}}
+	}
+
+	reader, err := NewDecompressingReader(src, compressionType)
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("opening %s: %w", path, err)
+	}
+	return reader, nil
+}
+
+// DetectCompression inspects the magic bytes at the start of r without consuming them
+func DetectCompression(r *bufio.Reader) CompressionType {
+	magic, _ := r.Peek(len(zstdMagic))
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return CompressionGzip
+	case bytes.HasPrefix(magic, zstdMagic):
+		return CompressionZstd
+	default:
+		return CompressionNone
+	}
+}
+
+// Magic bytes identifying compressed streams
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// NewDecompressingReader wraps r with a decompressor for the compression type.
+// Closing the returned reader also closes r. A torn final frame, as left by a
+// crash while a stream-compressed file was appended, ends the data after its
+// last complete line instead of failing the read, so the frames before it can
+// still be read.
+func NewDecompressingReader(r io.ReadCloser, ct CompressionType) (io.ReadCloser, error) {
+	switch ct {
+	case CompressionNone:
+		return r, nil
+	case CompressionGzip:
+		buffered := bufio.NewReader(r)
+		magic, _ := buffered.Peek(len(gzipMagic))
+		gr, err := gzip.NewReader(buffered)
+		if errors.Is(err, io.ErrUnexpectedEOF) && bytes.Equal(magic, gzipMagic) {
+			// The only frame is torn
+			return &decompressingReader{Reader: bytes.NewReader(nil), closers: []io.Closer{r}}, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("creating gzip reader: %w", err)
+		}
+		return &decompressingReader{Reader: newTornFrameReader(gr), closers: []io.Closer{gr, r}}, nil
+	case CompressionZstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("creating zstd reader: %w", err)
+		}
+		return &decompressingReader{Reader: newTornFrameReader(zr), closers: []io.Closer{zstdReadCloser{zr}, r}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported compression type: %v", ct)
+	}
+}
+
+// tornFrameReader reports the end of a torn final frame as the end of the
+// data. Decompressed bytes after the last line break are held back until
+// more data arrives, so the incomplete line of a torn frame is dropped
+// rather than read as a record.
+type tornFrameReader struct {
+	r       io.Reader
+	buf     []byte
+	ready   []byte // Complete lines not yet read
+	pending []byte // Bytes after the last line break
+	err     error
+}
+
+// newTornFrameReader wraps the decompressor r
+func newTornFrameReader(r io.Reader) *tornFrameReader {
+	return &tornFrameReader{r: r, buf: make([]byte, 32*1024)}
+}
+
+// Read reads decompressed data, turning io.ErrUnexpectedEOF into io.EOF
+func (t *tornFrameReader) Read(p []byte) (int, error) {
+	for len(t.ready) == 0 {
+		if t.err != nil {
+			return 0, t.err
+		}
+		n, err := t.r.Read(t.buf)
+		t.pending = append(t.pending, t.buf[:n]...)
+		switch {
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			t.pending = nil
+			t.err = io.EOF
+		case err != nil:
+			t.ready, t.pending = t.pending, nil
+			t.err = err
+		default:
+			if i := bytes.LastIndexByte(t.pending, '\n'); i >= 0 {
+				t.ready = t.pending[:i+1]
+				t.pending = append([]byte(nil), t.pending[i+1:]...)
+			}
+		}
+	}
+	n := copy(p, t.ready)
+	t.ready = t.ready[n:]
+	return n, nil
+}
+
+// CompleteFramesLength returns the length of the complete gzip members or
+// zstd frames at the start of r. Anything after them, such as a frame torn
+// by a crash while it was appended, cannot be decompressed.
+func CompleteFramesLength(r io.Reader, ct CompressionType) (int64, error) {
+	src := &countingReader{r: bufio.NewReader(r)}
+	var frame func(*countingReader) error
+	switch ct {
+	case CompressionGzip:
+		frame = readGzipMember
+	case CompressionZstd:
+		frame = readZstdFrame
+	default:
+		return 0, fmt.Errorf("unsupported compression type: %v", ct)
+	}
+
+	for {
+		start := src.n
+		err := frame(src)
+		if src.err != nil {
+			return 0, src.err
+		}
+		if err != nil {
+			return start, nil
+		}
+		if _, err := src.r.Peek(1); err == io.EOF {
+			return src.n, nil
+		}
+	}
+}
+
+// readGzipMember decompresses one gzip member, failing if it is incomplete
+func readGzipMember(src *countingReader) error {
+	// src is an io.ByteReader, so the decompressor does not read past the member
+	gr, err := gzip.NewReader(src)
+	if err != nil {
+		return err
+	}
+	gr.Multistream(false)
+	_, err = io.Copy(io.Discard, gr)
+	return err
+}
+
+// Zstandard frame layout, from RFC 8878
+const (
+	zstdFrameMagic         = 0xFD2FB528
+	zstdSkippableMagicMask = 0xFFFFFFF0
+	zstdSkippableMagic     = 0x184D2A50
+	zstdBlockRLE           = 1
+	zstdBlockReserved      = 3
+)
+
+// readZstdFrame skips one zstd frame by its block headers, failing if it is
+// incomplete or malformed
+func readZstdFrame(src *countingReader) error {
+	var header [4]byte
+	if _, err := io.ReadFull(src, header[:]); err != nil {
+		return err
+	}
+	magic := binary.LittleEndian.Uint32(header[:])
+	if magic&zstdSkippableMagicMask == zstdSkippableMagic {
+		if _, err := io.ReadFull(src, header[:]); err != nil {
+			return err
+		}
+		return skipBytes(src, int64(binary.LittleEndian.Uint32(header[:])))
+	}
+	if magic != zstdFrameMagic {
+		return fmt.Errorf("not a zstd frame")
+	}
+
+	descriptor, err := src.ReadByte()
+	if err != nil {
+		return err
+	}
+	singleSegment := descriptor&0x20 != 0
+	headerSize := int64([]int{0, 1, 2, 4}[descriptor&0x03]) // Dictionary ID
+	// Frame content size
+	switch descriptor >> 6 {
+	case 0:
+		if singleSegment {
+			headerSize++
+		}
+	case 1:
+		headerSize += 2
+	case 2:
+		headerSize += 4
+	case 3:
+		headerSize += 8
+	}
+	if !singleSegment {
+		headerSize++ // Window descriptor
+	}
+	if err := skipBytes(src, headerSize); err != nil {
+		return err
+	}
+
+	for last := false; !last; {
+		var block [3]byte
+		if _, err := io.ReadFull(src, block[:]); err != nil {
+			return err
+		}
+		blockHeader := uint32(block[0]) | uint32(block[1])<<8 | uint32(block[2])<<16
+		last = blockHeader&1 != 0
+		size := int64(blockHeader >> 3)
+		switch (blockHeader >> 1) & 0x03 {
+		case zstdBlockRLE:
+			size = 1
+		case zstdBlockReserved:
+			return fmt.Errorf("reserved zstd block type")
+		}
+		if err := skipBytes(src, size); err != nil {
+			return err
+		}
+	}
+
+	if descriptor&0x04 != 0 {
+		return skipBytes(src, 4) // Content checksum
+	}
+	return nil
+}
+
+// skipBytes discards n bytes of src, failing if it ends first
+func skipBytes(src *countingReader, n int64) error {
+	skipped, err := io.CopyN(io.Discard, src, n)
+	if skipped < n && err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// countingReader counts the bytes read from a buffered reader and records
+// read failures other than the end of the data
+type countingReader struct {
+	r   *bufio.Reader
+	n   int64
+	err error
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	c.record(err)
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+	}
+	c.record(err)
+	return b, err
+}
+
+// record keeps the first read failure other than io.EOF
+func (c *countingReader) record(err error) {
+	if err != nil && err != io.EOF && c.err == nil {
+		c.err = err
+	}
+}
+
+// decompressingReader closes the decompressor and underlying file together
+type decompressingReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+// Close closes the decompressor and the underlying reader
+func (d *decompressingReader) Close() error {
+	var firstErr error
+	for _, closer := range d.closers {
+		if err := closer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// zstdReadCloser adapts zstd.Decoder, whose Close has no return value
+type zstdReadCloser struct {
+	decoder *zstd.Decoder
+}
+
+// Close releases the decoder's resources
+func (z zstdReadCloser) Close() error {
+	z.decoder.Close()
+	return nil
+}
+
+// NewCompressingWriter returns a writer that compresses to w with the given
+// type and level. Each writer produces one complete frame (gzip member or zstd
+// frame) when closed; closing does not close w.
+func NewCompressingWriter(w io.Writer, ct CompressionType, level int) (io.WriteCloser, error) {
+	if err := ValidateCompressionLevel(ct, level); err != nil {
+		return nil, err
+	}
+
+	switch ct {
+	case CompressionGzip:
+		if level == CompressionLevelDefault {
+			level = gzip.DefaultCompression
+		}
+		return gzip.NewWriterLevel(w, level)
+	case CompressionZstd:
+		encoderLevel := zstd.SpeedDefault
+		if level != CompressionLevelDefault {
+			encoderLevel = zstd.EncoderLevelFromZstd(level)
+		}
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(encoderLevel), zstd.WithEncoderConcurrency(1))
+	default:
+		return nil, fmt.Errorf("unsupported compression type: %v", ct)
+	}
+}
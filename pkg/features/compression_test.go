@@ -40,6 +40,7 @@ func TestSetCompression(t *testing.T) {
 	}{
 		{"Set to None", CompressionNone, false},
 		{"Set to Gzip", CompressionGzip, false},
+		{"Set to Zstd", CompressionZstd, false},
 		{"Set to invalid", CompressionType(99), true},
 	}
 
@@ -330,13 +331,14 @@ func TestConcurrentCompression(t *testing.T) {
 func TestGetSupportedCompressionTypes(t *testing.T) {
 	types := GetSupportedCompressionTypes()
 
-	if len(types) != 2 {
-		t.Errorf("Expected 2 supported compression types, got %d", len(types))
+	if len(types) != 3 {
+		t.Errorf("Expected 3 supported compression types, got %d", len(types))
 	}
 
 	expectedTypes := map[CompressionType]bool{
 		CompressionNone: true,
 		CompressionGzip: true,
+		CompressionZstd: true,
 	}
 
 	for _, ct := range types {
@@ -353,6 +355,7 @@ func TestCompressionTypeString(t *testing.T) {
 	}{
 		{CompressionNone, "none"},
 		{CompressionGzip, "gzip"},
+		{CompressionZstd, "zstd"},
 		{CompressionType(99), "unknown"},
 	}
 
@@ -374,6 +377,7 @@ func TestParseCompressionType(t *testing.T) {
 	}{
 		{"none", CompressionNone, false},
 		{"gzip", CompressionGzip, false},
+		{"zstd", CompressionZstd, false},
 		{"invalid", CompressionNone, true},
 		{"", CompressionNone, true},
 	}
@@ -426,3 +430,265 @@ func TestStartStopWorkers(t *testing.T) {
 		t.Error("Expected compression channel to be nil after stop")
 	}
 }
+
+func TestStopWhileCompressing(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test.log")
+	if err := os.WriteFile(testFile, []byte("test content"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	cm := NewCompressionManager()
+	running := make(chan struct{})
+	release := make(chan struct{})
+	cm.SetMetricsHandler(func(string) {
+		close(running)
+		<-release
+	})
+	completed := make(chan string, 1)
+	cm.SetCompletionHandler(func(original, compressed string) {
+		completed <- compressed
+	})
+	if err := cm.SetCompression(CompressionGzip); err != nil {
+		t.Fatalf("Failed to set compression: %v", err)
+	}
+	cm.QueueFile(testFile)
+	<-running
+
+	// Stop waits for the running job, which must finish without c.mu
+	stopped := make(chan struct{})
+	go func() {
+		cm.Stop()
+		close(stopped)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop deadlocked with a compression job running")
+	}
+	select {
+	case compressed := <-completed:
+		if compressed != testFile+GzipSuffix {
+			t.Errorf("Unexpected compressed path %s", compressed)
+		}
+	default:
+		t.Error("Expected the running job to complete before Stop returned")
+	}
+}
+
+func TestCompressFileZstd(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.log")
+	testContent := strings.Repeat("zstd compressed log line\n", 100)
+
+	if err := os.WriteFile(testFile, []byte(testContent), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	cm := NewCompressionManager()
+	if err := cm.SetCompression(CompressionZstd); err != nil {
+		t.Fatalf("Failed to set compression: %v", err)
+	}
+	defer cm.Stop()
+
+	if err := cm.SetLevel(19); err != nil {
+		t.Fatalf("Failed to set level: %v", err)
+	}
+
+	if err := cm.CompressFileSync(testFile); err != nil {
+		t.Fatalf("Failed to compress file: %v", err)
+	}
+
+	compressedFile := testFile + ZstdSuffix
+	if _, err := os.Stat(testFile); !os.IsNotExist(err) {
+		t.Fatal("Original file still exists after compression")
+	}
+
+	reader, err := OpenLogFile(compressedFile)
+	if err != nil {
+		t.Fatalf("Failed to open compressed file: %v", err)
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Failed to read compressed file: %v", err)
+	}
+	if string(content) != testContent {
+		t.Errorf("Content mismatch after zstd round trip")
+	}
+}
+
+func TestCompressFileSkipsCompressed(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.log.20240101-000000.000.gz")
+
+	if err := os.WriteFile(testFile, []byte("already compressed"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	cm := NewCompressionManager()
+	if err := cm.SetCompression(CompressionZstd); err != nil {
+		t.Fatalf("Failed to set compression: %v", err)
+	}
+	defer cm.Stop()
+
+	if err := cm.CompressFileSync(testFile); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if _, err := os.Stat(testFile); err != nil {
+		t.Errorf("Compressed file should be left alone: %v", err)
+	}
+	if _, err := os.Stat(testFile + ZstdSuffix); !os.IsNotExist(err) {
+		t.Error("Compressed file should not be compressed again")
+	}
+}
+
+func TestCompressionLevels(t *testing.T) {
+	tests := []struct {
+		name        string
+		ct          CompressionType
+		level       int
+		expectError bool
+	}{
+		{"gzip default", CompressionGzip, CompressionLevelDefault, false},
+		{"gzip fastest", CompressionGzip, GzipLevelMin, false},
+		{"gzip best", CompressionGzip, GzipLevelMax, false},
+		{"gzip too high", CompressionGzip, 10, true},
+		{"gzip negative", CompressionGzip, -1, true},
+		{"zstd default", CompressionZstd, CompressionLevelDefault, false},
+		{"zstd fastest", CompressionZstd, ZstdLevelMin, false},
+		{"zstd best", CompressionZstd, ZstdLevelMax, false},
+		{"zstd too high", CompressionZstd, 23, true},
+		{"none ignores level", CompressionNone, 5, false},
+		{"invalid type", CompressionType(99), 5, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCompressionLevel(tt.ct, tt.level)
+			if tt.expectError && err == nil {
+				t.Error("Expected error but got nil")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+
+	// Every valid gzip level must produce a readable file
+	for level := GzipLevelMin; level <= GzipLevelMax; level += 4 {
+		tempDir := t.TempDir()
+		testFile := filepath.Join(tempDir, "test.log")
+		if err := os.WriteFile(testFile, []byte("gzip level test\n"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+
+		cm := NewCompressionManager()
+		if err := cm.SetCompression(CompressionGzip); err != nil {
+			t.Fatalf("Failed to set compression: %v", err)
+		}
+		if err := cm.SetLevel(level); err != nil {
+			t.Fatalf("Failed to set level %d: %v", level, err)
+		}
+		if cm.GetStatus().Level != level {
+			t.Errorf("Expected status level %d, got %d", level, cm.GetStatus().Level)
+		}
+		if err := cm.CompressFileSync(testFile); err != nil {
+			t.Fatalf("Failed to compress at level %d: %v", level, err)
+		}
+		cm.Stop()
+
+		reader, err := OpenLogFile(testFile + GzipSuffix)
+		if err != nil {
+			t.Fatalf("Failed to open gzip file: %v", err)
+		}
+		content, err := io.ReadAll(reader)
+		_ = reader.Close()
+		if err != nil || string(content) != "gzip level test\n" {
+			t.Errorf("Level %d round trip failed: %q, %v", level, content, err)
+		}
+	}
+
+	// Switching to a type where the level is out of range resets it
+	cm := NewCompressionManager()
+	if err := cm.SetCompression(CompressionZstd); err != nil {
+		t.Fatalf("Failed to set compression: %v", err)
+	}
+	defer cm.Stop()
+	if err := cm.SetLevel(19); err != nil {
+		t.Fatalf("Failed to set level: %v", err)
+	}
+	if err := cm.SetCompression(CompressionGzip); err != nil {
+		t.Fatalf("Failed to set compression: %v", err)
+	}
+	if cm.GetLevel() != CompressionLevelDefault {
+		t.Errorf("Expected level reset to default, got %d", cm.GetLevel())
+	}
+}
+
+func TestCompressionSuffixes(t *testing.T) {
+	tests := []struct {
+		path         string
+		ct           CompressionType
+		trimmed      string
+		isCompressed bool
+	}{
+		{"app.log.20240101-000000.000", CompressionNone, "app.log.20240101-000000.000", false},
+		{"app.log.20240101-000000.000.gz", CompressionGzip, "app.log.20240101-000000.000", true},
+		{"app.log.20240101-000000.000.zst", CompressionZstd, "app.log.20240101-000000.000", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if ct := CompressionTypeForPath(tt.path); ct != tt.ct {
+				t.Errorf("CompressionTypeForPath() = %v, want %v", ct, tt.ct)
+			}
+			if IsCompressedFile(tt.path) != tt.isCompressed {
+				t.Errorf("IsCompressedFile() = %v, want %v", !tt.isCompressed, tt.isCompressed)
+			}
+			if trimmed := TrimCompressionSuffix(tt.path); trimmed != tt.trimmed {
+				t.Errorf("TrimCompressionSuffix() = %q, want %q", trimmed, tt.trimmed)
+			}
+			if tt.ct != CompressionNone && !strings.HasSuffix(tt.path, CompressionSuffix(tt.ct)) {
+				t.Errorf("CompressionSuffix(%v) = %q", tt.ct, CompressionSuffix(tt.ct))
+			}
+		})
+	}
+}
+
+func TestOpenLogFile(t *testing.T) {
+	tempDir := t.TempDir()
+	content := "plain log line\n"
+
+	plain := filepath.Join(tempDir, "app.log")
+	if err := os.WriteFile(plain, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create plain file: %v", err)
+	}
+
+	reader, err := OpenLogFile(plain)
+	if err != nil {
+		t.Fatalf("Failed to open plain file: %v", err)
+	}
+	data, _ := io.ReadAll(reader)
+	_ = reader.Close()
+	if string(data) != content {
+		t.Errorf("Expected %q, got %q", content, data)
+	}
+
+	// A file with a compression suffix but invalid content must fail to open
+	corrupt := filepath.Join(tempDir, "app.log.gz")
+	if err := os.WriteFile(corrupt, []byte("not gzip"), 0644); err != nil {
+		t.Fatalf("Failed to create corrupt file: %v", err)
+	}
+	if _, err := OpenLogFile(corrupt); err == nil {
+		t.Error("Expected error opening corrupt gzip file")
+	}
+
+	if _, err := OpenLogFile(filepath.Join(tempDir, "missing.log")); err == nil {
+		t.Error("Expected error opening missing file")
+	}
+}
@@ -0,0 +1,554 @@
+package features
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/formatters"
+)
+
+// ManifestSuffix is appended to a log path to name its rotation manifest.
+// Example: "app.log" keeps its manifest in "app.log.manifest.json"
+const ManifestSuffix = ".manifest.json"
+
+// ManifestVersion is the version of the manifest format written by this package
+const ManifestVersion = 1
+
+// ErrManifestChecksum is returned when a rotated file does not match its manifest entry
+var ErrManifestChecksum = errors.New("rotated file checksum mismatch")
+
+// Manifest describes the rotated files of a log, oldest first
+type Manifest struct {
+	Version int             `json:"version"`
+	LogFile string          `json:"log_file"`
+	Updated time.Time       `json:"updated"`
+	Files   []ManifestEntry `json:"files"`
+}
+
+// ManifestEntry describes the contents of one rotated file
+type ManifestEntry struct {
+	Name           string           `json:"name"`
+	RotationTime   time.Time        `json:"rotation_time"`
+	FirstTimestamp time.Time        `json:"first_timestamp,omitzero"`
+	LastTimestamp  time.Time        `json:"last_timestamp,omitzero"`
+	EntryCount     int64            `json:"entry_count"`
+	LevelCounts    map[string]int64 `json:"level_counts,omitempty"`
+	Size           int64            `json:"size"`
+	Compression    string           `json:"compression"`
+	SHA256         string           `json:"sha256"`
+
+	// ContentUnknown is set when no entries of a non-empty file were
+	// recognised, such as in logfmt, CSV or binary files. Its counts and time
+	// range are then unknown rather than zero.
+	ContentUnknown bool `json:"content_unknown,omitempty"`
+}
+
+// Overlaps reports whether the entry may hold log entries with since <= timestamp < until.
+// Zero bounds are open, and entries without timestamps always overlap.
+func (e ManifestEntry) Overlaps(since, until time.Time) bool {
+	if e.FirstTimestamp.IsZero() || e.LastTimestamp.IsZero() {
+		return true
+	}
+	if !since.IsZero() && e.LastTimestamp.Before(since) {
+		return false
+	}
+	if !until.IsZero() && !e.FirstTimestamp.Before(until) {
+		return false
+	}
+	return true
+}
+
+// Entry returns the entry for a rotated file name
+func (m *Manifest) Entry(name string) (ManifestEntry, bool) {
+	for _, entry := range m.Files {
+		if entry.Name == name {
+			return entry, true
+		}
+	}
+	return ManifestEntry{}, false
+}
+
+// EntriesInRange returns the entries that may hold log entries in the time range
+func (m *Manifest) EntriesInRange(since, until time.Time) []ManifestEntry {
+	var entries []ManifestEntry
+	for _, entry := range m.Files {
+		if entry.Overlaps(since, until) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// ManifestPath returns the manifest path for a log path
+func ManifestPath(logPath string) string {
+	return filepath.Clean(logPath) + ManifestSuffix
+}
+
+// ReadManifest reads the manifest for a log path. A log without a manifest
+// returns an empty manifest.
+func ReadManifest(logPath string) (*Manifest, error) {
+	data, err := os.ReadFile(ManifestPath(logPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Manifest{Version: ManifestVersion, LogFile: filepath.Base(logPath)}, nil
+		}
+		return nil, fmt.Errorf("reading manifest: %w", err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("parsing manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// VerifyManifest checks the checksum of every rotated file listed in the
+// manifest for a log path. Files removed since the manifest was written are
+// skipped. Mismatches wrap ErrManifestChecksum.
+func VerifyManifest(logPath string) error {
+	manifest, err := ReadManifest(logPath)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(logPath)
+	var errs []error
+	for _, entry := range manifest.Files {
+		sum, err := fileChecksum(filepath.Join(dir, entry.Name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			errs = append(errs, err)
+			continue
+		}
+		if sum != entry.SHA256 {
+			errs = append(errs, fmt.Errorf("%s: %w", entry.Name, ErrManifestChecksum))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ScanLogFile reads a log file, decompressing it if needed, and returns its
+// manifest entry. Entries are recognised from JSON lines and from text lines
+// starting with "[timestamp] [LEVEL]"; other lines are treated as
+// continuations of the previous entry. Files in which no entry is recognised
+// are marked ContentUnknown.
+func ScanLogFile(path string) (ManifestEntry, error) {
+	cleanPath := filepath.Clean(path)
+	file, err := os.Open(cleanPath)
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+	defer func() { _ = file.Close() }() // Best effort close, the file was only read
+
+	info, err := file.Stat()
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+
+	// Hash the raw bytes while they are decompressed and scanned
+	hash := sha256.New()
+	raw := bufio.NewReader(io.TeeReader(file, hash))
+
+	compressionType := CompressionTypeForPath(cleanPath)
+	if compressionType == CompressionNone {
+		compressionType = DetectCompression(raw)
+	}
+
+	entry := ManifestEntry{
+		Name:        filepath.Base(cleanPath),
+		Size:        info.Size(),
+		Compression: CompressionTypeString(compressionType),
+		LevelCounts: make(map[string]int64),
+	}
+	if matches := rotatedTimestampPattern.FindStringSubmatch(TrimCompressionSuffix(entry.Name)); matches != nil {
+		entry.RotationTime, _ = time.Parse(RotationTimeFormat, matches[2])
+	}
+
+	if info.Size() > 0 {
+		content, err := NewDecompressingReader(io.NopCloser(raw), compressionType)
+		if err != nil {
+			return ManifestEntry{}, err
+		}
+		err = scanManifestEntries(content, &entry)
+		_ = content.Close() // Best effort close of the decompressor
+		if errors.Is(err, bufio.ErrTooLong) {
+			// Not a line-based format, such as MessagePack or CBOR
+			entry.EntryCount = 0
+			err = nil
+		}
+		if err != nil {
+			return ManifestEntry{}, fmt.Errorf("scanning %s: %w", entry.Name, err)
+		}
+		if entry.EntryCount == 0 {
+			entry.ContentUnknown = true
+			entry.LevelCounts = nil
+			entry.FirstTimestamp, entry.LastTimestamp = time.Time{}, time.Time{}
+		}
+	}
+
+	// Hash anything the decompressor did not consume
+	if _, err := io.Copy(io.Discard, raw); err != nil {
+		return ManifestEntry{}, err
+	}
+	entry.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	if len(entry.LevelCounts) == 0 {
+		entry.LevelCounts = nil
+	}
+	return entry, nil
+}
+
+// Patterns used to recognise entries while scanning
+var (
+	manifestJSONKeys        = formatters.JSONReadKeys()
+	manifestTextHeader      = regexp.MustCompile(`^\[([^\]]+)\] \[([A-Za-z]+)\]`)
+	rotatedTimestampPattern = regexp.MustCompile(`^(.*)\.(\d{8}-\d{6}\.\d{3})$`)
+	manifestTimeFormats     = []string{
+		time.RFC3339Nano,
+		"2006-01-02 15:04:05.000",
+		"2006-01-02 15:04:05",
+	}
+)
+
+// scanManifestEntries counts the entries in r and records their time range
+func scanManifestEntries(r io.Reader, entry *ManifestEntry) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+
+	for scanner.Scan() {
+		// Entries of JSON array files after the first are led by a comma
+		line := strings.TrimPrefix(scanner.Text(), ",")
+
+		var timestamp, level string
+		if strings.HasPrefix(line, "{") {
+			var header map[string]interface{}
+			if err := json.Unmarshal([]byte(line), &header); err != nil {
+				continue
+			}
+			timestamp, level = jsonHeader(header)
+		} else if matches := manifestTextHeader.FindStringSubmatch(line); matches != nil {
+			timestamp, level = matches[1], matches[2]
+		} else {
+			continue
+		}
+
+		entry.EntryCount++
+		if level != "" {
+			entry.LevelCounts[strings.ToLower(level)]++
+		}
+		if t, ok := parseManifestTime(timestamp); ok {
+			if entry.FirstTimestamp.IsZero() || t.Before(entry.FirstTimestamp) {
+				entry.FirstTimestamp = t
+			}
+			if t.After(entry.LastTimestamp) {
+				entry.LastTimestamp = t
+			}
+		}
+	}
+
+	return scanner.Err()
+}
+
+// jsonHeader returns the timestamp and level of a JSON entry, written with
+// Omni's own keys or those of a JSON preset
+func jsonHeader(header map[string]interface{}) (timestamp, level string) {
+	for _, keys := range manifestJSONKeys {
+		if value, ok := header[keys.Timestamp].(string); ok && timestamp == "" {
+			timestamp = value
+		}
+		if value, ok := header[keys.Level].(string); ok && level == "" {
+			level = value
+		}
+	}
+	return timestamp, level
+}
+
+// parseManifestTime parses an entry timestamp
+func parseManifestTime(value string) (time.Time, bool) {
+	for _, format := range manifestTimeFormats {
+		if t, err := time.Parse(format, value); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// fileChecksum returns the hex SHA-256 of a file
+func fileChecksum(path string) (string, error) {
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = file.Close() }() // Best effort close, the file was only read
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// logPathForRotated returns the active log path a rotated file belongs to
+func logPathForRotated(rotatedPath string) (string, bool) {
+	matches := rotatedTimestampPattern.FindStringSubmatch(TrimCompressionSuffix(filepath.Clean(rotatedPath)))
+	if matches == nil {
+		return "", false
+	}
+	return matches[1], true
+}
+
+// SetManifestEnabled controls whether rotations and compressions are recorded
+// in a manifest next to each log. Recording a rotation reads the rotated file
+// once to count its entries and compute its checksum; RotateFile does this in
+// the background and FlushManifest waits for it.
+func (r *RotationManager) SetManifestEnabled(enabled bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.manifestEnabled = enabled
+}
+
+// IsManifestEnabled returns whether the manifest is maintained
+func (r *RotationManager) IsManifestEnabled() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.manifestEnabled
+}
+
+// RecordRotation scans a rotated file and adds it to the manifest of logPath
+func (r *RotationManager) RecordRotation(logPath, rotatedPath string) error {
+	entry, err := ScanLogFile(rotatedPath)
+	if err != nil {
+		return fmt.Errorf("scanning rotated file: %w", err)
+	}
+
+	return r.updateManifest(logPath, func(m *Manifest) {
+		m.Files = append(removeManifestEntry(m.Files, entry.Name), entry)
+	})
+}
+
+// RecordRotationAsync adds a rotated file to the manifest of logPath in the
+// background. Errors are passed to the error handler.
+func (r *RotationManager) RecordRotationAsync(logPath, rotatedPath string) {
+	r.queueRecord(manifestRecord{logPath: logPath, rotatedPath: rotatedPath})
+}
+
+// FlushManifest waits until every queued rotation is recorded in the manifest
+func (r *RotationManager) FlushManifest() {
+	r.recordsMu.Lock()
+	done := r.recordsDone
+	r.recordsMu.Unlock()
+
+	if done != nil {
+		<-done
+	}
+}
+
+// manifestRecord is a rotated file waiting to be recorded in the manifest
+type manifestRecord struct {
+	logPath     string
+	rotatedPath string
+	recorded    func(rotatedPath string) // Called once the file is recorded, may be nil
+}
+
+// queueRecord queues a rotated file for the record worker, starting it if idle
+func (r *RotationManager) queueRecord(record manifestRecord) {
+	r.recordsMu.Lock()
+	defer r.recordsMu.Unlock()
+
+	r.records = append(r.records, record)
+	if r.recordsDone == nil {
+		r.recordsDone = make(chan struct{})
+		go r.recordWorker(r.recordsDone)
+	}
+}
+
+// recordWorker records queued rotated files in order and exits once the queue is empty
+func (r *RotationManager) recordWorker(done chan struct{}) {
+	for {
+		r.recordsMu.Lock()
+		if len(r.records) == 0 {
+			r.recordsDone = nil
+			r.recordsMu.Unlock()
+			close(done)
+			return
+		}
+		record := r.records[0]
+		r.records = r.records[1:]
+		r.recordsMu.Unlock()
+
+		err := r.RecordRotation(record.logPath, record.rotatedPath)
+		if errors.Is(err, os.ErrNotExist) {
+			continue // Already removed by cleanup
+		}
+		if err != nil {
+			r.reportManifestError(record.logPath, err)
+		}
+		if record.recorded != nil {
+			record.recorded(record.rotatedPath)
+		}
+	}
+}
+
+// RecordCompression updates the manifest entry of a rotated file after it
+// was compressed into compressedPath. Counts and timestamps are carried over
+// from the existing entry; files missing from the manifest are scanned.
+func (r *RotationManager) RecordCompression(originalPath, compressedPath string) error {
+	logPath, ok := logPathForRotated(originalPath)
+	if !ok {
+		return fmt.Errorf("not a rotated log file: %s", originalPath)
+	}
+
+	info, err := os.Stat(compressedPath)
+	if err != nil {
+		return err
+	}
+	sum, err := fileChecksum(compressedPath)
+	if err != nil {
+		return err
+	}
+
+	originalName := filepath.Base(originalPath)
+	var scanErr error
+	err = r.updateManifest(logPath, func(m *Manifest) {
+		entry, found := m.Entry(originalName)
+		if !found {
+			entry, scanErr = ScanLogFile(compressedPath)
+			if scanErr != nil {
+				return
+			}
+		}
+
+		entry.Name = filepath.Base(compressedPath)
+		entry.Size = info.Size()
+		entry.SHA256 = sum
+		entry.Compression = CompressionTypeString(CompressionTypeForPath(compressedPath))
+
+		files := removeManifestEntry(m.Files, originalName)
+		m.Files = append(removeManifestEntry(files, entry.Name), entry)
+	})
+	if scanErr != nil {
+		return fmt.Errorf("scanning compressed file: %w", scanErr)
+	}
+	return err
+}
+
+// pruneManifest drops entries for removed files if the manifest is maintained
+func (r *RotationManager) pruneManifest(logPath string) {
+	if !r.IsManifestEnabled() {
+		return
+	}
+	if _, err := os.Stat(ManifestPath(logPath)); err != nil {
+		return
+	}
+	if err := r.updateManifest(logPath, nil); err != nil {
+		r.reportManifestError(logPath, err)
+	}
+}
+
+// reportManifestError passes a manifest failure to the error handler
+func (r *RotationManager) reportManifestError(logPath string, err error) {
+	r.mu.RLock()
+	errorHandler := r.errorHandler
+	r.mu.RUnlock()
+
+	if errorHandler != nil {
+		errorHandler("manifest", logPath, "Failed to update rotation manifest", err)
+	}
+}
+
+// updateManifest applies update to the manifest of logPath, drops entries
+// whose files no longer exist and atomically replaces the manifest file.
+func (r *RotationManager) updateManifest(logPath string, update func(*Manifest)) error {
+	r.manifestMu.Lock()
+	defer r.manifestMu.Unlock()
+
+	manifest, err := ReadManifest(logPath)
+	if err != nil {
+		return err
+	}
+	if update != nil {
+		update(manifest)
+	}
+
+	dir := filepath.Dir(logPath)
+	files := manifest.Files[:0]
+	for _, entry := range manifest.Files {
+		if _, err := os.Stat(filepath.Join(dir, entry.Name)); err == nil {
+			files = append(files, entry)
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].RotationTime.Before(files[j].RotationTime)
+	})
+
+	manifest.Version = ManifestVersion
+	manifest.LogFile = filepath.Base(logPath)
+	manifest.Updated = time.Now().UTC()
+	manifest.Files = files
+
+	return writeManifest(logPath, manifest)
+}
+
+// writeManifest writes the manifest to a temporary file and renames it into
+// place so readers never see a partial manifest
+func writeManifest(logPath string, manifest *Manifest) (err error) {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding manifest: %w", err)
+	}
+
+	path := ManifestPath(logPath)
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating manifest: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmp.Name()) // Best effort cleanup
+		}
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("writing manifest: %w", err)
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("syncing manifest: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("closing manifest: %w", err)
+	}
+	// #nosec G302 - manifests are read by log tooling like the logs themselves
+	if err = os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("setting manifest permissions: %w", err)
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("replacing manifest: %w", err)
+	}
+	return nil
+}
+
+// removeManifestEntry returns files without the entry for name
+func removeManifestEntry(files []ManifestEntry, name string) []ManifestEntry {
+	result := files[:0]
+	for _, entry := range files {
+		if entry.Name != name {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
@@ -0,0 +1,311 @@
+package features
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const manifestTestLog = `[2024-01-01T10:00:00Z] [INFO] started
+{"level":"warn","message":"slow","timestamp":"2024-01-01T10:05:00Z"}
+[2024-01-01T10:02:00Z] [ERROR] failed stack_trace=goroutine 1 [running]:
+main.main()
+{"fields":{"user":"bob"},"level":"INFO","message":"login","timestamp":"2024-01-01T10:10:00.5Z"}
+`
+
+func TestScanLogFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log.20240101-101000.000")
+	if err := os.WriteFile(path, []byte(manifestTestLog), 0600); err != nil {
+		t.Fatalf("Failed to write log: %v", err)
+	}
+
+	entry, err := ScanLogFile(path)
+	if err != nil {
+		t.Fatalf("ScanLogFile failed: %v", err)
+	}
+
+	if entry.EntryCount != 4 {
+		t.Errorf("Expected 4 entries, got %d", entry.EntryCount)
+	}
+	if entry.LevelCounts["info"] != 2 || entry.LevelCounts["warn"] != 1 || entry.LevelCounts["error"] != 1 {
+		t.Errorf("Unexpected level counts: %v", entry.LevelCounts)
+	}
+	if !entry.FirstTimestamp.Equal(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected first timestamp: %v", entry.FirstTimestamp)
+	}
+	if !entry.LastTimestamp.Equal(time.Date(2024, 1, 1, 10, 10, 0, 500000000, time.UTC)) {
+		t.Errorf("Unexpected last timestamp: %v", entry.LastTimestamp)
+	}
+	if !entry.RotationTime.Equal(time.Date(2024, 1, 1, 10, 10, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected rotation time: %v", entry.RotationTime)
+	}
+	if entry.Size != int64(len(manifestTestLog)) || entry.Compression != "none" {
+		t.Errorf("Unexpected size or compression: %d %s", entry.Size, entry.Compression)
+	}
+	sum, _ := fileChecksum(path)
+	if entry.SHA256 != sum || len(sum) != 64 {
+		t.Errorf("Unexpected checksum: %s", entry.SHA256)
+	}
+
+	// Compressed files are scanned after decompression but hashed as stored
+	cm := NewCompressionManager()
+	if err := cm.SetCompression(CompressionZstd); err != nil {
+		t.Fatalf("SetCompression failed: %v", err)
+	}
+	if err := cm.CompressFileSync(path); err != nil {
+		t.Fatalf("Compression failed: %v", err)
+	}
+	compressed, err := ScanLogFile(path + ZstdSuffix)
+	if err != nil {
+		t.Fatalf("ScanLogFile failed on compressed file: %v", err)
+	}
+	if compressed.EntryCount != 4 || compressed.Compression != "zstd" || compressed.SHA256 == entry.SHA256 {
+		t.Errorf("Unexpected compressed entry: %+v", compressed)
+	}
+}
+
+func TestScanLogFileJSONPresets(t *testing.T) {
+	presets := map[string]string{
+		"ecs": `{"@timestamp":"2024-01-01T10:00:00Z","ecs.version":"1.6.0","log.level":"info","message":"started"}
+{"@timestamp":"2024-01-01T10:05:00Z","ecs.version":"1.6.0","log.level":"error","message":"failed"}
+`,
+		"gcp": `{"message":"started","severity":"INFO","timestamp":"2024-01-01T10:00:00Z"}
+{"message":"failed","severity":"ERROR","timestamp":"2024-01-01T10:05:00Z"}
+`,
+		"datadog": `{"message":"started","status":"info","timestamp":"2024-01-01T10:00:00Z"}
+{"message":"failed","status":"error","timestamp":"2024-01-01T10:05:00Z"}
+`,
+	}
+
+	for name, content := range presets {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "app.log.20240101-101000.000")
+			if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+				t.Fatalf("Failed to write log: %v", err)
+			}
+
+			entry, err := ScanLogFile(path)
+			if err != nil {
+				t.Fatalf("ScanLogFile failed: %v", err)
+			}
+			if entry.EntryCount != 2 || entry.LevelCounts["info"] != 1 || entry.LevelCounts["error"] != 1 {
+				t.Errorf("Unexpected counts: %d %v", entry.EntryCount, entry.LevelCounts)
+			}
+			if !entry.FirstTimestamp.Equal(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)) ||
+				!entry.LastTimestamp.Equal(time.Date(2024, 1, 1, 10, 5, 0, 0, time.UTC)) {
+				t.Errorf("Unexpected time range: %v - %v", entry.FirstTimestamp, entry.LastTimestamp)
+			}
+		})
+	}
+}
+
+func TestScanLogFileUnknownContent(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"logfmt": []byte("ts=2024-01-01T10:00:00Z level=info msg=started\n"),
+		"csv":    []byte("timestamp,level,message\n2024-01-01T10:00:00Z,info,started\n"),
+		"binary": []byte(strings.Repeat("\x83\xa2ts\xd6\xff", 1024*1024)),
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, content, 0600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		entry, err := ScanLogFile(path)
+		if err != nil {
+			t.Fatalf("ScanLogFile(%s) failed: %v", name, err)
+		}
+		if !entry.ContentUnknown || entry.EntryCount != 0 || entry.LevelCounts != nil {
+			t.Errorf("Expected %s content to be unknown, got %+v", name, entry)
+		}
+		if !entry.Overlaps(time.Now(), time.Time{}) {
+			t.Errorf("Expected %s to overlap any range", name)
+		}
+	}
+
+	// Empty files hold no entries rather than unknown ones
+	empty := filepath.Join(dir, "empty")
+	if err := os.WriteFile(empty, nil, 0600); err != nil {
+		t.Fatalf("Failed to write empty file: %v", err)
+	}
+	if entry, err := ScanLogFile(empty); err != nil || entry.ContentUnknown {
+		t.Errorf("Expected an empty file to be known, got %+v (%v)", entry, err)
+	}
+}
+
+func TestRotationManagerManifest(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "app.log")
+
+	rm := NewRotationManager()
+	rm.SetManifestEnabled(true)
+	if !rm.IsManifestEnabled() {
+		t.Fatal("Expected manifest to be enabled")
+	}
+
+	cm := NewCompressionManager()
+	if err := cm.SetCompression(CompressionGzip); err != nil {
+		t.Fatalf("SetCompression failed: %v", err)
+	}
+	cm.SetCompletionHandler(func(original, compressed string) {
+		if err := rm.RecordCompression(original, compressed); err != nil {
+			t.Errorf("RecordCompression failed: %v", err)
+		}
+	})
+
+	var rotated []string
+	for i := 0; i < 2; i++ {
+		if err := os.WriteFile(logPath, []byte(manifestTestLog), 0600); err != nil {
+			t.Fatalf("Failed to write log: %v", err)
+		}
+		path, err := rm.RotateFile(logPath, nil)
+		if err != nil {
+			t.Fatalf("RotateFile failed: %v", err)
+		}
+		rotated = append(rotated, path)
+		time.Sleep(2 * time.Millisecond) // Distinct rotation timestamps
+	}
+
+	// Rotated files are scanned in the background
+	rm.FlushManifest()
+	manifest, err := ReadManifest(logPath)
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	if manifest.Version != ManifestVersion || manifest.LogFile != "app.log" || len(manifest.Files) != 2 {
+		t.Fatalf("Unexpected manifest: %+v", manifest)
+	}
+	if manifest.Files[0].Name != filepath.Base(rotated[0]) {
+		t.Errorf("Expected oldest file first, got %s", manifest.Files[0].Name)
+	}
+
+	// Compression replaces the entry and keeps its counts
+	if err := cm.CompressFileSync(rotated[0]); err != nil {
+		t.Fatalf("Compression failed: %v", err)
+	}
+	manifest, _ = ReadManifest(logPath)
+	entry, ok := manifest.Entry(filepath.Base(rotated[0]) + GzipSuffix)
+	if !ok {
+		t.Fatalf("Expected compressed entry, got %+v", manifest.Files)
+	}
+	if entry.Compression != "gzip" || entry.EntryCount != 4 || len(manifest.Files) != 2 {
+		t.Errorf("Unexpected compressed entry: %+v", entry)
+	}
+	if _, ok := manifest.Entry(filepath.Base(rotated[0])); ok {
+		t.Error("Expected uncompressed entry to be replaced")
+	}
+
+	if err := VerifyManifest(logPath); err != nil {
+		t.Errorf("VerifyManifest failed: %v", err)
+	}
+
+	// Tampering is detected
+	if err := os.WriteFile(rotated[1], []byte("tampered\n"), 0600); err != nil {
+		t.Fatalf("Failed to tamper: %v", err)
+	}
+	if err := VerifyManifest(logPath); !errors.Is(err, ErrManifestChecksum) {
+		t.Errorf("Expected ErrManifestChecksum, got %v", err)
+	}
+
+	// Cleanup prunes removed files from the manifest
+	rm.SetMaxFiles(1)
+	if err := rm.CleanupOldFiles(logPath); err != nil {
+		t.Fatalf("CleanupOldFiles failed: %v", err)
+	}
+	manifest, _ = ReadManifest(logPath)
+	if len(manifest.Files) != 1 || manifest.Files[0].Name != filepath.Base(rotated[1]) {
+		t.Errorf("Expected only the newest file after cleanup, got %+v", manifest.Files)
+	}
+
+	// No temporary files are left behind
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	if len(matches) != 0 {
+		t.Errorf("Unexpected temporary files: %v", matches)
+	}
+}
+
+func TestRotationManagerManifestBeforeCompression(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(logPath, []byte(manifestTestLog), 0600); err != nil {
+		t.Fatalf("Failed to write log: %v", err)
+	}
+
+	rm := NewRotationManager()
+	rm.SetManifestEnabled(true)
+	queued := make(chan bool, 1)
+	rm.SetCompressionCallback(func(path string) {
+		// The file is handed to compression only once it is recorded
+		manifest, err := ReadManifest(logPath)
+		_, recorded := manifest.Entry(filepath.Base(path))
+		queued <- err == nil && recorded
+	})
+
+	if _, err := rm.RotateFile(logPath, nil); err != nil {
+		t.Fatalf("RotateFile failed: %v", err)
+	}
+	rm.FlushManifest()
+
+	select {
+	case recorded := <-queued:
+		if !recorded {
+			t.Error("Expected the rotated file to be in the manifest before compression")
+		}
+	default:
+		t.Fatal("Expected the rotated file to be queued for compression")
+	}
+}
+
+func TestRotationManagerManifestDisabled(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(logPath, []byte(manifestTestLog), 0600); err != nil {
+		t.Fatalf("Failed to write log: %v", err)
+	}
+
+	if _, err := NewRotationManager().RotateFile(logPath, nil); err != nil {
+		t.Fatalf("RotateFile failed: %v", err)
+	}
+	if _, err := os.Stat(ManifestPath(logPath)); !os.IsNotExist(err) {
+		t.Error("Expected no manifest when disabled")
+	}
+
+	manifest, err := ReadManifest(logPath)
+	if err != nil || len(manifest.Files) != 0 {
+		t.Errorf("Expected empty manifest, got %+v, %v", manifest, err)
+	}
+}
+
+func TestManifestEntriesInRange(t *testing.T) {
+	at := func(hour int) time.Time { return time.Date(2024, 1, 1, hour, 0, 0, 0, time.UTC) }
+	manifest := &Manifest{Files: []ManifestEntry{
+		{Name: "a", FirstTimestamp: at(0), LastTimestamp: at(1)},
+		{Name: "b", FirstTimestamp: at(2), LastTimestamp: at(3)},
+		{Name: "c"}, // Unknown time range
+	}}
+
+	names := func(entries []ManifestEntry) string {
+		var result []string
+		for _, entry := range entries {
+			result = append(result, entry.Name)
+		}
+		return strings.Join(result, ",")
+	}
+
+	tests := []struct {
+		since, until time.Time
+		expected     string
+	}{
+		{time.Time{}, time.Time{}, "a,b,c"},
+		{at(2), time.Time{}, "b,c"},
+		{time.Time{}, at(2), "a,c"},
+		{at(1), at(3), "a,b,c"},
+		{at(4), at(5), "c"},
+	}
+	for _, tt := range tests {
+		if got := names(manifest.EntriesInRange(tt.since, tt.until)); got != tt.expected {
+			t.Errorf("EntriesInRange(%v, %v) = %s, want %s", tt.since, tt.until, got, tt.expected)
+		}
+	}
+}
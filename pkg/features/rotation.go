@@ -31,6 +31,15 @@ type RotationManager struct {
 	// Compression callback
 	compressionCallback func(path string)
 
+	// Rotation manifest
+	manifestEnabled bool
+	manifestMu      sync.Mutex // Serializes manifest updates
+
+	// Rotated files waiting to be scanned into the manifest
+	records     []manifestRecord
+	recordsDone chan struct{} // Closed when the record worker goes idle, nil while idle
+	recordsMu   sync.Mutex
+
 	// Current log paths being managed
 	logPaths []string
 	pathsMu  sync.RWMutex
@@ -231,13 +240,17 @@ func (r *RotationManager) RotateFile(path string, writer *bufio.Writer) (string,
 		return "", fmt.Errorf("rotating log: %w", err)
 	}
 
-	// Queue for compression if callback is set
 	r.mu.RLock()
 	compressionCallback := r.compressionCallback
 	metricsHandler := r.metricsHandler
+	manifestEnabled := r.manifestEnabled
 	r.mu.RUnlock()
 
-	if compressionCallback != nil {
+	// Scanning and hashing the file happens in the background; it is queued
+	// for compression once recorded so it is not renamed while being scanned
+	if manifestEnabled {
+		r.queueRecord(manifestRecord{logPath: cleanPath, rotatedPath: rotatedPath, recorded: compressionCallback})
+	} else if compressionCallback != nil {
 		compressionCallback(rotatedPath)
 	}
 
@@ -274,9 +287,9 @@ func (r *RotationManager) CleanupOldLogs(logPath string) error {
 	}
 
 	// Match patterns for timestamp-based log files
-	// Pattern: base.YYYYMMDD-HHMMSS.sss or base.YYYYMMDD-HHMMSS.sss.gz
-	pattern := regexp.MustCompile(fmt.Sprintf(`^%s\.(\d{8}-\d{6}\.\d{3})(?:\.gz)?$`, regexp.QuoteMeta(base)))
+	pattern := rotatedFilePattern(base)
 
+	removed := false
 	for _, file := range files {
 		// Skip directories
 		if file.IsDir() {
@@ -314,6 +327,7 @@ func (r *RotationManager) CleanupOldLogs(logPath string) error {
 					r.errorHandler("cleanup", filePath, "Failed to remove old log file", err)
 				}
 			} else {
+				removed = true
 				// Track cleanup metric
 				if r.metricsHandler != nil {
 					r.metricsHandler("cleanup_completed")
@@ -322,6 +336,10 @@ func (r *RotationManager) CleanupOldLogs(logPath string) error {
 		}
 	}
 
+	if removed {
+		r.pruneManifest(logPath)
+	}
+
 	return nil
 }
 
@@ -346,8 +364,7 @@ func (r *RotationManager) CleanupOldFiles(logPath string) error {
 	}
 
 	// Match patterns for timestamp-based log files
-	// Pattern: base.YYYYMMDD-HHMMSS.sss or base.YYYYMMDD-HHMMSS.sss.gz
-	pattern := regexp.MustCompile(fmt.Sprintf(`^%s\.(\d{8}-\d{6}\.\d{3})(?:\.gz)?$`, regexp.QuoteMeta(base)))
+	pattern := rotatedFilePattern(base)
 
 	// Collect matching files with their timestamps
 	type logFile struct {
@@ -393,6 +410,7 @@ func (r *RotationManager) CleanupOldFiles(logPath string) error {
 				}
 			}
 		}
+		r.pruneManifest(logPath)
 	}
 
 	return nil
@@ -417,7 +435,7 @@ func (r *RotationManager) GetRotatedFiles(logPath string) ([]RotatedFileInfo, er
 	}
 
 	// Match patterns for timestamp-based log files
-	pattern := regexp.MustCompile(fmt.Sprintf(`^%s\.(\d{8}-\d{6}\.\d{3})(?:\.gz)?$`, regexp.QuoteMeta(base)))
+	pattern := rotatedFilePattern(base)
 
 	var rotatedFiles []RotatedFileInfo
 	for _, file := range files {
@@ -446,7 +464,7 @@ func (r *RotationManager) GetRotatedFiles(logPath string) ([]RotatedFileInfo, er
 			Name:         file.Name(),
 			Size:         fileInfo.Size(),
 			RotationTime: fileTime,
-			IsCompressed: filepath.Ext(file.Name()) == ".gz",
+			IsCompressed: IsCompressedFile(file.Name()),
 		})
 	}
 
@@ -509,9 +527,25 @@ func (r *RotationManager) GetCleanupInterval() time.Duration {
 	return r.cleanupInterval
 }
 
+// GetLogPaths returns the log paths covered by the cleanup routine
+func (r *RotationManager) GetLogPaths() []string {
+	r.pathsMu.RLock()
+	defer r.pathsMu.RUnlock()
+	paths := make([]string, len(r.logPaths))
+	copy(paths, r.logPaths)
+	return paths
+}
+
 // IsRunning returns whether the cleanup routine is running
 func (r *RotationManager) IsRunning() bool {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	return r.cleanupTicker != nil
 }
+
+// rotatedFilePattern matches rotated files for base, with or without a compression suffix.
+// Pattern: base.YYYYMMDD-HHMMSS.sss, base.YYYYMMDD-HHMMSS.sss.gz or base.YYYYMMDD-HHMMSS.sss.zst
+func rotatedFilePattern(base string) *regexp.Regexp {
+	return regexp.MustCompile(fmt.Sprintf(`^%s\.(\d{8}-\d{6}\.\d{3})(?:%s|%s)?$`,
+		regexp.QuoteMeta(base), regexp.QuoteMeta(GzipSuffix), regexp.QuoteMeta(ZstdSuffix)))
+}
@@ -365,6 +365,13 @@ func TestGetRotatedFiles(t *testing.T) {
 		t.Fatalf("Failed to create file2: %v", err)
 	}
 
+	// Zstd compressed rotated file
+	time3 := now.Add(-3 * time.Hour)
+	file3 := logFile + "." + time3.Format(RotationTimeFormat) + ".zst"
+	if err := os.WriteFile(file3, []byte("compressed"), 0644); err != nil {
+		t.Fatalf("Failed to create file3: %v", err)
+	}
+
 	// Current log file (should not be included)
 	if err := os.WriteFile(logFile, []byte("current"), 0644); err != nil {
 		t.Fatalf("Failed to create current log: %v", err)
@@ -376,8 +383,8 @@ func TestGetRotatedFiles(t *testing.T) {
 		t.Fatalf("Failed to get rotated files: %v", err)
 	}
 
-	if len(rotatedFiles) != 2 {
-		t.Errorf("Expected 2 rotated files, got %d", len(rotatedFiles))
+	if len(rotatedFiles) != 3 {
+		t.Errorf("Expected 3 rotated files, got %d", len(rotatedFiles))
 	}
 
 	// Check that files are sorted by rotation time (newest first)
@@ -389,7 +396,7 @@ func TestGetRotatedFiles(t *testing.T) {
 
 	// Check compressed flag
 	for _, rf := range rotatedFiles {
-		if strings.HasSuffix(rf.Name, ".gz") && !rf.IsCompressed {
+		if (strings.HasSuffix(rf.Name, ".gz") || strings.HasSuffix(rf.Name, ".zst")) && !rf.IsCompressed {
 			t.Errorf("File %s should be marked as compressed", rf.Name)
 		}
 	}
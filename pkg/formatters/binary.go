@@ -0,0 +1,125 @@
+package formatters
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/types"
+)
+
+// Keys of the map written by MsgpackFormatter and CBORFormatter. The schema
+// is shared by both formats and is stable: keys are only ever added.
+const (
+	BinaryTimeKey     = "ts"          // Timestamp: MessagePack timestamp extension, CBOR epoch nanoseconds
+	BinaryLevelKey    = "level"       // Level number, LevelTrace to LevelError
+	BinaryMessageKey  = "msg"         // Message
+	BinaryFieldsKey   = "fields"      // Fields with their int, float, bool, bytes and time types
+	BinaryStackKey    = "stack_trace" // Stack trace, when present
+	BinaryFileKey     = "file"        // Source file, when known
+	BinaryLineKey     = "line"        // Source line, when known
+	BinaryMetadataKey = "metadata"    // Metadata, when present
+)
+
+// binaryMaxDepth limits the nesting of field values written by the binary formatters
+const binaryMaxDepth = 10
+
+// binaryRecord holds the values of a log message in the binary schema
+type binaryRecord struct {
+	time       time.Time
+	level      int
+	message    string
+	fields     map[string]interface{}
+	stackTrace string
+	file       string
+	line       int
+	metadata   map[string]interface{}
+}
+
+// newBinaryRecord resolves the values of a log message for the binary formatters
+func newBinaryRecord(msg types.LogMessage) binaryRecord {
+	r := binaryRecord{time: msg.Timestamp, level: msg.Level}
+	if msg.Entry == nil {
+		r.message = msg.Format
+		if len(msg.Args) > 0 {
+			r.message = fmt.Sprintf(msg.Format, msg.Args...)
+		}
+		return r
+	}
+
+	entry := msg.Entry
+	if r.time.IsZero() {
+		r.time, _ = time.Parse(time.RFC3339Nano, entry.Timestamp)
+	}
+	if entry.Level != "" {
+		r.level = LevelNumber(entry.Level)
+	}
+	r.message = entry.Message
+	r.fields = entry.Fields
+	r.stackTrace = entry.StackTrace
+	r.file = entry.File
+	r.line = entry.Line
+	r.metadata = entry.Metadata
+	return r
+}
+
+// size returns the number of keys the record is written with
+func (r binaryRecord) size() int {
+	n := 2 // level and message
+	for _, present := range []bool{
+		!r.time.IsZero(), len(r.fields) > 0, r.stackTrace != "", r.file != "", r.line > 0, len(r.metadata) > 0,
+	} {
+		if present {
+			n++
+		}
+	}
+	return n
+}
+
+// LevelNumber converts a level name into LevelTrace to LevelError. Fatal,
+// critical and panic count as LevelError and unknown names as LevelInfo.
+func LevelNumber(level string) int {
+	switch strings.ToLower(level) {
+	case "trace":
+		return LevelTrace
+	case "debug":
+		return LevelDebug
+	case "warn", "warning":
+		return LevelWarn
+	case "error", "fatal", "critical", "panic":
+		return LevelError
+	default:
+		return LevelInfo
+	}
+}
+
+// binaryEntry converts a decoded binary record into a log entry
+func binaryEntry(format string, value interface{}) (*types.LogEntry, error) {
+	record, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: expected a map, got %T", format, value)
+	}
+
+	entry := &types.LogEntry{}
+	switch ts := record[BinaryTimeKey].(type) {
+	case time.Time:
+		entry.Timestamp = ts.UTC().Format(time.RFC3339Nano)
+	case int64:
+		entry.Timestamp = time.Unix(0, ts).UTC().Format(time.RFC3339Nano)
+	}
+	switch level := record[BinaryLevelKey].(type) {
+	case int64:
+		entry.Level = levelToString(int(level))
+	case string:
+		entry.Level = level
+	}
+	entry.Message, _ = record[BinaryMessageKey].(string)
+	entry.Fields, _ = record[BinaryFieldsKey].(map[string]interface{})
+	entry.StackTrace, _ = record[BinaryStackKey].(string)
+	entry.File, _ = record[BinaryFileKey].(string)
+	if line, ok := record[BinaryLineKey].(int64); ok {
+		entry.Line = int(line)
+	}
+	entry.Metadata, _ = record[BinaryMetadataKey].(map[string]interface{})
+	return entry, nil
+}
@@ -0,0 +1,206 @@
+package formatters
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wayneeseguin/omni/internal/cbor"
+	"github.com/wayneeseguin/omni/internal/msgpack"
+	"github.com/wayneeseguin/omni/pkg/types"
+)
+
+// binaryFormats pairs each binary formatter with its decoder
+var binaryFormats = []struct {
+	name      string
+	formatter types.Formatter
+	decode    func([]byte) (*types.LogEntry, []byte, error)
+}{
+	{"msgpack", NewMsgpackFormatter(), DecodeMsgpackEntry},
+	{"cbor", NewCBORFormatter(), DecodeCBOREntry},
+}
+
+func TestBinaryFormatters_RoundTrip(t *testing.T) {
+	created := time.Date(2024, 5, 1, 11, 59, 0, 123456789, time.UTC)
+	msg := types.LogMessage{
+		Timestamp: time.Date(2024, 5, 1, 12, 0, 0, 250, time.UTC),
+		Entry: &types.LogEntry{
+			Level:   "WARN",
+			Message: "slow request",
+			Fields: map[string]interface{}{
+				"status":   200,
+				"latency":  0.25,
+				"cached":   false,
+				"body":     []byte{0xde, 0xad},
+				"created":  created,
+				"user":     nil,
+				"err":      errors.New("timed out"),
+				"request":  map[string]interface{}{"path": "/a", "retries": int64(-2)},
+				"tags":     []string{"a", "b"},
+				"counters": []interface{}{uint64(1), 2.5},
+			},
+			StackTrace: "main.main()",
+			File:       "main.go",
+			Line:       42,
+			Metadata:   map[string]interface{}{"host": "web1"},
+		},
+	}
+	expected := &types.LogEntry{
+		Timestamp: "2024-05-01T12:00:00.00000025Z",
+		Level:     "WARN",
+		Message:   "slow request",
+		Fields: map[string]interface{}{
+			"status":   int64(200),
+			"latency":  0.25,
+			"cached":   false,
+			"body":     []byte{0xde, 0xad},
+			"created":  created,
+			"user":     nil,
+			"err":      "timed out",
+			"request":  map[string]interface{}{"path": "/a", "retries": int64(-2)},
+			"tags":     []interface{}{"a", "b"},
+			"counters": []interface{}{int64(1), 2.5},
+		},
+		StackTrace: "main.main()",
+		File:       "main.go",
+		Line:       42,
+		Metadata:   map[string]interface{}{"host": "web1"},
+	}
+
+	for _, format := range binaryFormats {
+		t.Run(format.name, func(t *testing.T) {
+			data, err := format.formatter.Format(msg)
+			if err != nil {
+				t.Fatalf("Format failed: %v", err)
+			}
+			entry, rest, err := format.decode(data)
+			if err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+			if len(rest) != 0 {
+				t.Errorf("Expected no remaining bytes, got %d", len(rest))
+			}
+			if !reflect.DeepEqual(entry, expected) {
+				t.Errorf("Expected %#v, got %#v", expected, entry)
+			}
+		})
+	}
+}
+
+func TestBinaryFormatters_Message(t *testing.T) {
+	msg := types.LogMessage{
+		Level:     LevelError,
+		Format:    "disk %d%% full",
+		Args:      []interface{}{91},
+		Timestamp: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	for _, format := range binaryFormats {
+		t.Run(format.name, func(t *testing.T) {
+			var stream []byte
+			for i := 0; i < 2; i++ {
+				data, err := format.formatter.Format(msg)
+				if err != nil {
+					t.Fatalf("Format failed: %v", err)
+				}
+				stream = append(stream, data...)
+			}
+
+			// Entries are self-delimiting, so a stream decodes one at a time
+			for i := 0; i < 2; i++ {
+				var entry *types.LogEntry
+				var err error
+				entry, stream, err = format.decode(stream)
+				if err != nil {
+					t.Fatalf("Decode %d failed: %v", i, err)
+				}
+				if entry.Timestamp != "2024-05-01T12:00:00Z" || entry.Level != "ERROR" || entry.Message != "disk 91% full" || entry.Fields != nil {
+					t.Errorf("Unexpected entry %+v", entry)
+				}
+			}
+			if len(stream) != 0 {
+				t.Errorf("Expected no remaining bytes, got %d", len(stream))
+			}
+		})
+	}
+}
+
+func TestBinaryFormatters_Schema(t *testing.T) {
+	msg := types.LogMessage{
+		Level:     LevelInfo,
+		Format:    "started",
+		Timestamp: time.Unix(1714564800, 5).UTC(),
+	}
+
+	data, err := NewMsgpackFormatter().Format(msg)
+	if err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+	value, _, err := msgpack.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	expected := map[string]interface{}{"ts": time.Unix(1714564800, 5).UTC(), "level": int64(LevelInfo), "msg": "started"}
+	if !reflect.DeepEqual(value, expected) {
+		t.Errorf("Expected msgpack %#v, got %#v", expected, value)
+	}
+
+	data, err = NewCBORFormatter().Format(msg)
+	if err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+	value, _, err = cbor.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	expected = map[string]interface{}{"ts": int64(1714564800000000005), "level": int64(LevelInfo), "msg": "started"}
+	if !reflect.DeepEqual(value, expected) {
+		t.Errorf("Expected CBOR %#v, got %#v", expected, value)
+	}
+}
+
+func TestBinaryFormatters_Errors(t *testing.T) {
+	circular := map[string]interface{}{}
+	circular["self"] = circular
+
+	for _, format := range binaryFormats {
+		t.Run(format.name, func(t *testing.T) {
+			raw := []byte("raw")
+			if data, _ := format.formatter.Format(types.LogMessage{Raw: raw}); !bytes.Equal(data, raw) {
+				t.Errorf("Expected raw bytes, got %q", data)
+			}
+
+			// Circular fields are cut off instead of recursing forever
+			data, err := format.formatter.Format(types.LogMessage{Entry: &types.LogEntry{Message: "m", Fields: circular}})
+			if err != nil {
+				t.Fatalf("Format failed: %v", err)
+			}
+			if _, _, err := format.decode(data); err != nil {
+				t.Errorf("Decode failed: %v", err)
+			}
+
+			if _, _, err := format.decode(data[:len(data)-1]); !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Errorf("Expected io.ErrUnexpectedEOF for a truncated entry, got %v", err)
+			}
+		})
+	}
+
+	if _, _, err := DecodeMsgpackEntry(msgpack.AppendString(nil, "not a map")); err == nil {
+		t.Error("Expected error for a msgpack value that is not a map")
+	}
+	if _, _, err := DecodeCBOREntry(cbor.AppendInt(nil, 1)); err == nil {
+		t.Error("Expected error for a CBOR value that is not a map")
+	}
+}
+
+func TestLevelNumber(t *testing.T) {
+	tests := map[string]int{"TRACE": 0, "debug": 1, "info": 2, "Warning": 3, "error": 4, "fatal": 4, "other": 2}
+	for level, expected := range tests {
+		if got := LevelNumber(level); got != expected {
+			t.Errorf("LevelNumber(%q) = %d, want %d", level, got, expected)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package formatters
+
+import (
+	"github.com/wayneeseguin/omni/internal/cbor"
+	"github.com/wayneeseguin/omni/pkg/types"
+)
+
+// CBORFormatter formats log messages as CBOR (RFC 8949) maps in the binary
+// schema described by the Binary*Key constants. The timestamp is written as
+// nanoseconds since the Unix epoch, the level as an integer, and fields keep
+// their integer, float, boolean, byte string and time types, with times
+// written as RFC 3339 date/time strings (tag 0) to keep nanoseconds and
+// offsets.
+//
+// Entries are not delimited: each is one self-contained CBOR data item,
+// suited to message-oriented destinations such as NATS or Redis, or to
+// files read back with DecodeCBOREntry.
+type CBORFormatter struct{}
+
+// NewCBORFormatter creates a new CBOR formatter
+func NewCBORFormatter() *CBORFormatter {
+	return &CBORFormatter{}
+}
+
+// Format formats a log message as a CBOR map
+func (f *CBORFormatter) Format(msg types.LogMessage) ([]byte, error) {
+	// Handle raw bytes - pass through as-is
+	if msg.Raw != nil {
+		return msg.Raw, nil
+	}
+
+	r := newBinaryRecord(msg)
+	b := cbor.AppendMapHeader(make([]byte, 0, 128), r.size())
+	if !r.time.IsZero() {
+		b = cbor.AppendInt(cbor.AppendString(b, BinaryTimeKey), r.time.UnixNano())
+	}
+	b = cbor.AppendInt(cbor.AppendString(b, BinaryLevelKey), int64(r.level))
+	b = cbor.AppendString(cbor.AppendString(b, BinaryMessageKey), r.message)
+	if len(r.fields) > 0 {
+		b = appendCBORField(cbor.AppendString(b, BinaryFieldsKey), r.fields, 0)
+	}
+	if r.stackTrace != "" {
+		b = cbor.AppendString(cbor.AppendString(b, BinaryStackKey), r.stackTrace)
+	}
+	if r.file != "" {
+		b = cbor.AppendString(cbor.AppendString(b, BinaryFileKey), r.file)
+	}
+	if r.line > 0 {
+		b = cbor.AppendInt(cbor.AppendString(b, BinaryLineKey), int64(r.line))
+	}
+	if len(r.metadata) > 0 {
+		b = appendCBORField(cbor.AppendString(b, BinaryMetadataKey), r.metadata, 0)
+	}
+	return b, nil
+}
+
+// appendCBORField appends a field value, limiting the nesting of maps and
+// slices so circular references cannot recurse forever
+func appendCBORField(b []byte, value interface{}, depth int) []byte {
+	if depth > binaryMaxDepth {
+		return cbor.AppendString(b, "[max depth exceeded]")
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		b = cbor.AppendMapHeader(b, len(v))
+		for key, item := range v {
+			b = appendCBORField(cbor.AppendString(b, key), item, depth+1)
+		}
+		return b
+	case []interface{}:
+		b = cbor.AppendArrayHeader(b, len(v))
+		for _, item := range v {
+			b = appendCBORField(b, item, depth+1)
+		}
+		return b
+	default:
+		return cbor.AppendValue(b, value)
+	}
+}
+
+// DecodeCBOREntry decodes the first entry written by CBORFormatter in data
+// and returns it with the remaining bytes. Fields keep their decoded types:
+// integers are int64, floats float64, byte strings []byte and date/time
+// tags time.Time. Errors wrap io.ErrUnexpectedEOF when data ends within the
+// entry.
+func DecodeCBOREntry(data []byte) (*types.LogEntry, []byte, error) {
+	value, rest, err := cbor.Decode(data)
+	if err != nil {
+		return nil, data, err
+	}
+	entry, err := binaryEntry("cbor", value)
+	if err != nil {
+		return nil, data, err
+	}
+	return entry, rest, nil
+}
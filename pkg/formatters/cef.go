@@ -0,0 +1,241 @@
+package formatters
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/types"
+)
+
+// Defaults for SecurityEventMapping
+const (
+	DefaultSecurityEventVendor  = "Omni"
+	DefaultSecurityEventVersion = "1.0"
+	DefaultSecurityEventIDField = "event_id"
+	DefaultSecurityEventID      = "log"
+)
+
+// SecurityEventMapping selects what CEF and LEEF formatters put in the
+// event header and how entry fields are named in the extension
+type SecurityEventMapping struct {
+	Vendor         string            // Device vendor; DefaultSecurityEventVendor when empty
+	Product        string            // Device product; the process name when empty
+	Version        string            // Device version; DefaultSecurityEventVersion when empty
+	EventIDField   string            // Field holding the signature/event ID; DefaultSecurityEventIDField when empty
+	DefaultEventID string            // Event ID of entries without EventIDField; DefaultSecurityEventID when empty
+	NameField      string            // Field holding the event name (CEF only); the message when absent
+	FieldMap       map[string]string // Entry field name to extension key, such as "client_ip": "src"
+}
+
+// header returns the vendor, product and version with their defaults
+func (m *SecurityEventMapping) header() (vendor, product, version string) {
+	vendor, product, version = m.Vendor, m.Product, m.Version
+	if vendor == "" {
+		vendor = DefaultSecurityEventVendor
+	}
+	if product == "" {
+		product = filepath.Base(getProcessName())
+	}
+	if version == "" {
+		version = DefaultSecurityEventVersion
+	}
+	return vendor, product, version
+}
+
+// eventIDField returns the field holding the event ID
+func (m *SecurityEventMapping) eventIDField() string {
+	if m.EventIDField == "" {
+		return DefaultSecurityEventIDField
+	}
+	return m.EventIDField
+}
+
+// defaultEventID returns the event ID of entries without one
+func (m *SecurityEventMapping) defaultEventID() string {
+	if m.DefaultEventID == "" {
+		return DefaultSecurityEventID
+	}
+	return m.DefaultEventID
+}
+
+// securityEvent is a log message reduced to the parts CEF and LEEF carry
+type securityEvent struct {
+	timestamp time.Time
+	level     string
+	message   string
+	fields    map[string]interface{}
+}
+
+// newSecurityEvent extracts the event of a log message
+func newSecurityEvent(msg types.LogMessage) securityEvent {
+	event := securityEvent{
+		timestamp: msg.Timestamp,
+		level:     levelToString(msg.Level),
+	}
+
+	switch {
+	case msg.Raw != nil:
+		event.message = strings.TrimSuffix(string(msg.Raw), "\n")
+	case msg.Entry != nil:
+		if parsed, err := time.Parse(time.RFC3339Nano, msg.Entry.Timestamp); err == nil {
+			event.timestamp = parsed
+		}
+		if msg.Entry.Level != "" {
+			event.level = msg.Entry.Level
+		}
+		event.message = msg.Entry.Message
+		event.fields = msg.Entry.Fields
+		if msg.Entry.StackTrace != "" {
+			event.fields = make(map[string]interface{}, len(msg.Entry.Fields)+1)
+			for key, value := range msg.Entry.Fields {
+				event.fields[key] = value
+			}
+			event.fields["stack_trace"] = msg.Entry.StackTrace
+		}
+	default:
+		event.message = msg.Format
+		if len(msg.Args) > 0 {
+			event.message = fmt.Sprintf(msg.Format, msg.Args...)
+		}
+	}
+
+	if event.timestamp.IsZero() {
+		event.timestamp = time.Now()
+	}
+	return event
+}
+
+// take removes a field from the event and returns its text
+func (e *securityEvent) take(name string) (string, bool) {
+	value, ok := e.fields[name]
+	if !ok || name == "" {
+		return "", false
+	}
+
+	// Copy before deleting so the caller's fields are left untouched
+	fields := make(map[string]interface{}, len(e.fields))
+	for key, v := range e.fields {
+		if key != name {
+			fields[key] = v
+		}
+	}
+	e.fields = fields
+	return LogfmtValue(value), true
+}
+
+// extension returns the event fields as key/value pairs sorted by key, with
+// keys renamed through FieldMap and reduced to letters, digits, '_' and '.'
+func (m *SecurityEventMapping) extension(fields map[string]interface{}) [][2]string {
+	pairs := make([][2]string, 0, len(fields))
+	for name, value := range fields {
+		key, ok := m.FieldMap[name]
+		if !ok {
+			key = securityEventKey(name)
+		}
+		pairs = append(pairs, [2]string{key, LogfmtValue(value)})
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i][0] < pairs[j][0] })
+	return pairs
+}
+
+// securityEventKey replaces characters CEF and LEEF keys cannot carry
+func securityEventKey(name string) string {
+	key := []byte(name)
+	for i, c := range key {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '.') {
+			key[i] = '_'
+		}
+	}
+	if len(key) == 0 {
+		return "_"
+	}
+	return string(key)
+}
+
+// securityEventHeaderEscaper escapes CEF and LEEF header fields. Headers
+// cannot span lines, so line breaks become spaces.
+var securityEventHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r\n", " ", "\n", " ", "\r", " ")
+
+// cefExtensionEscaper escapes CEF extension values
+var cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r\n", `\n`, "\n", `\n`, "\r", `\r`)
+
+// CEFSeverity maps a level name to a CEF severity: trace 0, debug 1, info 3,
+// notice 4, warn 6, error 8 and fatal, critical or panic 10
+func CEFSeverity(level string) int {
+	switch strings.ToLower(level) {
+	case "trace":
+		return 0
+	case "debug":
+		return 1
+	case "notice":
+		return 4
+	case "warn", "warning":
+		return 6
+	case "error":
+		return 8
+	case "fatal", "critical", "panic":
+		return 10
+	default:
+		return 3
+	}
+}
+
+// CEFFormatter formats log messages as ArcSight Common Event Format (CEF:0)
+// events:
+//
+//	CEF:0|Omni|checkout|1.0|auth.failed|login failed|6|rt=1714564800000 src=10.0.0.1
+//
+// The event name is the first line of the message unless NameField is set,
+// and the full message is sent as msg when it differs from the name. The
+// level sets the severity; remaining fields become extension pairs.
+type CEFFormatter struct {
+	SecurityEventMapping
+}
+
+// NewCEFFormatter creates a new CEF formatter
+func NewCEFFormatter() *CEFFormatter {
+	return &CEFFormatter{}
+}
+
+// Format formats a log message as a newline-terminated CEF event
+func (f *CEFFormatter) Format(msg types.LogMessage) ([]byte, error) {
+	event := newSecurityEvent(msg)
+
+	eventID, ok := event.take(f.eventIDField())
+	if !ok {
+		eventID = f.defaultEventID()
+	}
+	name, named := event.take(f.NameField)
+	if !named {
+		name, _, _ = strings.Cut(event.message, "\n")
+	}
+	vendor, product, version := f.header()
+
+	var b strings.Builder
+	b.WriteString("CEF:0")
+	for _, field := range []string{vendor, product, version, eventID, name} {
+		b.WriteByte('|')
+		b.WriteString(securityEventHeaderEscaper.Replace(field))
+	}
+	fmt.Fprintf(&b, "|%d|", CEFSeverity(event.level))
+
+	b.WriteString("rt=")
+	b.WriteString(strconv.FormatInt(event.timestamp.UnixMilli(), 10))
+	if event.message != name {
+		b.WriteString(" msg=")
+		b.WriteString(cefExtensionEscaper.Replace(event.message))
+	}
+	for _, pair := range f.extension(event.fields) {
+		b.WriteByte(' ')
+		b.WriteString(pair[0])
+		b.WriteByte('=')
+		b.WriteString(cefExtensionEscaper.Replace(pair[1]))
+	}
+	b.WriteByte('\n')
+
+	return []byte(b.String()), nil
+}
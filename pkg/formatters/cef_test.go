@@ -0,0 +1,126 @@
+package formatters
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/types"
+)
+
+func TestCEFFormatter_Format(t *testing.T) {
+	timestamp := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		mapping  SecurityEventMapping
+		msg      types.LogMessage
+		expected string
+	}{
+		{
+			name: "formatted message",
+			msg: types.LogMessage{
+				Level:     LevelWarn,
+				Format:    "disk %d%% full",
+				Args:      []interface{}{91},
+				Timestamp: timestamp,
+			},
+			expected: "CEF:0|Omni|app|1.0|log|disk 91% full|6|rt=1714564800000\n",
+		},
+		{
+			name: "mapped fields",
+			mapping: SecurityEventMapping{
+				Vendor:   "Acme",
+				Product:  "checkout",
+				Version:  "2.4",
+				FieldMap: map[string]string{"client_ip": "src", "user": "suser"},
+			},
+			msg: types.LogMessage{
+				Entry: &types.LogEntry{
+					Timestamp: "2024-05-01T12:00:00Z",
+					Level:     "ERROR",
+					Message:   "login failed",
+					Fields: map[string]interface{}{
+						"event_id":  "auth.failed",
+						"client_ip": "10.0.0.1",
+						"user":      "ada",
+						"attempts":  3,
+						"reason":    errors.New("bad password"),
+					},
+				},
+			},
+			expected: "CEF:0|Acme|checkout|2.4|auth.failed|login failed|8|rt=1714564800000 attempts=3 reason=bad password src=10.0.0.1 suser=ada\n",
+		},
+		{
+			name: "escaping",
+			mapping: SecurityEventMapping{
+				Product:        "a|b",
+				EventIDField:   "sig",
+				DefaultEventID: `c\d`,
+				NameField:      "title",
+			},
+			msg: types.LogMessage{
+				Entry: &types.LogEntry{
+					Timestamp:  "2024-05-01T12:00:00Z",
+					Level:      "fatal",
+					Message:    "query a=b failed\nretrying",
+					Fields:     map[string]interface{}{"title": "db|down", "path": `C:\tmp`, "bad key": "x"},
+					StackTrace: "main.main()",
+				},
+			},
+			expected: `CEF:0|Omni|a\|b|1.0|c\\d|db\|down|10|rt=1714564800000 msg=query a\=b failed\nretrying bad_key=x path=C:\\tmp stack_trace=main.main()` + "\n",
+		},
+		{
+			name: "multi-line message",
+			msg: types.LogMessage{
+				Entry: &types.LogEntry{Timestamp: "2024-05-01T12:00:00Z", Level: "debug", Message: "first\nsecond"},
+			},
+			expected: `CEF:0|Omni|app|1.0|log|first|1|rt=1714564800000 msg=first\nsecond` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.mapping.Product == "" {
+				tt.mapping.Product = "app"
+			}
+			f := NewCEFFormatter()
+			f.SecurityEventMapping = tt.mapping
+			data, err := f.Format(tt.msg)
+			if err != nil {
+				t.Fatalf("Format failed: %v", err)
+			}
+			if string(data) != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, string(data))
+			}
+		})
+	}
+}
+
+func TestCEFFormatter_FieldsUnchanged(t *testing.T) {
+	fields := map[string]interface{}{"event_id": "x", "user": "ada"}
+	if _, err := NewCEFFormatter().Format(types.LogMessage{Entry: &types.LogEntry{Message: "m", Fields: fields}}); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+	if len(fields) != 2 {
+		t.Errorf("Expected the entry fields to be left unchanged, got %v", fields)
+	}
+}
+
+func TestCEFSeverity(t *testing.T) {
+	tests := map[string]int{
+		"TRACE":   0,
+		"debug":   1,
+		"INFO":    3,
+		"notice":  4,
+		"warning": 6,
+		"ERROR":   8,
+		"fatal":   10,
+		"other":   3,
+	}
+	for level, expected := range tests {
+		if got := CEFSeverity(level); got != expected {
+			t.Errorf("CEFSeverity(%q) = %d, want %d", level, got, expected)
+		}
+	}
+}
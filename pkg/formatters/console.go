@@ -0,0 +1,298 @@
+package formatters
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/types"
+)
+
+// ColorMode selects when ConsoleFormatter colours its output
+type ColorMode int
+
+const (
+	// ColorAuto colours output when Terminal is a terminal and NO_COLOR is
+	// not set
+	ColorAuto ColorMode = iota
+	// ColorAlways always colours output
+	ColorAlways
+	// ColorNever never colours output
+	ColorNever
+)
+
+// ANSI colours for ConsoleFormatter levels and highlighted fields
+const (
+	ColorReset   = "\x1b[0m"
+	ColorBold    = "\x1b[1m"
+	ColorDim     = "\x1b[2m"
+	ColorRed     = "\x1b[31m"
+	ColorGreen   = "\x1b[32m"
+	ColorYellow  = "\x1b[33m"
+	ColorBlue    = "\x1b[34m"
+	ColorMagenta = "\x1b[35m"
+	ColorCyan    = "\x1b[36m"
+	ColorGray    = "\x1b[90m"
+)
+
+// DefaultConsoleMessageWidth is the column inline fields start at
+const DefaultConsoleMessageWidth = 40
+
+// DefaultConsoleFileTimeFormat is the timestamp layout of console output not
+// written to a terminal when TimeFormat is empty
+const DefaultConsoleFileTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// consoleIndent indents stack traces and multi-line fields
+const consoleIndent = "    "
+
+// consoleLevelColors holds the colour of each level name
+var consoleLevelColors = map[string]string{
+	"TRACE": ColorGray,
+	"DEBUG": ColorBlue,
+	"INFO":  ColorGreen,
+	"WARN":  ColorYellow,
+	"ERROR": ColorRed,
+	"FATAL": ColorBold + ColorRed,
+}
+
+// ConsoleFormatter formats log messages for reading in a terminal during
+// development:
+//
+//	0.512s INFO  server started                           env=dev port=8080
+//	1.204s ERROR request failed                           path=/users
+//	    main.handler()
+//	        /app/main.go:42
+//
+// Timestamps are relative to Start unless TimeFormat is set, levels are
+// coloured and padded, and fields are aligned after the message. Nested
+// fields, multi-line values and stack traces are written indented on the
+// following lines. The logger formats each destination with ForFile, so
+// only terminals get colours and relative timestamps.
+type ConsoleFormatter struct {
+	Options      FormatOptions     // IncludeTime, IncludeLevel and the TimeZone of TimeFormat
+	Color        ColorMode         // When to colour output
+	Terminal     *os.File          // File ColorAuto checks for a terminal; os.Stderr when nil
+	TimeFormat   string            // Timestamp layout, such as "15:04:05.000"; time since Start when empty
+	Start        time.Time         // Origin of relative timestamps
+	MessageWidth int               // Column fields are aligned to; DefaultConsoleMessageWidth when zero, no padding when negative
+	Highlight    map[string]string // Colour of the values of selected fields, such as "user_id": ColorCyan
+
+	colorOnce sync.Once
+	color     bool
+}
+
+// NewConsoleFormatter creates a new console formatter with timestamps
+// relative to now
+func NewConsoleFormatter() *ConsoleFormatter {
+	return &ConsoleFormatter{
+		Options: DefaultFormatOptions(),
+		Start:   time.Now(),
+	}
+}
+
+// ForFile returns a formatter with the settings of f for output written to
+// file, or to a destination without a file when file is nil. Output that
+// does not go to a terminal is not coloured under ColorAuto and gets
+// absolute timestamps, in DefaultConsoleFileTimeFormat when TimeFormat is
+// empty, as times relative to start-up mean nothing once the process exits.
+func (f *ConsoleFormatter) ForFile(file *os.File) *ConsoleFormatter {
+	formatter := &ConsoleFormatter{
+		Options:      f.Options,
+		Color:        f.Color,
+		Terminal:     file,
+		TimeFormat:   f.TimeFormat,
+		Start:        f.Start,
+		MessageWidth: f.MessageWidth,
+		Highlight:    f.Highlight,
+	}
+	if !isTerminal(file) {
+		if formatter.Color == ColorAuto {
+			formatter.Color = ColorNever
+		}
+		if formatter.TimeFormat == "" {
+			formatter.TimeFormat = DefaultConsoleFileTimeFormat
+		}
+	}
+	return formatter
+}
+
+// isTerminal reports whether file is a terminal
+func isTerminal(file *os.File) bool {
+	if file == nil {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// colored reports whether output is coloured, checking the terminal once
+func (f *ConsoleFormatter) colored() bool {
+	switch f.Color {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+
+	f.colorOnce.Do(func() {
+		if os.Getenv("NO_COLOR") != "" {
+			return
+		}
+		terminal := f.Terminal
+		if terminal == nil {
+			terminal = os.Stderr
+		}
+		f.color = isTerminal(terminal)
+	})
+	return f.color
+}
+
+// Format formats a log message as one or more lines of console output
+func (f *ConsoleFormatter) Format(msg types.LogMessage) ([]byte, error) {
+	// Handle raw bytes - pass through as-is
+	if msg.Raw != nil {
+		return msg.Raw, nil
+	}
+
+	color := f.colored()
+	paint := func(code, s string) string {
+		if !color || code == "" {
+			return s
+		}
+		return code + s + ColorReset
+	}
+
+	timestamp := msg.Timestamp
+	level := levelToString(msg.Level)
+	var message, stackTrace string
+	var fields map[string]interface{}
+	if msg.Entry != nil {
+		if parsed, err := time.Parse(time.RFC3339Nano, msg.Entry.Timestamp); err == nil && timestamp.IsZero() {
+			timestamp = parsed
+		}
+		if msg.Entry.Level != "" {
+			level = strings.ToUpper(msg.Entry.Level)
+		}
+		message = msg.Entry.Message
+		fields = msg.Entry.Fields
+		stackTrace = msg.Entry.StackTrace
+	} else {
+		message = msg.Format
+		if len(msg.Args) > 0 {
+			message = fmt.Sprintf(msg.Format, msg.Args...)
+		}
+	}
+	message = strings.TrimSuffix(message, "\n")
+
+	var b strings.Builder
+	if f.Options.IncludeTime {
+		b.WriteString(paint(ColorDim, f.formatTimestamp(timestamp)))
+		b.WriteByte(' ')
+	}
+	if f.Options.IncludeLevel {
+		b.WriteString(paint(consoleLevelColors[level], fmt.Sprintf("%-5s", level)))
+		b.WriteByte(' ')
+	}
+	b.WriteString(message)
+
+	// Scalar fields follow the message on its line; nested and multi-line
+	// values are written below it
+	var blocks []string
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	width := f.MessageWidth
+	if width == 0 {
+		width = DefaultConsoleMessageWidth
+	}
+	padded := false
+	for _, key := range keys {
+		value, block := consoleValue(fields[key])
+		if block {
+			blocks = append(blocks, paint(ColorDim, key+"=")+paint(f.Highlight[key], value))
+			continue
+		}
+		if !padded {
+			if pad := width - len(message); pad > 0 && !strings.Contains(message, "\n") {
+				b.WriteString(strings.Repeat(" ", pad))
+			}
+			padded = true
+		}
+		b.WriteByte(' ')
+		b.WriteString(paint(ColorDim, key+"="))
+		b.WriteString(paint(f.Highlight[key], value))
+	}
+	b.WriteByte('\n')
+
+	for _, block := range blocks {
+		writeConsoleBlock(&b, block)
+	}
+	if stackTrace != "" {
+		writeConsoleBlock(&b, paint(ColorGray, strings.TrimRight(stackTrace, "\n")))
+	}
+
+	return []byte(b.String()), nil
+}
+
+// formatTimestamp formats a timestamp as time since Start, or with TimeFormat
+func (f *ConsoleFormatter) formatTimestamp(t time.Time) string {
+	if f.TimeFormat != "" {
+		if f.Options.TimeZone != nil {
+			t = t.In(f.Options.TimeZone)
+		}
+		return t.Format(f.TimeFormat)
+	}
+	return fmt.Sprintf("%8.3fs", t.Sub(f.Start).Seconds())
+}
+
+// writeConsoleBlock writes each line of a block indented
+func writeConsoleBlock(b *strings.Builder, block string) {
+	for _, line := range strings.Split(block, "\n") {
+		b.WriteString(consoleIndent)
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+}
+
+// consoleValue renders a field value for the console. Block values are
+// nested maps, slices and structs, pretty-printed as indented JSON, and
+// values spanning several lines, such as errors with stack traces.
+func consoleValue(value interface{}) (text string, block bool) {
+	switch v := value.(type) {
+	case nil:
+		return "null", false
+	case string:
+		if strings.Contains(v, "\n") {
+			return v, true
+		}
+		if logfmtNeedsQuote(v) {
+			return strconv.Quote(v), false
+		}
+		return v, false
+	case error:
+		// %+v includes the stack trace of errors that carry one
+		return consoleValue(fmt.Sprintf("%+v", v))
+	case fmt.Stringer:
+		return consoleValue(LogfmtValue(v))
+	}
+
+	switch reflect.Indirect(reflect.ValueOf(value)).Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+		data, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return fmt.Sprint(value), false
+		}
+		return string(data), true
+	default:
+		return LogfmtValue(value), false
+	}
+}
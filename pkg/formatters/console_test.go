@@ -0,0 +1,176 @@
+package formatters
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/types"
+)
+
+func TestConsoleFormatter_Format(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		msg      types.LogMessage
+		expected string
+	}{
+		{
+			name: "formatted message",
+			msg: types.LogMessage{
+				Level:     LevelInfo,
+				Format:    "listening on %d",
+				Args:      []interface{}{8080},
+				Timestamp: start.Add(512 * time.Millisecond),
+			},
+			expected: "   0.512s INFO  listening on 8080\n",
+		},
+		{
+			name: "aligned fields",
+			msg: types.LogMessage{
+				Timestamp: start.Add(1500 * time.Millisecond),
+				Entry: &types.LogEntry{
+					Level:   "warn",
+					Message: "slow query",
+					Fields:  map[string]interface{}{"table": "users", "query": "select 1", "took": 2 * time.Second},
+				},
+			},
+			expected: "   1.500s WARN  slow query                               query=\"select 1\" table=users took=2s\n",
+		},
+		{
+			name: "nested fields and stack trace",
+			msg: types.LogMessage{
+				Timestamp: start.Add(61 * time.Second),
+				Entry: &types.LogEntry{
+					Level:      "ERROR",
+					Message:    "request failed",
+					Fields:     map[string]interface{}{"user": map[string]interface{}{"id": 42}, "status": 500, "error": errors.New("timeout")},
+					StackTrace: "main.handler()\n\t/app/main.go:42\n",
+				},
+			},
+			expected: "  61.000s ERROR request failed                           error=timeout status=500\n" +
+				"    user={\n" +
+				"      \"id\": 42\n" +
+				"    }\n" +
+				"    main.handler()\n" +
+				"    \t/app/main.go:42\n",
+		},
+		{
+			name: "multi-line value",
+			msg: types.LogMessage{
+				Timestamp: start,
+				Entry: &types.LogEntry{
+					Level:   "debug",
+					Message: "config",
+					Fields:  map[string]interface{}{"body": "a\nb"},
+				},
+			},
+			expected: "   0.000s DEBUG config\n    body=a\n    b\n",
+		},
+		{
+			name:     "raw passthrough",
+			msg:      types.LogMessage{Raw: []byte("raw\n")},
+			expected: "raw\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewConsoleFormatter()
+			f.Start = start
+			f.Color = ColorNever
+			data, err := f.Format(tt.msg)
+			if err != nil {
+				t.Fatalf("Format failed: %v", err)
+			}
+			if string(data) != tt.expected {
+				t.Errorf("Expected:\n%q\ngot:\n%q", tt.expected, string(data))
+			}
+		})
+	}
+}
+
+func TestConsoleFormatter_Color(t *testing.T) {
+	f := NewConsoleFormatter()
+	f.Color = ColorAlways
+	f.TimeFormat = "15:04:05"
+	f.MessageWidth = -1
+	f.Highlight = map[string]string{"user_id": ColorCyan}
+
+	data, err := f.Format(types.LogMessage{
+		Timestamp: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Entry:     &types.LogEntry{Level: "error", Message: "denied", Fields: map[string]interface{}{"user_id": 7}},
+	})
+	if err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+	expected := ColorDim + "12:00:00" + ColorReset + " " + ColorRed + "ERROR" + ColorReset + " denied " +
+		ColorDim + "user_id=" + ColorReset + ColorCyan + "7" + ColorReset + "\n"
+	if string(data) != expected {
+		t.Errorf("Expected %q, got %q", expected, string(data))
+	}
+}
+
+func TestConsoleFormatter_ColorAuto(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "out.log"))
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	defer file.Close()
+
+	// A regular file is not a terminal
+	f := NewConsoleFormatter()
+	f.Terminal = file
+	data, err := f.Format(types.LogMessage{Level: LevelError, Format: "m"})
+	if err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+	if strings.Contains(string(data), "\x1b[") {
+		t.Errorf("Expected no colour for a regular file, got %q", data)
+	}
+
+	// NO_COLOR disables colour whatever the output
+	t.Setenv("NO_COLOR", "1")
+	f = NewConsoleFormatter()
+	if f.colored() {
+		t.Error("Expected NO_COLOR to disable colour")
+	}
+}
+
+func TestConsoleFormatter_ForFile(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "out.log"))
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	defer file.Close()
+
+	timestamp := time.Date(2024, 5, 1, 12, 0, 0, 500e6, time.UTC)
+	msg := types.LogMessage{Level: LevelInfo, Format: "started", Timestamp: timestamp}
+
+	// Files and destinations without one get absolute, uncoloured timestamps
+	for _, out := range []*os.File{file, nil} {
+		data, err := NewConsoleFormatter().ForFile(out).Format(msg)
+		if err != nil {
+			t.Fatalf("Format failed: %v", err)
+		}
+		if expected := "2024-05-01T12:00:00.500Z INFO  started\n"; string(data) != expected {
+			t.Errorf("Expected %q, got %q", expected, data)
+		}
+	}
+
+	// Explicit colours and time formats are kept
+	f := NewConsoleFormatter()
+	f.Color = ColorAlways
+	f.TimeFormat = "15:04:05"
+	data, err := f.ForFile(file).Format(msg)
+	if err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+	if expected := ColorDim + "12:00:00" + ColorReset + " " + ColorGreen + "INFO " + ColorReset + " started\n"; string(data) != expected {
+		t.Errorf("Expected %q, got %q", expected, data)
+	}
+}
@@ -0,0 +1,213 @@
+package formatters
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/types"
+)
+
+// Columns of CSV and TSV output that are not entry fields
+const (
+	CSVTimestampColumn = "timestamp"
+	CSVLevelColumn     = "level"
+	CSVMessageColumn   = "message"
+	CSVStackColumn     = "stack_trace"
+)
+
+// CSVExtraColumn holds the fields outside the column list as a JSON object
+const CSVExtraColumn = "fields"
+
+// DefaultCSVColumns returns the columns of CSV and TSV output without a
+// configured list
+func DefaultCSVColumns() []string {
+	return []string{CSVTimestampColumn, CSVLevelColumn, CSVMessageColumn}
+}
+
+// CSVExtra selects what CSV and TSV output does with the fields outside the
+// column list
+type CSVExtra int
+
+const (
+	// CSVExtraJSON writes them as a JSON object in a last CSVExtraColumn
+	CSVExtraJSON CSVExtra = iota
+	// CSVExtraDrop leaves them out
+	CSVExtraDrop
+)
+
+// CSVFormatter formats log messages as CSV or TSV rows. Columns come from
+// FormatOptions.CSVColumns: "timestamp", "level", "message", "stack_trace"
+// and field names. Values are quoted as RFC 4180 requires, and values that
+// are not strings or numbers are written as JSON.
+//
+// StartStream returns the header row, which file destinations write at the
+// top of every new file, including after rotation.
+type CSVFormatter struct {
+	Options   FormatOptions
+	Delimiter rune // Separates values; ',' for CSV, '\t' for TSV
+	CRLF      bool // End rows with \r\n rather than \n
+}
+
+// NewCSVFormatter creates a new comma-separated formatter
+func NewCSVFormatter() *CSVFormatter {
+	return &CSVFormatter{
+		Options:   DefaultFormatOptions(),
+		Delimiter: ',',
+	}
+}
+
+// NewTSVFormatter creates a new tab-separated formatter
+func NewTSVFormatter() *CSVFormatter {
+	f := NewCSVFormatter()
+	f.Delimiter = '\t'
+	return f
+}
+
+// Columns returns the header of each row, including CSVExtraColumn unless
+// extra fields are dropped
+func (f *CSVFormatter) Columns() []string {
+	columns := f.columns()
+	if f.Options.CSVExtra == CSVExtraJSON {
+		columns = append(columns[:len(columns):len(columns)], CSVExtraColumn)
+	}
+	return columns
+}
+
+// Format formats a log message as one row
+func (f *CSVFormatter) Format(msg types.LogMessage) ([]byte, error) {
+	// Handle raw bytes - pass through as-is
+	if msg.Raw != nil {
+		return msg.Raw, nil
+	}
+
+	var timestamp, level, message, stack string
+	var fields map[string]interface{}
+	if msg.Entry != nil {
+		timestamp = msg.Entry.Timestamp
+		level = strings.ToLower(msg.Entry.Level)
+		message = msg.Entry.Message
+		stack = msg.Entry.StackTrace
+		fields = msg.Entry.Fields
+	} else {
+		timestamp = msg.Timestamp.In(f.Options.TimeZone).Format(f.Options.TimestampFormat)
+		level = strings.ToLower(levelToString(msg.Level))
+		message = msg.Format
+		if len(msg.Args) > 0 {
+			message = fmt.Sprintf(msg.Format, msg.Args...)
+		}
+	}
+
+	columns := f.columns()
+	record := make([]string, 0, len(columns)+1)
+	written := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		switch column {
+		case CSVTimestampColumn:
+			record = append(record, timestamp)
+		case CSVLevelColumn:
+			record = append(record, level)
+		case CSVMessageColumn:
+			record = append(record, message)
+		case CSVStackColumn:
+			record = append(record, stack)
+		default:
+			record = append(record, csvValue(fields[column]))
+			written[column] = true
+		}
+	}
+
+	if f.Options.CSVExtra == CSVExtraJSON {
+		extra := make(map[string]interface{}, len(fields))
+		for k, v := range fields {
+			if written[k] {
+				continue
+			}
+			if err, ok := v.(error); ok {
+				// Errors marshal as empty objects
+				v = err.Error()
+			}
+			extra[k] = v
+		}
+		value := ""
+		if len(extra) > 0 {
+			data, err := json.Marshal(safeFields(extra))
+			if err != nil {
+				return nil, err
+			}
+			value = string(data)
+		}
+		record = append(record, value)
+	}
+
+	return f.writeRecord(record)
+}
+
+// StartStream returns the header row
+func (f *CSVFormatter) StartStream() ([]byte, error) {
+	return f.writeRecord(f.Columns())
+}
+
+// EndStream returns nothing, as CSV files have no trailer
+func (f *CSVFormatter) EndStream() ([]byte, error) {
+	return nil, nil
+}
+
+// columns returns the configured columns, or the defaults without any
+func (f *CSVFormatter) columns() []string {
+	if len(f.Options.CSVColumns) == 0 {
+		return DefaultCSVColumns()
+	}
+	return f.Options.CSVColumns
+}
+
+// writeRecord encodes one row with the delimiter and line ending
+func (f *CSVFormatter) writeRecord(record []string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = f.Delimiter
+	if w.Comma == 0 {
+		w.Comma = ','
+	}
+	w.UseCRLF = f.CRLF
+	if err := w.Write(record); err != nil {
+		return nil, err
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// csvValue renders a field value: strings as they are, numbers and booleans
+// in Go syntax, times in RFC 3339 and other values as JSON
+func csvValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case time.Duration:
+		return v.String()
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		data, err := json.Marshal(safeFieldsCopy(v, make(map[uintptr]bool), 0))
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+}
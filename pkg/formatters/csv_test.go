@@ -0,0 +1,136 @@
+package formatters
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/types"
+)
+
+func TestCSVFormatter_Format(t *testing.T) {
+	timestamp := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	entry := &types.LogEntry{
+		Timestamp: "2024-05-01T12:00:00Z",
+		Level:     "INFO",
+		Message:   `said "hi", then left`,
+		Fields: map[string]interface{}{
+			"user":    "ada",
+			"status":  200,
+			"tags":    []string{"a", "b"},
+			"err":     errors.New("timed out"),
+			"ignored": nil,
+		},
+	}
+
+	tests := []struct {
+		name     string
+		tsv      bool
+		columns  []string
+		extra    CSVExtra
+		msg      types.LogMessage
+		expected string
+	}{
+		{
+			name: "formatted message",
+			msg: types.LogMessage{
+				Level:     LevelWarn,
+				Format:    "disk %d%% full",
+				Args:      []interface{}{91},
+				Timestamp: timestamp,
+			},
+			expected: "2024-05-01T12:00:00Z,warn,disk 91% full,\n",
+		},
+		{
+			name:     "quoting and extra fields",
+			columns:  []string{"timestamp", "level", "user", "message"},
+			msg:      types.LogMessage{Entry: entry},
+			expected: `2024-05-01T12:00:00Z,info,ada,"said ""hi"", then left","{""err"":""timed out"",""ignored"":null,""status"":200,""tags"":[""a"",""b""]}"` + "\n",
+		},
+		{
+			name:     "dropped extra fields",
+			columns:  []string{"status", "tags", "err", "missing", "message"},
+			extra:    CSVExtraDrop,
+			msg:      types.LogMessage{Entry: entry},
+			expected: `200,"[""a"",""b""]",timed out,,"said ""hi"", then left"` + "\n",
+		},
+		{
+			name:     "tab separated",
+			tsv:      true,
+			columns:  []string{"level", "user", "message"},
+			extra:    CSVExtraDrop,
+			msg:      types.LogMessage{Entry: &types.LogEntry{Level: "ERROR", Message: "a\tb", Fields: map[string]interface{}{"user": "ada"}}},
+			expected: "error\tada\t\"a\tb\"\n",
+		},
+		{
+			name:     "multiline stack trace",
+			columns:  []string{"message", "stack_trace"},
+			extra:    CSVExtraDrop,
+			msg:      types.LogMessage{Entry: &types.LogEntry{Message: "panic", StackTrace: "main.go:1\nmain.go:2"}},
+			expected: "panic,\"main.go:1\nmain.go:2\"\n",
+		},
+		{
+			name:     "raw passthrough",
+			msg:      types.LogMessage{Raw: []byte("raw line\n")},
+			expected: "raw line\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewCSVFormatter()
+			if tt.tsv {
+				f = NewTSVFormatter()
+			}
+			f.Options.CSVColumns = tt.columns
+			f.Options.CSVExtra = tt.extra
+			data, err := f.Format(tt.msg)
+			if err != nil {
+				t.Fatalf("Format failed: %v", err)
+			}
+			if string(data) != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, string(data))
+			}
+		})
+	}
+}
+
+func TestCSVFormatter_Header(t *testing.T) {
+	f := NewCSVFormatter()
+	header, err := f.StartStream()
+	if err != nil {
+		t.Fatalf("StartStream failed: %v", err)
+	}
+	if string(header) != "timestamp,level,message,fields\n" {
+		t.Errorf("Unexpected default header %q", header)
+	}
+
+	f = NewTSVFormatter()
+	f.Options.CSVColumns = []string{"level", "request id"}
+	f.Options.CSVExtra = CSVExtraDrop
+	f.CRLF = true
+	header, _ = f.StartStream()
+	if string(header) != "level\trequest id\r\n" {
+		t.Errorf("Unexpected TSV header %q", header)
+	}
+	if end, err := f.EndStream(); err != nil || end != nil {
+		t.Errorf("Expected no stream end, got %q, %v", end, err)
+	}
+}
+
+func TestCSVFormatter_Factory(t *testing.T) {
+	for name, delimiter := range map[string]rune{"csv": ',', "tsv": '\t'} {
+		formatter, err := CreateFormatter(name)
+		if err != nil {
+			t.Fatalf("CreateFormatter(%q) failed: %v", name, err)
+		}
+		csv, ok := formatter.(*CSVFormatter)
+		if !ok || csv.Delimiter != delimiter {
+			t.Errorf("Expected a %q-delimited *CSVFormatter for %s, got %#v", delimiter, name, formatter)
+		}
+	}
+
+	if formatter, err := CreateFormatterByType(FormatTSV); err != nil || formatter == nil {
+		t.Errorf("CreateFormatterByType failed: %v", err)
+	}
+}
@@ -31,6 +31,46 @@ func NewFactory() *Factory {
 		return NewJSONFormatter(), nil
 	})
 
+	_ = f.Register("gelf", func() (types.Formatter, error) {
+		return NewGELFFormatter(), nil
+	})
+
+	_ = f.Register("logfmt", func() (types.Formatter, error) {
+		return NewLogfmtFormatter(), nil
+	})
+
+	_ = f.Register("cef", func() (types.Formatter, error) {
+		return NewCEFFormatter(), nil
+	})
+
+	_ = f.Register("leef", func() (types.Formatter, error) {
+		return NewLEEFFormatter(), nil
+	})
+
+	_ = f.Register("console", func() (types.Formatter, error) {
+		return NewConsoleFormatter(), nil
+	})
+
+	_ = f.Register("pattern", func() (types.Formatter, error) {
+		return NewPatternFormatter("")
+	})
+
+	_ = f.Register("msgpack", func() (types.Formatter, error) {
+		return NewMsgpackFormatter(), nil
+	})
+
+	_ = f.Register("cbor", func() (types.Formatter, error) {
+		return NewCBORFormatter(), nil
+	})
+
+	_ = f.Register("csv", func() (types.Formatter, error) {
+		return NewCSVFormatter(), nil
+	})
+
+	_ = f.Register("tsv", func() (types.Formatter, error) {
+		return NewTSVFormatter(), nil
+	})
+
 	return f
 }
 
@@ -66,9 +106,19 @@ func (f *Factory) CreateFormatter(name string) (types.Formatter, error) {
 
 // Format type constants
 const (
-	FormatText   = 0
-	FormatJSON   = 1
-	FormatCustom = 2
+	FormatText    = 0
+	FormatJSON    = 1
+	FormatCustom  = 2
+	FormatGELF    = 3
+	FormatLogfmt  = 4
+	FormatCEF     = 5
+	FormatLEEF    = 6
+	FormatConsole = 7
+	FormatPattern = 8
+	FormatMsgpack = 9
+	FormatCBOR    = 10
+	FormatCSV     = 11
+	FormatTSV     = 12
 )
 
 // CreateFormatterByType creates a formatter by type constant
@@ -82,6 +132,26 @@ func (f *Factory) CreateFormatterByType(formatType int) (types.Formatter, error)
 		// For custom format, we need a name from somewhere
 		// This would typically come from configuration
 		return nil, fmt.Errorf("custom formatter requires explicit name")
+	case FormatGELF:
+		return f.CreateFormatter("gelf")
+	case FormatLogfmt:
+		return f.CreateFormatter("logfmt")
+	case FormatCEF:
+		return f.CreateFormatter("cef")
+	case FormatLEEF:
+		return f.CreateFormatter("leef")
+	case FormatConsole:
+		return f.CreateFormatter("console")
+	case FormatPattern:
+		return f.CreateFormatter("pattern")
+	case FormatMsgpack:
+		return f.CreateFormatter("msgpack")
+	case FormatCBOR:
+		return f.CreateFormatter("cbor")
+	case FormatCSV:
+		return f.CreateFormatter("csv")
+	case FormatTSV:
+		return f.CreateFormatter("tsv")
 	default:
 		return nil, fmt.Errorf("unknown format type: %d", formatType)
 	}
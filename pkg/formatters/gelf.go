@@ -0,0 +1,208 @@
+package formatters
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/types"
+)
+
+// GELFVersion is the GELF specification version produced
+const GELFVersion = "1.1"
+
+// Syslog severities used as GELF levels
+const (
+	gelfLevelCritical = 2
+	gelfLevelError    = 3
+	gelfLevelWarning  = 4
+	gelfLevelNotice   = 5
+	gelfLevelInfo     = 6
+	gelfLevelDebug    = 7
+)
+
+// GELFFormatter formats log messages as GELF 1.1 payloads for Graylog. The
+// first line of the message becomes short_message and a multi-line message or
+// stack trace is sent as full_message. Fields are sent as additional fields,
+// prefixed with an underscore.
+type GELFFormatter struct {
+	Host string // Source host; defaults to the hostname
+}
+
+// NewGELFFormatter creates a new GELF formatter
+func NewGELFFormatter() *GELFFormatter {
+	return &GELFFormatter{
+		Host: getHostname(),
+	}
+}
+
+// Format formats a log message as a newline-terminated GELF JSON object
+func (f *GELFFormatter) Format(msg types.LogMessage) ([]byte, error) {
+	var gelf GELFMessage
+
+	switch {
+	case msg.Raw != nil:
+		gelf = NewGELFMessage(f.Host, msg.Timestamp, levelToString(msg.Level),
+			strings.TrimSuffix(string(msg.Raw), "\n"), nil)
+	case msg.Entry != nil:
+		timestamp := msg.Timestamp
+		if parsed, err := time.Parse(time.RFC3339Nano, msg.Entry.Timestamp); err == nil {
+			timestamp = parsed
+		}
+		gelf = NewGELFMessage(f.Host, timestamp, msg.Entry.Level, msg.Entry.Message, msg.Entry.Fields)
+		if msg.Entry.StackTrace != "" {
+			gelf.FullMessage = msg.Entry.Message + "\n" + msg.Entry.StackTrace
+		}
+		if msg.Entry.File != "" {
+			gelf.File = msg.Entry.File
+			gelf.Line = msg.Entry.Line
+		}
+	default:
+		message := msg.Format
+		if len(msg.Args) > 0 {
+			message = fmt.Sprintf(msg.Format, msg.Args...)
+		}
+		gelf = NewGELFMessage(f.Host, msg.Timestamp, levelToString(msg.Level), message, nil)
+	}
+
+	data, err := json.Marshal(gelf)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(data, '\n'), nil
+}
+
+// GELFMessage is a GELF 1.1 message. It is shared by the GELF formatter and
+// the GELF network destination.
+type GELFMessage struct {
+	Host         string
+	ShortMessage string
+	FullMessage  string // Omitted when empty
+	Timestamp    time.Time
+	Level        int                    // Syslog severity
+	File         string                 // Sent as _file when set
+	Line         int                    // Sent as _line when File is set
+	Fields       map[string]interface{} // Additional fields, named without the underscore
+}
+
+// NewGELFMessage creates a GELF message for a log entry. level is a level
+// name such as "info" or "ERROR"; a multi-line message is split into its
+// first line and the full text.
+func NewGELFMessage(host string, timestamp time.Time, level, message string, fields map[string]interface{}) GELFMessage {
+	gelf := GELFMessage{
+		Host:         host,
+		ShortMessage: message,
+		Timestamp:    timestamp,
+		Level:        GELFLevel(level),
+		Fields:       fields,
+	}
+
+	if first, _, multiline := strings.Cut(message, "\n"); multiline {
+		gelf.ShortMessage = first
+		gelf.FullMessage = message
+	}
+	if strings.TrimSpace(gelf.ShortMessage) == "" {
+		// Graylog rejects messages without a short message
+		gelf.ShortMessage = "-"
+	}
+
+	return gelf
+}
+
+// GELFLevel maps a level name to its syslog severity. Unknown names map to
+// informational.
+func GELFLevel(level string) int {
+	switch strings.ToLower(level) {
+	case "trace", "debug":
+		return gelfLevelDebug
+	case "notice":
+		return gelfLevelNotice
+	case "warn", "warning":
+		return gelfLevelWarning
+	case "error":
+		return gelfLevelError
+	case "fatal", "critical", "panic":
+		return gelfLevelCritical
+	default:
+		return gelfLevelInfo
+	}
+}
+
+// MarshalJSON encodes the message as a GELF 1.1 JSON object
+func (m GELFMessage) MarshalJSON() ([]byte, error) {
+	object := make(map[string]interface{}, len(m.Fields)+8)
+	for key, value := range m.Fields {
+		if value == nil {
+			continue
+		}
+		object["_"+GELFFieldName(key)] = gelfFieldValue(value)
+	}
+	if m.File != "" {
+		object["_file"] = m.File
+		object["_line"] = m.Line
+	}
+
+	object["version"] = GELFVersion
+	object["host"] = m.Host
+	object["short_message"] = m.ShortMessage
+	if m.FullMessage != "" {
+		object["full_message"] = m.FullMessage
+	}
+	if !m.Timestamp.IsZero() {
+		// Seconds since the epoch with millisecond precision
+		millis := m.Timestamp.UnixMilli()
+		object["timestamp"] = json.Number(fmt.Sprintf("%d.%03d", millis/1000, millis%1000))
+	}
+	object["level"] = m.Level
+
+	return json.Marshal(object)
+}
+
+// GELFFieldName returns key as a valid additional field name: characters
+// other than letters, digits, underscores, dashes and dots are replaced with
+// underscores, and the reserved name "id" becomes "id_"
+func GELFFieldName(key string) string {
+	if key == "id" {
+		return "id_"
+	}
+
+	name := []byte(key)
+	for i, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_', c == '-', c == '.':
+		default:
+			name[i] = '_'
+		}
+	}
+	return string(name)
+}
+
+// gelfFieldValue converts a field value to a string or number, the only
+// additional field types GELF allows
+func gelfFieldValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return v
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, json.Number:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case time.Duration:
+		return v.String()
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		data, err := json.Marshal(safeFieldsCopy(v, make(map[uintptr]bool), 0))
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(data)
+	}
+}
@@ -0,0 +1,170 @@
+package formatters
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/types"
+)
+
+func TestGELFFormatter_Format(t *testing.T) {
+	f := NewGELFFormatter()
+	f.Host = "web-1"
+	timestamp := time.Date(2024, 5, 1, 12, 0, 0, 250e6, time.UTC)
+
+	tests := []struct {
+		name     string
+		msg      types.LogMessage
+		expected map[string]interface{}
+		absent   []string
+	}{
+		{
+			name: "formatted message",
+			msg: types.LogMessage{
+				Level:     LevelWarn,
+				Format:    "disk %d%% full",
+				Args:      []interface{}{91},
+				Timestamp: timestamp,
+			},
+			expected: map[string]interface{}{
+				"version":       "1.1",
+				"host":          "web-1",
+				"short_message": "disk 91% full",
+				"timestamp":     1714564800.25,
+				"level":         float64(4),
+			},
+			absent: []string{"full_message"},
+		},
+		{
+			name: "structured entry",
+			msg: types.LogMessage{
+				Level:     LevelError,
+				Timestamp: timestamp,
+				Entry: &types.LogEntry{
+					Timestamp:  "2024-05-01T12:00:01.5Z",
+					Level:      "ERROR",
+					Message:    "payment failed",
+					StackTrace: "main.charge()\n\tmain.go:42",
+					File:       "main.go",
+					Line:       42,
+					Fields: map[string]interface{}{
+						"order":      "A-17",
+						"retry":      true,
+						"error":      errors.New("card declined"),
+						"id":         7,
+						"user name":  "ada",
+						"attributes": map[string]interface{}{"tier": "gold"},
+						"ignored":    nil,
+					},
+				},
+			},
+			expected: map[string]interface{}{
+				"short_message": "payment failed",
+				"full_message":  "payment failed\nmain.charge()\n\tmain.go:42",
+				"timestamp":     1714564801.5,
+				"level":         float64(3),
+				"_order":        "A-17",
+				"_retry":        "true",
+				"_error":        "card declined",
+				"_id_":          float64(7),
+				"_user_name":    "ada",
+				"_attributes":   `{"tier":"gold"}`,
+				"_file":         "main.go",
+				"_line":         float64(42),
+			},
+			absent: []string{"_id", "_ignored"},
+		},
+		{
+			name: "multi-line message",
+			msg: types.LogMessage{
+				Level:     LevelInfo,
+				Format:    "request failed\nstatus 502",
+				Timestamp: timestamp,
+			},
+			expected: map[string]interface{}{
+				"short_message": "request failed",
+				"full_message":  "request failed\nstatus 502",
+				"level":         float64(6),
+			},
+		},
+		{
+			name: "raw bytes",
+			msg: types.LogMessage{
+				Level:     LevelDebug,
+				Raw:       []byte("raw line\n"),
+				Timestamp: timestamp,
+			},
+			expected: map[string]interface{}{
+				"short_message": "raw line",
+				"level":         float64(7),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := f.Format(tt.msg)
+			if err != nil {
+				t.Fatalf("Format failed: %v", err)
+			}
+			if !strings.HasSuffix(string(result), "}\n") {
+				t.Errorf("Expected a newline-terminated object, got %q", result)
+			}
+
+			var m map[string]interface{}
+			if err := json.Unmarshal(result, &m); err != nil {
+				t.Fatalf("failed to unmarshal GELF: %v", err)
+			}
+			for key, value := range tt.expected {
+				if m[key] != value {
+					t.Errorf("expected %s %v, got %v", key, value, m[key])
+				}
+			}
+			for _, key := range tt.absent {
+				if _, ok := m[key]; ok {
+					t.Errorf("expected no %s, got %v", key, m[key])
+				}
+			}
+		})
+	}
+}
+
+func TestGELFLevel(t *testing.T) {
+	tests := map[string]int{
+		"trace":   7,
+		"DEBUG":   7,
+		"info":    6,
+		"notice":  5,
+		"WARN":    4,
+		"warning": 4,
+		"error":   3,
+		"fatal":   2,
+		"":        6,
+	}
+	for level, expected := range tests {
+		if got := GELFLevel(level); got != expected {
+			t.Errorf("GELFLevel(%q) = %d, expected %d", level, got, expected)
+		}
+	}
+}
+
+func TestGELFMessage_EmptyShortMessage(t *testing.T) {
+	data, err := json.Marshal(NewGELFMessage("web-1", time.Time{}, "info", "", nil))
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatalf("failed to unmarshal GELF: %v", err)
+	}
+	if m["short_message"] != "-" {
+		t.Errorf("expected placeholder short_message, got %v", m["short_message"])
+	}
+	if _, ok := m["timestamp"]; ok {
+		t.Error("expected no timestamp for a zero time")
+	}
+}
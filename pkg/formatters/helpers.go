@@ -5,6 +5,7 @@ import (
 	"os"
 	"reflect"
 	"runtime"
+	"sort"
 )
 
 var (
@@ -170,3 +171,43 @@ func safeFieldsCopy(value interface{}, visited map[uintptr]bool, depth int) inte
 		return value
 	}
 }
+
+// orderedKeys returns the keys of m named in order first, in that order,
+// followed by the others sorted
+func orderedKeys(m map[string]interface{}, order []string) []string {
+	keys := make([]string, 0, len(m))
+	seen := make(map[string]bool, len(order))
+	for _, k := range order {
+		if _, ok := m[k]; ok && !seen[k] {
+			keys = append(keys, k)
+			seen[k] = true
+		}
+	}
+	rest := len(keys)
+	for k := range m {
+		if !seen[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys[rest:])
+	return keys
+}
+
+// excludeField reports whether a field is dropped by an include and exclude
+// list. Exclusions win; an empty include list keeps every other field.
+func excludeField(field string, include, exclude []string) bool {
+	for _, excluded := range exclude {
+		if field == excluded {
+			return true
+		}
+	}
+	if len(include) == 0 {
+		return false
+	}
+	for _, included := range include {
+		if field == included {
+			return false
+		}
+	}
+	return true
+}
@@ -37,6 +37,15 @@ type StreamFormatter interface {
 	EndStream() ([]byte, error)
 }
 
+// SeparatedStreamFormatter is a StreamFormatter whose entries are separated
+// within a stream, such as the elements of a JSON array
+type SeparatedStreamFormatter interface {
+	StreamFormatter
+
+	// StreamSeparator returns the bytes written between two entries of a stream
+	StreamSeparator() []byte
+}
+
 // ContextualFormatter includes contextual information in formatting
 type ContextualFormatter interface {
 	types.Formatter
@@ -47,3 +56,13 @@ type ContextualFormatter interface {
 	// ClearContext removes all contextual information
 	ClearContext()
 }
+
+// Ensure the built-in formatters implement the optional interfaces
+var (
+	_ EnhancedFormatter        = (*JSONFormatter)(nil)
+	_ BatchFormatter           = (*JSONFormatter)(nil)
+	_ SeparatedStreamFormatter = (*JSONFormatter)(nil)
+	_ EnhancedFormatter        = (*TextFormatter)(nil)
+	_ BatchFormatter           = (*TextFormatter)(nil)
+	_ StreamFormatter          = (*CSVFormatter)(nil)
+)
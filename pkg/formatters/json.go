@@ -1,18 +1,251 @@
 package formatters
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/wayneeseguin/omni/pkg/types"
 )
 
+// ECSVersion is the ecs.version written by the JSONPresetECS preset
+const ECSVersion = "1.6.0"
+
+// JSONKeys names the top-level keys of JSON output. Empty names keep the
+// preset's key, or the DefaultJSONKeys one without a preset.
+type JSONKeys struct {
+	Timestamp  string // Entry timestamp
+	Level      string // Entry level
+	Message    string // Entry message
+	Fields     string // Object holding the entry fields unless they are flattened
+	StackTrace string // Stack trace
+	Metadata   string // Entry metadata
+	Source     string // Object holding the entry file and line; not written when empty
+	TraceID    string // Key the trace_id field is moved to; left among the fields when empty
+	SpanID     string // Key the span_id field is moved to; left among the fields when empty
+}
+
+// DefaultJSONKeys returns the keys of JSON output without a preset
+func DefaultJSONKeys() JSONKeys {
+	return JSONKeys{
+		Timestamp:  "timestamp",
+		Level:      "level",
+		Message:    "message",
+		Fields:     "fields",
+		StackTrace: "stack_trace",
+		Metadata:   "metadata",
+	}
+}
+
+// merge returns k with its empty names taken from base
+func (k JSONKeys) merge(base JSONKeys) JSONKeys {
+	for _, pair := range []struct{ key, base *string }{
+		{&k.Timestamp, &base.Timestamp},
+		{&k.Level, &base.Level},
+		{&k.Message, &base.Message},
+		{&k.Fields, &base.Fields},
+		{&k.StackTrace, &base.StackTrace},
+		{&k.Metadata, &base.Metadata},
+		{&k.Source, &base.Source},
+		{&k.TraceID, &base.TraceID},
+		{&k.SpanID, &base.SpanID},
+	} {
+		if *pair.key == "" {
+			*pair.key = *pair.base
+		}
+	}
+	return k
+}
+
+// JSONLevelStyle selects how JSON output writes the level
+type JSONLevelStyle int
+
+const (
+	// JSONLevelDefault uses the preset's style. Without a preset, levels are
+	// lowercase names and entry levels are written as given.
+	JSONLevelDefault JSONLevelStyle = iota
+	// JSONLevelLower writes lowercase names ("info", "error")
+	JSONLevelLower
+	// JSONLevelUpper writes uppercase names ("INFO", "ERROR")
+	JSONLevelUpper
+	// JSONLevelNumber writes Omni level numbers, from 0 (trace) to 4 (error)
+	JSONLevelNumber
+	// JSONLevelSyslog writes syslog severities (7 debug, 6 info, 4 warning, 3 error)
+	JSONLevelSyslog
+	// JSONLevelGCP writes Google Cloud Logging severities ("INFO", "WARNING")
+	JSONLevelGCP
+)
+
+// JSONPreset selects the vendor schema of JSON output. Presets set the key
+// names and level style, and write entry fields at the top level.
+type JSONPreset int
+
+const (
+	// JSONPresetNone writes Omni's own schema
+	JSONPresetNone JSONPreset = iota
+	// JSONPresetECS writes Elastic Common Schema documents: @timestamp,
+	// log.level, message, error.stack_trace, trace.id and span.id
+	JSONPresetECS
+	// JSONPresetGCP writes Google Cloud Logging structured entries: severity,
+	// logging.googleapis.com/sourceLocation and the trace and span keys. The
+	// trace is written as projects/<GCPProject>/traces/<trace_id>, so trace_id
+	// stays among the fields without a GCPProject.
+	JSONPresetGCP
+	// JSONPresetDatadog writes Datadog reserved attributes: status,
+	// error.stack, dd.trace_id and dd.span_id, with hex trace and span IDs
+	// converted to the decimal of their lower 64 bits
+	JSONPresetDatadog
+)
+
+// jsonPresets holds the keys and level style of each preset
+var jsonPresets = map[JSONPreset]struct {
+	keys  JSONKeys
+	level JSONLevelStyle
+}{
+	JSONPresetECS: {
+		keys: JSONKeys{
+			Timestamp:  "@timestamp",
+			Level:      "log.level",
+			StackTrace: "error.stack_trace",
+			TraceID:    "trace.id",
+			SpanID:     "span.id",
+		},
+		level: JSONLevelLower,
+	},
+	JSONPresetGCP: {
+		keys: JSONKeys{
+			Level:   "severity",
+			Source:  "logging.googleapis.com/sourceLocation",
+			TraceID: "logging.googleapis.com/trace",
+			SpanID:  "logging.googleapis.com/spanId",
+		},
+		level: JSONLevelGCP,
+	},
+	JSONPresetDatadog: {
+		keys: JSONKeys{
+			Level:      "status",
+			StackTrace: "error.stack",
+			TraceID:    "dd.trace_id",
+			SpanID:     "dd.span_id",
+		},
+		level: JSONLevelLower,
+	},
+}
+
+// JSONReadKeys returns the keys of Omni's own schema followed by those of
+// each preset, so readers recognise entries written with any of them
+func JSONReadKeys() []JSONKeys {
+	keys := []JSONKeys{DefaultJSONKeys()}
+	for _, preset := range []JSONPreset{JSONPresetECS, JSONPresetGCP, JSONPresetDatadog} {
+		keys = append(keys, jsonPresets[preset].keys.merge(DefaultJSONKeys()))
+	}
+	return keys
+}
+
+// jsonSchema returns the keys, level style and field flattening of JSON
+// output, from the preset with JSONKeys and JSONLevelStyle applied over it
+func (o FormatOptions) jsonSchema() (keys JSONKeys, level JSONLevelStyle, flatten bool) {
+	preset, ok := jsonPresets[o.JSONPreset]
+	keys = o.JSONKeys.merge(preset.keys).merge(DefaultJSONKeys())
+	level = o.JSONLevelStyle
+	if level == JSONLevelDefault {
+		level = preset.level
+	}
+	return keys, level, o.FlattenFields || ok
+}
+
+// traceValue returns the value of a trace_id or span_id field under the
+// preset's trace key, or false to leave the field among the others
+func (o FormatOptions) traceValue(field string, value interface{}) (interface{}, bool) {
+	id, ok := value.(string)
+	if !ok {
+		return value, true
+	}
+
+	switch o.JSONPreset {
+	case JSONPresetGCP:
+		if field != "trace_id" || strings.HasPrefix(id, "projects/") {
+			return id, true
+		}
+		if o.GCPProject == "" {
+			return nil, false
+		}
+		return "projects/" + o.GCPProject + "/traces/" + id, true
+	case JSONPresetDatadog:
+		return datadogID(id), true
+	default:
+		return id, true
+	}
+}
+
+// datadogID returns the decimal of the lower 64 bits of a 16 or 32 digit hex
+// ID, as Datadog correlates traces; other IDs are returned as given
+func datadogID(id string) string {
+	if len(id) != 16 && len(id) != 32 {
+		return id
+	}
+	n, err := strconv.ParseUint(id[len(id)-16:], 16, 64)
+	if err != nil {
+		return id
+	}
+	return strconv.FormatUint(n, 10)
+}
+
+// JSONLevelValue returns the JSON value of a level name in a level style
+func JSONLevelValue(style JSONLevelStyle, level string) interface{} {
+	switch style {
+	case JSONLevelLower:
+		return strings.ToLower(level)
+	case JSONLevelUpper:
+		return strings.ToUpper(level)
+	case JSONLevelNumber:
+		switch strings.ToLower(level) {
+		case "trace":
+			return LevelTrace
+		case "debug":
+			return LevelDebug
+		case "info":
+			return LevelInfo
+		case "warn", "warning":
+			return LevelWarn
+		case "error":
+			return LevelError
+		}
+		return level
+	case JSONLevelSyslog:
+		return GELFLevel(level)
+	case JSONLevelGCP:
+		switch strings.ToLower(level) {
+		case "trace", "debug":
+			return "DEBUG"
+		case "info":
+			return "INFO"
+		case "notice":
+			return "NOTICE"
+		case "warn", "warning":
+			return "WARNING"
+		case "error":
+			return "ERROR"
+		case "fatal", "critical", "panic":
+			return "CRITICAL"
+		default:
+			return "DEFAULT"
+		}
+	default:
+		return level
+	}
+}
+
 // JSONFormatter formats log messages as JSON
 type JSONFormatter struct {
 	Options       FormatOptions
 	IncludeFields []string // Optional: specific fields to include
 	ExcludeFields []string // Optional: fields to exclude
+	FieldOrder    []string // Optional: keys written first, in this order; the rest are sorted
+	Indent        string   // Optional: indentation of nested values; Options.IndentJSON uses two spaces
 }
 
 // NewJSONFormatter creates a new JSON formatter
@@ -38,7 +271,7 @@ func (f *JSONFormatter) Format(msg types.LogMessage) ([]byte, error) {
 	entry := f.createJSONEntry(msg)
 
 	// Marshal to JSON with circular reference protection
-	data, err := f.safeMarshal(entry)
+	data, err := f.marshalEntry(entry, "")
 	if err != nil {
 		return nil, err
 	}
@@ -51,58 +284,84 @@ func (f *JSONFormatter) Format(msg types.LogMessage) ([]byte, error) {
 
 // formatStructuredEntry formats a structured log entry as JSON
 func (f *JSONFormatter) formatStructuredEntry(entry *types.LogEntry) ([]byte, error) {
+	keys, level, flatten := f.Options.jsonSchema()
+
 	// Create a map for JSON output
 	jsonEntry := make(map[string]interface{})
 
 	// Add timestamp if included
 	if f.Options.IncludeTime {
-		jsonEntry["timestamp"] = entry.Timestamp
+		jsonEntry[keys.Timestamp] = entry.Timestamp
 	}
 
 	// Add level if included
 	if f.Options.IncludeLevel {
-		jsonEntry["level"] = entry.Level
+		jsonEntry[keys.Level] = JSONLevelValue(level, entry.Level)
 	}
 
 	// Add message
-	jsonEntry["message"] = entry.Message
-
-	// Add fields
-	if len(entry.Fields) > 0 {
-		// Check if we should flatten fields or nest them
-		if f.Options.FlattenFields {
-			// Add fields directly to the root
-			for k, v := range entry.Fields {
-				if !f.shouldExcludeField(k) {
-					jsonEntry[k] = v
-				}
-			}
-		} else {
-			// Nest fields under a "fields" key
-			filteredFields := make(map[string]interface{})
-			for k, v := range entry.Fields {
-				if !f.shouldExcludeField(k) {
-					filteredFields[k] = v
-				}
-			}
-			if len(filteredFields) > 0 {
-				jsonEntry["fields"] = filteredFields
-			}
-		}
-	}
+	jsonEntry[keys.Message] = entry.Message
 
 	// Add stack trace if present
 	if entry.StackTrace != "" {
-		jsonEntry["stack_trace"] = entry.StackTrace
+		jsonEntry[keys.StackTrace] = entry.StackTrace
 	}
 
 	// Add metadata if configured
-	if entry.Metadata != nil && len(entry.Metadata) > 0 {
-		jsonEntry["metadata"] = entry.Metadata
+	if len(entry.Metadata) > 0 {
+		jsonEntry[keys.Metadata] = entry.Metadata
+	}
+
+	// Add the source location when the schema has a key for it
+	if keys.Source != "" && entry.File != "" {
+		jsonEntry[keys.Source] = map[string]interface{}{"file": entry.File, "line": entry.Line}
+	}
+
+	if f.Options.JSONPreset == JSONPresetECS {
+		jsonEntry["ecs.version"] = ECSVersion
+	}
+
+	// Add fields, moving the trace context to its own keys
+	fields := make(map[string]interface{}, len(entry.Fields))
+	for k, v := range entry.Fields {
+		if f.shouldExcludeField(k) {
+			continue
+		}
+		key := ""
+		switch k {
+		case "trace_id":
+			key = keys.TraceID
+		case "span_id":
+			key = keys.SpanID
+		}
+		if key != "" {
+			if value, ok := f.Options.traceValue(k, v); ok {
+				jsonEntry[key] = value
+				continue
+			}
+		}
+		fields[k] = v
+	}
+	if flatten {
+		// Add fields directly to the root, prefixing those that would
+		// replace one of the keys above
+		reserved := make(map[string]bool, len(jsonEntry))
+		for k := range jsonEntry {
+			reserved[k] = true
+		}
+		for k, v := range fields {
+			if reserved[k] {
+				k = keys.Fields + "." + k
+			}
+			jsonEntry[k] = v
+		}
+	} else if len(fields) > 0 {
+		// Nest fields under the fields key
+		jsonEntry[keys.Fields] = fields
 	}
 
 	// Marshal to JSON with circular reference protection
-	data, err := f.safeMarshal(jsonEntry)
+	data, err := f.marshalEntry(jsonEntry, keys.Fields)
 	if err != nil {
 		return nil, err
 	}
@@ -115,16 +374,17 @@ func (f *JSONFormatter) formatStructuredEntry(entry *types.LogEntry) ([]byte, er
 
 // createJSONEntry creates a JSON-serializable entry from a log message
 func (f *JSONFormatter) createJSONEntry(msg types.LogMessage) map[string]interface{} {
+	keys, level, _ := f.Options.jsonSchema()
 	entry := make(map[string]interface{})
 
 	// Add timestamp if included
 	if f.Options.IncludeTime {
-		entry["timestamp"] = f.formatTimestamp(msg.Timestamp)
+		entry[keys.Timestamp] = f.formatTimestamp(msg.Timestamp)
 	}
 
 	// Add level if included
 	if f.Options.IncludeLevel {
-		entry["level"] = f.formatLevel(msg.Level)
+		entry[keys.Level] = JSONLevelValue(level, f.formatLevel(msg.Level))
 	}
 
 	// Format and add message
@@ -136,7 +396,11 @@ func (f *JSONFormatter) createJSONEntry(msg types.LogMessage) map[string]interfa
 			message = msg.Format
 		}
 	}
-	entry["message"] = message
+	entry[keys.Message] = message
+
+	if f.Options.JSONPreset == JSONPresetECS {
+		entry["ecs.version"] = ECSVersion
+	}
 
 	return entry
 }
@@ -170,24 +434,7 @@ func (f *JSONFormatter) formatLevel(level int) string {
 
 // shouldExcludeField checks if a field should be excluded from output
 func (f *JSONFormatter) shouldExcludeField(field string) bool {
-	// Check exclude list
-	for _, excluded := range f.ExcludeFields {
-		if field == excluded {
-			return true
-		}
-	}
-
-	// If include list is specified, only include fields in the list
-	if len(f.IncludeFields) > 0 {
-		for _, included := range f.IncludeFields {
-			if field == included {
-				return false
-			}
-		}
-		return true // Not in include list
-	}
-
-	return false
+	return excludeField(field, f.IncludeFields, f.ExcludeFields)
 }
 
 // WithIncludeFields sets fields to include in JSON output
@@ -202,6 +449,143 @@ func (f *JSONFormatter) WithExcludeFields(fields ...string) *JSONFormatter {
 	return f
 }
 
+// SetFieldOrder sets the keys written first, in this order, both at the top
+// level and among nested fields. Other keys follow in sorted order.
+func (f *JSONFormatter) SetFieldOrder(fields []string) {
+	f.FieldOrder = fields
+}
+
+// SetFieldFilter sets the entry fields to include and exclude
+func (f *JSONFormatter) SetFieldFilter(include []string, exclude []string) {
+	f.IncludeFields = include
+	f.ExcludeFields = exclude
+}
+
+// SetIndentation sets the indentation of nested values. Indented entries span
+// several lines, so readers expecting one entry per line need a JSON array.
+func (f *JSONFormatter) SetIndentation(indent string) {
+	f.Indent = indent
+}
+
+// indent returns the indentation of output, or "" for compact output
+func (f *JSONFormatter) indent() string {
+	if f.Indent == "" && f.Options.IndentJSON {
+		return "  "
+	}
+	return f.Indent
+}
+
+// FormatBatch formats messages as one JSON array. Raw messages that are not
+// JSON are written as strings.
+func (f *JSONFormatter) FormatBatch(messages []types.LogMessage) ([]byte, error) {
+	buf := []byte{'['}
+	for i, msg := range messages {
+		data, err := f.Format(msg)
+		if err != nil {
+			return nil, err
+		}
+		data = bytes.TrimSuffix(data, []byte("\n"))
+		if !json.Valid(data) {
+			if data, err = json.Marshal(string(data)); err != nil {
+				return nil, err
+			}
+		}
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, data...)
+	}
+	buf = append(buf, ']')
+
+	if indent := f.indent(); indent != "" {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, buf, "", indent); err != nil {
+			return nil, err
+		}
+		buf = indented.Bytes()
+	}
+	return append(buf, '\n'), nil
+}
+
+// StartStream returns the opening bracket of a JSON array when
+// Options.JSONArray is set. Line-delimited JSON needs no framing.
+func (f *JSONFormatter) StartStream() ([]byte, error) {
+	if !f.Options.JSONArray {
+		return nil, nil
+	}
+	return []byte("[\n"), nil
+}
+
+// EndStream returns the closing bracket of a JSON array when
+// Options.JSONArray is set
+func (f *JSONFormatter) EndStream() ([]byte, error) {
+	if !f.Options.JSONArray {
+		return nil, nil
+	}
+	return []byte("]\n"), nil
+}
+
+// StreamSeparator returns the comma leading every array element after the
+// first when Options.JSONArray is set, so each entry still starts a line
+func (f *JSONFormatter) StreamSeparator() []byte {
+	if !f.Options.JSONArray {
+		return nil
+	}
+	return []byte(",")
+}
+
+// marshalEntry marshals an entry, ordering its keys and those of the nested
+// fields object by FieldOrder and indenting it as configured
+func (f *JSONFormatter) marshalEntry(entry map[string]interface{}, fieldsKey string) ([]byte, error) {
+	var data []byte
+	var err error
+	if len(f.FieldOrder) > 0 {
+		data, err = f.marshalOrdered(entry, fieldsKey)
+	} else {
+		data, err = f.safeMarshal(entry)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if indent := f.indent(); indent != "" {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, data, "", indent); err != nil {
+			return nil, err
+		}
+		data = indented.Bytes()
+	}
+	return data, nil
+}
+
+// marshalOrdered marshals m as an object with its keys in FieldOrder,
+// recursing into the object under fieldsKey
+func (f *JSONFormatter) marshalOrdered(m map[string]interface{}, fieldsKey string) ([]byte, error) {
+	buf := []byte{'{'}
+	for i, k := range orderedKeys(m, f.FieldOrder) {
+		key, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		var value []byte
+		if nested, ok := m[k].(map[string]interface{}); ok && fieldsKey != "" && k == fieldsKey {
+			value, err = f.marshalOrdered(nested, "")
+		} else {
+			value, err = f.safeMarshal(m[k])
+		}
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, key...)
+		buf = append(buf, ':')
+		buf = append(buf, value...)
+	}
+	return append(buf, '}'), nil
+}
+
 // safeMarshal marshals data to JSON with circular reference protection
 func (f *JSONFormatter) safeMarshal(data interface{}) ([]byte, error) {
 	// Use a simple approach - attempt to marshal, and if it fails with likely
@@ -504,3 +504,260 @@ func TestJSONFormatter_TimeZone(t *testing.T) {
 		t.Errorf("expected time in EST, got %v", m["timestamp"])
 	}
 }
+
+func TestJSONFormatter_Presets(t *testing.T) {
+	entry := &types.LogEntry{
+		Timestamp:  "2024-05-01T12:00:00Z",
+		Level:      "WARN",
+		Message:    "slow query",
+		File:       "db.go",
+		Line:       42,
+		StackTrace: "main.main()",
+		Fields: map[string]interface{}{
+			"trace_id": "4bf92f3577b34da6a3ce929d0e0e4736",
+			"span_id":  "00f067aa0ba902b7",
+			"table":    "users",
+			"message":  "shadowed",
+		},
+	}
+
+	tests := []struct {
+		preset   JSONPreset
+		project  string
+		expected string
+	}{
+		{
+			preset:   JSONPresetECS,
+			expected: `{"@timestamp":"2024-05-01T12:00:00Z","ecs.version":"1.6.0","error.stack_trace":"main.main()","fields.message":"shadowed","log.level":"warn","message":"slow query","span.id":"00f067aa0ba902b7","table":"users","trace.id":"4bf92f3577b34da6a3ce929d0e0e4736"}`,
+		},
+		{
+			preset:   JSONPresetGCP,
+			project:  "my-project",
+			expected: `{"fields.message":"shadowed","logging.googleapis.com/sourceLocation":{"file":"db.go","line":42},"logging.googleapis.com/spanId":"00f067aa0ba902b7","logging.googleapis.com/trace":"projects/my-project/traces/4bf92f3577b34da6a3ce929d0e0e4736","message":"slow query","severity":"WARNING","stack_trace":"main.main()","table":"users","timestamp":"2024-05-01T12:00:00Z"}`,
+		},
+		{
+			// Without a project the trace cannot be named, so it stays a field
+			preset:   JSONPresetGCP,
+			expected: `{"fields.message":"shadowed","logging.googleapis.com/sourceLocation":{"file":"db.go","line":42},"logging.googleapis.com/spanId":"00f067aa0ba902b7","message":"slow query","severity":"WARNING","stack_trace":"main.main()","table":"users","timestamp":"2024-05-01T12:00:00Z","trace_id":"4bf92f3577b34da6a3ce929d0e0e4736"}`,
+		},
+		{
+			// Datadog IDs are the decimal lower 64 bits of the hex IDs
+			preset:   JSONPresetDatadog,
+			expected: `{"dd.span_id":"67667974448284343","dd.trace_id":"11803532876627986230","error.stack":"main.main()","fields.message":"shadowed","message":"slow query","status":"warn","table":"users","timestamp":"2024-05-01T12:00:00Z"}`,
+		},
+		{
+			preset:   JSONPresetNone,
+			expected: `{"fields":{"message":"shadowed","span_id":"00f067aa0ba902b7","table":"users","trace_id":"4bf92f3577b34da6a3ce929d0e0e4736"},"level":"WARN","message":"slow query","stack_trace":"main.main()","timestamp":"2024-05-01T12:00:00Z"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		f := NewJSONFormatter()
+		f.Options.JSONPreset = tt.preset
+		f.Options.GCPProject = tt.project
+		result, err := f.Format(types.LogMessage{Entry: entry})
+		if err != nil {
+			t.Fatalf("preset %d: Format() error = %v", tt.preset, err)
+		}
+		if string(result) != tt.expected+"\n" {
+			t.Errorf("preset %d:\nexpected %s\ngot      %s", tt.preset, tt.expected, result)
+		}
+	}
+}
+
+func TestDatadogID(t *testing.T) {
+	tests := map[string]string{
+		"4bf92f3577b34da6a3ce929d0e0e4736": "11803532876627986230",
+		"00f067aa0ba902b7":                 "67667974448284343",
+		"ffffffffffffffff":                 "18446744073709551615",
+		"1234":                             "1234",
+		"not-a-hex-trace-id-of-32-digits!": "not-a-hex-trace-id-of-32-digits!",
+	}
+	for id, expected := range tests {
+		if got := datadogID(id); got != expected {
+			t.Errorf("datadogID(%q) = %q, expected %q", id, got, expected)
+		}
+	}
+}
+
+func TestJSONFormatter_Keys(t *testing.T) {
+	f := NewJSONFormatter()
+	f.Options.JSONPreset = JSONPresetDatadog
+	f.Options.JSONKeys = JSONKeys{Timestamp: "ts", Message: "msg"}
+	f.Options.JSONLevelStyle = JSONLevelSyslog
+
+	result, err := f.Format(types.LogMessage{
+		Level:     LevelError,
+		Format:    "failed after %d attempts",
+		Args:      []interface{}{3},
+		Timestamp: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	expected := `{"msg":"failed after 3 attempts","status":3,"ts":"2024-05-01T12:00:00Z"}` + "\n"
+	if string(result) != expected {
+		t.Errorf("expected %s, got %s", expected, result)
+	}
+
+	// Without a preset, only the renamed keys change
+	f = NewJSONFormatter()
+	f.Options.JSONKeys = JSONKeys{Fields: "attrs"}
+	f.Options.JSONLevelStyle = JSONLevelUpper
+	result, err = f.Format(types.LogMessage{Entry: &types.LogEntry{
+		Timestamp: "2024-05-01T12:00:00Z",
+		Level:     "info",
+		Message:   "m",
+		Fields:    map[string]interface{}{"trace_id": "abc"},
+	}})
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	expected = `{"attrs":{"trace_id":"abc"},"level":"INFO","message":"m","timestamp":"2024-05-01T12:00:00Z"}` + "\n"
+	if string(result) != expected {
+		t.Errorf("expected %s, got %s", expected, result)
+	}
+}
+
+func TestJSONLevelValue(t *testing.T) {
+	tests := []struct {
+		style    JSONLevelStyle
+		level    string
+		expected interface{}
+	}{
+		{JSONLevelDefault, "Info", "Info"},
+		{JSONLevelLower, "ERROR", "error"},
+		{JSONLevelUpper, "warn", "WARN"},
+		{JSONLevelNumber, "debug", LevelDebug},
+		{JSONLevelNumber, "custom", "custom"},
+		{JSONLevelSyslog, "warning", 4},
+		{JSONLevelGCP, "trace", "DEBUG"},
+		{JSONLevelGCP, "warn", "WARNING"},
+		{JSONLevelGCP, "fatal", "CRITICAL"},
+		{JSONLevelGCP, "log", "DEFAULT"},
+	}
+	for _, tt := range tests {
+		if got := JSONLevelValue(tt.style, tt.level); got != tt.expected {
+			t.Errorf("JSONLevelValue(%d, %q) = %v, want %v", tt.style, tt.level, got, tt.expected)
+		}
+	}
+}
+
+func TestJSONFormatter_FieldOrder(t *testing.T) {
+	f := NewJSONFormatter()
+	f.SetFieldOrder([]string{"message", "level", "user", "fields"})
+	f.SetFieldFilter(nil, []string{"password"})
+
+	msg := types.LogMessage{Entry: &types.LogEntry{
+		Timestamp: "2024-05-01T12:00:00Z",
+		Level:     "info",
+		Message:   "login",
+		Fields:    map[string]interface{}{"zone": "eu", "user": "ann", "password": "x", "attempt": 2},
+	}}
+	result, err := f.Format(msg)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	expected := `{"message":"login","level":"info","fields":{"user":"ann","attempt":2,"zone":"eu"},"timestamp":"2024-05-01T12:00:00Z"}` + "\n"
+	if string(result) != expected {
+		t.Errorf("expected %s, got %s", expected, result)
+	}
+
+	// Include lists keep only the named fields
+	f.SetFieldFilter([]string{"zone"}, nil)
+	f.SetIndentation("\t")
+	result, err = f.Format(msg)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	expected = "{\n\t\"message\": \"login\",\n\t\"level\": \"info\",\n\t\"fields\": {\n\t\t\"zone\": \"eu\"\n\t},\n\t\"timestamp\": \"2024-05-01T12:00:00Z\"\n}\n"
+	if string(result) != expected {
+		t.Errorf("expected %q, got %q", expected, result)
+	}
+
+	// IndentJSON indents by two spaces without an explicit indentation
+	f = NewJSONFormatter()
+	f.Options.IndentJSON = true
+	f.Options.IncludeTime = false
+	result, err = f.Format(types.LogMessage{Level: LevelInfo, Format: "m"})
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	expected = "{\n  \"level\": \"info\",\n  \"message\": \"m\"\n}\n"
+	if string(result) != expected {
+		t.Errorf("expected %q, got %q", expected, result)
+	}
+}
+
+func TestJSONFormatter_FormatBatch(t *testing.T) {
+	f := NewJSONFormatter()
+	f.Options.IncludeTime = false
+
+	result, err := f.FormatBatch([]types.LogMessage{
+		{Level: LevelInfo, Format: "first"},
+		{Raw: []byte("not json\n")},
+		{Raw: []byte(`{"raw":true}`)},
+	})
+	if err != nil {
+		t.Fatalf("FormatBatch() error = %v", err)
+	}
+	expected := `[{"level":"info","message":"first"},"not json",{"raw":true}]` + "\n"
+	if string(result) != expected {
+		t.Errorf("expected %s, got %s", expected, result)
+	}
+
+	// Indented batches are indented as one array
+	f.SetIndentation("  ")
+	result, err = f.FormatBatch([]types.LogMessage{{Level: LevelWarn, Format: "second"}})
+	if err != nil {
+		t.Fatalf("FormatBatch() error = %v", err)
+	}
+	expected = "[\n  {\n    \"level\": \"warn\",\n    \"message\": \"second\"\n  }\n]\n"
+	if string(result) != expected {
+		t.Errorf("expected %q, got %q", expected, result)
+	}
+
+	result, err = f.FormatBatch(nil)
+	if err != nil || string(result) != "[]\n" {
+		t.Errorf("FormatBatch(nil) = %q, %v", result, err)
+	}
+}
+
+func TestJSONFormatter_Stream(t *testing.T) {
+	f := NewJSONFormatter()
+	start, _ := f.StartStream()
+	end, _ := f.EndStream()
+	if start != nil || end != nil || f.StreamSeparator() != nil {
+		t.Errorf("line-delimited JSON should not be framed, got %q %q %q", start, f.StreamSeparator(), end)
+	}
+
+	f.Options.JSONArray = true
+	f.Options.IncludeTime = false
+	start, _ = f.StartStream()
+	end, _ = f.EndStream()
+
+	var stream []byte
+	stream = append(stream, start...)
+	for i, text := range []string{"a", "b", "c"} {
+		if i > 0 {
+			stream = append(stream, f.StreamSeparator()...)
+		}
+		entry, err := f.Format(types.LogMessage{Level: LevelInfo, Format: text})
+		if err != nil {
+			t.Fatalf("Format() error = %v", err)
+		}
+		stream = append(stream, entry...)
+	}
+	stream = append(stream, end...)
+
+	var entries []map[string]interface{}
+	if err := json.Unmarshal(stream, &entries); err != nil {
+		t.Fatalf("stream is not a JSON array: %v\n%s", err, stream)
+	}
+	if len(entries) != 3 || entries[2]["message"] != "c" {
+		t.Errorf("unexpected entries %v", entries)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(stream)), "\n"); len(lines) != 5 {
+		t.Errorf("expected one entry per line, got %q", lines)
+	}
+}
@@ -0,0 +1,99 @@
+package formatters
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/wayneeseguin/omni/pkg/types"
+)
+
+// DefaultLEEFDelimiter separates LEEF attributes
+const DefaultLEEFDelimiter = '\t'
+
+// leefTimeFormat is the default LEEF devTime format, MMM dd yyyy HH:mm:ss.SSS zzz
+const leefTimeFormat = "Jan 02 2006 15:04:05.000 MST"
+
+// LEEFSeverity maps a level name to a LEEF severity from 1 to 10: trace and
+// debug 1, info 3, notice 4, warn 6, error 8 and fatal, critical or panic 10
+func LEEFSeverity(level string) int {
+	if severity := CEFSeverity(level); severity > 0 {
+		return severity
+	}
+	return 1
+}
+
+// LEEFFormatter formats log messages as IBM QRadar Log Event Extended
+// Format (LEEF:2.0) events:
+//
+//	LEEF:2.0|Omni|checkout|1.0|auth.failed|x09|devTime=May 01 2024 12:00:00.000 UTC	sev=6	msg=login failed
+//
+// The level sets sev, the message is sent as msg and the remaining fields
+// follow as attributes separated by Delimiter.
+type LEEFFormatter struct {
+	SecurityEventMapping
+	Delimiter rune // Attribute separator; DefaultLEEFDelimiter when zero
+}
+
+// NewLEEFFormatter creates a new LEEF formatter
+func NewLEEFFormatter() *LEEFFormatter {
+	return &LEEFFormatter{
+		Delimiter: DefaultLEEFDelimiter,
+	}
+}
+
+// Format formats a log message as a newline-terminated LEEF event
+func (f *LEEFFormatter) Format(msg types.LogMessage) ([]byte, error) {
+	event := newSecurityEvent(msg)
+
+	eventID, ok := event.take(f.eventIDField())
+	if !ok {
+		eventID = f.defaultEventID()
+	}
+	vendor, product, version := f.header()
+
+	delimiter := f.Delimiter
+	if delimiter == 0 {
+		delimiter = DefaultLEEFDelimiter
+	}
+	// Attribute values escape the delimiter, so it cannot be a character
+	// that appears in escapes
+	if delimiter == '\\' || delimiter == '=' || delimiter == 'n' || delimiter == 'r' {
+		return nil, fmt.Errorf("invalid LEEF delimiter %q", delimiter)
+	}
+	escaper := strings.NewReplacer(`\`, `\\`, string(delimiter), `\`+string(delimiter), "\r\n", `\n`, "\n", `\n`, "\r", `\r`)
+
+	var b strings.Builder
+	b.WriteString("LEEF:2.0")
+	for _, field := range []string{vendor, product, version, eventID} {
+		b.WriteByte('|')
+		b.WriteString(securityEventHeaderEscaper.Replace(field))
+	}
+	b.WriteByte('|')
+	if delimiter > ' ' && delimiter < 0x7f && delimiter != '|' {
+		b.WriteRune(delimiter)
+	} else {
+		// Whitespace, '|' and non-ASCII delimiters are given in hex
+		fmt.Fprintf(&b, "x%02x", delimiter)
+	}
+	b.WriteByte('|')
+
+	header := b.Len()
+	attribute := func(key, value string) {
+		if b.Len() > header {
+			b.WriteRune(delimiter)
+		}
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(escaper.Replace(value))
+	}
+	attribute("devTime", event.timestamp.UTC().Format(leefTimeFormat))
+	attribute("sev", strconv.Itoa(LEEFSeverity(event.level)))
+	attribute("msg", event.message)
+	for _, pair := range f.extension(event.fields) {
+		attribute(pair[0], pair[1])
+	}
+	b.WriteByte('\n')
+
+	return []byte(b.String()), nil
+}
@@ -0,0 +1,95 @@
+package formatters
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/types"
+)
+
+func TestLEEFFormatter_Format(t *testing.T) {
+	tests := []struct {
+		name      string
+		delimiter rune
+		msg       types.LogMessage
+		expected  string
+	}{
+		{
+			name: "formatted message",
+			msg: types.LogMessage{
+				Level:     LevelInfo,
+				Format:    "user %s logged in",
+				Args:      []interface{}{"ada"},
+				Timestamp: time.Date(2024, 5, 1, 12, 0, 0, 250e6, time.UTC),
+			},
+			expected: "LEEF:2.0|Omni|app|1.0|log|x09|devTime=May 01 2024 12:00:00.250 UTC\tsev=3\tmsg=user ada logged in\n",
+		},
+		{
+			name: "fields and escaping",
+			msg: types.LogMessage{
+				Entry: &types.LogEntry{
+					Timestamp: "2024-05-01T12:00:00Z",
+					Level:     "WARN",
+					Message:   "line one\nline\ttwo",
+					Fields: map[string]interface{}{
+						"event_id":  "auth|failed",
+						"client_ip": "10.0.0.1",
+						"path":      `C:\tmp`,
+						"query":     "a=b",
+					},
+				},
+			},
+			expected: "LEEF:2.0|Omni|app|1.0|auth\\|failed|x09|devTime=May 01 2024 12:00:00.000 UTC\tsev=6\tmsg=line one\\nline\\\ttwo\tclient_ip=10.0.0.1\tpath=C:\\\\tmp\tquery=a=b\n",
+		},
+		{
+			name:      "custom delimiter",
+			delimiter: '^',
+			msg: types.LogMessage{
+				Entry: &types.LogEntry{Timestamp: "2024-05-01T12:00:00Z", Level: "trace", Message: "a^b"},
+			},
+			expected: "LEEF:2.0|Omni|app|1.0|log|^|devTime=May 01 2024 12:00:00.000 UTC^sev=1^msg=a\\^b\n",
+		},
+		{
+			name:      "pipe delimiter",
+			delimiter: '|',
+			msg: types.LogMessage{
+				Entry: &types.LogEntry{Timestamp: "2024-05-01T12:00:00Z", Level: "error", Message: "m"},
+			},
+			expected: "LEEF:2.0|Omni|app|1.0|log|x7c|devTime=May 01 2024 12:00:00.000 UTC|sev=8|msg=m\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewLEEFFormatter()
+			f.Product = "app"
+			if tt.delimiter != 0 {
+				f.Delimiter = tt.delimiter
+			}
+			data, err := f.Format(tt.msg)
+			if err != nil {
+				t.Fatalf("Format failed: %v", err)
+			}
+			if string(data) != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, string(data))
+			}
+		})
+	}
+}
+
+func TestLEEFFormatter_InvalidDelimiter(t *testing.T) {
+	f := NewLEEFFormatter()
+	f.Delimiter = '='
+	if _, err := f.Format(types.LogMessage{Format: "m"}); err == nil {
+		t.Error("Expected error for '=' delimiter")
+	}
+}
+
+func TestLEEFSeverity(t *testing.T) {
+	tests := map[string]int{"trace": 1, "debug": 1, "info": 3, "warn": 6, "error": 8, "panic": 10}
+	for level, expected := range tests {
+		if got := LEEFSeverity(level); got != expected {
+			t.Errorf("LEEFSeverity(%q) = %d, want %d", level, got, expected)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package formatters
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/wayneeseguin/omni/pkg/types"
+)
+
+// Keys written by LogfmtFormatter ahead of the entry fields
+const (
+	LogfmtTimeKey    = "ts"
+	LogfmtLevelKey   = "level"
+	LogfmtMessageKey = "msg"
+	LogfmtStackKey   = "stack_trace"
+)
+
+// LogfmtFieldPrefix is added to fields named like one of the keys above so
+// they do not repeat it
+const LogfmtFieldPrefix = "fields."
+
+// LogfmtFormatter formats log messages as logfmt lines:
+//
+//	ts=2024-05-01T12:00:00Z level=info msg="user logged in" user.id=42
+//
+// The timestamp, level and message come first, followed by the fields in
+// KeyOrder and then the remaining fields sorted by key. Nested maps are
+// flattened into dotted keys.
+type LogfmtFormatter struct {
+	Options  FormatOptions
+	KeyOrder []string // Fields written first, in this order, when present
+}
+
+// NewLogfmtFormatter creates a new logfmt formatter
+func NewLogfmtFormatter() *LogfmtFormatter {
+	return &LogfmtFormatter{
+		Options: DefaultFormatOptions(),
+	}
+}
+
+// Format formats a log message as a logfmt line
+func (f *LogfmtFormatter) Format(msg types.LogMessage) ([]byte, error) {
+	// Handle raw bytes - pass through as-is
+	if msg.Raw != nil {
+		return msg.Raw, nil
+	}
+
+	var b []byte
+	if msg.Entry != nil {
+		entry := msg.Entry
+		if f.Options.IncludeTime && entry.Timestamp != "" {
+			b = AppendLogfmtPair(b, LogfmtTimeKey, entry.Timestamp)
+		}
+		if f.Options.IncludeLevel && entry.Level != "" {
+			b = AppendLogfmtPair(b, LogfmtLevelKey, strings.ToLower(entry.Level))
+		}
+		b = AppendLogfmtPair(b, LogfmtMessageKey, entry.Message)
+		b = f.appendFields(b, entry.Fields)
+		if entry.StackTrace != "" {
+			b = AppendLogfmtPair(b, LogfmtStackKey, entry.StackTrace)
+		}
+	} else {
+		if f.Options.IncludeTime {
+			b = AppendLogfmtPair(b, LogfmtTimeKey, msg.Timestamp.In(f.Options.TimeZone).Format(f.Options.TimestampFormat))
+		}
+		if f.Options.IncludeLevel {
+			b = AppendLogfmtPair(b, LogfmtLevelKey, strings.ToLower(levelToString(msg.Level)))
+		}
+		message := msg.Format
+		if len(msg.Args) > 0 {
+			message = fmt.Sprintf(msg.Format, msg.Args...)
+		}
+		b = AppendLogfmtPair(b, LogfmtMessageKey, message)
+	}
+
+	return append(b, '\n'), nil
+}
+
+// appendFields appends the flattened fields in KeyOrder, then sorted
+func (f *LogfmtFormatter) appendFields(b []byte, fields map[string]interface{}) []byte {
+	if len(fields) == 0 {
+		return b
+	}
+
+	flat := FlattenLogfmtFields(fields)
+	for _, key := range f.KeyOrder {
+		if value, ok := flat[key]; ok {
+			b = appendLogfmtField(b, key, value)
+			delete(flat, key)
+		}
+	}
+
+	keys := make([]string, 0, len(flat))
+	for key := range flat {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		b = appendLogfmtField(b, key, flat[key])
+	}
+	return b
+}
+
+// appendLogfmtField appends a field, prefixing names that clash with the
+// keys written ahead of the fields
+func appendLogfmtField(b []byte, key, value string) []byte {
+	switch key {
+	case LogfmtTimeKey, LogfmtLevelKey, LogfmtMessageKey, LogfmtStackKey:
+		key = LogfmtFieldPrefix + key
+	}
+	return AppendLogfmtPair(b, key, value)
+}
+
+// FlattenLogfmtFields renders fields as logfmt values, flattening nested maps
+// into dotted keys: {"http": {"status": 200}} becomes "http.status" = "200".
+func FlattenLogfmtFields(fields map[string]interface{}) map[string]string {
+	flat := make(map[string]string, len(fields))
+	flattenLogfmt(flat, "", fields)
+	return flat
+}
+
+// flattenLogfmt adds the fields to flat under prefix
+func flattenLogfmt(flat map[string]string, prefix string, fields map[string]interface{}) {
+	for key, value := range fields {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			flattenLogfmt(flat, prefix+key+".", v)
+		case map[string]string:
+			for nested, s := range v {
+				flat[prefix+key+"."+nested] = s
+			}
+		default:
+			flat[prefix+key] = LogfmtValue(value)
+		}
+	}
+}
+
+// LogfmtValue renders a field value as logfmt text: numbers without
+// exponents, times in RFC 3339, nil as null and slices and structs as JSON
+func LogfmtValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, json.Number:
+		return fmt.Sprint(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case time.Duration:
+		return v.String()
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+}
+
+// AppendLogfmtPair appends key=value, preceded by a space unless b is empty.
+// The value is quoted when it is empty or contains spaces, quotes, '=',
+// backslashes or control characters; spaces, quotes, '=' and control
+// characters in the key are replaced with '_'.
+func AppendLogfmtPair(b []byte, key, value string) []byte {
+	if len(b) > 0 {
+		b = append(b, ' ')
+	}
+	b = appendLogfmtKey(b, key)
+	b = append(b, '=')
+	if logfmtNeedsQuote(value) {
+		return strconv.AppendQuote(b, value)
+	}
+	return append(b, value...)
+}
+
+// appendLogfmtKey appends key with characters logfmt cannot carry replaced
+func appendLogfmtKey(b []byte, key string) []byte {
+	if key == "" {
+		return append(b, '_')
+	}
+	for _, r := range key {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError || !unicode.IsPrint(r) {
+			b = append(b, '_')
+		} else {
+			b = utf8.AppendRune(b, r)
+		}
+	}
+	return b
+}
+
+// logfmtNeedsQuote reports whether value must be quoted
+func logfmtNeedsQuote(value string) bool {
+	if value == "" {
+		return true
+	}
+	for _, r := range value {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || r == utf8.RuneError || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package formatters
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/types"
+)
+
+func TestLogfmtFormatter_Format(t *testing.T) {
+	timestamp := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		keyOrder []string
+		msg      types.LogMessage
+		expected string
+	}{
+		{
+			name: "formatted message",
+			msg: types.LogMessage{
+				Level:     LevelWarn,
+				Format:    "disk %d%% full",
+				Args:      []interface{}{91},
+				Timestamp: timestamp,
+			},
+			expected: `ts=2024-05-01T12:00:00Z level=warn msg="disk 91% full"` + "\n",
+		},
+		{
+			name: "structured entry",
+			msg: types.LogMessage{
+				Entry: &types.LogEntry{
+					Timestamp: "2024-05-01T12:00:00Z",
+					Level:     "INFO",
+					Message:   "request",
+					Fields: map[string]interface{}{
+						"status":   200,
+						"duration": 0.25,
+						"ok":       true,
+						"user":     nil,
+						"tags":     []string{"a", "b"},
+						"err":      errors.New("timed out"),
+					},
+				},
+			},
+			expected: `ts=2024-05-01T12:00:00Z level=info msg=request duration=0.25 err="timed out" ok=true status=200 tags="[\"a\",\"b\"]" user=null` + "\n",
+		},
+		{
+			name: "nested fields",
+			msg: types.LogMessage{
+				Entry: &types.LogEntry{
+					Level:   "info",
+					Message: "nested",
+					Fields: map[string]interface{}{
+						"http": map[string]interface{}{
+							"method":  "GET",
+							"request": map[string]interface{}{"path": "/a b"},
+						},
+						"labels": map[string]string{"env": "prod"},
+					},
+				},
+			},
+			expected: `level=info msg=nested http.method=GET http.request.path="/a b" labels.env=prod` + "\n",
+		},
+		{
+			name:     "key order",
+			keyOrder: []string{"request_id", "missing", "user"},
+			msg: types.LogMessage{
+				Entry: &types.LogEntry{
+					Message: "ordered",
+					Fields:  map[string]interface{}{"a": 1, "user": "ada", "request_id": "r1"},
+				},
+			},
+			expected: `msg=ordered request_id=r1 user=ada a=1` + "\n",
+		},
+		{
+			name: "escaping",
+			msg: types.LogMessage{
+				Entry: &types.LogEntry{
+					Message:    "line one\nline \"two\"",
+					Fields:     map[string]interface{}{"expr": "a=b", "path": `C:\tmp`, "empty": "", "bad key": "x"},
+					StackTrace: "main.main()\n\tmain.go:10",
+				},
+			},
+			expected: `msg="line one\nline \"two\"" bad_key=x empty="" expr="a=b" path="C:\\tmp" stack_trace="main.main()\n\tmain.go:10"` + "\n",
+		},
+		{
+			name: "reserved field names",
+			msg: types.LogMessage{
+				Entry: &types.LogEntry{
+					Message: "clash",
+					Fields:  map[string]interface{}{"msg": "inner", "level": 3},
+				},
+			},
+			expected: `msg=clash fields.level=3 fields.msg=inner` + "\n",
+		},
+		{
+			name:     "raw passthrough",
+			msg:      types.LogMessage{Raw: []byte("raw line\n")},
+			expected: "raw line\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewLogfmtFormatter()
+			f.KeyOrder = tt.keyOrder
+			data, err := f.Format(tt.msg)
+			if err != nil {
+				t.Fatalf("Format failed: %v", err)
+			}
+			if string(data) != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, string(data))
+			}
+		})
+	}
+}
+
+func TestLogfmtFormatter_Factory(t *testing.T) {
+	formatter, err := CreateFormatter("logfmt")
+	if err != nil {
+		t.Fatalf("CreateFormatter failed: %v", err)
+	}
+	if _, ok := formatter.(*LogfmtFormatter); !ok {
+		t.Errorf("Expected *LogfmtFormatter, got %T", formatter)
+	}
+
+	if formatter, err := CreateFormatterByType(FormatLogfmt); err != nil || formatter == nil {
+		t.Errorf("CreateFormatterByType failed: %v", err)
+	}
+}
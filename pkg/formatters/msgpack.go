@@ -0,0 +1,98 @@
+package formatters
+
+import (
+	"time"
+
+	"github.com/wayneeseguin/omni/internal/msgpack"
+	"github.com/wayneeseguin/omni/pkg/types"
+)
+
+// MsgpackFormatter formats log messages as MessagePack maps in the binary
+// schema described by the Binary*Key constants. The timestamp is written as
+// the MessagePack timestamp extension (-1), the level as an integer, and
+// fields keep their integer, float, boolean, binary and time types, with
+// times written as timestamp extensions too.
+//
+// Entries are not delimited: each is one self-contained MessagePack value,
+// suited to message-oriented destinations such as NATS or Redis, or to
+// files read back with DecodeMsgpackEntry.
+type MsgpackFormatter struct{}
+
+// NewMsgpackFormatter creates a new MessagePack formatter
+func NewMsgpackFormatter() *MsgpackFormatter {
+	return &MsgpackFormatter{}
+}
+
+// Format formats a log message as a MessagePack map
+func (f *MsgpackFormatter) Format(msg types.LogMessage) ([]byte, error) {
+	// Handle raw bytes - pass through as-is
+	if msg.Raw != nil {
+		return msg.Raw, nil
+	}
+
+	r := newBinaryRecord(msg)
+	b := msgpack.AppendMapHeader(make([]byte, 0, 128), r.size())
+	if !r.time.IsZero() {
+		b = msgpack.AppendTimestamp(msgpack.AppendString(b, BinaryTimeKey), r.time)
+	}
+	b = msgpack.AppendInt(msgpack.AppendString(b, BinaryLevelKey), int64(r.level))
+	b = msgpack.AppendString(msgpack.AppendString(b, BinaryMessageKey), r.message)
+	if len(r.fields) > 0 {
+		b = appendMsgpackField(msgpack.AppendString(b, BinaryFieldsKey), r.fields, 0)
+	}
+	if r.stackTrace != "" {
+		b = msgpack.AppendString(msgpack.AppendString(b, BinaryStackKey), r.stackTrace)
+	}
+	if r.file != "" {
+		b = msgpack.AppendString(msgpack.AppendString(b, BinaryFileKey), r.file)
+	}
+	if r.line > 0 {
+		b = msgpack.AppendInt(msgpack.AppendString(b, BinaryLineKey), int64(r.line))
+	}
+	if len(r.metadata) > 0 {
+		b = appendMsgpackField(msgpack.AppendString(b, BinaryMetadataKey), r.metadata, 0)
+	}
+	return b, nil
+}
+
+// appendMsgpackField appends a field value, writing times as timestamp
+// extensions at any depth
+func appendMsgpackField(b []byte, value interface{}, depth int) []byte {
+	if depth > binaryMaxDepth {
+		return msgpack.AppendString(b, "[max depth exceeded]")
+	}
+	switch v := value.(type) {
+	case time.Time:
+		return msgpack.AppendTimestamp(b, v)
+	case map[string]interface{}:
+		b = msgpack.AppendMapHeader(b, len(v))
+		for key, item := range v {
+			b = appendMsgpackField(msgpack.AppendString(b, key), item, depth+1)
+		}
+		return b
+	case []interface{}:
+		b = msgpack.AppendArrayHeader(b, len(v))
+		for _, item := range v {
+			b = appendMsgpackField(b, item, depth+1)
+		}
+		return b
+	default:
+		return msgpack.AppendValue(b, value)
+	}
+}
+
+// DecodeMsgpackEntry decodes the first entry written by MsgpackFormatter in
+// data and returns it with the remaining bytes. Fields keep their decoded
+// types: integers are int64, floats float64, binary data []byte and times
+// time.Time. Errors wrap io.ErrUnexpectedEOF when data ends within the entry.
+func DecodeMsgpackEntry(data []byte) (*types.LogEntry, []byte, error) {
+	value, rest, err := msgpack.Decode(data)
+	if err != nil {
+		return nil, data, err
+	}
+	entry, err := binaryEntry("msgpack", value)
+	if err != nil {
+		return nil, data, err
+	}
+	return entry, rest, nil
+}
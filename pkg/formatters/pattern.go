@@ -0,0 +1,466 @@
+package formatters
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/wayneeseguin/omni/pkg/types"
+)
+
+// DefaultPattern is the layout of PatternFormatter when none is given
+const DefaultPattern = "%d %-5level %msg %fields%n"
+
+// patternTimeLayouts maps the named %d layouts of log4j and logback to Go
+// layouts
+var patternTimeLayouts = map[string]string{
+	"ISO8601":       "2006-01-02T15:04:05,000",
+	"ISO8601_BASIC": "20060102T150405,000",
+	"DEFAULT":       "2006-01-02 15:04:05,000",
+	"ABSOLUTE":      "15:04:05,000",
+	"DATE":          "02 Jan 2006 15:04:05,000",
+	"COMPACT":       "20060102150405000",
+	"RFC3339":       time.RFC3339,
+	"RFC3339NANO":   time.RFC3339Nano,
+}
+
+// patternEvent is a log message reduced to the values layouts refer to
+type patternEvent struct {
+	timestamp time.Time
+	timeText  string // Entry timestamp that is not RFC 3339, written as is
+	level     string
+	message   string
+	fields    map[string]interface{}
+	file      string
+	line      int
+	stack     string
+}
+
+// patternConverter appends one value of an event
+type patternConverter func(f *PatternFormatter, b []byte, e *patternEvent) []byte
+
+// patternSegment is a literal or a converter with its width modifiers
+type patternSegment struct {
+	literal   string
+	convert   patternConverter
+	minWidth  int  // Pad to this many characters
+	padRight  bool // Pad after the value ("%-5level") instead of before it
+	maxWidth  int  // Truncate to this many characters; no limit when zero
+	keepStart bool // Truncate the end ("%.-10logger") instead of the start
+}
+
+// Pattern is a layout compiled into the segments it renders
+type Pattern struct {
+	layout   string
+	segments []patternSegment
+	named    map[string]bool // Fields written by name, left out of %fields
+	newline  bool            // Whether the layout has %n
+}
+
+// CompilePattern compiles a log4j-style layout such as
+// "%d{ISO8601} %-5level [%thread] %logger - %msg%n". Conversions are written
+// %[-][min][.[-]max]name{option}:
+//
+//	%d, %date{layout}{zone}     Timestamp, in a Go layout or ISO8601, ISO8601_BASIC,
+//	                            DEFAULT, ABSOLUTE, DATE, COMPACT, RFC3339, RFC3339NANO,
+//	                            UNIX or UNIX_MILLIS; FormatOptions.TimestampFormat
+//	                            without a layout
+//	%p, %le, %level             Level name
+//	%m, %msg, %message          Message
+//	%c, %lo, %logger            The "logger" field, or PatternFormatter.LoggerName
+//	%t, %thread                 The "thread" field
+//	%l, %caller                 Source file and line, as file.go:42
+//	%F, %file and %L, %line     Source file and line separately
+//	%X{name}, %field{name}      One field
+//	%X, %fields                 Fields not written by name, as sorted key=value pairs
+//	%host, %hostname and %pid   Host name and process ID
+//	%ex, %stack                 Stack trace
+//	%n and %%                   Newline and percent sign
+//
+// A minimum width pads values with spaces, before them unless the width is
+// preceded by '-'. A maximum width truncates values from the start, or from
+// the end when preceded by '-'.
+func CompilePattern(layout string) (*Pattern, error) {
+	p := &Pattern{layout: layout, named: make(map[string]bool)}
+
+	var literal strings.Builder
+	flush := func() {
+		if literal.Len() > 0 {
+			p.segments = append(p.segments, patternSegment{literal: literal.String()})
+			literal.Reset()
+		}
+	}
+
+	for i := 0; i < len(layout); {
+		c := layout[i]
+		if c != '%' {
+			literal.WriteByte(c)
+			i++
+			continue
+		}
+		i++
+		if i < len(layout) && layout[i] == '%' {
+			literal.WriteByte('%')
+			i++
+			continue
+		}
+
+		var seg patternSegment
+		if i < len(layout) && layout[i] == '-' {
+			seg.padRight = true
+			i++
+		}
+		seg.minWidth, i = patternNumber(layout, i)
+		if i < len(layout) && layout[i] == '.' {
+			i++
+			if i < len(layout) && layout[i] == '-' {
+				seg.keepStart = true
+				i++
+			}
+			seg.maxWidth, i = patternNumber(layout, i)
+			if seg.maxWidth == 0 {
+				return nil, fmt.Errorf("pattern %q: missing maximum width at offset %d", layout, i)
+			}
+		}
+
+		start := i
+		for i < len(layout) && (layout[i] >= 'a' && layout[i] <= 'z' || layout[i] >= 'A' && layout[i] <= 'Z' || layout[i] == '_') {
+			i++
+		}
+		name := layout[start:i]
+		if name == "" {
+			return nil, fmt.Errorf("pattern %q: missing conversion name at offset %d", layout, start)
+		}
+
+		var options []string
+		for i < len(layout) && layout[i] == '{' {
+			end := strings.IndexByte(layout[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("pattern %q: unterminated option at offset %d", layout, i)
+			}
+			options = append(options, layout[i+1:i+end])
+			i += end + 1
+		}
+
+		if name == "n" {
+			literal.WriteByte('\n')
+			p.newline = true
+			continue
+		}
+		convert, err := p.converter(name, options)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %q: %w", layout, err)
+		}
+		flush()
+		seg.convert = convert
+		p.segments = append(p.segments, seg)
+	}
+	flush()
+
+	return p, nil
+}
+
+// patternNumber parses the digits at layout[i:]
+func patternNumber(layout string, i int) (int, int) {
+	n := 0
+	for i < len(layout) && layout[i] >= '0' && layout[i] <= '9' {
+		n = n*10 + int(layout[i]-'0')
+		i++
+	}
+	return n, i
+}
+
+// converter returns the converter of a conversion name and its options
+func (p *Pattern) converter(name string, options []string) (patternConverter, error) {
+	option := func(n int) string {
+		if n < len(options) {
+			return options[n]
+		}
+		return ""
+	}
+
+	switch name {
+	case "d", "date":
+		return patternTime(option(0), option(1))
+	case "p", "le", "level":
+		return func(f *PatternFormatter, b []byte, e *patternEvent) []byte {
+			return append(b, f.formatLevel(e.level)...)
+		}, nil
+	case "m", "msg", "message":
+		return func(f *PatternFormatter, b []byte, e *patternEvent) []byte {
+			return append(b, e.message...)
+		}, nil
+	case "c", "lo", "logger":
+		p.named["logger"] = true
+		return func(f *PatternFormatter, b []byte, e *patternEvent) []byte {
+			if value, ok := e.fields["logger"]; ok {
+				return append(b, LogfmtValue(value)...)
+			}
+			if f.LoggerName != "" {
+				return append(b, f.LoggerName...)
+			}
+			return append(b, filepath.Base(getProcessName())...)
+		}, nil
+	case "t", "thread":
+		return p.fieldConverter("thread"), nil
+	case "l", "caller":
+		return func(f *PatternFormatter, b []byte, e *patternEvent) []byte {
+			if e.file == "" {
+				return append(b, '?')
+			}
+			b = append(b, filepath.Base(e.file)...)
+			b = append(b, ':')
+			return strconv.AppendInt(b, int64(e.line), 10)
+		}, nil
+	case "F", "file":
+		return func(f *PatternFormatter, b []byte, e *patternEvent) []byte {
+			return append(b, e.file...)
+		}, nil
+	case "L", "line":
+		return func(f *PatternFormatter, b []byte, e *patternEvent) []byte {
+			if e.file == "" {
+				return b
+			}
+			return strconv.AppendInt(b, int64(e.line), 10)
+		}, nil
+	case "X", "field", "fields":
+		if name == "fields" || name == "X" && len(options) == 0 {
+			return patternFields, nil
+		}
+		if option(0) == "" {
+			return nil, fmt.Errorf("%%%s requires a field name", name)
+		}
+		return p.fieldConverter(option(0)), nil
+	case "host", "hostname":
+		return func(f *PatternFormatter, b []byte, e *patternEvent) []byte {
+			return append(b, getHostname()...)
+		}, nil
+	case "pid":
+		return func(f *PatternFormatter, b []byte, e *patternEvent) []byte {
+			return strconv.AppendInt(b, int64(getPID()), 10)
+		}, nil
+	case "ex", "stack":
+		return func(f *PatternFormatter, b []byte, e *patternEvent) []byte {
+			return append(b, strings.TrimRight(e.stack, "\n")...)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown conversion %%%s", name)
+	}
+}
+
+// fieldConverter returns a converter writing one field, which %fields
+// leaves out
+func (p *Pattern) fieldConverter(name string) patternConverter {
+	p.named[name] = true
+	return func(f *PatternFormatter, b []byte, e *patternEvent) []byte {
+		if value, ok := e.fields[name]; ok {
+			return append(b, LogfmtValue(value)...)
+		}
+		return b
+	}
+}
+
+// patternFields writes the fields not written by name as key=value pairs
+func patternFields(f *PatternFormatter, b []byte, e *patternEvent) []byte {
+	keys := make([]string, 0, len(e.fields))
+	for key := range e.fields {
+		if !f.pattern.named[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	// AppendLogfmtPair separates pairs from anything before them
+	var pairs []byte
+	for _, key := range keys {
+		pairs = AppendLogfmtPair(pairs, key, LogfmtValue(e.fields[key]))
+	}
+	return append(b, pairs...)
+}
+
+// patternTime returns the converter of %d with a layout and time zone
+func patternTime(layout, zone string) (patternConverter, error) {
+	var location *time.Location
+	if zone != "" {
+		var err error
+		if location, err = time.LoadLocation(zone); err != nil {
+			return nil, fmt.Errorf("%%d time zone: %w", err)
+		}
+	}
+	if named, ok := patternTimeLayouts[layout]; ok {
+		layout = named
+	}
+
+	return func(f *PatternFormatter, b []byte, e *patternEvent) []byte {
+		if e.timestamp.IsZero() {
+			return append(b, e.timeText...)
+		}
+		t := e.timestamp
+		if location != nil {
+			t = t.In(location)
+		} else if f.Options.TimeZone != nil {
+			t = t.In(f.Options.TimeZone)
+		}
+		switch layout {
+		case "":
+			if f.Options.TimestampFormat == "" {
+				return t.AppendFormat(b, time.RFC3339)
+			}
+			return t.AppendFormat(b, f.Options.TimestampFormat)
+		case "UNIX":
+			return strconv.AppendInt(b, t.Unix(), 10)
+		case "UNIX_MILLIS":
+			return strconv.AppendInt(b, t.UnixMilli(), 10)
+		default:
+			return t.AppendFormat(b, layout)
+		}
+	}, nil
+}
+
+// String returns the layout the pattern was compiled from
+func (p *Pattern) String() string {
+	return p.layout
+}
+
+// PatternFormatter formats log messages with a compiled layout, to match the
+// line format of existing log4j or logback configurations:
+//
+//	%d{ISO8601} %-5level [%thread] %logger - %msg%n
+//	2024-05-01T12:00:00,000 INFO  [worker-1] billing - invoice sent
+//
+// See CompilePattern for the conversions. A newline is added to each line
+// when the layout has no %n.
+type PatternFormatter struct {
+	Options    FormatOptions // TimestampFormat of %d without a layout, TimeZone and LevelFormat
+	LoggerName string        // %logger of entries without a "logger" field; the process name when empty
+
+	pattern *Pattern
+}
+
+// NewPatternFormatter creates a new pattern formatter, compiling layout or
+// DefaultPattern when it is empty
+func NewPatternFormatter(layout string) (*PatternFormatter, error) {
+	f := &PatternFormatter{
+		Options: DefaultFormatOptions(),
+	}
+	if err := f.SetPattern(layout); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// SetPattern compiles and uses layout, or DefaultPattern when it is empty
+func (f *PatternFormatter) SetPattern(layout string) error {
+	if layout == "" {
+		layout = DefaultPattern
+	}
+	pattern, err := CompilePattern(layout)
+	if err != nil {
+		return err
+	}
+	f.pattern = pattern
+	return nil
+}
+
+// Pattern returns the compiled layout
+func (f *PatternFormatter) Pattern() *Pattern {
+	return f.pattern
+}
+
+// Format formats a log message with the layout
+func (f *PatternFormatter) Format(msg types.LogMessage) ([]byte, error) {
+	// Handle raw bytes - pass through as-is
+	if msg.Raw != nil {
+		return msg.Raw, nil
+	}
+
+	e := patternEvent{
+		timestamp: msg.Timestamp,
+		level:     levelToString(msg.Level),
+	}
+	if msg.Entry != nil {
+		if e.timestamp.IsZero() {
+			if parsed, err := time.Parse(time.RFC3339Nano, msg.Entry.Timestamp); err == nil {
+				e.timestamp = parsed
+			} else {
+				e.timeText = msg.Entry.Timestamp
+			}
+		}
+		if msg.Entry.Level != "" {
+			e.level = strings.ToUpper(msg.Entry.Level)
+		}
+		e.message = msg.Entry.Message
+		e.fields = msg.Entry.Fields
+		e.file = msg.Entry.File
+		e.line = msg.Entry.Line
+		e.stack = msg.Entry.StackTrace
+	} else {
+		e.message = msg.Format
+		if len(msg.Args) > 0 {
+			e.message = fmt.Sprintf(msg.Format, msg.Args...)
+		}
+	}
+
+	b := make([]byte, 0, 128)
+	for i := range f.pattern.segments {
+		seg := &f.pattern.segments[i]
+		if seg.convert == nil {
+			b = append(b, seg.literal...)
+			continue
+		}
+		start := len(b)
+		b = seg.convert(f, b, &e)
+		if seg.minWidth > 0 || seg.maxWidth > 0 {
+			b = seg.fit(b, start)
+		}
+	}
+	if !f.pattern.newline {
+		b = append(b, '\n')
+	}
+
+	return b, nil
+}
+
+// fit pads or truncates the value written from b[start:] to the widths of
+// the segment
+func (seg *patternSegment) fit(b []byte, start int) []byte {
+	value := string(b[start:])
+	b = b[:start]
+
+	n := utf8.RuneCountInString(value)
+	if seg.maxWidth > 0 && n > seg.maxWidth {
+		runes := []rune(value)
+		if seg.keepStart {
+			value = string(runes[:seg.maxWidth])
+		} else {
+			value = string(runes[n-seg.maxWidth:])
+		}
+		n = seg.maxWidth
+	}
+
+	if n < seg.minWidth && !seg.padRight {
+		b = append(b, strings.Repeat(" ", seg.minWidth-n)...)
+	}
+	b = append(b, value...)
+	if n < seg.minWidth && seg.padRight {
+		b = append(b, strings.Repeat(" ", seg.minWidth-n)...)
+	}
+	return b
+}
+
+// formatLevel applies FormatOptions.LevelFormat to a level name
+func (f *PatternFormatter) formatLevel(level string) string {
+	switch f.Options.LevelFormat {
+	case LevelFormatNameLower:
+		return strings.ToLower(level)
+	case LevelFormatSymbol:
+		if len(level) > 0 {
+			return level[:1]
+		}
+	}
+	return level
+}
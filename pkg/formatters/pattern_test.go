@@ -0,0 +1,122 @@
+package formatters
+
+import (
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/types"
+)
+
+func TestPatternFormatter_Format(t *testing.T) {
+	timestamp := time.Date(2024, 5, 1, 12, 0, 0, 250e6, time.UTC)
+	entry := &types.LogEntry{
+		Level:   "info",
+		Message: "invoice sent",
+		File:    "/app/billing/invoice.go",
+		Line:    42,
+		Fields: map[string]interface{}{
+			"logger":   "billing",
+			"thread":   "worker-1",
+			"invoice":  1001,
+			"customer": "Ada Lovelace",
+		},
+	}
+
+	tests := []struct {
+		layout   string
+		msg      types.LogMessage
+		expected string
+	}{
+		{
+			layout:   "%d{ISO8601} %-5level [%thread] %logger - %msg%n",
+			msg:      types.LogMessage{Timestamp: timestamp, Entry: entry},
+			expected: "2024-05-01T12:00:00,250 INFO  [worker-1] billing - invoice sent\n",
+		},
+		{
+			layout:   "%d{ABSOLUTE} %5p %.3c %l %msg | %fields",
+			msg:      types.LogMessage{Timestamp: timestamp, Entry: entry},
+			expected: "12:00:00,250  INFO ing invoice.go:42 invoice sent | customer=\"Ada Lovelace\" invoice=1001 thread=worker-1\n",
+		},
+		{
+			layout:   "%d{UNIX_MILLIS} [%-8.-3X{customer}] %X{missing}%F:%L %%%n",
+			msg:      types.LogMessage{Timestamp: timestamp, Entry: entry},
+			expected: "1714564800250 [Ada     ] /app/billing/invoice.go:42 %\n",
+		},
+		{
+			layout:   "%d{15:04:05.000}{America/New_York} %level %msg %l%n",
+			msg:      types.LogMessage{Timestamp: timestamp, Level: LevelWarn, Format: "disk %d%% full", Args: []interface{}{91}},
+			expected: "08:00:00.250 WARN disk 91% full ?\n",
+		},
+		{
+			layout:   "%d %level %msg%n%ex",
+			msg:      types.LogMessage{Entry: &types.LogEntry{Timestamp: "yesterday", Level: "error", Message: "failed", StackTrace: "main.main()\n"}},
+			expected: "yesterday ERROR failed\nmain.main()",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.layout, func(t *testing.T) {
+			f, err := NewPatternFormatter(tt.layout)
+			if err != nil {
+				t.Fatalf("NewPatternFormatter failed: %v", err)
+			}
+			data, err := f.Format(tt.msg)
+			if err != nil {
+				t.Fatalf("Format failed: %v", err)
+			}
+			if string(data) != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, string(data))
+			}
+		})
+	}
+}
+
+func TestPatternFormatter_Defaults(t *testing.T) {
+	f, err := NewPatternFormatter("")
+	if err != nil {
+		t.Fatalf("NewPatternFormatter failed: %v", err)
+	}
+	if f.Pattern().String() != DefaultPattern {
+		t.Errorf("Expected default pattern %q, got %q", DefaultPattern, f.Pattern())
+	}
+	f.Options.LevelFormat = LevelFormatNameLower
+
+	data, err := f.Format(types.LogMessage{
+		Timestamp: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Entry:     &types.LogEntry{Level: "DEBUG", Message: "m", Fields: map[string]interface{}{"a": 1}},
+	})
+	if err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+	if expected := "2024-05-01T12:00:00Z debug m a=1\n"; string(data) != expected {
+		t.Errorf("Expected %q, got %q", expected, string(data))
+	}
+
+	// Host and process
+	if err := f.SetPattern("%host %pid"); err != nil {
+		t.Fatalf("SetPattern failed: %v", err)
+	}
+	hostname, _ := os.Hostname()
+	data, _ = f.Format(types.LogMessage{Format: "m"})
+	if expected := hostname + " " + strconv.Itoa(os.Getpid()) + "\n"; string(data) != expected {
+		t.Errorf("Expected %q, got %q", expected, string(data))
+	}
+}
+
+func TestCompilePattern_Errors(t *testing.T) {
+	for _, layout := range []string{
+		"%",
+		"%-5",
+		"%unknown",
+		"%X{}",
+		"%d{ISO8601",
+		"%d{ISO8601}{Not/AZone}",
+		"%.level",
+	} {
+		if _, err := CompilePattern(layout); err == nil {
+			t.Errorf("Expected error for %q", layout)
+		}
+	}
+}
@@ -10,7 +10,11 @@ import (
 
 // TextFormatter formats log messages as human-readable text
 type TextFormatter struct {
-	Options FormatOptions
+	Options       FormatOptions
+	IncludeFields []string // Optional: specific fields to include
+	ExcludeFields []string // Optional: fields to exclude
+	FieldOrder    []string // Optional: fields written first, in this order; the rest are sorted
+	Indent        string   // Optional: writes stack traces on the following lines, indented by it
 }
 
 // NewTextFormatter creates a new text formatter
@@ -86,18 +90,24 @@ func (f *TextFormatter) formatStructuredEntry(entry *types.LogEntry) ([]byte, er
 	result.WriteString(entry.Message)
 
 	// Add fields
-	if len(entry.Fields) > 0 {
+	fields := make(map[string]interface{}, len(entry.Fields))
+	for k, v := range entry.Fields {
+		if !excludeField(k, f.IncludeFields, f.ExcludeFields) {
+			fields[k] = v
+		}
+	}
+	if len(fields) > 0 {
 		result.WriteString(" ")
-		for k, v := range entry.Fields {
+		for _, k := range orderedKeys(fields, f.FieldOrder) {
 			result.WriteString(k)
 			result.WriteString("=")
-			result.WriteString(fmt.Sprintf("%v", v))
+			result.WriteString(fmt.Sprintf("%v", fields[k]))
 			result.WriteString(" ")
 		}
 	}
 
-	// Add stack trace if present
-	if entry.StackTrace != "" {
+	// Add stack trace if present, inline or on indented lines of its own
+	if entry.StackTrace != "" && f.Indent == "" {
 		result.WriteString("stack_trace=")
 		result.WriteString(entry.StackTrace)
 		result.WriteString(" ")
@@ -106,6 +116,14 @@ func (f *TextFormatter) formatStructuredEntry(entry *types.LogEntry) ([]byte, er
 	// Ensure newline at end
 	result.WriteString("\n")
 
+	if entry.StackTrace != "" && f.Indent != "" {
+		for _, line := range strings.Split(strings.TrimRight(entry.StackTrace, "\n"), "\n") {
+			result.WriteString(f.Indent)
+			result.WriteString(line)
+			result.WriteString("\n")
+		}
+	}
+
 	return []byte(result.String()), nil
 }
 
@@ -148,15 +166,46 @@ func (f *TextFormatter) formatLevel(level int) string {
 	return levelStr
 }
 
-// FormatFields formats fields as key=value pairs
+// FormatBatch formats messages as consecutive lines
+func (f *TextFormatter) FormatBatch(messages []types.LogMessage) ([]byte, error) {
+	var result []byte
+	for _, msg := range messages {
+		data, err := f.Format(msg)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, data...)
+	}
+	return result, nil
+}
+
+// SetFieldOrder sets the fields written first, in this order. Other fields
+// follow in sorted order.
+func (f *TextFormatter) SetFieldOrder(fields []string) {
+	f.FieldOrder = fields
+}
+
+// SetFieldFilter sets the entry fields to include and exclude
+func (f *TextFormatter) SetFieldFilter(include []string, exclude []string) {
+	f.IncludeFields = include
+	f.ExcludeFields = exclude
+}
+
+// SetIndentation writes stack traces on the lines after their entry,
+// indented by indent, rather than as a stack_trace field
+func (f *TextFormatter) SetIndentation(indent string) {
+	f.Indent = indent
+}
+
+// FormatFields formats fields as key=value pairs in FieldOrder
 func (f *TextFormatter) FormatFields(fields map[string]interface{}) string {
 	if len(fields) == 0 {
 		return ""
 	}
 
 	var parts []string
-	for k, v := range fields {
-		parts = append(parts, fmt.Sprintf("%s=%v", k, v))
+	for _, k := range orderedKeys(fields, f.FieldOrder) {
+		parts = append(parts, fmt.Sprintf("%s=%v", k, fields[k]))
 	}
 
 	return strings.Join(parts, " ")
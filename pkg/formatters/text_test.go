@@ -455,3 +455,57 @@ func TestTextFormatter_NilFields(t *testing.T) {
 		t.Errorf("expected valid_field=value in output, got %s", string(result))
 	}
 }
+
+func TestTextFormatter_FieldOrder(t *testing.T) {
+	f := NewTextFormatter()
+	f.Options.IncludeTime = false
+	f.SetFieldOrder([]string{"user"})
+	f.SetFieldFilter(nil, []string{"password"})
+
+	msg := types.LogMessage{Entry: &types.LogEntry{
+		Level:      "ERROR",
+		Message:    "login failed",
+		Fields:     map[string]interface{}{"zone": "eu", "user": "ann", "password": "x", "attempt": 2},
+		StackTrace: "main.go:10\nlogin.go:20\n",
+	}}
+	result, err := f.Format(msg)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	expected := "[ERROR] login failed user=ann attempt=2 zone=eu stack_trace=main.go:10\nlogin.go:20\n \n"
+	if string(result) != expected {
+		t.Errorf("expected %q, got %q", expected, result)
+	}
+
+	// Indentation moves the stack trace onto lines of its own
+	f.SetFieldFilter([]string{"zone"}, nil)
+	f.SetIndentation("    ")
+	result, err = f.Format(msg)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	expected = "[ERROR] login failed zone=eu \n    main.go:10\n    login.go:20\n"
+	if string(result) != expected {
+		t.Errorf("expected %q, got %q", expected, result)
+	}
+
+	if got := f.FormatFields(map[string]interface{}{"b": 1, "user": "ann", "a": 2}); got != "user=ann a=2 b=1" {
+		t.Errorf("FormatFields() = %q", got)
+	}
+}
+
+func TestTextFormatter_FormatBatch(t *testing.T) {
+	f := NewTextFormatter()
+	f.Options.IncludeTime = false
+
+	result, err := f.FormatBatch([]types.LogMessage{
+		{Level: LevelInfo, Format: "first"},
+		{Level: LevelWarn, Format: "second"},
+	})
+	if err != nil {
+		t.Fatalf("FormatBatch() error = %v", err)
+	}
+	if expected := "[INFO] first\n[WARN] second\n"; string(result) != expected {
+		t.Errorf("expected %q, got %q", expected, result)
+	}
+}
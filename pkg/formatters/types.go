@@ -14,9 +14,17 @@ type FormatOptions struct {
 	IndentJSON      bool
 	FieldSeparator  string
 	TimeZone        *time.Location
-	FlattenFields   bool // Whether to flatten nested fields in JSON output
-	IncludeSource   bool // Whether to include source field
-	IncludeHost     bool // Whether to include hostname field
+	FlattenFields   bool           // Whether to flatten nested fields in JSON output
+	IncludeSource   bool           // Whether to include source field
+	IncludeHost     bool           // Whether to include hostname field
+	JSONPreset      JSONPreset     // Vendor schema of JSON output
+	JSONKeys        JSONKeys       // JSON key names, overriding the preset's
+	JSONLevelStyle  JSONLevelStyle // JSON level values, overriding the preset's
+	GCPProject      string         // Google Cloud project of trace IDs written by JSONPresetGCP
+	Pattern         string         // Layout of the pattern format, such as "%d{ISO8601} %-5level %msg%n"
+	JSONArray       bool           // Whether JSON files hold one array of entries rather than one entry per line
+	CSVColumns      []string       // Columns of CSV and TSV output; DefaultCSVColumns when empty
+	CSVExtra        CSVExtra       // What CSV and TSV output does with fields outside CSVColumns
 }
 
 // LevelFormat defines level format options
@@ -148,8 +148,11 @@ func (a *LoggerAdapter) WithError(err error) Logger {
 
 // WithContext returns a new logger with context values
 func (a *LoggerAdapter) WithContext(ctx context.Context) Logger {
-	// For now, just return self - context handling can be added later
-	return a
+	fields := traceFields(ctx)
+	if fields == nil {
+		return a
+	}
+	return a.WithFields(fields)
 }
 
 // IsTraceEnabled returns true if trace level is enabled
@@ -198,70 +201,72 @@ type ContextLogger struct {
 	ctx    context.Context
 }
 
+// adapter returns a logger adding the context's trace fields to each entry
+func (c *ContextLogger) adapter() *LoggerAdapter {
+	return &LoggerAdapter{
+		logger: c.logger,
+		fields: traceFields(c.ctx),
+	}
+}
+
 // Trace logs a message at trace level
 func (c *ContextLogger) Trace(args ...interface{}) {
-	c.logger.Trace(args...)
+	c.adapter().Trace(args...)
 }
 
 // Debug logs a message at debug level
 func (c *ContextLogger) Debug(args ...interface{}) {
-	c.logger.Debug(args...)
+	c.adapter().Debug(args...)
 }
 
 // Info logs a message at info level
 func (c *ContextLogger) Info(args ...interface{}) {
-	c.logger.Info(args...)
+	c.adapter().Info(args...)
 }
 
 // Warn logs a message at warn level
 func (c *ContextLogger) Warn(args ...interface{}) {
-	c.logger.Warn(args...)
+	c.adapter().Warn(args...)
 }
 
 // Error logs a message at error level
 func (c *ContextLogger) Error(args ...interface{}) {
-	c.logger.Error(args...)
+	c.adapter().Error(args...)
 }
 
 // Tracef logs a formatted message at trace level
 func (c *ContextLogger) Tracef(format string, args ...interface{}) {
-	c.logger.Tracef(format, args...)
+	c.adapter().Tracef(format, args...)
 }
 
 // Debugf logs a formatted message at debug level
 func (c *ContextLogger) Debugf(format string, args ...interface{}) {
-	c.logger.Debugf(format, args...)
+	c.adapter().Debugf(format, args...)
 }
 
 // Infof logs a formatted message at info level
 func (c *ContextLogger) Infof(format string, args ...interface{}) {
-	c.logger.Infof(format, args...)
+	c.adapter().Infof(format, args...)
 }
 
 // Warnf logs a formatted message at warn level
 func (c *ContextLogger) Warnf(format string, args ...interface{}) {
-	c.logger.Warnf(format, args...)
+	c.adapter().Warnf(format, args...)
 }
 
 // Errorf logs a formatted message at error level
 func (c *ContextLogger) Errorf(format string, args ...interface{}) {
-	c.logger.Errorf(format, args...)
+	c.adapter().Errorf(format, args...)
 }
 
 // WithField returns a new logger with an additional field
 func (c *ContextLogger) WithField(key string, value interface{}) Logger {
-	return &LoggerAdapter{
-		logger: c.logger,
-		fields: map[string]interface{}{key: value},
-	}
+	return c.adapter().WithField(key, value)
 }
 
 // WithFields returns a new logger with additional fields
 func (c *ContextLogger) WithFields(fields map[string]interface{}) Logger {
-	return &LoggerAdapter{
-		logger: c.logger,
-		fields: fields,
-	}
+	return c.adapter().WithFields(fields)
 }
 
 // WithError returns a new logger with an error field
@@ -1,7 +1,12 @@
 package omni
 
 import (
+	"errors"
 	"time"
+
+	"github.com/wayneeseguin/omni/pkg/backends"
+	"github.com/wayneeseguin/omni/pkg/features"
+	"github.com/wayneeseguin/omni/pkg/formatters"
 )
 
 // Config contains all configuration options for Omni.
@@ -12,7 +17,7 @@ type Config struct {
 	// Core settings
 	Path          string        // Primary log file path
 	Level         int           // Minimum log level
-	Format        int           // Output format (text/json)
+	Format        int           // Output format (text/json/gelf/logfmt/cef/leef/console/pattern/msgpack/cbor/csv/tsv)
 	FormatOptions FormatOptions // Format-specific options
 	ChannelSize   int           // Message channel buffer size
 
@@ -23,9 +28,20 @@ type Config struct {
 	CleanupInterval time.Duration // Interval for age-based cleanup
 
 	// Compression settings
-	Compression     int // Compression type (none/gzip)
-	CompressMinAge  int // Minimum rotations before compression
-	CompressWorkers int // Number of compression workers
+	Compression      int // Compression type (none/gzip/zstd)
+	CompressionLevel int // Compression level (0 uses the algorithm default)
+	CompressMinAge   int // Minimum rotations before compression
+	CompressWorkers  int // Number of compression workers
+
+	// Stream compression settings
+	StreamCompression   int           // Compress the active file in-line (none/gzip/zstd)
+	StreamFlushInterval time.Duration // How often to complete a compressed frame
+
+	// Rotation manifest settings
+	RotationManifest bool // Record rotated files in a manifest next to each log
+
+	// Disk space settings
+	DiskSpace *DiskSpaceConfig // Disk budgets and free-space monitoring for file destinations
 
 	// Error handling
 	ErrorHandler ErrorHandler // Custom error handler
@@ -132,6 +148,8 @@ func DefaultConfig() *Config {
 // - MaxFiles >= 0
 // - CleanupInterval >= 1 minute
 // - CompressWorkers > 0
+// - CompressionLevel within the range of the compression type
+// - StreamCompression and DiskSpace are not both set
 // - StackSize > 0
 // - SamplingRate between 0.0 and 1.0
 func (c *Config) Validate() error {
@@ -155,6 +173,20 @@ func (c *Config) Validate() error {
 		c.CompressWorkers = 1
 	}
 
+	if features.ValidateCompressionLevel(features.CompressionType(c.Compression), c.CompressionLevel) != nil {
+		c.CompressionLevel = features.CompressionLevelDefault
+	}
+
+	if c.StreamCompression != CompressionNone && c.StreamFlushInterval <= 0 {
+		c.StreamFlushInterval = backends.DefaultFrameFlushInterval
+	}
+
+	if c.StreamCompression != CompressionNone && c.DiskSpace != nil {
+		// Stream-compressed files are written by a backend without disk space monitoring
+		return NewOmniError(ErrCodeInvalidConfig, "config", "",
+			errors.New("stream compression cannot be combined with disk space limits"))
+	}
+
 	if c.StackSize <= 0 {
 		c.StackSize = 4096
 	}
@@ -168,7 +200,24 @@ func (c *Config) Validate() error {
 	}
 
 	if c.FormatOptions.TimestampFormat == "" {
-		c.FormatOptions = DefaultFormatOptions()
+		// Keep the pattern and JSON schema, which are set on their own
+		options := DefaultFormatOptions()
+		options.Pattern = c.FormatOptions.Pattern
+		options.JSONPreset = c.FormatOptions.JSONPreset
+		options.JSONKeys = c.FormatOptions.JSONKeys
+		options.JSONLevelStyle = c.FormatOptions.JSONLevelStyle
+		options.GCPProject = c.FormatOptions.GCPProject
+		options.JSONArray = c.FormatOptions.JSONArray
+		options.CSVColumns = c.FormatOptions.CSVColumns
+		options.CSVExtra = c.FormatOptions.CSVExtra
+		c.FormatOptions = options
+	}
+
+	if c.Format == FormatPattern && c.FormatOptions.Pattern != "" {
+		if _, err := formatters.CompilePattern(c.FormatOptions.Pattern); err != nil {
+			return NewOmniError(ErrCodeInvalidFormat, "config", "", err).
+				WithContext("pattern", c.FormatOptions.Pattern)
+		}
 	}
 
 	if c.SampleKeyFunc == nil {
@@ -208,31 +257,35 @@ func NewWithConfig(config *Config) (*Omni, error) {
 
 	// Create logger instance
 	f := &Omni{
-		maxSize:          config.MaxSize,
-		maxFiles:         config.MaxFiles,
-		level:            config.Level,
-		format:           config.Format,
-		includeTrace:     config.IncludeTrace,
-		stackSize:        config.StackSize,
-		captureAll:       config.CaptureAll,
-		formatOptions:    config.FormatOptions,
-		compression:      config.Compression,
-		compressMinAge:   config.CompressMinAge,
-		compressWorkers:  config.CompressWorkers,
-		compressCh:       nil,
-		maxAge:           config.MaxAge,
-		cleanupInterval:  config.CleanupInterval,
-		cleanupTicker:    nil,
-		cleanupDone:      nil,
-		filters:          nil,
-		samplingStrategy: config.SamplingStrategy,
-		samplingRate:     config.SamplingRate,
-		sampleCounter:    0,
-		sampleKeyFunc:    config.SampleKeyFunc,
-		msgChan:          make(chan LogMessage, config.ChannelSize),
-		channelSize:      config.ChannelSize,
-		Destinations:     make([]*Destination, 0),
-		messageQueue:     make(chan *LogMessage, config.ChannelSize),
+		maxSize:             config.MaxSize,
+		maxFiles:            config.MaxFiles,
+		level:               config.Level,
+		format:              config.Format,
+		includeTrace:        config.IncludeTrace,
+		stackSize:           config.StackSize,
+		captureAll:          config.CaptureAll,
+		formatOptions:       config.FormatOptions,
+		compression:         config.Compression,
+		compressLevel:       config.CompressionLevel,
+		compressMinAge:      config.CompressMinAge,
+		streamCompression:   config.StreamCompression,
+		streamFlushInterval: config.StreamFlushInterval,
+		rotationManifest:    config.RotationManifest,
+		compressWorkers:     config.CompressWorkers,
+		compressCh:          nil,
+		maxAge:              config.MaxAge,
+		cleanupInterval:     config.CleanupInterval,
+		cleanupTicker:       nil,
+		cleanupDone:         nil,
+		filters:             nil,
+		samplingStrategy:    config.SamplingStrategy,
+		samplingRate:        config.SamplingRate,
+		sampleCounter:       0,
+		sampleKeyFunc:       config.SampleKeyFunc,
+		msgChan:             make(chan LogMessage, config.ChannelSize),
+		channelSize:         config.ChannelSize,
+		Destinations:        make([]*Destination, 0),
+		messageQueue:        make(chan *LogMessage, config.ChannelSize),
 		// errorHandler will be set below
 		// messagesByLevel and errorsBySource are sync.Map, no initialization needed
 	}
@@ -252,6 +305,20 @@ func NewWithConfig(config *Config) (*Omni, error) {
 	// Set formatter if provided
 	if config.Formatter != nil {
 		f.formatter = config.Formatter
+	} else if config.Format != FormatText && config.Format != FormatJSON {
+		// Text and JSON are formatted with the configured format options
+		if formatter, err := newFormatter(config.Format); err == nil {
+			if err := applyFormatOptions(formatter, config.FormatOptions); err != nil {
+				return nil, err
+			}
+			f.formatter = formatter
+		}
+	}
+
+	// Disk space monitoring must be known before file destinations are created
+	if config.DiskSpace != nil {
+		diskSpace := *config.DiskSpace
+		f.diskSpace = &diskSpace
 	}
 
 	// Add primary destination if path provided
@@ -322,6 +389,11 @@ func NewWithConfig(config *Config) (*Omni, error) {
 		f.SetMaxFiles(config.MaxFiles)
 	}
 
+	// Record rotated files in a manifest if requested
+	if config.RotationManifest {
+		f.SetRotationManifest(true)
+	}
+
 	// Start cleanup routine if max age is set
 	if config.MaxAge > 0 {
 		f.startCleanupRoutine()
@@ -347,18 +419,22 @@ func (f *Omni) GetConfig() *Config {
 	defer f.mu.RUnlock()
 
 	config := &Config{
-		Path:            f.path,
-		Level:           f.level,
-		Format:          f.format,
-		FormatOptions:   f.formatOptions,
-		ChannelSize:     f.channelSize,
-		MaxSize:         f.maxSize,
-		MaxFiles:        f.maxFiles,
-		MaxAge:          f.maxAge,
-		CleanupInterval: f.cleanupInterval,
-		Compression:     f.compression,
-		CompressMinAge:  f.compressMinAge,
-		CompressWorkers: f.compressWorkers,
+		Path:                f.path,
+		Level:               f.level,
+		Format:              f.format,
+		FormatOptions:       f.formatOptions,
+		ChannelSize:         f.channelSize,
+		MaxSize:             f.maxSize,
+		MaxFiles:            f.maxFiles,
+		MaxAge:              f.maxAge,
+		CleanupInterval:     f.cleanupInterval,
+		Compression:         f.compression,
+		CompressionLevel:    f.compressLevel,
+		CompressMinAge:      f.compressMinAge,
+		CompressWorkers:     f.compressWorkers,
+		StreamCompression:   f.streamCompression,
+		StreamFlushInterval: f.streamFlushInterval,
+		RotationManifest:    f.rotationManifest,
 		// ErrorHandler cannot be easily converted back
 		IncludeTrace:     f.includeTrace,
 		StackSize:        f.stackSize,
@@ -368,6 +444,11 @@ func (f *Omni) GetConfig() *Config {
 		SampleKeyFunc:    f.sampleKeyFunc,
 	}
 
+	if f.diskSpace != nil {
+		diskSpace := *f.diskSpace
+		config.DiskSpace = &diskSpace
+	}
+
 	// Get redaction patterns if set
 	if f.redactor != nil {
 		config.RedactionPatterns = f.redactionPatterns
@@ -433,10 +514,11 @@ func (f *Omni) UpdateConfig(config *Config) error {
 	defer f.mu.Unlock()
 
 	// Update compression settings
-	if f.compression != config.Compression {
+	if f.compression != config.Compression || f.compressLevel != config.CompressionLevel {
 		if f.compression == CompressionNone && config.Compression != CompressionNone {
 			// Starting compression
 			f.compression = config.Compression
+			f.compressLevel = config.CompressionLevel
 			f.compressMinAge = config.CompressMinAge
 			f.compressWorkers = config.CompressWorkers
 			f.startCompressionWorkers()
@@ -445,9 +527,26 @@ func (f *Omni) UpdateConfig(config *Config) error {
 			f.stopCompressionWorkers()
 			f.compression = CompressionNone
 		} else {
-			// Changing compression type
+			// Changing compression type or level
 			f.compression = config.Compression
+			f.compressLevel = config.CompressionLevel
 			f.compressMinAge = config.CompressMinAge
+			if f.compressionManager != nil {
+				if err := f.compressionManager.SetCompression(features.CompressionType(f.compression)); err != nil {
+					return err
+				}
+				if err := f.compressionManager.SetLevel(f.compressLevel); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	// Update rotation manifest setting
+	if f.rotationManifest != config.RotationManifest {
+		f.rotationManifest = config.RotationManifest
+		if f.rotationManager != nil {
+			f.rotationManager.SetManifestEnabled(config.RotationManifest)
 		}
 	}
 
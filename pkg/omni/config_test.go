@@ -1,11 +1,19 @@
 package omni
 
 import (
+	"encoding/json"
+	"io"
+	"math"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/wayneeseguin/omni/pkg/backends"
+	"github.com/wayneeseguin/omni/pkg/features"
+	"github.com/wayneeseguin/omni/pkg/formatters"
 )
 
 func TestDefaultConfig(t *testing.T) {
@@ -353,3 +361,674 @@ func TestConfigNoPath(t *testing.T) {
 	logger.Info("test message")
 	time.Sleep(100 * time.Millisecond)
 }
+
+func TestConfigDiskSpace(t *testing.T) {
+	if _, err := backends.GetDiskUsage(os.TempDir()); err != nil {
+		t.Skipf("Disk usage not available: %v", err)
+	}
+
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "test.log")
+
+	config := DefaultConfig()
+	config.Path = logFile
+	config.Level = LevelDebug
+	config.DiskSpace = &DiskSpaceConfig{
+		MinFreeBytes: math.MaxUint64, // Always low on space
+		Policy:       LowSpaceShedDebug,
+	}
+
+	logger, err := NewWithConfig(config)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+
+	backend, ok := logger.Destinations[0].GetBackend().(*backends.FileBackendWithRotation)
+	if !ok {
+		t.Fatalf("Expected disk space aware backend, got %T", logger.Destinations[0].GetBackend())
+	}
+	_ = backend.CheckDiskSpace()
+
+	logger.Debug("shed debug message")
+	logger.Info("kept info message")
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Failed to close logger: %v", err)
+	}
+
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	if strings.Contains(string(content), "shed debug message") {
+		t.Error("Expected debug message to be shed while low on space")
+	}
+	if !strings.Contains(string(content), "kept info message") {
+		t.Error("Expected info message to be written while low on space")
+	}
+
+	if got := logger.GetConfig().DiskSpace; got == nil || got.Policy != LowSpaceShedDebug {
+		t.Errorf("Expected disk space config to round-trip, got %+v", got)
+	}
+}
+
+func TestDiskSpaceRotationKeepsCleanup(t *testing.T) {
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "test.log")
+
+	logger, err := NewWithOptions(
+		WithPath(logFile),
+		WithDiskBudget(1<<30, 0),
+		WithMaxAge(time.Hour),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	// Each rotation replaces the backend; closing the old one must leave the
+	// path registered for cleanup
+	for i := 0; i < 2; i++ {
+		logger.Infof("message %d", i)
+		if err := logger.FlushAll(); err != nil {
+			t.Fatalf("Failed to flush: %v", err)
+		}
+		if err := logger.rotateDestination(logger.Destinations[0]); err != nil {
+			t.Fatalf("Rotation %d failed: %v", i, err)
+		}
+		time.Sleep(2 * time.Millisecond) // Distinct rotation timestamps
+	}
+
+	paths := logger.rotationManager.GetLogPaths()
+	found := false
+	for _, path := range paths {
+		found = found || path == logFile
+	}
+	if !found {
+		t.Fatalf("Expected %s to stay registered for cleanup, got %v", logFile, paths)
+	}
+
+	if rotated, err := filepath.Glob(logFile + ".*"); err != nil || len(rotated) != 2 {
+		t.Fatalf("Expected 2 rotated files, got %v (%v)", rotated, err)
+	}
+
+	// Expire both rotated files
+	if err := logger.SetMaxAge(time.Millisecond); err != nil {
+		t.Fatalf("Failed to set max age: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// Run a cleanup pass over the registered paths as the background routine does
+	for _, path := range paths {
+		if err := logger.rotationManager.RunCleanup(path); err != nil {
+			t.Fatalf("Cleanup failed: %v", err)
+		}
+	}
+	if remaining, _ := filepath.Glob(logFile + ".*"); len(remaining) != 0 {
+		t.Errorf("Expected expired files to be removed, still have %v", remaining)
+	}
+}
+
+func TestDiskSpaceBackendRotation(t *testing.T) {
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "test.log")
+
+	logger, err := NewWithOptions(
+		WithPath(logFile),
+		WithJSON(),
+		WithDiskBudget(1<<30, 0),
+		WithRotation(1<<20, 5),
+		WithGzipCompression(),
+		WithRotationManifest(),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	backend, ok := logger.Destinations[0].GetBackend().(*backends.FileBackendWithRotation)
+	if !ok {
+		t.Fatalf("Expected disk space aware backend, got %T", logger.Destinations[0].GetBackend())
+	}
+
+	for i := 0; i < 10; i++ {
+		logger.Infof("before rotation %d", i)
+	}
+	if err := logger.Sync(); err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+
+	// Rotate the way the backend does when it runs short of space
+	if err := backend.Rotate(); err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+
+	// The rotated file is recorded and compressed like the logger's own rotations
+	var compressed []string
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		compressed, _ = filepath.Glob(logFile + ".*.gz")
+		if len(compressed) == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(compressed) != 1 {
+		t.Fatalf("Expected the rotated file to be compressed, got %v", compressed)
+	}
+	manifest, err := features.ReadManifest(logFile)
+	if err != nil || len(manifest.Files) != 1 || manifest.Files[0].EntryCount != 10 {
+		t.Fatalf("Expected the rotation in the manifest, got %+v (%v)", manifest, err)
+	}
+
+	// The destination's size follows the new file
+	logger.Info("after rotation")
+	if err := logger.Sync(); err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+	dest := logger.Destinations[0]
+	dest.mu.Lock()
+	size := dest.Size
+	dest.mu.Unlock()
+	if size != backend.Size() {
+		t.Errorf("Expected destination size %d to match the backend, got %d", backend.Size(), size)
+	}
+}
+
+func TestWithDiskSpace(t *testing.T) {
+	config := DefaultConfig()
+
+	if err := WithDiskBudget(1024, 4096)(config); err != nil {
+		t.Fatalf("WithDiskBudget failed: %v", err)
+	}
+	if config.DiskSpace == nil || config.DiskSpace.MaxTotalBytes != 1024 || config.DiskSpace.MaxDirBytes != 4096 {
+		t.Errorf("Unexpected disk space config: %+v", config.DiskSpace)
+	}
+
+	invalid := []DiskSpaceConfig{
+		{MaxTotalBytes: -1},
+		{MinFreePercent: 150},
+		{Policy: LowSpaceFallback},
+	}
+	for _, diskSpace := range invalid {
+		if err := WithDiskSpace(diskSpace)(DefaultConfig()); err == nil {
+			t.Errorf("Expected error for %+v", diskSpace)
+		}
+	}
+}
+
+func TestConfigZstdCompression(t *testing.T) {
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "test.log")
+
+	logger, err := NewWithOptions(
+		WithPath(logFile),
+		WithRotation(512, 5),
+		WithZstdCompression(),
+		WithCompressionLevel(9),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	if got := logger.GetConfig(); got.Compression != CompressionZstd || got.CompressionLevel != 9 {
+		t.Errorf("Expected zstd level 9, got type %d level %d", got.Compression, got.CompressionLevel)
+	}
+
+	for i := 0; i < 50; i++ {
+		logger.Infof("zstd compression test message %d", i)
+	}
+	if err := logger.Sync(); err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+
+	// Rotated files are compressed asynchronously
+	deadline := time.Now().Add(5 * time.Second)
+	var compressed []string
+	for time.Now().Before(deadline) {
+		compressed, _ = filepath.Glob(logFile + ".*" + CompressSuffixZstd)
+		if len(compressed) > 0 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if len(compressed) == 0 {
+		t.Fatal("Expected rotated files to be compressed with zstd")
+	}
+
+	if err := WithCompressionLevel(30)(&Config{Compression: CompressionZstd}); err == nil {
+		t.Error("Expected error for out of range zstd level")
+	}
+	if err := logger.SetCompressionLevel(3); err != nil {
+		t.Errorf("Failed to set compression level: %v", err)
+	}
+}
+
+func TestConfigCloseWhileCompressing(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "test.log")
+
+	logger, err := NewWithOptions(
+		WithPath(logFile),
+		WithRotation(512, 20),
+		WithGzipCompression(),
+		WithRotationManifest(),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+
+	// Hold the first compression job until Close is waiting for it
+	running := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	logger.compressionManager.SetMetricsHandler(func(string) {
+		once.Do(func() {
+			close(running)
+			<-release
+		})
+	})
+
+	for i := 0; i < 20; i++ {
+		logger.Infof("compression during close %d", i)
+	}
+	if err := logger.Sync(); err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+	select {
+	case <-running:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a rotated file to be compressed")
+	}
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- logger.Close()
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Errorf("Failed to close: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Close deadlocked with a compression job running")
+	}
+}
+
+func TestConfigRotationManifest(t *testing.T) {
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "test.log")
+
+	logger, err := NewWithOptions(
+		WithPath(logFile),
+		WithJSON(),
+		WithRotation(512, 5),
+		WithRotationManifest(),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	if !logger.GetConfig().RotationManifest {
+		t.Error("Expected RotationManifest in config")
+	}
+
+	for i := 0; i < 20; i++ {
+		logger.Infof("manifest message %d", i)
+	}
+	if err := logger.Sync(); err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+
+	var manifest *features.Manifest
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		manifest, err = features.ReadManifest(logFile)
+		if err == nil && len(manifest.Files) > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if manifest == nil || len(manifest.Files) == 0 {
+		t.Fatalf("Expected rotated files in manifest, got %+v (%v)", manifest, err)
+	}
+
+	entry := manifest.Files[0]
+	if entry.EntryCount == 0 || entry.LevelCounts["info"] != entry.EntryCount || entry.FirstTimestamp.IsZero() {
+		t.Errorf("Unexpected manifest entry: %+v", entry)
+	}
+	if err := features.VerifyManifest(logFile); err != nil {
+		t.Errorf("VerifyManifest failed: %v", err)
+	}
+}
+
+func TestConfigJSONArray(t *testing.T) {
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "test.json")
+
+	logger, err := NewWithOptions(
+		WithPath(logFile),
+		WithJSONArray(),
+		WithRotation(512, 20),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	// Rotating once, as rotations within a millisecond share a file name
+	for i := 0; i < 8; i++ {
+		logger.Infof("array message %d", i)
+	}
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+
+	// Reopening continues the array of the active file
+	logger, err = NewWithOptions(WithPath(logFile), WithJSONArray())
+	if err != nil {
+		t.Fatalf("Failed to reopen logger: %v", err)
+	}
+	logger.Info("after restart")
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+
+	files, _ := filepath.Glob(logFile + "*")
+	if len(files) != 2 {
+		t.Fatalf("Expected the active and a rotated file, got %v", files)
+	}
+	total := 0
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		var entries []map[string]interface{}
+		if err := json.Unmarshal(data, &entries); err != nil {
+			t.Fatalf("%s is not a JSON array: %v\n%s", file, err, data)
+		}
+		total += len(entries)
+		if file == logFile && entries[len(entries)-1]["message"] != "after restart" {
+			t.Errorf("Expected the restarted entry last in %s", data)
+		}
+	}
+	if total != 9 {
+		t.Errorf("Expected 9 entries across %d files, got %d", len(files), total)
+	}
+
+	// Stream-compressed files cannot be reopened as arrays
+	_, err = NewWithOptions(
+		WithPath(filepath.Join(dir, "compressed.json")),
+		WithJSONArray(),
+		WithStreamCompression(CompressionGzip, time.Hour),
+	)
+	if err == nil {
+		t.Error("Expected an error for a stream-compressed JSON array")
+	}
+}
+
+func TestConfigCSV(t *testing.T) {
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "test.csv")
+
+	logger, err := NewWithOptions(
+		WithPath(logFile),
+		WithCSV("level", "user", "message"),
+		WithRotation(256, 20),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	// Rotating once, as rotations within a millisecond share a file name
+	for i := 0; i < 8; i++ {
+		logger.InfoWithFields("signed in", map[string]interface{}{"user": "ada", "attempt": i})
+	}
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+
+	// Reopening a file with rows adds no second header
+	logger, err = NewWithOptions(WithPath(logFile), WithCSV("level", "user", "message"))
+	if err != nil {
+		t.Fatalf("Failed to reopen logger: %v", err)
+	}
+	logger.Info("after restart")
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+
+	files, _ := filepath.Glob(logFile + "*")
+	if len(files) != 2 {
+		t.Fatalf("Expected the active and a rotated file, got %v", files)
+	}
+	const header = "level,user,message,fields"
+	rows := 0
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		if lines[0] != header {
+			t.Errorf("Expected %s to start with the header, got %q", file, lines[0])
+		}
+		for _, line := range lines[1:] {
+			if line == header {
+				t.Errorf("Unexpected second header in %s", file)
+			}
+		}
+		rows += len(lines) - 1
+	}
+	if rows != 9 {
+		t.Errorf("Expected 9 rows across %d files, got %d", len(files), rows)
+	}
+
+	if _, err := NewWithOptions(WithPath(filepath.Join(dir, "bad.csv")), WithTSV("level", "")); err == nil {
+		t.Error("Expected an error for an empty column name")
+	}
+}
+
+func TestConfigStreamCompression(t *testing.T) {
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "test.log")
+
+	logger, err := NewWithOptions(
+		WithPath(logFile),
+		WithRotation(64*1024, 5),
+		WithStreamCompression(CompressionZstd, time.Hour),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	logger.Info("stream compressed message")
+	if err := logger.Sync(); err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+
+	reader, err := features.OpenLogFile(logFile)
+	if err != nil {
+		t.Fatalf("Failed to open log file: %v", err)
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	if !strings.Contains(string(content), "stream compressed message") {
+		t.Errorf("Expected message in decompressed stream, got %q", content)
+	}
+
+	if err := WithStreamCompression(CompressionNone, 0)(DefaultConfig()); err == nil {
+		t.Error("Expected error for stream compression without a type")
+	}
+
+	// Stream-compressed files cannot enforce disk budgets
+	_, err = NewWithOptions(
+		WithPath(filepath.Join(dir, "budget.log")),
+		WithStreamCompression(CompressionGzip, 0),
+		WithDiskBudget(1<<20, 0),
+	)
+	if err == nil || !strings.Contains(err.Error(), "disk space") {
+		t.Errorf("Expected stream compression with a disk budget to be rejected, got %v", err)
+	}
+}
+
+func TestConfigJSONPreset(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "test.log")
+
+	logger, err := NewWithOptions(
+		WithPath(logFile),
+		WithJSONPreset(JSONPresetECS),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	logger.WarnWithFields("slow request", map[string]interface{}{"trace_id": "abc", "path": "/a"})
+	if err := logger.Sync(); err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal(content, &entry); err != nil {
+		t.Fatalf("Failed to parse %q: %v", content, err)
+	}
+	for key, expected := range map[string]interface{}{
+		"log.level": "warn",
+		"message":   "slow request",
+		"trace.id":  "abc",
+		"path":      "/a",
+	} {
+		if entry[key] != expected {
+			t.Errorf("Expected %s=%v, got %v in %s", key, expected, entry[key], content)
+		}
+	}
+	if _, ok := entry["@timestamp"]; !ok {
+		t.Errorf("Expected @timestamp in %s", content)
+	}
+
+	if err := WithJSONPreset(JSONPreset(99))(DefaultConfig()); err == nil {
+		t.Error("Expected error for unknown JSON preset")
+	}
+}
+
+func TestConfigGCPProject(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "test.log")
+
+	logger, err := NewWithOptions(
+		WithPath(logFile),
+		WithGCPProject("my-project"),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	logger.InfoWithFields("traced", map[string]interface{}{"trace_id": "4bf92f3577b34da6a3ce929d0e0e4736"})
+	if err := logger.Sync(); err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal(content, &entry); err != nil {
+		t.Fatalf("Failed to parse %q: %v", content, err)
+	}
+	if trace := entry["logging.googleapis.com/trace"]; trace != "projects/my-project/traces/4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Unexpected trace %v in %s", trace, content)
+	}
+
+	if err := WithGCPProject("")(DefaultConfig()); err == nil {
+		t.Error("Expected error for an empty GCP project")
+	}
+}
+
+func TestConfigDevelopmentDefaults(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "test.log")
+
+	logger, err := NewWithOptions(
+		WithPath(logFile),
+		WithDevelopmentDefaults(),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	if logger.GetFormat() != FormatConsole {
+		t.Fatalf("Expected console format, got %d", logger.GetFormat())
+	}
+	if _, ok := logger.GetFormatter().(*formatters.ConsoleFormatter); !ok {
+		t.Fatalf("Expected a console formatter, got %T", logger.GetFormatter())
+	}
+
+	logger.DebugWithFields("cache miss", map[string]interface{}{"path": "/users/42"})
+	if err := logger.Sync(); err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	// The log file is not a terminal, so the output is not coloured and
+	// carries absolute timestamps
+	line := string(content)
+	if !strings.Contains(line, " DEBUG cache miss ") || !strings.HasSuffix(line, " path=/users/42\n") || strings.Contains(line, "\x1b[") {
+		t.Errorf("Unexpected console line %q", line)
+	}
+	timestamp, _, _ := strings.Cut(line, " ")
+	if _, err := time.Parse(formatters.DefaultConsoleFileTimeFormat, timestamp); err != nil {
+		t.Errorf("Expected an absolute timestamp in %q: %v", line, err)
+	}
+}
+
+func TestConfigPattern(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "test.log")
+
+	logger, err := NewWithOptions(
+		WithPath(logFile),
+		WithPattern("%-5level [%thread] %logger - %msg {%fields}%n"),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	logger.InfoWithFields("invoice sent", map[string]interface{}{"logger": "billing", "thread": "worker-1", "invoice": 1001})
+	if err := logger.Sync(); err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	if expected := "INFO  [worker-1] billing - invoice sent {invoice=1001}\n"; string(content) != expected {
+		t.Errorf("Expected %q, got %q", expected, content)
+	}
+
+	if err := WithPattern("%bogus")(DefaultConfig()); err == nil {
+		t.Error("Expected error for unknown conversion")
+	}
+	config := DefaultConfig()
+	config.Path = filepath.Join(t.TempDir(), "invalid.log")
+	config.Format = FormatPattern
+	config.FormatOptions.Pattern = "%d{ISO8601"
+	if _, err := NewWithConfig(config); err == nil {
+		t.Error("Expected error for unterminated option")
+	}
+}
@@ -38,12 +38,45 @@ const (
 	// FormatCustom specifies a custom output format provided by a plugin.
 	// Requires a custom formatter implementation to be registered.
 	FormatCustom = 2
+	// FormatGELF specifies GELF 1.1 output format for Graylog.
+	// Messages are formatted as GELF JSON objects with fields as additional fields.
+	FormatGELF = 3
+	// FormatLogfmt specifies logfmt output format.
+	// Messages are formatted as key=value pairs with nested fields as dotted keys.
+	FormatLogfmt = 4
+	// FormatCEF specifies ArcSight Common Event Format (CEF:0) output.
+	// Messages are formatted as CEF events with fields as extension pairs.
+	FormatCEF = 5
+	// FormatLEEF specifies IBM QRadar LEEF 2.0 output.
+	// Messages are formatted as LEEF events with fields as attributes.
+	FormatLEEF = 6
+	// FormatConsole specifies coloured, aligned output for development.
+	// Messages are formatted with relative timestamps and indented nested fields.
+	FormatConsole = 7
+	// FormatPattern specifies output laid out by FormatOptions.Pattern.
+	// Messages are formatted with log4j-style conversions such as %d and %-5level.
+	FormatPattern = 8
+	// FormatMsgpack specifies MessagePack output for high-throughput destinations.
+	// Messages are formatted as binary maps with typed fields, decoded by formatters.DecodeMsgpackEntry.
+	FormatMsgpack = 9
+	// FormatCBOR specifies CBOR output for high-throughput destinations.
+	// Messages are formatted as binary maps with typed fields, decoded by formatters.DecodeCBOREntry.
+	FormatCBOR = 10
+	// FormatCSV specifies comma-separated rows for spreadsheets and warehouses.
+	// Messages are formatted in FormatOptions.CSVColumns, under a header row at the top of each file.
+	FormatCSV = 11
+	// FormatTSV specifies tab-separated rows for spreadsheets and warehouses.
+	// Messages are formatted as for FormatCSV with tabs between values.
+	FormatTSV = 12
 
 	// CompressionNone disables compression for rotated log files.
 	CompressionNone = 0
 	// CompressionGzip enables gzip compression for rotated log files.
 	// Rotated files will be compressed with gzip to save disk space.
 	CompressionGzip = 1
+	// CompressionZstd enables zstd compression for rotated log files.
+	// zstd compresses faster and smaller than gzip for typical log data.
+	CompressionZstd = 2
 
 	// SamplingNone disables log sampling - all messages are logged.
 	SamplingNone = 0
@@ -74,6 +107,33 @@ const (
 	// BackendPlugin specifies a plugin-based backend.
 	// Allows custom backends to be loaded as plugins.
 	BackendPlugin = 2
+	// BackendNetwork specifies a TCP, UDP or Unix socket backend.
+	// Streams entries to collectors such as Logstash or Vector.
+	BackendNetwork = 3
+	// BackendHTTP specifies an HTTP(S) batch backend.
+	// POSTs batches of entries to an ingestion endpoint.
+	BackendHTTP = 4
+	// BackendLoki specifies a Grafana Loki push API backend.
+	// Derives stream labels from entry fields and global fields.
+	BackendLoki = 5
+	// BackendElasticsearch specifies an Elasticsearch or OpenSearch bulk API backend.
+	// Indexes entries as documents in date-based indices or a data stream.
+	BackendElasticsearch = 6
+	// BackendSplunkHEC specifies a Splunk HTTP Event Collector backend.
+	// Sends event envelopes, optionally waiting for indexer acknowledgement.
+	BackendSplunkHEC = 7
+	// BackendOTLP specifies an OpenTelemetry OTLP/HTTP logs backend.
+	// Exports entries as log records to an OpenTelemetry collector.
+	BackendOTLP = 8
+	// BackendGELF specifies a Graylog GELF backend over UDP or TCP.
+	// Sends entries as GELF messages, chunking large UDP messages.
+	BackendGELF = 9
+	// BackendJournald specifies a systemd journal backend.
+	// Sends entries with their fields over the journal native protocol.
+	BackendJournald = 10
+	// BackendFluent specifies a Fluentd or Fluent Bit forward backend.
+	// Sends batches of entries over the Forward protocol with optional acks.
+	BackendFluent = 11
 
 	// SeverityLow represents minor errors that don't significantly impact operation.
 	// Use for errors that are automatically recoverable or have minimal impact.
@@ -135,5 +195,6 @@ const (
 	DefaultFileMode = 0644
 
 	// Compression suffixes
-	CompressSuffix = ".gz"
+	CompressSuffix     = ".gz"
+	CompressSuffixZstd = ".zst"
 )
@@ -12,7 +12,7 @@
 //   - Multiple output destinations (files, syslog)
 //   - Structured logging with key-value pairs
 //   - Automatic log rotation based on file size
-//   - Compression of rotated logs (gzip)
+//   - Compression of rotated logs (gzip, zstd)
 //   - Flexible filtering and sampling
 //   - Dynamic runtime configuration
 //   - Context-aware logging with trace IDs
@@ -0,0 +1,137 @@
+package omni
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/backends"
+)
+
+// elasticsearchMapPrefix marks query parameters that rename document fields
+const elasticsearchMapPrefix = "map."
+
+// elasticsearchSchemes maps Elasticsearch URI schemes to the cluster URL scheme
+var elasticsearchSchemes = map[string]string{
+	"elasticsearch":       "http",
+	"elasticsearch+https": "https",
+	"opensearch":          "http",
+	"opensearch+https":    "https",
+}
+
+// isElasticsearchURI reports whether uri names an Elasticsearch or OpenSearch destination
+func isElasticsearchURI(uri string) bool {
+	scheme, _, ok := strings.Cut(uri, "://")
+	if !ok {
+		return false
+	}
+	_, known := elasticsearchSchemes[scheme]
+	return known
+}
+
+// parseElasticsearchURI parses an Elasticsearch destination URI such as
+// "elasticsearch://es:9200?index=logs-app-%25Y.%25m.%25d&map.message=log.message"
+// or "elasticsearch+https://elastic:secret@es:9200?data_stream=logs-app-default".
+// The opensearch:// and opensearch+https:// schemes are equivalent.
+//
+// User info in the URI is sent as basic auth. Supported query parameters are
+// index (template with %Y, %m, %d and %H), data_stream, map.<field> (target
+// field name), api_key, gzip, batch, batch_bytes, interval, timeout, retries,
+// in_flight and ca.
+func parseElasticsearchURI(uri string) (backends.ElasticsearchConfig, error) {
+	var cfg backends.ElasticsearchConfig
+
+	scheme, _, _ := strings.Cut(uri, "://")
+	httpScheme, known := elasticsearchSchemes[scheme]
+	endpoint, err := url.Parse(uri)
+	if !known || err != nil || endpoint.Host == "" {
+		return cfg, fmt.Errorf("invalid Elasticsearch URI %q", uri)
+	}
+	endpoint.Scheme = httpScheme
+
+	if endpoint.User != nil {
+		cfg.Username = endpoint.User.Username()
+		cfg.Password, _ = endpoint.User.Password()
+		endpoint.User = nil
+	}
+
+	var caFile string
+	for key, values := range endpoint.Query() {
+		value := values[len(values)-1]
+		switch {
+		case strings.HasPrefix(key, elasticsearchMapPrefix):
+			if cfg.FieldMapping == nil {
+				cfg.FieldMapping = make(map[string]string)
+			}
+			cfg.FieldMapping[strings.TrimPrefix(key, elasticsearchMapPrefix)] = value
+		case key == "index":
+			cfg.Index = value
+		case key == "data_stream":
+			cfg.DataStream = value
+		case key == "api_key":
+			cfg.APIKey = value
+		case key == "gzip":
+			if cfg.Compress, err = strconv.ParseBool(value); err != nil {
+				return cfg, fmt.Errorf("invalid Elasticsearch gzip value %q", value)
+			}
+		case key == "batch":
+			if cfg.BatchSize, err = strconv.Atoi(value); err != nil || cfg.BatchSize <= 0 {
+				return cfg, fmt.Errorf("invalid Elasticsearch batch size %q", value)
+			}
+		case key == "batch_bytes":
+			if cfg.BatchBytes, err = strconv.Atoi(value); err != nil || cfg.BatchBytes <= 0 {
+				return cfg, fmt.Errorf("invalid Elasticsearch batch bytes %q", value)
+			}
+		case key == "interval":
+			if cfg.FlushInterval, err = time.ParseDuration(value); err != nil || cfg.FlushInterval <= 0 {
+				return cfg, fmt.Errorf("invalid Elasticsearch flush interval %q", value)
+			}
+		case key == "timeout":
+			if cfg.Timeout, err = time.ParseDuration(value); err != nil || cfg.Timeout <= 0 {
+				return cfg, fmt.Errorf("invalid Elasticsearch timeout %q", value)
+			}
+		case key == "retries":
+			if cfg.MaxRetries, err = strconv.Atoi(value); err != nil || cfg.MaxRetries < 0 {
+				return cfg, fmt.Errorf("invalid Elasticsearch retries %q", value)
+			}
+			if cfg.MaxRetries == 0 {
+				cfg.MaxRetries = -1
+			}
+		case key == "in_flight":
+			if cfg.MaxInFlight, err = strconv.Atoi(value); err != nil || cfg.MaxInFlight <= 0 {
+				return cfg, fmt.Errorf("invalid Elasticsearch in_flight %q", value)
+			}
+		case key == "ca":
+			caFile = value
+		default:
+			return cfg, fmt.Errorf("unknown Elasticsearch URI parameter %q", key)
+		}
+	}
+	endpoint.RawQuery = ""
+	cfg.URL = endpoint.String()
+
+	if cfg.Index != "" && cfg.DataStream != "" {
+		return cfg, fmt.Errorf("elasticsearch URI %q: index and data_stream are exclusive", uri)
+	}
+	if caFile != "" {
+		if httpScheme != "https" {
+			return cfg, fmt.Errorf("elasticsearch URI %q: ca needs the %s+https scheme", uri, scheme)
+		}
+		if cfg.TLSConfig, err = loadTLSConfig(caFile, "", "", ""); err != nil {
+			return cfg, err
+		}
+	}
+
+	return cfg, nil
+}
+
+// createElasticsearchBackend creates an Elasticsearch backend configured from its URI
+func createElasticsearchBackend(uri string) (backends.Backend, error) {
+	cfg, err := parseElasticsearchURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	return backends.NewElasticsearchBackend(cfg)
+}
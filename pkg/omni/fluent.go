@@ -0,0 +1,147 @@
+package omni
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/backends"
+)
+
+// fluentSchemes maps Fluent forward URI schemes to their transport
+var fluentSchemes = map[string]string{
+	"fluent":      "tcp",
+	"fluent+tcp":  "tcp",
+	"fluent+tls":  backends.NetworkTLS,
+	"fluent+unix": "unix",
+}
+
+// isFluentURI reports whether uri names a Fluentd or Fluent Bit forward
+// destination: fluent://, fluent+tcp://, fluent+tls:// or fluent+unix://
+func isFluentURI(uri string) bool {
+	scheme, _, ok := strings.Cut(uri, "://")
+	if !ok {
+		return false
+	}
+	_, known := fluentSchemes[scheme]
+	return known
+}
+
+// parseFluentURI parses a Fluent forward destination URI such as
+// "fluent://fluentd:24224?tag=app&ack=true" or
+// "fluent+unix:///var/run/fluent.sock?tag_field=service".
+//
+// Supported query parameters are tag, tag_field, mode (packed or forward),
+// ack, ack_timeout, time_as_integer, batch, interval, heartbeat, buffer,
+// connect_timeout, timeout (write timeout), ca, cert, key and server_name.
+func parseFluentURI(uri string) (backends.FluentConfig, error) {
+	var cfg backends.FluentConfig
+
+	scheme, rest, ok := strings.Cut(uri, "://")
+	network, known := fluentSchemes[scheme]
+	if !ok || !known {
+		return cfg, fmt.Errorf("invalid Fluent URI %q", uri)
+	}
+	cfg.Network = network
+
+	address, rawQuery, _ := strings.Cut(rest, "?")
+	if network == "unix" {
+		if !strings.HasPrefix(address, "/") {
+			return cfg, fmt.Errorf("fluent URI %q: socket path must be absolute", uri)
+		}
+	} else if _, _, err := net.SplitHostPort(address); err != nil {
+		return cfg, fmt.Errorf("fluent URI %q: address needs host and port", uri)
+	}
+	cfg.Address = address
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return cfg, fmt.Errorf("invalid Fluent URI query: %w", err)
+	}
+	var caFile, certFile, keyFile, serverName string
+	for key, values := range query {
+		value := values[len(values)-1]
+		switch key {
+		case "tag":
+			cfg.Tag = value
+		case "tag_field":
+			cfg.TagField = value
+		case "mode":
+			if cfg.Mode, err = backends.ParseFluentMode(value); err != nil {
+				return cfg, err
+			}
+		case "ack":
+			if cfg.Ack, err = strconv.ParseBool(value); err != nil {
+				return cfg, fmt.Errorf("invalid Fluent ack %q", value)
+			}
+		case "ack_timeout":
+			if cfg.AckTimeout, err = time.ParseDuration(value); err != nil || cfg.AckTimeout <= 0 {
+				return cfg, fmt.Errorf("invalid Fluent ack timeout %q", value)
+			}
+		case "time_as_integer":
+			if cfg.TimeAsInteger, err = strconv.ParseBool(value); err != nil {
+				return cfg, fmt.Errorf("invalid Fluent time_as_integer %q", value)
+			}
+		case "batch":
+			if cfg.BatchSize, err = strconv.Atoi(value); err != nil || cfg.BatchSize <= 0 {
+				return cfg, fmt.Errorf("invalid Fluent batch size %q", value)
+			}
+		case "interval":
+			if cfg.FlushInterval, err = time.ParseDuration(value); err != nil || cfg.FlushInterval <= 0 {
+				return cfg, fmt.Errorf("invalid Fluent flush interval %q", value)
+			}
+		case "heartbeat":
+			if cfg.Heartbeat, err = time.ParseDuration(value); err != nil || cfg.Heartbeat <= 0 {
+				return cfg, fmt.Errorf("invalid Fluent heartbeat interval %q", value)
+			}
+		case "buffer":
+			if cfg.BufferSize, err = strconv.Atoi(value); err != nil || cfg.BufferSize <= 0 {
+				return cfg, fmt.Errorf("invalid Fluent buffer size %q", value)
+			}
+		case "connect_timeout":
+			if cfg.DialTimeout, err = time.ParseDuration(value); err != nil || cfg.DialTimeout <= 0 {
+				return cfg, fmt.Errorf("invalid Fluent connect timeout %q", value)
+			}
+		case "timeout":
+			if cfg.WriteTimeout, err = time.ParseDuration(value); err != nil || cfg.WriteTimeout <= 0 {
+				return cfg, fmt.Errorf("invalid Fluent timeout %q", value)
+			}
+		case "ca":
+			caFile = value
+		case "cert":
+			certFile = value
+		case "key":
+			keyFile = value
+		case "server_name":
+			serverName = value
+		default:
+			return cfg, fmt.Errorf("unknown Fluent URI parameter %q", key)
+		}
+	}
+
+	if (caFile != "" || certFile != "" || serverName != "") && cfg.Network != backends.NetworkTLS {
+		return cfg, fmt.Errorf("fluent URI %q: TLS parameters need the fluent+tls scheme", uri)
+	}
+	if (certFile == "") != (keyFile == "") {
+		return cfg, fmt.Errorf("fluent URI %q: cert and key must be given together", uri)
+	}
+	if cfg.Network == backends.NetworkTLS {
+		if cfg.TLSConfig, err = loadTLSConfig(caFile, certFile, keyFile, serverName); err != nil {
+			return cfg, err
+		}
+	}
+
+	return cfg, nil
+}
+
+// createFluentBackend creates a Fluent forward backend configured from its URI
+func createFluentBackend(uri string) (backends.Backend, error) {
+	cfg, err := parseFluentURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	return backends.NewFluentBackend(cfg)
+}
@@ -0,0 +1,131 @@
+package omni
+
+import (
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wayneeseguin/omni/internal/msgpack"
+	"github.com/wayneeseguin/omni/pkg/backends"
+)
+
+func TestParseFluentURI(t *testing.T) {
+	cfg, err := parseFluentURI("fluent://fluentd:24224?tag=app&tag_field=service&mode=forward&ack=true&ack_timeout=5s&time_as_integer=true&batch=100&interval=2s&heartbeat=10s")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := backends.FluentConfig{
+		Network:       "tcp",
+		Address:       "fluentd:24224",
+		Tag:           "app",
+		TagField:      "service",
+		Mode:          backends.FluentModeForward,
+		Ack:           true,
+		AckTimeout:    5 * time.Second,
+		TimeAsInteger: true,
+		BatchSize:     100,
+		FlushInterval: 2 * time.Second,
+		Heartbeat:     10 * time.Second,
+	}
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, cfg)
+	}
+
+	if cfg, err := parseFluentURI("fluent+unix:///var/run/fluent.sock"); err != nil || cfg.Network != "unix" || cfg.Address != "/var/run/fluent.sock" {
+		t.Errorf("Unexpected config %+v, %v", cfg, err)
+	}
+	if cfg, err := parseFluentURI("fluent+tls://fluentd:24224?server_name=fluentd.internal"); err != nil || cfg.Network != backends.NetworkTLS || cfg.TLSConfig == nil {
+		t.Errorf("Unexpected config %+v, %v", cfg, err)
+	}
+
+	for _, uri := range []string{
+		"fluent://fluentd",
+		"fluent+unix://relative.sock",
+		"fluent://fluentd:24224?mode=compressed",
+		"fluent://fluentd:24224?ack=maybe",
+		"fluent://fluentd:24224?batch=0",
+		"fluent://fluentd:24224?ca=/ca.pem",
+		"fluent+tls://fluentd:24224?cert=/cert.pem",
+		"fluent://fluentd:24224?level=info",
+	} {
+		if _, err := parseFluentURI(uri); err == nil {
+			t.Errorf("Expected error for %q", uri)
+		}
+	}
+}
+
+func TestFluentDestination(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	messages := make(chan []interface{}, 10)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var data []byte
+		buf := make([]byte, 4096)
+		for {
+			n, err := conn.Read(buf)
+			data = append(data, buf[:n]...)
+			for {
+				value, rest, decodeErr := msgpack.Decode(data)
+				if decodeErr != nil {
+					break
+				}
+				data = rest
+				message, _ := value.([]interface{})
+				messages <- message
+
+				// Acknowledge the chunk
+				option, _ := message[2].(map[string]interface{})
+				ack := msgpack.AppendMapHeader(nil, 1)
+				ack = msgpack.AppendString(msgpack.AppendString(ack, "ack"), option["chunk"].(string))
+				_, _ = conn.Write(ack)
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	logger, err := New(filepath.Join(t.TempDir(), "test.log"))
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	if err := logger.AddDestination("fluent://" + listener.Addr().String() + "?tag=app&mode=forward&ack=true"); err != nil {
+		t.Fatalf("Failed to add Fluent destination: %v", err)
+	}
+
+	logger.WarnWithFields("disk nearly full", map[string]interface{}{"mount": "/var"})
+	if err := logger.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	var message []interface{}
+	select {
+	case message = <-messages:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Timed out waiting for a message")
+	}
+
+	entries, _ := message[1].([]interface{})
+	if message[0] != "app" || len(entries) != 1 {
+		t.Fatalf("Unexpected message %v", message)
+	}
+	record := entries[0].([]interface{})[1].(map[string]interface{})
+	if record["message"] != "disk nearly full" || record["level"] != "warn" || record["mount"] != "/var" {
+		t.Errorf("Unexpected record %v", record)
+	}
+}
@@ -0,0 +1,123 @@
+package omni
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/backends"
+)
+
+// gelfSchemes maps GELF URI schemes to their transport
+var gelfSchemes = map[string]string{
+	"gelf+udp": "udp",
+	"gelf+tcp": "tcp",
+	"gelf+tls": backends.NetworkTLS,
+}
+
+// isGELFURI reports whether uri names a Graylog GELF destination:
+// gelf+udp://, gelf+tcp:// or gelf+tls://
+func isGELFURI(uri string) bool {
+	scheme, _, ok := strings.Cut(uri, "://")
+	if !ok {
+		return false
+	}
+	_, known := gelfSchemes[scheme]
+	return known
+}
+
+// parseGELFURI parses a GELF destination URI such as
+// "gelf+udp://graylog:12201?compress=gzip" or
+// "gelf+tls://graylog:12201?ca=/etc/ssl/ca.pem".
+//
+// Supported query parameters are host (source host), compress (none, gzip
+// or zlib; UDP only), chunk_size (UDP only), ca, cert, key, server_name,
+// connect_timeout, timeout (write timeout) and buffer.
+func parseGELFURI(uri string) (backends.GELFConfig, error) {
+	var cfg backends.GELFConfig
+
+	scheme, rest, ok := strings.Cut(uri, "://")
+	network, known := gelfSchemes[scheme]
+	if !ok || !known {
+		return cfg, fmt.Errorf("invalid GELF URI %q", uri)
+	}
+	cfg.Network = network
+
+	address, rawQuery, _ := strings.Cut(rest, "?")
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return cfg, fmt.Errorf("GELF URI %q: address needs host and port", uri)
+	}
+	cfg.Address = address
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return cfg, fmt.Errorf("invalid GELF URI query: %w", err)
+	}
+	var caFile, certFile, keyFile, serverName string
+	for key, values := range query {
+		value := values[len(values)-1]
+		switch key {
+		case "host":
+			cfg.Host = value
+		case "compress":
+			if cfg.Compression, err = backends.ParseGELFCompression(value); err != nil {
+				return cfg, err
+			}
+		case "chunk_size":
+			if cfg.ChunkSize, err = strconv.Atoi(value); err != nil || cfg.ChunkSize <= 0 {
+				return cfg, fmt.Errorf("invalid GELF chunk size %q", value)
+			}
+		case "ca":
+			caFile = value
+		case "cert":
+			certFile = value
+		case "key":
+			keyFile = value
+		case "server_name":
+			serverName = value
+		case "connect_timeout":
+			if cfg.DialTimeout, err = time.ParseDuration(value); err != nil || cfg.DialTimeout <= 0 {
+				return cfg, fmt.Errorf("invalid GELF connect timeout %q", value)
+			}
+		case "timeout":
+			if cfg.WriteTimeout, err = time.ParseDuration(value); err != nil || cfg.WriteTimeout <= 0 {
+				return cfg, fmt.Errorf("invalid GELF timeout %q", value)
+			}
+		case "buffer":
+			if cfg.BufferSize, err = strconv.Atoi(value); err != nil || cfg.BufferSize <= 0 {
+				return cfg, fmt.Errorf("invalid GELF buffer size %q", value)
+			}
+		default:
+			return cfg, fmt.Errorf("unknown GELF URI parameter %q", key)
+		}
+	}
+
+	if (cfg.Compression != backends.GELFCompressionNone || cfg.ChunkSize != 0) && cfg.Network != "udp" {
+		return cfg, fmt.Errorf("GELF URI %q: compress and chunk_size need the gelf+udp scheme", uri)
+	}
+	if (caFile != "" || certFile != "" || serverName != "") && cfg.Network != backends.NetworkTLS {
+		return cfg, fmt.Errorf("GELF URI %q: TLS parameters need the gelf+tls scheme", uri)
+	}
+	if (certFile == "") != (keyFile == "") {
+		return cfg, fmt.Errorf("GELF URI %q: cert and key must be given together", uri)
+	}
+	if cfg.Network == backends.NetworkTLS {
+		if cfg.TLSConfig, err = loadTLSConfig(caFile, certFile, keyFile, serverName); err != nil {
+			return cfg, err
+		}
+	}
+
+	return cfg, nil
+}
+
+// createGELFBackend creates a GELF backend configured from its URI
+func createGELFBackend(uri string) (backends.Backend, error) {
+	cfg, err := parseGELFURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	return backends.NewGELFBackend(cfg)
+}
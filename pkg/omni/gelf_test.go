@@ -0,0 +1,118 @@
+package omni
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/backends"
+)
+
+func TestParseGELFURI(t *testing.T) {
+	cfg, err := parseGELFURI("gelf+udp://graylog:12201?host=web-1&compress=zlib&chunk_size=8192&buffer=50")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := backends.GELFConfig{
+		Network:     "udp",
+		Address:     "graylog:12201",
+		Host:        "web-1",
+		Compression: backends.GELFCompressionZlib,
+		ChunkSize:   8192,
+		BufferSize:  50,
+	}
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, cfg)
+	}
+
+	if cfg, err := parseGELFURI("gelf+tls://graylog:12201?server_name=graylog.internal"); err != nil || cfg.Network != backends.NetworkTLS || cfg.TLSConfig == nil {
+		t.Errorf("Unexpected config %+v, %v", cfg, err)
+	}
+
+	for _, uri := range []string{
+		"gelf+udp://graylog",
+		"gelf+udp://graylog:12201?compress=lz4",
+		"gelf+udp://graylog:12201?chunk_size=0",
+		"gelf+tcp://graylog:12201?compress=gzip",
+		"gelf+udp://graylog:12201?ca=/ca.pem",
+		"gelf+tls://graylog:12201?cert=/cert.pem",
+		"gelf+udp://graylog:12201?level=info",
+	} {
+		if _, err := parseGELFURI(uri); err == nil {
+			t.Errorf("Expected error for %q", uri)
+		}
+	}
+}
+
+func TestGELFDestinationUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer conn.Close()
+
+	logger, err := New(filepath.Join(t.TempDir(), "test.log"))
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	if err := logger.AddDestination("gelf+udp://" + conn.LocalAddr().String() + "?host=web-1"); err != nil {
+		t.Fatalf("Failed to add GELF destination: %v", err)
+	}
+
+	logger.WarnWithFields("disk nearly full", map[string]interface{}{"mount": "/var"})
+	if err := logger.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	buf := make([]byte, 65536)
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("ReadFrom failed: %v", err)
+	}
+
+	var message map[string]interface{}
+	if err := json.Unmarshal(buf[:n], &message); err != nil {
+		t.Fatalf("Invalid GELF message %q: %v", buf[:n], err)
+	}
+	if message["host"] != "web-1" || message["short_message"] != "disk nearly full" || message["level"] != float64(4) || message["_mount"] != "/var" {
+		t.Errorf("Unexpected GELF message %v", message)
+	}
+}
+
+func TestSetFormatGELF(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	logger, err := New(path)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	if err := logger.SetFormat(FormatGELF); err != nil {
+		t.Fatalf("SetFormat failed: %v", err)
+	}
+	logger.Info("started")
+	if err := logger.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read log: %v", err)
+	}
+	var message map[string]interface{}
+	if err := json.Unmarshal(bytes.TrimSpace(data), &message); err != nil {
+		t.Fatalf("Expected a GELF line, got %q: %v", data, err)
+	}
+	if message["version"] != "1.1" || message["short_message"] != "started" || message["level"] != float64(6) {
+		t.Errorf("Unexpected GELF message %v", message)
+	}
+}
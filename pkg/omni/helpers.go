@@ -2,6 +2,7 @@ package omni
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -94,7 +95,7 @@ func NewBatchWriter(writer interface{}, maxSize, maxCount int, flushInterval tim
 func NewSyslog(address, tag string) (*Omni, error) {
 	// Create syslog URI
 	uri := address
-	if !strings.HasPrefix(uri, "syslog://") {
+	if !isSyslogURI(uri) {
 		if strings.HasPrefix(address, "/") {
 			// Unix socket path
 			uri = "syslog://" + address
@@ -103,6 +104,13 @@ func NewSyslog(address, tag string) (*Omni, error) {
 			uri = "syslog://" + address
 		}
 	}
+	if tag != "" && !strings.Contains(uri, "tag=") {
+		separator := "?"
+		if strings.Contains(uri, "?") {
+			separator = "&"
+		}
+		uri += separator + "tag=" + url.QueryEscape(tag)
+	}
 
 	return NewWithBackend(uri, BackendSyslog)
 }
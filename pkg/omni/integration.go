@@ -3,7 +3,10 @@ package omni
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"net/url"
+	"os"
 	"reflect"
 	"strings"
 	"sync"
@@ -75,49 +78,45 @@ func (w *pluginBackendWrapper) GetStats() backends.BackendStats {
 
 // createDestination creates a destination with proper backend integration
 func (f *Omni) createDestination(uri string, backendType int) (*Destination, error) {
+	// Network and plugin destinations may name their own formatter
+	formatter, err := f.uriFormatter(uri, backendType)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create backend instance
 	var backend backends.Backend
-	var err error
 
 	switch backendType {
 	case BackendFlock:
-		backend, err = backends.NewFileBackend(uri)
-	case BackendSyslog:
-		// Parse URI to extract network, address, priority, tag
-		network, address := "unix", "/dev/log" // defaults
-		tag := "omni"
-		priority := 16 // local0.info (facility 16, severity 6)
-
-		// Parse syslog URI: syslog://address or syslog:///path
-		if strings.HasPrefix(uri, "syslog://") {
-			address = strings.TrimPrefix(uri, "syslog://")
-			if strings.HasPrefix(address, "/") {
-				// Unix socket
-				network = "unix"
-			} else {
-				// Network address
-				network = "tcp"
-				if !strings.Contains(address, ":") {
-					address += ":514" // default syslog port
-				}
-			}
-		} else {
-			// Direct address
-			if strings.HasPrefix(uri, "/") {
-				// Unix socket path
-				network = "unix"
-				address = uri
-			} else {
-				// Network address
-				network = "tcp"
-				address = uri
-				if !strings.Contains(address, ":") {
-					address += ":514"
-				}
-			}
+		switch {
+		case f.streamCompression != CompressionNone:
+			backend, err = f.createStreamCompressedBackend(uri)
+		case f.diskSpace != nil:
+			backend, err = f.createDiskSpaceBackend(uri)
+		default:
+			backend, err = backends.NewFileBackend(uri)
 		}
-
-		backend, err = backends.NewSyslogBackend(network, address, priority, tag)
+	case BackendSyslog:
+		backend, err = createSyslogBackend(uri)
+	case BackendNetwork:
+		backend, err = createNetworkBackend(uri, isBinaryFormatter(formatter))
+	case BackendHTTP:
+		backend, err = createHTTPBackend(uri)
+	case BackendLoki:
+		backend, err = createLokiBackend(uri)
+	case BackendElasticsearch:
+		backend, err = createElasticsearchBackend(uri)
+	case BackendSplunkHEC:
+		backend, err = createSplunkHECBackend(uri)
+	case BackendOTLP:
+		backend, err = createOTLPBackend(uri)
+	case BackendGELF:
+		backend, err = createGELFBackend(uri)
+	case BackendJournald:
+		backend, err = createJournaldBackend(uri)
+	case BackendFluent:
+		backend, err = createFluentBackend(uri)
 	default:
 		// Try plugin backends
 		if f.pluginManager != nil {
@@ -139,6 +138,14 @@ func (f *Omni) createDestination(uri string, backendType int) (*Destination, err
 		return nil, err
 	}
 
+	// Frame files the way the formatter streams them, such as a JSON array
+	if backendType == BackendFlock {
+		if err := f.setFileStream(backend, formatter); err != nil {
+			_ = backend.Close() // Best effort close on error path
+			return nil, fmt.Errorf("destination %s: %w", uri, err)
+		}
+	}
+
 	// Create destination
 	dest := &Destination{
 		URI:     uri,
@@ -149,18 +156,159 @@ func (f *Omni) createDestination(uri string, backendType int) (*Destination, err
 
 	// Set backend using thread-safe method
 	dest.SetBackend(backend)
+	dest.formatter = formatter
 
 	// For file backends, set additional fields
-	if fileBackend, ok := backend.(*backends.FileBackendImpl); ok {
+	switch fileBackend := backend.(type) {
+	case *backends.FileBackendImpl:
+		dest.File = fileBackend.GetFile()
+		dest.Writer = fileBackend.GetWriter()
+		dest.Lock = fileBackend.GetLock()
+		dest.Size = fileBackend.GetSize()
+	case *backends.FileBackendWithRotation:
 		dest.File = fileBackend.GetFile()
 		dest.Writer = fileBackend.GetWriter()
 		dest.Lock = fileBackend.GetLock()
 		dest.Size = fileBackend.GetSize()
+	case *backends.CompressedFileBackend:
+		// No buffered writer is exposed; entries must pass through the compressor
+		dest.File = fileBackend.GetFile()
+		dest.Lock = fileBackend.GetLock()
+		dest.Size = fileBackend.GetSize()
 	}
 
 	return dest, nil
 }
 
+// uriFormatter returns the formatter named by the format query parameter of
+// network and plugin destination URIs, such as "tcp://collector:5170?format=msgpack",
+// or nil when the destination uses the logger's formatter. Syslog and HTTP
+// destinations give format their own meaning, and plugins may accept format
+// values that are not formatter names.
+func (f *Omni) uriFormatter(uri string, backendType int) (Formatter, error) {
+	var name string
+	switch backendType {
+	case BackendNetwork:
+		cfg, err := parseNetworkURI(uri)
+		if err != nil {
+			return nil, err
+		}
+		name = cfg.format
+	case BackendPlugin:
+		parsed, err := url.Parse(uri)
+		if err != nil {
+			return nil, nil
+		}
+		name = parsed.Query().Get("format")
+	}
+	if name == "" {
+		return nil, nil
+	}
+
+	formatter, err := formatters.CreateFormatter(name)
+	if err != nil {
+		if backendType == BackendPlugin {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("destination %s: %w", uri, err)
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if err := applyFormatOptions(formatter, f.formatOptions); err != nil {
+		return nil, err
+	}
+	return formatter, nil
+}
+
+// setFileStream frames the files of a file backend with the stream of
+// formatter, or of the logger's formatter when it is nil
+func (f *Omni) setFileStream(backend backends.Backend, formatter Formatter) error {
+	if formatter == nil {
+		formatter = f.GetFormatter()
+	}
+	if formatter == nil && f.GetFormat() == FormatJSON {
+		// Line formatting falls back to a JSON formatter with the format options
+		jsonFormatter := formatters.NewJSONFormatter()
+		jsonFormatter.Options = f.GetFormatOptions()
+		formatter = jsonFormatter
+	}
+
+	streamer, ok := formatter.(formatters.StreamFormatter)
+	if !ok {
+		return nil
+	}
+	start, err := streamer.StartStream()
+	if err != nil {
+		return err
+	}
+	end, err := streamer.EndStream()
+	if err != nil {
+		return err
+	}
+	stream := backends.FileStream{Start: start, End: end}
+	if separated, ok := formatter.(formatters.SeparatedStreamFormatter); ok {
+		stream.Separator = separated.StreamSeparator()
+	}
+	if stream.IsZero() {
+		return nil
+	}
+
+	streaming, ok := backend.(backends.StreamingBackend)
+	if !ok {
+		return fmt.Errorf("%T files cannot be framed for %T", backend, formatter)
+	}
+	return streaming.SetStream(stream)
+}
+
+// isBinaryFormatter reports whether formatter writes binary entries, which
+// network destinations must send unchanged rather than as lines
+func isBinaryFormatter(formatter Formatter) bool {
+	switch formatter.(type) {
+	case *formatters.MsgpackFormatter, *formatters.CBORFormatter:
+		return true
+	default:
+		return false
+	}
+}
+
+// createStreamCompressedBackend creates a file backend that writes compressed
+// frames directly to the active file
+func (f *Omni) createStreamCompressedBackend(uri string) (backends.Backend, error) {
+	fileBackend, err := backends.NewCompressedFileBackend(uri,
+		features.CompressionType(f.streamCompression), f.compressLevel, f.streamFlushInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	fileBackend.SetErrorHandler(func(source, dest, msg string, err error) {
+		f.logError(source, dest, msg, err, ErrorLevelWarn)
+	})
+
+	return fileBackend, nil
+}
+
+// createDiskSpaceBackend creates a file backend that enforces the configured
+// disk budgets and free-space policy
+func (f *Omni) createDiskSpaceBackend(uri string) (backends.Backend, error) {
+	if f.rotationManager == nil {
+		f.ensureRotationManager()
+		f.rotationManager.SetMaxFiles(f.maxFiles)
+	}
+
+	fileBackend, err := backends.NewFileBackendWithRotation(uri, f.rotationManager)
+	if err != nil {
+		return nil, err
+	}
+
+	fileBackend.SetErrorHandler(func(source, dest, msg string, err error) {
+		f.logError(source, dest, msg, err, ErrorLevelWarn)
+	})
+	fileBackend.SetDiskSpaceConfig(*f.diskSpace)
+
+	return fileBackend, nil
+}
+
 // SetRedaction integrates with the redaction feature
 func (f *Omni) SetRedaction(patterns []string, replace string) error {
 	// Create redactor
@@ -231,14 +379,27 @@ func (f *Omni) startCompressionWorkers() {
 		f.compressionManager.SetMetricsHandler(f.trackMetric)
 	}
 
-	f.compressionManager.Start()
-
-	// Set compression callback for rotation manager
-	if f.rotationManager != nil {
-		f.rotationManager.SetCompressionCallback(func(path string) {
-			f.compressionManager.QueueFile(path)
-		})
+	// Apply the logger's compression settings before the workers start
+	f.compressionManager.SetWorkers(f.compressWorkers)
+	f.compressionManager.SetMinAge(f.compressMinAge)
+	if err := f.compressionManager.SetCompression(features.CompressionType(f.compression)); err != nil {
+		f.logError("compress", "", "Invalid compression type", err, ErrorLevelWarn)
 	}
+	if err := f.compressionManager.SetLevel(f.compressLevel); err != nil {
+		f.logError("compress", "", "Invalid compression level", err, ErrorLevelWarn)
+	}
+
+	// Keep the rotation manifest in step with compressed files
+	f.compressionManager.SetCompletionHandler(func(original, compressed string) {
+		if f.rotationManager == nil || !f.rotationManager.IsManifestEnabled() {
+			return
+		}
+		if err := f.rotationManager.RecordCompression(original, compressed); err != nil {
+			f.logError("manifest", original, "Failed to update rotation manifest", err, ErrorLevelWarn)
+		}
+	})
+
+	f.compressionManager.Start()
 }
 
 func (f *Omni) stopCompressionWorkers() {
@@ -249,14 +410,27 @@ func (f *Omni) stopCompressionWorkers() {
 
 // Rotation integration
 
-func (f *Omni) startCleanupRoutine() {
-	if f.rotationManager == nil {
-		f.rotationManager = features.NewRotationManager()
-		f.rotationManager.SetErrorHandler(func(source, dest, msg string, err error) {
-			f.logError(source, dest, msg, err, ErrorLevelWarn)
-		})
-		f.rotationManager.SetMetricsHandler(f.trackMetric)
+// ensureRotationManager creates the rotation manager if needed. Every file it
+// rotates, whether rotated by the logger or by a disk space aware backend, is
+// queued for compression through its callback.
+func (f *Omni) ensureRotationManager() {
+	if f.rotationManager != nil {
+		return
 	}
+	f.rotationManager = features.NewRotationManager()
+	f.rotationManager.SetErrorHandler(func(source, dest, msg string, err error) {
+		f.logError(source, dest, msg, err, ErrorLevelWarn)
+	})
+	f.rotationManager.SetMetricsHandler(f.trackMetric)
+	f.rotationManager.SetCompressionCallback(func(path string) {
+		if f.compressionManager != nil {
+			f.compressionManager.QueueFile(path)
+		}
+	})
+}
+
+func (f *Omni) startCleanupRoutine() {
+	f.ensureRotationManager()
 
 	// Add all log paths to rotation manager
 	f.mu.RLock()
@@ -311,25 +485,71 @@ func (f *Omni) WithError(err error) Logger {
 // Manager interface implementations
 
 func (f *Omni) SetFormat(format int) error {
+	// Validate format
+	formatter, err := newFormatter(format)
+	if err != nil {
+		return err
+	}
+
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
-	// Validate format
-	if format < FormatText || format > FormatJSON {
-		return fmt.Errorf("invalid format: %d", format)
+	if err := applyFormatOptions(formatter, f.formatOptions); err != nil {
+		return err
 	}
-
 	f.format = format
+	f.formatter = formatter
+
+	return nil
+}
+
+// applyFormatOptions sets the options of the built-in formatters that follow
+// the logger's format options, such as a JSON preset, time zone or pattern
+func applyFormatOptions(formatter Formatter, options FormatOptions) error {
+	switch formatter := formatter.(type) {
+	case *formatters.JSONFormatter:
+		formatter.Options = options
+	case *formatters.ConsoleFormatter:
+		formatter.Options = options
+	case *formatters.PatternFormatter:
+		formatter.Options = options
+		return formatter.SetPattern(options.Pattern)
+	case *formatters.CSVFormatter:
+		formatter.Options = options
+	}
+	return nil
+}
 
-	// Update formatter based on format
+// newFormatter returns the built-in formatter for a format constant
+func newFormatter(format int) (Formatter, error) {
 	switch format {
-	case FormatJSON:
-		f.formatter = formatters.NewJSONFormatter()
 	case FormatText:
-		f.formatter = formatters.NewTextFormatter()
+		return formatters.NewTextFormatter(), nil
+	case FormatJSON:
+		return formatters.NewJSONFormatter(), nil
+	case FormatGELF:
+		return formatters.NewGELFFormatter(), nil
+	case FormatLogfmt:
+		return formatters.NewLogfmtFormatter(), nil
+	case FormatCEF:
+		return formatters.NewCEFFormatter(), nil
+	case FormatLEEF:
+		return formatters.NewLEEFFormatter(), nil
+	case FormatConsole:
+		return formatters.NewConsoleFormatter(), nil
+	case FormatPattern:
+		return formatters.NewPatternFormatter("")
+	case FormatMsgpack:
+		return formatters.NewMsgpackFormatter(), nil
+	case FormatCBOR:
+		return formatters.NewCBORFormatter(), nil
+	case FormatCSV:
+		return formatters.NewCSVFormatter(), nil
+	case FormatTSV:
+		return formatters.NewTSVFormatter(), nil
+	default:
+		return nil, fmt.Errorf("invalid format: %d", format)
 	}
-
-	return nil
 }
 
 func (f *Omni) GetFormat() int {
@@ -350,22 +570,52 @@ func (f *Omni) SetCompression(compressionType int) error {
 	return f.compressionManager.SetCompression(features.CompressionType(compressionType))
 }
 
+// SetCompressionLevel sets the compression level for rotated log files.
+// Use 0 for the algorithm default, 1-9 for gzip, or 1-22 for zstd.
+func (f *Omni) SetCompressionLevel(level int) error {
+	f.mu.RLock()
+	manager := f.compressionManager
+	compression := f.compression
+	f.mu.RUnlock()
+
+	if manager != nil {
+		if err := manager.SetLevel(level); err != nil {
+			return err
+		}
+	} else if err := features.ValidateCompressionLevel(features.CompressionType(compression), level); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	f.compressLevel = level
+	f.mu.Unlock()
+	return nil
+}
+
 func (f *Omni) SetMaxAge(duration time.Duration) error {
 	f.mu.Lock()
 	f.maxAge = duration
 	f.mu.Unlock()
 
-	if f.rotationManager == nil {
-		f.rotationManager = features.NewRotationManager()
-		f.rotationManager.SetErrorHandler(func(source, dest, msg string, err error) {
-			f.logError(source, dest, msg, err, ErrorLevelWarn)
-		})
-		f.rotationManager.SetMetricsHandler(f.trackMetric)
-	}
+	f.ensureRotationManager()
 
 	return f.rotationManager.SetMaxAge(duration)
 }
 
+// SetRotationManifest enables or disables the rotation manifest. When enabled,
+// each rotated file is recorded in <path>.manifest.json with its time range,
+// entry counts, size, compression and SHA-256 checksum, which
+// features.ReadManifest and features.VerifyManifest read back.
+func (f *Omni) SetRotationManifest(enabled bool) {
+	f.mu.Lock()
+	f.rotationManifest = enabled
+	f.mu.Unlock()
+
+	f.ensureRotationManager()
+
+	f.rotationManager.SetManifestEnabled(enabled)
+}
+
 // Destination management
 
 func (f *Omni) SetDestinationEnabled(index int, enabled bool) error {
@@ -387,8 +637,27 @@ func (f *Omni) SetDestinationEnabled(index int, enabled bool) error {
 func (f *Omni) AddDestination(uri string) error {
 	// Auto-detect backend type from URI
 	backendType := BackendFlock // Default
-	if strings.HasPrefix(uri, "syslog://") {
+	switch {
+	case isSyslogURI(uri):
 		backendType = BackendSyslog
+	case isNetworkURI(uri):
+		backendType = BackendNetwork
+	case isHTTPURI(uri):
+		backendType = BackendHTTP
+	case isLokiURI(uri):
+		backendType = BackendLoki
+	case isElasticsearchURI(uri):
+		backendType = BackendElasticsearch
+	case isSplunkHECURI(uri):
+		backendType = BackendSplunkHEC
+	case isOTLPURI(uri):
+		backendType = BackendOTLP
+	case isGELFURI(uri):
+		backendType = BackendGELF
+	case isJournaldURI(uri):
+		backendType = BackendJournald
+	case isFluentURI(uri):
+		backendType = BackendFluent
 	}
 
 	return f.AddDestinationWithBackend(uri, backendType)
@@ -476,6 +745,23 @@ func (f *Omni) EnableDestination(name string) error {
 	return fmt.Errorf("destination not found: %s", name)
 }
 
+// SetDestinationFormatter sets the formatter of the named destination, so it
+// can write, for example, MessagePack while other destinations write JSON.
+// A nil formatter restores the logger's formatter.
+func (f *Omni) SetDestinationFormatter(name string, formatter Formatter) error {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	for _, dest := range f.Destinations {
+		if dest.URI == name {
+			dest.SetFormatter(formatter)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("destination not found: %s", name)
+}
+
 func (f *Omni) DisableDestination(name string) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
@@ -552,13 +838,30 @@ func (f *Omni) Sync() error {
 		backend := dest.GetBackend()
 		if backend != nil {
 			if fileBackend, ok := backend.(backends.FileBackend); ok {
-				if err := fileBackend.Sync(); err != nil {
+				err := fileBackend.Sync()
+				// A concurrent rotation closes the old backend after replacing it
+				if err != nil && errors.Is(err, os.ErrClosed) {
+					if current, ok := dest.GetBackend().(backends.FileBackend); ok && current != fileBackend {
+						err = current.Sync()
+					}
+				}
+				if err != nil {
+					errs = append(errs, fmt.Errorf("sync %s: %w", dest.URI, err))
+				}
+			} else if batching, ok := backend.(backends.BatchingBackend); ok && batching.Batching() {
+				// Send the entries batched since the last write
+				if err := batching.Sync(); err != nil {
 					errs = append(errs, fmt.Errorf("sync %s: %w", dest.URI, err))
 				}
 			}
 		}
 	}
 
+	// Rotated files are scanned into the manifest in the background
+	if f.rotationManager != nil {
+		f.rotationManager.FlushManifest()
+	}
+
 	if len(errs) > 0 {
 		return fmt.Errorf("sync errors: %v", errs)
 	}
@@ -581,7 +884,10 @@ func (f *Omni) Close() error {
 	// Wait for dispatcher to finish
 	f.workerWg.Wait()
 
-	// Stop managers
+	// Stop managers once rotated files are recorded and queued for compression
+	if f.rotationManager != nil {
+		f.rotationManager.FlushManifest()
+	}
 	f.stopCompressionWorkers()
 	f.stopCleanupRoutine()
 
@@ -989,17 +1295,34 @@ func (f *Omni) rotateDestination(dest *Destination) error {
 		return nil // Only file destinations support rotation
 	}
 
-	if f.rotationManager == nil {
-		f.rotationManager = features.NewRotationManager()
-		f.rotationManager.SetErrorHandler(func(source, dest, msg string, err error) {
-			f.logError(source, dest, msg, err, ErrorLevelWarn)
-		})
-		f.rotationManager.SetMetricsHandler(f.trackMetric)
+	f.ensureRotationManager()
+
+	// Stream-compressed files complete their frame and rename themselves,
+	// keeping the compression suffix so they are not compressed again
+	backend := dest.GetBackend()
+	if compressed, ok := backend.(*backends.CompressedFileBackend); ok {
+		rotatedPath, err := compressed.RotateFile()
+		if err != nil {
+			return err
+		}
+
+		dest.mu.Lock()
+		dest.File = compressed.GetFile()
+		dest.Size = 0
+		dest.mu.Unlock()
+
+		f.trackMetric("rotation_completed")
+		if f.rotationManager.IsManifestEnabled() {
+			f.rotationManager.RecordRotationAsync(dest.URI, rotatedPath)
+		}
+		if err := f.rotationManager.CleanupOldFiles(dest.URI); err != nil {
+			f.logError("cleanup", dest.URI, "Failed to cleanup old files after rotation", err, ErrorLevelLow)
+		}
+		return nil
 	}
 
 	// Get writer from backend using thread-safe method
 	var writer *bufio.Writer
-	backend := dest.GetBackend()
 	if backend != nil {
 		// Check if it's a file backend that has GetWriter
 		if fileBackend, ok := backend.(backends.FileBackend); ok {
@@ -1007,11 +1330,27 @@ func (f *Omni) rotateDestination(dest *Destination) error {
 		}
 	}
 
-	rotatedPath, err := f.rotationManager.RotateFile(dest.URI, writer)
-	if err != nil {
+	// End the stream framing the file before it is renamed and compressed
+	if streaming, ok := backend.(backends.StreamingBackend); ok {
+		if err := streaming.FinishStream(); err != nil {
+			return err
+		}
+	}
+
+	// The rotation manager records and queues the rotated file for compression
+	if _, err := f.rotationManager.RotateFile(dest.URI, writer); err != nil {
 		return err
 	}
 
+	// Release the rotated backend's file handle and monitor before the file is
+	// re-opened; closing it unregisters its path from the rotation manager, so
+	// it must not run after the replacement has registered the same path
+	if backend != nil {
+		if err := backend.Close(); err != nil {
+			f.logError("rotation", dest.URI, "Failed to close rotated backend", err, ErrorLevelWarn)
+		}
+	}
+
 	// Re-open the file
 	newDest, err := f.createDestination(dest.URI, dest.Backend)
 	if err != nil {
@@ -1027,11 +1366,6 @@ func (f *Omni) rotateDestination(dest *Destination) error {
 	dest.Size = 0
 	dest.mu.Unlock()
 
-	// Queue for compression if enabled
-	if f.compressionManager != nil && f.compression != CompressionNone {
-		f.compressionManager.QueueFile(rotatedPath)
-	}
-
 	// Run cleanup after rotation to enforce maxFiles limit
 	if f.rotationManager != nil {
 		if err := f.rotationManager.CleanupOldFiles(dest.URI); err != nil {
@@ -1397,6 +1731,10 @@ func (f *Omni) formatMessage(msg LogMessage) ([]byte, error) {
 		if f.jsonFormatter == nil {
 			f.jsonFormatter = formatters.NewJSONFormatter()
 		}
+		// Update formatter options from logger's formatOptions
+		f.mu.RLock()
+		f.jsonFormatter.Options = f.formatOptions
+		f.mu.RUnlock()
 		return f.jsonFormatter.Format(msg)
 	default:
 		if f.textFormatter == nil {
@@ -220,6 +220,66 @@ func TestDestinationEnableDisable(t *testing.T) {
 	}
 }
 
+// TestDestinationFormatter tests a destination writing CBOR alongside a JSON destination
+func TestDestinationFormatter(t *testing.T) {
+	tempDir := t.TempDir()
+	jsonFile := filepath.Join(tempDir, "main.log")
+	cborFile := filepath.Join(tempDir, "entries.cbor")
+
+	logger, err := New(jsonFile)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+	logger.SetFormat(FormatJSON)
+
+	if err := logger.AddDestination(cborFile); err != nil {
+		t.Fatalf("Failed to add destination: %v", err)
+	}
+	if err := logger.SetDestinationFormatter(cborFile, formatters.NewCBORFormatter()); err != nil {
+		t.Fatalf("Failed to set destination formatter: %v", err)
+	}
+	if err := logger.SetDestinationFormatter(filepath.Join(tempDir, "missing.log"), formatters.NewCBORFormatter()); err == nil {
+		t.Error("Expected error for an unknown destination")
+	}
+
+	logger.WarnWithFields("disk low", map[string]interface{}{"free": 1024})
+	if err := logger.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	content, err := os.ReadFile(jsonFile)
+	if err != nil {
+		t.Fatalf("Failed to read JSON file: %v", err)
+	}
+	if !strings.Contains(string(content), `"message":"disk low"`) {
+		t.Errorf("Expected a JSON entry, got %q", content)
+	}
+
+	data, err := os.ReadFile(cborFile)
+	if err != nil {
+		t.Fatalf("Failed to read CBOR file: %v", err)
+	}
+	entry, rest, err := formatters.DecodeCBOREntry(data)
+	if err != nil || len(rest) != 0 {
+		t.Fatalf("Expected one CBOR entry, got % x: %v", data, err)
+	}
+	if entry.Message != "disk low" || entry.Level != "WARN" || entry.Fields["free"] != int64(1024) {
+		t.Errorf("Unexpected entry %+v", entry)
+	}
+
+	// Plugin URIs name a formatter through format, leaving other values to the plugin
+	if formatter, err := logger.uriFormatter("nats://localhost:4222/logs?format=msgpack", BackendPlugin); err != nil || !isBinaryFormatter(formatter) {
+		t.Errorf("Expected a MessagePack formatter for a plugin URI, got %T, %v", formatter, err)
+	}
+	if formatter, err := logger.uriFormatter("nats://localhost:4222/logs?format=protobuf", BackendPlugin); err != nil || formatter != nil {
+		t.Errorf("Expected no formatter for an unknown plugin format, got %T, %v", formatter, err)
+	}
+	if formatter, err := logger.uriFormatter("syslog://localhost?format=rfc5424", BackendSyslog); err != nil || formatter != nil {
+		t.Errorf("Expected syslog to keep its own format parameter, got %T, %v", formatter, err)
+	}
+}
+
 // TestConcurrentMultiDestination tests concurrent logging to multiple destinations
 func TestConcurrentMultiDestination(t *testing.T) {
 	tempDir := t.TempDir()
@@ -1110,6 +1170,34 @@ func TestSetFormatterOperation(t *testing.T) {
 	}
 }
 
+func TestSetFormatLogfmt(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "logfmt.log")
+	logger, err := New(logFile)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	if err := logger.SetFormat(FormatLogfmt); err != nil {
+		t.Fatalf("SetFormat failed: %v", err)
+	}
+	logger.InfoWithFields("user logged in", map[string]interface{}{
+		"user": map[string]interface{}{"id": 42},
+	})
+	if err := logger.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	line := string(content)
+	if !strings.HasPrefix(line, "ts=") || !strings.HasSuffix(line, ` level=info msg="user logged in" user.id=42`+"\n") {
+		t.Errorf("Unexpected logfmt line %q", line)
+	}
+}
+
 // TestStructuredLoggingEnhanced tests enhanced structured logging functionality
 func TestStructuredLoggingEnhanced(t *testing.T) {
 	tmpDir := t.TempDir()
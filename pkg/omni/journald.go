@@ -0,0 +1,57 @@
+package omni
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/wayneeseguin/omni/pkg/backends"
+)
+
+// isJournaldURI reports whether uri names a systemd journal destination
+func isJournaldURI(uri string) bool {
+	return strings.HasPrefix(uri, "journald://")
+}
+
+// parseJournaldURI parses a journal destination URI. "journald://" writes to
+// the default journal socket; "journald:///path/to/socket" to another socket.
+//
+// The only supported query parameter is identifier (SYSLOG_IDENTIFIER).
+func parseJournaldURI(uri string) (backends.JournaldConfig, error) {
+	var cfg backends.JournaldConfig
+
+	if !isJournaldURI(uri) {
+		return cfg, fmt.Errorf("invalid journald URI %q", uri)
+	}
+
+	path, rawQuery, _ := strings.Cut(strings.TrimPrefix(uri, "journald://"), "?")
+	if path != "" && !strings.HasPrefix(path, "/") {
+		return cfg, fmt.Errorf("journald URI %q: socket path must be absolute", uri)
+	}
+	cfg.Socket = path
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return cfg, fmt.Errorf("invalid journald URI query: %w", err)
+	}
+	for key, values := range query {
+		value := values[len(values)-1]
+		switch key {
+		case "identifier":
+			cfg.Identifier = value
+		default:
+			return cfg, fmt.Errorf("unknown journald URI parameter %q", key)
+		}
+	}
+
+	return cfg, nil
+}
+
+// createJournaldBackend creates a journald backend configured from its URI
+func createJournaldBackend(uri string) (backends.Backend, error) {
+	cfg, err := parseJournaldURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	return backends.NewJournaldBackend(cfg)
+}
@@ -0,0 +1,66 @@
+package omni
+
+import (
+	"bytes"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseJournaldURI(t *testing.T) {
+	cfg, err := parseJournaldURI("journald://")
+	if err != nil || cfg.Socket != "" || cfg.Identifier != "" {
+		t.Errorf("Unexpected config %+v, %v", cfg, err)
+	}
+
+	cfg, err = parseJournaldURI("journald:///run/test/journal.sock?identifier=checkout")
+	if err != nil || cfg.Socket != "/run/test/journal.sock" || cfg.Identifier != "checkout" {
+		t.Errorf("Unexpected config %+v, %v", cfg, err)
+	}
+
+	for _, uri := range []string{
+		"journald://relative.sock",
+		"journald://?priority=3",
+	} {
+		if _, err := parseJournaldURI(uri); err == nil {
+			t.Errorf("Expected error for %q", uri)
+		}
+	}
+}
+
+func TestJournaldDestination(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unixgram sockets unavailable: %v", err)
+	}
+	defer conn.Close()
+
+	logger, err := New(filepath.Join(t.TempDir(), "test.log"))
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	if err := logger.AddDestination("journald://" + path + "?identifier=checkout"); err != nil {
+		t.Fatalf("Failed to add journald destination: %v", err)
+	}
+
+	logger.ErrorWithFields("charge failed", map[string]interface{}{"order_id": "A-17"})
+	if err := logger.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	buf := make([]byte, 4096)
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	for _, field := range []string{"MESSAGE=charge failed\n", "PRIORITY=3\n", "SYSLOG_IDENTIFIER=checkout\n", "ORDER_ID=A-17\n"} {
+		if !bytes.Contains(buf[:n], []byte(field)) {
+			t.Errorf("Expected %q in entry %q", field, buf[:n])
+		}
+	}
+}
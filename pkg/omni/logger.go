@@ -50,6 +50,7 @@ type Omni struct {
 
 	// Compression fields
 	compression     int
+	compressLevel   int
 	compressMinAge  int
 	compressWorkers int
 	compressCh      chan string
@@ -60,6 +61,16 @@ type Omni struct {
 	cleanupDone     chan struct{}
 	cleanupWg       sync.WaitGroup
 
+	// Disk space monitoring for file destinations
+	diskSpace *DiskSpaceConfig
+
+	// Stream compression of active file destinations
+	streamCompression   int
+	streamFlushInterval time.Duration
+
+	// Record rotated files in a manifest next to each log
+	rotationManifest bool
+
 	// Sampling fields
 	samplingStrategy int
 	samplingRate     float64
@@ -346,13 +357,7 @@ func (f *Omni) SetMaxFiles(count int) {
 	f.mu.Unlock()
 
 	// Initialize rotation manager if needed
-	if f.rotationManager == nil {
-		f.rotationManager = features.NewRotationManager()
-		f.rotationManager.SetErrorHandler(func(source, dest, msg string, err error) {
-			f.logError(source, dest, msg, err, ErrorLevelWarn)
-		})
-		f.rotationManager.SetMetricsHandler(f.trackMetric)
-	}
+	f.ensureRotationManager()
 
 	// Propagate the setting to rotation manager
 	f.rotationManager.SetMaxFiles(count)
@@ -463,7 +468,7 @@ func (f *Omni) SetChannelSize(size int) error {
 // NewDestination creates a new Destination based on the provided URI.
 func NewDestination(uri string) (*Destination, error) {
 	// Parse the URI to determine the destination type
-	if strings.HasPrefix(uri, "syslog://") {
+	if isSyslogURI(uri) {
 		return &Destination{
 			URI:     uri,
 			Backend: BackendSyslog,
@@ -0,0 +1,133 @@
+package omni
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/backends"
+)
+
+// lokiLabelPrefix marks query parameters that set static stream labels
+const lokiLabelPrefix = "label."
+
+// isLokiURI reports whether uri names a Loki destination: loki:// (HTTP) or loki+https://
+func isLokiURI(uri string) bool {
+	return strings.HasPrefix(uri, "loki://") || strings.HasPrefix(uri, "loki+https://")
+}
+
+// parseLokiURI parses a Loki destination URI such as
+// "loki://loki:3100?labels=service,env&label.job=api&tenant=team-a".
+//
+// User info in the URI is sent as basic auth. Supported query parameters are
+// labels (fields promoted to stream labels), label.<name> (static labels),
+// level (add the level label, default true), max_streams, tenant, token,
+// gzip, batch, batch_bytes, interval, timeout, retries and ca.
+func parseLokiURI(uri string) (backends.LokiConfig, error) {
+	cfg := backends.LokiConfig{LevelLabel: true}
+
+	if !isLokiURI(uri) {
+		return cfg, fmt.Errorf("invalid Loki URI %q", uri)
+	}
+	scheme := "http"
+	if strings.HasPrefix(uri, "loki+https://") {
+		scheme = "https"
+	}
+
+	endpoint, err := url.Parse(uri)
+	if err != nil || endpoint.Host == "" {
+		return cfg, fmt.Errorf("invalid Loki URI %q", uri)
+	}
+	endpoint.Scheme = scheme
+
+	if endpoint.User != nil {
+		cfg.Username = endpoint.User.Username()
+		cfg.Password, _ = endpoint.User.Password()
+		endpoint.User = nil
+	}
+
+	var caFile string
+	for key, values := range endpoint.Query() {
+		value := values[len(values)-1]
+		switch {
+		case strings.HasPrefix(key, lokiLabelPrefix):
+			if cfg.Labels == nil {
+				cfg.Labels = make(map[string]string)
+			}
+			cfg.Labels[strings.TrimPrefix(key, lokiLabelPrefix)] = value
+		case key == "labels":
+			for _, field := range strings.Split(value, ",") {
+				if field = strings.TrimSpace(field); field != "" {
+					cfg.LabelFields = append(cfg.LabelFields, field)
+				}
+			}
+		case key == "level":
+			if cfg.LevelLabel, err = strconv.ParseBool(value); err != nil {
+				return cfg, fmt.Errorf("invalid Loki level value %q", value)
+			}
+		case key == "max_streams":
+			if cfg.MaxStreams, err = strconv.Atoi(value); err != nil || cfg.MaxStreams <= 0 {
+				return cfg, fmt.Errorf("invalid Loki max_streams %q", value)
+			}
+		case key == "tenant":
+			cfg.TenantID = value
+		case key == "token":
+			cfg.BearerToken = value
+		case key == "gzip":
+			if cfg.Compress, err = strconv.ParseBool(value); err != nil {
+				return cfg, fmt.Errorf("invalid Loki gzip value %q", value)
+			}
+		case key == "batch":
+			if cfg.BatchSize, err = strconv.Atoi(value); err != nil || cfg.BatchSize <= 0 {
+				return cfg, fmt.Errorf("invalid Loki batch size %q", value)
+			}
+		case key == "batch_bytes":
+			if cfg.BatchBytes, err = strconv.Atoi(value); err != nil || cfg.BatchBytes <= 0 {
+				return cfg, fmt.Errorf("invalid Loki batch bytes %q", value)
+			}
+		case key == "interval":
+			if cfg.FlushInterval, err = time.ParseDuration(value); err != nil || cfg.FlushInterval <= 0 {
+				return cfg, fmt.Errorf("invalid Loki flush interval %q", value)
+			}
+		case key == "timeout":
+			if cfg.Timeout, err = time.ParseDuration(value); err != nil || cfg.Timeout <= 0 {
+				return cfg, fmt.Errorf("invalid Loki timeout %q", value)
+			}
+		case key == "retries":
+			if cfg.MaxRetries, err = strconv.Atoi(value); err != nil || cfg.MaxRetries < 0 {
+				return cfg, fmt.Errorf("invalid Loki retries %q", value)
+			}
+			if cfg.MaxRetries == 0 {
+				cfg.MaxRetries = -1
+			}
+		case key == "ca":
+			caFile = value
+		default:
+			return cfg, fmt.Errorf("unknown Loki URI parameter %q", key)
+		}
+	}
+	endpoint.RawQuery = ""
+	cfg.URL = endpoint.String()
+
+	if caFile != "" {
+		if scheme != "https" {
+			return cfg, fmt.Errorf("loki URI %q: ca needs the loki+https scheme", uri)
+		}
+		if cfg.TLSConfig, err = loadTLSConfig(caFile, "", "", ""); err != nil {
+			return cfg, err
+		}
+	}
+
+	return cfg, nil
+}
+
+// createLokiBackend creates a Loki backend configured from its URI
+func createLokiBackend(uri string) (backends.Backend, error) {
+	cfg, err := parseLokiURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	return backends.NewLokiBackend(cfg)
+}
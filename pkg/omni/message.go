@@ -7,7 +7,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/wayneeseguin/omni/pkg/backends"
 	"github.com/wayneeseguin/omni/pkg/features"
+	"github.com/wayneeseguin/omni/pkg/formatters"
 )
 
 // writeToDestination writes binary data to a destination using batch writer if enabled, otherwise direct write.
@@ -88,6 +90,12 @@ func (f *Omni) processMessage(msg LogMessage, dest *Destination) error {
 		return fmt.Errorf("nil destination")
 	}
 
+	// Destinations under disk pressure may shed low-severity messages
+	if shedder, ok := dest.GetBackend().(interface{ AcceptsLevel(int) bool }); ok && !shedder.AcceptsLevel(msg.Level) {
+		f.trackMessageDropped()
+		return nil
+	}
+
 	// Apply redaction before formatting
 	if f.redactionManager != nil {
 		if msg.Entry != nil && msg.Entry.Fields != nil {
@@ -105,8 +113,19 @@ func (f *Omni) processMessage(msg LogMessage, dest *Destination) error {
 		}
 	}
 
-	// Format the message using the configured formatter
-	data, err := f.formatMessage(msg)
+	// Format the message using the destination's formatter, or the logger's
+	var data []byte
+	var err error
+	formatter := dest.GetFormatter()
+	if console, ok := f.GetFormatter().(*formatters.ConsoleFormatter); ok && formatter == nil {
+		formatter = dest.consoleFormatter(console)
+	}
+	if formatter != nil {
+		data, err = formatter.Format(msg)
+	} else {
+		formatter = f.GetFormatter()
+		data, err = f.formatMessage(msg)
+	}
 	if err != nil {
 		f.logError("format", dest.URI, "Failed to format message", err, ErrorLevelMedium)
 		return err
@@ -116,11 +135,24 @@ func (f *Omni) processMessage(msg LogMessage, dest *Destination) error {
 	backend := dest.GetBackend()
 	if backend != nil {
 		writeStart := time.Now()
-		n, err := backend.Write(data)
+		var n int
+		if syslog, ok := backend.(backends.SyslogMessageWriter); ok {
+			// Syslog carries the timestamp and severity in its own header
+			n, err = syslog.WriteMessage(syslogMessage(msg, data, syslog.StructuredData()))
+		} else if recordWriter, ok := backend.(backends.RecordWriter); ok {
+			n, err = recordWriter.WriteRecord(f.logRecord(msg, data))
+		} else if batchWriter, batchFormatter := batchMessageWriter(backend, formatter); batchWriter != nil {
+			// The batch is encoded by the formatter as a whole
+			n, err = batchWriter.WriteBatchMessage(batchFormatter, msg, data)
+		} else {
+			n, err = backend.Write(data)
+		}
 		writeDuration := time.Since(writeStart)
 
-		// Flush to ensure data is written to disk immediately
-		if err == nil {
+		// Flush to ensure data is written to disk immediately; batching
+		// backends send on their own schedule
+		batching, _ := backend.(backends.BatchingBackend)
+		if err == nil && (batching == nil || !batching.Batching()) {
 			if flushErr := backend.Flush(); flushErr != nil {
 				f.logError("flush", dest.URI, "Failed to flush backend", flushErr, ErrorLevelLow)
 			}
@@ -143,7 +175,16 @@ func (f *Omni) processMessage(msg LogMessage, dest *Destination) error {
 
 		// Update size for file backends
 		dest.mu.Lock()
-		dest.Size += int64(n)
+		switch fileBackend := backend.(type) {
+		case *backends.CompressedFileBackend:
+			// Rotation follows the compressed size on disk
+			dest.Size = fileBackend.Size()
+		case *backends.FileBackendWithRotation:
+			// The backend rotates on its own when disk space runs low
+			dest.Size = fileBackend.Size()
+		default:
+			dest.Size += int64(n)
+		}
 		needsRotation := f.maxSize > 0 && dest.Size > f.maxSize
 		dest.mu.Unlock()
 
@@ -175,6 +216,20 @@ func (f *Omni) processMessage(msg LogMessage, dest *Destination) error {
 	return nil
 }
 
+// batchMessageWriter returns backend as a BatchMessageWriter and formatter
+// as a BatchFormatter when both offer batch encoding
+func batchMessageWriter(backend backends.Backend, formatter Formatter) (backends.BatchMessageWriter, formatters.BatchFormatter) {
+	batchWriter, ok := backend.(backends.BatchMessageWriter)
+	if !ok {
+		return nil, nil
+	}
+	batchFormatter, ok := formatter.(formatters.BatchFormatter)
+	if !ok {
+		return nil, nil
+	}
+	return batchWriter, batchFormatter
+}
+
 // processCustomMessage processes a message for a custom backend (primarily used in testing).
 // It formats the message according to the configured format options and writes it to the
 // custom writer without file locking.
@@ -0,0 +1,184 @@
+package omni
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/backends"
+)
+
+// networkConfig holds the settings parsed from a network destination URI
+type networkConfig struct {
+	network      string
+	address      string
+	framing      backends.NetworkFraming
+	caFile       string
+	certFile     string
+	keyFile      string
+	serverName   string
+	dialTimeout  time.Duration
+	writeTimeout time.Duration
+	bufferSize   int
+	format       string
+}
+
+// networkSchemes maps network URI schemes to their transport
+var networkSchemes = map[string]string{
+	"tcp":      "tcp",
+	"tcp+tls":  backends.NetworkTLS,
+	"tls":      backends.NetworkTLS,
+	"udp":      "udp",
+	"unix":     "unix",
+	"unixgram": "unixgram",
+}
+
+// isNetworkURI reports whether uri names a socket destination: tcp://,
+// tcp+tls:// (or tls://), udp://, unix:// or unixgram://
+func isNetworkURI(uri string) bool {
+	scheme, _, ok := strings.Cut(uri, "://")
+	if !ok {
+		return false
+	}
+	_, known := networkSchemes[scheme]
+	return known
+}
+
+// parseNetworkURI parses a network destination URI such as
+// "tcp://logstash:5000", "tcp+tls://vector:9000?ca=/etc/ssl/ca.pem",
+// "udp://127.0.0.1:5514" or "unix:///var/run/vector.sock?framing=null".
+//
+// Supported query parameters are framing (newline, length or null),
+// ca, cert, key, server_name, connect_timeout, timeout (write timeout),
+// buffer (entries kept while disconnected) and format (the name of the
+// formatter for this destination, such as msgpack).
+func parseNetworkURI(uri string) (networkConfig, error) {
+	var cfg networkConfig
+
+	scheme, rest, ok := strings.Cut(uri, "://")
+	network, known := networkSchemes[scheme]
+	if !ok || !known {
+		return cfg, fmt.Errorf("invalid network URI %q", uri)
+	}
+	cfg.network = network
+
+	address, rawQuery, _ := strings.Cut(rest, "?")
+	cfg.address = address
+	if cfg.address == "" {
+		return cfg, fmt.Errorf("network URI %q: missing address", uri)
+	}
+	if !strings.HasPrefix(cfg.network, "unix") {
+		if _, _, err := net.SplitHostPort(cfg.address); err != nil {
+			return cfg, fmt.Errorf("network URI %q: address needs host and port", uri)
+		}
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return cfg, fmt.Errorf("invalid network URI query: %w", err)
+	}
+	for key, values := range query {
+		value := values[len(values)-1]
+		switch key {
+		case "framing":
+			if cfg.framing, err = backends.ParseNetworkFraming(value); err != nil {
+				return cfg, err
+			}
+		case "ca":
+			cfg.caFile = value
+		case "cert":
+			cfg.certFile = value
+		case "key":
+			cfg.keyFile = value
+		case "server_name":
+			cfg.serverName = value
+		case "connect_timeout":
+			if cfg.dialTimeout, err = time.ParseDuration(value); err != nil || cfg.dialTimeout <= 0 {
+				return cfg, fmt.Errorf("invalid network connect timeout %q", value)
+			}
+		case "timeout":
+			if cfg.writeTimeout, err = time.ParseDuration(value); err != nil || cfg.writeTimeout <= 0 {
+				return cfg, fmt.Errorf("invalid network timeout %q", value)
+			}
+		case "buffer":
+			if cfg.bufferSize, err = strconv.Atoi(value); err != nil || cfg.bufferSize <= 0 {
+				return cfg, fmt.Errorf("invalid network buffer size %q", value)
+			}
+		case "format":
+			cfg.format = value
+		default:
+			return cfg, fmt.Errorf("unknown network URI parameter %q", key)
+		}
+	}
+
+	if (cfg.caFile != "" || cfg.certFile != "" || cfg.serverName != "") && cfg.network != backends.NetworkTLS {
+		return cfg, fmt.Errorf("network URI %q: TLS parameters need the tcp+tls scheme", uri)
+	}
+	if (cfg.certFile == "") != (cfg.keyFile == "") {
+		return cfg, fmt.Errorf("network URI %q: cert and key must be given together", uri)
+	}
+
+	return cfg, nil
+}
+
+// createNetworkBackend creates a socket backend configured from its URI.
+// Binary entries, such as MessagePack, are sent without trimming newlines.
+func createNetworkBackend(uri string, binary bool) (backends.Backend, error) {
+	cfg, err := parseNetworkURI(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	config := backends.NetworkConfig{
+		Network:      cfg.network,
+		Address:      cfg.address,
+		Framing:      cfg.framing,
+		DialTimeout:  cfg.dialTimeout,
+		WriteTimeout: cfg.writeTimeout,
+		BufferSize:   cfg.bufferSize,
+		Binary:       binary,
+	}
+	if cfg.network == backends.NetworkTLS {
+		if config.TLSConfig, err = loadTLSConfig(cfg.caFile, cfg.certFile, cfg.keyFile, cfg.serverName); err != nil {
+			return nil, err
+		}
+	}
+
+	return backends.NewNetworkBackend(config)
+}
+
+// loadTLSConfig builds a TLS client configuration trusting the CA in caFile
+// (the system roots when empty) and presenting the certFile/keyFile pair
+func loadTLSConfig(caFile, certFile, keyFile, serverName string) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+	}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile) // #nosec G304 - path comes from the destination URI
+		if err != nil {
+			return nil, fmt.Errorf("read CA: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA %s", caFile)
+		}
+	}
+
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
@@ -0,0 +1,188 @@
+package omni
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/backends"
+	"github.com/wayneeseguin/omni/pkg/formatters"
+)
+
+func TestParseNetworkURI(t *testing.T) {
+	tests := []struct {
+		uri      string
+		expected networkConfig
+	}{
+		{
+			uri:      "tcp://logstash:5000",
+			expected: networkConfig{network: "tcp", address: "logstash:5000"},
+		},
+		{
+			uri:      "udp://127.0.0.1:5514?buffer=10",
+			expected: networkConfig{network: "udp", address: "127.0.0.1:5514", bufferSize: 10},
+		},
+		{
+			uri:      "unix:///var/run/vector.sock?framing=null",
+			expected: networkConfig{network: "unix", address: "/var/run/vector.sock", framing: backends.NetworkFramingNull},
+		},
+		{
+			uri: "tcp+tls://[::1]:9000?ca=/ca.pem&cert=/c.pem&key=/k.pem&server_name=vector&framing=length&connect_timeout=1s&timeout=2s",
+			expected: networkConfig{network: backends.NetworkTLS, address: "[::1]:9000", framing: backends.NetworkFramingLength,
+				caFile: "/ca.pem", certFile: "/c.pem", keyFile: "/k.pem", serverName: "vector", dialTimeout: time.Second, writeTimeout: 2 * time.Second},
+		},
+		{
+			uri:      "tcp://collector:5170?format=msgpack&framing=length",
+			expected: networkConfig{network: "tcp", address: "collector:5170", framing: backends.NetworkFramingLength, format: "msgpack"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			cfg, err := parseNetworkURI(tt.uri)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if cfg != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, cfg)
+			}
+		})
+	}
+
+	for _, uri := range []string{
+		"tcp://",
+		"tcp://logstash",
+		"tcp://logstash:5000?framing=crlf",
+		"tcp://logstash:5000?ca=/ca.pem",
+		"tcp+tls://logstash:5000?key=/k.pem",
+		"udp://127.0.0.1:5514?timeout=soon",
+		"unix:///tmp/s.sock?fraiming=null",
+		"sctp://logstash:5000",
+	} {
+		if _, err := parseNetworkURI(uri); err == nil {
+			t.Errorf("Expected error for %q", uri)
+		}
+	}
+}
+
+func TestNetworkDestinationTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	lines := make(chan string, 10)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	logger, err := New(filepath.Join(t.TempDir(), "test.log"))
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+	logger.SetFormat(FormatJSON)
+
+	if err := logger.AddDestination("tcp://" + listener.Addr().String()); err != nil {
+		t.Fatalf("Failed to add network destination: %v", err)
+	}
+
+	logger.InfoWithFields("shipped", map[string]interface{}{"service": "api"})
+	if err := logger.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	select {
+	case line := <-lines:
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Expected one JSON entry per line, got %q: %v", line, err)
+		}
+		if entry["message"] != "shipped" {
+			t.Errorf("Unexpected entry %q", line)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Timed out waiting for the entry")
+	}
+}
+
+func TestNetworkDestinationMsgpack(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	entries := make(chan []byte, 10)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			var size uint32
+			if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+				return
+			}
+			entry := make([]byte, size)
+			if _, err := io.ReadFull(r, entry); err != nil {
+				return
+			}
+			entries <- entry
+		}
+	}()
+
+	logger, err := New(filepath.Join(t.TempDir(), "test.log"))
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+	logger.SetFormat(FormatJSON)
+
+	address := listener.Addr().String()
+	if err := logger.AddDestination("tcp://" + address + "?format=msgpack"); err == nil {
+		t.Error("Expected error for MessagePack with newline framing")
+	}
+	if err := logger.AddDestination("tcp://" + address + "?format=protobuf&framing=length"); err == nil {
+		t.Error("Expected error for an unknown formatter")
+	}
+	if err := logger.AddDestination("tcp://" + address + "?format=msgpack&framing=length"); err != nil {
+		t.Fatalf("Failed to add network destination: %v", err)
+	}
+
+	// Field types survive the trip, unlike with JSON
+	logger.InfoWithFields("shipped", map[string]interface{}{"status": 200, "ratio": 0.5, "ok": true})
+	if err := logger.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	select {
+	case data := <-entries:
+		entry, rest, err := formatters.DecodeMsgpackEntry(data)
+		if err != nil || len(rest) != 0 {
+			t.Fatalf("Expected one MessagePack entry, got % x: %v", data, err)
+		}
+		if entry.Message != "shipped" || entry.Level != "INFO" || entry.Fields["status"] != int64(200) ||
+			entry.Fields["ratio"] != 0.5 || entry.Fields["ok"] != true {
+			t.Errorf("Unexpected entry %+v", entry)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Timed out waiting for the entry")
+	}
+}
@@ -3,6 +3,9 @@ package omni
 import (
 	"fmt"
 	"time"
+
+	"github.com/wayneeseguin/omni/pkg/features"
+	"github.com/wayneeseguin/omni/pkg/formatters"
 )
 
 // Option is a functional option for configuring Omni.
@@ -96,7 +99,9 @@ func WithLevel(level int) Option {
 }
 
 // WithFormat sets the output format.
-// Supported formats are FormatText and FormatJSON.
+// Supported formats are FormatText, FormatJSON, FormatGELF, FormatLogfmt,
+// FormatCEF, FormatLEEF, FormatConsole, FormatPattern, FormatMsgpack,
+// FormatCBOR, FormatCSV and FormatTSV.
 //
 // Parameters:
 //   - format: The output format constant
@@ -105,7 +110,7 @@ func WithLevel(level int) Option {
 //   - Option: The configuration option
 func WithFormat(format int) Option {
 	return func(c *Config) error {
-		if format != FormatText && format != FormatJSON {
+		if _, err := newFormatter(format); err != nil {
 			return NewOmniError(ErrCodeInvalidFormat, "config", "", nil).
 				WithContext("format", fmt.Sprintf("%d", format))
 		}
@@ -196,6 +201,135 @@ func WithGzipCompression() Option {
 	return WithCompression(CompressionGzip, 1)
 }
 
+// WithZstdCompression enables zstd compression.
+// This is a convenience method for WithCompression(CompressionZstd, 1).
+//
+// Returns:
+//   - Option: The configuration option
+func WithZstdCompression() Option {
+	return WithCompression(CompressionZstd, 1)
+}
+
+// WithCompressionLevel sets the compression level for rotated files.
+// Use 0 for the algorithm default, 1-9 for gzip, or 1-22 for zstd.
+// Apply it after the option that selects the compression type.
+// The level also applies to stream compression.
+//
+// Parameters:
+//   - level: The compression level
+//
+// Returns:
+//   - Option: The configuration option
+func WithCompressionLevel(level int) Option {
+	return func(c *Config) error {
+		compressionType := c.Compression
+		if compressionType == CompressionNone {
+			compressionType = c.StreamCompression
+		}
+		if err := features.ValidateCompressionLevel(features.CompressionType(compressionType), level); err != nil {
+			return NewOmniError(ErrCodeInvalidConfig, "config", "", err).
+				WithContext("compressionLevel", fmt.Sprintf("%d", level))
+		}
+		c.CompressionLevel = level
+		return nil
+	}
+}
+
+// WithStreamCompression compresses file destinations in-line instead of after rotation.
+// Entries are written as gzip or zstd frames, so the active file is always a valid
+// compressed stream and a crash loses at most the frame being built. Frames are
+// completed on Sync, rotation and every flushInterval (0 uses the default of 1s).
+// The level set by WithCompressionLevel applies to the stream as well. Disk
+// space limits set by WithDiskSpace are not enforced on compressed streams, so
+// the logger rejects the combination.
+//
+// Parameters:
+//   - compressionType: CompressionGzip or CompressionZstd
+//   - flushInterval: How often to complete a frame
+//
+// Returns:
+//   - Option: The configuration option
+func WithStreamCompression(compressionType int, flushInterval time.Duration) Option {
+	return func(c *Config) error {
+		if compressionType != CompressionGzip && compressionType != CompressionZstd {
+			return NewOmniError(ErrCodeInvalidConfig, "config", "", nil).
+				WithContext("streamCompression", fmt.Sprintf("%d", compressionType))
+		}
+		if flushInterval < 0 {
+			return NewOmniError(ErrCodeInvalidConfig, "config", "", nil).
+				WithContext("streamFlushInterval", flushInterval.String())
+		}
+		c.StreamCompression = compressionType
+		c.StreamFlushInterval = flushInterval
+		return nil
+	}
+}
+
+// WithRotationManifest records rotated files in a manifest next to each log.
+// The manifest lists each rotated file's first and last timestamp, entry
+// count, per-level counts, size, compression and SHA-256 checksum, so readers
+// can skip files outside a time window and verify integrity.
+//
+// Returns:
+//   - Option: The configuration option
+func WithRotationManifest() Option {
+	return func(c *Config) error {
+		c.RotationManifest = true
+		return nil
+	}
+}
+
+// WithDiskSpace enables disk budgets and free-space monitoring for file destinations.
+// When the filesystem runs low, the configured LowSpacePolicy decides whether old
+// rotated files are reclaimed, trace/debug messages are shed, writes move to a
+// fallback path, or writes stop.
+//
+// Parameters:
+//   - config: Disk space configuration
+//
+// Returns:
+//   - Option: The configuration option
+func WithDiskSpace(config DiskSpaceConfig) Option {
+	return func(c *Config) error {
+		if config.MaxTotalBytes < 0 || config.MaxDirBytes < 0 {
+			return NewOmniError(ErrCodeInvalidConfig, "config", "", nil).
+				WithContext("diskBudget", fmt.Sprintf("%d/%d", config.MaxTotalBytes, config.MaxDirBytes))
+		}
+		if config.MinFreePercent < 0 || config.MinFreePercent > 100 {
+			return NewOmniError(ErrCodeInvalidConfig, "config", "", nil).
+				WithContext("minFreePercent", fmt.Sprintf("%.2f", config.MinFreePercent))
+		}
+		if config.Policy == LowSpaceFallback && config.FallbackPath == "" {
+			return NewOmniError(ErrCodeInvalidConfig, "config", "", nil).
+				WithContext("error", "fallback policy requires a fallback path")
+		}
+		c.DiskSpace = &config
+		return nil
+	}
+}
+
+// WithDiskBudget caps the bytes used by each file destination including its
+// rotated files (maxTotal) and by the destination's directory (maxDir).
+// A value of 0 disables the corresponding budget.
+//
+// Parameters:
+//   - maxTotal: Maximum bytes for a destination and its rotated files
+//   - maxDir: Maximum bytes for the log directory
+//
+// Returns:
+//   - Option: The configuration option
+func WithDiskBudget(maxTotal, maxDir int64) Option {
+	return func(c *Config) error {
+		config := DiskSpaceConfig{}
+		if c.DiskSpace != nil {
+			config = *c.DiskSpace
+		}
+		config.MaxTotalBytes = maxTotal
+		config.MaxDirBytes = maxDir
+		return WithDiskSpace(config)(c)
+	}
+}
+
 // WithStackTrace enables stack trace capture.
 // Stack traces will be included in error-level logs.
 //
@@ -395,6 +529,143 @@ func WithTimestampFormat(format string) Option {
 	}
 }
 
+// WithJSONPreset selects JSON output in a vendor schema.
+// Presets rename the timestamp, level and stack trace keys, move trace_id and
+// span_id fields to the vendor's trace keys and write fields at the top level.
+//
+// Parameters:
+//   - preset: JSONPresetECS, JSONPresetGCP, JSONPresetDatadog or JSONPresetNone
+//
+// Returns:
+//   - Option: The configuration option
+//
+// Example:
+//
+//	WithJSONPreset(omni.JSONPresetECS)
+func WithJSONPreset(preset JSONPreset) Option {
+	return func(c *Config) error {
+		if preset < JSONPresetNone || preset > JSONPresetDatadog {
+			return NewOmniError(ErrCodeInvalidConfig, "config", "", nil).
+				WithContext("json_preset", fmt.Sprintf("%d", preset))
+		}
+		c.Format = FormatJSON
+		c.FormatOptions.JSONPreset = preset
+		return nil
+	}
+}
+
+// WithGCPProject selects the JSONPresetGCP schema, writing trace_id fields
+// as "projects/<project>/traces/<trace_id>" under logging.googleapis.com/trace
+// so Cloud Logging links entries to their traces.
+//
+// Parameters:
+//   - project: The Google Cloud project ID of the traces
+//
+// Returns:
+//   - Option: The configuration option
+//
+// Example:
+//
+//	WithGCPProject("my-project")
+func WithGCPProject(project string) Option {
+	return func(c *Config) error {
+		if project == "" {
+			return NewOmniError(ErrCodeInvalidConfig, "config", "", nil).
+				WithContext("error", "GCP project cannot be empty")
+		}
+		c.Format = FormatJSON
+		c.FormatOptions.JSONPreset = JSONPresetGCP
+		c.FormatOptions.GCPProject = project
+		return nil
+	}
+}
+
+// WithJSONArray selects JSON output written to files as one JSON array.
+// Each file opens with "[" and gets its "]" when rotated or closed; entries
+// after the first are led by a comma so each still starts a line. Reopening
+// a finished file removes its "]" so appended entries stay in the array.
+// Stream-compressed files cannot be reopened, so they fail to open as arrays.
+//
+// Returns:
+//   - Option: The configuration option
+func WithJSONArray() Option {
+	return func(c *Config) error {
+		c.Format = FormatJSON
+		c.FormatOptions.JSONArray = true
+		return nil
+	}
+}
+
+// WithCSV selects comma-separated rows for spreadsheets and warehouses.
+// Columns are "timestamp", "level", "message", "stack_trace" and field names,
+// defaulting to timestamp, level and message. Fields outside the columns are
+// written as a JSON object in a last "fields" column, or left out when
+// FormatOptions.CSVExtra is CSVExtraDrop. Every new log file, including
+// after rotation, starts with a header row.
+//
+// Parameters:
+//   - columns: The columns of each row
+//
+// Returns:
+//   - Option: The configuration option
+//
+// Example:
+//
+//	WithCSV("timestamp", "level", "user_id", "action", "message")
+func WithCSV(columns ...string) Option {
+	return withColumns(FormatCSV, columns)
+}
+
+// WithTSV selects tab-separated rows, with columns as for WithCSV.
+//
+// Parameters:
+//   - columns: The columns of each row
+//
+// Returns:
+//   - Option: The configuration option
+func WithTSV(columns ...string) Option {
+	return withColumns(FormatTSV, columns)
+}
+
+// withColumns selects a delimited format with the given columns
+func withColumns(format int, columns []string) Option {
+	return func(c *Config) error {
+		for _, column := range columns {
+			if column == "" {
+				return NewOmniError(ErrCodeInvalidConfig, "config", "", nil).
+					WithContext("error", "column name cannot be empty")
+			}
+		}
+		c.Format = format
+		c.FormatOptions.CSVColumns = columns
+		return nil
+	}
+}
+
+// WithPattern selects output laid out by a log4j-style pattern.
+// See formatters.CompilePattern for the conversions.
+//
+// Parameters:
+//   - layout: The pattern layout (cannot be empty)
+//
+// Returns:
+//   - Option: The configuration option
+//
+// Example:
+//
+//	WithPattern("%d{ISO8601} %-5level [%thread] %logger - %msg%n")
+func WithPattern(layout string) Option {
+	return func(c *Config) error {
+		if _, err := formatters.CompilePattern(layout); err != nil || layout == "" {
+			return NewOmniError(ErrCodeInvalidFormat, "config", "", err).
+				WithContext("pattern", layout)
+		}
+		c.Format = FormatPattern
+		c.FormatOptions.Pattern = layout
+		return nil
+	}
+}
+
 // WithRecovery enables recovery with fallback.
 // If the primary log destination fails, logging will fall back to the specified path.
 //
@@ -504,7 +775,7 @@ func WithProductionDefaults() Option {
 // WithDevelopmentDefaults sets recommended development settings.
 // Configures the logger with:
 // - Debug level logging
-// - Text format
+// - Console format (coloured with relative times on terminals, plain with absolute times in files)
 // - Moderate buffer (1000)
 // - Stack traces enabled
 // - Local timestamps
@@ -515,7 +786,7 @@ func WithProductionDefaults() Option {
 func WithDevelopmentDefaults() Option {
 	return func(c *Config) error {
 		c.Level = LevelDebug
-		c.Format = FormatText
+		c.Format = FormatConsole
 		c.ChannelSize = 1000
 		c.IncludeTrace = true
 		c.ErrorHandler = StderrErrorHandler
@@ -0,0 +1,124 @@
+package omni
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/backends"
+)
+
+// otlpResourcePrefix marks query parameters that set resource attributes
+const otlpResourcePrefix = "resource."
+
+// isOTLPURI reports whether uri names an OTLP destination: otlp+http:// or otlp+https://
+func isOTLPURI(uri string) bool {
+	return strings.HasPrefix(uri, "otlp+http://") || strings.HasPrefix(uri, "otlp+https://")
+}
+
+// parseOTLPURI parses an OTLP/HTTP logs destination URI such as
+// "otlp+http://collector:4318?service=checkout&resource.deployment.environment=prod".
+// Without a path, records are sent to /v1/logs.
+//
+// Supported query parameters are encoding (protobuf or json), service,
+// resource.<name>, header.<Name>, gzip, batch, batch_bytes, interval,
+// timeout, retries, in_flight and ca.
+func parseOTLPURI(uri string) (backends.OTLPConfig, error) {
+	var cfg backends.OTLPConfig
+
+	if !isOTLPURI(uri) {
+		return cfg, fmt.Errorf("invalid OTLP URI %q", uri)
+	}
+	scheme := "http"
+	if strings.HasPrefix(uri, "otlp+https://") {
+		scheme = "https"
+	}
+
+	endpoint, err := url.Parse(uri)
+	if err != nil || endpoint.Host == "" {
+		return cfg, fmt.Errorf("invalid OTLP URI %q", uri)
+	}
+	endpoint.Scheme = scheme
+
+	var caFile string
+	for key, values := range endpoint.Query() {
+		value := values[len(values)-1]
+		switch {
+		case strings.HasPrefix(key, otlpResourcePrefix):
+			if cfg.ResourceAttributes == nil {
+				cfg.ResourceAttributes = make(map[string]string)
+			}
+			cfg.ResourceAttributes[strings.TrimPrefix(key, otlpResourcePrefix)] = value
+		case strings.HasPrefix(key, httpHeaderPrefix):
+			if cfg.Headers == nil {
+				cfg.Headers = make(map[string]string)
+			}
+			cfg.Headers[strings.TrimPrefix(key, httpHeaderPrefix)] = value
+		case key == "encoding":
+			if cfg.Encoding, err = backends.ParseOTLPEncoding(value); err != nil {
+				return cfg, err
+			}
+		case key == "service":
+			cfg.ServiceName = value
+		case key == "gzip":
+			if cfg.Compress, err = strconv.ParseBool(value); err != nil {
+				return cfg, fmt.Errorf("invalid OTLP gzip value %q", value)
+			}
+		case key == "batch":
+			if cfg.BatchSize, err = strconv.Atoi(value); err != nil || cfg.BatchSize <= 0 {
+				return cfg, fmt.Errorf("invalid OTLP batch size %q", value)
+			}
+		case key == "batch_bytes":
+			if cfg.BatchBytes, err = strconv.Atoi(value); err != nil || cfg.BatchBytes <= 0 {
+				return cfg, fmt.Errorf("invalid OTLP batch bytes %q", value)
+			}
+		case key == "interval":
+			if cfg.FlushInterval, err = time.ParseDuration(value); err != nil || cfg.FlushInterval <= 0 {
+				return cfg, fmt.Errorf("invalid OTLP flush interval %q", value)
+			}
+		case key == "timeout":
+			if cfg.Timeout, err = time.ParseDuration(value); err != nil || cfg.Timeout <= 0 {
+				return cfg, fmt.Errorf("invalid OTLP timeout %q", value)
+			}
+		case key == "retries":
+			if cfg.MaxRetries, err = strconv.Atoi(value); err != nil || cfg.MaxRetries < 0 {
+				return cfg, fmt.Errorf("invalid OTLP retries %q", value)
+			}
+			if cfg.MaxRetries == 0 {
+				cfg.MaxRetries = -1
+			}
+		case key == "in_flight":
+			if cfg.MaxInFlight, err = strconv.Atoi(value); err != nil || cfg.MaxInFlight <= 0 {
+				return cfg, fmt.Errorf("invalid OTLP in_flight %q", value)
+			}
+		case key == "ca":
+			caFile = value
+		default:
+			return cfg, fmt.Errorf("unknown OTLP URI parameter %q", key)
+		}
+	}
+	endpoint.RawQuery = ""
+	cfg.URL = endpoint.String()
+
+	if caFile != "" {
+		if scheme != "https" {
+			return cfg, fmt.Errorf("OTLP URI %q: ca needs the otlp+https scheme", uri)
+		}
+		if cfg.TLSConfig, err = loadTLSConfig(caFile, "", "", ""); err != nil {
+			return cfg, err
+		}
+	}
+
+	return cfg, nil
+}
+
+// createOTLPBackend creates an OTLP backend configured from its URI
+func createOTLPBackend(uri string) (backends.Backend, error) {
+	cfg, err := parseOTLPURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	return backends.NewOTLPBackend(cfg)
+}
@@ -0,0 +1,130 @@
+package omni
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/backends"
+)
+
+func TestParseOTLPURI(t *testing.T) {
+	cfg, err := parseOTLPURI("otlp+https://collector.example.com:4318?encoding=json&service=checkout&resource.deployment.environment=prod&header.Authorization=Bearer%20abc&gzip=true&batch=512&interval=5s")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := backends.OTLPConfig{
+		URL:                "https://collector.example.com:4318",
+		Encoding:           backends.OTLPEncodingJSON,
+		ServiceName:        "checkout",
+		ResourceAttributes: map[string]string{"deployment.environment": "prod"},
+		Headers:            map[string]string{"Authorization": "Bearer abc"},
+		Compress:           true,
+		BatchSize:          512,
+		FlushInterval:      5 * time.Second,
+	}
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, cfg)
+	}
+
+	if cfg, err := parseOTLPURI("otlp+http://collector:4318/custom/v1/logs"); err != nil || cfg.URL != "http://collector:4318/custom/v1/logs" || cfg.Encoding != backends.OTLPEncodingProtobuf {
+		t.Errorf("Unexpected config %+v, %v", cfg, err)
+	}
+
+	for _, uri := range []string{
+		"otlp+http://",
+		"otlp+http://collector?encoding=xml",
+		"otlp+http://collector?batch=0",
+		"otlp+http://collector?ca=/ca.pem",
+		"otlp+http://collector?servce=api",
+	} {
+		if _, err := parseOTLPURI(uri); err == nil {
+			t.Errorf("Expected error for %q", uri)
+		}
+	}
+}
+
+func TestOTLPDestination(t *testing.T) {
+	type keyValue struct {
+		Key   string            `json:"key"`
+		Value map[string]string `json:"value"`
+	}
+	var mu sync.Mutex
+	var resources, attributes [][]keyValue
+	var traceIDs, spanIDs []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			ResourceLogs []struct {
+				Resource struct {
+					Attributes []keyValue `json:"attributes"`
+				} `json:"resource"`
+				ScopeLogs []struct {
+					LogRecords []struct {
+						Attributes []keyValue `json:"attributes"`
+						TraceID    string     `json:"traceId"`
+						SpanID     string     `json:"spanId"`
+					} `json:"logRecords"`
+				} `json:"scopeLogs"`
+			} `json:"resourceLogs"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		mu.Lock()
+		for _, resourceLogs := range request.ResourceLogs {
+			resources = append(resources, resourceLogs.Resource.Attributes)
+			for _, record := range resourceLogs.ScopeLogs[0].LogRecords {
+				attributes = append(attributes, record.Attributes)
+				traceIDs = append(traceIDs, record.TraceID)
+				spanIDs = append(spanIDs, record.SpanID)
+			}
+		}
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	logger, err := New(filepath.Join(t.TempDir(), "test.log"))
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+	logger.SetGlobalFields(map[string]interface{}{"host.name": "web-1"})
+
+	uri := "otlp+http://" + server.Listener.Addr().String() + "?encoding=json&service=checkout&interval=1h"
+	if err := logger.AddDestination(uri); err != nil {
+		t.Fatalf("Failed to add OTLP destination: %v", err)
+	}
+
+	ctx := ContextWithTrace(context.Background(), "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7")
+	logger.WithContext(ctx).WithField("order", "A-17").Info("charged")
+	if err := logger.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(resources) != 1 || len(attributes) != 1 {
+		t.Fatalf("Expected 1 record, got %v", attributes)
+	}
+	expectedResource := []keyValue{
+		{Key: "host.name", Value: map[string]string{"stringValue": "web-1"}},
+		{Key: "service.name", Value: map[string]string{"stringValue": "checkout"}},
+	}
+	if !reflect.DeepEqual(resources[0], expectedResource) {
+		t.Errorf("Expected resource %v, got %v", expectedResource, resources[0])
+	}
+	expectedAttributes := []keyValue{{Key: "order", Value: map[string]string{"stringValue": "A-17"}}}
+	if !reflect.DeepEqual(attributes[0], expectedAttributes) {
+		t.Errorf("Expected attributes %v, got %v", expectedAttributes, attributes[0])
+	}
+	if traceIDs[0] != "4bf92f3577b34da6a3ce929d0e0e4736" || spanIDs[0] != "00f067aa0ba902b7" {
+		t.Errorf("Unexpected trace context %q/%q", traceIDs[0], spanIDs[0])
+	}
+}
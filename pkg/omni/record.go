@@ -0,0 +1,48 @@
+package omni
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/wayneeseguin/omni/pkg/backends"
+)
+
+// logRecord converts a log message and its formatted entry for backends
+// taking structured records, adding the global fields to the entry fields
+func (f *Omni) logRecord(msg LogMessage, line []byte) backends.Record {
+	rec := backends.Record{
+		Timestamp: msg.Timestamp,
+		Level:     strings.ToLower(levelToString(msg.Level)),
+		Line:      line,
+	}
+
+	switch {
+	case msg.Entry != nil:
+		rec.Message = msg.Entry.Message
+		rec.Fields = msg.Entry.Fields
+		rec.SourceFile = msg.Entry.File
+		rec.SourceLine = msg.Entry.Line
+		if msg.Entry.Level != "" {
+			rec.Level = strings.ToLower(msg.Entry.Level)
+		}
+	case msg.Raw != nil:
+		rec.Message = strings.TrimSpace(string(msg.Raw))
+	default:
+		rec.Message = fmt.Sprintf(msg.Format, msg.Args...)
+	}
+
+	// Entry fields take precedence over global fields
+	if global := f.GetGlobalFields(); len(global) > 0 {
+		fields := make(map[string]interface{}, len(global)+len(rec.Fields))
+		for key, value := range global {
+			fields[key] = value
+		}
+		for key, value := range rec.Fields {
+			fields[key] = value
+		}
+		rec.Fields = fields
+		rec.Global = global
+	}
+
+	return rec
+}
@@ -0,0 +1,128 @@
+package omni
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/backends"
+)
+
+// isSplunkHECURI reports whether uri names a Splunk HEC destination:
+// splunk-hec:// (HTTP) or splunk-hec+https://
+func isSplunkHECURI(uri string) bool {
+	return strings.HasPrefix(uri, "splunk-hec://") || strings.HasPrefix(uri, "splunk-hec+https://")
+}
+
+// parseSplunkHECURI parses a Splunk HEC destination URI such as
+// "splunk-hec+https://splunk:8088?token=...&sourcetype=_json&index=main&ack=true".
+//
+// Supported query parameters are token (required), host, source, sourcetype,
+// index, ack, channel, ack_timeout, gzip, batch, batch_bytes, interval,
+// timeout, retries, in_flight and ca.
+func parseSplunkHECURI(uri string) (backends.SplunkHECConfig, error) {
+	var cfg backends.SplunkHECConfig
+
+	if !isSplunkHECURI(uri) {
+		return cfg, fmt.Errorf("invalid Splunk HEC URI %q", uri)
+	}
+	scheme := "http"
+	if strings.HasPrefix(uri, "splunk-hec+https://") {
+		scheme = "https"
+	}
+
+	endpoint, err := url.Parse(uri)
+	if err != nil || endpoint.Host == "" {
+		return cfg, fmt.Errorf("invalid Splunk HEC URI %q", uri)
+	}
+	endpoint.Scheme = scheme
+
+	var caFile string
+	for key, values := range endpoint.Query() {
+		value := values[len(values)-1]
+		switch key {
+		case "token":
+			cfg.Token = value
+		case "host":
+			cfg.Host = value
+		case "source":
+			cfg.Source = value
+		case "sourcetype":
+			cfg.SourceType = value
+		case "index":
+			cfg.Index = value
+		case "ack":
+			if cfg.Ack, err = strconv.ParseBool(value); err != nil {
+				return cfg, fmt.Errorf("invalid Splunk HEC ack value %q", value)
+			}
+		case "channel":
+			cfg.Channel = value
+		case "ack_timeout":
+			if cfg.AckTimeout, err = time.ParseDuration(value); err != nil || cfg.AckTimeout <= 0 {
+				return cfg, fmt.Errorf("invalid Splunk HEC ack timeout %q", value)
+			}
+		case "gzip":
+			if cfg.Compress, err = strconv.ParseBool(value); err != nil {
+				return cfg, fmt.Errorf("invalid Splunk HEC gzip value %q", value)
+			}
+		case "batch":
+			if cfg.BatchSize, err = strconv.Atoi(value); err != nil || cfg.BatchSize <= 0 {
+				return cfg, fmt.Errorf("invalid Splunk HEC batch size %q", value)
+			}
+		case "batch_bytes":
+			if cfg.BatchBytes, err = strconv.Atoi(value); err != nil || cfg.BatchBytes <= 0 {
+				return cfg, fmt.Errorf("invalid Splunk HEC batch bytes %q", value)
+			}
+		case "interval":
+			if cfg.FlushInterval, err = time.ParseDuration(value); err != nil || cfg.FlushInterval <= 0 {
+				return cfg, fmt.Errorf("invalid Splunk HEC flush interval %q", value)
+			}
+		case "timeout":
+			if cfg.Timeout, err = time.ParseDuration(value); err != nil || cfg.Timeout <= 0 {
+				return cfg, fmt.Errorf("invalid Splunk HEC timeout %q", value)
+			}
+		case "retries":
+			if cfg.MaxRetries, err = strconv.Atoi(value); err != nil || cfg.MaxRetries < 0 {
+				return cfg, fmt.Errorf("invalid Splunk HEC retries %q", value)
+			}
+			if cfg.MaxRetries == 0 {
+				cfg.MaxRetries = -1
+			}
+		case "in_flight":
+			if cfg.MaxInFlight, err = strconv.Atoi(value); err != nil || cfg.MaxInFlight <= 0 {
+				return cfg, fmt.Errorf("invalid Splunk HEC in_flight %q", value)
+			}
+		case "ca":
+			caFile = value
+		default:
+			return cfg, fmt.Errorf("unknown Splunk HEC URI parameter %q", key)
+		}
+	}
+	endpoint.RawQuery = ""
+	cfg.URL = endpoint.String()
+
+	if cfg.Token == "" {
+		return cfg, fmt.Errorf("splunk HEC URI %q: token is required", uri)
+	}
+	if caFile != "" {
+		if scheme != "https" {
+			return cfg, fmt.Errorf("splunk HEC URI %q: ca needs the splunk-hec+https scheme", uri)
+		}
+		if cfg.TLSConfig, err = loadTLSConfig(caFile, "", "", ""); err != nil {
+			return cfg, err
+		}
+	}
+
+	return cfg, nil
+}
+
+// createSplunkHECBackend creates a Splunk HEC backend configured from its URI
+func createSplunkHECBackend(uri string) (backends.Backend, error) {
+	cfg, err := parseSplunkHECURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	return backends.NewSplunkHECBackend(cfg)
+}
@@ -0,0 +1,97 @@
+package omni
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/backends"
+)
+
+func TestParseSplunkHECURI(t *testing.T) {
+	cfg, err := parseSplunkHECURI("splunk-hec+https://splunk.example.com:8088?token=abc&host=web-1&source=omni&sourcetype=_json&index=main&ack=true&ack_timeout=30s&batch=50&retries=0")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := backends.SplunkHECConfig{
+		URL:        "https://splunk.example.com:8088",
+		Token:      "abc",
+		Host:       "web-1",
+		Source:     "omni",
+		SourceType: "_json",
+		Index:      "main",
+		Ack:        true,
+		AckTimeout: 30 * time.Second,
+		BatchSize:  50,
+		MaxRetries: -1,
+	}
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, cfg)
+	}
+
+	for _, uri := range []string{
+		"splunk-hec://",
+		"splunk-hec://splunk:8088",
+		"splunk-hec://splunk:8088?token=abc&ack=sometimes",
+		"splunk-hec://splunk:8088?token=abc&ca=/ca.pem",
+		"splunk-hec://splunk:8088?token=abc&sourcetyp=_json",
+	} {
+		if _, err := parseSplunkHECURI(uri); err == nil {
+			t.Errorf("Expected error for %q", uri)
+		}
+	}
+}
+
+func TestSplunkHECDestination(t *testing.T) {
+	var mu sync.Mutex
+	var events []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/services/collector/event" || r.Header.Get("Authorization") != "Splunk abc" {
+			http.Error(w, `{"text":"Invalid token","code":4}`, http.StatusForbidden)
+			return
+		}
+		scanner := bufio.NewScanner(r.Body)
+		mu.Lock()
+		for scanner.Scan() {
+			var event map[string]interface{}
+			_ = json.Unmarshal(scanner.Bytes(), &event)
+			events = append(events, event)
+		}
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"text":"Success","code":0}`))
+	}))
+	defer server.Close()
+
+	logger, err := New(filepath.Join(t.TempDir(), "test.log"))
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	uri := "splunk-hec://" + server.Listener.Addr().String() + "?token=abc&sourcetype=_json&interval=1h"
+	if err := logger.AddDestination(uri); err != nil {
+		t.Fatalf("Failed to add Splunk HEC destination: %v", err)
+	}
+
+	logger.WarnWithFields("disk low", map[string]interface{}{"free_mb": 512})
+	if err := logger.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 1 || events[0]["sourcetype"] != "_json" || events[0]["time"] == nil {
+		t.Fatalf("Unexpected events %v", events)
+	}
+	event, _ := events[0]["event"].(map[string]interface{})
+	if event["message"] != "disk low" || event["level"] != "warn" || event["free_mb"] != float64(512) {
+		t.Errorf("Unexpected event %v", events[0]["event"])
+	}
+}
@@ -0,0 +1,266 @@
+package omni
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/backends"
+)
+
+// syslogConfig holds the settings parsed from a syslog destination URI
+type syslogConfig struct {
+	network      string
+	address      string
+	tag          string
+	facility     int
+	format       backends.SyslogFormat
+	framing      backends.SyslogFraming
+	hostname     string
+	msgID        string
+	sdID         string
+	structured   bool
+	caFile       string
+	certFile     string
+	keyFile      string
+	serverName   string
+	writeTimeout time.Duration
+	bufferSize   int
+}
+
+// defaultSyslogFacility is local0
+const defaultSyslogFacility = 16
+
+// syslogNetworks maps syslog URI schemes to their transport
+var syslogNetworks = map[string]string{
+	"syslog+tcp":      "tcp",
+	"syslog+udp":      "udp",
+	"syslog+tls":      backends.SyslogNetworkTLS,
+	"syslog+tcp+tls":  backends.SyslogNetworkTLS,
+	"syslog+unix":     "unix",
+	"syslog+unixgram": "unixgram",
+}
+
+// isSyslogURI reports whether uri names a syslog destination: syslog:// or
+// one of the syslog+<transport>:// schemes
+func isSyslogURI(uri string) bool {
+	scheme, _, ok := strings.Cut(uri, "://")
+	if !ok {
+		return false
+	}
+	_, known := syslogNetworks[scheme]
+	return scheme == "syslog" || known
+}
+
+// parseSyslogURI parses a syslog destination URI such as
+// "syslog://host:514?facility=local3&tag=api&format=rfc5424",
+// "syslog+tls://host:6514?ca=/etc/ssl/ca.pem" or "syslog:///dev/log".
+//
+// The syslog scheme uses TCP for network addresses and detects the socket
+// type of paths. The syslog+tcp, syslog+udp, syslog+tls (or syslog+tcp+tls),
+// syslog+unix and syslog+unixgram schemes select the transport.
+//
+// Supported query parameters are facility, tag, format (basic, rfc3164 or
+// rfc5424), hostname, msgid, sdid, structured (send fields as RFC 5424
+// structured data), framing (newline or octet-counting), ca, cert, key,
+// server_name, timeout (write timeout) and buffer (messages kept while
+// disconnected). The basic format, "<PRI>TAG: MSG", is the default.
+func parseSyslogURI(uri string) (syslogConfig, error) {
+	cfg := syslogConfig{
+		address:  "/dev/log",
+		tag:      "omni",
+		facility: defaultSyslogFacility,
+		format:   backends.SyslogFormatBasic,
+	}
+
+	scheme, rest, ok := strings.Cut(uri, "://")
+	if !ok || !isSyslogURI(uri) {
+		return cfg, fmt.Errorf("invalid syslog URI %q", uri)
+	}
+	network, explicit := syslogNetworks[scheme]
+
+	address, rawQuery, _ := strings.Cut(rest, "?")
+	if address != "" {
+		cfg.address = address
+	}
+
+	switch {
+	case explicit:
+		cfg.network = network
+	case strings.HasPrefix(cfg.address, "/"):
+		cfg.network = backends.SyslogNetworkLocal
+	default:
+		cfg.network = "tcp"
+	}
+	if !strings.HasPrefix(cfg.network, "unix") && cfg.network != backends.SyslogNetworkLocal {
+		if strings.HasPrefix(cfg.address, "/") {
+			return cfg, fmt.Errorf("syslog URI %q: %s needs a host address", uri, cfg.network)
+		}
+		if _, _, err := net.SplitHostPort(cfg.address); err != nil {
+			// Default syslog port
+			port := "514"
+			if cfg.network == backends.SyslogNetworkTLS {
+				port = "6514"
+			}
+			host := strings.TrimSuffix(strings.TrimPrefix(cfg.address, "["), "]")
+			cfg.address = net.JoinHostPort(host, port)
+		}
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return cfg, fmt.Errorf("invalid syslog URI query: %w", err)
+	}
+	for key, values := range query {
+		value := values[len(values)-1]
+		switch key {
+		case "facility":
+			if cfg.facility, err = backends.ParseSyslogFacility(value); err != nil {
+				return cfg, err
+			}
+		case "format":
+			if cfg.format, err = backends.ParseSyslogFormat(value); err != nil {
+				return cfg, err
+			}
+		case "framing":
+			if cfg.framing, err = backends.ParseSyslogFraming(value); err != nil {
+				return cfg, err
+			}
+		case "tag":
+			cfg.tag = value
+		case "hostname":
+			cfg.hostname = value
+		case "msgid":
+			cfg.msgID = value
+		case "sdid":
+			cfg.sdID = value
+		case "structured":
+			if cfg.structured, err = strconv.ParseBool(value); err != nil {
+				return cfg, fmt.Errorf("invalid syslog structured value %q", value)
+			}
+		case "ca":
+			cfg.caFile = value
+		case "cert":
+			cfg.certFile = value
+		case "key":
+			cfg.keyFile = value
+		case "server_name":
+			cfg.serverName = value
+		case "timeout":
+			if cfg.writeTimeout, err = time.ParseDuration(value); err != nil || cfg.writeTimeout <= 0 {
+				return cfg, fmt.Errorf("invalid syslog timeout %q", value)
+			}
+		case "buffer":
+			if cfg.bufferSize, err = strconv.Atoi(value); err != nil || cfg.bufferSize <= 0 {
+				return cfg, fmt.Errorf("invalid syslog buffer size %q", value)
+			}
+		default:
+			return cfg, fmt.Errorf("unknown syslog URI parameter %q", key)
+		}
+	}
+
+	if (cfg.caFile != "" || cfg.certFile != "" || cfg.serverName != "") && cfg.network != backends.SyslogNetworkTLS {
+		return cfg, fmt.Errorf("syslog URI %q: TLS parameters need the syslog+tls scheme", uri)
+	}
+	if cfg.structured && cfg.format != backends.SyslogFormatRFC5424 {
+		return cfg, fmt.Errorf("syslog URI %q: structured data needs format=rfc5424", uri)
+	}
+	if (cfg.certFile == "") != (cfg.keyFile == "") {
+		return cfg, fmt.Errorf("syslog URI %q: cert and key must be given together", uri)
+	}
+
+	return cfg, nil
+}
+
+// createSyslogBackend creates a syslog backend configured from its URI
+func createSyslogBackend(uri string) (backends.Backend, error) {
+	cfg, err := parseSyslogURI(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	config := backends.SyslogConfig{
+		Network:      cfg.network,
+		Address:      cfg.address,
+		Priority:     cfg.facility<<3 | backends.SyslogSeverityInfo,
+		Tag:          cfg.tag,
+		Framing:      cfg.framing,
+		WriteTimeout: cfg.writeTimeout,
+		BufferSize:   cfg.bufferSize,
+	}
+	if cfg.network == backends.SyslogNetworkTLS {
+		if config.TLSConfig, err = loadTLSConfig(cfg.caFile, cfg.certFile, cfg.keyFile, cfg.serverName); err != nil {
+			return nil, err
+		}
+	}
+
+	backend, err := backends.NewSyslogBackendWithConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	backend.SetFormat(cfg.format)
+	if cfg.hostname != "" {
+		backend.SetHostname(cfg.hostname)
+	}
+	if cfg.msgID != "" {
+		backend.SetMsgID(cfg.msgID)
+	}
+	if cfg.sdID != "" {
+		backend.SetStructuredDataID(cfg.sdID)
+	}
+	backend.SetStructuredData(cfg.structured)
+
+	return backend, nil
+}
+
+// syslogSeverity maps a log level to its syslog severity
+func syslogSeverity(level int) int {
+	switch {
+	case level >= LevelError:
+		return backends.SyslogSeverityError
+	case level == LevelWarn:
+		return backends.SyslogSeverityWarning
+	case level == LevelInfo:
+		return backends.SyslogSeverityInfo
+	default:
+		return backends.SyslogSeverityDebug
+	}
+}
+
+// syslogMessage converts a log message for a syslog backend, which carries
+// the timestamp and level in the syslog header. The formatted entry, data, is
+// the message unless fields are sent as structured data, which then carries
+// the fields with the entry's message text.
+func syslogMessage(msg LogMessage, data []byte, structured bool) backends.SyslogMessage {
+	sm := backends.SyslogMessage{
+		Severity:  syslogSeverity(msg.Level),
+		Timestamp: msg.Timestamp,
+	}
+	if !structured {
+		sm.Message = strings.TrimSpace(string(data))
+		return sm
+	}
+
+	switch {
+	case msg.Entry != nil:
+		sm.Message = msg.Entry.Message
+		sm.Fields = msg.Entry.Fields
+		if msg.Entry.StackTrace != "" {
+			sm.Fields = make(map[string]interface{}, len(msg.Entry.Fields)+1)
+			for key, value := range msg.Entry.Fields {
+				sm.Fields[key] = value
+			}
+			sm.Fields["stack_trace"] = msg.Entry.StackTrace
+		}
+	case msg.Raw != nil:
+		sm.Message = strings.TrimSpace(string(msg.Raw))
+	default:
+		sm.Message = fmt.Sprintf(msg.Format, msg.Args...)
+	}
+
+	return sm
+}
@@ -0,0 +1,344 @@
+package omni
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	testhelpers "github.com/wayneeseguin/omni/internal/testing"
+	"github.com/wayneeseguin/omni/pkg/backends"
+	"github.com/wayneeseguin/omni/pkg/formatters"
+)
+
+func TestParseSyslogURI(t *testing.T) {
+	tests := []struct {
+		uri      string
+		expected syslogConfig
+	}{
+		{
+			uri:      "syslog://",
+			expected: syslogConfig{network: backends.SyslogNetworkLocal, address: "/dev/log", tag: "omni", facility: 16},
+		},
+		{
+			uri:      "syslog:///var/run/syslog?tag=worker",
+			expected: syslogConfig{network: backends.SyslogNetworkLocal, address: "/var/run/syslog", tag: "worker", facility: 16},
+		},
+		{
+			uri:      "syslog://logs.example.com",
+			expected: syslogConfig{network: "tcp", address: "logs.example.com:514", tag: "omni", facility: 16},
+		},
+		{
+			uri: "syslog://host:1514?facility=local3&tag=api&format=rfc5424&hostname=web1&msgid=http&sdid=meta@12345",
+			expected: syslogConfig{network: "tcp", address: "host:1514", tag: "api", facility: 19, format: backends.SyslogFormatRFC5424,
+				hostname: "web1", msgID: "http", sdID: "meta@12345"},
+		},
+		{
+			uri:      "syslog://host?format=rfc5424&structured=true",
+			expected: syslogConfig{network: "tcp", address: "host:514", tag: "omni", facility: 16, format: backends.SyslogFormatRFC5424, structured: true},
+		},
+		{
+			uri:      "syslog://host?format=bsd",
+			expected: syslogConfig{network: "tcp", address: "host:514", tag: "omni", facility: 16, format: backends.SyslogFormatRFC3164},
+		},
+		{
+			uri:      "syslog+udp://10.0.0.1",
+			expected: syslogConfig{network: "udp", address: "10.0.0.1:514", tag: "omni", facility: 16},
+		},
+		{
+			uri:      "syslog+unixgram:///dev/log?buffer=50",
+			expected: syslogConfig{network: "unixgram", address: "/dev/log", tag: "omni", facility: 16, bufferSize: 50},
+		},
+		{
+			uri: "syslog+tls://[::1]?ca=/ca.pem&cert=/c.pem&key=/k.pem&server_name=logs&framing=newline&timeout=2s",
+			expected: syslogConfig{network: backends.SyslogNetworkTLS, address: "[::1]:6514", tag: "omni", facility: 16,
+				framing: backends.SyslogFramingNewline, caFile: "/ca.pem", certFile: "/c.pem", keyFile: "/k.pem", serverName: "logs", writeTimeout: 2 * time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			cfg, err := parseSyslogURI(tt.uri)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if cfg != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, cfg)
+			}
+		})
+	}
+
+	for _, uri := range []string{
+		"syslog://host?facility=local9",
+		"syslog://host?format=json",
+		"syslog://host?facilty=local3",
+		"syslog+udp:///dev/log",
+		"syslog://host?ca=/ca.pem",
+		"syslog+tls://host?cert=/c.pem",
+		"syslog+tcp://host?buffer=0",
+		"syslog+quic://host",
+		"syslog://host?structured=true",
+		"syslog://host?format=rfc5424&structured=maybe",
+	} {
+		if _, err := parseSyslogURI(uri); err == nil {
+			t.Errorf("Expected error for %q", uri)
+		}
+	}
+}
+
+func TestSyslogSeverity(t *testing.T) {
+	expected := map[int]int{
+		LevelTrace: backends.SyslogSeverityDebug,
+		LevelDebug: backends.SyslogSeverityDebug,
+		LevelInfo:  backends.SyslogSeverityInfo,
+		LevelWarn:  backends.SyslogSeverityWarning,
+		LevelError: backends.SyslogSeverityError,
+	}
+	for level, severity := range expected {
+		if got := syslogSeverity(level); got != severity {
+			t.Errorf("syslogSeverity(%d) = %d, want %d", level, got, severity)
+		}
+	}
+}
+
+func TestSyslogDestinationRFC5424(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	lines := make(chan string, 10)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	logger, err := New(filepath.Join(t.TempDir(), "test.log"))
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	uri := "syslog://" + listener.Addr().String() + "?facility=local3&tag=api&format=rfc5424&hostname=web1&structured=true"
+	if err := logger.AddDestination(uri); err != nil {
+		t.Fatalf("Failed to add syslog destination: %v", err)
+	}
+
+	logger.WarnWithFields("slow request", map[string]interface{}{"path": `/a"b]`})
+	logger.Error("failed")
+	if err := logger.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	pid := os.Getpid()
+	expected := []string{
+		fmt.Sprintf(`<156>1 web1 api %d - [fields@32473 path="/a\"b\]"] slow request`, pid),
+		fmt.Sprintf(`<155>1 web1 api %d - - failed`, pid),
+	}
+
+	for i, want := range expected {
+		select {
+		case line := <-lines:
+			// Drop the timestamp between the version and the hostname
+			prefix, rest, _ := strings.Cut(line, " ")
+			_, rest, _ = strings.Cut(rest, " ")
+			if got := prefix + " " + rest; got != want {
+				t.Errorf("Expected %q, got %q", want, line)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("Timed out waiting for syslog message %d", i)
+		}
+	}
+}
+
+func TestSyslogDestinationFormatter(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	lines := make(chan string, 10)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	logger, err := New(filepath.Join(t.TempDir(), "test.log"))
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	// The basic format is the default and carries the formatted entry
+	if err := logger.AddDestination("syslog://" + listener.Addr().String() + "?tag=api"); err != nil {
+		t.Fatalf("Failed to add syslog destination: %v", err)
+	}
+	if err := logger.SetDestinationFormatter("syslog://"+listener.Addr().String()+"?tag=api", formatters.NewJSONFormatter()); err != nil {
+		t.Fatalf("Failed to set formatter: %v", err)
+	}
+
+	logger.WarnWithFields("slow request", map[string]interface{}{"path": "/a"})
+	if err := logger.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	select {
+	case line := <-lines:
+		header, payload, _ := strings.Cut(line, " ")
+		var entry map[string]interface{}
+		if header != "<132>api:" || json.Unmarshal([]byte(payload), &entry) != nil || entry["message"] != "slow request" {
+			t.Errorf("Expected the JSON entry as the message, got %q", line)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Timed out waiting for syslog message")
+	}
+}
+
+func TestSyslogDestinationCEF(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	lines := make(chan string, 10)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	logger, err := New(filepath.Join(t.TempDir(), "test.log"))
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	formatter := formatters.NewCEFFormatter()
+	formatter.Product = "checkout"
+	formatter.FieldMap = map[string]string{"client_ip": "src"}
+	logger.SetFormatter(formatter)
+
+	if err := logger.AddDestination("syslog://" + listener.Addr().String() + "?tag=api&format=rfc3164&hostname=web1"); err != nil {
+		t.Fatalf("Failed to add syslog destination: %v", err)
+	}
+
+	logger.WarnWithFields("login failed", map[string]interface{}{"event_id": "auth.failed", "client_ip": "10.0.0.1"})
+	if err := logger.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	select {
+	case line := <-lines:
+		// The CEF event is the syslog message, without the fields repeated
+		_, event, _ := strings.Cut(line, ": ")
+		if !strings.HasPrefix(line, "<132>") || !strings.HasPrefix(event, "CEF:0|Omni|checkout|1.0|auth.failed|login failed|6|rt=") || !strings.HasSuffix(event, " src=10.0.0.1") {
+			t.Errorf("Unexpected syslog message %q", line)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Timed out waiting for syslog message")
+	}
+}
+
+func TestSyslogDestinationTLS(t *testing.T) {
+	cert := testhelpers.NewTestCertificate(t)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", cert.ServerConfig())
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	messages := make(chan string, 10)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			// Octet counting: "LEN SP MSG"
+			length, err := r.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(length))
+			if err != nil {
+				return
+			}
+			message := make([]byte, n)
+			if _, err := io.ReadFull(r, message); err != nil {
+				return
+			}
+			messages <- string(message)
+		}
+	}()
+
+	logger, err := New(filepath.Join(t.TempDir(), "test.log"))
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	query := url.Values{
+		"ca":          {cert.CertFile},
+		"cert":        {cert.CertFile},
+		"key":         {cert.KeyFile},
+		"server_name": {"localhost"},
+		"tag":         {"secure"},
+	}
+	if err := logger.AddDestination("syslog+tls://" + listener.Addr().String() + "?" + query.Encode()); err != nil {
+		t.Fatalf("Failed to add TLS syslog destination: %v", err)
+	}
+
+	logger.Info("over tls")
+	if err := logger.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	select {
+	case message := <-messages:
+		if !strings.HasPrefix(message, "<134>secure: [") || !strings.HasSuffix(message, "] [INFO] over tls") {
+			t.Errorf("Unexpected message %q", message)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Timed out waiting for TLS syslog message")
+	}
+
+	// A missing CA file is reported when the destination is added
+	if err := logger.AddDestination("syslog+tls://" + listener.Addr().String() + "?ca=/nonexistent/ca.pem"); err == nil {
+		t.Error("Expected error for missing CA file")
+	}
+}
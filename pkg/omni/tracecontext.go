@@ -0,0 +1,51 @@
+package omni
+
+import "context"
+
+// traceContextKey is the context key holding a traceContext
+type traceContextKey struct{}
+
+// traceContext holds the W3C trace and span IDs of the current operation
+type traceContext struct {
+	traceID string
+	spanID  string
+}
+
+// ContextWithTrace returns a copy of ctx carrying a trace ID and span ID, as
+// lower-case hex. Loggers obtained with WithContext add them to every entry
+// as the trace_id and span_id fields, which destinations such as OTLP map to
+// their dedicated trace context fields.
+//
+// With the OpenTelemetry SDK:
+//
+//	sc := trace.SpanContextFromContext(ctx)
+//	ctx = omni.ContextWithTrace(ctx, sc.TraceID().String(), sc.SpanID().String())
+//	logger.WithContext(ctx).Info("handled request")
+func ContextWithTrace(ctx context.Context, traceID, spanID string) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, traceContext{traceID: traceID, spanID: spanID})
+}
+
+// TraceFromContext returns the trace ID and span ID stored by ContextWithTrace
+func TraceFromContext(ctx context.Context) (traceID, spanID string, ok bool) {
+	if ctx == nil {
+		return "", "", false
+	}
+	tc, ok := ctx.Value(traceContextKey{}).(traceContext)
+	return tc.traceID, tc.spanID, ok
+}
+
+// traceFields returns the trace context of ctx as entry fields, or nil
+func traceFields(ctx context.Context) map[string]interface{} {
+	traceID, spanID, ok := TraceFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	fields := make(map[string]interface{}, 2)
+	if traceID != "" {
+		fields["trace_id"] = traceID
+	}
+	if spanID != "" {
+		fields["span_id"] = spanID
+	}
+	return fields
+}
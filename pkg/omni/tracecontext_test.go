@@ -0,0 +1,38 @@
+package omni
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestTraceContext(t *testing.T) {
+	if _, _, ok := TraceFromContext(context.Background()); ok {
+		t.Error("Expected no trace context")
+	}
+	var none context.Context
+	if fields := traceFields(none); fields != nil {
+		t.Errorf("Expected no fields, got %v", fields)
+	}
+
+	ctx := ContextWithTrace(context.Background(), "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7")
+	traceID, spanID, ok := TraceFromContext(ctx)
+	if !ok || traceID != "4bf92f3577b34da6a3ce929d0e0e4736" || spanID != "00f067aa0ba902b7" {
+		t.Errorf("Unexpected trace context %q/%q/%v", traceID, spanID, ok)
+	}
+
+	// Context and adapter loggers carry the trace context as fields
+	logger := &ContextLogger{ctx: ctx}
+	expected := map[string]interface{}{
+		"trace_id": "4bf92f3577b34da6a3ce929d0e0e4736",
+		"span_id":  "00f067aa0ba902b7",
+		"order":    "A-17",
+	}
+	if fields := logger.WithField("order", "A-17").(*LoggerAdapter).fields; !reflect.DeepEqual(fields, expected) {
+		t.Errorf("Expected fields %v, got %v", expected, fields)
+	}
+	adapter := (&LoggerAdapter{fields: map[string]interface{}{"order": "A-17"}}).WithContext(ctx)
+	if fields := adapter.(*LoggerAdapter).fields; !reflect.DeepEqual(fields, expected) {
+		t.Errorf("Expected fields %v, got %v", expected, fields)
+	}
+}
@@ -28,10 +28,50 @@ type FilterFunc = types.FilterFunc
 type FormatOptions = formatters.FormatOptions
 type LevelFormat = formatters.LevelFormat
 type FormatOption = formatters.FormatOption
+type JSONKeys = formatters.JSONKeys
+type JSONLevelStyle = formatters.JSONLevelStyle
+type JSONPreset = formatters.JSONPreset
+type CSVExtra = formatters.CSVExtra
+
+// Vendor schemas for JSON output, selected with FormatOptions.JSONPreset
+const (
+	JSONPresetNone    = formatters.JSONPresetNone
+	JSONPresetECS     = formatters.JSONPresetECS
+	JSONPresetGCP     = formatters.JSONPresetGCP
+	JSONPresetDatadog = formatters.JSONPresetDatadog
+)
+
+// Level value styles for JSON output, selected with FormatOptions.JSONLevelStyle
+const (
+	JSONLevelDefault = formatters.JSONLevelDefault
+	JSONLevelLower   = formatters.JSONLevelLower
+	JSONLevelUpper   = formatters.JSONLevelUpper
+	JSONLevelNumber  = formatters.JSONLevelNumber
+	JSONLevelSyslog  = formatters.JSONLevelSyslog
+	JSONLevelGCP     = formatters.JSONLevelGCP
+)
+
+// Handling of fields outside the CSV and TSV columns, selected with FormatOptions.CSVExtra
+const (
+	CSVExtraJSON = formatters.CSVExtraJSON
+	CSVExtraDrop = formatters.CSVExtraDrop
+)
 
 // Re-export features types for backward compatibility
 type Redactor = features.Redactor
 
+// Re-export disk space types from the backends package
+type DiskSpaceConfig = backends.DiskSpaceConfig
+type LowSpacePolicy = backends.LowSpacePolicy
+
+// Low-space policies for file destinations with a DiskSpaceConfig
+const (
+	LowSpaceCleanup   = backends.LowSpaceCleanup
+	LowSpaceShedDebug = backends.LowSpaceShedDebug
+	LowSpaceFallback  = backends.LowSpaceFallback
+	LowSpaceStop      = backends.LowSpaceStop
+)
+
 // BatchConfig defines batching configuration
 type BatchConfig struct {
 	MaxSize       int
@@ -178,6 +218,7 @@ type Destination struct {
 	Size           int64            // Current file size
 	Done           chan struct{}    // Done channel for shutdown
 	Enabled        bool             // Whether destination is enabled
+	formatter      Formatter        // Formats entries for this destination instead of the logger's formatter
 	mu             sync.RWMutex
 	isHealthy      bool
 	lastError      error
@@ -193,6 +234,11 @@ type Destination struct {
 	batchMaxSize  int
 	batchMaxCount int
 	batchWriter   interface{} // Generic batch writer
+
+	// Logger's console formatter adapted to File, and what it was adapted from
+	console     *formatters.ConsoleFormatter
+	consoleBase *formatters.ConsoleFormatter
+	consoleFile *os.File
 }
 
 // GetBackend returns the backend for this destination
@@ -209,6 +255,35 @@ func (d *Destination) SetBackend(backend backends.Backend) {
 	d.backend = backend
 }
 
+// GetFormatter returns the formatter of this destination, or nil when it
+// uses the logger's formatter
+func (d *Destination) GetFormatter() Formatter {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.formatter
+}
+
+// SetFormatter sets the formatter of this destination. Nil restores the
+// logger's formatter.
+func (d *Destination) SetFormatter(formatter Formatter) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.formatter = formatter
+}
+
+// consoleFormatter returns the logger's console formatter adapted to this
+// destination, so that only terminals get colours and relative timestamps
+func (d *Destination) consoleFormatter(base *formatters.ConsoleFormatter) *formatters.ConsoleFormatter {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.console == nil || d.consoleBase != base || d.consoleFile != d.File {
+		d.console = base.ForFile(d.File)
+		d.consoleBase = base
+		d.consoleFile = d.File
+	}
+	return d.console
+}
+
 // IsHealthy returns whether the destination is healthy
 func (d *Destination) IsHealthy() bool {
 	d.mu.RLock()
@@ -0,0 +1,98 @@
+package reader
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/wayneeseguin/omni/pkg/formatters"
+	"github.com/wayneeseguin/omni/pkg/types"
+)
+
+// binaryReadSize is how much binary input is read at a time
+const binaryReadSize = 32 * 1024
+
+// entryDecoder decodes the first entry of data and returns the remaining bytes
+type entryDecoder func(data []byte) (*types.LogEntry, []byte, error)
+
+// decoder returns the entry decoder of a binary format, or nil for line formats
+func (f Format) decoder() entryDecoder {
+	switch f {
+	case FormatMsgpack:
+		return formatters.DecodeMsgpackEntry
+	case FormatCBOR:
+		return formatters.DecodeCBOREntry
+	default:
+		return nil
+	}
+}
+
+// detectBinary returns the binary format of src from its first byte. Entries
+// written by MsgpackFormatter start with a MessagePack fixmap (0x80-0x8f) and
+// entries written by CBORFormatter with a CBOR map (0xa0-0xbf). Neither byte
+// can start UTF-8 text, so line formats are never mistaken for them.
+func detectBinary(src *bufio.Reader) Format {
+	head, err := src.Peek(1)
+	if err != nil {
+		return FormatAuto
+	}
+	switch {
+	case head[0] >= 0x80 && head[0] <= 0x8f:
+		return FormatMsgpack
+	case head[0] >= 0xa0 && head[0] <= 0xbf:
+		return FormatCBOR
+	default:
+		return FormatAuto
+	}
+}
+
+// feedEntry wraps an entry decoded from a binary format. Binary records are
+// numbered by entry rather than line.
+func (p *parser) feedEntry(entry *types.LogEntry) *Record {
+	p.line++
+	return p.newRecord(entry)
+}
+
+// scanEntries decodes each entry of src with decode and calls fn for
+// matching records
+func (r *Reader) scanEntries(src io.Reader, p *parser, decode entryDecoder, fn func(Record) error) error {
+	var buf []byte
+	chunk := make([]byte, binaryReadSize)
+	for {
+		// Decode every complete entry buffered so far
+		pending := buf
+		for len(pending) > 0 {
+			entry, rest, err := decode(pending)
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("entry %d: %w", p.line+1, err)
+			}
+			pending = rest
+			if err := r.emit(p.feedEntry(entry), fn); err != nil {
+				return err
+			}
+		}
+		buf = append(buf[:0], pending...)
+		if len(buf) > maxLineSize {
+			return fmt.Errorf("entry %d: exceeds %d bytes", p.line+1, maxLineSize)
+		}
+
+		n, err := src.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+		if errors.Is(err, io.EOF) {
+			if n > 0 {
+				continue
+			}
+			if len(buf) > 0 {
+				return fmt.Errorf("entry %d: %w", p.line+1, io.ErrUnexpectedEOF)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
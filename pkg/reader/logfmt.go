@@ -0,0 +1,148 @@
+package reader
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/wayneeseguin/omni/pkg/formatters"
+	"github.com/wayneeseguin/omni/pkg/types"
+)
+
+// logfmtPair is a key=value pair of a logfmt line
+type logfmtPair struct {
+	key    string
+	value  string
+	quoted bool
+}
+
+// ParseLogfmt parses a logfmt line into its key/value pairs. Quoted values
+// are unescaped and keys without a value map to "". When a key repeats, the
+// last value wins.
+func ParseLogfmt(line string) (map[string]string, error) {
+	pairs, err := scanLogfmt(line)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		values[pair.key] = pair.value
+	}
+	return values, nil
+}
+
+// scanLogfmt splits a logfmt line into pairs
+func scanLogfmt(line string) ([]logfmtPair, error) {
+	var pairs []logfmtPair
+	i := 0
+	for {
+		for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+			i++
+		}
+		if i == len(line) {
+			return pairs, nil
+		}
+
+		start := i
+		for i < len(line) && line[i] != '=' && line[i] != ' ' && line[i] != '\t' {
+			if line[i] == '"' {
+				return nil, fmt.Errorf("logfmt: quote in key at offset %d", i)
+			}
+			i++
+		}
+		if i == start {
+			return nil, fmt.Errorf("logfmt: missing key at offset %d", i)
+		}
+		pair := logfmtPair{key: line[start:i]}
+
+		if i < len(line) && line[i] == '=' {
+			i++
+			if i < len(line) && line[i] == '"' {
+				end := i + 1
+				for end < len(line) && line[end] != '"' {
+					if line[end] == '\\' {
+						end++
+					}
+					end++
+				}
+				if end >= len(line) {
+					return nil, fmt.Errorf("logfmt: unterminated quoted value for %q", pair.key)
+				}
+				value, err := strconv.Unquote(line[i : end+1])
+				if err != nil {
+					return nil, fmt.Errorf("logfmt: invalid quoted value for %q: %w", pair.key, err)
+				}
+				pair.value, pair.quoted = value, true
+				i = end + 1
+			} else {
+				start = i
+				for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+					i++
+				}
+				pair.value = line[start:i]
+			}
+		}
+
+		pairs = append(pairs, pair)
+	}
+}
+
+// looksLikeLogfmt reports whether line starts with a key=value pair and
+// carries at least one of the keys LogfmtFormatter writes first
+func looksLikeLogfmt(line string) bool {
+	if !textFieldPattern.MatchString(line) {
+		return false
+	}
+	for _, key := range []string{formatters.LogfmtTimeKey, formatters.LogfmtLevelKey, formatters.LogfmtMessageKey} {
+		if strings.HasPrefix(line, key+"=") || strings.Contains(line, " "+key+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseLogfmt parses a logfmt line written by LogfmtFormatter. Dotted keys
+// of flattened maps are kept as they are; unquoted numbers, booleans and
+// null are converted as for text lines.
+func (p *parser) parseLogfmt(line string) (*Record, bool) {
+	pairs, err := scanLogfmt(line)
+	if err != nil {
+		return nil, false
+	}
+
+	entry := &types.LogEntry{}
+	for _, pair := range pairs {
+		switch pair.key {
+		case formatters.LogfmtTimeKey:
+			entry.Timestamp = pair.value
+		case formatters.LogfmtLevelKey:
+			entry.Level = pair.value
+		case formatters.LogfmtMessageKey:
+			entry.Message = pair.value
+		case formatters.LogfmtStackKey:
+			entry.StackTrace = pair.value
+		default:
+			if entry.Fields == nil {
+				entry.Fields = make(map[string]interface{})
+			}
+			// Fields named like a reserved key are written with a prefix
+			key := pair.key
+			if name, ok := strings.CutPrefix(key, formatters.LogfmtFieldPrefix); ok {
+				switch name {
+				case formatters.LogfmtTimeKey, formatters.LogfmtLevelKey, formatters.LogfmtMessageKey, formatters.LogfmtStackKey:
+					key = name
+				}
+			}
+			switch {
+			case pair.quoted:
+				entry.Fields[key] = pair.value
+			case pair.value == "null":
+				entry.Fields[key] = nil
+			default:
+				entry.Fields[key] = parseTextValue(pair.value)
+			}
+		}
+	}
+
+	return p.newRecord(entry), true
+}
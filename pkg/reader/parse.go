@@ -0,0 +1,324 @@
+package reader
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/formatters"
+	"github.com/wayneeseguin/omni/pkg/types"
+)
+
+// Format identifies the encoding of log lines
+type Format int
+
+const (
+	// FormatAuto detects MessagePack or CBOR from the first byte of a file,
+	// and otherwise JSON, logfmt or text per line
+	FormatAuto Format = iota
+	// FormatJSON parses line-delimited JSON written by JSONFormatter, with or
+	// without a JSON preset, and JSON array files written with one entry per line
+	FormatJSON
+	// FormatText parses "[timestamp] [LEVEL] message key=value" lines written by TextFormatter
+	FormatText
+	// FormatLogfmt parses "ts=... level=... msg=..." lines written by LogfmtFormatter
+	FormatLogfmt
+	// FormatMsgpack decodes MessagePack entries written by MsgpackFormatter
+	FormatMsgpack
+	// FormatCBOR decodes CBOR entries written by CBORFormatter
+	FormatCBOR
+)
+
+// LevelUnknown is reported for records whose level could not be parsed
+const LevelUnknown = -1
+
+// Record is a log entry read back from a file
+type Record struct {
+	Entry  *types.LogEntry // Parsed entry
+	Time   time.Time       // Parsed timestamp, zero if missing or unparseable
+	Level  int             // Numeric level (LevelTrace..LevelError) or LevelUnknown
+	Source string          // Path of the file the record was read from
+	Line   int             // Line number of the record within Source; entry number for binary formats
+}
+
+// Reserved JSON keys that are not treated as fields when fields are flattened
+var jsonReservedKeys = map[string]bool{
+	"timestamp":   true,
+	"level":       true,
+	"message":     true,
+	"fields":      true,
+	"stack_trace": true,
+	"metadata":    true,
+	"file":        true,
+	"line":        true,
+}
+
+// jsonEntryKeys are the entry keys of Omni's JSON schema and of each preset
+var jsonEntryKeys = formatters.JSONReadKeys()
+
+// textHeaderPattern matches the "[timestamp] [LEVEL] " prefix of text lines
+var textHeaderPattern = regexp.MustCompile(`^\[([^\]]+)\] \[([A-Za-z]+)\] ?(.*)$`)
+
+// textFieldPattern matches a trailing key=value field in a text line
+var textFieldPattern = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_.\-]*)=(.*)$`)
+
+// defaultTimeFormats are tried in order when parsing timestamps
+var defaultTimeFormats = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02 15:04:05.000",
+	"2006-01-02 15:04:05",
+}
+
+// parser turns lines into records. Text records may span several lines
+// (multi-line messages and stack traces), so a record is only complete once
+// the next record starts or the input ends.
+type parser struct {
+	format      Format
+	timeFormats []string
+	source      string
+	line        int
+	pending     *Record
+	array       bool // The input is a JSON array with one entry per line
+}
+
+// newParser creates a parser for lines read from source
+func newParser(format Format, timeFormat, source string) *parser {
+	timeFormats := defaultTimeFormats
+	if timeFormat != "" {
+		timeFormats = append([]string{timeFormat}, defaultTimeFormats...)
+	}
+	return &parser{format: format, timeFormats: timeFormats, source: source}
+}
+
+// feed parses one line and returns the record it completes, if any
+func (p *parser) feed(line string) *Record {
+	p.line++
+	line = strings.TrimRight(line, "\r")
+
+	if p.format != FormatText {
+		// JSON array files open with "[", close with "]" and lead every entry
+		// after the first with a comma
+		trimmed := strings.TrimSpace(line)
+		if (trimmed == "[" || trimmed == "]") && (p.format == FormatJSON || p.array || p.line == 1) {
+			p.array = true
+			return nil
+		}
+		if object := strings.TrimPrefix(line, ","); strings.HasPrefix(object, "{") {
+			if record, ok := p.parseJSON(object); ok {
+				p.array = p.array || len(object) < len(line)
+				return p.replacePending(record)
+			}
+		}
+	}
+
+	if p.format == FormatLogfmt || (p.format == FormatAuto && looksLikeLogfmt(line)) {
+		if record, ok := p.parseLogfmt(line); ok {
+			return p.replacePending(record)
+		}
+	}
+
+	if p.format == FormatJSON || p.format == FormatLogfmt {
+		if strings.TrimSpace(line) == "" {
+			return nil
+		}
+		// Not valid JSON or logfmt: keep the raw line so nothing is silently lost
+		return p.replacePending(p.newRecord(&types.LogEntry{Message: line}))
+	}
+
+	if matches := textHeaderPattern.FindStringSubmatch(line); matches != nil {
+		return p.replacePending(p.parseText(matches[1], matches[2], matches[3]))
+	}
+
+	// Continuation of a multi-line text record
+	if p.pending != nil {
+		entry := p.pending.Entry
+		if entry.StackTrace != "" {
+			entry.StackTrace += "\n" + line
+		} else {
+			entry.Message += "\n" + line
+		}
+		return nil
+	}
+
+	if strings.TrimSpace(line) == "" {
+		return nil
+	}
+	return p.replacePending(p.newRecord(&types.LogEntry{Message: line}))
+}
+
+// flush returns the pending record at the end of the input
+func (p *parser) flush() *Record {
+	record := p.pending
+	p.pending = nil
+	return record
+}
+
+// replacePending stores record as pending and returns the previous pending record
+func (p *parser) replacePending(record *Record) *Record {
+	previous := p.pending
+	p.pending = record
+	return previous
+}
+
+// newRecord wraps an entry, resolving its timestamp and level
+func (p *parser) newRecord(entry *types.LogEntry) *Record {
+	return &Record{
+		Entry:  entry,
+		Time:   p.parseTime(entry.Timestamp),
+		Level:  ParseLevel(entry.Level),
+		Source: p.source,
+		Line:   p.line,
+	}
+}
+
+// parseJSON parses a JSON line
+func (p *parser) parseJSON(line string) (*Record, bool) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &raw); err != nil {
+		return nil, false
+	}
+
+	// Entries written with a JSON preset use its keys, such as "@timestamp" and "log.level"
+	read := make(map[string]bool)
+	entry := &types.LogEntry{
+		Timestamp:  jsonEntryValue(raw, read, func(k formatters.JSONKeys) string { return k.Timestamp }),
+		Level:      jsonEntryValue(raw, read, func(k formatters.JSONKeys) string { return k.Level }),
+		Message:    jsonEntryValue(raw, read, func(k formatters.JSONKeys) string { return k.Message }),
+		StackTrace: jsonEntryValue(raw, read, func(k formatters.JSONKeys) string { return k.StackTrace }),
+		File:       stringValue(raw["file"]),
+	}
+	if line, ok := raw["line"].(float64); ok {
+		entry.Line = int(line)
+	}
+	for _, keys := range jsonEntryKeys {
+		if source, ok := raw[keys.Source].(map[string]interface{}); keys.Source != "" && ok {
+			entry.File = stringValue(source["file"])
+			if line, ok := source["line"].(float64); ok {
+				entry.Line = int(line)
+			}
+			read[keys.Source] = true
+			break
+		}
+	}
+	if metadata, ok := raw["metadata"].(map[string]interface{}); ok {
+		entry.Metadata = metadata
+	}
+
+	// Fields are nested under "fields" unless the formatter flattened them
+	if fields, ok := raw["fields"].(map[string]interface{}); ok {
+		entry.Fields = fields
+	}
+	for key, value := range raw {
+		if jsonReservedKeys[key] || read[key] {
+			continue
+		}
+		if entry.Fields == nil {
+			entry.Fields = make(map[string]interface{})
+		}
+		entry.Fields[key] = value
+	}
+
+	return p.newRecord(entry), true
+}
+
+// jsonEntryValue returns the string under the first key selected from
+// jsonEntryKeys that raw holds, and marks the key as read
+func jsonEntryValue(raw map[string]interface{}, read map[string]bool, key func(formatters.JSONKeys) string) string {
+	for _, keys := range jsonEntryKeys {
+		name := key(keys)
+		if value, ok := raw[name].(string); ok {
+			read[name] = true
+			return value
+		}
+	}
+	return ""
+}
+
+// parseText parses the body of a text line after its timestamp and level.
+// Structured entries end with "key=value " pairs; values containing spaces
+// cannot be told apart from the message and are left in the message.
+func (p *parser) parseText(timestamp, level, body string) *Record {
+	entry := &types.LogEntry{
+		Timestamp: timestamp,
+		Level:     level,
+	}
+
+	// The stack trace follows the fields and may continue on later lines
+	if idx := strings.Index(body, "stack_trace="); idx >= 0 && (idx == 0 || body[idx-1] == ' ') {
+		entry.StackTrace = strings.TrimSuffix(body[idx+len("stack_trace="):], " ")
+		body = body[:idx]
+	}
+
+	// Structured entries always end with a space after the last field
+	if strings.HasSuffix(body, " ") {
+		tokens := strings.Split(strings.TrimRight(body, " "), " ")
+		end := len(tokens)
+		for end > 1 && textFieldPattern.MatchString(tokens[end-1]) {
+			end--
+		}
+		if end < len(tokens) {
+			entry.Fields = make(map[string]interface{}, len(tokens)-end)
+			for _, token := range tokens[end:] {
+				matches := textFieldPattern.FindStringSubmatch(token)
+				entry.Fields[matches[1]] = parseTextValue(matches[2])
+			}
+		}
+		body = strings.Join(tokens[:end], " ")
+	}
+
+	entry.Message = body
+	return p.newRecord(entry)
+}
+
+// parseTime parses a timestamp using the configured formats
+func (p *parser) parseTime(value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	for _, format := range p.timeFormats {
+		if t, err := time.Parse(format, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// ParseLevel converts a level name or symbol into its numeric value. Google
+// Cloud severities map to the nearest level.
+func ParseLevel(level string) int {
+	switch strings.ToUpper(strings.TrimSpace(level)) {
+	case "TRACE", "T":
+		return formatters.LevelTrace
+	case "DEBUG", "D":
+		return formatters.LevelDebug
+	case "INFO", "I", "NOTICE":
+		return formatters.LevelInfo
+	case "WARN", "WARNING", "W":
+		return formatters.LevelWarn
+	case "ERROR", "E", "CRITICAL", "ALERT", "EMERGENCY":
+		return formatters.LevelError
+	default:
+		return LevelUnknown
+	}
+}
+
+// parseTextValue converts text field values to numbers and booleans where possible,
+// matching how the same values decode from JSON
+func parseTextValue(value string) interface{} {
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return f
+	}
+	if b, err := strconv.ParseBool(value); err == nil {
+		return b
+	}
+	return value
+}
+
+// stringValue returns v as a string if it is one
+func stringValue(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
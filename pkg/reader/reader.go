@@ -0,0 +1,445 @@
+// Package reader reads log entries back from files written by omni.
+//
+// A Reader walks a destination's rotated files (plain, gzip or zstd) in
+// chronological order followed by the active file, parses JSON, logfmt and
+// text lines or MessagePack and CBOR entries back into types.LogEntry values
+// and applies time, level, message and field filters. Follow keeps reading
+// the active file as it grows and across rotations, like tail -F.
+package reader
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/features"
+)
+
+// DefaultPollInterval is how often Follow checks the active file for new data
+const DefaultPollInterval = 250 * time.Millisecond
+
+// maxLineSize bounds a single log line; longer lines are reported as errors
+const maxLineSize = 4 * 1024 * 1024
+
+// ErrStop can be returned from a record callback to stop reading without an error
+var ErrStop = errors.New("stop reading")
+
+// Option configures a Reader
+type Option func(*Reader)
+
+// Reader reads log entries from a destination path and its rotated files
+type Reader struct {
+	path           string
+	format         Format
+	timeFormat     string
+	since          time.Time
+	until          time.Time
+	filters        []features.FilterFunc
+	includeRotated bool
+	pollInterval   time.Duration
+	tail           int
+}
+
+// New creates a Reader for the destination at path
+func New(path string, opts ...Option) *Reader {
+	r := &Reader{
+		path:           path,
+		format:         FormatAuto,
+		includeRotated: true,
+		pollInterval:   DefaultPollInterval,
+		tail:           -1,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// WithFormat sets the line format instead of detecting it per line
+func WithFormat(format Format) Option {
+	return func(r *Reader) {
+		r.format = format
+	}
+}
+
+// WithTimeFormat sets an additional timestamp layout to try before the defaults
+func WithTimeFormat(layout string) Option {
+	return func(r *Reader) {
+		r.timeFormat = layout
+	}
+}
+
+// WithTimeRange keeps records with since <= timestamp < until. A zero bound is open.
+// Records without a parseable timestamp are dropped when a bound is set.
+func WithTimeRange(since, until time.Time) Option {
+	return func(r *Reader) {
+		r.since = since
+		r.until = until
+	}
+}
+
+// WithLevel keeps records at or above the given level
+func WithLevel(minLevel int) Option {
+	return WithFilter(features.CreateLevelFilter(minLevel))
+}
+
+// WithMessageRegex keeps records whose message matches the pattern
+func WithMessageRegex(pattern *regexp.Regexp) Option {
+	return WithFilter(features.CreateRegexFilter(pattern))
+}
+
+// WithExcludeRegex drops records whose message matches the pattern
+func WithExcludeRegex(pattern *regexp.Regexp) Option {
+	return WithFilter(features.CreateExcludeRegexFilter(pattern))
+}
+
+// WithField keeps records whose field equals one of the values.
+// Numbers read back from files are float64, so numeric values must be given as float64.
+func WithField(field string, values ...interface{}) Option {
+	return WithFilter(features.CreateFieldFilter(field, values...))
+}
+
+// WithFilter adds a filter. Records must pass every filter to be returned.
+func WithFilter(filter features.FilterFunc) Option {
+	return func(r *Reader) {
+		if filter != nil {
+			r.filters = append(r.filters, filter)
+		}
+	}
+}
+
+// WithRotated controls whether rotated files are read before the active file
+func WithRotated(include bool) Option {
+	return func(r *Reader) {
+		r.includeRotated = include
+	}
+}
+
+// WithPollInterval sets how often Follow checks for new data
+func WithPollInterval(interval time.Duration) Option {
+	return func(r *Reader) {
+		if interval > 0 {
+			r.pollInterval = interval
+		}
+	}
+}
+
+// WithTail makes Follow start with the last n matching records instead of all
+// of them. Zero starts with new records only.
+func WithTail(n int) Option {
+	return func(r *Reader) {
+		r.tail = n
+	}
+}
+
+// Files returns the files that will be read, oldest first. The active file is
+// last and is included only if it exists.
+func (r *Reader) Files() ([]string, error) {
+	var files []string
+
+	if r.includeRotated {
+		rotated, err := features.NewRotationManager().GetRotatedFiles(r.path)
+		if err != nil {
+			return nil, fmt.Errorf("listing rotated files: %w", err)
+		}
+		// A missing or unreadable manifest only means no files can be skipped by content
+		manifest, _ := features.ReadManifest(r.path)
+
+		// GetRotatedFiles returns the newest file first
+		for i := len(rotated) - 1; i >= 0; i-- {
+			if r.outsideRange(rotated[i], manifest) {
+				continue
+			}
+			files = append(files, rotated[i].Path)
+		}
+	}
+
+	if _, err := os.Stat(r.path); err == nil {
+		files = append(files, r.path)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// Each calls fn for every matching record, oldest first. Returning ErrStop
+// from fn stops reading and Each returns nil.
+func (r *Reader) Each(fn func(Record) error) error {
+	files, err := r.Files()
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if err := r.readFile(file, fn); err != nil {
+			if errors.Is(err, ErrStop) {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadAll returns every matching record, oldest first
+func (r *Reader) ReadAll() ([]Record, error) {
+	var records []Record
+	err := r.Each(func(record Record) error {
+		records = append(records, record)
+		return nil
+	})
+	return records, err
+}
+
+// Match reports whether a record passes the reader's time range and filters
+func (r *Reader) Match(record Record) bool {
+	if !r.since.IsZero() || !r.until.IsZero() {
+		if record.Time.IsZero() {
+			return false
+		}
+		if !r.since.IsZero() && record.Time.Before(r.since) {
+			return false
+		}
+		if !r.until.IsZero() && !record.Time.Before(r.until) {
+			return false
+		}
+	}
+
+	for _, filter := range r.filters {
+		if !filter(record.Level, record.Entry.Message, record.Entry.Fields) {
+			return false
+		}
+	}
+	return true
+}
+
+// outsideRange reports whether a rotated file holds no entries in the time
+// range. Entries in a rotated file were all written before it was rotated,
+// and the manifest, when present, records the exact time range of each file.
+func (r *Reader) outsideRange(file features.RotatedFileInfo, manifest *features.Manifest) bool {
+	if r.since.IsZero() && r.until.IsZero() {
+		return false
+	}
+	if !r.since.IsZero() && !file.RotationTime.IsZero() && file.RotationTime.Before(r.since) {
+		return true
+	}
+	if manifest != nil {
+		if entry, ok := manifest.Entry(file.Name); ok {
+			return !entry.Overlaps(r.since, r.until)
+		}
+	}
+	return false
+}
+
+// readFile parses a complete file and calls fn for each matching record
+func (r *Reader) readFile(path string, fn func(Record) error) error {
+	src, err := features.OpenLogFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// Removed by cleanup between listing and opening
+			return nil
+		}
+		return err
+	}
+	defer func() { _ = src.Close() }() // Best effort close after reading
+
+	p := newParser(r.format, r.timeFormat, path)
+	if err := r.scan(src, p, fn); err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+	return r.emit(p.flush(), fn)
+}
+
+// Scan parses records from src instead of the reader's files and calls fn
+// for every matching record. Compressed input is detected and decompressed.
+// Records report source as their Source. Returning ErrStop from fn stops
+// reading and Scan returns nil.
+func (r *Reader) Scan(src io.Reader, source string, fn func(Record) error) error {
+	buffered := bufio.NewReader(src)
+	content, err := features.NewDecompressingReader(io.NopCloser(buffered), features.DetectCompression(buffered))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = content.Close() }() // Best effort close of the decompressor
+
+	p := newParser(r.format, r.timeFormat, source)
+	err = r.scan(content, p, fn)
+	if err == nil {
+		err = r.emit(p.flush(), fn)
+	}
+	if errors.Is(err, ErrStop) {
+		return nil
+	}
+	return err
+}
+
+// scan reads the records of src, decoding binary entries or parsing lines
+func (r *Reader) scan(src io.Reader, p *parser, fn func(Record) error) error {
+	buffered := bufio.NewReader(src)
+	if p.format == FormatAuto {
+		if format := detectBinary(buffered); format != FormatAuto {
+			p.format = format
+		}
+	}
+	if decode := p.format.decoder(); decode != nil {
+		return r.scanEntries(buffered, p, decode, fn)
+	}
+	return r.scanLines(buffered, p, fn)
+}
+
+// scanLines feeds each line of src to p and calls fn for completed records
+func (r *Reader) scanLines(src io.Reader, p *parser, fn func(Record) error) error {
+	scanner := bufio.NewScanner(src)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		if err := r.emit(p.feed(scanner.Text()), fn); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// emit calls fn with record if it is complete and matches
+func (r *Reader) emit(record *Record, fn func(Record) error) error {
+	if record == nil || !r.Match(*record) {
+		return nil
+	}
+	return fn(*record)
+}
+
+// Follow reads the existing records and then waits for new ones, following
+// the active file across rotations until ctx is cancelled or fn returns an
+// error. Returning ErrStop from fn stops following and Follow returns nil.
+func (r *Reader) Follow(ctx context.Context, fn func(Record) error) error {
+	err := r.follow(ctx, fn)
+	if errors.Is(err, ErrStop) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return nil
+	}
+	return err
+}
+
+func (r *Reader) follow(ctx context.Context, fn func(Record) error) error {
+	if r.format.decoder() != nil {
+		return fmt.Errorf("following %s: binary formats can only be read, not followed", r.path)
+	}
+
+	// Open the active file before listing rotated files so a rotation in
+	// between is seen as a rotation rather than losing entries
+	t := newTailer(r.path)
+	defer t.close()
+	if err := t.open(); err != nil {
+		return err
+	}
+
+	history := fn
+	var buffered []Record
+	if r.tail >= 0 {
+		history = func(record Record) error {
+			if r.tail == 0 {
+				return nil
+			}
+			if len(buffered) == r.tail {
+				buffered = buffered[1:]
+			}
+			buffered = append(buffered, record)
+			return nil
+		}
+	}
+
+	if r.includeRotated {
+		files, err := r.Files()
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if file == r.path {
+				continue
+			}
+			if err := r.readFile(file, history); err != nil {
+				return err
+			}
+		}
+	}
+
+	p := newParser(r.format, r.timeFormat, r.path)
+	if err := r.drain(t, p, history); err != nil {
+		return err
+	}
+	// A record at the end of the existing data is complete unless more lines
+	// continue it, which is unlikely once writers have flushed
+	if err := r.emit(p.flush(), history); err != nil {
+		return err
+	}
+	for _, record := range buffered {
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		if err := r.drain(t, p, fn); err != nil {
+			return err
+		}
+
+		rotated, err := t.rotated()
+		if err != nil {
+			return err
+		}
+		if rotated {
+			// Anything written to the old file before the rotation has been
+			// drained; an unterminated last line is still a line
+			if partial := t.takePartial(); partial != "" {
+				if err := r.emit(p.feed(partial), fn); err != nil {
+					return err
+				}
+			}
+			if err := r.emit(p.flush(), fn); err != nil {
+				return err
+			}
+			t.close()
+			if err := t.open(); err != nil {
+				return err
+			}
+			p = newParser(r.format, r.timeFormat, r.path)
+			if err := r.drain(t, p, fn); err != nil {
+				return err
+			}
+		}
+
+		// Complete a pending record once no continuation lines arrived
+		if !t.hasPartial() {
+			if err := r.emit(p.flush(), fn); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// drain parses the complete lines available from the tailer
+func (r *Reader) drain(t *tailer, p *parser, fn func(Record) error) error {
+	lines, err := t.read()
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		if err := r.emit(p.feed(line), fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,676 @@
+package reader
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wayneeseguin/omni/pkg/backends"
+	"github.com/wayneeseguin/omni/pkg/features"
+	"github.com/wayneeseguin/omni/pkg/formatters"
+	"github.com/wayneeseguin/omni/pkg/types"
+)
+
+// writeLogFile writes lines to path, compressed with the given type
+func writeLogFile(t *testing.T, path string, ct features.CompressionType, lines ...string) {
+	t.Helper()
+
+	content := []byte(strings.Join(lines, "\n") + "\n")
+	if ct != features.CompressionNone {
+		var buf bytes.Buffer
+		w, err := features.NewCompressingWriter(&buf, ct, features.CompressionLevelDefault)
+		if err != nil {
+			t.Fatalf("Failed to create writer: %v", err)
+		}
+		if _, err := w.Write(content); err != nil {
+			t.Fatalf("Failed to write lines: %v", err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Failed to close writer: %v", err)
+		}
+		content = buf.Bytes()
+	}
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+// rotatedPath returns the rotated file name for path at the given time
+func rotatedPath(path string, at time.Time, ct features.CompressionType) string {
+	return path + "." + at.Format(features.RotationTimeFormat) + features.CompressionSuffix(ct)
+}
+
+func messages(records []Record) []string {
+	result := make([]string, len(records))
+	for i, record := range records {
+		result[i] = record.Entry.Message
+	}
+	return result
+}
+
+func TestReaderReadsRotatedAndCompressedFilesInOrder(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "app.log")
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	writeLogFile(t, rotatedPath(logPath, base, features.CompressionNone), features.CompressionNone,
+		`[2024-01-01T00:00:00Z] [INFO] one`)
+	writeLogFile(t, rotatedPath(logPath, base.Add(time.Minute), features.CompressionGzip), features.CompressionGzip,
+		`{"level":"info","message":"two","timestamp":"2024-01-01T00:01:00Z"}`)
+	writeLogFile(t, rotatedPath(logPath, base.Add(2*time.Minute), features.CompressionZstd), features.CompressionZstd,
+		`[2024-01-01T00:02:00Z] [WARN] three`)
+	// Stream-compressed active file without a suffix
+	writeLogFile(t, logPath, features.CompressionGzip,
+		`{"level":"error","message":"four","timestamp":"2024-01-01T00:03:00Z"}`)
+
+	records, err := New(logPath).ReadAll()
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+
+	got := strings.Join(messages(records), ",")
+	if got != "one,two,three,four" {
+		t.Errorf("Expected records in chronological order, got %s", got)
+	}
+	if records[3].Source != logPath {
+		t.Errorf("Expected last record from active file, got %s", records[3].Source)
+	}
+
+	records, err = New(logPath, WithRotated(false)).ReadAll()
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if len(records) != 1 {
+		t.Errorf("Expected only the active file to be read, got %d records", len(records))
+	}
+}
+
+func TestReaderTornFrame(t *testing.T) {
+	for _, ct := range []features.CompressionType{features.CompressionGzip, features.CompressionZstd} {
+		t.Run(features.CompressionTypeString(ct), func(t *testing.T) {
+			dir := t.TempDir()
+			logPath := filepath.Join(dir, "app.log")
+
+			// Two frames, the second torn by a crash while it was appended
+			writeLogFile(t, filepath.Join(dir, "first"), ct, `[2024-01-01T00:00:00Z] [INFO] one`)
+			writeLogFile(t, filepath.Join(dir, "second"), ct, `[2024-01-01T00:01:00Z] [INFO] two`)
+			first, _ := os.ReadFile(filepath.Join(dir, "first"))
+			second, _ := os.ReadFile(filepath.Join(dir, "second"))
+			if err := os.WriteFile(logPath, append(first, second[:len(second)/2]...), 0600); err != nil {
+				t.Fatalf("Failed to write %s: %v", logPath, err)
+			}
+
+			records, err := New(logPath, WithRotated(false)).ReadAll()
+			if err != nil {
+				t.Fatalf("ReadAll failed: %v", err)
+			}
+			if got := strings.Join(messages(records), ","); got != "one" {
+				t.Errorf("Expected the records of the complete frame, got %v", messages(records))
+			}
+		})
+	}
+}
+
+func TestReaderJSONArray(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "app.json")
+	json := formatters.NewJSONFormatter()
+	json.Options.JSONArray = true
+	start, _ := json.StartStream()
+	end, _ := json.EndStream()
+	stream := backends.FileStream{Start: start, Separator: json.StreamSeparator(), End: end}
+
+	// Each run appends to the array; the reopened file loses its "]" first
+	write := func(entries ...string) {
+		t.Helper()
+		backend, err := backends.NewFileBackend(logPath)
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", logPath, err)
+		}
+		if err := backend.SetStream(stream); err != nil {
+			t.Fatalf("SetStream failed: %v", err)
+		}
+		for _, entry := range entries {
+			if _, err := backend.Write([]byte(entry + "\n")); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+		}
+		if err := backend.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+	}
+	write(`{"level":"info","message":"one","timestamp":"2024-01-01T00:00:00Z"}`,
+		`{"level":"warn","message":"two","timestamp":"2024-01-01T00:01:00Z"}`)
+	write(`{"level":"error","message":"three","timestamp":"2024-01-01T00:02:00Z"}`)
+
+	content, _ := os.ReadFile(logPath)
+	if !strings.HasPrefix(string(content), "[\n{") || !strings.Contains(string(content), "\n,{") || strings.Count(string(content), "]") != 1 {
+		t.Fatalf("Unexpected JSON array file %q", content)
+	}
+
+	for _, format := range []Format{FormatAuto, FormatJSON} {
+		records, err := New(logPath, WithRotated(false), WithFormat(format)).ReadAll()
+		if err != nil {
+			t.Fatalf("ReadAll failed: %v", err)
+		}
+		if got := strings.Join(messages(records), ","); got != "one,two,three" {
+			t.Errorf("Format %d: expected the array entries, got %q", format, messages(records))
+		}
+		if len(records) == 3 && records[2].Level != formatters.LevelError {
+			t.Errorf("Format %d: unexpected level %d", format, records[2].Level)
+		}
+	}
+
+	entry, err := features.ScanLogFile(logPath)
+	if err != nil {
+		t.Fatalf("ScanLogFile failed: %v", err)
+	}
+	if entry.EntryCount != 3 || entry.LevelCounts["error"] != 1 {
+		t.Errorf("Unexpected manifest entry %+v", entry)
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	p := newParser(FormatAuto, "", "app.log")
+
+	p.feed(`{"fields":{"user":"bob","n":3},"level":"INFO","message":"login","timestamp":"2024-01-01T00:00:00Z","stack_trace":"trace"}`)
+	record := p.flush()
+	if record == nil {
+		t.Fatal("Expected a record")
+	}
+	if record.Level != formatters.LevelInfo || record.Entry.Message != "login" {
+		t.Errorf("Unexpected record: level=%d message=%q", record.Level, record.Entry.Message)
+	}
+	if record.Entry.Fields["user"] != "bob" || record.Entry.Fields["n"] != float64(3) {
+		t.Errorf("Unexpected fields: %v", record.Entry.Fields)
+	}
+	if record.Entry.StackTrace != "trace" {
+		t.Errorf("Unexpected stack trace: %q", record.Entry.StackTrace)
+	}
+	if !record.Time.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected time: %v", record.Time)
+	}
+
+	// Flattened fields are collected from the top level
+	p.feed(`{"level":"debug","message":"flat","user":"alice"}`)
+	record = p.flush()
+	if record.Level != formatters.LevelDebug || record.Entry.Fields["user"] != "alice" {
+		t.Errorf("Unexpected flattened record: %+v", record.Entry)
+	}
+}
+
+func TestParseText(t *testing.T) {
+	p := newParser(FormatAuto, "", "app.log")
+
+	if record := p.feed(`[2024-01-01T00:00:00Z] [WARN] disk almost full path=/var free=10 `); record != nil {
+		t.Fatal("Expected the record to stay pending until the next line")
+	}
+	p.feed(`[2024-01-01T00:00:01Z] [ERROR] failed err=boom stack_trace=goroutine 1 [running]:`)
+	first := p.feed(`main.main()`)
+	if first != nil {
+		t.Fatal("Expected continuation line to be appended")
+	}
+	second := p.feed(`[2024-01-01 00:00:02.000] [INFO] plain message`)
+
+	if second == nil || second.Entry.Message != "failed" {
+		t.Fatalf("Unexpected record: %+v", second)
+	}
+	if second.Entry.Fields["err"] != "boom" {
+		t.Errorf("Unexpected fields: %v", second.Entry.Fields)
+	}
+	if second.Entry.StackTrace != "goroutine 1 [running]:\nmain.main()" {
+		t.Errorf("Unexpected stack trace: %q", second.Entry.StackTrace)
+	}
+
+	last := p.flush()
+	if last.Entry.Message != "plain message" || last.Entry.Fields != nil {
+		t.Errorf("Unexpected plain record: %+v", last.Entry)
+	}
+	if last.Time.IsZero() {
+		t.Error("Expected custom timestamp layout to be parsed")
+	}
+
+	p = newParser(FormatText, "", "app.log")
+	p.feed(`[2024-01-01T00:00:00Z] [WARN] disk almost full path=/var free=10 `)
+	record := p.flush()
+	if record.Entry.Message != "disk almost full" || record.Entry.Fields["path"] != "/var" || record.Entry.Fields["free"] != float64(10) {
+		t.Errorf("Unexpected structured record: %+v", record.Entry)
+	}
+}
+
+func TestParseLogfmt(t *testing.T) {
+	// Lines written by the logfmt formatter are read back
+	line, err := formatters.NewLogfmtFormatter().Format(types.LogMessage{Entry: &types.LogEntry{
+		Timestamp: "2024-01-01T00:00:00Z",
+		Level:     "WARN",
+		Message:   "disk \"almost\" full",
+		Fields: map[string]interface{}{
+			"free":  10,
+			"owner": nil,
+			"msg":   "inner",
+			"disk":  map[string]interface{}{"path": "/var log"},
+		},
+		StackTrace: "main.main()\n\tmain.go:10",
+	}})
+	if err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	p := newParser(FormatAuto, "", "app.log")
+	p.feed(strings.TrimSuffix(string(line), "\n"))
+	record := p.flush()
+	if record.Level != formatters.LevelWarn || record.Entry.Message != `disk "almost" full` || record.Time.IsZero() {
+		t.Errorf("Unexpected record: %+v", record.Entry)
+	}
+	fields := record.Entry.Fields
+	if fields["free"] != float64(10) || fields["owner"] != nil || fields["msg"] != "inner" || fields["disk.path"] != "/var log" {
+		t.Errorf("Unexpected fields: %v", fields)
+	}
+	if record.Entry.StackTrace != "main.main()\n\tmain.go:10" {
+		t.Errorf("Unexpected stack trace: %q", record.Entry.StackTrace)
+	}
+
+	// Lines without a logfmt key are plain messages when detecting
+	p.feed("mode=fast startup")
+	if record := p.flush(); record.Entry.Message != "mode=fast startup" || record.Entry.Fields != nil {
+		t.Errorf("Unexpected plain record: %+v", record.Entry)
+	}
+
+	// Invalid lines are kept as messages
+	p = newParser(FormatLogfmt, "", "app.log")
+	p.feed(`msg="unterminated`)
+	if record := p.flush(); record.Entry.Message != `msg="unterminated` {
+		t.Errorf("Unexpected invalid record: %+v", record.Entry)
+	}
+}
+
+func TestParseLogfmtPairs(t *testing.T) {
+	values, err := ParseLogfmt(`a=1 b="two words" c= d e="x=\"y\"" a=3`)
+	if err != nil {
+		t.Fatalf("ParseLogfmt failed: %v", err)
+	}
+	expected := map[string]string{"a": "3", "b": "two words", "c": "", "d": "", "e": `x="y"`}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+
+	for _, line := range []string{`=1`, `a="open`, `a"b=1`, `a="bad \q"`} {
+		if _, err := ParseLogfmt(line); err == nil {
+			t.Errorf("Expected error for %q", line)
+		}
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := map[string]int{
+		"TRACE":   formatters.LevelTrace,
+		"debug":   formatters.LevelDebug,
+		"I":       formatters.LevelInfo,
+		"warning": formatters.LevelWarn,
+		"Error":   formatters.LevelError,
+		"fatal":   LevelUnknown,
+	}
+	for input, expected := range tests {
+		if got := ParseLevel(input); got != expected {
+			t.Errorf("ParseLevel(%q) = %d, want %d", input, got, expected)
+		}
+	}
+}
+
+func TestReaderFilters(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "app.log")
+	writeLogFile(t, logPath, features.CompressionNone,
+		`{"fields":{"user":"bob"},"level":"DEBUG","message":"cache miss","timestamp":"2024-01-01T00:00:00Z"}`,
+		`{"fields":{"user":"bob"},"level":"INFO","message":"login ok","timestamp":"2024-01-01T00:01:00Z"}`,
+		`{"fields":{"user":"eve"},"level":"WARN","message":"login failed","timestamp":"2024-01-01T00:02:00Z"}`,
+		`{"level":"error","message":"db down","timestamp":"2024-01-01T00:03:00Z"}`,
+	)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		expected string
+	}{
+		{"level", []Option{WithLevel(formatters.LevelWarn)}, "login failed,db down"},
+		{"regex", []Option{WithMessageRegex(regexp.MustCompile(`^login`))}, "login ok,login failed"},
+		{"exclude", []Option{WithExcludeRegex(regexp.MustCompile(`login`))}, "cache miss,db down"},
+		{"field", []Option{WithField("user", "bob")}, "cache miss,login ok"},
+		{"time range", []Option{WithTimeRange(base.Add(time.Minute), base.Add(3*time.Minute))}, "login ok,login failed"},
+		{"combined", []Option{WithLevel(formatters.LevelInfo), WithField("user", "bob")}, "login ok"},
+		{"custom", []Option{WithFilter(features.CreateFieldNotExistsFilter("user"))}, "db down"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := New(logPath, tt.opts...).ReadAll()
+			if err != nil {
+				t.Fatalf("ReadAll failed: %v", err)
+			}
+			if got := strings.Join(messages(records), ","); got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestReaderJSONPresets(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		name   string
+		preset formatters.JSONPreset
+	}{
+		{"ecs", formatters.JSONPresetECS},
+		{"gcp", formatters.JSONPresetGCP},
+		{"datadog", formatters.JSONPresetDatadog},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			json := formatters.NewJSONFormatter()
+			json.Options.JSONPreset = tt.preset
+			var lines []string
+			for i, level := range []string{"DEBUG", "INFO", "WARN", "ERROR"} {
+				at := base.Add(time.Duration(i) * time.Minute)
+				entry := &types.LogEntry{Timestamp: at.Format(time.RFC3339), Level: level, Message: strings.ToLower(level) + " message", Fields: map[string]interface{}{"user": "bob"}}
+				if level == "ERROR" {
+					entry.StackTrace = "main.go:1"
+					entry.File, entry.Line = "main.go", 12
+				}
+				data, err := json.Format(types.LogMessage{Timestamp: at, Entry: entry})
+				if err != nil {
+					t.Fatalf("Format failed: %v", err)
+				}
+				lines = append(lines, strings.TrimSuffix(string(data), "\n"))
+			}
+			logPath := filepath.Join(t.TempDir(), "app.log")
+			writeLogFile(t, logPath, features.CompressionNone, lines...)
+
+			records, err := New(logPath, WithLevel(formatters.LevelWarn), WithTimeRange(base.Add(time.Minute), time.Time{})).ReadAll()
+			if err != nil {
+				t.Fatalf("ReadAll failed: %v", err)
+			}
+			if got := strings.Join(messages(records), ","); got != "warn message,error message" {
+				t.Fatalf("Expected the warn and error messages, got %s", got)
+			}
+			last := records[1]
+			if last.Level != formatters.LevelError || !last.Time.Equal(base.Add(3*time.Minute)) || last.Entry.Fields["user"] != "bob" {
+				t.Errorf("Unexpected record %+v", last)
+			}
+			if last.Entry.StackTrace != "main.go:1" {
+				t.Errorf("Expected the stack trace, got %q", last.Entry.StackTrace)
+			}
+			if tt.preset == formatters.JSONPresetGCP && (last.Entry.File != "main.go" || last.Entry.Line != 12) {
+				t.Errorf("Expected the source location, got %s:%d", last.Entry.File, last.Entry.Line)
+			}
+			for key := range last.Entry.Fields {
+				if key != "user" && !strings.HasPrefix(key, "ecs.") {
+					t.Errorf("Unexpected field %s in %v", key, last.Entry.Fields)
+				}
+			}
+		})
+	}
+}
+
+func TestReaderSkipsRotatedFilesBeforeRange(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "app.log")
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	oldPath := rotatedPath(logPath, base, features.CompressionNone)
+	newPath := rotatedPath(logPath, base.Add(time.Hour), features.CompressionNone)
+	writeLogFile(t, oldPath, features.CompressionNone, `[2024-01-01T00:00:00Z] [INFO] old`)
+	writeLogFile(t, newPath, features.CompressionNone, `[2024-01-01T00:30:00Z] [INFO] new`)
+
+	files, err := New(logPath, WithTimeRange(base.Add(10*time.Minute), time.Time{})).Files()
+	if err != nil {
+		t.Fatalf("Files failed: %v", err)
+	}
+	if len(files) != 1 || files[0] != newPath {
+		t.Errorf("Expected only %s, got %v", newPath, files)
+	}
+}
+
+func TestReaderSkipsRotatedFilesByManifest(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "app.log")
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	rm := features.NewRotationManager()
+	early := rotatedPath(logPath, base.Add(time.Hour), features.CompressionNone)
+	late := rotatedPath(logPath, base.Add(2*time.Hour), features.CompressionNone)
+	writeLogFile(t, early, features.CompressionNone, `[2024-01-01T00:00:00Z] [INFO] early`)
+	writeLogFile(t, late, features.CompressionNone, `[2024-01-01T01:30:00Z] [INFO] late`)
+	for _, path := range []string{early, late} {
+		if err := rm.RecordRotation(logPath, path); err != nil {
+			t.Fatalf("RecordRotation failed: %v", err)
+		}
+	}
+
+	// Both files were rotated after the window starts, only the manifest tells them apart
+	files, err := New(logPath, WithTimeRange(base.Add(time.Hour), time.Time{})).Files()
+	if err != nil {
+		t.Fatalf("Files failed: %v", err)
+	}
+	if len(files) != 1 || files[0] != late {
+		t.Errorf("Expected only %s, got %v", late, files)
+	}
+
+	files, err = New(logPath, WithTimeRange(time.Time{}, base.Add(time.Hour))).Files()
+	if err != nil {
+		t.Fatalf("Files failed: %v", err)
+	}
+	if len(files) != 1 || files[0] != early {
+		t.Errorf("Expected only %s, got %v", early, files)
+	}
+}
+
+func TestReaderScan(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := features.NewCompressingWriter(&buf, features.CompressionGzip, features.CompressionLevelDefault)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	_, _ = w.Write([]byte("[2024-01-01T00:00:00Z] [DEBUG] skipped\n[2024-01-01T00:00:01Z] [ERROR] kept\n"))
+	_ = w.Close()
+
+	var records []Record
+	err = New("", WithLevel(formatters.LevelInfo)).Scan(&buf, "stdin", func(record Record) error {
+		records = append(records, record)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(records) != 1 || records[0].Entry.Message != "kept" || records[0].Source != "stdin" {
+		t.Errorf("Unexpected records: %+v", records)
+	}
+}
+
+func TestReaderBinary(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		name      string
+		format    Format
+		formatter types.Formatter
+	}{
+		{"msgpack", FormatMsgpack, formatters.NewMsgpackFormatter()},
+		{"cbor", FormatCBOR, formatters.NewCBORFormatter()},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// Enough entries to span several reads
+			var content []byte
+			for i := 0; i < 2000; i++ {
+				data, err := tt.formatter.Format(types.LogMessage{
+					Timestamp: base.Add(time.Duration(i) * time.Second),
+					Entry:     &types.LogEntry{Level: "INFO", Message: "request", Fields: map[string]interface{}{"n": i, "at": base}},
+				})
+				if err != nil {
+					t.Fatalf("Format failed: %v", err)
+				}
+				content = append(content, data...)
+			}
+			logPath := filepath.Join(t.TempDir(), "app.log")
+			if err := os.WriteFile(logPath, content, 0600); err != nil {
+				t.Fatalf("Failed to write %s: %v", logPath, err)
+			}
+
+			for _, format := range []Format{FormatAuto, tt.format} {
+				records, err := New(logPath, WithFormat(format), WithTimeRange(base.Add(1500*time.Second), time.Time{})).ReadAll()
+				if err != nil {
+					t.Fatalf("ReadAll failed: %v", err)
+				}
+				if len(records) != 500 {
+					t.Fatalf("Expected 500 records, got %d", len(records))
+				}
+				last := records[len(records)-1]
+				if last.Line != 2000 || last.Level != formatters.LevelInfo || last.Entry.Fields["n"] != int64(1999) || last.Entry.Fields["at"] != base {
+					t.Errorf("Unexpected last record %+v", last)
+				}
+			}
+
+			// A truncated last entry is reported
+			if err := os.WriteFile(logPath, content[:len(content)-1], 0600); err != nil {
+				t.Fatalf("Failed to write %s: %v", logPath, err)
+			}
+			if _, err := New(logPath, WithFormat(tt.format)).ReadAll(); err == nil || !strings.Contains(err.Error(), "entry 2000") {
+				t.Errorf("Expected error for the truncated entry 2000, got %v", err)
+			}
+			if err := New(logPath, WithFormat(tt.format)).Follow(context.Background(), func(Record) error { return nil }); err == nil {
+				t.Error("Expected error following a binary format")
+			}
+		})
+	}
+}
+
+func TestReaderEachStop(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "app.log")
+	writeLogFile(t, logPath, features.CompressionNone, `[2024-01-01T00:00:00Z] [INFO] a`, `[2024-01-01T00:00:01Z] [INFO] b`)
+
+	count := 0
+	err := New(logPath).Each(func(Record) error {
+		count++
+		return ErrStop
+	})
+	if err != nil || count != 1 {
+		t.Errorf("Expected to stop after one record, got count=%d err=%v", count, err)
+	}
+}
+
+// collector gathers records from Follow
+type collector struct {
+	mu      sync.Mutex
+	records []string
+}
+
+func (c *collector) add(record Record) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.records = append(c.records, record.Entry.Message)
+	return nil
+}
+
+func (c *collector) waitFor(t *testing.T, expected string) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		c.mu.Lock()
+		got := strings.Join(c.records, ",")
+		c.mu.Unlock()
+		if got == expected {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t.Fatalf("Expected %s, got %s", expected, strings.Join(c.records, ","))
+}
+
+func appendLine(t *testing.T, path, line string) {
+	t.Helper()
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(line + "\n"); err != nil {
+		t.Fatalf("Failed to append: %v", err)
+	}
+}
+
+func TestReaderFollowAcrossRotation(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "app.log")
+	appendLine(t, logPath, `[2024-01-01T00:00:00Z] [INFO] one`)
+	appendLine(t, logPath, `[2024-01-01T00:00:01Z] [INFO] two`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &collector{}
+	done := make(chan error, 1)
+	go func() {
+		done <- New(logPath, WithTail(1), WithPollInterval(10*time.Millisecond)).Follow(ctx, c.add)
+	}()
+
+	c.waitFor(t, "two")
+
+	appendLine(t, logPath, `[2024-01-01T00:00:02Z] [INFO] three`)
+	c.waitFor(t, "two,three")
+
+	// Rotate: the last write to the old file lands before the new file appears
+	appendLine(t, logPath, `[2024-01-01T00:00:03Z] [INFO] four`)
+	rotated := rotatedPath(logPath, time.Now().UTC(), features.CompressionNone)
+	if err := os.Rename(logPath, rotated); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	appendLine(t, logPath, `[2024-01-01T00:00:04Z] [INFO] five`)
+	c.waitFor(t, "two,three,four,five")
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Follow returned error: %v", err)
+	}
+}
+
+func TestReaderFollowStreamCompressed(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "app.log")
+
+	backend, err := backends.NewCompressedFileBackend(logPath, features.CompressionZstd, features.CompressionLevelDefault, 0)
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	defer backend.Close()
+
+	write := func(line string) {
+		if _, err := backend.Write([]byte(line + "\n")); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		if err := backend.Sync(); err != nil {
+			t.Fatalf("Sync failed: %v", err)
+		}
+	}
+	write(`{"level":"info","message":"one"}`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := &collector{}
+	done := make(chan error, 1)
+	go func() {
+		done <- New(logPath, WithPollInterval(10*time.Millisecond)).Follow(ctx, c.add)
+	}()
+
+	c.waitFor(t, "one")
+	write(`{"level":"info","message":"two"}`)
+	c.waitFor(t, "one,two")
+
+	if _, err := backend.RotateFile(); err != nil {
+		t.Fatalf("RotateFile failed: %v", err)
+	}
+	write(`{"level":"info","message":"three"}`)
+	c.waitFor(t, "one,two,three")
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Follow returned error: %v", err)
+	}
+}
@@ -0,0 +1,193 @@
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/wayneeseguin/omni/pkg/features"
+)
+
+// tailer reads complete lines appended to the active file. Plain files are
+// read incrementally from the last offset. Stream-compressed files only ever
+// grow by whole frames, so they are decompressed again from the start when
+// they grow and the already-read output is skipped.
+type tailer struct {
+	path        string
+	file        *os.File
+	info        os.FileInfo
+	detected    bool
+	compression features.CompressionType
+	offset      int64 // Bytes consumed: on disk for plain files, decompressed otherwise
+	lastSize    int64 // Size on disk at the last read of a compressed file
+	partial     []byte
+}
+
+// newTailer creates a tailer for path
+func newTailer(path string) *tailer {
+	return &tailer{path: filepath.Clean(path)}
+}
+
+// open opens the active file from the start. A missing file is not an error;
+// it is opened once it is created.
+func (t *tailer) open() error {
+	t.offset = 0
+	t.lastSize = 0
+	t.detected = false
+	t.compression = features.CompressionNone
+	t.partial = nil
+
+	file, err := os.Open(t.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close() // Best effort close on error path
+		return err
+	}
+	t.file = file
+	t.info = info
+	return nil
+}
+
+// close closes the current file
+func (t *tailer) close() {
+	if t.file != nil {
+		_ = t.file.Close() // Best effort close, the file was only read
+		t.file = nil
+		t.info = nil
+	}
+}
+
+// hasPartial reports whether an incomplete line is buffered
+func (t *tailer) hasPartial() bool {
+	return len(t.partial) > 0
+}
+
+// takePartial returns and clears the buffered incomplete line
+func (t *tailer) takePartial() string {
+	partial := string(t.partial)
+	t.partial = nil
+	return partial
+}
+
+// rotated reports whether the path now refers to a different file than the
+// one being read, or the file was truncated
+func (t *tailer) rotated() (bool, error) {
+	info, err := os.Stat(t.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// Renamed away and not yet recreated
+			return false, nil
+		}
+		return false, err
+	}
+	if t.file == nil {
+		return true, nil
+	}
+	if !os.SameFile(t.info, info) {
+		return true, nil
+	}
+	if t.compression == features.CompressionNone && info.Size() < t.offset {
+		return true, nil
+	}
+	return false, nil
+}
+
+// read returns the complete lines appended since the last read
+func (t *tailer) read() ([]string, error) {
+	if t.file == nil {
+		if err := t.open(); err != nil || t.file == nil {
+			return nil, err
+		}
+	}
+
+	info, err := t.file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+
+	if !t.detected {
+		if size == 0 {
+			return nil, nil
+		}
+		magic := make([]byte, 4)
+		n, err := t.file.ReadAt(magic, 0)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		t.compression = features.DetectCompression(bufio.NewReader(bytes.NewReader(magic[:n])))
+		t.detected = true
+	}
+
+	var data []byte
+	if t.compression == features.CompressionNone {
+		if size <= t.offset {
+			return nil, nil
+		}
+		data, err = io.ReadAll(io.NewSectionReader(t.file, t.offset, size-t.offset))
+		if err != nil {
+			return nil, err
+		}
+		t.offset += int64(len(data))
+	} else {
+		if size == t.lastSize {
+			return nil, nil
+		}
+		data, err = t.readCompressed(size)
+		if err != nil {
+			return nil, err
+		}
+		t.lastSize = size
+	}
+
+	return t.splitLines(data), nil
+}
+
+// readCompressed decompresses the file up to size and returns the output
+// beyond what was already consumed
+func (t *tailer) readCompressed(size int64) ([]byte, error) {
+	src := io.NopCloser(io.NewSectionReader(t.file, 0, size))
+	decompressed, err := features.NewDecompressingReader(src, t.compression)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = decompressed.Close() }() // Best effort close of the decompressor
+
+	if _, err := io.CopyN(io.Discard, decompressed, t.offset); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(decompressed)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	t.offset += int64(len(data))
+	return data, nil
+}
+
+// splitLines appends data to the buffered partial line and returns the complete lines
+func (t *tailer) splitLines(data []byte) []string {
+	t.partial = append(t.partial, data...)
+
+	var lines []string
+	for {
+		idx := bytes.IndexByte(t.partial, '\n')
+		if idx < 0 {
+			break
+		}
+		lines = append(lines, string(t.partial[:idx]))
+		t.partial = t.partial[idx+1:]
+	}
+	if len(t.partial) == 0 {
+		t.partial = nil
+	}
+	return lines
+}